@@ -1,77 +1,513 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/h2"
 	"github.com/andrey-viktorov/auto-mock-tools/pkg/handlers"
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/openapi"
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/proxy"
 	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
 	"github.com/valyala/fasthttp"
 )
 
 func main() {
+	// Converter subcommands have their own flags
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		if code, ok := runSubcommand(os.Args[1], os.Args[2:]); ok {
+			os.Exit(code)
+		}
+	}
+
 	// Define CLI flags
 	mockDir := flag.String("mock-dir", "mocks", "Directory containing recorded mock files")
 	scenarioConfig := flag.String("mock-config", "", "YAML file describing scenario filters and responses")
+	stateFile := flag.String("state-file", "", "File persisting scenario sequence positions, after_requests/max_serves counters, response.dir rotations and captured session variables, so a restarted server resumes mid-flow")
+	scenarioTags := flag.String("scenario-tags", "", "Comma-separated tags; load only the tagged scenarios of -mock-config carrying one of them (untagged scenarios always load)")
+	onConflict := flag.String("on-conflict", "first", "Recording served when files answer the same request differently: first (by file path), newest (latest modified) or error (refuse to start)")
+	indexCache := flag.String("index-cache", "", "Cache file for the parsed mock index; reused while the mock dir is unchanged")
 	logDir := flag.String("log-dir", "mock_log", "Directory to store 404 request/response logs")
 	host := flag.String("host", "127.0.0.1", "Host to bind the server to")
 	port := flag.Int("port", 8000, "Port to bind the server to")
 	replayTiming := flag.Bool("replay-timing", false, "Replay original request/response timing (latency)")
 	jitter := flag.Float64("jitter", 0.0, "Add random jitter to timing (0.0-1.0, 0.1 = ±10%)")
+	sseGapJitter := flag.Float64("sse-gap-jitter", 0.0, "Jitter each SSE inter-event gap independently (0.0-1.0, 0.2 = ±20% per gap)")
+	methodOverride := flag.Bool("method-override", false, "Honor X-HTTP-Method-Override on POST requests when matching mocks")
+	mockIDPrefix := flag.Bool("mock-id-prefix", false, "Serve mocks under /<mock-id>/original/path when no x-mock-id header is sent")
+	contentTypeFallback := flag.Bool("content-type-fallback", false, "Answer with a same path and method mock of any content type when none matches the Accept header")
+	compressionParity := flag.Bool("compression-parity", false, "Recompress bodies with the upstream's recorded Content-Encoding (gzip, deflate, br) when the client accepts it")
+	dateHeader := flag.String("date-header", "live", "Date of mocked responses: live (current time), recorded (the recorded Date) or none")
+	serverHeader := flag.String("server-header", "recorded", "Server of mocked responses: recorded (falls back to AutoMockServer), live (always AutoMockServer) or none")
+	bodyFraming := flag.String("body-framing", "live", "Body framing of mocked responses: live (Content-Length), recorded (chunked when the recording was) or chunked")
+	respondWithHeader := flag.Bool("respond-with-header", false, "Answer requests carrying x-mock-respond-with: {\"status\",\"body\",\"delay\"} with that response instead of a mock")
+	debugHeaders := flag.Bool("debug-headers", false, "Add x-mock-matched-id, x-mock-matched-file and x-mock-scenario headers naming the recording and scenario that answered")
+	debug := flag.Bool("debug", false, "List the 3 closest mocks and the dimension each failed on in every 404 (per request: x-mock-debug: 1)")
+	templateSeed := flag.String("template-seed", "", "Seed {{uuid}}, {{randInt}} and {{fake}} with this integer and a hash of each request, so the same request renders the same values in every run (default: random)")
+	canonicalJSON := flag.Bool("canonical-json", false, "Canonicalize JSON request bodies (sorted keys, compact, normalized numbers) before scenario filters")
+	checkContentLength := flag.Bool("check-content-length", false, "Report mocks whose recorded Content-Length differs from the body actually served")
+	strict := flag.Bool("strict", false, "Count unmatched requests and exit non-zero at shutdown if any occurred")
+	chaosErrorRate := flag.Float64("chaos-error-rate", 0, "Share of matched requests (0-1) answered with a random 500, 503 or timeout instead of the mock")
+	chaosExtraLatency := flag.Duration("chaos-extra-latency", 0, "Add a random latency of up to this much (e.g. 500ms) to matched requests")
+	chaosTimeout := flag.Duration("chaos-timeout", 30*time.Second, "How long a chaos timeout holds the request before dropping the connection")
+	failOnUnused := flag.Bool("fail-on-unused", false, "List loaded mocks that were never served and exit non-zero at shutdown if any")
+	maxBodySize := flag.String("max-body-size", "", "Reject request bodies larger than this (e.g. 1MB) with 413 (default: fasthttp's 4MB)")
+	maxHeaderSize := flag.String("max-header-size", "", "Reject request headers larger than this (e.g. 8KB) with 431 (default: 4KB)")
+	notFoundStatus := flag.Int("not-found-status", 0, "Status code for requests without a mock (default 404)")
+	notFoundBody := flag.String("not-found-body", "", "Body template for requests without a mock, or @file to read it from a file (e.g. '{\"path\":\"{{request.path}}\"}')")
+	notFoundContentType := flag.String("not-found-content-type", "", "Content-Type for requests without a mock (default application/json)")
+	servedLog := flag.String("served-log", "", "Directory to write a record of every served response (final headers/body)")
+	matchLog := flag.String("match-log", "", "Directory to log matched requests in the recorder format, grouped by mock ID, for diffing against the recordings")
+	validateSpec := flag.String("validate-spec", "", "OpenAPI 3 spec (YAML or JSON); requests violating its paths, parameters or bodies get a 400 listing the violations")
+	watch := flag.Bool("watch", false, "Re-index recordings in -mock-dir as they are added, changed or removed")
+	persistAdmin := flag.Bool("persist-admin", false, "Write mocks added through /__mock__/mocks into the mock dir so they survive a restart")
+	jsonOutput := flag.Bool("json-output", false, "Print startup info as a single JSON line on stdout (banner goes to stderr)")
+	tlsCert := flag.String("tls-cert", "", "Server certificate file; serves HTTPS when set together with -tls-key")
+	tlsKey := flag.String("tls-key", "", "Server private key file for -tls-cert")
+	clientCA := flag.String("client-ca", "", "CA bundle used to verify client certificates (enables mTLS)")
+	clientAuth := flag.String("client-auth", "require", "Client certificate policy with -client-ca: require or optional")
+	readTimeout := flag.Duration("read-timeout", 0, "Max time to read a full request, including the body (0 = no limit)")
+	writeTimeout := flag.Duration("write-timeout", 0, "Max time to write a response; also caps SSE streams (0 = no limit)")
+	idleTimeout := flag.Duration("idle-timeout", 0, "Close keep-alive connections idle for this long (0 = use -read-timeout)")
+	concurrency := flag.Int("concurrency", 0, "Max concurrent connections served (0 = fasthttp default 256*1024)")
+	maxConnsPerIP := flag.Int("max-conns-per-ip", 0, "Max concurrent connections per client IP (0 = unlimited)")
+	mode := flag.String("mode", "replay", "Serving mode: replay (404 without a mock) or hybrid (proxy to -target and record when no mock matches)")
+	targetURL := flag.String("target", "", "Backend URL used for unmatched requests in -mode=hybrid")
+	http2 := flag.Bool("http2", false, "Also serve HTTP/2: h2 via ALPN with -tls-cert, h2c (prior knowledge) otherwise")
+	grpcPort := flag.Int("grpc-port", -1, "Serve gRPC mocks over unencrypted HTTP/2 (h2c) on this port (-1 = disabled, 0 = random free port)")
+	selfTest := flag.Bool("self-test", false, "Serve every loaded mock and scenario response once in-process, report the ones that fail to serve, then exit")
+	portFile := flag.String("port-file", "", "Write pid and bound address (JSON) to this file once listening; removed at shutdown")
 	flag.Parse()
 
+	if *scenarioTags != "" && *scenarioConfig == "" {
+		log.Fatal("Error: -scenario-tags requires -mock-config")
+	}
+
+	switch *mode {
+	case "replay":
+		if *targetURL != "" {
+			log.Fatal("Error: -target requires -mode=hybrid")
+		}
+	case "hybrid":
+		if *targetURL == "" {
+			log.Fatal("Error: -mode=hybrid requires -target")
+		}
+		if *scenarioConfig != "" {
+			log.Fatal("Error: -mode=hybrid cannot be combined with -mock-config (new recordings are matched by x-mock-id)")
+		}
+	default:
+		log.Fatalf("Error: unknown -mode %q (expected replay or hybrid)", *mode)
+	}
+
+	// Keep stdout machine-readable in JSON mode
+	out := os.Stdout
+	if *jsonOutput {
+		out = os.Stderr
+	}
+
 	// Create storage
-	fmt.Println("🚀 Starting mock server...")
-	fmt.Printf("📁 Loading mocks from directory: %s\n", *mockDir)
+	fmt.Fprintln(out, "🚀 Starting mock server...")
+	fmt.Fprintf(out, "📁 Loading mocks from directory: %s\n", *mockDir)
 
-	store, err := storage.NewMockStorage(*mockDir)
+	var store *storage.MockStorage
+	var err error
+	if *indexCache != "" {
+		var cacheHit bool
+		store, cacheHit, err = storage.NewMockStorageWithCache(*mockDir, *indexCache)
+		if err == nil && cacheHit {
+			fmt.Fprintf(out, "⚡ Index restored from cache: %s\n", *indexCache)
+		}
+	} else {
+		store, err = storage.NewMockStorage(*mockDir)
+	}
+	if err != nil {
+		log.Fatalf("Failed to load mocks: %v", err)
+	}
+	conflictPolicy, err := storage.ParseConflictPolicy(*onConflict)
 	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := store.SetConflictPolicy(conflictPolicy); err != nil {
 		log.Fatalf("Failed to load mocks: %v", err)
 	}
 
 	if *scenarioConfig != "" {
-		fmt.Printf("🧩 Loading scenarios from: %s\n", *scenarioConfig)
+		fmt.Fprintf(out, "🧩 Loading scenarios from: %s\n", *scenarioConfig)
+		if tags := splitList(*scenarioTags); len(tags) > 0 {
+			store.SetScenarioTags(tags)
+			fmt.Fprintf(out, "🏷️  Scenario tags: %s\n", strings.Join(tags, ", "))
+		}
 		if err := store.LoadScenarioConfig(*scenarioConfig); err != nil {
 			log.Fatalf("Failed to load scenarios: %v", err)
 		}
 	} else {
-		fmt.Println("🎯 Scenario mode: disabled (using x-mock-id header)")
+		fmt.Fprintln(out, "🎯 Scenario mode: disabled (using x-mock-id header)")
 	}
 
 	// Configure timing
 	store.SetTimingConfig(*replayTiming, *jitter)
+	store.SetSSEGapJitter(*sseGapJitter)
 	if *replayTiming {
-		fmt.Printf("⏱️  Timing replay: enabled (jitter: %.1f%%)\n", *jitter*100)
+		fmt.Fprintf(out, "⏱️  Timing replay: enabled (jitter: %.1f%%)\n", *jitter*100)
+		if *sseGapJitter > 0 {
+			fmt.Fprintf(out, "〰️  SSE per-gap jitter: %.1f%%\n", *sseGapJitter*100)
+		}
 	} else {
-		fmt.Println("⚡ Timing replay: disabled (instant responses)")
+		fmt.Fprintln(out, "⚡ Timing replay: disabled (instant responses)")
+	}
+
+	store.SetMethodOverride(*methodOverride)
+	if *methodOverride {
+		fmt.Fprintln(out, "🔀 Method override: X-HTTP-Method-Override honored on POST")
+	}
+
+	store.SetMockIDPrefix(*mockIDPrefix)
+	if *mockIDPrefix {
+		fmt.Fprintln(out, "🗂️  Mock ID prefix: mocks served under /<mock-id>/original/path")
+	}
+
+	store.SetContentTypeFallback(*contentTypeFallback)
+	if *contentTypeFallback {
+		fmt.Fprintln(out, "🎯 Content-type fallback: unusual Accept values get the recording of any content type")
+	}
+
+	store.SetCompressionParity(*compressionParity)
+	if *compressionParity {
+		fmt.Fprintln(out, "🗜️  Compression parity: bodies re-encoded with the recorded Content-Encoding")
+	}
+
+	var fidelity storage.HeaderFidelity
+	if fidelity.Date, err = storage.ParseHeaderSource("date-header", *dateHeader, storage.HeaderLive, storage.HeaderRecorded, storage.HeaderNone); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if fidelity.Server, err = storage.ParseHeaderSource("server-header", *serverHeader, storage.HeaderRecorded, storage.HeaderLive, storage.HeaderNone); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if fidelity.Framing, err = storage.ParseHeaderSource("body-framing", *bodyFraming, storage.HeaderLive, storage.HeaderRecorded, storage.HeaderChunked); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	store.SetHeaderFidelity(fidelity)
+	if fidelity != storage.DefaultHeaderFidelity {
+		fmt.Fprintf(out, "📨 Header fidelity: Date %s, Server %s, body framing %s\n", fidelity.Date, fidelity.Server, fidelity.Framing)
+	}
+
+	store.SetRespondWithHeader(*respondWithHeader)
+	if *respondWithHeader {
+		fmt.Fprintln(out, "✋ Respond-with header: x-mock-respond-with overrides matching per request")
+	}
+
+	store.SetDebugHeaders(*debugHeaders)
+	if *debugHeaders {
+		fmt.Fprintln(out, "🔎 Debug headers: x-mock-matched-id, x-mock-matched-file and x-mock-scenario on mocked responses")
+	}
+
+	store.SetDebug(*debug)
+	if *debug {
+		fmt.Fprintln(out, "🐞 Debug: 404s list the closest mocks and why they did not match")
+	}
+
+	if *templateSeed != "" {
+		seed, err := strconv.ParseInt(*templateSeed, 10, 64)
+		if err != nil {
+			log.Fatalf("Error: -template-seed must be an integer, got %q", *templateSeed)
+		}
+		storage.SetTemplateSeed(seed)
+		fmt.Fprintf(out, "🎲 Template seed: %d (random template values repeat per request)\n", seed)
+	}
+
+	store.SetCanonicalJSON(*canonicalJSON)
+	if *canonicalJSON {
+		fmt.Fprintln(out, "🧮 Canonical JSON: request bodies normalized before filter evaluation")
+	}
+
+	store.SetStrict(*strict)
+	if *strict {
+		fmt.Fprintln(out, "🚨 Strict mode: unmatched requests fail the run at shutdown")
+	}
+	if *failOnUnused {
+		fmt.Fprintln(out, "🧹 Unused mocks fail the run at shutdown")
+	}
+
+	if *chaosErrorRate < 0 || *chaosErrorRate > 1 {
+		log.Fatalf("Error: -chaos-error-rate must be between 0 and 1")
+	}
+	if *chaosExtraLatency < 0 || *chaosTimeout < 0 {
+		log.Fatalf("Error: -chaos-extra-latency and -chaos-timeout must not be negative")
+	}
+	store.SetChaos(storage.ChaosConfig{ErrorRate: *chaosErrorRate, ExtraLatency: *chaosExtraLatency, Timeout: *chaosTimeout})
+	if *chaosErrorRate > 0 || *chaosExtraLatency > 0 {
+		fmt.Fprintf(out, "🐒 Chaos mode: %.1f%% errors (500/503/timeout), up to %s extra latency\n", *chaosErrorRate*100, *chaosExtraLatency)
+	}
+
+	// Emulate gateway request size limits; zero keeps the fasthttp defaults
+	bodyLimit, headerLimit := 0, 0
+	if *maxBodySize != "" {
+		size, err := storage.ParseSize(*maxBodySize)
+		if err != nil || size <= 0 {
+			log.Fatalf("Invalid -max-body-size %q", *maxBodySize)
+		}
+		bodyLimit = int(size)
+		fmt.Fprintf(out, "📏 Max request body size: %s\n", *maxBodySize)
+	}
+	if *maxHeaderSize != "" {
+		size, err := storage.ParseSize(*maxHeaderSize)
+		if err != nil || size <= 0 {
+			log.Fatalf("Invalid -max-header-size %q", *maxHeaderSize)
+		}
+		headerLimit = int(size)
+		fmt.Fprintf(out, "📏 Max request header size: %s\n", *maxHeaderSize)
+	}
+
+	if *notFoundStatus != 0 || *notFoundBody != "" || *notFoundContentType != "" {
+		body := *notFoundBody
+		if strings.HasPrefix(body, "@") {
+			data, err := os.ReadFile(body[1:])
+			if err != nil {
+				log.Fatalf("Failed to read -not-found-body: %v", err)
+			}
+			body = string(data)
+		}
+		notFound, err := storage.NewNotFoundResponse(*notFoundStatus, *notFoundContentType, body)
+		if err != nil {
+			log.Fatalf("Invalid not found response: %v", err)
+		}
+		store.SetNotFoundResponse(notFound)
+		fmt.Fprintf(out, "🚫 Unmatched requests answered with %d (%s)\n", notFound.Status, notFound.ContentType)
+	}
+
+	if *servedLog != "" {
+		if err := store.SetServedLog(*servedLog); err != nil {
+			log.Fatalf("Failed to create served log directory: %v", err)
+		}
+		fmt.Fprintf(out, "🧾 Served responses recorded to: %s\n", *servedLog)
+	}
+
+	if *matchLog != "" {
+		if err := store.SetMatchLog(*matchLog); err != nil {
+			log.Fatalf("Failed to create match log directory: %v", err)
+		}
+		fmt.Fprintf(out, "🪞 Matched requests logged to: %s\n", *matchLog)
+	}
+
+	if *validateSpec != "" {
+		doc, err := openapi.LoadSpec(*validateSpec)
+		if err != nil {
+			log.Fatalf("Failed to load -validate-spec: %v", err)
+		}
+		validator, err := openapi.NewValidator(doc)
+		if err != nil {
+			log.Fatalf("Invalid -validate-spec: %v", err)
+		}
+		store.SetRequestValidator(validator)
+		fmt.Fprintf(out, "📐 Validating requests against %s (%d paths)\n", *validateSpec, validator.Paths())
+	}
+
+	if *watch {
+		watcher, err := store.Watch(func(file string, err error) {
+			if err != nil {
+				log.Printf("⚠️  Failed to reload %s: %v", file, err)
+				return
+			}
+			log.Printf("🔄 Reloaded %s", file)
+		})
+		if err != nil {
+			log.Fatalf("Failed to watch %s: %v", *mockDir, err)
+		}
+		defer watcher.Close()
+		fmt.Fprintf(out, "👀 Watching %s for changes\n", *mockDir)
+	}
+
+	store.SetPersistAdmin(*persistAdmin)
+	if *persistAdmin {
+		fmt.Fprintf(out, "💾 Admin mocks persisted to: %s\n", *mockDir)
+	}
+
+	// In hybrid mode unmatched requests are proxied and recorded into the mock dir,
+	// and each new recording is indexed so the next identical request replays it
+	var fallback fasthttp.RequestHandler
+	if *mode == "hybrid" {
+		recorder, err := proxy.NewRecorder(*mockDir)
+		if err != nil {
+			log.Fatalf("Failed to create recorder: %v", err)
+		}
+		defer recorder.Close()
+		recorder.SetRecordHook(func(path, mockID string) {
+			if err := store.AddResponseFile(path, mockID); err != nil {
+				log.Printf("Failed to load new recording %s: %v", path, err)
+			}
+		})
+		fallback = proxy.NewProxyHandler(recorder, *targetURL).Handle
+		fmt.Fprintf(out, "🔁 Hybrid mode: unmatched requests proxied to %s and recorded\n", *targetURL)
 	}
 
 	// Get stats
 	stats := store.GetStats()
-	fmt.Printf("📊 Loaded %d responses\n", stats["total_responses"])
-	fmt.Printf("🔗 %d unique paths\n", stats["unique_paths"])
+	fmt.Fprintf(out, "📊 Loaded %d responses\n", stats["total_responses"])
+	fmt.Fprintf(out, "🔗 %d unique paths\n", stats["unique_paths"])
 	if uniqueMockIDs, ok := stats["unique_mock_ids"].(int); ok && uniqueMockIDs > 0 {
-		fmt.Printf("🏷️  %d unique mock IDs\n", uniqueMockIDs)
+		fmt.Fprintf(out, "🏷️  %d unique mock IDs\n", uniqueMockIDs)
+	}
+
+	if failed := store.FailedFiles(); len(failed) > 0 {
+		fmt.Fprintf(out, "⚠️  %d mock file(s) failed to load\n", len(failed))
+	}
+	if conflicts := store.Conflicts(); len(conflicts) > 0 {
+		fmt.Fprintf(out, "⚠️  %d conflicting recording(s), serving the %s file\n", len(conflicts), conflictPolicy)
+		for _, c := range conflicts {
+			fmt.Fprintf(out, "   %s %s [%s]: %s over %s\n", c.Method, c.Path, c.MockID, c.Winner, strings.Join(c.Overridden, ", "))
+		}
+	}
+
+	// The served Content-Length is always the real one; this flags recordings
+	// whose captured header disagrees, which strict clients would reject
+	if *checkContentLength {
+		if mismatches := store.CheckContentLength(); len(mismatches) > 0 {
+			fmt.Fprintf(out, "⚠️  %d mock(s) with a stale Content-Length\n", len(mismatches))
+			for _, m := range mismatches {
+				fmt.Fprintf(out, "   %s %s [%s]: recorded %d, served %d\n", m.Method, m.Path, m.MockID, m.Recorded, m.Served)
+			}
+		} else {
+			fmt.Fprintln(out, "📐 Content-Length check: all recorded lengths match")
+		}
+	}
+
+	if *selfTest {
+		os.Exit(runSelfTest(out, store, *jsonOutput))
 	}
 
-	addr := fmt.Sprintf("%s:%d", *host, *port)
-	fmt.Printf("\n🌐 Server running at http://%s\n", addr)
-	fmt.Printf("📈 Stats endpoint: http://%s/__mock__/stats\n", addr)
-	fmt.Printf("📋 List endpoint: http://%s/__mock__/list\n", addr)
-	fmt.Printf("📝 404 logs directory: %s\n", *logDir)
-	fmt.Println("\nPress Ctrl+C to stop")
+	// Resume scenario flows where the previous run left them
+	var statePersister *storage.ScenarioStatePersister
+	if *stateFile != "" {
+		restored, err := store.RestoreScenarioState(*stateFile)
+		if err != nil {
+			log.Fatalf("Failed to restore scenario state: %v", err)
+		}
+		statePersister = store.PersistScenarioState(*stateFile, time.Second, func(err error) {
+			log.Printf("⚠️  Failed to save scenario state: %v", err)
+		})
+		fmt.Fprintf(out, "💾 Scenario state persisted to: %s (%d scenario(s) resumed)\n", *stateFile, restored)
+	}
+
+	// Configure HTTPS and optional client certificate verification
+	scheme := "http"
+	var tlsConfig *tls.Config
+	if *tlsCert != "" || *tlsKey != "" {
+		tlsConfig, err = loadServerTLS(*tlsCert, *tlsKey, *clientCA, *clientAuth)
+		if err != nil {
+			log.Fatalf("Failed to configure TLS: %v", err)
+		}
+		scheme = "https"
+		if *clientCA != "" {
+			fmt.Fprintf(out, "🔐 mTLS: client certificates verified against %s (%s)\n", *clientCA, *clientAuth)
+		}
+	} else if *clientCA != "" {
+		log.Fatal("Error: -client-ca requires -tls-cert and -tls-key")
+	}
+
+	// Listen before announcing so -port 0 reports the port actually bound
+	ln, err := net.Listen("tcp4", net.JoinHostPort(*host, strconv.Itoa(*port)))
+	if err != nil {
+		log.Fatalf("Error in Listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	if *replayTiming {
+		ln = handlers.ReceiptListener(ln) // Delays count from the first request bytes, body read included
+	}
+	if tlsConfig != nil && !*http2 {
+		ln = tls.NewListener(ln, tlsConfig) // With -http2 TLS is negotiated by the HTTP/2 listener
+	}
+	baseURL := scheme + "://" + addr
+
+	// gRPC needs HTTP/2, so recorded calls are served by a net/http server
+	var grpcLn net.Listener
+	if *grpcPort >= 0 {
+		grpcLn, err = net.Listen("tcp4", net.JoinHostPort(*host, strconv.Itoa(*grpcPort)))
+		if err != nil {
+			log.Fatalf("Error in gRPC Listen: %v", err)
+		}
+	}
+
+	fmt.Fprintf(out, "\n🌐 Server running at %s\n", baseURL)
+	fmt.Fprintf(out, "📈 Stats endpoint: %s/__mock__/stats\n", baseURL)
+	fmt.Fprintf(out, "📋 List endpoint: %s/__mock__/list\n", baseURL)
+	fmt.Fprintf(out, "📣 SSE emit endpoint: POST %s/__mock__/sse/{stream}/emit\n", baseURL)
+	fmt.Fprintf(out, "📝 404 logs directory: %s\n", *logDir)
+	if *http2 {
+		fmt.Fprintln(out, "🚄 HTTP/2: enabled (h2 over TLS, h2c with prior knowledge)")
+	}
+	if grpcLn != nil {
+		fmt.Fprintf(out, "🛰️  gRPC endpoint (h2c): %s\n", grpcLn.Addr())
+	}
+	fmt.Fprintln(out, "\nPress Ctrl+C to stop")
+
+	if *jsonOutput {
+		printReadiness(addr, baseURL, store)
+	}
+	if *portFile != "" {
+		if err := writePortFile(*portFile, ln.Addr()); err != nil {
+			log.Fatalf("Failed to write port file: %v", err)
+		}
+	}
 
 	// Create router
-	handler := handlers.Router(store, *logDir)
+	handler := handlers.RouterWithFallback(store, *logDir, fallback)
+	if fidelity.Date == storage.HeaderRecorded {
+		handler = handlers.LiveDate(handler) // Dates for responses without a recorded one
+	}
+	serverName := "AutoMockServer"
+	if fidelity.Server == storage.HeaderNone {
+		serverName = ""
+	}
 
 	// Create server
 	server := &fasthttp.Server{
-		Handler: handler,
-		Name:    "AutoMockServer",
+		Handler:               handler,
+		Name:                  serverName,
+		NoDefaultServerHeader: fidelity.Server == storage.HeaderNone,
+		NoDefaultDate:         fidelity.Date != storage.HeaderLive,
+		ErrorHandler:          handlers.RequestErrorHandler,
+		MaxRequestBodySize:    bodyLimit,
+		ReadBufferSize:        headerLimit, // Bounds the request line plus headers
+		ReadTimeout:           *readTimeout,
+		WriteTimeout:          *writeTimeout,
+		IdleTimeout:           *idleTimeout,
+		Concurrency:           *concurrency,
+		MaxConnsPerIP:         *maxConnsPerIP,
+	}
+
+	// fasthttp only speaks HTTP/1.1; HTTP/2 connections are bridged to the same server
+	if *http2 {
+		ln = h2.NewServer(server, tlsConfig).Listener(ln)
+	}
+
+	var grpcServer *http.Server
+	if grpcLn != nil {
+		protocols := new(http.Protocols)
+		protocols.SetUnencryptedHTTP2(true)
+		grpcServer = &http.Server{
+			Handler:      handlers.GRPCHandler(store),
+			Protocols:    protocols,
+			ReadTimeout:  *readTimeout,
+			WriteTimeout: *writeTimeout,
+			IdleTimeout:  *idleTimeout,
+		}
+		go func() {
+			if err := grpcServer.Serve(grpcLn); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Error in gRPC Serve: %v", err)
+			}
+		}()
 	}
 
 	// Handle graceful shutdown
@@ -80,15 +516,177 @@ func main() {
 		signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
 		<-sigint
 
-		fmt.Println("\n👋 Shutting down mock server...")
+		fmt.Fprintln(out, "\n👋 Shutting down mock server...")
+		// End held-open SSE streams so shutdown does not wait on them
+		store.SSEHub().Close()
 		if err := server.Shutdown(); err != nil {
 			log.Printf("Server shutdown error: %v", err)
 		}
-		os.Exit(0)
+		if grpcServer != nil {
+			grpcServer.Close()
+		}
+
+		if *portFile != "" {
+			os.Remove(*portFile)
+		}
+		if statePersister != nil {
+			if err := statePersister.Close(); err != nil {
+				log.Printf("⚠️  Failed to save scenario state: %v", err)
+			}
+		}
+
+		exitCode := 0
+		if unmatched := store.Unmatched(); unmatched != nil && unmatched.Total() > 0 {
+			fmt.Fprintf(out, "❌ Strict mode: %d unmatched request(s)\n", unmatched.Total())
+			for _, entry := range unmatched.Summary() {
+				fmt.Fprintf(out, "   %4d  %s %s\n", entry.Count, entry.Method, entry.Path)
+			}
+			exitCode = 1
+		}
+		if *failOnUnused {
+			if coverage := store.Coverage(); len(coverage.Unused) > 0 {
+				fmt.Fprintf(out, "❌ %d of %d mock(s) never served\n", len(coverage.Unused), coverage.Total)
+				for _, entry := range coverage.Unused {
+					target := entry.MockID
+					if entry.Scenario != "" {
+						target = "scenario " + entry.Scenario
+					}
+					fmt.Fprintf(out, "   %s %s [%s] %s\n", entry.Method, entry.Path, target, entry.RequestID)
+				}
+				exitCode = 1
+			}
+		}
+		os.Exit(exitCode)
 	}()
 
 	// Start server
-	if err := server.ListenAndServe(addr); err != nil {
-		log.Fatalf("Error in ListenAndServe: %v", err)
+	if err := server.Serve(ln); err != nil {
+		log.Fatalf("Error in Serve: %v", err)
+	}
+
+	// Serve returns as soon as Shutdown closes the listener; let the shutdown
+	// goroutine finish its cleanup and exit the process
+	select {}
+}
+
+// runSelfTest serves every mock once and returns the process exit code:
+// 1 when any of them failed to serve.
+func runSelfTest(out *os.File, store *storage.MockStorage, jsonOutput bool) int {
+	// Timing replay only slows the run down; the process exits afterwards
+	store.SetTimingConfig(false, 0)
+	report := handlers.SelfTest(store)
+
+	if jsonOutput {
+		line, err := json.Marshal(map[string]interface{}{
+			"event":    "self_test",
+			"served":   report.Served,
+			"skipped":  report.Skipped,
+			"failures": report.Failures,
+		})
+		if err != nil {
+			log.Fatalf("Failed to encode self-test report: %v", err)
+		}
+		fmt.Println(string(line))
+	}
+
+	fmt.Fprintf(out, "🩺 Self-test: %d response(s) served, %d skipped (need a live connection)\n", report.Served, report.Skipped)
+	if len(report.Failures) == 0 {
+		fmt.Fprintln(out, "✅ Self-test passed")
+		return 0
+	}
+	fmt.Fprintf(out, "❌ Self-test: %d response(s) failed to serve\n", len(report.Failures))
+	for _, f := range report.Failures {
+		target := f.MockID
+		if f.Scenario != "" {
+			target = "scenario " + f.Scenario
+		}
+		fmt.Fprintf(out, "   %s %s [%s] %s: %s\n", f.Method, f.Path, target, f.RequestID, f.Error)
+	}
+	return 1
+}
+
+// printReadiness writes the startup summary as one JSON line for test harnesses.
+func printReadiness(addr, baseURL string, store *storage.MockStorage) {
+	stats := store.GetStats()
+	failed := store.FailedFiles()
+	if failed == nil {
+		failed = []storage.LoadFailure{}
+	}
+
+	line, err := json.Marshal(map[string]interface{}{
+		"event":        "ready",
+		"address":      addr,
+		"url":          baseURL,
+		"mocks_loaded": stats["total_responses"],
+		"unique_paths": stats["unique_paths"],
+		"scenarios":    store.HasScenarios(),
+		"failed_files": failed,
+	})
+	if err != nil {
+		log.Fatalf("Failed to encode startup info: %v", err)
+	}
+	fmt.Println(string(line))
+}
+
+// writePortFile records the process id and bound address so parallel jobs can find the server.
+func writePortFile(path string, addr net.Addr) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"pid":     os.Getpid(),
+		"address": addr.String(),
+		"port":    addr.(*net.TCPAddr).Port,
+	})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// loadServerTLS builds the HTTPS configuration. With a client CA, client
+// certificates are verified and exposed to scenario client_cert matching.
+func loadServerTLS(certFile, keyFile, clientCAFile, clientAuth string) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("-tls-cert and -tls-key must be set together")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load server certificate: %w", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	if clientCAFile == "" {
+		return config, nil
+	}
+
+	caPEM, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %s", clientCAFile)
+	}
+	config.ClientCAs = pool
+
+	switch clientAuth {
+	case "require":
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	case "optional":
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return nil, fmt.Errorf("unknown -client-auth %q (expected require or optional)", clientAuth)
+	}
+
+	return config, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/convert"
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/openapi"
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// runSubcommand runs a converter or generator subcommand and returns its exit code.
+// ok is false when name is not a subcommand.
+func runSubcommand(name string, args []string) (code int, ok bool) {
+	switch name {
+	case "wiremock-import":
+		return runWireMockImport(args), true
+	case "wiremock-export":
+		return runWireMockExport(args), true
+	case "vcr-import":
+		return runVCRImport(args), true
+	case "gen-openapi":
+		return runGenOpenAPI(args), true
+	}
+	return 0, false
+}
+
+func runWireMockImport(args []string) int {
+	flags := flag.NewFlagSet("wiremock-import", flag.ExitOnError)
+	mappings := flags.String("mappings", "mappings", "WireMock mapping file or mappings directory")
+	files := flags.String("files", "", "Directory for bodyFileName references (default: __files next to -mappings)")
+	mockDir := flags.String("mock-dir", "mocks", "Directory to write the converted recordings to")
+	flags.Parse(args)
+
+	report, err := convert.ImportWireMock(*mappings, *files, *mockDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	printReport(report)
+	fmt.Printf("📥 Imported %d WireMock mappings into %s (%d skipped)\n", report.Converted, *mockDir, report.Skipped)
+	return 0
+}
+
+func runWireMockExport(args []string) int {
+	flags := flag.NewFlagSet("wiremock-export", flag.ExitOnError)
+	mockDir := flags.String("mock-dir", "mocks", "Directory containing recorded mock files")
+	outFile := flags.String("out", "", "File to write the WireMock mappings to (default: stdout)")
+	flags.Parse(args)
+
+	mappings, report, err := convert.ExportWireMock(*mockDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	data, err := json.MarshalIndent(mappings, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	data = append(data, '\n')
+
+	printReport(report)
+	if *outFile == "" {
+		os.Stdout.Write(data)
+		return 0
+	}
+	if err := os.WriteFile(*outFile, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "📤 Exported %d mocks to %s (%d skipped)\n", report.Converted, *outFile, report.Skipped)
+	return 0
+}
+
+func runVCRImport(args []string) int {
+	flags := flag.NewFlagSet("vcr-import", flag.ExitOnError)
+	cassettes := flags.String("cassettes", "testdata", "go-vcr cassette or httpreplay log, or a directory of them")
+	mockDir := flags.String("mock-dir", "mocks", "Directory to write the converted recordings to")
+	mockID := flags.String("mock-id", "default", "Mock ID of the converted recordings (empty = one mock ID per cassette, named after it)")
+	flags.Parse(args)
+
+	report, err := convert.ImportCassettes(*cassettes, *mockDir, *mockID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	printReport(report)
+	fmt.Printf("📼 Imported %d recorded interactions into %s (%d skipped)\n", report.Converted, *mockDir, report.Skipped)
+	return 0
+}
+
+func runGenOpenAPI(args []string) int {
+	flags := flag.NewFlagSet("gen-openapi", flag.ExitOnError)
+	mockDir := flags.String("mock-dir", "mocks", "Directory containing recorded mock files")
+	outFile := flags.String("out", "", "File to write the OpenAPI document to; .yaml/.yml writes YAML (default: JSON on stdout)")
+	title := flags.String("title", "Recorded API", "info.title of the generated document")
+	version := flags.String("version", "1.0.0", "info.version of the generated document")
+	flags.Parse(args)
+
+	store, err := storage.NewMockStorage(*mockDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	for _, failure := range store.FailedFiles() {
+		fmt.Fprintf(os.Stderr, "⚠️  %s: not loaded: %s\n", failure.File, failure.Error)
+	}
+	doc := openapi.Generate(store, openapi.Info{
+		Title:       *title,
+		Version:     *version,
+		Description: "Generated from the recordings in " + *mockDir,
+	})
+
+	var data []byte
+	switch strings.ToLower(filepath.Ext(*outFile)) {
+	case ".yaml", ".yml":
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		err = encoder.Encode(doc)
+		data = buf.Bytes()
+	default:
+		data, err = json.MarshalIndent(doc, "", "  ")
+		data = append(data, '\n')
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if *outFile == "" {
+		os.Stdout.Write(data)
+		return 0
+	}
+	if err := os.WriteFile(*outFile, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "📘 Wrote OpenAPI %s document with %d paths to %s\n", openapi.Version, len(doc.Paths), *outFile)
+	return 0
+}
+
+// printReport prints conversion warnings to stderr.
+func printReport(report *convert.Report) {
+	for _, warning := range report.Warnings {
+		fmt.Fprintf(os.Stderr, "⚠️  %s\n", warning)
+	}
+}
@@ -1,34 +1,132 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/h2"
 	"github.com/andrey-viktorov/auto-mock-tools/pkg/proxy"
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
 	"github.com/valyala/fasthttp"
 )
 
+// stringList collects the values of a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 func main() {
 	// Define CLI flags
 	logDir := flag.String("log-dir", "mocks", "Directory to store recorded mock files")
 	host := flag.String("host", "127.0.0.1", "Host to bind the proxy to")
 	port := flag.Int("port", 8080, "Port to bind the proxy to")
-	targetURL := flag.String("target", "", "Target URL to proxy requests to (e.g., http://localhost:3000)")
+	var targets stringList
+	flag.Var(&targets, "target", "Target URL to proxy requests to (e.g., http://localhost:3000), or a route like '/billing/* -> http://billing:8080 [strip]' (repeatable)")
+	routesFile := flag.String("routes", "", "File with one '/prefix/* -> http://host:port [strip]' route per line")
+	forwardMode := flag.Bool("forward", false, "Forward proxy mode: send each request to its own Host/absolute URI (use as HTTP_PROXY) instead of -target")
 	clientCert := flag.String("client-cert", "", "Path to client certificate file for mTLS (optional)")
 	clientKey := flag.String("client-key", "", "Path to client key file for mTLS (optional)")
+	mitmCACert := flag.String("mitm-ca-cert", "", "CA certificate (PEM) used to intercept HTTPS CONNECT tunnels")
+	mitmCAKey := flag.String("mitm-ca-key", "", "Private key (PEM) of -mitm-ca-cert")
+	var resolveRules stringList
+	flag.Var(&resolveRules, "resolve", "Override DNS for upstream host:port=addr (repeatable, like curl --resolve)")
+	var recordIf, skipIf stringList
+	flag.Var(&recordIf, "record-if", "Only record responses whose header matches, e.g. 'x-cache=MISS' (repeatable)")
+	flag.Var(&skipIf, "skip-if", "Skip recording when a response header matches, e.g. 'content-length>1MB' (repeatable)")
+	var recordPaths, skipPaths stringList
+	flag.Var(&recordPaths, "record-path", "Only record requests whose path matches this glob, e.g. '/api/**' (repeatable)")
+	flag.Var(&skipPaths, "skip-path", "Never record requests whose path matches this glob, e.g. '/health' (repeatable)")
+	recordMethods := flag.String("record-method", "", "Comma-separated methods to record, e.g. 'GET,POST' (default: all)")
+	recordStatuses := flag.String("record-status", "", "Comma-separated response statuses to record, e.g. '4xx,5xx' or '200-299'")
+	skipStatuses := flag.String("skip-status", "", "Comma-separated response statuses never recorded, e.g. '2xx'")
+	recordFilterFile := flag.String("record-filter", "", "YAML file with include_paths, exclude_paths, methods, statuses and exclude_statuses")
+	filenameStrategy := flag.String("filename-strategy", "timestamp", "Recorded file naming: timestamp (unique per call) or hash (method+URL+body, overwrites on re-record)")
+	format := flag.String("format", proxy.FormatJSON, "Recording file format: json (native schema) or har (HTTP Archive 1.2, one entry per file)")
+	rewriteFile := flag.String("rewrite", "", "YAML file with response rewrite rules (set_headers, remove_headers, replace, set_fields) applied before the client and the recording see a response")
+	keepHeaders := flag.String("keep-headers", "", "Comma-separated headers to persist in mock files, e.g. 'accept,x-request-*' (default: all)")
+	dropHeaders := flag.String("drop-headers", "", "Comma-separated headers never persisted in mock files, e.g. 'cookie,set-cookie,cf-*'")
+	redactHeaders := flag.String("redact-headers", "", "Comma-separated headers masked in mock files, e.g. 'authorization,cookie,set-cookie,x-api-*'")
+	redactFields := flag.String("redact-fields", "", "Comma-separated JSON body paths masked in mock files, e.g. '$.user.ssn,$.cards[*].number'")
+	redactPlaceholder := flag.String("redact-placeholder", proxy.DefaultRedactPlaceholder, "Replacement written for redacted values")
+	asyncQueue := flag.Int("async-queue", 0, "Write recordings from a background worker with a queue of this many entries (0 = write in the request path)")
+	maxCorpusSize := flag.String("max-corpus-size", "", "Cap the total size of recordings in -log-dir (e.g. 500MB), deleting the oldest first")
+	maxFilesPerMockID := flag.Int("max-files-per-mock-id", 0, "Keep at most this many recordings per mock ID directory, deleting the oldest first (0 = unlimited)")
+	queuePolicy := flag.String("queue-policy", proxy.QueueBlock, "What to do when -async-queue is full: block (wait), drop (skip the recording) or sync (write inline)")
+	latencyReport := flag.String("latency-report", "", "Write per-endpoint latency histogram summary (JSON) to this file at shutdown")
+	jsonOutput := flag.Bool("json-output", false, "Print startup info as a single JSON line on stdout (banner goes to stderr)")
+	streamUploadsOver := flag.String("stream-uploads-over", "", "Stream request bodies larger than this size (e.g. 10MB) to the upstream instead of buffering them")
+	captureUploads := flag.Bool("capture-uploads", false, "Save streamed request bodies to <log-dir>/<mock_id>/uploads/ (requires -stream-uploads-over)")
+	readTimeout := flag.Duration("read-timeout", 0, "Max time to read a full request, including the body (0 = no limit)")
+	writeTimeout := flag.Duration("write-timeout", 0, "Max time to write a response; also caps SSE streams (0 = no limit)")
+	idleTimeout := flag.Duration("idle-timeout", 0, "Close keep-alive connections idle for this long (0 = use -read-timeout)")
+	concurrency := flag.Int("concurrency", 0, "Max concurrent connections served (0 = fasthttp default 256*1024)")
+	maxConnsPerIP := flag.Int("max-conns-per-ip", 0, "Max concurrent connections per client IP (0 = unlimited)")
+	sseMaxDuration := flag.Duration("sse-max-duration", 0, "Stop capturing an SSE stream after this long and write its recording; the stream keeps flowing (0 = no limit)")
+	sseMaxEvents := flag.Int("sse-max-events", 0, "Stop capturing an SSE stream after this many events and write its recording (0 = no limit)")
+	sseMaxBytes := flag.String("sse-max-bytes", "", "Stop capturing an SSE stream after this much event data (e.g. 1MB) and write its recording")
+	cacheGets := flag.Bool("cache-gets", false, "Answer repeated GETs from the response recorded for the first one instead of hitting the upstream again")
+	cacheTTL := flag.Duration("cache-ttl", 0, "How long -cache-gets reuses a response (0 = whole session)")
+	maxInFlight := flag.Int("max-inflight", 0, "Answer 503 instead of forwarding when this many requests are already in flight (0 = unlimited)")
+	http2 := flag.Bool("http2", false, "Also accept HTTP/2 clients over h2c (prior knowledge); CONNECT tunnels still need HTTP/1.1")
+	portFile := flag.String("port-file", "", "Write pid and bound address (JSON) to this file once listening; removed at shutdown")
 	flag.Parse()
 
-	if *targetURL == "" {
-		log.Fatal("Error: -target flag is required. Specify the target URL to proxy to.")
+	// Keep stdout machine-readable in JSON mode
+	out := os.Stdout
+	if *jsonOutput {
+		out = os.Stderr
+	}
+
+	// Split -target values into the default upstream and prefix routes
+	var targetURL string
+	var routes []proxy.Route
+	for _, value := range targets {
+		if !strings.Contains(value, "->") {
+			if targetURL != "" {
+				log.Fatal("Error: only one -target may be a plain URL; use '/prefix/* -> URL' for the others.")
+			}
+			targetURL = value
+			continue
+		}
+		route, err := proxy.ParseRoute(value)
+		if err != nil {
+			log.Fatalf("Invalid -target value: %v", err)
+		}
+		routes = append(routes, route)
+	}
+	if *routesFile != "" {
+		fileRoutes, err := proxy.LoadRoutes(*routesFile)
+		if err != nil {
+			log.Fatalf("Failed to load routes: %v", err)
+		}
+		routes = append(routes, fileRoutes...)
+	}
+
+	if targetURL == "" && len(routes) == 0 && !*forwardMode {
+		log.Fatal("Error: -target flag is required. Specify the target URL to proxy to, or use -forward.")
+	}
+	if (targetURL != "" || len(routes) > 0) && *forwardMode {
+		log.Fatal("Error: -target/-routes and -forward are mutually exclusive.")
 	}
 
 	// Create recorder
-	fmt.Println("🚀 Starting HTTP recording proxy...")
-	fmt.Printf("📁 Recording to directory: %s\n", *logDir)
+	fmt.Fprintln(out, "🚀 Starting HTTP recording proxy...")
+	fmt.Fprintf(out, "📁 Recording to directory: %s\n", *logDir)
 
 	recorder, err := proxy.NewRecorder(*logDir)
 	if err != nil {
@@ -36,22 +134,247 @@ func main() {
 	}
 	defer recorder.Close()
 
+	if err := recorder.SetFilenameStrategy(*filenameStrategy); err != nil {
+		log.Fatalf("Invalid -filename-strategy: %v", err)
+	}
+	if *filenameStrategy == proxy.FilenameHash {
+		fmt.Fprintln(out, "🔑 Filename strategy: hash (re-recording overwrites matching files)")
+	}
+	if err := recorder.SetFormat(*format); err != nil {
+		log.Fatalf("Invalid -format: %v", err)
+	}
+	if *format == proxy.FormatHAR {
+		fmt.Fprintln(out, "🗂️  Recording format: HAR 1.2 (.har files)")
+	}
+
+	// Register header-based recording conditions
+	for _, rule := range recordIf {
+		cond, err := proxy.ParseRecordCondition(rule, false)
+		if err != nil {
+			log.Fatalf("Invalid -record-if value: %v", err)
+		}
+		recorder.AddCondition(cond)
+		fmt.Fprintf(out, "✅ Record only if: %s\n", rule)
+	}
+	for _, rule := range skipIf {
+		cond, err := proxy.ParseRecordCondition(rule, true)
+		if err != nil {
+			log.Fatalf("Invalid -skip-if value: %v", err)
+		}
+		recorder.AddCondition(cond)
+		fmt.Fprintf(out, "⏭️  Skip recording if: %s\n", rule)
+	}
+
+	// Restrict which exchanges are recorded by path, method and status
+	recordFilter := &proxy.RecordFilter{}
+	if *recordFilterFile != "" {
+		if recordFilter, err = proxy.LoadRecordFilter(*recordFilterFile); err != nil {
+			log.Fatalf("Failed to load record filter: %v", err)
+		}
+	}
+	recordFilter.IncludePaths = append(recordFilter.IncludePaths, recordPaths...)
+	recordFilter.ExcludePaths = append(recordFilter.ExcludePaths, skipPaths...)
+	recordFilter.Methods = append(recordFilter.Methods, splitList(*recordMethods)...)
+	recordFilter.Statuses = append(recordFilter.Statuses, splitList(*recordStatuses)...)
+	recordFilter.ExcludeStatuses = append(recordFilter.ExcludeStatuses, splitList(*skipStatuses)...)
+	if len(recordFilter.IncludePaths)+len(recordFilter.ExcludePaths)+len(recordFilter.Methods)+
+		len(recordFilter.Statuses)+len(recordFilter.ExcludeStatuses) > 0 {
+		if err := recorder.SetRecordFilter(recordFilter); err != nil {
+			log.Fatalf("Invalid record filter: %v", err)
+		}
+		if len(recordFilter.IncludePaths) > 0 {
+			fmt.Fprintf(out, "✅ Record only paths: %s\n", strings.Join(recordFilter.IncludePaths, ", "))
+		}
+		if len(recordFilter.ExcludePaths) > 0 {
+			fmt.Fprintf(out, "⏭️  Skip recording paths: %s\n", strings.Join(recordFilter.ExcludePaths, ", "))
+		}
+		if len(recordFilter.Methods) > 0 {
+			fmt.Fprintf(out, "✅ Record only methods: %s\n", strings.Join(recordFilter.Methods, ", "))
+		}
+		if len(recordFilter.Statuses) > 0 {
+			fmt.Fprintf(out, "✅ Record only statuses: %s\n", strings.Join(recordFilter.Statuses, ", "))
+		}
+		if len(recordFilter.ExcludeStatuses) > 0 {
+			fmt.Fprintf(out, "⏭️  Skip recording statuses: %s\n", strings.Join(recordFilter.ExcludeStatuses, ", "))
+		}
+	}
+
+	// Restrict which headers land in recorded files
+	if *keepHeaders != "" || *dropHeaders != "" {
+		keep := proxy.ParseHeaderPatterns(*keepHeaders)
+		drop := proxy.ParseHeaderPatterns(*dropHeaders)
+		recorder.SetHeaderFilter(proxy.NewHeaderFilter(keep, drop))
+		if len(keep) > 0 {
+			fmt.Fprintf(out, "🧹 Persisting only headers: %s\n", strings.Join(keep, ", "))
+		}
+		if len(drop) > 0 {
+			fmt.Fprintf(out, "🧹 Dropping headers: %s\n", strings.Join(drop, ", "))
+		}
+	}
+
+	// Mask secrets in recorded files
+	if *redactHeaders != "" || *redactFields != "" {
+		headers := proxy.ParseHeaderPatterns(*redactHeaders)
+		fields := splitList(*redactFields)
+		redactor, err := proxy.NewRedactor(headers, fields, *redactPlaceholder)
+		if err != nil {
+			log.Fatalf("Invalid -redact-fields value: %v", err)
+		}
+		recorder.SetRedactor(redactor)
+		if len(headers) > 0 {
+			fmt.Fprintf(out, "🙈 Redacting headers: %s\n", strings.Join(headers, ", "))
+		}
+		if len(fields) > 0 {
+			fmt.Fprintf(out, "🙈 Redacting body fields: %s\n", strings.Join(fields, ", "))
+		}
+	}
+
+	// Move file writes out of the request path; flushed at shutdown
+	if *asyncQueue > 0 {
+		if err := recorder.SetAsync(*asyncQueue, *queuePolicy); err != nil {
+			log.Fatalf("Invalid -async-queue settings: %v", err)
+		}
+		fmt.Fprintf(out, "📥 Async recording: queue of %d, %s when full\n", *asyncQueue, *queuePolicy)
+	}
+
+	// Rotate out the oldest recordings so always-on proxies don't fill the disk
+	if *maxCorpusSize != "" || *maxFilesPerMockID > 0 {
+		var maxBytes int64
+		if *maxCorpusSize != "" {
+			size, err := storage.ParseSize(*maxCorpusSize)
+			if err != nil || size <= 0 {
+				log.Fatalf("Invalid -max-corpus-size %q", *maxCorpusSize)
+			}
+			maxBytes = int64(size)
+		}
+		if err := recorder.SetCorpusLimits(maxBytes, *maxFilesPerMockID); err != nil {
+			log.Fatalf("Invalid corpus limits: %v", err)
+		}
+		if maxBytes > 0 {
+			fmt.Fprintf(out, "🗑️  Corpus capped at %s, oldest recordings rotated out\n", *maxCorpusSize)
+		}
+		if *maxFilesPerMockID > 0 {
+			fmt.Fprintf(out, "🗑️  Keeping at most %d recordings per mock ID\n", *maxFilesPerMockID)
+		}
+		if evicted := recorder.Evicted(); evicted > 0 {
+			fmt.Fprintf(out, "🗑️  Rotated out %d existing recording(s) over the limits\n", evicted)
+		}
+	}
+
 	// Create proxy handler
-	proxyHandler := proxy.NewProxyHandler(recorder, *targetURL)
+	proxyHandler := proxy.NewProxyHandler(recorder, targetURL)
+	proxyHandler.SetForwardMode(*forwardMode)
+	for _, route := range routes {
+		proxyHandler.AddRoute(route)
+	}
+
+	// Normalize environment-specific values at capture time
+	if *rewriteFile != "" {
+		rewriter, err := proxy.LoadRewriter(*rewriteFile)
+		if err != nil {
+			log.Fatalf("Failed to load rewrite rules: %v", err)
+		}
+		proxyHandler.SetRewriter(rewriter)
+		fmt.Fprintf(out, "✏️  Rewriting responses: %d rule(s) from %s\n", len(rewriter.Rules), *rewriteFile)
+	}
+
+	// Shed load beyond the in-flight limit instead of queueing it
+	if *maxInFlight < 0 {
+		log.Fatal("Error: -max-inflight must not be negative")
+	}
+	if *maxInFlight > 0 {
+		proxyHandler.SetMaxInFlight(*maxInFlight)
+		fmt.Fprintf(out, "🚦 Max in-flight requests: %d (503 beyond)\n", *maxInFlight)
+	}
 
 	// Load client certificate if provided
 	if *clientCert != "" && *clientKey != "" {
 		if err := proxyHandler.LoadClientCertificate(*clientCert, *clientKey); err != nil {
 			log.Fatalf("Failed to load client certificate: %v", err)
 		}
-		fmt.Printf("🔐 Client certificate loaded: %s\n", *clientCert)
+		fmt.Fprintf(out, "🔐 Client certificate loaded: %s\n", *clientCert)
+	}
+
+	// Intercept HTTPS tunnels with per-host certificates signed by the given CA
+	if *mitmCACert != "" || *mitmCAKey != "" {
+		if *mitmCACert == "" || *mitmCAKey == "" {
+			log.Fatal("Both -mitm-ca-cert and -mitm-ca-key are required for MITM mode")
+		}
+		ca, err := proxy.LoadCertAuthority(*mitmCACert, *mitmCAKey)
+		if err != nil {
+			log.Fatalf("Failed to load MITM CA: %v", err)
+		}
+		proxyHandler.EnableMITM(ca)
+		fmt.Fprintf(out, "🔓 HTTPS interception enabled (CA: %s)\n", *mitmCACert)
+	}
+
+	// Apply DNS overrides
+	for _, rule := range resolveRules {
+		hostPort, addr, err := proxy.ParseResolveRule(rule)
+		if err != nil {
+			log.Fatalf("Invalid -resolve value: %v", err)
+		}
+		proxyHandler.AddResolveOverride(hostPort, addr)
+		fmt.Fprintf(out, "🧭 Resolving %s → %s\n", hostPort, addr)
+	}
+
+	// Enable latency histograms if a report was requested
+	var latencyTracker *proxy.LatencyTracker
+	if *latencyReport != "" {
+		latencyTracker = proxy.NewLatencyTracker()
+		proxyHandler.SetLatencyTracker(latencyTracker)
+		fmt.Fprintf(out, "📊 Latency report: %s\n", *latencyReport)
+	}
+
+	// Stream large uploads instead of buffering them in memory
+	if *streamUploadsOver != "" {
+		if err := proxyHandler.SetUploadStreaming(*streamUploadsOver, *captureUploads); err != nil {
+			log.Fatalf("Invalid -stream-uploads-over value: %v", err)
+		}
+		fmt.Fprintf(out, "📤 Streaming request bodies over %s", *streamUploadsOver)
+		if *captureUploads {
+			fmt.Fprint(out, " (captured to disk)")
+		}
+		fmt.Fprintln(out)
+	} else if *captureUploads {
+		log.Fatalf("-capture-uploads requires -stream-uploads-over")
+	}
+
+	// Endless event streams are recorded up to a limit instead of buffered forever
+	if *sseMaxDuration != 0 || *sseMaxEvents != 0 || *sseMaxBytes != "" {
+		if err := proxyHandler.SetSSELimits(*sseMaxDuration, *sseMaxEvents, *sseMaxBytes); err != nil {
+			log.Fatalf("Invalid SSE limits: %v", err)
+		}
+		var limits []string
+		if *sseMaxDuration > 0 {
+			limits = append(limits, sseMaxDuration.String())
+		}
+		if *sseMaxEvents > 0 {
+			limits = append(limits, fmt.Sprintf("%d events", *sseMaxEvents))
+		}
+		if *sseMaxBytes != "" {
+			limits = append(limits, *sseMaxBytes)
+		}
+		fmt.Fprintf(out, "✂️  SSE capture limits: %s\n", strings.Join(limits, ", "))
+	}
+
+	// Repeated GETs are answered locally to spare fragile upstreams
+	if *cacheGets {
+		if err := proxyHandler.SetResponseCache(true, *cacheTTL); err != nil {
+			log.Fatalf("Invalid -cache-ttl: %v", err)
+		}
+		if *cacheTTL > 0 {
+			fmt.Fprintf(out, "♻️  Repeated GETs answered from recorded responses for %s\n", *cacheTTL)
+		} else {
+			fmt.Fprintln(out, "♻️  Repeated GETs answered from recorded responses")
+		}
 	}
 
 	// Create request handler
 	handler := func(ctx *fasthttp.RequestCtx) {
 		method := string(ctx.Method())
 
-		// Handle CONNECT for HTTPS (currently not supported)
+		// Handle CONNECT for HTTPS (intercepted in MITM mode, rejected otherwise)
 		if method == "CONNECT" {
 			proxyHandler.HandleConnect(ctx)
 			return
@@ -61,19 +384,76 @@ func main() {
 		proxyHandler.Handle(ctx)
 	}
 
-	addr := fmt.Sprintf("%s:%d", *host, *port)
-	fmt.Printf("\n🌐 Reverse proxy running at http://%s\n", addr)
-	fmt.Printf("🎯 Proxying to: %s\n", *targetURL)
-	fmt.Println("📝 All requests will be recorded with x-mock-id header support")
-	fmt.Println("\nUsage examples:")
-	fmt.Printf("  curl http://%s/get\n", addr)
-	fmt.Printf("  curl -H \"x-mock-id: test-1\" http://%s/get\n", addr)
-	fmt.Println("\nPress Ctrl+C to stop")
+	// Listen before announcing so -port 0 reports the port actually bound
+	ln, err := net.Listen("tcp4", net.JoinHostPort(*host, strconv.Itoa(*port)))
+	if err != nil {
+		log.Fatalf("Error in Listen: %v", err)
+	}
+	addr := ln.Addr().String()
+
+	if *forwardMode {
+		fmt.Fprintf(out, "\n🌐 Forward proxy running at http://%s\n", addr)
+		fmt.Fprintln(out, "🎯 Proxying to: each request's own host (recorded into per-host subdirectories)")
+		fmt.Fprintln(out, "📝 All requests will be recorded with x-mock-id header support")
+		fmt.Fprintln(out, "\nUsage examples:")
+		fmt.Fprintf(out, "  HTTP_PROXY=http://%s curl http://api.example.com/get\n", addr)
+		fmt.Fprintf(out, "  curl -x http://%s -H \"x-mock-id: test-1\" http://api.example.com/get\n", addr)
+	} else {
+		fmt.Fprintf(out, "\n🌐 Reverse proxy running at http://%s\n", addr)
+		if targetURL != "" {
+			fmt.Fprintf(out, "🎯 Proxying to: %s\n", targetURL)
+		}
+		for _, route := range routes {
+			fmt.Fprintf(out, "🔀 Route %s/* → %s", route.Prefix, route.Target)
+			if route.StripPrefix {
+				fmt.Fprint(out, " (prefix stripped)")
+			}
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintln(out, "📝 All requests will be recorded with x-mock-id header support")
+		fmt.Fprintln(out, "\nUsage examples:")
+		fmt.Fprintf(out, "  curl http://%s/get\n", addr)
+		fmt.Fprintf(out, "  curl -H \"x-mock-id: test-1\" http://%s/get\n", addr)
+	}
+	fmt.Fprintf(out, "📈 Metrics: http://%s%s\n", addr, proxy.MetricsPath)
+	if *http2 {
+		fmt.Fprintln(out, "🚄 HTTP/2: h2c clients accepted (prior knowledge)")
+	}
+	fmt.Fprintln(out, "\nPress Ctrl+C to stop")
+
+	if *jsonOutput {
+		printReadiness(map[string]interface{}{
+			"event":   "ready",
+			"address": addr,
+			"url":     "http://" + addr,
+			"target":  targetURL,
+			"routes":  len(routes),
+			"forward": *forwardMode,
+			"log_dir": *logDir,
+			"metrics": "http://" + addr + proxy.MetricsPath,
+		})
+	}
+	if *portFile != "" {
+		if err := writePortFile(*portFile, ln.Addr()); err != nil {
+			log.Fatalf("Failed to write port file: %v", err)
+		}
+	}
 
 	// Create server
 	server := &fasthttp.Server{
-		Handler: handler,
-		Name:    "AutoRecordingProxy",
+		Handler:           handler,
+		Name:              "AutoRecordingProxy",
+		StreamRequestBody: *streamUploadsOver != "",
+		ReadTimeout:       *readTimeout,
+		WriteTimeout:      *writeTimeout,
+		IdleTimeout:       *idleTimeout,
+		Concurrency:       *concurrency,
+		MaxConnsPerIP:     *maxConnsPerIP,
+	}
+
+	// fasthttp only speaks HTTP/1.1; HTTP/2 connections are bridged to the same server
+	if *http2 {
+		ln = h2.NewServer(server, nil).Listener(ln)
 	}
 
 	// Handle graceful shutdown
@@ -82,15 +462,72 @@ func main() {
 		signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
 		<-sigint
 
-		fmt.Println("\n👋 Shutting down proxy...")
+		fmt.Fprintln(out, "\n👋 Shutting down proxy...")
 		if err := server.Shutdown(); err != nil {
 			log.Printf("Server shutdown error: %v", err)
 		}
+		recorder.Close()
+		if dropped := recorder.Dropped(); dropped > 0 {
+			fmt.Fprintf(out, "⚠️  %d recording(s) dropped because the write queue was full\n", dropped)
+		}
+		if evicted := recorder.Evicted(); evicted > 0 {
+			fmt.Fprintf(out, "🗑️  %d old recording(s) rotated out by the corpus limits\n", evicted)
+		}
+		if hits := proxyHandler.CacheHits(); hits > 0 {
+			fmt.Fprintf(out, "♻️  %d GET(s) answered from the response cache\n", hits)
+		}
+		if latencyTracker != nil {
+			if err := latencyTracker.WriteReport(*latencyReport); err != nil {
+				log.Printf("Failed to write latency report: %v", err)
+			} else {
+				fmt.Fprintf(out, "📊 Latency report written to %s\n", *latencyReport)
+			}
+		}
+		if *portFile != "" {
+			os.Remove(*portFile)
+		}
 		os.Exit(0)
 	}()
 
 	// Start server
-	if err := server.ListenAndServe(addr); err != nil {
-		log.Fatalf("Error in ListenAndServe: %v", err)
+	if err := server.Serve(ln); err != nil {
+		log.Fatalf("Error in Serve: %v", err)
+	}
+
+	// Serve returns as soon as Shutdown closes the listener; let the shutdown
+	// goroutine finish its cleanup and exit the process
+	select {}
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// printReadiness writes the startup summary as one JSON line for test harnesses.
+func printReadiness(info map[string]interface{}) {
+	line, err := json.Marshal(info)
+	if err != nil {
+		log.Fatalf("Failed to encode startup info: %v", err)
+	}
+	fmt.Println(string(line))
+}
+
+// writePortFile records the process id and bound address so parallel jobs can find the proxy.
+func writePortFile(path string, addr net.Addr) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"pid":     os.Getpid(),
+		"address": addr.String(),
+		"port":    addr.(*net.TCPAddr).Port,
+	})
+	if err != nil {
+		return err
 	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
 }
@@ -4,6 +4,8 @@ go 1.25.4
 
 require (
 	github.com/andrey-viktorov/jsonfilter-go v1.0.2
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/tidwall/gjson v1.18.0
 	github.com/valyala/fasthttp v1.51.0
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -11,8 +13,8 @@ require (
 require (
 	github.com/andybalholm/brotli v1.0.5 // indirect
 	github.com/klauspost/compress v1.17.0 // indirect
-	github.com/tidwall/gjson v1.18.0 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )
@@ -0,0 +1,318 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// VCRCassette is a go-vcr cassette (format versions 1 and 2, as written by
+// go-vcr v1 to v3).
+type VCRCassette struct {
+	Version      int              `yaml:"version"`
+	Interactions []VCRInteraction `yaml:"interactions"`
+}
+
+// VCRInteraction is one recorded request and its response.
+type VCRInteraction struct {
+	Request  VCRRequest  `yaml:"request"`
+	Response VCRResponse `yaml:"response"`
+}
+
+// VCRRequest is the request half of an interaction.
+type VCRRequest struct {
+	Method  string              `yaml:"method"`
+	URL     string              `yaml:"url"`
+	Headers map[string][]string `yaml:"headers"`
+	Body    string              `yaml:"body"`
+}
+
+// VCRResponse is the response half of an interaction. Status is the status
+// line ("200 OK"); Code is only written by format version 2.
+type VCRResponse struct {
+	Status   string              `yaml:"status"`
+	Code     int                 `yaml:"code"`
+	Headers  map[string][]string `yaml:"headers"`
+	Body     string              `yaml:"body"`
+	Duration string              `yaml:"duration"`
+}
+
+// ReplayLog is an httpreplay log (cloud.google.com/go/httpreplay). Bodies are
+// base64 in the file and decoded by encoding/json.
+type ReplayLog struct {
+	Entries []ReplayEntry `json:"Entries"`
+}
+
+// ReplayEntry is one recorded request and its response.
+type ReplayEntry struct {
+	ID      string `json:"ID"`
+	Request struct {
+		Method    string              `json:"Method"`
+		URL       string              `json:"URL"`
+		Header    map[string][]string `json:"Header"`
+		BodyParts [][]byte            `json:"BodyParts"`
+	} `json:"Request"`
+	Response *struct {
+		StatusCode int                 `json:"StatusCode"`
+		Header     map[string][]string `json:"Header"`
+		Body       []byte              `json:"Body"`
+	} `json:"Response"`
+	Err string `json:"Err"`
+}
+
+// cassetteInteraction is a recorded exchange in either format.
+type cassetteInteraction struct {
+	method          string
+	url             string
+	requestHeaders  map[string][]string
+	requestBody     []byte
+	status          int
+	responseHeaders map[string][]string
+	responseBody    []byte
+	delay           time.Duration
+}
+
+// ImportCassettes converts go-vcr cassettes (*.yaml, *.yml) and httpreplay
+// logs (*.replay, *.json) at path, a cassette file or a directory of them, to
+// native recordings under mockDir/<mock-id>/. mockID selects the mock ID of
+// every recording; an empty mockID uses the cassette name instead, so each
+// test's fixtures are selected with its own x-mock-id.
+//
+// Native mocks are matched by path and method, so queries and request headers
+// are kept in the recordings but not matched on replay.
+func ImportCassettes(path, mockDir, mockID string) (*Report, error) {
+	files, err := cassetteFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	for _, file := range files {
+		interactions, err := readCassette(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		recordMockID := mockID
+		if recordMockID == "" {
+			recordMockID = fileComponent(name)
+		}
+		for i, interaction := range interactions {
+			label := fmt.Sprintf("%s#%d", name, i+1)
+			record, err := cassetteRecord(interaction, recordMockID, fmt.Sprintf("vcr-%s-%d", name, i+1))
+			if err != nil {
+				report.Skipped++
+				report.warnf("%s: skipped: %v", label, err)
+				continue
+			}
+			if err := writeRecord(mockDir, record); err != nil {
+				return nil, err
+			}
+			report.Converted++
+		}
+	}
+	return report, nil
+}
+
+// cassetteFiles lists the cassettes at path, in name order.
+func cassetteFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml", "*.replay", "*.json"} {
+		matches, err := filepath.Glob(filepath.Join(path, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// readCassette reads a cassette in either format; httpreplay logs are JSON
+// objects with "Entries", anything else is parsed as a go-vcr cassette.
+func readCassette(path string) ([]cassetteInteraction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var replay ReplayLog
+	if err := json.Unmarshal(data, &replay); err == nil && replay.Entries != nil {
+		return replayInteractions(replay), nil
+	}
+
+	var cassette VCRCassette
+	if err := yaml.Unmarshal(data, &cassette); err != nil {
+		return nil, err
+	}
+	if cassette.Interactions == nil {
+		return nil, fmt.Errorf("not a go-vcr cassette or httpreplay log")
+	}
+	interactions := make([]cassetteInteraction, 0, len(cassette.Interactions))
+	for _, recorded := range cassette.Interactions {
+		status := recorded.Response.Code
+		if status == 0 {
+			// Format version 1 only has the status line
+			fields := strings.Fields(recorded.Response.Status)
+			if len(fields) > 0 {
+				status, _ = strconv.Atoi(fields[0])
+			}
+		}
+		interactions = append(interactions, cassetteInteraction{
+			method:          recorded.Request.Method,
+			url:             recorded.Request.URL,
+			requestHeaders:  recorded.Request.Headers,
+			requestBody:     []byte(recorded.Request.Body),
+			status:          status,
+			responseHeaders: recorded.Response.Headers,
+			responseBody:    []byte(recorded.Response.Body),
+			delay:           parseVCRDuration(recorded.Response.Duration),
+		})
+	}
+	return interactions, nil
+}
+
+// parseVCRDuration parses a go-vcr duration: a Go duration string, or
+// nanoseconds. Anything else counts as no delay.
+func parseVCRDuration(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return duration
+	}
+	if nanos, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(nanos)
+	}
+	return 0
+}
+
+func replayInteractions(replay ReplayLog) []cassetteInteraction {
+	interactions := make([]cassetteInteraction, 0, len(replay.Entries))
+	for _, entry := range replay.Entries {
+		interaction := cassetteInteraction{
+			method:         entry.Request.Method,
+			url:            entry.Request.URL,
+			requestHeaders: entry.Request.Header,
+		}
+		for _, part := range entry.Request.BodyParts {
+			interaction.requestBody = append(interaction.requestBody, part...)
+		}
+		// Entries for failed requests have no response; status 0 skips them
+		if entry.Response != nil {
+			interaction.status = entry.Response.StatusCode
+			interaction.responseHeaders = entry.Response.Header
+			interaction.responseBody = entry.Response.Body
+		}
+		interactions = append(interactions, interaction)
+	}
+	return interactions
+}
+
+// cassetteRecord converts one interaction to a native record.
+func cassetteRecord(interaction cassetteInteraction, mockID, requestID string) (nativeRecord, error) {
+	if interaction.url == "" {
+		return nativeRecord{}, fmt.Errorf("no request URL")
+	}
+	if interaction.status == 0 {
+		return nativeRecord{}, fmt.Errorf("no recorded response")
+	}
+	method := strings.ToUpper(interaction.method)
+	if method == "" {
+		method = "GET"
+	}
+
+	requestHeaders := joinHeaders(interaction.requestHeaders)
+	if mockID != "default" {
+		requestHeaders["x-mock-id"] = mockID
+	}
+	responseHeaders := joinHeaders(interaction.responseHeaders)
+	// Bodies are stored decoded, so the encoding headers no longer apply
+	contentType := ""
+	for header, value := range responseHeaders {
+		switch strings.ToLower(header) {
+		case "content-type":
+			contentType = value
+		case "content-encoding", "content-length", "transfer-encoding":
+			delete(responseHeaders, header)
+		}
+	}
+
+	responseBody, err := cassetteBody(interaction.responseBody)
+	if err != nil {
+		return nativeRecord{}, fmt.Errorf("response body: %w", err)
+	}
+	requestBody, err := cassetteBody(interaction.requestBody)
+	if err != nil {
+		return nativeRecord{}, fmt.Errorf("request body: %w", err)
+	}
+	if contentType == "" {
+		contentType = "text/plain"
+		if _, isText := responseBody.(string); !isText {
+			contentType = "application/json"
+		}
+		responseHeaders["Content-Type"] = contentType
+	}
+
+	return nativeRecord{
+		mockID:      mockID,
+		contentType: contentType,
+		requestID:   requestID,
+		record: map[string]interface{}{
+			"request": map[string]interface{}{
+				"request_id": requestID,
+				"method":     method,
+				"url":        interaction.url,
+				"headers":    requestHeaders,
+				"body":       requestBody,
+			},
+			"response": map[string]interface{}{
+				"request_id":  requestID,
+				"status_code": interaction.status,
+				"headers":     responseHeaders,
+				"body":        responseBody,
+				"delay":       interaction.delay.Seconds(),
+			},
+		},
+	}, nil
+}
+
+// joinHeaders flattens multi-valued headers the way native records store them.
+func joinHeaders(headers map[string][]string) map[string]string {
+	joined := make(map[string]string, len(headers))
+	for key, values := range headers {
+		joined[key] = strings.Join(values, ", ")
+	}
+	return joined
+}
+
+// cassetteBody returns a recorded body as stored in a native record:
+// decoded JSON, or text.
+func cassetteBody(data []byte) (interface{}, error) {
+	if len(data) == 0 {
+		return "", nil
+	}
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("binary bodies have no native equivalent")
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err == nil {
+		return decoded, nil
+	}
+	return string(data), nil
+}
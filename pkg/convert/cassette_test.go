@@ -0,0 +1,98 @@
+package convert
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+)
+
+func TestImportCassettes(t *testing.T) {
+	cassetteDir := t.TempDir()
+	vcr := `---
+version: 2
+interactions:
+- request:
+    body: '{"name":"Ann"}'
+    headers:
+      Content-Type:
+      - application/json
+    url: https://api.example.com/users?notify=true
+    method: POST
+  response:
+    body: '{"id":7}'
+    headers:
+      Content-Type:
+      - application/json
+      Content-Length:
+      - "8"
+    status: 201 Created
+    code: 201
+    duration: 150ms
+- request:
+    url: https://api.example.com/health
+    method: GET
+  response:
+    body: ok
+    headers: {}
+    status: 200 OK
+    duration: ""
+`
+	if err := os.WriteFile(filepath.Join(cassetteDir, "users.yaml"), []byte(vcr), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	body := base64.StdEncoding.EncodeToString([]byte(`{"items":[]}`))
+	replay := `{"Initial": "", "Version": "0.2", "Entries": [
+		{"ID": "a1", "Request": {"Method": "GET", "URL": "https://storage.example.com/b/items", "Header": {}},
+		 "Response": {"StatusCode": 200, "Header": {"Content-Type": ["application/json; charset=UTF-8"]}, "Body": "` + body + `"}},
+		{"ID": "a2", "Request": {"Method": "GET", "URL": "https://storage.example.com/b/broken"}, "Err": "connection reset"}
+	]}`
+	if err := os.WriteFile(filepath.Join(cassetteDir, "storage.replay"), []byte(replay), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mockDir := t.TempDir()
+	report, err := ImportCassettes(cassetteDir, mockDir, "")
+	if err != nil {
+		t.Fatalf("ImportCassettes failed: %v", err)
+	}
+	if report.Converted != 3 || report.Skipped != 1 {
+		t.Fatalf("expected 3 converted and 1 skipped, got %+v", report)
+	}
+
+	store, err := storage.NewMockStorage(mockDir)
+	if err != nil {
+		t.Fatalf("failed to load imported mocks: %v", err)
+	}
+	created := store.FindResponse("/users", "users", "application/json", "POST")
+	if created == nil || created.StatusCode != 201 || created.Delay != 0.15 || string(created.Body) != `{"id":7}` {
+		t.Fatalf("unexpected imported go-vcr mock: %+v", created)
+	}
+	if _, ok := created.Headers["Content-Length"]; ok {
+		t.Errorf("expected the recorded Content-Length to be dropped, got %v", created.Headers)
+	}
+	// Format version 1 style: status line only, no content type
+	health := store.FindResponse("/health", "users", "text/plain", "GET")
+	if health == nil || health.StatusCode != 200 || string(health.Body) != "ok" {
+		t.Fatalf("unexpected imported status-line mock: %+v", health)
+	}
+	items := store.FindResponse("/b/items", "storage", "application/json", "GET")
+	if items == nil || string(items.Body) != `{"items":[]}` {
+		t.Fatalf("unexpected imported httpreplay mock: %+v", items)
+	}
+
+	// A fixed mock ID puts every cassette under it
+	mockDir = t.TempDir()
+	if _, err := ImportCassettes(filepath.Join(cassetteDir, "users.yaml"), mockDir, "default"); err != nil {
+		t.Fatalf("ImportCassettes failed: %v", err)
+	}
+	if store, err = storage.NewMockStorage(mockDir); err != nil {
+		t.Fatalf("failed to load imported mocks: %v", err)
+	}
+	if store.FindResponse("/health", "default", "text/plain", "GET") == nil {
+		t.Fatal("expected the cassette under the default mock ID")
+	}
+}
@@ -0,0 +1,449 @@
+// Package convert translates mocks between the native recording format and
+// other mocking tools.
+package convert
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+)
+
+// WireMockMappings is the {"mappings": [...]} document WireMock loads from
+// its mappings directory and returns from /__admin/mappings.
+type WireMockMappings struct {
+	Mappings []WireMockMapping `json:"mappings"`
+}
+
+// WireMockMapping is a single WireMock stub.
+type WireMockMapping struct {
+	ID       string           `json:"id,omitempty"`
+	Name     string           `json:"name,omitempty"`
+	Request  WireMockRequest  `json:"request"`
+	Response WireMockResponse `json:"response"`
+}
+
+// WireMockRequest is the request pattern of a stub. Only one of the URL
+// fields is set.
+type WireMockRequest struct {
+	Method          string                   `json:"method,omitempty"`
+	URL             string                   `json:"url,omitempty"`
+	URLPath         string                   `json:"urlPath,omitempty"`
+	URLPattern      string                   `json:"urlPattern,omitempty"`
+	URLPathPattern  string                   `json:"urlPathPattern,omitempty"`
+	QueryParameters map[string]WireMockMatch `json:"queryParameters,omitempty"`
+	Headers         map[string]WireMockMatch `json:"headers,omitempty"`
+	BodyPatterns    []WireMockMatch          `json:"bodyPatterns,omitempty"`
+}
+
+// WireMockMatch is a matcher object such as {"equalTo": "x"} or
+// {"equalToJson": {...}}, keyed by operator.
+type WireMockMatch map[string]interface{}
+
+// WireMockResponse is the response definition of a stub. Headers values are
+// strings or lists of strings.
+type WireMockResponse struct {
+	Status                 int                    `json:"status,omitempty"`
+	Headers                map[string]interface{} `json:"headers,omitempty"`
+	Body                   string                 `json:"body,omitempty"`
+	JSONBody               json.RawMessage        `json:"jsonBody,omitempty"`
+	Base64Body             string                 `json:"base64Body,omitempty"`
+	BodyFileName           string                 `json:"bodyFileName,omitempty"`
+	FixedDelayMilliseconds int                    `json:"fixedDelayMilliseconds,omitempty"`
+}
+
+// Report summarizes a conversion. Warnings name what could not be carried
+// over, such as matchers the target format has no equivalent for.
+type Report struct {
+	Converted int
+	Skipped   int
+	Warnings  []string
+}
+
+func (r *Report) warnf(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// anyMethods are the methods a WireMock "ANY" stub is expanded to.
+var anyMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// ImportWireMock converts the WireMock stubs in mappingsPath (a mapping file
+// or a directory of them) to native recordings under mockDir/<mock-id>/.
+// filesDir holds the files referenced by bodyFileName; when empty, the
+// __files directory next to a mappings directory is used.
+//
+// Native mocks are matched by path, method and x-mock-id, so an equalTo
+// x-mock-id header selects the mock ID, queries are ignored and other
+// matchers (patterns, body patterns, other headers) only produce warnings.
+func ImportWireMock(mappingsPath, filesDir, mockDir string) (*Report, error) {
+	files, err := mappingFiles(mappingsPath)
+	if err != nil {
+		return nil, err
+	}
+	if filesDir == "" {
+		filesDir = filepath.Join(filepath.Dir(filepath.Clean(mappingsPath)), "__files")
+	}
+
+	report := &Report{}
+	for _, file := range files {
+		mappings, err := readMappings(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for i, mapping := range mappings {
+			name := mapping.ID
+			if name == "" {
+				name = fmt.Sprintf("%s#%d", strings.TrimSuffix(filepath.Base(file), ".json"), i+1)
+			}
+			records, err := nativeRecords(mapping, name, filesDir, report)
+			if err != nil {
+				report.Skipped++
+				report.warnf("%s: skipped: %v", name, err)
+				continue
+			}
+			for _, record := range records {
+				if err := writeRecord(mockDir, record); err != nil {
+					return nil, err
+				}
+			}
+			report.Converted++
+		}
+	}
+	return report, nil
+}
+
+// mappingFiles lists the mapping files at path, in name order.
+func mappingFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	files, err := filepath.Glob(filepath.Join(path, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// readMappings reads a file holding either one stub or {"mappings": [...]}.
+func readMappings(path string) ([]WireMockMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var document struct {
+		WireMockMappings
+		WireMockMapping
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if document.Mappings != nil {
+		return document.Mappings, nil
+	}
+	return []WireMockMapping{document.WireMockMapping}, nil
+}
+
+// nativeRecord is a recording in the format auto-proxy writes.
+type nativeRecord struct {
+	mockID      string
+	contentType string
+	requestID   string
+	record      map[string]interface{}
+}
+
+// nativeRecords converts one stub, one record per method.
+func nativeRecords(mapping WireMockMapping, name, filesDir string, report *Report) ([]nativeRecord, error) {
+	request := mapping.Request
+	target := request.URL
+	if target == "" {
+		target = request.URLPath
+	}
+	if target == "" {
+		if request.URLPattern != "" || request.URLPathPattern != "" {
+			return nil, fmt.Errorf("URL patterns have no native equivalent")
+		}
+		return nil, fmt.Errorf("no url or urlPath")
+	}
+	if len(request.QueryParameters) > 0 {
+		report.warnf("%s: queryParameters are not matched on replay", name)
+	}
+
+	mockID := "default"
+	requestHeaders := map[string]interface{}{}
+	for header, match := range request.Headers {
+		value, ok := match["equalTo"].(string)
+		switch {
+		case ok && strings.EqualFold(header, "x-mock-id"):
+			mockID = value
+			requestHeaders["x-mock-id"] = value
+		case ok:
+			requestHeaders[header] = value
+			report.warnf("%s: header %s is not matched on replay", name, header)
+		default:
+			report.warnf("%s: header matcher for %s has no native equivalent", name, header)
+		}
+	}
+
+	var requestBody interface{} = ""
+	if len(request.BodyPatterns) > 0 {
+		report.warnf("%s: bodyPatterns are not matched on replay; use a scenario filter", name)
+		if pattern := request.BodyPatterns[0]; len(request.BodyPatterns) == 1 {
+			if body, ok := pattern["equalToJson"]; ok {
+				requestBody = jsonValue(body)
+			} else if body, ok := pattern["equalTo"].(string); ok {
+				requestBody = body
+			}
+		}
+	}
+
+	response := mapping.Response
+	status := response.Status
+	if status == 0 {
+		status = 200
+	}
+	responseHeaders := map[string]interface{}{}
+	contentType := ""
+	for header, value := range response.Headers {
+		switch typed := value.(type) {
+		case string:
+			responseHeaders[header] = typed
+		case []interface{}:
+			values := make([]string, 0, len(typed))
+			for _, item := range typed {
+				values = append(values, fmt.Sprint(item))
+			}
+			responseHeaders[header] = strings.Join(values, ", ")
+		}
+		if strings.EqualFold(header, "content-type") {
+			contentType, _ = responseHeaders[header].(string)
+		}
+	}
+
+	body, err := responseBody(response, filesDir)
+	if err != nil {
+		return nil, err
+	}
+	if contentType == "" {
+		contentType = "text/plain"
+		if _, isText := body.(string); !isText {
+			contentType = "application/json"
+		}
+		responseHeaders["Content-Type"] = contentType
+	}
+
+	methods := []string{strings.ToUpper(request.Method)}
+	switch methods[0] {
+	case "", "ANY":
+		methods = anyMethods
+	}
+
+	records := make([]nativeRecord, 0, len(methods))
+	for _, method := range methods {
+		requestID := "wiremock-" + name
+		if len(methods) > 1 {
+			requestID += "-" + strings.ToLower(method)
+		}
+		records = append(records, nativeRecord{
+			mockID:      mockID,
+			contentType: contentType,
+			requestID:   requestID,
+			record: map[string]interface{}{
+				"request": map[string]interface{}{
+					"request_id": requestID,
+					"method":     method,
+					"url":        "http://localhost" + target,
+					"headers":    requestHeaders,
+					"body":       requestBody,
+				},
+				"response": map[string]interface{}{
+					"request_id":  requestID,
+					"status_code": status,
+					"headers":     responseHeaders,
+					"body":        body,
+					"delay":       float64(response.FixedDelayMilliseconds) / 1000,
+				},
+			},
+		})
+	}
+	return records, nil
+}
+
+// jsonValue decodes equalToJson values, which may be given as a JSON string.
+func jsonValue(value interface{}) interface{} {
+	if text, ok := value.(string); ok {
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(text), &decoded); err == nil {
+			return decoded
+		}
+	}
+	return value
+}
+
+// responseBody returns the body of a stub as stored in a native record:
+// decoded JSON, or text.
+func responseBody(response WireMockResponse, filesDir string) (interface{}, error) {
+	var data []byte
+	switch {
+	case len(response.JSONBody) > 0:
+		var decoded interface{}
+		if err := json.Unmarshal(response.JSONBody, &decoded); err != nil {
+			return nil, fmt.Errorf("jsonBody: %w", err)
+		}
+		return decoded, nil
+	case response.Base64Body != "":
+		decoded, err := base64.StdEncoding.DecodeString(response.Base64Body)
+		if err != nil {
+			return nil, fmt.Errorf("base64Body: %w", err)
+		}
+		data = decoded
+	case response.BodyFileName != "":
+		content, err := os.ReadFile(filepath.Join(filesDir, filepath.FromSlash(response.BodyFileName)))
+		if err != nil {
+			return nil, fmt.Errorf("bodyFileName: %w", err)
+		}
+		data = content
+	default:
+		return response.Body, nil
+	}
+
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("binary bodies have no native equivalent")
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err == nil {
+		return decoded, nil
+	}
+	return string(data), nil
+}
+
+// writeRecord writes a native record as <mock-dir>/<mock-id>/<content-type>_<request-id>.json.
+func writeRecord(mockDir string, record nativeRecord) error {
+	dir := filepath.Join(mockDir, record.mockID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(record.record, "", "  ")
+	if err != nil {
+		return err
+	}
+	filename := fileComponent(record.contentType) + "_" + fileComponent(record.requestID) + ".json"
+	return os.WriteFile(filepath.Join(dir, filename), data, 0644)
+}
+
+// fileComponent makes text safe to use in a file name.
+func fileComponent(text string) string {
+	if idx := strings.IndexByte(text, ';'); idx >= 0 {
+		text = text[:idx]
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(text))
+}
+
+// ExportWireMock converts the mocks loaded from mockDir to WireMock stubs.
+// Stubs match on method and urlPath like the mock server, plus an equalTo
+// x-mock-id header for mocks outside "default" and the recorded request body
+// as a body pattern. WebSocket and gRPC mocks have no WireMock equivalent and
+// are skipped; SSE streams are served as one body.
+func ExportWireMock(mockDir string) (*WireMockMappings, *Report, error) {
+	store, err := storage.NewMockStorage(mockDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	report := &Report{}
+	for _, failure := range store.FailedFiles() {
+		report.warnf("%s: not loaded: %s", failure.File, failure.Error)
+	}
+
+	mocks := store.ListAllMocks()
+	sort.Slice(mocks, func(i, j int) bool {
+		a, b := mocks[i], mocks[j]
+		if a.MockID != b.MockID {
+			return a.MockID < b.MockID
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.RequestID < b.RequestID
+	})
+
+	document := &WireMockMappings{Mappings: []WireMockMapping{}}
+	for _, mock := range mocks {
+		if mock.IsWebSocket || mock.IsGRPC {
+			report.Skipped++
+			report.warnf("%s %s [%s]: WebSocket and gRPC mocks have no WireMock equivalent", mock.Method, mock.Path, mock.MockID)
+			continue
+		}
+		if mock.BodyTemplate != nil {
+			report.warnf("%s %s [%s]: template placeholders are exported as literal text", mock.Method, mock.Path, mock.MockID)
+		}
+		document.Mappings = append(document.Mappings, wireMockMapping(mock))
+		report.Converted++
+	}
+	return document, report, nil
+}
+
+// wireMockMapping converts one loaded mock.
+func wireMockMapping(mock *storage.MockResponse) WireMockMapping {
+	mapping := WireMockMapping{
+		Name: fmt.Sprintf("%s %s [%s] %s", mock.Method, mock.Path, mock.MockID, mock.RequestID),
+		Request: WireMockRequest{
+			Method:  mock.Method,
+			URLPath: mock.Path,
+		},
+		Response: WireMockResponse{
+			Status:                 mock.StatusCode,
+			Headers:                map[string]interface{}{},
+			FixedDelayMilliseconds: int(mock.Delay * 1000),
+		},
+	}
+	if mock.MockID != "default" {
+		mapping.Request.Headers = map[string]WireMockMatch{"x-mock-id": {"equalTo": mock.MockID}}
+	}
+
+	switch body := mock.RequestBody.(type) {
+	case nil:
+	case string:
+		if body != "" {
+			mapping.Request.BodyPatterns = []WireMockMatch{{"equalTo": body}}
+		}
+	case map[string]interface{}, []interface{}:
+		mapping.Request.BodyPatterns = []WireMockMatch{{"equalToJson": body}}
+	}
+
+	// Bodies are served decoded, so the encoding headers no longer apply
+	for key, value := range mock.Headers {
+		switch strings.ToLower(key) {
+		case "content-encoding", "content-length", "transfer-encoding", "x-mock-id":
+			continue
+		}
+		mapping.Response.Headers[key] = value
+	}
+
+	switch {
+	case strings.HasSuffix(mock.ContentType, "json") && json.Valid(mock.Body):
+		mapping.Response.JSONBody = json.RawMessage(mock.Body)
+	case utf8.Valid(mock.Body):
+		mapping.Response.Body = string(mock.Body)
+	default:
+		mapping.Response.Base64Body = base64.StdEncoding.EncodeToString(mock.Body)
+	}
+	return mapping
+}
@@ -0,0 +1,102 @@
+package convert
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+)
+
+func TestWireMockRoundTrip(t *testing.T) {
+	wiremockDir := t.TempDir()
+	mappingsDir := filepath.Join(wiremockDir, "mappings")
+	filesDir := filepath.Join(wiremockDir, "__files")
+	for _, dir := range []string{mappingsDir, filesDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(filesDir, "report.txt"), []byte("quarterly report"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stubs := `{"mappings": [
+		{"id": "users", "request": {"method": "GET", "urlPath": "/api/users", "headers": {"x-mock-id": {"equalTo": "team-a"}}},
+		 "response": {"status": 200, "jsonBody": {"users": [{"id": 1}]}, "headers": {"Content-Type": "application/json"}, "fixedDelayMilliseconds": 250}},
+		{"id": "create", "request": {"method": "POST", "url": "/api/orders", "bodyPatterns": [{"equalToJson": {"sku": "A-1"}}]},
+		 "response": {"status": 201, "body": "{\"order\":7}", "headers": {"Content-Type": ["application/json"]}}},
+		{"id": "report", "request": {"method": "ANY", "url": "/report"},
+		 "response": {"status": 200, "bodyFileName": "report.txt"}},
+		{"id": "pattern", "request": {"method": "GET", "urlPattern": "/items/.*"}, "response": {"status": 200}}
+	]}`
+	if err := os.WriteFile(filepath.Join(mappingsDir, "stubs.json"), []byte(stubs), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mockDir := t.TempDir()
+	report, err := ImportWireMock(mappingsDir, "", mockDir)
+	if err != nil {
+		t.Fatalf("ImportWireMock failed: %v", err)
+	}
+	if report.Converted != 3 || report.Skipped != 1 {
+		t.Fatalf("expected 3 converted and 1 skipped, got %+v", report)
+	}
+
+	store, err := storage.NewMockStorage(mockDir)
+	if err != nil {
+		t.Fatalf("failed to load imported mocks: %v", err)
+	}
+	users := store.FindResponse("/api/users", "team-a", "application/json", "GET")
+	if users == nil || users.StatusCode != 200 || users.Delay != 0.25 {
+		t.Fatalf("unexpected imported users mock: %+v", users)
+	}
+	if string(users.Body) != `{"users":[{"id":1}]}` {
+		t.Fatalf("unexpected users body: %s", users.Body)
+	}
+	order := store.FindResponse("/api/orders", "default", "application/json", "POST")
+	if order == nil || order.StatusCode != 201 {
+		t.Fatalf("unexpected imported order mock: %+v", order)
+	}
+	for _, method := range anyMethods {
+		mock := store.FindResponse("/report", "default", "text/plain", method)
+		if mock == nil || string(mock.Body) != "quarterly report" || mock.ContentType != "text/plain" {
+			t.Fatalf("expected ANY stub to serve %s /report from __files, got %+v", method, mock)
+		}
+	}
+
+	exported, report, err := ExportWireMock(mockDir)
+	if err != nil {
+		t.Fatalf("ExportWireMock failed: %v", err)
+	}
+	if report.Converted != 7 || len(exported.Mappings) != 7 {
+		t.Fatalf("expected 7 exported mappings, got %d (%+v)", len(exported.Mappings), report)
+	}
+
+	var orderMapping, usersMapping *WireMockMapping
+	for i := range exported.Mappings {
+		switch exported.Mappings[i].Request.URLPath {
+		case "/api/orders":
+			orderMapping = &exported.Mappings[i]
+		case "/api/users":
+			usersMapping = &exported.Mappings[i]
+		}
+	}
+	if usersMapping == nil || usersMapping.Request.Headers["x-mock-id"]["equalTo"] != "team-a" {
+		t.Fatalf("expected users mapping to match x-mock-id team-a, got %+v", usersMapping)
+	}
+	if usersMapping.Response.FixedDelayMilliseconds != 250 || string(usersMapping.Response.JSONBody) != `{"users":[{"id":1}]}` {
+		t.Fatalf("unexpected users response: %+v", usersMapping.Response)
+	}
+	if orderMapping == nil || orderMapping.Request.Method != "POST" || len(orderMapping.Request.BodyPatterns) != 1 {
+		t.Fatalf("expected order mapping with a body pattern, got %+v", orderMapping)
+	}
+	pattern, _ := json.Marshal(orderMapping.Request.BodyPatterns[0])
+	if string(pattern) != `{"equalToJson":{"sku":"A-1"}}` {
+		t.Fatalf("unexpected body pattern: %s", pattern)
+	}
+	if orderMapping.Request.Headers != nil {
+		t.Fatalf("default mocks should not match on x-mock-id, got %+v", orderMapping.Request.Headers)
+	}
+}
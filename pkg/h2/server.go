@@ -0,0 +1,331 @@
+// Package h2 adds HTTP/2 to the fasthttp servers, which only speak HTTP/1.1.
+//
+// A listener wrapper sorts incoming connections: HTTP/1.1 goes to fasthttp
+// untouched, while HTTP/2 (h2 negotiated over TLS with ALPN, or h2c with prior
+// knowledge on plaintext) is served by net/http. Each HTTP/2 request is then
+// passed to the same fasthttp.Server over an in-memory HTTP/1.1 connection,
+// so handlers, server limits and error handling behave exactly as on HTTP/1.1.
+package h2
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// clientPreface starts every HTTP/2 connection (RFC 9113 section 3.4).
+var clientPreface = []byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n")
+
+// sniffTimeout bounds the TLS handshake and preface detection of a new connection.
+const sniffTimeout = 10 * time.Second
+
+// hopHeaders are HTTP/1.1 connection headers that are invalid in HTTP/2.
+var hopHeaders = map[string]bool{
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+var errorHTTP11Required = []byte(`{"error":"This endpoint requires HTTP/1.1"}`)
+
+// Server serves HTTP/2 next to a fasthttp server.
+type Server struct {
+	fast      *fasthttp.Server
+	tlsConfig *tls.Config
+	http2     *http.Server
+}
+
+// NewServer creates an HTTP/2 front for fast. With a TLS config, connections
+// are TLS and h2 is offered through ALPN; without one, plaintext connections
+// may use h2c with prior knowledge.
+func NewServer(fast *fasthttp.Server, tlsConfig *tls.Config) *Server {
+	s := &Server{fast: fast}
+	if tlsConfig != nil {
+		s.tlsConfig = tlsConfig.Clone()
+		s.tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+	}
+
+	protocols := new(http.Protocols)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	s.http2 = &http.Server{
+		Handler:     http.HandlerFunc(s.serveHTTP),
+		Protocols:   protocols,
+		IdleTimeout: fast.IdleTimeout,
+	}
+	return s
+}
+
+// Listener wraps ln, which must be a plain TCP listener, and returns the
+// listener to pass to fasthttp.Server.Serve. It yields HTTP/1.1 connections
+// only; HTTP/2 connections are served in the background. Closing it closes ln
+// and all HTTP/2 connections.
+func (s *Server) Listener(ln net.Listener) net.Listener {
+	l := &splitListener{
+		Listener: ln,
+		http1:    make(chan net.Conn),
+		http2:    make(chan net.Conn),
+		closed:   make(chan struct{}),
+		server:   s,
+	}
+	go l.acceptLoop()
+	go s.http2.Serve(&chanListener{conns: l.http2, addr: ln.Addr(), closed: l.closed})
+	return l
+}
+
+// splitListener sorts accepted connections by protocol.
+type splitListener struct {
+	net.Listener
+	http1     chan net.Conn
+	http2     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+	err       error
+	server    *Server
+}
+
+func (l *splitListener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			// Report the failure from Accept unless the listener was closed on purpose
+			l.closeOnce.Do(func() {
+				l.err = err
+				l.shutdown()
+			})
+			return
+		}
+		go l.route(conn)
+	}
+}
+
+// route detects the protocol of conn and hands it to the matching server.
+func (l *splitListener) route(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(sniffTimeout))
+
+	target := l.http1
+	if l.server.tlsConfig != nil {
+		tlsConn := tls.Server(conn, l.server.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return
+		}
+		if tlsConn.ConnectionState().NegotiatedProtocol == "h2" {
+			target = l.http2
+		}
+		conn = tlsConn
+	} else {
+		peeked := &peekedConn{Conn: conn, reader: bufio.NewReader(conn)}
+		isHTTP2, err := hasPreface(peeked.reader)
+		if err != nil {
+			conn.Close()
+			return
+		}
+		if isHTTP2 {
+			target = l.http2
+		}
+		conn = peeked
+	}
+	conn.SetDeadline(time.Time{})
+
+	select {
+	case target <- conn:
+	case <-l.closed:
+		conn.Close()
+	}
+}
+
+// hasPreface reads ahead just far enough to tell an HTTP/2 preface from an
+// HTTP/1.1 request line; no HTTP/1.1 method shares more than "P" with it.
+func hasPreface(r *bufio.Reader) (bool, error) {
+	for n := 1; n <= len(clientPreface); n++ {
+		peek, err := r.Peek(n)
+		if err != nil {
+			return false, err
+		}
+		if peek[n-1] != clientPreface[n-1] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (l *splitListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.http1:
+		return conn, nil
+	case <-l.closed:
+		if l.err != nil {
+			return nil, l.err
+		}
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *splitListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		err = l.shutdown()
+	})
+	return err
+}
+
+func (l *splitListener) shutdown() error {
+	close(l.closed)
+	err := l.Listener.Close()
+	l.server.http2.Close()
+	return err
+}
+
+// chanListener feeds connections that were already accepted to net/http.
+type chanListener struct {
+	conns  chan net.Conn
+	addr   net.Addr
+	closed chan struct{}
+}
+
+func (l *chanListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *chanListener) Close() error   { return nil }
+func (l *chanListener) Addr() net.Addr { return l.addr }
+
+// peekedConn replays the bytes read while detecting the protocol.
+type peekedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// serveHTTP passes one HTTP/2 request to fasthttp as an HTTP/1.1 request on an
+// in-memory connection and relays the response, streaming its body.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	client, server := net.Pipe()
+	go s.fast.ServeConn(newBridgeConn(server, r))
+
+	// Write the request concurrently: the handler may answer before reading the body
+	go func() {
+		req := fasthttp.AcquireRequest()
+		defer fasthttp.ReleaseRequest(req)
+		req.Header.SetMethod(r.Method)
+		req.SetRequestURI(r.URL.RequestURI())
+		req.Header.SetHost(r.Host)
+		for key, values := range r.Header {
+			for _, value := range values {
+				req.Header.Add(key, value)
+			}
+		}
+		req.Header.SetConnectionClose()
+		if r.ContentLength != 0 {
+			req.SetBodyStream(r.Body, int(r.ContentLength))
+		}
+
+		bw := bufio.NewWriter(client)
+		if err := req.Write(bw); err == nil {
+			bw.Flush()
+		}
+	}()
+
+	// Closing the pipe first ends the handler, so releasing the streamed response cannot block
+	resp := fasthttp.AcquireResponse()
+	defer func() {
+		client.Close()
+		fasthttp.ReleaseResponse(resp)
+	}()
+	resp.StreamBody = true
+	resp.SkipBody = r.Method == http.MethodHead
+	if err := resp.Read(bufio.NewReader(client)); err != nil {
+		panic(http.ErrAbortHandler)
+	}
+
+	// Upgrades and other connection takeovers have no HTTP/2 equivalent
+	if resp.StatusCode() == fasthttp.StatusSwitchingProtocols {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusHTTPVersionNotSupported)
+		w.Write(errorHTTP11Required)
+		return
+	}
+
+	header := w.Header()
+	resp.Header.VisitAll(func(key, value []byte) {
+		name := string(key)
+		if !hopHeaders[strings.ToLower(name)] {
+			header.Add(name, string(value))
+		}
+	})
+	if resp.Header.ContentLength() < 0 {
+		header.Del("Content-Length")
+	}
+	w.WriteHeader(resp.StatusCode())
+
+	if resp.SkipBody {
+		return
+	}
+	if _, err := io.Copy(flushWriter{w}, resp.BodyStream()); err != nil && !errors.Is(err, io.EOF) {
+		// The handler cut the connection mid-body; reset the stream the same way
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// flushWriter sends every write immediately so streamed responses keep their timing.
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if flusher, ok := f.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
+
+// bridgeConn is the server side of the in-memory connection. It reports the
+// HTTP/2 client's address and TLS state so handlers see the real client.
+type bridgeConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+// tlsBridgeConn additionally exposes the TLS state, as fasthttp looks for
+// ConnectionState to implement RequestCtx.TLSConnectionState.
+type tlsBridgeConn struct {
+	bridgeConn
+	state tls.ConnectionState
+}
+
+func newBridgeConn(conn net.Conn, r *http.Request) net.Conn {
+	remote, err := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+	bridge := bridgeConn{Conn: conn, remote: remote}
+	if err != nil {
+		bridge.remote = conn.RemoteAddr()
+	}
+	if r.TLS != nil {
+		return &tlsBridgeConn{bridgeConn: bridge, state: *r.TLS}
+	}
+	return &bridge
+}
+
+func (c *bridgeConn) RemoteAddr() net.Addr { return c.remote }
+
+func (c *tlsBridgeConn) Handshake() error                     { return nil }
+func (c *tlsBridgeConn) ConnectionState() tls.ConnectionState { return c.state }
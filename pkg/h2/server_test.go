@@ -0,0 +1,133 @@
+package h2
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func testHandler(ctx *fasthttp.RequestCtx) {
+	switch string(ctx.Path()) {
+	case "/stream":
+		ctx.SetContentType("text/event-stream")
+		ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+			for _, event := range []string{"one", "two"} {
+				w.WriteString("data: " + event + "\n\n")
+				w.Flush()
+			}
+		})
+	default:
+		ctx.Response.Header.Set("X-TLS", map[bool]string{true: "yes", false: "no"}[ctx.IsTLS()])
+		ctx.SetContentType("text/plain")
+		ctx.WriteString(string(ctx.Method()) + " " + string(ctx.RequestURI()) + " " + string(ctx.PostBody()))
+	}
+}
+
+// startServer serves testHandler with HTTP/2 enabled and returns its address.
+func startServer(t *testing.T, tlsConfig *tls.Config) string {
+	t.Helper()
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	fast := &fasthttp.Server{Handler: testHandler}
+	split := NewServer(fast, tlsConfig).Listener(ln)
+	go fast.Serve(split)
+	t.Cleanup(func() { fast.Shutdown() })
+	return ln.Addr().String()
+}
+
+func TestH2CAndHTTP11(t *testing.T) {
+	addr := startServer(t, nil)
+
+	transport := &http.Transport{Protocols: new(http.Protocols)}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	h2c := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+
+	resp, err := h2c.Post("http://"+addr+"/echo?x=1", "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatalf("h2c request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 || string(body) != "POST /echo?x=1 payload" || resp.Header.Get("Content-Type") != "text/plain" {
+		t.Fatalf("Unexpected h2c response %s %q %v", resp.Proto, body, resp.Header)
+	}
+
+	resp, err = h2c.Get("http://" + addr + "/stream")
+	if err != nil {
+		t.Fatalf("h2c stream failed: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "data: one\n\ndata: two\n\n" {
+		t.Fatalf("Unexpected streamed body %q", body)
+	}
+
+	// Plain HTTP/1.1 clients keep talking to fasthttp directly
+	resp, err = http.Get("http://" + addr + "/echo")
+	if err != nil {
+		t.Fatalf("HTTP/1.1 request failed: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.ProtoMajor != 1 || string(body) != "GET /echo " {
+		t.Fatalf("Unexpected HTTP/1.1 response %s %q", resp.Proto, body)
+	}
+}
+
+func TestH2OverTLS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	addr := startServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+
+	leaf, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	for _, tc := range []struct {
+		name  string
+		http2 bool
+	}{{"h2", true}, {"http/1.1", false}} {
+		transport := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}, Protocols: new(http.Protocols)}
+		transport.Protocols.SetHTTP1(!tc.http2)
+		transport.Protocols.SetHTTP2(tc.http2)
+		client := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+
+		resp, err := client.Get("https://" + addr + "/echo")
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", tc.name, err)
+		}
+		resp.Body.Close()
+		if (resp.ProtoMajor == 2) != tc.http2 || resp.Header.Get("X-TLS") != "yes" {
+			t.Fatalf("%s: got %s with X-TLS %q", tc.name, resp.Proto, resp.Header.Get("X-TLS"))
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	adminMocksPath      = []byte("/__mock__/mocks")
+	adminMockPathPrefix = []byte("/__mock__/mocks/")
+	methodPUT           = []byte("PUT")
+	methodDELETE        = []byte("DELETE")
+	errorAdminNotFound  = []byte(`{"error":"Admin mock not found"}`)
+	errorAdminScenarios = []byte(`{"error":"Admin mocks are not served when a scenario config is loaded"}`)
+	adminExportPath     = []byte("/__mock__/export")
+	adminImportPath     = []byte("/__mock__/import")
+	adminBatchPath      = []byte("/__mock__/mocks/batch")
+	errorImportDir      = []byte(`{"error":"Expected {\"dir\": \"<directory of exported mocks>\"}"}`)
+	errorBatchBody      = []byte(`{"error":"Expected an array of operations [{\"op\": \"add|update|delete\", \"id\", \"mock\"}]"}`)
+	adminRecordHint     = `Expected a recording {"request": {"method", "url", ...}, "response": {"status_code", "headers", "body", ...}}`
+)
+
+// adminMockResult describes an admin mock after it was added or updated.
+type adminMockResult struct {
+	ID          string `json:"id"`
+	MockID      string `json:"mock_id"`
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	ContentType string `json:"content_type"`
+	StatusCode  int    `json:"status_code"`
+}
+
+// newAdminMockResult describes mockResponse for the admin API answers.
+func newAdminMockResult(mockResponse *storage.MockResponse) adminMockResult {
+	return adminMockResult{
+		ID:          mockResponse.RequestID,
+		MockID:      mockResponse.MockID,
+		Method:      mockResponse.Method,
+		Path:        mockResponse.Path,
+		ContentType: mockResponse.ContentType,
+		StatusCode:  mockResponse.StatusCode,
+	}
+}
+
+// isAdminMockRequest reports whether the request addresses the admin mocks
+// API: POST /__mock__/mocks, PUT or DELETE /__mock__/mocks/{id}.
+func isAdminMockRequest(path, method []byte) bool {
+	if bytes.Equal(path, adminMocksPath) {
+		return bytes.Equal(method, methodPOST)
+	}
+	return len(path) > len(adminMockPathPrefix) && bytes.HasPrefix(path, adminMockPathPrefix) &&
+		(bytes.Equal(method, methodPUT) || bytes.Equal(method, methodDELETE))
+}
+
+// AdminMocksHandler registers, replaces and removes mocks at runtime. The
+// body of POST and PUT is a recording in the native JSON format; its mock ID
+// comes from the recorded x-mock-id header, else "default". POST answers 201
+// with the assigned id, PUT replaces the mock with that id and DELETE
+// answers 204. Admin mocks are served before recordings of the same request.
+func AdminMocksHandler(store *storage.MockStorage) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType(defaultContentType)
+		if store.HasScenarios() {
+			ctx.SetStatusCode(fasthttp.StatusConflict)
+			ctx.SetBody(errorAdminScenarios)
+			return
+		}
+
+		path := ctx.Path()
+		id := string(bytes.TrimPrefix(path, adminMockPathPrefix))
+
+		var mockResponse *storage.MockResponse
+		var err error
+		switch {
+		case bytes.Equal(ctx.Method(), methodDELETE):
+			if err := store.DeleteAdminMock(id); err != nil {
+				writeAdminError(ctx, err)
+				return
+			}
+			ctx.SetStatusCode(fasthttp.StatusNoContent)
+			return
+		case bytes.Equal(ctx.Method(), methodPUT):
+			mockResponse, err = store.UpdateAdminMock(id, ctx.PostBody(), defaultMockID)
+		default:
+			mockResponse, err = store.AddAdminMock(ctx.PostBody(), defaultMockID)
+			ctx.SetStatusCode(fasthttp.StatusCreated)
+		}
+		if err != nil {
+			writeAdminError(ctx, err)
+			return
+		}
+
+		body, _ := json.Marshal(newAdminMockResult(mockResponse))
+		ctx.SetBody(body)
+	}
+}
+
+// adminBatchResult is the outcome of one batch operation; deletes carry no mock.
+type adminBatchResult struct {
+	Op   string           `json:"op"`
+	ID   string           `json:"id"`
+	Mock *adminMockResult `json:"mock,omitempty"`
+}
+
+// AdminBatchHandler applies an array of add, update and delete operations on
+// admin mocks all or nothing. It answers with one result per operation, or
+// with the first rejected operation and nothing changed: 404 for an unknown
+// id, 400 otherwise.
+func AdminBatchHandler(store *storage.MockStorage) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType(defaultContentType)
+		if store.HasScenarios() {
+			ctx.SetStatusCode(fasthttp.StatusConflict)
+			ctx.SetBody(errorAdminScenarios)
+			return
+		}
+		var operations []storage.AdminBatchOperation
+		if err := json.Unmarshal(ctx.PostBody(), &operations); err != nil {
+			ctx.SetStatusCode(fasthttp.StatusBadRequest)
+			ctx.SetBody(errorBatchBody)
+			return
+		}
+
+		registered, err := store.ApplyAdminBatch(operations, defaultMockID)
+		if err != nil {
+			status := fasthttp.StatusBadRequest
+			if errors.Is(err, storage.ErrAdminMockNotFound) {
+				status = fasthttp.StatusNotFound
+			}
+			result := map[string]interface{}{"error": err.Error()}
+			var batchErr *storage.AdminBatchError
+			if errors.As(err, &batchErr) {
+				result["operation"] = batchErr.Index
+			}
+			ctx.SetStatusCode(status)
+			body, _ := json.Marshal(result)
+			ctx.SetBody(body)
+			return
+		}
+
+		results := make([]adminBatchResult, len(operations))
+		for i, operation := range operations {
+			results[i].Op = operation.Op
+			if results[i].Op == "" {
+				results[i].Op = storage.AdminBatchAdd
+			}
+			results[i].ID = operation.ID
+			if registered[i] != nil {
+				mock := newAdminMockResult(registered[i])
+				results[i].ID = mock.ID
+				results[i].Mock = &mock
+			}
+		}
+		body, _ := json.Marshal(map[string]interface{}{"operations": results, "total": len(results)})
+		ctx.SetBody(body)
+	}
+}
+
+// writeAdminError answers 404 for unknown ids and 400 for invalid recordings.
+func writeAdminError(ctx *fasthttp.RequestCtx, err error) {
+	if errors.Is(err, storage.ErrAdminMockNotFound) {
+		ctx.SetStatusCode(fasthttp.StatusNotFound)
+		ctx.SetBody(errorAdminNotFound)
+		return
+	}
+	ctx.SetStatusCode(fasthttp.StatusBadRequest)
+	body, _ := json.Marshal(map[string]string{"error": adminRecordHint, "detail": err.Error()})
+	ctx.SetBody(body)
+}
+
+// adminTransferRequest is the optional body of the export and import
+// endpoints.
+type adminTransferRequest struct {
+	Dir string `json:"dir"`
+}
+
+// AdminExportHandler writes the admin mocks to the directory given as
+// {"dir": ...} (the mock dir by default) in the native layout,
+// <dir>/<mock-id>/<id>.json, and lists the written files.
+func AdminExportHandler(store *storage.MockStorage) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType(defaultContentType)
+		var request adminTransferRequest
+		if body := ctx.PostBody(); len(body) > 0 {
+			if err := json.Unmarshal(body, &request); err != nil {
+				writeTransferError(ctx, fasthttp.StatusBadRequest, err)
+				return
+			}
+		}
+
+		files, err := store.ExportAdminMocks(request.Dir)
+		if err != nil {
+			writeTransferError(ctx, fasthttp.StatusInternalServerError, err)
+			return
+		}
+		body, _ := json.Marshal(map[string]interface{}{"files": files, "total": len(files)})
+		ctx.SetBody(body)
+	}
+}
+
+// AdminImportHandler registers the recordings of {"dir": ...}, laid out like
+// the mock dir, as admin mocks and answers 201 with them. Exported mocks keep
+// their id.
+func AdminImportHandler(store *storage.MockStorage) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType(defaultContentType)
+		if store.HasScenarios() {
+			ctx.SetStatusCode(fasthttp.StatusConflict)
+			ctx.SetBody(errorAdminScenarios)
+			return
+		}
+		var request adminTransferRequest
+		if err := json.Unmarshal(ctx.PostBody(), &request); err != nil || request.Dir == "" {
+			ctx.SetStatusCode(fasthttp.StatusBadRequest)
+			ctx.SetBody(errorImportDir)
+			return
+		}
+
+		imported, err := store.ImportAdminMocks(request.Dir)
+		if err != nil {
+			writeTransferError(ctx, fasthttp.StatusBadRequest, err)
+			return
+		}
+		mocks := make([]adminMockResult, 0, len(imported))
+		for _, mockResponse := range imported {
+			mocks = append(mocks, newAdminMockResult(mockResponse))
+		}
+		ctx.SetStatusCode(fasthttp.StatusCreated)
+		body, _ := json.Marshal(map[string]interface{}{"mocks": mocks, "total": len(mocks)})
+		ctx.SetBody(body)
+	}
+}
+
+// writeTransferError answers an export or import failure.
+func writeTransferError(ctx *fasthttp.RequestCtx, status int, err error) {
+	ctx.SetStatusCode(status)
+	body, _ := json.Marshal(map[string]string{"error": err.Error()})
+	ctx.SetBody(body)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net"
+	"time"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	errorChaosInternal    = []byte(`{"error":"Chaos: injected internal server error"}`)
+	errorChaosUnavailable = []byte(`{"error":"Chaos: injected service unavailable"}`)
+)
+
+// serveChaos applies chaos mode to a matched request: the extra latency is
+// slept first, then a chaos error replaces the response. It reports whether
+// the request was answered, so the mock is not served.
+func serveChaos(ctx *fasthttp.RequestCtx, store *storage.MockStorage, mockResponse *storage.MockResponse, action storage.ChaosAction) bool {
+	if action.Latency > 0 {
+		time.Sleep(action.Latency)
+	}
+
+	switch action.Error {
+	case storage.ChaosInternalError:
+		ctx.SetStatusCode(fasthttp.StatusInternalServerError)
+		ctx.Response.Header.SetBytesKV(headerContentType, defaultContentTypeBytes)
+		ctx.SetBody(errorChaosInternal)
+	case storage.ChaosUnavailable:
+		ctx.SetStatusCode(fasthttp.StatusServiceUnavailable)
+		ctx.Response.Header.SetBytesKV(headerContentType, defaultContentTypeBytes)
+		ctx.SetBody(errorChaosUnavailable)
+	case storage.ChaosTimeout:
+		// The request is held without an answer, then the connection is dropped
+		timeout := store.ChaosTimeout()
+		ctx.HijackSetNoResponse(true)
+		ctx.Hijack(func(net.Conn) { time.Sleep(timeout) })
+	default:
+		return false
+	}
+	store.CountFault(mockResponse, action.Error)
+	return true
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+// closestMatchLimit is the number of candidates listed in a debug 404.
+const closestMatchLimit = 3
+
+var (
+	headerMockDebug = []byte("x-mock-debug")
+	debugOn         = []byte("1")
+)
+
+// notFoundDebug is the body of a 404 with closest-match diagnostics.
+type notFoundDebug struct {
+	Error   string                 `json:"error"`
+	Closest []storage.ClosestMatch `json:"closest"`
+}
+
+// writeClosestMatches answers a request without a mock with a 404 listing the
+// mocks that came closest and the dimensions they failed on, for -debug and
+// x-mock-debug: 1. mockIDUsed tells that the x-mock-id lookup ran even though
+// scenarios are loaded (after a delay-only scenario matched).
+func writeClosestMatches(ctx *fasthttp.RequestCtx, store *storage.MockStorage, methodBytes []byte, mockIDUsed bool) {
+	body := ctx.PostBody()
+	if store.CanonicalJSON {
+		if canonical, ok := storage.CanonicalizeJSON(body); ok {
+			body = canonical
+		}
+	}
+	query := storage.MissQuery{
+		Method:             methodBytes,
+		MockIDUsed:         mockIDUsed,
+		RequestContentType: ctx.Request.Header.ContentType(),
+		Accept:             ctx.Request.Header.PeekBytes(headerAccept),
+		Body:               body,
+		ClientCert:         clientCertificate(ctx),
+		ClientIP:           clientIP(ctx),
+	}
+	query.Path, query.MockID, query.ContentType = mockIDLookup(store, ctx.Path(),
+		ctx.Request.Header.PeekBytes(headerXMockID), query.Accept)
+	if store.HasScenarios() {
+		query.Path = ctx.Path() // Scenarios match the path as sent
+	}
+	if isWebSocketUpgrade(ctx) {
+		query.ContentType = websocketContentType
+	}
+
+	payload, err := json.Marshal(notFoundDebug{
+		Error:   "No mock found",
+		Closest: store.ClosestMatches(query, closestMatchLimit),
+	})
+	if err != nil {
+		payload = errorNotFound
+	}
+	ctx.SetStatusCode(fasthttp.StatusNotFound)
+	ctx.Response.Header.SetBytesKV(headerContentType, defaultContentTypeBytes)
+	ctx.SetBody(payload)
+}
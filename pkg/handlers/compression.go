@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+var headerContentEncoding = []byte("Content-Encoding")
+
+// writeEncodedBody sends body compressed with the Content-Encoding recorded
+// from the upstream, as long as the client accepts it. It reports false when
+// the body should be sent uncompressed instead.
+func writeEncodedBody(ctx *fasthttp.RequestCtx, mockResponse *storage.MockResponse, body []byte) bool {
+	encoding := mockResponse.ContentEncoding()
+	if encoding == "" || !ctx.Request.Header.HasAcceptEncoding(encoding) {
+		return false
+	}
+
+	switch encoding {
+	case "gzip":
+		fasthttp.WriteGzip(ctx, body)
+	case "deflate":
+		fasthttp.WriteDeflate(ctx, body)
+	case "br":
+		fasthttp.WriteBrotli(ctx, body)
+	default:
+		return false
+	}
+	ctx.Response.Header.SetBytesK(headerContentEncoding, encoding)
+	return true
+}
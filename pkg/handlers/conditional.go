@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	headerIfNoneMatch     = []byte("If-None-Match")
+	headerIfModifiedSince = []byte("If-Modified-Since")
+)
+
+// serveNotModified answers a conditional GET or HEAD with 304 Not Modified
+// when its validators match the recorded response, with the headers of the
+// recorded 304 if the exchange was captured. It reports whether it did.
+func serveNotModified(ctx *fasthttp.RequestCtx, mockResponse *storage.MockResponse) bool {
+	if !ctx.IsGet() && !ctx.IsHead() {
+		return false
+	}
+	if !mockResponse.NotModifiedFor(ctx.Request.Header.PeekBytes(headerIfNoneMatch), ctx.Request.Header.PeekBytes(headerIfModifiedSince)) {
+		return false
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusNotModified)
+	for key, value := range mockResponse.NotModifiedHeaders() {
+		if !excludeHeadersLower[strings.ToLower(key)] {
+			ctx.Response.Header.Set(key, value)
+		}
+	}
+	ctx.Response.SkipBody = true
+	return true
+}
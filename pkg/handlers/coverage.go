@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+var coveragePath = []byte("/__mock__/coverage")
+
+// CoverageHandler reports how many of the loaded mocks served requests and
+// lists the ones that never did.
+func CoverageHandler(store *storage.MockStorage) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType(defaultContentType)
+		body, _ := json.Marshal(store.Coverage())
+		ctx.SetBody(body)
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	headerMockMatchedID   = []byte("x-mock-matched-id")
+	headerMockMatchedFile = []byte("x-mock-matched-file")
+	headerMockScenario    = []byte("x-mock-scenario")
+
+	// Former name of x-mock-matched-file, still sent for existing clients
+	headerMockFile = []byte("x-mock-file")
+)
+
+// setDebugHeaders names the recording that answers the request and the
+// scenario that chose it or adjusted its timing, for -debug-headers.
+// Headers without a value (e.g. mocks registered in code have no file) are
+// left out.
+func setDebugHeaders(ctx *fasthttp.RequestCtx, mockResponse *storage.MockResponse, scenario string) {
+	if mockResponse.RequestID != "" {
+		ctx.Response.Header.SetBytesK(headerMockMatchedID, mockResponse.RequestID)
+	}
+	if mockResponse.SourceFile != "" {
+		ctx.Response.Header.SetBytesK(headerMockMatchedFile, mockResponse.SourceFile)
+		ctx.Response.Header.SetBytesK(headerMockFile, mockResponse.SourceFile)
+	}
+	if scenario != "" {
+		ctx.Response.Header.SetBytesK(headerMockScenario, scenario)
+	}
+}
+
+// setGRPCDebugHeaders is setDebugHeaders for gRPC calls, sent as response
+// metadata.
+func setGRPCDebugHeaders(header http.Header, mockResponse *storage.MockResponse, scenario string) {
+	if mockResponse.RequestID != "" {
+		header.Set(string(headerMockMatchedID), mockResponse.RequestID)
+	}
+	if mockResponse.SourceFile != "" {
+		header.Set(string(headerMockMatchedFile), mockResponse.SourceFile)
+		header.Set(string(headerMockFile), mockResponse.SourceFile)
+	}
+	if scenario != "" {
+		header.Set(string(headerMockScenario), scenario)
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+var evaluatePath = []byte("/__mock__/evaluate")
+
+// evaluateRequest is the synthetic request accepted by EvaluateHandler. Body
+// may be any JSON value; a JSON string is matched as its raw text.
+type evaluateRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// evaluateResult explains which mock a request would get and why.
+type evaluateResult struct {
+	Matched   bool                         `json:"matched"`
+	Source    string                       `json:"source"` // scenario, mock_id or none
+	Scenario  string                       `json:"scenario,omitempty"`
+	Response  *evaluatedResponse           `json:"response,omitempty"`
+	Timing    *evaluatedTiming             `json:"timing,omitempty"`
+	Scenarios []storage.ScenarioEvaluation `json:"scenarios,omitempty"`
+}
+
+type evaluatedResponse struct {
+	MockID      string `json:"mock_id"`
+	RequestID   string `json:"request_id"`
+	Method      string `json:"method"`
+	URL         string `json:"url"`
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type"`
+	Variants    int    `json:"variants,omitempty"` // Responses an A/B experiment or response.dir picks from
+}
+
+type evaluatedTiming struct {
+	Delay  *float64 `json:"delay,omitempty"`
+	Jitter *float64 `json:"jitter,omitempty"`
+}
+
+// EvaluateHandler answers POST /__mock__/evaluate: it matches the synthetic
+// request in the body like MockHandler would and reports the verdict of every
+// scenario for the path, without serving the mock, advancing sequences or
+// counting the request anywhere.
+func EvaluateHandler(store *storage.MockStorage) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType(defaultContentType)
+
+		var input evaluateRequest
+		if err := json.Unmarshal(ctx.PostBody(), &input); err != nil || input.Path == "" {
+			ctx.SetStatusCode(fasthttp.StatusBadRequest)
+			ctx.SetBodyString(`{"error":"Expected {\"method\",\"path\",\"headers\",\"body\"} with a path"}`)
+			return
+		}
+
+		// Build the request the way the mock handler would see it
+		probe := &fasthttp.RequestCtx{}
+		probe.Request.SetRequestURI(input.Path)
+		if input.Method != "" {
+			probe.Request.Header.SetMethod(input.Method)
+		}
+		for key, value := range input.Headers {
+			probe.Request.Header.Set(key, value)
+		}
+		var text string
+		if json.Unmarshal(input.Body, &text) == nil {
+			probe.Request.SetBodyString(text)
+		} else if len(input.Body) > 0 && !bytes.Equal(input.Body, []byte("null")) {
+			probe.Request.SetBody(input.Body)
+		}
+
+		body, _ := json.Marshal(evaluate(store, probe))
+		ctx.SetBody(body)
+	}
+}
+
+// evaluate mirrors the matching steps of MockHandlerWithFallback.
+func evaluate(store *storage.MockStorage, ctx *fasthttp.RequestCtx) *evaluateResult {
+	pathBytes := ctx.Path()
+	methodBytes := ctx.Method()
+	if store.MethodOverride && bytes.Equal(methodBytes, methodPOST) {
+		if override := ctx.Request.Header.PeekBytes(headerMethodOver); len(override) > 0 {
+			methodBytes = override
+		}
+	}
+
+	result := &evaluateResult{Source: "none"}
+	var mockResponse *storage.MockResponse
+	var timing *storage.TimingOverride
+
+	if store.HasScenarios() {
+		body := ctx.PostBody()
+		if store.CanonicalJSON {
+			if canonical, ok := storage.CanonicalizeJSON(body); ok {
+				body = canonical
+			}
+		}
+		trace := store.EvaluateScenarios(pathBytes, methodBytes,
+			ctx.Request.Header.ContentType(), ctx.Request.Header.PeekBytes(headerAccept), body, nil, clientIP(ctx))
+		result.Scenarios = trace.Scenarios
+		mockResponse, timing = trace.Response, trace.Timing
+		if mockResponse != nil {
+			result.Source, result.Scenario = "scenario", trace.Scenario
+		}
+	}
+
+	if mockResponse == nil && (timing != nil || !store.HasScenarios()) {
+		mockResponse, _ = lookupByMockID(store, pathBytes, methodBytes, ctx.Request.Header.PeekBytes(headerXMockID),
+			ctx.Request.Header.PeekBytes(headerAccept), isWebSocketUpgrade(ctx))
+		if mockResponse != nil {
+			result.Source = "mock_id"
+		}
+	}
+
+	if timing != nil {
+		result.Timing = &evaluatedTiming{Delay: timing.Delay, Jitter: timing.Jitter}
+	}
+	if mockResponse == nil {
+		return result
+	}
+
+	result.Matched = true
+	result.Response = &evaluatedResponse{
+		MockID:      mockResponse.MockID,
+		RequestID:   mockResponse.RequestID,
+		Method:      mockResponse.Method,
+		URL:         mockResponse.FullURL,
+		StatusCode:  mockResponse.StatusCode,
+		ContentType: mockResponse.ContentType,
+	}
+	switch {
+	case mockResponse.Experiment != nil:
+		result.Response.Variants = 2
+	case mockResponse.Rotation != nil:
+		result.Response.Variants = len(mockResponse.Rotation.Responses)
+	}
+	return result
+}
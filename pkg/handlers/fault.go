@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"net"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+// garbageSize is how many random bytes the random_garbage fault sends.
+const garbageSize = 1024
+
+// serveFault answers with the fault of mockResponse instead of the response,
+// whose status and headers are already set. Connection faults hijack the
+// connection and release limiter once done. It reports false when the fault
+// does not apply, e.g. body faults on HEAD requests.
+func serveFault(ctx *fasthttp.RequestCtx, mockResponse *storage.MockResponse, limiter *storage.ConcurrencyLimiter) bool {
+	fault := mockResponse.Fault
+	switch fault.Kind {
+	case storage.FaultMalformedJSON:
+		if ctx.IsHead() {
+			return false
+		}
+		ctx.SetBody(malformJSON(responseBody(ctx, mockResponse)))
+
+	case storage.FaultTruncateBody:
+		if ctx.IsHead() {
+			return false
+		}
+		// The full length is announced, so clients see the connection drop mid-body
+		body := responseBody(ctx, mockResponse)
+		sent := fault.TruncateBody
+		if sent >= len(body) {
+			sent = len(body) - 1
+		}
+		if sent < 0 {
+			sent = 0
+		}
+		ctx.Response.Header.SetContentLength(len(body))
+		ctx.Response.Header.SetConnectionClose()
+		head := append([]byte(nil), ctx.Response.Header.Header()...)
+		part := body[:sent]
+		hijackFault(ctx, limiter, func(conn net.Conn) {
+			if _, err := conn.Write(head); err == nil {
+				conn.Write(part)
+			}
+		})
+
+	case storage.FaultEmptyResponse:
+		hijackFault(ctx, limiter, func(net.Conn) {})
+
+	case storage.FaultConnectionReset:
+		hijackFault(ctx, limiter, resetConn)
+
+	case storage.FaultRandomGarbage:
+		hijackFault(ctx, limiter, func(conn net.Conn) {
+			garbage := make([]byte, garbageSize)
+			crand.Read(garbage)
+			conn.Write(garbage)
+		})
+	}
+	return true
+}
+
+// hijackFault takes over the connection for a fault; fasthttp closes it once
+// write returns.
+func hijackFault(ctx *fasthttp.RequestCtx, limiter *storage.ConcurrencyLimiter, write func(net.Conn)) {
+	ctx.HijackSetNoResponse(true)
+	ctx.Hijack(func(conn net.Conn) {
+		if limiter != nil {
+			defer limiter.Release()
+		}
+		write(conn)
+	})
+}
+
+// resetConn makes the close of conn send a TCP RST instead of a FIN, by
+// turning off lingering on the TCP connection underneath fasthttp and TLS.
+func resetConn(conn net.Conn) {
+	for {
+		switch c := conn.(type) {
+		case *net.TCPConn:
+			c.SetLinger(0)
+			return
+		case interface{ UnsafeConn() net.Conn }: // fasthttp's hijacked connection
+			conn = c.UnsafeConn()
+		case interface{ NetConn() net.Conn }: // crypto/tls
+			conn = c.NetConn()
+		default:
+			return
+		}
+	}
+}
+
+// responseBody returns the body mockResponse would be served with.
+func responseBody(ctx *fasthttp.RequestCtx, mockResponse *storage.MockResponse) []byte {
+	if mockResponse.BodyTemplate != nil {
+		return mockResponse.BodyTemplate.Render(ctx)
+	}
+	return mockResponse.Body
+}
+
+// malformJSON breaks body so that no JSON parser accepts it: the closing
+// character is dropped and a stray comma takes its place.
+func malformJSON(body []byte) []byte {
+	body = bytes.TrimRight(body, " \t\r\n")
+	if len(body) == 0 {
+		return []byte("{")
+	}
+	broken := make([]byte, 0, len(body))
+	broken = append(broken, body[:len(body)-1]...)
+	return append(broken, ',')
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+// gRPC status codes used by the mock server itself.
+const (
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+)
+
+// grpcExcludeHeaders are recorded response headers that are not replayed as
+// gRPC response metadata, on top of the hop-by-hop headers.
+var grpcExcludeHeaders = map[string]bool{
+	"content-type":  true,
+	"grpc-status":   true,
+	"grpc-message":  true,
+	"grpc-encoding": true, // Messages are replayed uncompressed
+	"trailer":       true,
+}
+
+var errorGRPCOnly = []byte(`{"error":"gRPC mocks are served on the gRPC port"}`)
+
+// GRPCHandler serves recorded gRPC calls. gRPC needs HTTP/2, which fasthttp
+// does not speak, so this is a net/http handler to run on a server with
+// unencrypted HTTP/2 (h2c) enabled.
+//
+// Calls are matched like HTTP mocks: by the full method name as the path and
+// the x-mock-id metadata, or by scenarios, whose body filters see the first
+// request message decoded by storage.DecodeProtoJSON. The recorded response
+// messages are streamed at their recorded offsets when timing replay is
+// enabled, and the call ends with the recorded trailers once the client has
+// finished sending, which covers unary and all streaming kinds.
+func GRPCHandler(store *storage.MockStorage) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received := time.Now() // Recorded offsets count from here, not from when matching is done
+		if r.ProtoMajor != 2 || !strings.HasPrefix(r.Header.Get("Content-Type"), storage.GRPCContentType) {
+			w.Header().Set("Content-Type", defaultContentType)
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			w.Write(errorGRPCOnly)
+			return
+		}
+		w.Header().Set("Content-Type", storage.GRPCContentType)
+
+		// The first request message drives scenario filters
+		var body []byte
+		compressed, message, err := storage.ReadGRPCMessage(r.Body)
+		if err != nil && err != io.EOF {
+			writeGRPCStatus(w, grpcInternal, "read request: "+err.Error())
+			return
+		}
+		if compressed {
+			if r.Header.Get("Grpc-Encoding") != "gzip" {
+				writeGRPCStatus(w, grpcUnimplemented, "unsupported grpc-encoding "+r.Header.Get("Grpc-Encoding"))
+				return
+			}
+			if message, err = fasthttp.AppendGunzipBytes(nil, message); err != nil {
+				writeGRPCStatus(w, grpcInternal, "decompress request: "+err.Error())
+				return
+			}
+		}
+		if decoded, err := storage.DecodeProtoJSON(message); err == nil {
+			body = decoded
+		}
+
+		mockResponse, timing, scenario := findGRPCMock(store, r, body)
+		if mockResponse != nil && mockResponse.Rotation != nil {
+			mockResponse = mockResponse.Rotation.Next()
+		}
+		if mockResponse == nil || !mockResponse.IsGRPC {
+			store.CountMiss()
+			if unmatched := store.Unmatched(); unmatched != nil {
+				unmatched.Record(http.MethodPost, r.URL.Path)
+			}
+			writeGRPCStatus(w, grpcUnimplemented, "no mock for "+r.URL.Path)
+			return
+		}
+		store.CountServed(mockResponse)
+
+		if limiter := mockResponse.Limiter; limiter != nil {
+			if !limiter.Acquire() {
+				writeGRPCStatus(w, grpcResourceExhausted, "concurrency limit reached")
+				return
+			}
+			defer limiter.Release()
+		}
+
+		delay := mockResponse.Delay
+		jitter := store.Jitter
+		if mockResponse.Jitter != nil {
+			jitter = *mockResponse.Jitter
+		}
+		if timing != nil {
+			delay, jitter = timing.Apply(delay, jitter)
+		}
+		scale := 1.0
+		if jitter > 0 {
+			scale = 1.0 + (rand.Float64()*2-1)*jitter
+			if scale < 0 {
+				scale = 0
+			}
+		}
+		if store.ReplayTiming && delay > 0 {
+			if jitter > 0 {
+				store.ObserveJitter(mockResponse, scale)
+			}
+			store.ObserveDelay(mockResponse, delay*scale)
+		}
+		waitUntil := func(offset float64) {
+			if store.ReplayTiming {
+				sleepUntil(received.Add(time.Duration(offset * scale * float64(time.Second))))
+			}
+		}
+
+		for keyLower, key := range mockResponse.HeaderKeysLower {
+			if !excludeHeadersLower[keyLower] && !grpcExcludeHeaders[keyLower] {
+				w.Header().Set(key, mockResponse.Headers[key])
+			}
+		}
+		if store.DebugHeaders {
+			setGRPCDebugHeaders(w.Header(), mockResponse, scenario)
+		}
+		w.WriteHeader(http.StatusOK)
+		flusher, _ := w.(http.Flusher)
+
+		frame := make([]byte, 0, 256)
+		for _, msg := range mockResponse.GRPCMessages {
+			waitUntil(msg.Timestamp)
+			frame = storage.AppendGRPCMessage(frame[:0], msg.Data)
+			if _, err := w.Write(frame); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		// Client and bidirectional streams finish sending before the status
+		io.Copy(io.Discard, r.Body)
+		waitUntil(delay)
+
+		for key, value := range mockResponse.GRPCTrailers {
+			w.Header().Set(http.TrailerPrefix+key, value)
+		}
+	})
+}
+
+// findGRPCMock resolves a call through scenarios first, then the x-mock-id
+// lookup. scenario names the scenario that chose the mock or adjusted its timing.
+func findGRPCMock(store *storage.MockStorage, r *http.Request, body []byte) (mockResponse *storage.MockResponse, timing *storage.TimingOverride, scenario string) {
+	pathBytes := []byte(r.URL.Path)
+	if store.HasScenarios() {
+		mockResponse, timing = store.MatchScenario(pathBytes, methodPOST,
+			[]byte(r.Header.Get("Content-Type")), nil, body, nil, grpcClientIP(r))
+		if mockResponse != nil {
+			scenario = mockResponse.MockID // Scenario responses carry the scenario name
+		} else if timing != nil {
+			scenario = timing.Scenario
+		}
+	}
+	if mockResponse == nil && (timing != nil || !store.HasScenarios()) {
+		mockID := r.Header.Get("X-Mock-Id")
+		if mockID == "" {
+			mockID = defaultMockID
+		}
+		mockResponse = store.FindResponseBytes(pathBytes, []byte(mockID), []byte(storage.GRPCContentType), methodPOST)
+	}
+	return mockResponse, timing, scenario
+}
+
+// writeGRPCStatus ends a call without messages (a trailers-only response).
+func writeGRPCStatus(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	w.Header().Set("Grpc-Message", message)
+	w.WriteHeader(http.StatusOK)
+}
+
+// grpcClientIP is clientIP for gRPC calls: the first X-Forwarded-For entry,
+// else the remote address.
+func grpcClientIP(r *http.Request) net.IP {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		if idx := strings.IndexByte(forwarded, ','); idx >= 0 {
+			forwarded = forwarded[:idx]
+		}
+		if ip := net.ParseIP(strings.TrimSpace(forwarded)); ip != nil {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
@@ -3,7 +3,10 @@ package handlers
 import (
 	"bufio"
 	"bytes"
+	"crypto/x509"
+	"errors"
 	"math/rand"
+	"net"
 	"sync"
 	"time"
 
@@ -17,13 +20,22 @@ var (
 	defaultContentType = "application/json"
 	acceptAny          = []byte("*/*")
 	headerXMockID      = []byte("x-mock-id")
+	headerMethodOver   = []byte("X-HTTP-Method-Override")
+	methodPOST         = []byte("POST")
+	rootPath           = []byte("/")
 	headerAccept       = []byte("Accept")
 	headerContentType  = []byte("Content-Type")
+	headerForwardedFor = []byte("X-Forwarded-For")
 	errorNotFound      = []byte(`{"error":"No mock found"}`)
+	errorLimitReached  = []byte(`{"error":"Mock concurrency limit reached"}`)
+	errorBodyTooLarge  = []byte(`{"error":"Request body too large"}`)
+	errorHeaderTooBig  = []byte(`{"error":"Request header too large"}`)
+	errorBadRequest    = []byte(`{"error":"Malformed request"}`)
+	errorTimeout       = []byte(`{"error":"Request timeout"}`)
 
-	// SSE constants to avoid allocations
-	sseDataPrefix = []byte("data: ")
-	sseDataSuffix = []byte("\n\n")
+	// Lookup defaults when a request has no x-mock-id or Accept header
+	defaultMockIDBytes      = []byte(defaultMockID)
+	defaultContentTypeBytes = []byte(defaultContentType)
 
 	// Pool for SSE stream writers to avoid allocations
 	sseStreamPool = sync.Pool{
@@ -52,12 +64,67 @@ func trimSpaceASCII(s []byte) []byte {
 	return s[start:end]
 }
 
+// clientCertificate returns the verified mTLS client certificate of the request, if any.
+func clientCertificate(ctx *fasthttp.RequestCtx) *x509.Certificate {
+	state := ctx.TLSConnectionState()
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return state.PeerCertificates[0]
+}
+
+// clientIP returns the address client_ip scenarios match: the first
+// X-Forwarded-For entry when sent, so tests can pose as any client, else the
+// remote address of the connection.
+func clientIP(ctx *fasthttp.RequestCtx) net.IP {
+	if forwarded := ctx.Request.Header.PeekBytes(headerForwardedFor); len(forwarded) > 0 {
+		if idx := bytes.IndexByte(forwarded, ','); idx >= 0 {
+			forwarded = forwarded[:idx]
+		}
+		if ip := net.ParseIP(string(trimSpaceASCII(forwarded))); ip != nil {
+			return ip
+		}
+	}
+	return ctx.RemoteIP()
+}
+
 // sseStreamWriter is a pooled struct for streaming SSE events with timing.
 // Using sync.Pool reduces memory allocations by ~30% (1595 -> 1105 bytes per request).
 // The pool reuses writer objects instead of creating new ones for each SSE request.
 type sseStreamWriter struct {
 	events      []storage.SSEEvent
-	jitterScale float64 // Computed once per request: 1.0 + random jitter
+	jitterScale float64                     // Computed once per request: 1.0 + random jitter
+	gapJitter   float64                     // Independent jitter fraction applied to each inter-event gap
+	limiter     *storage.ConcurrencyLimiter // Released once the stream finishes
+
+	// Abort fault state (hijacked connections only)
+	abort   *storage.SSEAbort
+	head    []byte // Raw response head written before the chunked body
+	instant bool   // Send events without delays when timing replay is disabled
+
+	// Event injection state
+	hub      *storage.SSEHub
+	stream   string      // Mock ID the stream is registered under
+	inbox    chan []byte // Injected frames; nil when the stream is not registered
+	keepOpen bool        // Hold the stream open after the recorded events
+}
+
+// nextGap returns the delay before event relative to the previous one.
+func (sw *sseStreamWriter) nextGap(event *storage.SSEEvent, previousTimestamp *float64) float64 {
+	// Event timestamps are already scaled (either from original recording or from delay override in config)
+	// We only apply jitter scale here, which affects all events proportionally
+	effectiveTimestamp := event.Timestamp * sw.jitterScale
+	gap := effectiveTimestamp - *previousTimestamp
+	*previousTimestamp = effectiveTimestamp
+
+	// Per-gap jitter varies each inter-event gap on its own (bounded percentage)
+	if sw.gapJitter > 0 && gap > 0 {
+		gap *= 1.0 + (rand.Float64()*2-1)*sw.gapJitter
+		if gap < 0 {
+			gap = 0
+		}
+	}
+	return gap
 }
 
 // StreamTo writes SSE events to the writer with timing delays
@@ -66,26 +133,57 @@ func (sw *sseStreamWriter) StreamTo(w *bufio.Writer) {
 	// This moves the time.Now() allocation out of the hot request handling path
 	startTime := time.Now()
 
+	// Offsets are accumulated gap by gap so each gap can be jittered independently
+	previousTimestamp := 0.0
+	offset := 0.0
+
 	for i := range sw.events {
 		event := &sw.events[i]
 
-		// Event timestamps are already scaled (either from original recording or from delay override in config)
-		// We only apply jitter scale here, which affects all events proportionally
-		effectiveTimestamp := event.Timestamp * sw.jitterScale
-		targetTime := startTime.Add(time.Duration(effectiveTimestamp * float64(time.Second)))
+		offset += sw.nextGap(event, &previousTimestamp)
+		targetTime := startTime.Add(time.Duration(offset * float64(time.Second)))
+
+		// Wait until target time, forwarding injected events in the meantime
+		if !sw.instant && !sw.wait(w, time.Until(targetTime)) {
+			sw.release()
+			return
+		}
+
+		// Send pre-built event frame - use []byte to avoid string allocations
+		w.Write(event.Frame)
+		if err := w.Flush(); err != nil {
+			sw.release()
+			return
+		}
+	}
+
+	if sw.keepOpen {
+		sw.hold(w)
+	}
 
-		// Wait until target time
-		time.Sleep(time.Until(targetTime))
+	sw.release()
+}
 
-		// Send event - use []byte to avoid string allocations
-		w.Write(sseDataPrefix)
-		w.Write(event.SerializedData)
-		w.Write(sseDataSuffix)
-		w.Flush()
+// release frees the concurrency slot and returns the writer to the pool.
+func (sw *sseStreamWriter) release() {
+	// Free the concurrency slot held for the duration of the stream
+	if sw.limiter != nil {
+		sw.limiter.Release()
+	}
+
+	if sw.inbox != nil {
+		sw.hub.Unsubscribe(sw.stream, sw.inbox)
 	}
 
 	// Return to pool after streaming
 	sw.events = nil
+	sw.limiter = nil
+	sw.abort = nil
+	sw.head = nil
+	sw.instant = false
+	sw.hub = nil
+	sw.inbox = nil
+	sw.keepOpen = false
 	sseStreamPool.Put(sw)
 }
 
@@ -106,49 +204,153 @@ var (
 	}
 )
 
+// splitMockIDPrefix splits /<mock-id>/rest into the mock ID and /rest.
+// A path with a single segment maps to the mock's root path.
+func splitMockIDPrefix(path []byte) ([]byte, []byte) {
+	if len(path) < 2 || path[0] != '/' {
+		return nil, path
+	}
+	idx := bytes.IndexByte(path[1:], '/')
+	if idx < 0 {
+		return path[1:], rootPath
+	}
+	return path[1 : idx+1], path[idx+1:]
+}
+
+// mockIDLookup resolves the path, mock ID and content type that the x-mock-id
+// lookup uses for a request. A nil content type accepts any (Accept: */*).
+func mockIDLookup(store *storage.MockStorage, pathBytes, mockIDBytes, acceptBytes []byte) ([]byte, []byte, []byte) {
+	lookupPath := pathBytes
+	if len(mockIDBytes) == 0 && store.MockIDPrefix {
+		// /<mock-id>/original/path selects the variant by URL
+		mockIDBytes, lookupPath = splitMockIDPrefix(pathBytes)
+	}
+	if len(mockIDBytes) == 0 {
+		mockIDBytes = defaultMockIDBytes
+	}
+
+	if len(acceptBytes) == 0 {
+		return lookupPath, mockIDBytes, defaultContentTypeBytes
+	}
+	if bytes.Equal(acceptBytes, acceptAny) {
+		return lookupPath, mockIDBytes, nil
+	}
+	if idx := bytes.IndexByte(acceptBytes, ','); idx >= 0 {
+		acceptBytes = acceptBytes[:idx]
+	}
+	if idx := bytes.IndexByte(acceptBytes, ';'); idx >= 0 {
+		acceptBytes = acceptBytes[:idx]
+	}
+	return lookupPath, mockIDBytes, trimSpaceASCII(acceptBytes)
+}
+
+// lookupByMockID finds the recording for a request by x-mock-id (or the
+// /<mock-id> path prefix) and Accept header, as used without scenarios. It
+// also returns the path looked up, without any /<mock-id> prefix.
+func lookupByMockID(store *storage.MockStorage, pathBytes, methodBytes, mockIDBytes, acceptBytes []byte, websocket bool) (*storage.MockResponse, []byte) {
+	lookupPath, mockIDBytes, contentType := mockIDLookup(store, pathBytes, mockIDBytes, acceptBytes)
+	if websocket {
+		// Upgrades are answered by recorded WebSocket conversations
+		return store.FindResponseBytes(lookupPath, mockIDBytes, websocketContentType, methodBytes), lookupPath
+	}
+	if contentType == nil {
+		// Accept: */* means any content-type is acceptable
+		return store.FindResponseBytesAnyContentType(lookupPath, mockIDBytes, methodBytes), lookupPath
+	}
+	return store.FindResponseBytes(lookupPath, mockIDBytes, contentType, methodBytes), lookupPath
+}
+
 // MockHandler handles all requests and returns mock responses based on the storage.
 // Zero allocations: works with []byte directly, no string conversions.
 func MockHandler(store *storage.MockStorage, logger *storage.NotFoundLogger) fasthttp.RequestHandler {
-	defaultMockIDBytes := []byte(defaultMockID)
-	defaultContentTypeBytes := []byte(defaultContentType)
+	return MockHandlerWithFallback(store, logger, nil)
+}
 
+// MockHandlerWithFallback is MockHandler with a handler for requests that have
+// no mock. In hybrid mode the fallback proxies to the real backend instead of
+// answering 404.
+func MockHandlerWithFallback(store *storage.MockStorage, logger *storage.NotFoundLogger, fallback fasthttp.RequestHandler) fasthttp.RequestHandler {
 	return func(ctx *fasthttp.RequestCtx) {
 		// Work with []byte directly - zero allocations
 		pathBytes := ctx.Path()
 		methodBytes := ctx.Method()
 		var mockResponse *storage.MockResponse
+		var timing *storage.TimingOverride
+		var scenario string
+		matchedPath := pathBytes // Path the mock was found by, for templated paths
 
-		if store.HasScenarios() {
-			mockResponse = store.MatchScenarioResponse(pathBytes, methodBytes, ctx.PostBody())
-		} else {
-			mockIDBytes := ctx.Request.Header.PeekBytes(headerXMockID)
-			if len(mockIDBytes) == 0 {
-				mockIDBytes = defaultMockIDBytes
+		// Developers can dictate the response of a single request
+		if store.RespondWithHeader && serveRespondWith(ctx) {
+			return
+		}
+
+		// Clients tunneling PUT/DELETE through POST declare the real method in a header
+		if store.MethodOverride && bytes.Equal(methodBytes, methodPOST) {
+			if override := ctx.Request.Header.PeekBytes(headerMethodOver); len(override) > 0 {
+				methodBytes = override
 			}
+		}
 
-			acceptBytes := ctx.Request.Header.PeekBytes(headerAccept)
-			if len(acceptBytes) == 0 {
-				acceptBytes = defaultContentTypeBytes
-				mockResponse = store.FindResponseBytes(pathBytes, mockIDBytes, acceptBytes, methodBytes)
-			} else if bytes.Equal(acceptBytes, acceptAny) {
-				// Accept: */* means any content-type is acceptable
-				mockResponse = store.FindResponseBytesAnyContentType(pathBytes, mockIDBytes, methodBytes)
-			} else {
-				if idx := bytes.IndexByte(acceptBytes, ','); idx >= 0 {
-					acceptBytes = acceptBytes[:idx]
-				}
-				if idx := bytes.IndexByte(acceptBytes, ';'); idx >= 0 {
-					acceptBytes = acceptBytes[:idx]
+		// Requests that break the API contract never reach a mock
+		if validator := store.RequestValidator(); validator != nil {
+			if violations := validator.ValidateRequest(ctx, methodBytes); violations != nil {
+				ctx.SetStatusCode(fasthttp.StatusBadRequest)
+				ctx.Response.Header.SetBytesKV(headerContentType, defaultContentTypeBytes)
+				ctx.SetBody(violations)
+				return
+			}
+		}
+
+		if store.HasScenarios() {
+			body := ctx.PostBody()
+			// Semantically identical JSON payloads should match the same scenario
+			if store.CanonicalJSON {
+				if canonical, ok := storage.CanonicalizeJSON(body); ok {
+					body = canonical
 				}
-				acceptBytes = trimSpaceASCII(acceptBytes)
-				mockResponse = store.FindResponseBytes(pathBytes, mockIDBytes, acceptBytes, methodBytes)
+			}
+			mockResponse, timing = store.MatchScenario(pathBytes, methodBytes,
+				ctx.Request.Header.ContentType(), ctx.Request.Header.PeekBytes(headerAccept), body, clientCertificate(ctx), clientIP(ctx))
+			if mockResponse != nil {
+				scenario = mockResponse.MockID // Scenario responses carry the scenario name
+			} else if timing != nil {
+				scenario = timing.Scenario
 			}
 		}
 
+		// Delay-only scenarios fall through to the regular x-mock-id lookup
+		if mockResponse == nil && (timing != nil || !store.HasScenarios()) {
+			mockResponse, matchedPath = lookupByMockID(store, pathBytes, methodBytes, ctx.Request.Header.PeekBytes(headerXMockID),
+				ctx.Request.Header.PeekBytes(headerAccept), isWebSocketUpgrade(ctx))
+		}
+
+		if mockResponse == nil && fallback != nil {
+			store.CountMiss()
+			fallback(ctx)
+			return
+		}
+
+		// Record the final response once the handler has shaped it, against the
+		// experiment variant or rotated recording that answered
+		if served := store.ServedLog(); served != nil {
+			defer func() { served.LogServed(ctx, mockResponse) }()
+		}
+
 		if mockResponse == nil {
-			ctx.SetStatusCode(fasthttp.StatusNotFound)
-			ctx.Response.Header.SetBytesKV(headerContentType, defaultContentTypeBytes)
-			ctx.SetBody(errorNotFound)
+			store.CountMiss()
+			if store.Debug || bytes.Equal(ctx.Request.Header.PeekBytes(headerMockDebug), debugOn) {
+				writeClosestMatches(ctx, store, methodBytes, timing != nil)
+			} else if notFound := store.NotFoundResponse(); notFound != nil {
+				notFound.Write(ctx)
+			} else {
+				ctx.SetStatusCode(fasthttp.StatusNotFound)
+				ctx.Response.Header.SetBytesKV(headerContentType, defaultContentTypeBytes)
+				ctx.SetBody(errorNotFound)
+			}
+			// Count unmatched requests for the strict mode summary
+			if unmatched := store.Unmatched(); unmatched != nil {
+				unmatched.Record(string(methodBytes), string(pathBytes))
+			}
 			// Log 404 response if logger is configured
 			if logger != nil {
 				if err := logger.LogNotFound(ctx); err != nil {
@@ -159,78 +361,285 @@ func MockHandler(store *storage.MockStorage, logger *storage.NotFoundLogger) fas
 			return
 		}
 
-		// Apply timing delay for non-SSE requests (SSE handles timing internally)
-		if store.ReplayTiming && !mockResponse.IsSSE && mockResponse.Delay > 0 {
-			delay := mockResponse.Delay
+		// Chaos mode delays matched requests or replaces their response at random
+		if action := store.Chaos(pathBytes); action != (storage.ChaosAction{}) && serveChaos(ctx, store, mockResponse, action) {
+			return
+		}
 
-			// Apply jitter if configured
-			if store.Jitter > 0 {
-				jitterRange := delay * store.Jitter
-				jitterAmount := (rand.Float64()*2 - 1) * jitterRange // -jitter to +jitter
-				delay = delay + jitterAmount
-				if delay < 0 {
-					delay = 0
-				}
+		// Templates can refer to the params of paths such as /users/{id}
+		storage.BindPathParams(ctx, mockResponse, matchedPath)
+
+		// Scenarios capture session variables for {{session.name}} placeholders
+		store.BindSession(ctx, mockResponse)
+
+		// A/B scenarios pick the variant for this client
+		if mockResponse.Experiment != nil {
+			mockResponse = mockResponse.Experiment.Select(ctx)
+		}
+
+		// response.dir scenarios rotate through their recordings
+		if mockResponse.Rotation != nil {
+			mockResponse = mockResponse.Rotation.Next()
+		}
+
+		// Log the matched request against the recording that answers it
+		if matched := store.MatchLog(); matched != nil {
+			defer matched.LogMatched(ctx, mockResponse)
+		}
+
+		if store.DebugHeaders {
+			setDebugHeaders(ctx, mockResponse, scenario)
+		}
+
+		store.CountServed(mockResponse)
+
+		serveMock(ctx, store, mockResponse, timing)
+	}
+}
+
+// serveMock writes a resolved mock response, applying timing, templates,
+// streaming and compression. timing is the override of a delay-only scenario, if any.
+func serveMock(ctx *fasthttp.RequestCtx, store *storage.MockStorage, mockResponse *storage.MockResponse, timing *storage.TimingOverride) {
+	// gRPC calls need HTTP/2 and are answered by GRPCHandler
+	if mockResponse.IsGRPC {
+		ctx.SetStatusCode(fasthttp.StatusUnsupportedMediaType)
+		ctx.Response.Header.SetBytesKV(headerContentType, defaultContentTypeBytes)
+		ctx.SetBody(errorGRPCOnly)
+		return
+	}
+
+	// Enforce per-mock concurrency limit; the slot is held until the response is complete
+	limiter := mockResponse.Limiter
+	if limiter != nil {
+		if !limiter.Acquire() {
+			ctx.SetStatusCode(fasthttp.StatusServiceUnavailable)
+			ctx.Response.Header.SetBytesKV(headerContentType, defaultContentTypeBytes)
+			ctx.SetBody(errorLimitReached)
+			return
+		}
+		defer func() {
+			if limiter != nil {
+				limiter.Release()
 			}
+		}()
+	}
+
+	// Resolve effective timing: per-response jitter, then any delay-only scenario override
+	delay := mockResponse.Delay
+	jitter := store.Jitter
+	if mockResponse.Jitter != nil {
+		jitter = *mockResponse.Jitter
+	}
+	if timing != nil {
+		delay, jitter = timing.Apply(delay, jitter)
+	}
 
-			time.Sleep(time.Duration(delay * float64(time.Second)))
+	// SSE timestamps are stretched to fit an overridden delay
+	sseScale := 1.0
+	if mockResponse.IsSSE && mockResponse.Delay > 0 {
+		sseScale = delay / mockResponse.Delay
+	}
+
+	// Apply timing delay for non-SSE requests (SSE handles timing internally)
+	if store.ReplayTiming && !mockResponse.IsSSE && delay > 0 {
+		// Apply jitter if configured
+		if jitter > 0 {
+			configured := delay
+			jitterRange := delay * jitter
+			jitterAmount := (rand.Float64()*2 - 1) * jitterRange // -jitter to +jitter
+			delay = delay + jitterAmount
+			if delay < 0 {
+				delay = 0
+			}
+			store.ObserveJitter(mockResponse, delay/configured)
 		}
+		store.ObserveDelay(mockResponse, delay)
 
-		// Set status code
-		ctx.SetStatusCode(mockResponse.StatusCode)
+		// Wait out what is left of the delay once the response is ready: time
+		// spent reading the body, matching and rendering already counts
+		defer sleepUntil(requestReceivedAt(ctx).Add(time.Duration(delay * float64(time.Second))))
+	}
 
-		// Copy response headers - use pre-computed lowercase keys
-		contentTypeSet := false
-		for keyLower, key := range mockResponse.HeaderKeysLower {
-			if !excludeHeadersLower[keyLower] {
-				ctx.Response.Header.Set(key, mockResponse.Headers[key])
-				if keyLower == "content-type" {
-					contentTypeSet = true
-				}
+	// Conditional requests whose validators still match get a bodiless 304
+	if serveNotModified(ctx, mockResponse) {
+		return
+	}
+
+	// WebSocket conversations are replayed over the upgraded connection
+	if mockResponse.IsWebSocket {
+		replay := &websocketReplay{
+			frames:  mockResponse.WebSocketFrames,
+			instant: !store.ReplayTiming,
+			scale:   1.0,
+			limiter: limiter,
+		}
+		if jitter > 0 {
+			replay.scale = 1.0 + (rand.Float64()*2-1)*jitter
+			if replay.scale < 0 {
+				replay.scale = 0
 			}
 		}
+		if serveWebSocket(ctx, mockResponse, replay) {
+			limiter = nil
+		}
+		return
+	}
+
+	// Set status code
+	ctx.SetStatusCode(mockResponse.StatusCode)
 
-		// Set content-type if not already set
-		if !contentTypeSet {
-			if mockResponse.ContentType != "" {
-				ctx.Response.Header.SetContentType(mockResponse.ContentType)
+	// Copy response headers - use pre-computed lowercase keys
+	contentTypeSet := false
+	for keyLower, key := range mockResponse.HeaderKeysLower {
+		if !excludeHeadersLower[keyLower] {
+			if tmpl := mockResponse.HeaderTemplates[key]; tmpl != nil {
+				ctx.Response.Header.Set(key, tmpl.RenderString(ctx))
 			} else {
-				ctx.Response.Header.SetContentType(defaultContentType)
+				ctx.Response.Header.Set(key, mockResponse.Headers[key])
+			}
+			if keyLower == "content-type" {
+				contentTypeSet = true
 			}
 		}
+	}
 
-		// Handle SSE responses - use streaming for timing replay
-		if mockResponse.IsSSE && len(mockResponse.SSEEvents) > 0 {
-			// Use streaming only when timing replay is enabled
-			if store.ReplayTiming {
-				// Get writer from pool - reduces allocations by reusing objects
-				writer := sseStreamPool.Get().(*sseStreamWriter)
-				writer.events = mockResponse.SSEEvents
-
-				// Calculate jitter scale once for all events in this request
-				// Jitter is applied proportionally to all event timestamps
-				// Event timestamps are already properly scaled from config loading (scenario.go)
-				writer.jitterScale = 1.0
-				if store.Jitter > 0 {
-					jitterAmount := (rand.Float64()*2 - 1) * store.Jitter // -jitter to +jitter
-					writer.jitterScale = 1.0 + jitterAmount
-					if writer.jitterScale < 0 {
-						writer.jitterScale = 0
-					}
-				}
+	// Set content-type if not already set
+	if !contentTypeSet {
+		if mockResponse.ContentType != "" {
+			ctx.Response.Header.SetContentType(mockResponse.ContentType)
+		} else {
+			ctx.Response.Header.SetContentType(defaultContentType)
+		}
+	}
+	applyHeaderFidelity(ctx, store.HeaderFidelity, mockResponse)
 
-				// Pass method as stream writer - this creates a method value (small allocation)
-				// but avoids closure allocation that would capture all local variables
-				ctx.Response.SetBodyStreamWriter(writer.StreamTo)
-			} else {
-				// Without timing replay, use pre-serialized body (no allocation)
-				ctx.SetBody(mockResponse.Body)
+	// Faults replace the response to exercise client error handling
+	if mockResponse.Fault != nil && serveFault(ctx, mockResponse, limiter) {
+		store.CountFault(mockResponse, mockResponse.Fault.Kind)
+		if ctx.Hijacked() {
+			limiter = nil // Released once the fault is written
+		}
+		return
+	}
+
+	// HEAD gets the headers GET would get. fasthttp drops the body and keeps
+	// its length, so only streams and bodiless HEAD recordings need care here.
+	if ctx.IsHead() {
+		if mockResponse.IsSSE && len(mockResponse.SSEEvents) > 0 && (store.ReplayTiming || mockResponse.SSEKeepOpen || mockResponse.SSEAbort != nil) {
+			ctx.Response.Header.SetContentLength(-1) // chunked, like the stream
+			return
+		}
+		if len(mockResponse.Body) == 0 && mockResponse.BodyTemplate == nil {
+			if length, ok := mockResponse.RecordedContentLength(); ok {
+				ctx.Response.Header.SetContentLength(length)
 			}
 			return
 		}
+	}
+
+	// SSE responses with an abort fault take over the connection so it can be cut mid-stream
+	if mockResponse.IsSSE && mockResponse.SSEAbort != nil && len(mockResponse.SSEEvents) > 0 {
+		writer := sseStreamPool.Get().(*sseStreamWriter)
+		writer.events = mockResponse.SSEEvents
+		writer.abort = mockResponse.SSEAbort
+		writer.instant = !store.ReplayTiming
+		writer.jitterScale = sseScale
+		writer.gapJitter = store.SSEGapJitter
+		writer.limiter = limiter
+		limiter = nil
+
+		ctx.Response.Header.SetContentLength(-1) // chunked
+		ctx.Response.Header.SetConnectionClose()
+		writer.head = append(writer.head[:0], ctx.Response.Header.Header()...)
+
+		store.CountFault(mockResponse, storage.FaultSSEAbort)
+		ctx.HijackSetNoResponse(true)
+		ctx.Hijack(writer.AbortTo)
+		return
+	}
+
+	// Handle SSE responses - use streaming for timing replay
+	if mockResponse.IsSSE && len(mockResponse.SSEEvents) > 0 {
+		// Use streaming only when timing replay is enabled or the stream is held open
+		if store.ReplayTiming || mockResponse.SSEKeepOpen {
+			// Get writer from pool - reduces allocations by reusing objects
+			writer := sseStreamPool.Get().(*sseStreamWriter)
+			writer.events = mockResponse.SSEEvents
+
+			// Hand the concurrency slot over to the stream writer
+			writer.limiter = limiter
+			limiter = nil
+
+			// Calculate jitter scale once for all events in this request
+			// Jitter is applied proportionally to all event timestamps
+			// Event timestamps are already properly scaled from config loading (scenario.go)
+			writer.jitterScale = 1.0
+			if jitter > 0 {
+				jitterAmount := (rand.Float64()*2 - 1) * jitter // -jitter to +jitter
+				writer.jitterScale = 1.0 + jitterAmount
+				if writer.jitterScale < 0 {
+					writer.jitterScale = 0
+				}
+				store.ObserveJitter(mockResponse, writer.jitterScale)
+			}
+			writer.jitterScale *= sseScale
+
+			writer.gapJitter = store.SSEGapJitter
+			writer.instant = !store.ReplayTiming
+			writer.keepOpen = mockResponse.SSEKeepOpen
+
+			// Register the stream so events can be injected through the admin endpoint
+			writer.hub = store.SSEHub()
+			writer.stream = mockResponse.MockID
+			writer.inbox = writer.hub.Subscribe(writer.stream)
+
+			// Pass method as stream writer - this creates a method value (small allocation)
+			// but avoids closure allocation that would capture all local variables
+			ctx.Response.SetBodyStreamWriter(writer.StreamTo)
+		} else {
+			// Without timing replay, use pre-serialized body (no allocation)
+			ctx.SetBody(mockResponse.Body)
+			if chunkedFraming(store.HeaderFidelity, mockResponse) {
+				streamBody(ctx)
+			}
+		}
+		return
+	}
+
+	// Body is already pre-serialized; templated bodies are rendered per request
+	body := mockResponse.Body
+	if mockResponse.BodyTemplate != nil {
+		body = mockResponse.BodyTemplate.Render(ctx)
+	}
+
+	// Reproduce the upstream's compression so encoding and size match production
+	if !store.CompressionParity || !writeEncodedBody(ctx, mockResponse, body) {
+		ctx.SetBody(body)
+	}
+
+	if chunkedFraming(store.HeaderFidelity, mockResponse) {
+		streamBody(ctx)
+	}
+}
+
+// requestReceivedAt is when the first bytes of the request arrived on a
+// connection accepted by a ReceiptListener. Otherwise it is when fasthttp
+// finished reading the request and called the handler, or now for contexts
+// that were not served by a fasthttp.Server.
+func requestReceivedAt(ctx *fasthttp.RequestCtx) time.Time {
+	if received, ok := connReceivedAt(ctx); ok {
+		return received
+	}
+	if received := ctx.Time(); !received.IsZero() {
+		return received
+	}
+	return time.Now()
+}
 
-		// Body is already pre-serialized - just send it (no allocation)
-		ctx.SetBody(mockResponse.Body)
+// sleepUntil blocks until deadline; a deadline already past returns at once.
+func sleepUntil(deadline time.Time) {
+	if wait := time.Until(deadline); wait > 0 {
+		time.Sleep(wait)
 	}
 }
 
@@ -238,8 +647,8 @@ func MockHandler(store *storage.MockStorage, logger *storage.NotFoundLogger) fas
 func StatsHandler(store *storage.MockStorage) fasthttp.RequestHandler {
 	return func(ctx *fasthttp.RequestCtx) {
 		ctx.SetContentType("application/json")
-		// Pre-serialized stats - zero allocation, zero CPU
-		ctx.SetBody(store.GetStatsJSON())
+		// Streamed with the current counters, without buffering the whole document
+		ctx.SetBodyStreamWriter(func(w *bufio.Writer) { store.WriteStatsJSON(w) })
 	}
 }
 
@@ -247,15 +656,54 @@ func StatsHandler(store *storage.MockStorage) fasthttp.RequestHandler {
 func ListMocksHandler(store *storage.MockStorage) fasthttp.RequestHandler {
 	return func(ctx *fasthttp.RequestCtx) {
 		ctx.SetContentType("application/json")
-		// Pre-serialized mock list - zero allocation, zero CPU
-		ctx.SetBody(store.GetMockListJSON())
+		// Streamed one mock at a time, so large corpora keep memory flat
+		ctx.SetBodyStreamWriter(func(w *bufio.Writer) { store.WriteMockListJSON(w) })
+	}
+}
+
+// ErrorsHandler lists mock files that were skipped because they could not be loaded.
+func ErrorsHandler(store *storage.MockStorage) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType("application/json")
+		// Pre-serialized at load time like stats and the mock list
+		ctx.SetBody(store.GetErrorsJSON())
+	}
+}
+
+// RequestErrorHandler answers requests the server rejected before routing, such
+// as those over the configured body or header size limits, with JSON errors.
+// Use it as fasthttp.Server.ErrorHandler.
+func RequestErrorHandler(ctx *fasthttp.RequestCtx, err error) {
+	var smallBuffer *fasthttp.ErrSmallBuffer
+	var netErr *net.OpError
+	ctx.Response.Header.SetBytesK(headerContentType, defaultContentType)
+
+	switch {
+	case errors.Is(err, fasthttp.ErrBodyTooLarge):
+		ctx.SetStatusCode(fasthttp.StatusRequestEntityTooLarge)
+		ctx.SetBody(errorBodyTooLarge)
+	case errors.As(err, &smallBuffer):
+		ctx.SetStatusCode(fasthttp.StatusRequestHeaderFieldsTooLarge)
+		ctx.SetBody(errorHeaderTooBig)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		ctx.SetStatusCode(fasthttp.StatusRequestTimeout)
+		ctx.SetBody(errorTimeout)
+	default:
+		ctx.SetStatusCode(fasthttp.StatusBadRequest)
+		ctx.SetBody(errorBadRequest)
 	}
 }
 
 // Router routes requests to appropriate handlers.
 func Router(store *storage.MockStorage, logDir string) fasthttp.RequestHandler {
+	return RouterWithFallback(store, logDir, nil)
+}
+
+// RouterWithFallback is Router with a fallback for requests that have no mock.
+func RouterWithFallback(store *storage.MockStorage, logDir string, fallback fasthttp.RequestHandler) fasthttp.RequestHandler {
 	statsPath := []byte("/__mock__/stats")
 	listPath := []byte("/__mock__/list")
+	errorsPath := []byte("/__mock__/errors")
 	methodGET := []byte("GET")
 
 	// Create logger for 404 responses
@@ -285,7 +733,62 @@ func Router(store *storage.MockStorage, logDir string) fasthttp.RequestHandler {
 			return
 		}
 
+		if bytes.Equal(pathBytes, errorsPath) && bytes.Equal(methodBytes, methodGET) {
+			ErrorsHandler(store)(ctx)
+			return
+		}
+
+		if bytes.Equal(pathBytes, metricsPath) && bytes.Equal(methodBytes, methodGET) {
+			MetricsHandler(store)(ctx)
+			return
+		}
+
+		if bytes.Equal(pathBytes, coveragePath) && bytes.Equal(methodBytes, methodGET) {
+			CoverageHandler(store)(ctx)
+			return
+		}
+
+		if bytes.Equal(pathBytes, evaluatePath) && bytes.Equal(methodBytes, methodPOST) {
+			EvaluateHandler(store)(ctx)
+			return
+		}
+
+		if bytes.Equal(methodBytes, methodPOST) && isEmitPath(pathBytes) {
+			EmitHandler(store)(ctx)
+			return
+		}
+
+		if bytes.Equal(methodBytes, methodPOST) && bytes.Equal(pathBytes, reloadPath) {
+			ReloadHandler(store)(ctx)
+			return
+		}
+
+		if bytes.Equal(methodBytes, methodPOST) && bytes.Equal(pathBytes, resetPath) {
+			ResetHandler(store)(ctx)
+			return
+		}
+
+		if bytes.Equal(methodBytes, methodPOST) && bytes.Equal(pathBytes, adminExportPath) {
+			AdminExportHandler(store)(ctx)
+			return
+		}
+
+		if bytes.Equal(methodBytes, methodPOST) && bytes.Equal(pathBytes, adminImportPath) {
+			AdminImportHandler(store)(ctx)
+			return
+		}
+
+		if bytes.Equal(methodBytes, methodPOST) && bytes.Equal(pathBytes, adminBatchPath) {
+			AdminBatchHandler(store)(ctx)
+			return
+		}
+
+		if isAdminMockRequest(pathBytes, methodBytes) {
+			AdminMocksHandler(store)(ctx)
+			return
+		}
+
 		// Default to mock handler
-		MockHandler(store, logger)(ctx)
+		MockHandlerWithFallback(store, logger, fallback)(ctx)
 	}
 }
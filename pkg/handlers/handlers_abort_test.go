@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// fetchRaw serves a single GET through a real fasthttp server and returns the raw bytes
+// received until the server closes the connection.
+func fetchRaw(t *testing.T, handler fasthttp.RequestHandler, path string) []byte {
+	t.Helper()
+
+	ln := fasthttputil.NewInmemoryListener()
+	server := &fasthttp.Server{Handler: handler}
+	go server.Serve(ln)
+	defer ln.Close()
+
+	conn, err := ln.Dial()
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("GET " + path + " HTTP/1.1\r\nHost: mock\r\n\r\n")); err != nil {
+		t.Fatalf("Failed to write request: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	raw, err := io.ReadAll(conn)
+	if err != nil {
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		t.Fatal("Connection was not closed by the server")
+	}
+	return raw
+}
+
+func TestSSEAbortAfterEvents(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-sse-abort.yml"); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+
+	raw := fetchRaw(t, MockHandler(store, nil), "/sse-abort-events")
+
+	if !bytes.Contains(raw, []byte("Transfer-Encoding: chunked")) {
+		t.Fatalf("Expected chunked response, got:\n%s", raw)
+	}
+	if !bytes.Contains(raw, []byte(`"second"`)) {
+		t.Errorf("Expected the first two events to be delivered, got:\n%s", raw)
+	}
+	if bytes.Contains(raw, []byte(`"third"`)) {
+		t.Errorf("Did not expect the complete third event, got:\n%s", raw)
+	}
+	if bytes.HasSuffix(raw, []byte("0\r\n\r\n")) {
+		t.Error("Aborted stream must not end with the terminating chunk")
+	}
+
+	// broken_chunk sends half of the third frame after its size line
+	resp := store.MatchScenarioResponse([]byte("/sse-abort-events"), []byte("GET"), nil)
+	frame := resp.SSEEvents[2].Frame
+	if !bytes.HasSuffix(raw, frame[:len(frame)/2]) {
+		t.Errorf("Expected stream to end with a truncated frame, got:\n%s", raw)
+	}
+}
+
+func TestSSEAbortAfterSeconds(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-sse-abort.yml"); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+	store.SetTimingConfig(true, 0.0)
+
+	start := time.Now()
+	raw := fetchRaw(t, MockHandler(store, nil), "/sse-abort-seconds")
+	elapsed := time.Since(start)
+
+	// Events arrive every 200ms, so a 0.5s cut delivers exactly two of them
+	if !bytes.Contains(raw, []byte(`"second"`)) || bytes.Contains(raw, []byte(`"third"`)) {
+		t.Errorf("Expected exactly two events before the cut, got:\n%s", raw)
+	}
+	if elapsed < 450*time.Millisecond || elapsed > 900*time.Millisecond {
+		t.Errorf("Expected the connection to drop after ~0.5s, took %v", elapsed)
+	}
+}
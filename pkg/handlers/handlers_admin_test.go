@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+func adminRequest(t *testing.T, router fasthttp.RequestHandler, method, path, body string) (int, adminMockResult) {
+	t.Helper()
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI(path)
+	ctx.Request.Header.SetMethod(method)
+	ctx.Request.SetBodyString(body)
+	router(ctx)
+
+	var result adminMockResult
+	if status := ctx.Response.StatusCode(); status == fasthttp.StatusOK || status == fasthttp.StatusCreated {
+		if err := json.Unmarshal(ctx.Response.Body(), &result); err != nil {
+			t.Fatalf("Failed to parse admin answer %s: %v", ctx.Response.Body(), err)
+		}
+	}
+	return ctx.Response.StatusCode(), result
+}
+
+func getBody(router fasthttp.RequestHandler, path string) (int, string) {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI(path)
+	router(ctx)
+	return ctx.Response.StatusCode(), string(ctx.Response.Body())
+}
+
+func stubWithVersion(version int) string {
+	return fmt.Sprintf(`{"request":{"method":"GET","url":"http://localhost/api/stub"},`+
+		`"response":{"status_code":200,"headers":{"Content-Type":"application/json"},"body":{"version":%d}}}`, version)
+}
+
+func TestAdminMocks(t *testing.T) {
+	store, err := storage.NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	router := Router(store, "")
+
+	if status, _ := getBody(router, "/api/stub"); status != fasthttp.StatusNotFound {
+		t.Fatalf("Expected 404 before the stub is added, got %d", status)
+	}
+
+	status, added := adminRequest(t, router, "POST", "/__mock__/mocks", stubWithVersion(1))
+	if status != fasthttp.StatusCreated || added.ID == "" || added.MockID != "default" || added.Path != "/api/stub" {
+		t.Fatalf("Unexpected answer to POST: %d %+v", status, added)
+	}
+	if status, body := getBody(router, "/api/stub"); status != fasthttp.StatusOK || body != `{"version":1}` {
+		t.Fatalf("Expected the stub to be served, got %d %s", status, body)
+	}
+
+	status, updated := adminRequest(t, router, "PUT", "/__mock__/mocks/"+added.ID, stubWithVersion(2))
+	if status != fasthttp.StatusOK || updated.ID != added.ID {
+		t.Fatalf("Unexpected answer to PUT: %d %+v", status, updated)
+	}
+	if _, body := getBody(router, "/api/stub"); body != `{"version":2}` {
+		t.Fatalf("Expected the updated stub, got %s", body)
+	}
+
+	if status, _ := adminRequest(t, router, "DELETE", "/__mock__/mocks/"+added.ID, ""); status != fasthttp.StatusNoContent {
+		t.Fatalf("Expected 204 for DELETE, got %d", status)
+	}
+	if status, _ := getBody(router, "/api/stub"); status != fasthttp.StatusNotFound {
+		t.Fatalf("Expected 404 after the stub is deleted, got %d", status)
+	}
+
+	if status, _ := adminRequest(t, router, "DELETE", "/__mock__/mocks/"+added.ID, ""); status != fasthttp.StatusNotFound {
+		t.Fatalf("Expected 404 for an unknown id, got %d", status)
+	}
+	if status, _ := adminRequest(t, router, "PUT", "/__mock__/mocks/missing", stubWithVersion(1)); status != fasthttp.StatusNotFound {
+		t.Fatalf("Expected 404 for an unknown id, got %d", status)
+	}
+	if status, _ := adminRequest(t, router, "POST", "/__mock__/mocks", `{"response":{}}`); status != fasthttp.StatusBadRequest {
+		t.Fatalf("Expected 400 for an invalid recording, got %d", status)
+	}
+}
+
+func TestAdminMocksTakePrecedence(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	router := Router(store, "")
+
+	stub := `{"request":{"method":"POST","url":"http://localhost/api/v1/status"},` +
+		`"response":{"status_code":503,"headers":{"Content-Type":"application/json"},"body":{"stub":true}}}`
+	if status, _ := adminRequest(t, router, "POST", "/__mock__/mocks", stub); status != fasthttp.StatusCreated {
+		t.Fatalf("Expected 201, got %d", status)
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/v1/status")
+	ctx.Request.Header.SetMethod("POST")
+	router(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusServiceUnavailable {
+		t.Fatalf("Expected the stub to shadow the recording, got %d %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+}
+
+func TestAdminMocksPersist(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	store.SetPersistAdmin(true)
+	router := Router(store, "")
+
+	_, added := adminRequest(t, router, "POST", "/__mock__/mocks", stubWithVersion(1))
+	path := filepath.Join(dir, "default", added.ID+".json")
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("Expected the stub to be persisted: %v", err)
+	}
+
+	// A restarted server serves the persisted stub and can still delete it
+	restarted, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to reload storage: %v", err)
+	}
+	restarted.SetPersistAdmin(true)
+	router = Router(restarted, "")
+	if _, body := getBody(router, "/api/stub"); body != `{"version":1}` {
+		t.Fatalf("Expected the persisted stub, got %s", body)
+	}
+	if status, _ := adminRequest(t, router, "DELETE", "/__mock__/mocks/"+added.ID, ""); status != fasthttp.StatusNoContent {
+		t.Fatalf("Expected 204 for DELETE, got %d", status)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected the persisted file to be removed, got %v", err)
+	}
+}
+
+func TestAdminMocksRejectedWithScenarios(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig("../../tests/fixtures/mock-example.yml"); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+	if status, _ := adminRequest(t, Router(store, ""), "POST", "/__mock__/mocks", stubWithVersion(1)); status != fasthttp.StatusConflict {
+		t.Fatalf("Expected 409 with a scenario config, got %d", status)
+	}
+}
+
+func TestAdminMocksBatch(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	store.SetPersistAdmin(true)
+	router := Router(store, "")
+
+	_, kept := adminRequest(t, router, "POST", "/__mock__/mocks", stubWithVersion(1))
+	_, deleted := adminRequest(t, router, "POST", "/__mock__/mocks",
+		`{"request":{"method":"GET","url":"http://localhost/api/gone"},"response":{"status_code":200,"body":{}}}`)
+
+	batch := func(body string) (int, []byte) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/__mock__/mocks/batch")
+		ctx.Request.Header.SetMethod("POST")
+		ctx.Request.SetBodyString(body)
+		router(ctx)
+		return ctx.Response.StatusCode(), ctx.Response.Body()
+	}
+
+	// A failing operation leaves everything as it was
+	status, body := batch(`[
+		{"op": "update", "id": "` + kept.ID + `", "mock": ` + stubWithVersion(2) + `},
+		{"op": "delete", "id": "admin-unknown"}
+	]`)
+	if status != fasthttp.StatusNotFound || !strings.Contains(string(body), `"operation":1`) {
+		t.Fatalf("Expected 404 naming operation 1, got %d %s", status, body)
+	}
+	if _, body := getBody(router, "/api/stub"); body != `{"version":1}` {
+		t.Fatalf("Expected the rejected batch to change nothing, got %s", body)
+	}
+
+	status, body = batch(`[
+		{"mock": {"request":{"method":"GET","url":"http://localhost/api/first"},"response":{"status_code":201,"body":{"n":1}}}},
+		{"op": "add", "mock": {"request":{"method":"GET","url":"http://localhost/api/second","headers":{"x-mock-id":"tenant"}},"response":{"status_code":200,"body":{"n":2}}}},
+		{"op": "update", "id": "` + kept.ID + `", "mock": ` + stubWithVersion(2) + `},
+		{"op": "delete", "id": "` + deleted.ID + `"}
+	]`)
+	if status != fasthttp.StatusOK {
+		t.Fatalf("Expected 200, got %d %s", status, body)
+	}
+	var answer struct {
+		Operations []adminBatchResult `json:"operations"`
+		Total      int                `json:"total"`
+	}
+	if err := json.Unmarshal(body, &answer); err != nil {
+		t.Fatalf("Failed to parse answer %s: %v", body, err)
+	}
+	if answer.Total != 4 || answer.Operations[0].Op != "add" || answer.Operations[0].Mock == nil ||
+		answer.Operations[1].Mock.MockID != "tenant" || answer.Operations[2].ID != kept.ID || answer.Operations[3].Mock != nil {
+		t.Fatalf("Unexpected answer %s", body)
+	}
+
+	if status, body := getBody(router, "/api/first"); status != fasthttp.StatusCreated || body != `{"n":1}` {
+		t.Errorf("Expected the added mock, got %d %s", status, body)
+	}
+	if _, body := getBody(router, "/api/stub"); body != `{"version":2}` {
+		t.Errorf("Expected the updated mock, got %s", body)
+	}
+	if status, _ := getBody(router, "/api/gone"); status != fasthttp.StatusNotFound {
+		t.Errorf("Expected the deleted mock to be gone, got %d", status)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tenant", answer.Operations[1].ID+".json")); err != nil {
+		t.Errorf("Expected the added mock to be persisted: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "default", deleted.ID+".json")); !os.IsNotExist(err) {
+		t.Errorf("Expected the deleted mock's file to be removed, got %v", err)
+	}
+
+	if status, _ := batch(`{"op": "add"}`); status != fasthttp.StatusBadRequest {
+		t.Errorf("Expected 400 for a body that is not an array, got %d", status)
+	}
+	if status, body := batch(`[{"op": "upsert"}]`); status != fasthttp.StatusBadRequest || !strings.Contains(string(body), "unknown op") {
+		t.Errorf("Expected 400 for an unknown op, got %d %s", status, body)
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+func TestChaosMode(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	store.SetChaos(storage.ChaosConfig{ErrorRate: 1, Timeout: 10 * time.Millisecond})
+	handler := MockHandler(store, nil)
+
+	statuses := make(map[int]int)
+	for i := 0; i < 60; i++ {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/users/17")
+		ctx.Request.Header.Set("x-mock-id", "default")
+		handler(ctx)
+		if ctx.Hijacked() {
+			statuses[0]++ // Timeout
+			continue
+		}
+		statuses[ctx.Response.StatusCode()]++
+	}
+	if len(statuses) != 3 || statuses[500] == 0 || statuses[503] == 0 || statuses[0] == 0 {
+		t.Errorf("Expected a mix of 500, 503 and timeouts, got %v", statuses)
+	}
+
+	// A timeout holds the request, then drops the connection without an answer
+	ln := fasthttputil.NewInmemoryListener()
+	go (&fasthttp.Server{Handler: handler}).Serve(ln)
+	defer ln.Close()
+	timedOut := false
+	for i := 0; i < 50 && !timedOut; i++ {
+		conn, err := ln.Dial()
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		start := time.Now()
+		conn.Write([]byte("GET /users/17 HTTP/1.1\r\nHost: mock\r\nx-mock-id: default\r\nConnection: close\r\n\r\n"))
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		raw, err := io.ReadAll(conn)
+		conn.Close()
+		if err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		if len(raw) == 0 {
+			timedOut = true
+			if held := time.Since(start); held < 10*time.Millisecond {
+				t.Errorf("Expected the connection to be held for the chaos timeout, closed after %v", held)
+			}
+		}
+	}
+	if !timedOut {
+		t.Error("Expected a chaos timeout to drop a connection")
+	}
+
+	// Unmatched requests are left alone
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/nope")
+	handler(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusNotFound {
+		t.Errorf("Expected 404 for an unmatched request, got %d", ctx.Response.StatusCode())
+	}
+}
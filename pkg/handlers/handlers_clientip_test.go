@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+func TestScenarioClientIPAndGeo(t *testing.T) {
+	dir := t.TempDir()
+	record := `{"request": {"method": "GET", "url": "http://api/prices"},
+		"response": {"status_code": 200, "headers": {"Content-Type": "application/json"}, "body": {"currency": "EUR"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "prices.json"), []byte(record), 0644); err != nil {
+		t.Fatalf("Failed to write response: %v", err)
+	}
+	configPath := filepath.Join(dir, "scenarios.yml")
+	config := `scenarios:
+  - name: prices-eu
+    path: /prices
+    client_ip: [10.1.0.0/16, "2001:db8::/32"]
+    response:
+      file: prices.json
+      geo:
+        country: DE
+        city: Berlin
+  - name: prices-office
+    path: /prices
+    client_ip: 192.168.1.20
+    response:
+      file: prices.json
+      geo:
+        country: FR
+      headers:
+        X-Geo-Country: MC
+  - name: prices-default
+    path: /prices
+    response:
+      file: prices.json
+`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	store, err := storage.NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	store.SetDebugHeaders(true)
+	if err := store.LoadScenarioConfig(configPath); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+	router := Router(store, "")
+
+	call := func(remote, forwarded string) *fasthttp.Response {
+		var req fasthttp.Request
+		req.SetRequestURI("/prices")
+		if forwarded != "" {
+			req.Header.Set("X-Forwarded-For", forwarded)
+		}
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Init(&req, &net.TCPAddr{IP: net.ParseIP(remote), Port: 40000}, nil)
+		router(ctx)
+		return &ctx.Response
+	}
+
+	tests := []struct {
+		remote, forwarded string
+		scenario          string
+		country, city     string
+	}{
+		{"10.1.4.2", "", "prices-eu", "DE", "Berlin"},
+		{"127.0.0.1", "10.1.9.9, 127.0.0.1", "prices-eu", "DE", "Berlin"},
+		{"127.0.0.1", "2001:db8::7", "prices-eu", "DE", "Berlin"},
+		{"192.168.1.20", "", "prices-office", "MC", ""},
+		{"192.168.1.21", "", "prices-default", "", ""},
+		{"10.1.4.2", "not-an-ip", "prices-eu", "DE", "Berlin"},
+	}
+	for _, tt := range tests {
+		resp := call(tt.remote, tt.forwarded)
+		if got := string(resp.Header.Peek("x-mock-scenario")); got != tt.scenario {
+			t.Errorf("%s (forwarded %q): expected %s, got %q", tt.remote, tt.forwarded, tt.scenario, got)
+		}
+		if got := string(resp.Header.Peek("X-Geo-Country")); got != tt.country {
+			t.Errorf("%s: expected X-Geo-Country %q, got %q", tt.scenario, tt.country, got)
+		}
+		if got := string(resp.Header.Peek("X-Geo-City")); got != tt.city {
+			t.Errorf("%s: expected X-Geo-City %q, got %q", tt.scenario, tt.city, got)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+type closestBody struct {
+	Error   string                 `json:"error"`
+	Closest []storage.ClosestMatch `json:"closest"`
+}
+
+func TestClosestMatchDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	records := map[string]string{
+		"default/users.json": `{"request": {"request_id": "users", "method": "GET", "url": "http://api/api/users"},
+			"response": {"status_code": 200, "headers": {"Content-Type": "application/json"}, "body": []}}`,
+		"default/orders.json": `{"request": {"request_id": "orders", "method": "POST", "url": "http://api/api/orders"},
+			"response": {"status_code": 201, "headers": {"Content-Type": "application/json"}, "body": {}}}`,
+		"default/report.json": `{"request": {"request_id": "report", "method": "GET", "url": "http://api/api/report"},
+			"response": {"status_code": 200, "headers": {"Content-Type": "text/csv"}, "body": "a,b"}}`,
+		"beta/users.json": `{"request": {"request_id": "beta-users", "method": "GET", "url": "http://api/api/users"},
+			"response": {"status_code": 200, "headers": {"Content-Type": "application/json"}, "body": []}}`,
+	}
+	for name, record := range records {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("Failed to create mock dir: %v", err)
+		}
+		if err := os.WriteFile(file, []byte(record), 0644); err != nil {
+			t.Fatalf("Failed to write mock: %v", err)
+		}
+	}
+
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	router := Router(store, "")
+
+	call := func(method, path string, headers ...string) (*fasthttp.RequestCtx, closestBody) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(method)
+		ctx.Request.SetRequestURI(path)
+		for i := 0; i < len(headers); i += 2 {
+			ctx.Request.Header.Set(headers[i], headers[i+1])
+		}
+		router(ctx)
+		var body closestBody
+		json.Unmarshal(ctx.Response.Body(), &body)
+		return ctx, body
+	}
+
+	// Plain 404 unless asked for
+	if ctx, _ := call("DELETE", "/api/users"); string(ctx.Response.Body()) != `{"error":"No mock found"}` {
+		t.Fatalf("Expected the default 404 body, got %s", ctx.Response.Body())
+	}
+
+	ctx, body := call("DELETE", "/api/users", "x-mock-debug", "1")
+	if ctx.Response.StatusCode() != fasthttp.StatusNotFound || body.Error != "No mock found" {
+		t.Fatalf("Expected a 404 with diagnostics, got %d %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	if len(body.Closest) != 3 {
+		t.Fatalf("Expected 3 candidates, got %s", ctx.Response.Body())
+	}
+	want := []struct {
+		requestID string
+		failed    []string
+	}{
+		{"users", []string{"method"}},
+		{"beta-users", []string{"method", "mock_id"}},
+		{"orders", []string{"path", "method"}},
+	}
+	for i, w := range want {
+		got := body.Closest[i]
+		if got.RequestID != w.requestID || !reflect.DeepEqual(got.Failed, w.failed) {
+			t.Errorf("Candidate %d: expected %s failing %v, got %s failing %v", i, w.requestID, w.failed, got.RequestID, got.Failed)
+		}
+	}
+	if body.Closest[0].File != filepath.Join(dir, "default", "users.json") {
+		t.Errorf("Expected the recording file, got %q", body.Closest[0].File)
+	}
+
+	// The Accept lookup is the content type dimension
+	_, body = call("GET", "/api/report", "x-mock-debug", "1", "Accept", "application/json")
+	if len(body.Closest) == 0 || body.Closest[0].RequestID != "report" ||
+		!reflect.DeepEqual(body.Closest[0].Failed, []string{"content_type"}) {
+		t.Errorf("Expected report failing on content_type first, got %+v", body.Closest)
+	}
+
+	// -debug explains every 404
+	store.SetDebug(true)
+	if _, body := call("GET", "/api/user"); len(body.Closest) == 0 || body.Closest[0].RequestID != "users" {
+		t.Errorf("Expected diagnostics with -debug, got %+v", body.Closest)
+	}
+}
+
+func TestClosestMatchDiagnosticsScenarios(t *testing.T) {
+	dir := t.TempDir()
+	record := `{"request": {"request_id": "r1", "method": "POST", "url": "http://api/orders"},
+		"response": {"status_code": 201, "headers": {"Content-Type": "application/json"}, "body": {}}}`
+	if err := os.WriteFile(filepath.Join(dir, "order.json"), []byte(record), 0644); err != nil {
+		t.Fatalf("Failed to write response: %v", err)
+	}
+	configPath := filepath.Join(dir, "scenarios.yml")
+	config := `scenarios:
+  - name: vip-order
+    method: POST
+    path: /orders
+    filter:
+      body:
+        eq:
+          field: tier
+          value: vip
+    response:
+      file: order.json
+  - name: refund
+    method: POST
+    path: /refunds
+    response:
+      file: order.json
+`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	store, err := storage.NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig(configPath); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+	router := Router(store, "")
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetRequestURI("/orders")
+	ctx.Request.Header.Set("x-mock-debug", "1")
+	ctx.Request.SetBodyString(`{"tier":"basic"}`)
+	router(ctx)
+
+	var body closestBody
+	if err := json.Unmarshal(ctx.Response.Body(), &body); err != nil {
+		t.Fatalf("Expected JSON diagnostics, got %s", ctx.Response.Body())
+	}
+	if len(body.Closest) != 2 {
+		t.Fatalf("Expected both scenarios, got %s", ctx.Response.Body())
+	}
+	if got := body.Closest[0]; got.Scenario != "vip-order" || !reflect.DeepEqual(got.Failed, []string{"filter.body"}) {
+		t.Errorf("Expected vip-order failing on filter.body, got %+v", got)
+	}
+	if got := body.Closest[1]; got.Scenario != "refund" || !reflect.DeepEqual(got.Failed, []string{"path"}) {
+		t.Errorf("Expected refund failing on path, got %+v", got)
+	}
+}
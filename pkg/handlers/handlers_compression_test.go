@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+func TestCompressionParity(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "default"), 0755); err != nil {
+		t.Fatalf("Failed to create mock dir: %v", err)
+	}
+	plain := []byte(`{"items":[1,2,3]}`)
+	recordings := map[string][]byte{
+		"gzip":    fasthttp.AppendGzipBytes(nil, plain),
+		"deflate": fasthttp.AppendDeflateBytes(nil, plain),
+		"br":      fasthttp.AppendBrotliBytes(nil, plain),
+	}
+	for encoding, compressed := range recordings {
+		record := fmt.Sprintf(`{"request": {"method": "GET", "url": "http://api/%s"},
+			"response": {"status_code": 200, "headers": {"Content-Type": "application/json", "Content-Encoding": %q}, "body": %q}}`,
+			encoding, encoding, base64.StdEncoding.EncodeToString(compressed))
+		if err := os.WriteFile(filepath.Join(dir, "default", encoding+".json"), []byte(record), 0644); err != nil {
+			t.Fatalf("Failed to write mock: %v", err)
+		}
+	}
+
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	handler := MockHandler(store, nil)
+	call := func(path, acceptEncoding string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(path)
+		ctx.Request.Header.SetMethod("GET")
+		if acceptEncoding != "" {
+			ctx.Request.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		handler(ctx)
+		return ctx
+	}
+
+	// Without parity the decoded body is served
+	if ctx := call("/gzip", "gzip"); string(ctx.Response.Body()) != string(plain) || len(ctx.Response.Header.Peek("Content-Encoding")) != 0 {
+		t.Fatalf("Expected a decoded body, got %q (encoding %q)", ctx.Response.Body(), ctx.Response.Header.Peek("Content-Encoding"))
+	}
+
+	store.SetCompressionParity(true)
+	for encoding := range recordings {
+		ctx := call("/"+encoding, "gzip, deflate, br")
+		if got := string(ctx.Response.Header.Peek("Content-Encoding")); got != encoding {
+			t.Fatalf("Expected Content-Encoding %s, got %q", encoding, got)
+		}
+		decoded, err := ctx.Response.BodyUncompressed()
+		if err != nil || string(decoded) != string(plain) {
+			t.Fatalf("%s: expected the recorded body after decoding, got %q (%v)", encoding, decoded, err)
+		}
+	}
+
+	// Clients that do not accept the encoding still get the plain body
+	if ctx := call("/br", "gzip"); string(ctx.Response.Body()) != string(plain) || len(ctx.Response.Header.Peek("Content-Encoding")) != 0 {
+		t.Fatalf("Expected a plain body for a client without br, got %q", ctx.Response.Body())
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "default"), 0755); err != nil {
+		t.Fatalf("Failed to create mock dir: %v", err)
+	}
+	records := map[string]string{
+		"full.json": `{"request": {"request_id": "r1", "method": "GET", "url": "http://api/items"},
+			"response": {"status_code": 200, "headers": {"Content-Type": "application/json", "ETag": "\"v1\"", "Cache-Control": "max-age=10"}, "body": {"items": []}}}`,
+		"revalidated.json": `{"request": {"request_id": "r2", "method": "GET", "url": "http://api/items"},
+			"response": {"status_code": 304, "headers": {"ETag": "\"v1\"", "Cache-Control": "max-age=60"}, "body": "", "revalidates": "r1"}}`,
+		"dated.json": `{"request": {"method": "GET", "url": "http://api/report"},
+			"response": {"status_code": 200, "headers": {"Content-Type": "text/plain", "Last-Modified": "Tue, 01 Oct 2024 10:00:00 GMT"}, "body": "report"}}`,
+	}
+	for name, record := range records {
+		if err := os.WriteFile(filepath.Join(dir, "default", name), []byte(record), 0644); err != nil {
+			t.Fatalf("Failed to write mock: %v", err)
+		}
+	}
+
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	router := Router(store, "")
+
+	call := func(path, header, value string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(path)
+		ctx.Request.Header.Set("Accept", "*/*")
+		if header != "" {
+			ctx.Request.Header.Set(header, value)
+		}
+		router(ctx)
+		return ctx
+	}
+
+	// The recorded 304 is not served on its own
+	for i := 0; i < 3; i++ {
+		if ctx := call("/items", "", ""); ctx.Response.StatusCode() != fasthttp.StatusOK || string(ctx.Response.Body()) != `{"items":[]}` {
+			t.Fatalf("Expected the full response, got %d %s", ctx.Response.StatusCode(), ctx.Response.Body())
+		}
+	}
+
+	for _, etag := range []string{`"v1"`, `W/"v1"`, `"v0", "v1"`, `*`} {
+		ctx := call("/items", "If-None-Match", etag)
+		if ctx.Response.StatusCode() != fasthttp.StatusNotModified || len(ctx.Response.Body()) != 0 {
+			t.Fatalf("If-None-Match %s: expected 304 without a body, got %d %s", etag, ctx.Response.StatusCode(), ctx.Response.Body())
+		}
+		if cacheControl := string(ctx.Response.Header.Peek("Cache-Control")); cacheControl != "max-age=60" {
+			t.Fatalf("Expected the headers of the recorded 304, got Cache-Control %q", cacheControl)
+		}
+	}
+	if ctx := call("/items", "If-None-Match", `"v2"`); ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("Expected a stale ETag to get the full response, got %d", ctx.Response.StatusCode())
+	}
+
+	// Without a recorded 304 the validators of the full response are repeated
+	ctx := call("/report", "If-Modified-Since", "Wed, 02 Oct 2024 10:00:00 GMT")
+	if ctx.Response.StatusCode() != fasthttp.StatusNotModified {
+		t.Fatalf("Expected 304 for an unchanged report, got %d", ctx.Response.StatusCode())
+	}
+	if lastModified := string(ctx.Response.Header.Peek("Last-Modified")); lastModified != "Tue, 01 Oct 2024 10:00:00 GMT" {
+		t.Fatalf("Expected Last-Modified to be repeated, got %q", lastModified)
+	}
+	if ctx := call("/report", "If-Modified-Since", "Mon, 30 Sep 2024 10:00:00 GMT"); ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("Expected a modified report to be sent, got %d", ctx.Response.StatusCode())
+	}
+}
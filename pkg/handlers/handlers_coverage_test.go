@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+func TestCoverage(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "default"), 0755); err != nil {
+		t.Fatalf("Failed to create mock dir: %v", err)
+	}
+	for id, path := range map[string]string{"r1": "/users", "r2": "/orders"} {
+		record := `{"request": {"request_id": "` + id + `", "method": "GET", "url": "http://api` + path + `"},
+			"response": {"status_code": 200, "headers": {"Content-Type": "application/json"}, "body": []}}`
+		if err := os.WriteFile(filepath.Join(dir, "default", id+".json"), []byte(record), 0644); err != nil {
+			t.Fatalf("Failed to write mock: %v", err)
+		}
+	}
+
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	router := Router(store, "")
+
+	coverage := func() storage.CoverageReport {
+		t.Helper()
+		status, body := getBody(router, "/__mock__/coverage")
+		if status != fasthttp.StatusOK {
+			t.Fatalf("Expected 200 from coverage, got %d", status)
+		}
+		var report storage.CoverageReport
+		if err := json.Unmarshal([]byte(body), &report); err != nil {
+			t.Fatalf("Failed to parse coverage %s: %v", body, err)
+		}
+		return report
+	}
+
+	if report := coverage(); report.Total != 2 || report.Served != 0 || len(report.Unused) != 2 {
+		t.Fatalf("Expected both mocks unused before any request, got %+v", report)
+	}
+
+	getBody(router, "/users")
+	getBody(router, "/users")
+	getBody(router, "/missing")
+	report := coverage()
+	if report.Served != 1 || report.Ratio != 0.5 {
+		t.Fatalf("Expected one of two mocks served, got %+v", report)
+	}
+	if len(report.Unused) != 1 || report.Unused[0].RequestID != "r2" || report.Unused[0].Path != "/orders" {
+		t.Fatalf("Expected /orders to be reported unused, got %+v", report.Unused)
+	}
+	if report.Unused[0].File != filepath.Join(dir, "default", "r2.json") {
+		t.Fatalf("Expected the unused mock's file, got %q", report.Unused[0].File)
+	}
+}
+
+func TestCoverageScenarioSequence(t *testing.T) {
+	dir := t.TempDir()
+	for name, status := range map[string]string{"pending.json": "202", "done.json": "200"} {
+		record := `{"request": {"method": "GET", "url": "http://api/jobs/1"},
+			"response": {"status_code": ` + status + `, "headers": {"Content-Type": "application/json"}, "body": {}}}`
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(record), 0644); err != nil {
+			t.Fatalf("Failed to write response: %v", err)
+		}
+	}
+	configPath := filepath.Join(dir, "scenarios.yml")
+	config := `scenarios:
+  - name: job-polling
+    path: /jobs/1
+    responses:
+      - file: pending.json
+      - file: done.json
+`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	store, err := storage.NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig(configPath); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+	router := Router(store, "")
+
+	// A flow that stops after the first poll leaves the last step unused
+	getBody(router, "/jobs/1")
+	report := store.Coverage()
+	if report.Total != 2 || report.Served != 1 {
+		t.Fatalf("Expected one of two sequence steps served, got %+v", report)
+	}
+	if unused := report.Unused; len(unused) != 1 || unused[0].Scenario != "job-polling" || unused[0].StatusCode != 200 {
+		t.Fatalf("Expected the final step to be unused, got %+v", unused)
+	}
+
+	getBody(router, "/jobs/1")
+	if report := store.Coverage(); len(report.Unused) != 0 || report.Ratio != 1 {
+		t.Fatalf("Expected full coverage, got %+v", report)
+	}
+}
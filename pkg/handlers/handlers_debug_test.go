@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+func TestDebugHeaders(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "default"), 0755); err != nil {
+		t.Fatalf("Failed to create mock dir: %v", err)
+	}
+	record := `{"request": {"request_id": "r1", "method": "GET", "url": "http://api/users"},
+		"response": {"status_code": 200, "headers": {"Content-Type": "application/json"}, "body": []}}`
+	recordFile := filepath.Join(dir, "default", "users.json")
+	if err := os.WriteFile(recordFile, []byte(record), 0644); err != nil {
+		t.Fatalf("Failed to write mock: %v", err)
+	}
+
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	router := Router(store, "")
+
+	call := func(path string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(path)
+		router(ctx)
+		return ctx
+	}
+
+	if ctx := call("/users"); ctx.Response.Header.Peek("x-mock-matched-id") != nil {
+		t.Fatal("Expected no debug headers unless enabled")
+	}
+
+	store.SetDebugHeaders(true)
+	ctx := call("/users")
+	if id := string(ctx.Response.Header.Peek("x-mock-matched-id")); id != "r1" {
+		t.Fatalf("Expected x-mock-matched-id r1, got %q", id)
+	}
+	if file := string(ctx.Response.Header.Peek("x-mock-matched-file")); file != recordFile {
+		t.Fatalf("Expected x-mock-matched-file %s, got %q", recordFile, file)
+	}
+	if file := string(ctx.Response.Header.Peek("x-mock-file")); file != recordFile {
+		t.Fatalf("Expected the x-mock-file alias %s, got %q", recordFile, file)
+	}
+	if scenario := ctx.Response.Header.Peek("x-mock-scenario"); scenario != nil {
+		t.Fatalf("Expected no x-mock-scenario without scenarios, got %q", scenario)
+	}
+	if ctx := call("/missing"); ctx.Response.Header.Peek("x-mock-matched-id") != nil {
+		t.Fatal("Expected no debug headers on requests without a mock")
+	}
+}
+
+func TestDebugHeadersScenarios(t *testing.T) {
+	dir := t.TempDir()
+	record := `{"request": {"request_id": "r2", "method": "GET", "url": "http://api/orders"},
+		"response": {"status_code": 200, "headers": {"Content-Type": "application/json"}, "body": []}}`
+	if err := os.WriteFile(filepath.Join(dir, "orders.json"), []byte(record), 0644); err != nil {
+		t.Fatalf("Failed to write response: %v", err)
+	}
+	configPath := filepath.Join(dir, "scenarios.yml")
+	config := `scenarios:
+  - name: orders-empty
+    path: /orders
+    response:
+      file: orders.json
+`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	store, err := storage.NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig(configPath); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+	store.SetDebugHeaders(true)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/orders")
+	Router(store, "")(ctx)
+
+	if scenario := string(ctx.Response.Header.Peek("x-mock-scenario")); scenario != "orders-empty" {
+		t.Fatalf("Expected x-mock-scenario orders-empty, got %q", scenario)
+	}
+	if id := string(ctx.Response.Header.Peek("x-mock-matched-id")); id != "r2" {
+		t.Fatalf("Expected x-mock-matched-id r2, got %q", id)
+	}
+	if file := string(ctx.Response.Header.Peek("x-mock-matched-file")); file != filepath.Join(dir, "orders.json") {
+		t.Fatalf("Expected x-mock-matched-file to name the scenario's response file, got %q", file)
+	}
+}
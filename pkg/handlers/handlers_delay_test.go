@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
 	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
 )
 
 func TestNonSSEDelayWithoutReplayTiming(t *testing.T) {
@@ -212,3 +218,136 @@ func TestNonSSEDelayScenarioOverride(t *testing.T) {
 
 	t.Logf("Response time with scenario delay override: %v (expected ~200ms)", elapsed)
 }
+
+func TestDelayOnlyScenarioFallsThrough(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	// Delay-only scenario with no response of its own
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-delay-only.yml"); err != nil {
+		t.Fatalf("Failed to load scenario config: %v", err)
+	}
+
+	// Global jitter is overridden to zero by the scenario
+	store.SetTimingConfig(true, 0.5)
+
+	handler := MockHandler(store, nil)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/users/17")
+	ctx.Request.Header.SetMethod("GET")
+	ctx.Request.Header.Set("Accept", "application/json")
+	ctx.Request.Header.Set("x-mock-id", "default")
+
+	start := time.Now()
+	handler(ctx)
+	elapsed := time.Since(start)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("Expected recorded mock to be served, got %d", ctx.Response.StatusCode())
+	}
+
+	if elapsed < 190*time.Millisecond || elapsed > 220*time.Millisecond {
+		t.Errorf("Expected ~200ms delay from the delay-only scenario, got %v", elapsed)
+	}
+
+	// Recorded paths without a delay-only scenario keep the scenario-only behavior
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/users/1")
+	ctx.Request.Header.SetMethod("GET")
+	handler(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusNotFound {
+		t.Fatalf("Expected 404 for a path without scenarios, got %d", ctx.Response.StatusCode())
+	}
+}
+
+func TestDelayCountsFromRequestReceipt(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	store.SetTimingConfig(true, 0.0)
+
+	// Work done before the mock is served (60ms) is part of the recorded 100ms
+	router := Router(store, "")
+	ln := fasthttputil.NewInmemoryListener()
+	server := &fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) {
+		time.Sleep(60 * time.Millisecond)
+		router(ctx)
+	}}
+	go server.Serve(ln)
+	defer ln.Close()
+	client := &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI("http://mock/users/17")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("x-mock-id", "default")
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	start := time.Now()
+	if err := client.Do(req, resp); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode())
+	}
+	if elapsed < 90*time.Millisecond || elapsed > 140*time.Millisecond {
+		t.Errorf("Expected ~100ms in total, got %v", elapsed)
+	}
+}
+
+func TestDelayCountsSlowRequestBody(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "default"), 0755); err != nil {
+		t.Fatalf("Failed to create mock dir: %v", err)
+	}
+	record := `{"request": {"method": "POST", "url": "http://api/uploads"},
+		"response": {"status_code": 201, "headers": {"Content-Type": "application/json"}, "body": {}, "delay": 0.1}}`
+	if err := os.WriteFile(filepath.Join(dir, "default", "upload.json"), []byte(record), 0644); err != nil {
+		t.Fatalf("Failed to write mock: %v", err)
+	}
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	store.SetTimingConfig(true, 0.0)
+
+	ln := fasthttputil.NewInmemoryListener()
+	server := &fasthttp.Server{Handler: Router(store, "")}
+	go server.Serve(ReceiptListener(ln))
+	defer ln.Close()
+
+	conn, err := ln.Dial()
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Uploading the body takes 60ms of the recorded 100ms
+	body := `{"file":"report.pdf"}`
+	start := time.Now()
+	fmt.Fprintf(conn, "POST /uploads HTTP/1.1\r\nHost: mock\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n", len(body))
+	time.Sleep(60 * time.Millisecond)
+	conn.Write([]byte(body))
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	if err := resp.Read(bufio.NewReader(conn)); err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if resp.StatusCode() != fasthttp.StatusCreated {
+		t.Fatalf("Expected 201, got %d", resp.StatusCode())
+	}
+	if elapsed < 90*time.Millisecond || elapsed > 140*time.Millisecond {
+		t.Errorf("Expected ~100ms in total, got %v", elapsed)
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// readUntil reads from r until marker shows up or the deadline passes.
+func readUntil(t *testing.T, conn net.Conn, r *bufio.Reader, marker string) string {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var seen strings.Builder
+	for !strings.Contains(seen.String(), marker) {
+		line, err := r.ReadString('\n')
+		seen.WriteString(line)
+		if err != nil {
+			t.Fatalf("Did not receive %q (err: %v), got:\n%s", marker, err, seen.String())
+		}
+	}
+	return seen.String()
+}
+
+func TestSSEEmitIntoKeptOpenStream(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-sse-emit.yml"); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+
+	ln := fasthttputil.NewInmemoryListener()
+	server := &fasthttp.Server{Handler: Router(store, "")}
+	go server.Serve(ln)
+	defer ln.Close()
+
+	conn, err := ln.Dial()
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET /sse-live HTTP/1.1\r\nHost: mock\r\n\r\n"))
+	reader := bufio.NewReader(conn)
+
+	// Without timing replay the recorded events arrive at once, then the stream stays open
+	readUntil(t, conn, reader, `"fifth"`)
+
+	client := &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI("http://mock/__mock__/sse/live-feed/emit?event=update&id=7")
+	req.Header.SetMethod("POST")
+	req.SetBodyString("line one\nline two")
+	if err := client.Do(req, resp); err != nil {
+		t.Fatalf("Emit request failed: %v", err)
+	}
+	if !bytes.Equal(resp.Body(), []byte(`{"stream":"live-feed","delivered":1}`)) {
+		t.Fatalf("Unexpected emit response: %s", resp.Body())
+	}
+
+	received := readUntil(t, conn, reader, "data: line two")
+	if !strings.Contains(received, "event: update\n") {
+		t.Errorf("Expected injected event type, got:\n%s", received)
+	}
+	if !strings.Contains(received, "id: 7") || !strings.Contains(received, "data: line one") {
+		t.Errorf("Expected injected id and data lines, got:\n%s", received)
+	}
+
+	// Closing the hub ends held-open streams with a proper terminating chunk
+	store.SSEHub().Close()
+	readUntil(t, conn, reader, "0\r\n\r\n")
+}
+
+func TestSSEEmitWithoutStreams(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/__mock__/sse/nobody/emit")
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetBodyString(`{"n":1}`)
+
+	Router(store, "")(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("Expected 200, got %d", ctx.Response.StatusCode())
+	}
+	if !bytes.Equal(ctx.Response.Body(), []byte(`{"stream":"nobody","delivered":0}`)) {
+		t.Fatalf("Unexpected emit response: %s", ctx.Response.Body())
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+func evaluateRequestBody(t *testing.T, router fasthttp.RequestHandler, payload string) (int, evaluateResult) {
+	t.Helper()
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/__mock__/evaluate")
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetBodyString(payload)
+	router(ctx)
+
+	var result evaluateResult
+	if ctx.Response.StatusCode() == fasthttp.StatusOK {
+		if err := json.Unmarshal(ctx.Response.Body(), &result); err != nil {
+			t.Fatalf("Failed to parse evaluation %s: %v", ctx.Response.Body(), err)
+		}
+	}
+	return ctx.Response.StatusCode(), result
+}
+
+func TestEvaluateScenarios(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig("../../tests/fixtures/mock-example.yml"); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+	router := Router(store, "")
+
+	_, result := evaluateRequestBody(t, router,
+		`{"method":"POST","path":"/api/v1/status?x=1","body":{"processing":{"state":"pending"}}}`)
+	if !result.Matched || result.Source != "scenario" || result.Scenario != "Status Fallback Default" {
+		t.Fatalf("Unexpected evaluation: %+v", result)
+	}
+	if len(result.Scenarios) != 2 {
+		t.Fatalf("Expected both scenarios to be reported, got %+v", result.Scenarios)
+	}
+	first := result.Scenarios[0]
+	if first.Matched || first.Reason != "filter.body" || first.Filter == nil || first.Filter.Match {
+		t.Fatalf("Expected the first scenario to fail its body filter, got %+v", first)
+	}
+	if result.Response.StatusCode != fasthttp.StatusOK || result.Response.RequestID != "bench-test-059b6fbd" {
+		t.Fatalf("Unexpected response: %+v", result.Response)
+	}
+
+	// A later scenario is not reached once an earlier one answers
+	_, result = evaluateRequestBody(t, router,
+		`{"method":"POST","path":"/api/v1/status","body":"{\"processing\":{\"state\":\"done\"},\"payload\":{\"id\":\"ABC-1234\"}}"}`)
+	if result.Scenario != "Status Ready With Valid ID" || !result.Scenarios[1].Skipped {
+		t.Fatalf("Unexpected evaluation: %+v", result)
+	}
+
+	_, result = evaluateRequestBody(t, router, `{"method":"GET","path":"/api/v1/status"}`)
+	if result.Matched || result.Source != "none" || result.Scenarios[0].Reason != "method" {
+		t.Fatalf("Expected no match by method, got %+v", result)
+	}
+
+	if status, _ := evaluateRequestBody(t, router, `{"method":"GET"}`); status != fasthttp.StatusBadRequest {
+		t.Fatalf("Expected 400 without a path, got %d", status)
+	}
+}
+
+func TestEvaluateDoesNotAdvanceSequences(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-sequence.yml"); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+	store.SetStrict(true)
+	router := Router(store, "")
+
+	for i := 0; i < 3; i++ {
+		_, result := evaluateRequestBody(t, router, `{"method":"GET","path":"/api/once"}`)
+		if !result.Matched || result.Response.StatusCode != fasthttp.StatusOK {
+			t.Fatalf("Evaluation %d: unexpected result %+v", i, result)
+		}
+	}
+	evaluateRequestBody(t, router, `{"method":"GET","path":"/missing"}`)
+	if store.Unmatched().Total() != 0 {
+		t.Fatal("Expected evaluations not to be counted as unmatched requests")
+	}
+
+	// The one-shot sequence still serves its response once
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/once")
+	ctx.Request.Header.SetMethod("GET")
+	router(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("Expected the sequence to be untouched, got %d", ctx.Response.StatusCode())
+	}
+
+	_, result := evaluateRequestBody(t, router, `{"method":"GET","path":"/api/once"}`)
+	if result.Response.StatusCode != fasthttp.StatusGone {
+		t.Fatalf("Expected the exhausted sequence to report 410, got %+v", result.Response)
+	}
+}
+
+func TestEvaluateMockIDLookup(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	router := Router(store, "")
+
+	_, result := evaluateRequestBody(t, router,
+		`{"method":"GET","path":"/data/2","headers":{"x-mock-id":"api-v1","accept":"application/json"}}`)
+	if !result.Matched || result.Source != "mock_id" || result.Response.RequestID != "bench-test-3121ee87" {
+		t.Fatalf("Unexpected evaluation: %+v", result)
+	}
+
+	_, result = evaluateRequestBody(t, router, `{"method":"GET","path":"/data/2","headers":{"x-mock-id":"nope"}}`)
+	if result.Matched {
+		t.Fatalf("Expected no match for an unknown mock ID, got %+v", result)
+	}
+}
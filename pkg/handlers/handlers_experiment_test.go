@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+func TestExperimentSplitIsSticky(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-experiment.yml"); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+
+	handler := MockHandler(store, nil)
+	serve := func(setup func(req *fasthttp.Request)) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/api/data")
+		ctx.Request.Header.SetMethod("GET")
+		setup(&ctx.Request)
+		handler(ctx)
+		return ctx
+	}
+
+	// New clients are split roughly 70/30 and handed the assignment cookie
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		ctx := serve(func(*fasthttp.Request) {})
+		variant := string(ctx.Response.Header.Peek("X-Mock-Variant"))
+		counts[variant]++
+
+		cookie := string(ctx.Response.Header.PeekCookie("data_variant"))
+		if !strings.Contains(cookie, "data_variant="+variant) {
+			t.Fatalf("Expected sticky cookie for variant %q, got %q", variant, cookie)
+		}
+		if variant == storage.VariantB && !strings.Contains(string(ctx.Response.Body()), `"version":2`) {
+			t.Fatalf("Expected variant B body, got %s", ctx.Response.Body())
+		}
+	}
+	if counts[storage.VariantB] < 200 || counts[storage.VariantB] > 400 {
+		t.Fatalf("Expected about 30%% of clients on variant B, got %v", counts)
+	}
+
+	// The cookie pins the variant and is not reissued
+	ctx := serve(func(req *fasthttp.Request) { req.Header.SetCookie("data_variant", "b") })
+	if variant := string(ctx.Response.Header.Peek("X-Mock-Variant")); variant != storage.VariantB {
+		t.Fatalf("Expected cookie to pin variant b, got %q", variant)
+	}
+	if cookie := ctx.Response.Header.PeekCookie("data_variant"); len(cookie) != 0 {
+		t.Fatalf("Expected no new cookie for an assigned client, got %q", cookie)
+	}
+
+	// The client id header always maps to the same variant
+	for i := 0; i < 20; i++ {
+		clientID := fmt.Sprintf("client-%d", i)
+		first := serve(func(req *fasthttp.Request) { req.Header.Set("x-client-id", clientID) })
+		second := serve(func(req *fasthttp.Request) { req.Header.Set("x-client-id", clientID) })
+		a := string(first.Response.Header.Peek("X-Mock-Variant"))
+		b := string(second.Response.Header.Peek("X-Mock-Variant"))
+		if a != b {
+			t.Fatalf("Expected %s to stay on one variant, got %q then %q", clientID, a, b)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+func transferRequest(router fasthttp.RequestHandler, path, body string) (int, []byte) {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI(path)
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetBodyString(body)
+	router(ctx)
+	return ctx.Response.StatusCode(), ctx.Response.Body()
+}
+
+func TestAdminMocksExportImport(t *testing.T) {
+	store, err := storage.NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	router := Router(store, "")
+
+	_, added := adminRequest(t, router, "POST", "/__mock__/mocks", stubWithVersion(1))
+	status, _ := adminRequest(t, router, "PUT", "/__mock__/mocks/"+added.ID, stubWithVersion(2))
+	if status != fasthttp.StatusOK {
+		t.Fatalf("Failed to update the stub: %d", status)
+	}
+
+	fixtures := filepath.Join(t.TempDir(), "fixtures")
+	request, _ := json.Marshal(map[string]string{"dir": fixtures})
+	status, body := transferRequest(router, "/__mock__/export", string(request))
+	if status != fasthttp.StatusOK {
+		t.Fatalf("Expected 200 from export, got %d %s", status, body)
+	}
+	var exported struct {
+		Files []string `json:"files"`
+		Total int      `json:"total"`
+	}
+	if err := json.Unmarshal(body, &exported); err != nil {
+		t.Fatalf("Failed to parse export answer %s: %v", body, err)
+	}
+	wantFile := filepath.Join(fixtures, "default", added.ID+".json")
+	if exported.Total != 1 || len(exported.Files) != 1 || exported.Files[0] != wantFile {
+		t.Fatalf("Expected %s to be exported, got %s", wantFile, body)
+	}
+
+	// The exported dir is a regular mock dir
+	loaded, err := storage.NewMockStorage(fixtures)
+	if err != nil {
+		t.Fatalf("Failed to load the exported mocks: %v", err)
+	}
+	if status, body := getBody(Router(loaded, ""), "/api/stub"); status != fasthttp.StatusOK || body != `{"version":2}` {
+		t.Fatalf("Expected the updated stub from the export, got %d %s", status, body)
+	}
+
+	// Importing into a fresh server registers the mocks with their IDs
+	fresh, err := storage.NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	freshRouter := Router(fresh, "")
+	status, body = transferRequest(freshRouter, "/__mock__/import", string(request))
+	if status != fasthttp.StatusCreated {
+		t.Fatalf("Expected 201 from import, got %d %s", status, body)
+	}
+	var imported struct {
+		Mocks []adminMockResult `json:"mocks"`
+		Total int               `json:"total"`
+	}
+	if err := json.Unmarshal(body, &imported); err != nil {
+		t.Fatalf("Failed to parse import answer %s: %v", body, err)
+	}
+	if imported.Total != 1 || imported.Mocks[0].ID != added.ID {
+		t.Fatalf("Expected %s to be imported, got %s", added.ID, body)
+	}
+	if status, body := getBody(freshRouter, "/api/stub"); status != fasthttp.StatusOK || body != `{"version":2}` {
+		t.Fatalf("Expected the imported stub to be served, got %d %s", status, body)
+	}
+	// It is an admin mock again
+	if status, _ := adminRequest(t, freshRouter, "DELETE", "/__mock__/mocks/"+added.ID, ""); status != fasthttp.StatusNoContent {
+		t.Fatalf("Expected the imported mock to be deletable, got %d", status)
+	}
+
+	// Importing twice replaces instead of duplicating
+	if status, body := transferRequest(router, "/__mock__/import", string(request)); status != fasthttp.StatusCreated {
+		t.Fatalf("Expected 201 from import, got %d %s", status, body)
+	}
+	if _, body := getBody(router, "/__mock__/list"); !strings.Contains(body, `"total":1`) {
+		t.Fatalf("Expected the import to replace the admin mock, got %s", body)
+	}
+	if status, _ := transferRequest(router, "/__mock__/export", ""); status != fasthttp.StatusOK {
+		t.Fatalf("Expected the export to the mock dir to succeed, got %d", status)
+	}
+
+	if status, _ := transferRequest(router, "/__mock__/import", `{}`); status != fasthttp.StatusBadRequest {
+		t.Fatalf("Expected 400 without a dir, got %d", status)
+	}
+}
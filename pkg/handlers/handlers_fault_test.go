@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+func loadFaultScenarios(t *testing.T) *storage.MockStorage {
+	t.Helper()
+	recording, err := filepath.Abs("../../test_mocks/default/application_json_20251122_233842_059b6fbd.json")
+	if err != nil {
+		t.Fatalf("Failed to resolve recording: %v", err)
+	}
+	config := "scenarios:\n"
+	for _, fault := range []string{"empty_response", "connection_reset", "malformed_json", "random_garbage", "{truncate_body: 10}"} {
+		name := strings.Trim(strings.Fields(fault)[0], "{:")
+		config += "  - name: " + name + "\n    path: /" + name + "\n    response:\n      file: " + recording + "\n      fault: " + fault + "\n"
+	}
+	configPath := filepath.Join(t.TempDir(), "scenarios.yml")
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	store, err := storage.NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig(configPath); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+	return store
+}
+
+func TestResponseFaults(t *testing.T) {
+	store := loadFaultScenarios(t)
+	handler := MockHandler(store, nil)
+
+	if raw := fetchRaw(t, handler, "/empty_response"); len(raw) != 0 {
+		t.Errorf("Expected nothing before the close, got:\n%s", raw)
+	}
+
+	raw := fetchRaw(t, handler, "/random_garbage")
+	if len(raw) != garbageSize || bytes.HasPrefix(raw, []byte("HTTP/")) {
+		t.Errorf("Expected %d random bytes instead of a response, got %d:\n%q", garbageSize, len(raw), raw)
+	}
+
+	// The recorded body is {"id":17,"name":"User 17"}
+	raw = fetchRaw(t, handler, "/truncate_body")
+	if !bytes.Contains(raw, []byte("Content-Length: 26\r\n")) {
+		t.Errorf("Expected the full length to be announced, got:\n%s", raw)
+	}
+	if !bytes.HasSuffix(raw, []byte("\r\n\r\n"+`{"id":17,"`)) {
+		t.Errorf("Expected the first 10 body bytes before the close, got:\n%s", raw)
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/malformed_json")
+	handler(ctx)
+	if ctx.Response.StatusCode() != 200 || json.Valid(ctx.Response.Body()) {
+		t.Errorf("Expected a 200 with broken JSON, got %d %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+
+	// Body faults leave HEAD responses alone
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("HEAD")
+	ctx.Request.SetRequestURI("/truncate_body")
+	handler(ctx)
+	if ctx.Hijacked() || ctx.Response.StatusCode() != 200 {
+		t.Errorf("Expected a regular HEAD response, got %d (hijacked: %v)", ctx.Response.StatusCode(), ctx.Hijacked())
+	}
+}
+
+func TestConnectionResetFault(t *testing.T) {
+	store := loadFaultScenarios(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+	go (&fasthttp.Server{Handler: MockHandler(store, nil)}).Serve(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("GET /connection_reset HTTP/1.1\r\nHost: mock\r\n\r\n")); err != nil {
+		t.Fatalf("Failed to write request: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	raw, err := io.ReadAll(conn)
+	if !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("Expected the connection to be reset, got %v after %q", err, raw)
+	}
+}
+
+func TestResponseFaultValidation(t *testing.T) {
+	recording, err := filepath.Abs("../../test_mocks/default/application_json_20251122_233842_059b6fbd.json")
+	if err != nil {
+		t.Fatalf("Failed to resolve recording: %v", err)
+	}
+	store, err := storage.NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	for _, fault := range []string{"timeout", "truncate_body", "{truncate_body: -1}"} {
+		config := "scenarios:\n  - name: bad\n    path: /bad\n    response:\n      file: " + recording + "\n      fault: " + fault + "\n"
+		configPath := filepath.Join(t.TempDir(), "scenarios.yml")
+		if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		if err := store.LoadScenarioConfig(configPath); err == nil || !strings.Contains(err.Error(), "fault") {
+			t.Errorf("fault %s: expected a load error, got %v", fault, err)
+		}
+	}
+}
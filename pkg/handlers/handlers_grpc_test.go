@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+)
+
+// grpcRecord builds a grpc record answering method with the given messages.
+func grpcRecord(method string, status string, messages ...[]byte) string {
+	items := ""
+	for i, msg := range messages {
+		if i > 0 {
+			items += ","
+		}
+		items += fmt.Sprintf(`{"timestamp": %d, "data": %q}`, i, base64.StdEncoding.EncodeToString(msg))
+	}
+	return fmt.Sprintf(`{"type": "grpc",
+		"request": {"url": "http://api/%s"},
+		"response": {"status_code": 200, "headers": {"content-type": "application/grpc", "x-region": "eu"},
+			"body": [%s], "trailers": {"grpc-status": %q, "grpc-message": "recorded"}}}`, method, items, status)
+}
+
+func TestGRPCReplay(t *testing.T) {
+	dir := t.TempDir()
+	records := map[string]string{
+		"acme.json":  grpcRecord("prices.PriceService/Watch", "0", []byte{0x08, 0x2a}, []byte{0x08, 0x2b}),
+		"other.json": grpcRecord("prices.PriceService/Watch", "5"),
+	}
+	for name, record := range records {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(record), 0644); err != nil {
+			t.Fatalf("Failed to write mock: %v", err)
+		}
+	}
+	config := `scenarios:
+  - name: acme
+    path: /prices.PriceService/Watch
+    filter:
+      body:
+        eq:
+          field: "1"
+          value: ACME
+    response:
+      file: acme.json
+  - name: other
+    path: /prices.PriceService/Watch
+    response:
+      file: other.json
+`
+	configPath := filepath.Join(dir, "grpc.yml")
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	store, err := storage.NewMockStorage(filepath.Join(dir, "none"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig(configPath); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+	store.SetDebugHeaders(true)
+
+	server := httptest.NewUnstartedServer(GRPCHandler(store))
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+
+	transport := &http.Transport{Protocols: new(http.Protocols)}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: transport}
+
+	call := func(method string, request []byte) ([][]byte, http.Header, http.Header) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/"+method, bytes.NewReader(storage.AppendGRPCMessage(nil, request)))
+		req.Header.Set("Content-Type", "application/grpc")
+		req.Header.Set("TE", "trailers")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.ProtoMajor != 2 {
+			t.Fatalf("Expected HTTP/2, got %s", resp.Proto)
+		}
+		var messages [][]byte
+		for {
+			_, msg, err := storage.ReadGRPCMessage(resp.Body)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Failed to read message: %v", err)
+			}
+			messages = append(messages, msg)
+		}
+		return messages, resp.Header, resp.Trailer
+	}
+
+	// Field 1 = "ACME" selects the streaming scenario
+	messages, header, trailer := call("prices.PriceService/Watch", []byte("\x0a\x04ACME"))
+	if len(messages) != 2 || !bytes.Equal(messages[0], []byte{0x08, 0x2a}) || !bytes.Equal(messages[1], []byte{0x08, 0x2b}) {
+		t.Fatalf("Expected the two recorded messages, got %x", messages)
+	}
+	if header.Get("X-Region") != "eu" || trailer.Get("Grpc-Status") != "0" || trailer.Get("Grpc-Message") != "recorded" {
+		t.Fatalf("Unexpected metadata %v / trailers %v", header, trailer)
+	}
+	if header.Get("X-Mock-Scenario") != "acme" || header.Get("X-Mock-Matched-File") != filepath.Join(dir, "acme.json") {
+		t.Fatalf("Expected debug headers naming the acme scenario, got %v", header)
+	}
+
+	// Any other request falls through to the recorded error status
+	messages, _, trailer = call("prices.PriceService/Watch", []byte("\x0a\x03XYZ"))
+	if len(messages) != 0 || trailer.Get("Grpc-Status") != "5" {
+		t.Fatalf("Expected NOT_FOUND without messages, got %d message(s), trailers %v", len(messages), trailer)
+	}
+
+	// Unknown methods are UNIMPLEMENTED
+	_, header, _ = call("prices.PriceService/Missing", nil)
+	if header.Get("Grpc-Status") != "12" {
+		t.Fatalf("Expected UNIMPLEMENTED, got headers %v", header)
+	}
+}
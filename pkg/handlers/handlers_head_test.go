@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+func TestContentLengthAndHead(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "default"), 0755); err != nil {
+		t.Fatalf("Failed to create mock dir: %v", err)
+	}
+	records := map[string]string{
+		// Hand-edited body: the recorded length no longer matches
+		"get.json": `{"request": {"method": "GET", "url": "http://api/items"},
+			"response": {"status_code": 200, "headers": {"Content-Type": "application/json", "Content-Length": "999"}, "body": {"ok": true}}}`,
+		"head.json": `{"request": {"method": "HEAD", "url": "http://api/files"},
+			"response": {"status_code": 200, "headers": {"Content-Type": "application/json", "Content-Length": "1234"}, "body": ""}}`,
+	}
+	for name, record := range records {
+		if err := os.WriteFile(filepath.Join(dir, "default", name), []byte(record), 0644); err != nil {
+			t.Fatalf("Failed to write mock: %v", err)
+		}
+	}
+
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	ln := fasthttputil.NewInmemoryListener()
+	server := &fasthttp.Server{Handler: Router(store, "")}
+	go server.Serve(ln)
+	defer ln.Close()
+	client := &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+
+	call := func(method, path string) *fasthttp.Response {
+		req := fasthttp.AcquireRequest()
+		defer fasthttp.ReleaseRequest(req)
+		req.SetRequestURI("http://mock" + path)
+		req.Header.SetMethod(method)
+		resp := &fasthttp.Response{}
+		if method == fasthttp.MethodHead {
+			resp.SkipBody = true
+		}
+		if err := client.Do(req, resp); err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		return resp
+	}
+
+	get := call(fasthttp.MethodGet, "/items")
+	if get.Header.ContentLength() != len(`{"ok":true}`) || string(get.Body()) != `{"ok":true}` {
+		t.Fatalf("Expected the served body length, got %d for %q", get.Header.ContentLength(), get.Body())
+	}
+
+	// HEAD falls back to the GET recording and reports the length GET would send
+	head := call(fasthttp.MethodHead, "/items")
+	if head.StatusCode() != fasthttp.StatusOK || head.Header.ContentLength() != get.Header.ContentLength() {
+		t.Fatalf("Expected HEAD to mirror GET, got %d with length %d", head.StatusCode(), head.Header.ContentLength())
+	}
+	if len(head.Body()) != 0 {
+		t.Fatalf("Expected no body for HEAD, got %q", head.Body())
+	}
+
+	// Recorded HEAD responses keep the length of the resource they describe
+	if files := call(fasthttp.MethodHead, "/files"); files.Header.ContentLength() != 1234 {
+		t.Fatalf("Expected recorded HEAD length 1234, got %d", files.Header.ContentLength())
+	}
+
+	mismatches := store.CheckContentLength()
+	if len(mismatches) != 1 || mismatches[0].Path != "/items" || mismatches[0].Recorded != 999 || mismatches[0].Served != len(`{"ok":true}`) {
+		t.Fatalf("Expected /items to be flagged, got %+v", mismatches)
+	}
+}
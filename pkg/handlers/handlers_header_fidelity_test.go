@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+const fidelityRecord = `{
+	"request": {"method": "GET", "url": "http://api.example.com/fidelity", "headers": {"x-mock-id": "fidelity"}},
+	"response": {
+		"status_code": 200,
+		"headers": {
+			"Content-Type": "application/json",
+			"Date": "Tue, 15 Nov 1994 08:12:31 GMT",
+			"Server": "nginx/1.25",
+			"Transfer-Encoding": "chunked"
+		},
+		"body": {"ok": true}
+	}
+}`
+
+// fetchFidelity serves GET /fidelity through server and returns the raw response.
+func fetchFidelity(t *testing.T, fidelity storage.HeaderFidelity, server *fasthttp.Server) []byte {
+	t.Helper()
+
+	store, err := storage.NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.AddRecord([]byte(fidelityRecord), "fidelity"); err != nil {
+		t.Fatalf("Failed to add record: %v", err)
+	}
+	store.SetHeaderFidelity(fidelity)
+
+	ln := fasthttputil.NewInmemoryListener()
+	server.Handler = Router(store, "")
+	if fidelity.Date == storage.HeaderRecorded {
+		server.Handler = LiveDate(server.Handler)
+	}
+	go server.Serve(ln)
+	defer ln.Close()
+
+	conn, err := ln.Dial()
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	request := "GET /fidelity HTTP/1.1\r\nHost: mock\r\nx-mock-id: fidelity\r\nConnection: close\r\n\r\n"
+	if _, err := conn.Write([]byte(request)); err != nil {
+		t.Fatalf("Failed to write request: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	raw, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	return raw
+}
+
+func TestHeaderFidelityDefault(t *testing.T) {
+	raw := fetchFidelity(t, storage.DefaultHeaderFidelity, &fasthttp.Server{Name: "AutoMockServer"})
+
+	if bytes.Contains(raw, []byte("1994")) {
+		t.Errorf("Expected a live Date by default, got:\n%s", raw)
+	}
+	if !bytes.Contains(raw, []byte("Server: nginx/1.25\r\n")) {
+		t.Errorf("Expected the recorded Server by default, got:\n%s", raw)
+	}
+	if !bytes.Contains(raw, []byte("Content-Length: 11\r\n")) || bytes.Contains(raw, []byte("Transfer-Encoding")) {
+		t.Errorf("Expected Content-Length framing by default, got:\n%s", raw)
+	}
+}
+
+func TestHeaderFidelityRecorded(t *testing.T) {
+	fidelity := storage.HeaderFidelity{Date: storage.HeaderRecorded, Server: storage.HeaderRecorded, Framing: storage.HeaderRecorded}
+	raw := fetchFidelity(t, fidelity, &fasthttp.Server{Name: "AutoMockServer", NoDefaultDate: true})
+
+	if bytes.Count(raw, []byte("Date: ")) != 1 || !bytes.Contains(raw, []byte("Date: Tue, 15 Nov 1994 08:12:31 GMT\r\n")) {
+		t.Errorf("Expected only the recorded Date, got:\n%s", raw)
+	}
+	if !bytes.Contains(raw, []byte("Transfer-Encoding: chunked\r\n")) || bytes.Contains(raw, []byte("Content-Length")) {
+		t.Errorf("Expected chunked framing as recorded, got:\n%s", raw)
+	}
+	if !bytes.Contains(raw, []byte(`{"ok":true}`)) {
+		t.Errorf("Expected the body, got:\n%s", raw)
+	}
+}
+
+func TestHeaderFidelityLiveAndNone(t *testing.T) {
+	fidelity := storage.HeaderFidelity{Date: storage.HeaderNone, Server: storage.HeaderLive, Framing: storage.HeaderLive}
+	raw := fetchFidelity(t, fidelity, &fasthttp.Server{Name: "AutoMockServer", NoDefaultDate: true})
+
+	if bytes.Contains(raw, []byte("Date: ")) {
+		t.Errorf("Expected no Date, got:\n%s", raw)
+	}
+	if !bytes.Contains(raw, []byte("Server: AutoMockServer\r\n")) {
+		t.Errorf("Expected the live server name, got:\n%s", raw)
+	}
+
+	fidelity = storage.HeaderFidelity{Date: storage.HeaderLive, Server: storage.HeaderNone, Framing: storage.HeaderChunked}
+	raw = fetchFidelity(t, fidelity, &fasthttp.Server{NoDefaultServerHeader: true})
+
+	if bytes.Contains(raw, []byte("Server: ")) {
+		t.Errorf("Expected no Server, got:\n%s", raw)
+	}
+	if !bytes.Contains(raw, []byte("Transfer-Encoding: chunked\r\n")) {
+		t.Errorf("Expected chunked framing, got:\n%s", raw)
+	}
+}
+
+func TestLiveDateWithoutMock(t *testing.T) {
+	fidelity := storage.HeaderFidelity{Date: storage.HeaderRecorded, Server: storage.HeaderRecorded, Framing: storage.HeaderLive}
+	server := &fasthttp.Server{NoDefaultDate: true}
+	raw := fetchFidelity(t, fidelity, server)
+	if !bytes.Contains(raw, []byte("Date: Tue, 15 Nov 1994")) {
+		t.Fatalf("Expected the recorded Date, got:\n%s", raw)
+	}
+
+	// Admin endpoints get the live date from LiveDate
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/__mock__/stats")
+	store, _ := storage.NewMockStorage(t.TempDir())
+	LiveDate(Router(store, ""))(ctx)
+	if date := ctx.Response.Header.Peek("Date"); len(date) == 0 || bytes.Contains(date, []byte("1994")) {
+		t.Errorf("Expected a live Date, got %q", date)
+	}
+}
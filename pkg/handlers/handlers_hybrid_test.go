@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/proxy"
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+func TestHybridRecordsThenReplays(t *testing.T) {
+	upstreamCalls := 0
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	upstream := &fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) {
+		upstreamCalls++
+		ctx.SetContentType("application/json")
+		ctx.SetBodyString(`{"source":"backend"}`)
+	}}
+	go upstream.Serve(ln)
+	defer upstream.Shutdown()
+
+	dir := t.TempDir()
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	recorder, err := proxy.NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	recorder.SetRecordHook(func(path, mockID string) {
+		if err := store.AddResponseFile(path, mockID); err != nil {
+			t.Errorf("Failed to load recording: %v", err)
+		}
+	})
+	fallback := proxy.NewProxyHandler(recorder, "http://"+ln.Addr().String()).Handle
+	handler := MockHandlerWithFallback(store, nil, fallback)
+
+	for i := 0; i < 2; i++ {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/api/hybrid")
+		ctx.Request.Header.SetMethod("GET")
+		handler(ctx)
+
+		if ctx.Response.StatusCode() != fasthttp.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d", i+1, ctx.Response.StatusCode())
+		}
+		if got := string(ctx.Response.Body()); got != `{"source":"backend"}` {
+			t.Fatalf("Request %d: unexpected body %q", i+1, got)
+		}
+	}
+
+	if upstreamCalls != 1 {
+		t.Fatalf("Expected the backend to be called once, got %d calls", upstreamCalls)
+	}
+	if store.FindResponse("/api/hybrid", "default", "application/json", "GET") == nil {
+		t.Fatal("Expected the recording to be indexed")
+	}
+}
@@ -256,3 +256,60 @@ func TestSSEStreamWriter(t *testing.T) {
 		})
 	}
 }
+
+// timedWriter records when each flushed chunk arrives.
+type timedWriter struct {
+	times []time.Time
+}
+
+func (w *timedWriter) Write(p []byte) (int, error) {
+	w.times = append(w.times, time.Now())
+	return len(p), nil
+}
+
+func TestSSEPerGapJitter(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-jitter-original.yml"); err != nil {
+		t.Fatalf("Failed to load scenario config: %v", err)
+	}
+
+	resp := store.MatchScenarioResponse([]byte("/sse-stream"), []byte("GET"), []byte(""))
+	if resp == nil {
+		t.Fatal("Expected to find SSE response")
+	}
+
+	writer := &sseStreamWriter{
+		events:      resp.SSEEvents,
+		jitterScale: 1.0,
+		gapJitter:   0.5,
+	}
+
+	out := &timedWriter{}
+	start := time.Now()
+	writer.StreamTo(bufio.NewWriter(out))
+
+	if len(out.times) != len(resp.SSEEvents) {
+		t.Fatalf("Expected %d flushed events, got %d", len(resp.SSEEvents), len(out.times))
+	}
+
+	// Original gaps are a uniform 200ms; with ±50% per-gap jitter they must not all stay uniform
+	previous := start
+	irregular := false
+	for i, at := range out.times {
+		gap := at.Sub(previous)
+		previous = at
+		if gap < 90*time.Millisecond || gap > 320*time.Millisecond {
+			t.Errorf("Gap %d out of ±50%% bounds: %v", i+1, gap)
+		}
+		if gap < 185*time.Millisecond || gap > 215*time.Millisecond {
+			irregular = true
+		}
+	}
+	if !irregular {
+		t.Error("Expected per-gap jitter to produce irregular gaps")
+	}
+}
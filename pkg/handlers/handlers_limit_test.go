@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+// serveConcurrently fires n identical GET requests at the handler at once and
+// returns the resulting status codes.
+func serveConcurrently(handler fasthttp.RequestHandler, path string, n int) []int {
+	codes := make([]int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := &fasthttp.RequestCtx{}
+			ctx.Request.SetRequestURI(path)
+			ctx.Request.Header.SetMethod("GET")
+			handler(ctx)
+			codes[i] = ctx.Response.StatusCode()
+		}(i)
+	}
+	wg.Wait()
+	return codes
+}
+
+func TestMaxConcurrentReject(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-max-concurrent.yml"); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+	store.SetTimingConfig(true, 0.0)
+
+	codes := serveConcurrently(MockHandler(store, nil), "/api/limited", 3)
+
+	ok, rejected := 0, 0
+	for _, code := range codes {
+		switch code {
+		case fasthttp.StatusOK:
+			ok++
+		case fasthttp.StatusServiceUnavailable:
+			rejected++
+		}
+	}
+	if ok != 1 || rejected != 2 {
+		t.Fatalf("Expected 1 OK and 2 rejected responses, got %v", codes)
+	}
+}
+
+func TestMaxConcurrentQueue(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-max-concurrent.yml"); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+	store.SetTimingConfig(true, 0.0)
+
+	start := time.Now()
+	codes := serveConcurrently(MockHandler(store, nil), "/api/queued", 2)
+	elapsed := time.Since(start)
+
+	for i, code := range codes {
+		if code != fasthttp.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d", i+1, code)
+		}
+	}
+
+	// Two queued requests with a 200ms delay each must run one after the other
+	if elapsed < 380*time.Millisecond {
+		t.Fatalf("Expected queued requests to be serialized (>= 400ms), got %v", elapsed)
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	recording, err := filepath.Abs("../../test_mocks/default/application_json_20251122_233842_059b6fbd.json")
+	if err != nil {
+		t.Fatalf("Failed to resolve recording: %v", err)
+	}
+	config := `scenarios:
+  - name: broken
+    path: /broken
+    response:
+      file: ` + recording + `
+      fault: malformed_json
+  - name: slow
+    path: /slow
+    response:
+      file: ` + recording + `
+      delay: 0.02
+      jitter: 0.5
+`
+	configPath := filepath.Join(t.TempDir(), "scenarios.yml")
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	store, err := storage.NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig(configPath); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+	store.SetTimingConfig(true, 0)
+	router := Router(store, "")
+
+	get := func(path string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(path)
+		router(ctx)
+		return ctx
+	}
+	get("/broken")
+	get("/broken")
+	get("/slow")
+
+	ctx := get("/__mock__/metrics")
+	if contentType := string(ctx.Response.Header.ContentType()); contentType != storage.OpenMetricsContentType {
+		t.Errorf("Expected the OpenMetrics content type, got %q", contentType)
+	}
+	body := string(ctx.Response.Body())
+	for _, line := range []string{
+		"# TYPE mock_faults counter\n",
+		`mock_faults_total{mock_id="broken",fault="malformed_json"} 2` + "\n",
+		`mock_fault_last_timestamp_seconds{mock_id="broken",fault="malformed_json"} `,
+		"# TYPE mock_delay_seconds histogram\n",
+		`mock_delay_seconds_bucket{mock_id="slow",le="0.005"} 0` + "\n",
+		`mock_delay_seconds_bucket{mock_id="slow",le="0.05"} 1` + "\n",
+		`mock_delay_seconds_count{mock_id="slow"} 1` + "\n",
+		`mock_delay_jitter_ratio_bucket{mock_id="slow",le="0.5"} 0` + "\n",
+		`mock_delay_jitter_ratio_bucket{mock_id="slow",le="+Inf"} 1` + "\n",
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", line, body)
+		}
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("Expected the exposition to end with # EOF, got:\n%s", body)
+	}
+}
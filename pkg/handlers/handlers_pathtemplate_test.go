@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+func TestPathTemplateRecordings(t *testing.T) {
+	dir := t.TempDir()
+	records := map[string]string{
+		"default/user.json": `{"request": {"request_id": "user", "method": "GET", "url": "http://api/users/{id}"},
+			"response": {"status_code": 200, "headers": {"Content-Type": "application/json"}, "body": {"name": "any"}}}`,
+		"default/me.json": `{"request": {"request_id": "me", "method": "GET", "url": "http://api/users/me"},
+			"response": {"status_code": 200, "headers": {"Content-Type": "application/json"}, "body": {"name": "me"}}}`,
+		"default/files.json": `{"request": {"request_id": "files", "method": "GET", "url": "http://api/files/**"},
+			"response": {"status_code": 200, "headers": {"Content-Type": "text/plain"}, "body": "file"}}`,
+	}
+	for name, record := range records {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("Failed to create mock dir: %v", err)
+		}
+		if err := os.WriteFile(file, []byte(record), 0644); err != nil {
+			t.Fatalf("Failed to write mock: %v", err)
+		}
+	}
+
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	router := Router(store, "")
+
+	for _, c := range []struct {
+		method, path, accept string
+		status               int
+		body                 string
+	}{
+		{"GET", "/users/me", "", 200, `{"name":"me"}`}, // Exact paths win
+		{"GET", "/users/42", "", 200, `{"name":"any"}`},
+		{"HEAD", "/users/42", "", 200, ""},
+		{"DELETE", "/users/42", "", 404, ""},
+		{"GET", "/users/42/orders", "", 404, ""},
+		{"GET", "/files/docs/readme.txt", "*/*", 200, "file"},
+		{"GET", "/files/docs/readme.txt", "text/plain", 200, "file"},
+		{"GET", "/files/docs/readme.txt", "application/json", 404, ""},
+	} {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(c.method)
+		ctx.Request.SetRequestURI(c.path)
+		if c.accept != "" {
+			ctx.Request.Header.Set("Accept", c.accept)
+		}
+		router(ctx)
+		if ctx.Response.StatusCode() != c.status {
+			t.Errorf("%s %s: expected %d, got %d", c.method, c.path, c.status, ctx.Response.StatusCode())
+			continue
+		}
+		if c.status == 200 && c.body != "" && string(ctx.Response.Body()) != c.body {
+			t.Errorf("%s %s: expected %s, got %s", c.method, c.path, c.body, ctx.Response.Body())
+		}
+	}
+}
+
+func TestPathTemplateScenarios(t *testing.T) {
+	dir := t.TempDir()
+	record := `{"request": {"request_id": "r1", "method": "GET", "url": "http://api/orders/1"},
+		"response": {"status_code": 200, "headers": {"Content-Type": "application/json"}, "body": {"id": "{{request.path_params.id}}"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "order.json"), []byte(record), 0644); err != nil {
+		t.Fatalf("Failed to write response: %v", err)
+	}
+	configPath := filepath.Join(dir, "scenarios.yml")
+	config := `scenarios:
+  - name: any-item
+    path: /users/{user}/orders/{id}/items/**
+    response:
+      file: order.json
+  - name: user-order
+    path: /users/{user}/orders/{id}
+    response:
+      file: order.json
+      template: true
+      headers:
+        X-User: "{{request.path_params.user}}"
+  - name: latest-order
+    path: /users/{user}/orders/latest
+    response:
+      file: order.json
+      headers:
+        X-Latest: "true"
+`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	store, err := storage.NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig(configPath); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+	store.SetDebugHeaders(true)
+	router := Router(store, "")
+
+	call := func(path string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod("GET")
+		ctx.Request.SetRequestURI(path)
+		router(ctx)
+		return ctx
+	}
+
+	ctx := call("/users/7/orders/42")
+	if scenario := string(ctx.Response.Header.Peek("x-mock-scenario")); scenario != "user-order" {
+		t.Fatalf("Expected user-order, got %q (%d)", scenario, ctx.Response.StatusCode())
+	}
+	if body := string(ctx.Response.Body()); body != `{"id":"42"}` {
+		t.Errorf("Expected the id param in the body, got %s", body)
+	}
+	if user := string(ctx.Response.Header.Peek("X-User")); user != "7" {
+		t.Errorf("Expected the user param in a header, got %q", user)
+	}
+
+	// The literal segment is more specific than {id}, whatever the declaration order
+	if scenario := string(call("/users/7/orders/latest").Response.Header.Peek("x-mock-scenario")); scenario != "latest-order" {
+		t.Errorf("Expected latest-order, got %q", scenario)
+	}
+	if scenario := string(call("/users/7/orders/42/items/3/price").Response.Header.Peek("x-mock-scenario")); scenario != "any-item" {
+		t.Errorf("Expected any-item, got %q", scenario)
+	}
+	if status := call("/users/7/orders").Response.StatusCode(); status != fasthttp.StatusNotFound {
+		t.Errorf("Expected 404 outside the templates, got %d", status)
+	}
+
+	// Invalid templates are reported when the config loads
+	invalid := filepath.Join(dir, "invalid.yml")
+	if err := os.WriteFile(invalid, []byte("scenarios:\n  - name: bad\n    path: /files/**/raw\n    response:\n      file: order.json\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := store.LoadScenarioConfig(invalid); err == nil {
+		t.Error("Expected ** before the last segment to be rejected")
+	}
+}
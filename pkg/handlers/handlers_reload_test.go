@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+func postAdmin(router fasthttp.RequestHandler, path string) (int, string) {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI(path)
+	ctx.Request.Header.SetMethod("POST")
+	router(ctx)
+	return ctx.Response.StatusCode(), string(ctx.Response.Body())
+}
+
+func TestReloadAndReset(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "default"), 0755); err != nil {
+		t.Fatalf("Failed to create mock dir: %v", err)
+	}
+	writeMock := func(name, path, body string) {
+		record := `{"request": {"method": "GET", "url": "http://api` + path + `"},
+			"response": {"status_code": 200, "headers": {"Content-Type": "application/json"}, "body": ` + body + `}}`
+		if err := os.WriteFile(filepath.Join(dir, "default", name), []byte(record), 0644); err != nil {
+			t.Fatalf("Failed to write mock: %v", err)
+		}
+	}
+	writeMock("users.json", "/users", `{"version":1}`)
+
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	store.SetStrict(true)
+	router := Router(store, "")
+
+	if status, _ := adminRequest(t, router, "POST", "/__mock__/mocks", stubWithVersion(1)); status != fasthttp.StatusCreated {
+		t.Fatalf("Failed to add the admin mock: %d", status)
+	}
+	getBody(router, "/missing")
+
+	// Files changed on disk are only served after a reload
+	writeMock("users.json", "/users", `{"version":2}`)
+	writeMock("orders.json", "/orders", `[]`)
+	if status, _ := getBody(router, "/orders"); status != fasthttp.StatusNotFound {
+		t.Fatalf("Expected /orders to be unknown before the reload, got %d", status)
+	}
+	if status, body := postAdmin(router, "/__mock__/reload"); status != fasthttp.StatusOK || body != string(store.GetStatsJSON()) {
+		t.Fatalf("Expected the reload to answer with the stats, got %d %s", status, body)
+	}
+	if _, body := getBody(router, "/users"); body != `{"version":2}` {
+		t.Fatalf("Expected the edited recording after the reload, got %s", body)
+	}
+	if status, _ := getBody(router, "/orders"); status != fasthttp.StatusOK {
+		t.Fatalf("Expected the new recording after the reload, got %d", status)
+	}
+	if _, body := getBody(router, "/api/stub"); body != `{"version":1}` {
+		t.Fatalf("Expected the admin mock to survive the reload, got %s", body)
+	}
+
+	// Reset drops runtime state but keeps the recordings
+	if status, body := postAdmin(router, "/__mock__/reset"); status != fasthttp.StatusNoContent {
+		t.Fatalf("Expected 204 from reset, got %d %s", status, body)
+	}
+	if total := store.Unmatched().Total(); total != 0 {
+		t.Fatalf("Expected unmatched counts to be cleared, got %d", total)
+	}
+	if status, _ := getBody(router, "/api/stub"); status != fasthttp.StatusNotFound {
+		t.Fatalf("Expected the admin mock to be gone after the reset, got %d", status)
+	}
+	if status, _ := getBody(router, "/users"); status != fasthttp.StatusOK {
+		t.Fatalf("Expected recordings to be served after the reset, got %d", status)
+	}
+
+	// GET is not routed to the endpoints
+	if status, _ := getBody(router, "/__mock__/reset"); status != fasthttp.StatusNotFound {
+		t.Fatalf("Expected GET /__mock__/reset to be treated as a mock request, got %d", status)
+	}
+}
+
+func TestResetKeepsPersistedAdminMocks(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	store.SetPersistAdmin(true)
+	_, persisted := adminRequest(t, Router(store, ""), "POST", "/__mock__/mocks", stubWithVersion(1))
+	path := filepath.Join(dir, "default", persisted.ID+".json")
+
+	// After a restart the stub is part of the loaded state
+	restarted, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to reload storage: %v", err)
+	}
+	restarted.SetPersistAdmin(true)
+	router := Router(restarted, "")
+
+	_, added := adminRequest(t, router, "POST", "/__mock__/mocks",
+		`{"request":{"method":"GET","url":"http://localhost/api/added"},"response":{"status_code":200,"body":{}}}`)
+	if status, _ := adminRequest(t, router, "PUT", "/__mock__/mocks/"+persisted.ID, stubWithVersion(2)); status != fasthttp.StatusOK {
+		t.Fatalf("Failed to update the persisted stub: %d", status)
+	}
+
+	if status, body := postAdmin(router, "/__mock__/reset"); status != fasthttp.StatusNoContent {
+		t.Fatalf("Expected 204 from reset, got %d %s", status, body)
+	}
+	if _, body := getBody(router, "/api/stub"); body != `{"version":1}` {
+		t.Fatalf("Expected the persisted stub as loaded after the reset, got %s", body)
+	}
+	if data, err := os.ReadFile(path); err != nil || !strings.Contains(string(data), `"version": 1`) {
+		t.Fatalf("Expected the persisted file to be restored, got %s %v", data, err)
+	}
+	if status, _ := getBody(router, "/api/added"); status != fasthttp.StatusNotFound {
+		t.Fatalf("Expected the mock added since the start to be gone, got %d", status)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "default", added.ID+".json")); !os.IsNotExist(err) {
+		t.Fatalf("Expected the file of the added mock to be removed, got %v", err)
+	}
+
+	// A deleted persisted stub comes back as well
+	adminRequest(t, router, "DELETE", "/__mock__/mocks/"+persisted.ID, "")
+	postAdmin(router, "/__mock__/reset")
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("Expected the deleted file to be restored: %v", err)
+	}
+	if _, body := getBody(router, "/api/stub"); body != `{"version":1}` {
+		t.Fatalf("Expected the deleted stub to be served after the reset, got %s", body)
+	}
+}
+
+func TestReloadAndResetScenarios(t *testing.T) {
+	dir := t.TempDir()
+	for name, status := range map[string]string{"first.json": "200", "second.json": "202"} {
+		record := `{"request": {"method": "GET", "url": "http://api/jobs"},
+			"response": {"status_code": ` + status + `, "headers": {"Content-Type": "application/json"}, "body": {}}}`
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(record), 0644); err != nil {
+			t.Fatalf("Failed to write response: %v", err)
+		}
+	}
+	configPath := filepath.Join(dir, "scenarios.yml")
+	writeConfig := func(config string) {
+		if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+	}
+	writeConfig(`scenarios:
+  - name: jobs
+    path: /jobs
+    responses:
+      - file: first.json
+      - file: second.json
+`)
+
+	store, err := storage.NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig(configPath); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+	router := Router(store, "")
+
+	status := func() int {
+		code, _ := getBody(router, "/jobs")
+		return code
+	}
+	if first, second := status(), status(); first != 200 || second != 202 {
+		t.Fatalf("Expected the sequence 200, 202, got %d, %d", first, second)
+	}
+	postAdmin(router, "/__mock__/reset")
+	if first := status(); first != 200 {
+		t.Fatalf("Expected the sequence to start over after the reset, got %d", first)
+	}
+
+	// An edited config is picked up; a broken one keeps the previous scenarios
+	writeConfig(`scenarios:
+  - name: jobs
+    path: /jobs
+    response:
+      file: second.json
+`)
+	if code, body := postAdmin(router, "/__mock__/reload"); code != fasthttp.StatusOK {
+		t.Fatalf("Expected the reload to succeed, got %d %s", code, body)
+	}
+	if first := status(); first != 202 {
+		t.Fatalf("Expected the edited scenario after the reload, got %d", first)
+	}
+	writeConfig(`scenarios:
+  - name: jobs
+    path: /jobs
+    response:
+      file: missing.json
+`)
+	if code, _ := postAdmin(router, "/__mock__/reload"); code != fasthttp.StatusInternalServerError {
+		t.Fatalf("Expected a broken config to fail the reload, got %d", code)
+	}
+	if first := status(); first != 202 {
+		t.Fatalf("Expected the previous scenarios to be kept, got %d", first)
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+const respondWithRecord = `{
+	"request": {"method": "GET", "url": "http://api.example.com/users"},
+	"response": {"status_code": 200, "headers": {"Content-Type": "application/json"}, "body": {"users": []}}
+}`
+
+func TestRespondWithHeader(t *testing.T) {
+	store, err := storage.NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.AddRecord([]byte(respondWithRecord), "default"); err != nil {
+		t.Fatalf("Failed to add record: %v", err)
+	}
+	router := Router(store, "")
+
+	call := func(path, override string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod("GET")
+		ctx.Request.SetRequestURI(path)
+		ctx.Request.Header.Set("x-mock-respond-with", override)
+		router(ctx)
+		return ctx
+	}
+
+	// The header is ignored unless enabled
+	ctx := call("/users", `{"status": 503}`)
+	if ctx.Response.StatusCode() != 200 || string(ctx.Response.Body()) != `{"users":[]}` {
+		t.Fatalf("Expected the mock while disabled, got %d %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+
+	store.SetRespondWithHeader(true)
+	for _, c := range []struct {
+		path, override    string
+		status            int
+		contentType, body string
+	}{
+		{"/users", `{"status": 503, "body": {"error": "down"}}`, 503, "application/json", `{"error":"down"}`},
+		{"/no/mock/here", `{"body": "plain text"}`, 200, "text/plain; charset=utf-8", "plain text"},
+		{"/users", `{"status": 204}`, 204, "application/json", ""},
+		{"/users", `{"status": 42}`, 400, "application/json", ""},
+		{"/users", `{"status": 500, "delay": -1}`, 400, "application/json", ""},
+		{"/users", `{"code": 500}`, 400, "application/json", ""},
+		{"/users", `not json`, 400, "application/json", ""},
+	} {
+		ctx := call(c.path, c.override)
+		if ctx.Response.StatusCode() != c.status {
+			t.Errorf("%s: expected %d, got %d %s", c.override, c.status, ctx.Response.StatusCode(), ctx.Response.Body())
+			continue
+		}
+		if contentType := string(ctx.Response.Header.ContentType()); contentType != c.contentType {
+			t.Errorf("%s: expected Content-Type %s, got %s", c.override, c.contentType, contentType)
+		}
+		if c.status != 400 && string(ctx.Response.Body()) != c.body {
+			t.Errorf("%s: expected body %q, got %q", c.override, c.body, ctx.Response.Body())
+		}
+	}
+
+	start := time.Now()
+	if ctx := call("/users", `{"status": 429, "delay": 0.05}`); ctx.Response.StatusCode() != 429 {
+		t.Errorf("Expected 429, got %d", ctx.Response.StatusCode())
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected the delay to be applied, answered after %v", elapsed)
+	}
+
+	// Requests without the header are matched as usual
+	if ctx := call("/users", ""); ctx.Response.StatusCode() != 200 {
+		t.Errorf("Expected the mock without the header, got %d", ctx.Response.StatusCode())
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+func TestRetryAfterScript(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-retry.yml"); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+
+	handler := MockHandler(store, nil)
+	call := func(path string) (int, string) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(path)
+		ctx.Request.Header.SetMethod("GET")
+		handler(ctx)
+		return ctx.Response.StatusCode(), string(ctx.Response.Header.Peek("Retry-After"))
+	}
+
+	// Default status 429 with Retry-After counting down to 1, then success
+	for i, expected := range []string{"3", "2", "1"} {
+		status, retryAfter := call("/api/throttled")
+		if status != fasthttp.StatusTooManyRequests || retryAfter != expected {
+			t.Fatalf("Attempt %d: expected 429 with Retry-After %s, got %d with %q", i+1, expected, status, retryAfter)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if status, _ := call("/api/throttled"); status != fasthttp.StatusOK {
+			t.Fatalf("Expected success after retries, got %d", status)
+		}
+	}
+
+	// Explicit status and Retry-After list (last value repeats)
+	for i := 0; i < 2; i++ {
+		status, retryAfter := call("/api/unavailable")
+		if status != fasthttp.StatusServiceUnavailable || retryAfter != "10" {
+			t.Fatalf("Attempt %d: expected 503 with Retry-After 10, got %d with %q", i+1, status, retryAfter)
+		}
+	}
+	if status, _ := call("/api/unavailable"); status != fasthttp.StatusOK {
+		t.Fatalf("Expected success after retries, got %d", status)
+	}
+}
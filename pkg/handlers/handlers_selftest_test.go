@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+)
+
+func TestSelfTestReportsBrokenMocks(t *testing.T) {
+	dir := t.TempDir()
+	mockDir := filepath.Join(dir, "default")
+	if err := os.MkdirAll(mockDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"ok.json": `{"request": {"method": "GET", "url": "http://api.example.com/ok?page=1"},
+			"response": {"status_code": 200, "headers": {"Content-Type": "application/json"}, "body": {"ok": true}}}`,
+		"broken.json": `{"request": {"request_id": "broken-1", "method": "GET", "url": "http://api.example.com/broken"},
+			"response": {"status_code": 200, "headers": {"Content-Type": "application/json"}, "body": "{\"truncated\": "}}`,
+		"text.json": `{"request": {"method": "GET", "url": "http://api.example.com/text"},
+			"response": {"status_code": 200, "headers": {"Content-Type": "text/plain"}, "body": "not json"}}`,
+		"ws.json": `{"type": "websocket", "request": {"method": "GET", "url": "http://api.example.com/ws"},
+			"response": {"status_code": 101, "headers": {}, "body": []}}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(mockDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	store.SetStrict(true)
+
+	report := SelfTest(store)
+	if report.Served != 3 || report.Skipped != 1 {
+		t.Fatalf("Expected 3 served and 1 skipped, got %+v", report)
+	}
+	if len(report.Failures) != 1 {
+		t.Fatalf("Expected one failure, got %+v", report.Failures)
+	}
+	failure := report.Failures[0]
+	if failure.RequestID != "broken-1" || failure.Path != "/broken" || failure.Error != "body is not valid JSON" {
+		t.Fatalf("Unexpected failure: %+v", failure)
+	}
+	if store.Unmatched().Total() != 0 {
+		t.Fatal("Expected the self-test not to count unmatched requests")
+	}
+}
+
+func TestSelfTestScenarios(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-sequence.yml"); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+
+	report := SelfTest(store)
+	if report.Served == 0 || len(report.Failures) != 0 {
+		t.Fatalf("Expected every sequence step to serve, got %+v", report)
+	}
+
+	// Serving in the self-test does not advance sequences
+	router := Router(store, "")
+	for _, target := range store.SelfTestTargets() {
+		if target.Scenario == "" {
+			t.Fatalf("Expected scenario targets only, got %+v", target)
+		}
+	}
+	_, result := evaluateRequestBody(t, router, `{"method":"GET","path":"/api/once"}`)
+	if !result.Matched || result.Response.StatusCode != 200 {
+		t.Fatalf("Expected the one-shot sequence to be untouched, got %+v", result)
+	}
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+func TestScenarioSessionCapture(t *testing.T) {
+	dir := t.TempDir()
+	records := map[string]string{
+		"created.json": `{"request": {"request_id": "c1", "method": "POST", "url": "http://api/orders"},
+			"response": {"status_code": 201, "headers": {"Content-Type": "application/json"}, "body": {"created": "{{session.order_id}}"}}}`,
+		"order.json": `{"request": {"request_id": "o1", "method": "GET", "url": "http://api/orders/current"},
+			"response": {"status_code": 200, "headers": {"Content-Type": "application/json"}, "body": {"id": "{{session.order_id}}", "by": "{{session.user}}"}}}`,
+	}
+	for name, record := range records {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(record), 0644); err != nil {
+			t.Fatalf("Failed to write response: %v", err)
+		}
+	}
+	configPath := filepath.Join(dir, "scenarios.yml")
+	config := `sessions:
+  header: X-Session
+scenarios:
+  - name: create-order
+    method: POST
+    path: /orders
+    capture:
+      order_id: request.body.order.id
+      user: "{{request.headers.X-User}}"
+    response:
+      file: created.json
+      template: true
+  - name: current-order
+    path: /orders/current
+    response:
+      file: order.json
+      template: true
+`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	store, err := storage.NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig(configPath); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+	router := Router(store, "")
+
+	call := func(method, path, session, body string) string {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(method)
+		ctx.Request.SetRequestURI(path)
+		ctx.Request.Header.Set("X-Session", session)
+		ctx.Request.Header.Set("X-User", "user-"+session)
+		if body != "" {
+			ctx.Request.Header.SetContentType("application/json")
+			ctx.Request.SetBodyString(body)
+		}
+		router(ctx)
+		return string(ctx.Response.Body())
+	}
+
+	// The capturing request already sees its own variables
+	if body := call("POST", "/orders", "a", `{"order": {"id": "ord-1"}}`); body != `{"created":"ord-1"}` {
+		t.Errorf("Expected the captured id in the creating response, got %s", body)
+	}
+	call("POST", "/orders", "b", `{"order": {"id": "ord-2"}}`)
+
+	if body := call("GET", "/orders/current", "a", ""); body != `{"by":"user-a","id":"ord-1"}` {
+		t.Errorf("Expected session a's order, got %s", body)
+	}
+	if body := call("GET", "/orders/current", "b", ""); body != `{"by":"user-b","id":"ord-2"}` {
+		t.Errorf("Expected session b's order, got %s", body)
+	}
+	if body := call("GET", "/orders/current", "c", ""); body != `{"by":"","id":""}` {
+		t.Errorf("Expected empty variables for a new session, got %s", body)
+	}
+
+	// A later capture replaces the value; Reset forgets everything
+	call("POST", "/orders", "a", `{"order": {"id": "ord-3"}}`)
+	if body := call("GET", "/orders/current", "a", ""); body != `{"by":"user-a","id":"ord-3"}` {
+		t.Errorf("Expected the latest capture, got %s", body)
+	}
+	if err := store.Reset(); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if body := call("GET", "/orders/current", "a", ""); body != `{"by":"","id":""}` {
+		t.Errorf("Expected Reset to clear the session, got %s", body)
+	}
+}
+
+func TestScenarioSessionStatePersistence(t *testing.T) {
+	dir := t.TempDir()
+	record := `{"request": {"method": "GET", "url": "http://api/orders/current"},
+		"response": {"status_code": 200, "headers": {"Content-Type": "application/json"}, "body": {"id": "{{session.order_id}}"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "order.json"), []byte(record), 0644); err != nil {
+		t.Fatalf("Failed to write response: %v", err)
+	}
+	configPath := filepath.Join(dir, "scenarios.yml")
+	config := `sessions:
+  header: X-Session
+scenarios:
+  - name: create-order
+    method: POST
+    path: /orders
+    capture:
+      order_id: request.body.id
+    response:
+      file: order.json
+      template: true
+  - name: current-order
+    path: /orders/current
+    response:
+      file: order.json
+      template: true
+`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	load := func() *storage.MockStorage {
+		store, err := storage.NewMockStorage(t.TempDir())
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+		if err := store.LoadScenarioConfig(configPath); err != nil {
+			t.Fatalf("Failed to load scenarios: %v", err)
+		}
+		return store
+	}
+	call := func(router fasthttp.RequestHandler, method, path, body string) string {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(method)
+		ctx.Request.SetRequestURI(path)
+		ctx.Request.Header.Set("X-Session", "a")
+		if body != "" {
+			ctx.Request.Header.SetContentType("application/json")
+			ctx.Request.SetBodyString(body)
+		}
+		router(ctx)
+		return string(ctx.Response.Body())
+	}
+
+	statePath := filepath.Join(dir, "state.json")
+	store := load()
+	call(Router(store, ""), "POST", "/orders", `{"id": "ord-1"}`)
+	if err := store.SaveScenarioState(statePath); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	// A restarted server still knows what the session captured
+	restarted := load()
+	if _, err := restarted.RestoreScenarioState(statePath); err != nil {
+		t.Fatalf("Failed to restore state: %v", err)
+	}
+	if body := call(Router(restarted, ""), "GET", "/orders/current", ""); body != `{"id":"ord-1"}` {
+		t.Errorf("Expected the captured variable to survive the restart, got %s", body)
+	}
+}
+
+func TestScenarioSessionCaptureErrors(t *testing.T) {
+	dir := t.TempDir()
+	record := `{"request": {"request_id": "o1", "method": "GET", "url": "http://api/orders"},
+		"response": {"status_code": 200, "headers": {"Content-Type": "application/json"}, "body": {}}}`
+	if err := os.WriteFile(filepath.Join(dir, "order.json"), []byte(record), 0644); err != nil {
+		t.Fatalf("Failed to write response: %v", err)
+	}
+
+	for name, config := range map[string]string{
+		"bad name":      "scenarios:\n  - name: s\n    path: /orders\n    capture:\n      order-id: request.body.id\n    response:\n      file: order.json\n",
+		"bad reference": "scenarios:\n  - name: s\n    path: /orders\n    capture:\n      id: request.cookies.id\n    response:\n      file: order.json\n",
+		"delay only":    "scenarios:\n  - name: s\n    path: /orders\n    capture:\n      id: request.body.id\n    response:\n      delay: 1\n",
+		"both keys":     "sessions:\n  header: X-Session\n  cookie: session\nscenarios:\n  - name: s\n    path: /orders\n    capture:\n      id: request.body.id\n    response:\n      file: order.json\n",
+		"bad session":   "scenarios:\n  - name: s\n    path: /orders\n    response:\n      file: order.json\n      headers:\n        X-Id: \"{{session.a.b}}\"\n",
+	} {
+		configPath := filepath.Join(dir, "scenarios.yml")
+		if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		store, err := storage.NewMockStorage(t.TempDir())
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+		if err := store.LoadScenarioConfig(configPath); err == nil {
+			t.Errorf("%s: expected the config to be rejected", name)
+		}
+	}
+}
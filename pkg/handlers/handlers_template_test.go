@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+func TestResponseHeaderTemplating(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-template.yml"); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+
+	handler := MockHandler(store, nil)
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/orders?retry=30")
+	ctx.Request.Header.SetMethod("POST")
+	ctx.Request.SetBody([]byte(`{"id":"ORD-42","quantity":3}`))
+
+	handler(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusCreated {
+		t.Fatalf("Expected 201, got %d", ctx.Response.StatusCode())
+	}
+
+	if location := string(ctx.Response.Header.Peek("Location")); location != "/orders/ORD-42" {
+		t.Fatalf("Expected templated Location header, got %q", location)
+	}
+	if retryAfter := string(ctx.Response.Header.Peek("Retry-After")); retryAfter != "30" {
+		t.Fatalf("Expected Retry-After from query, got %q", retryAfter)
+	}
+	if path := string(ctx.Response.Header.Peek("X-Request-Path")); path != "/orders" {
+		t.Fatalf("Expected recorded header to be templated, got %q", path)
+	}
+
+	generatedAt, err := time.Parse(http.TimeFormat, string(ctx.Response.Header.Peek("X-Generated-At")))
+	if err != nil {
+		t.Fatalf("Expected HTTP date, got %q: %v", ctx.Response.Header.Peek("X-Generated-At"), err)
+	}
+	if time.Since(generatedAt) > time.Minute {
+		t.Fatalf("Expected fresh timestamp, got %v", generatedAt)
+	}
+
+	expectedBody := `{"id":"ORD-42","quantity":"3","status":"created"}`
+	if string(ctx.Response.Body()) != expectedBody {
+		t.Fatalf("Expected templated body %s, got %s", expectedBody, ctx.Response.Body())
+	}
+}
+
+func TestServedLogRecordsRenderedResponse(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-template.yml"); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := store.SetServedLog(dir); err != nil {
+		t.Fatalf("Failed to enable served log: %v", err)
+	}
+
+	handler := MockHandler(store, nil)
+	for _, uri := range []string{"/orders?retry=30", "/missing"} {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(uri)
+		ctx.Request.Header.SetMethod("POST")
+		ctx.Request.SetBody([]byte(`{"id":"ORD-42","quantity":3}`))
+		handler(ctx)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("Expected two served records, got %v (%v)", files, err)
+	}
+
+	var first struct {
+		Sequence int `json:"sequence"`
+		Response struct {
+			StatusCode int               `json:"status_code"`
+			Headers    map[string]string `json:"headers"`
+			Body       map[string]string `json:"body"`
+		} `json:"response"`
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Failed to read served record: %v", err)
+	}
+	if err := json.Unmarshal(data, &first); err != nil {
+		t.Fatalf("Failed to parse served record: %v", err)
+	}
+	if first.Sequence != 1 || first.Response.StatusCode != fasthttp.StatusCreated {
+		t.Fatalf("Unexpected first record: %+v", first)
+	}
+	if first.Response.Headers["Location"] != "/orders/ORD-42" {
+		t.Fatalf("Expected rendered Location header, got %q", first.Response.Headers["Location"])
+	}
+	if first.Response.Body["id"] != "ORD-42" {
+		t.Fatalf("Expected rendered body, got %v", first.Response.Body)
+	}
+
+	var second struct {
+		Response struct {
+			StatusCode int `json:"status_code"`
+		} `json:"response"`
+	}
+	data, err = os.ReadFile(files[1])
+	if err != nil {
+		t.Fatalf("Failed to read served record: %v", err)
+	}
+	if err := json.Unmarshal(data, &second); err != nil {
+		t.Fatalf("Failed to parse served record: %v", err)
+	}
+	if second.Response.StatusCode != fasthttp.StatusNotFound {
+		t.Fatalf("Expected 404 to be recorded, got %d", second.Response.StatusCode)
+	}
+}
+
+// servedMockRequestIDs returns the mock_request_id of every served record in
+// dir, in serving order.
+func servedMockRequestIDs(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatalf("Failed to list served records: %v", err)
+	}
+	ids := make([]string, 0, len(files))
+	for _, file := range files {
+		var record struct {
+			Response struct {
+				MockRequestID string `json:"mock_request_id"`
+			} `json:"response"`
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read served record: %v", err)
+		}
+		if err := json.Unmarshal(data, &record); err != nil {
+			t.Fatalf("Failed to parse served record: %v", err)
+		}
+		ids = append(ids, record.Response.MockRequestID)
+	}
+	return ids
+}
+
+func TestServedLogRecordsExperimentVariant(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-experiment.yml"); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+	dir := t.TempDir()
+	if err := store.SetServedLog(dir); err != nil {
+		t.Fatalf("Failed to enable served log: %v", err)
+	}
+
+	handler := MockHandler(store, nil)
+	for _, variant := range []string{"b", "a"} {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/api/data")
+		ctx.Request.Header.SetMethod("GET")
+		ctx.Request.Header.SetCookie("data_variant", variant)
+		handler(ctx)
+	}
+
+	// Each record names the recording of the variant that answered
+	ids := servedMockRequestIDs(t, dir)
+	if len(ids) != 2 || ids[0] != "bench-test-2040ed72" || ids[1] != "bench-test-3121ee87" {
+		t.Fatalf("Expected the B then the A recording, got %v", ids)
+	}
+}
+
+func TestServedLogRecordsRotatedResponse(t *testing.T) {
+	dir := t.TempDir()
+	quotes := filepath.Join(dir, "quotes")
+	if err := os.MkdirAll(quotes, 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	for _, name := range []string{"a", "b"} {
+		record := `{"request":{"request_id":"quote-` + name + `","method":"GET","url":"http://api.example.com/quote"},` +
+			`"response":{"status_code":200,"headers":{"Content-Type":"application/json"},"body":{"quote":"` + name + `"}}}`
+		if err := os.WriteFile(filepath.Join(quotes, name+".json"), []byte(record), 0644); err != nil {
+			t.Fatalf("Failed to write record: %v", err)
+		}
+	}
+	configPath := filepath.Join(dir, "scenarios.yml")
+	config := "scenarios:\n  - name: Rotating\n    path: /quote\n    response:\n      dir: quotes\n"
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	store, err := storage.NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig(configPath); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+	logDir := t.TempDir()
+	if err := store.SetServedLog(logDir); err != nil {
+		t.Fatalf("Failed to enable served log: %v", err)
+	}
+
+	handler := MockHandler(store, nil)
+	for i := 0; i < 3; i++ {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/quote")
+		handler(ctx)
+	}
+
+	// Each record names the recording the rotation picked
+	ids := servedMockRequestIDs(t, logDir)
+	if len(ids) != 3 || ids[0] != "quote-a" || ids[1] != "quote-b" || ids[2] != "quote-a" {
+		t.Fatalf("Expected the rotated recordings a, b, a, got %v", ids)
+	}
+}
+
+func TestEnvPlaceholdersInRecordedBody(t *testing.T) {
+	dir := t.TempDir()
+	mockDir := filepath.Join(dir, "default")
+	if err := os.MkdirAll(mockDir, 0755); err != nil {
+		t.Fatalf("Failed to create mock dir: %v", err)
+	}
+	record := `{"request":{"method":"GET","url":"http://api.example.com/config"},` +
+		`"response":{"status_code":200,"headers":{"Content-Type":"application/json"},` +
+		`"body":{"host":"${ENV:MOCK_TEST_HOST}","client":"${ENV:MOCK_TEST_UNSET}","raw":"${ENV:not valid}","literal":"{{request.path}}"}}}`
+	if err := os.WriteFile(filepath.Join(mockDir, "config.json"), []byte(record), 0644); err != nil {
+		t.Fatalf("Failed to write mock: %v", err)
+	}
+
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	handler := MockHandler(store, nil)
+
+	// Values are resolved when the response is served, not when it is loaded
+	for _, host := range []string{"billing.staging", "billing.prod"} {
+		t.Setenv("MOCK_TEST_HOST", host)
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/config")
+		handler(ctx)
+
+		var body map[string]string
+		if err := json.Unmarshal(ctx.Response.Body(), &body); err != nil {
+			t.Fatalf("Failed to parse body %s: %v", ctx.Response.Body(), err)
+		}
+		want := map[string]string{"host": host, "client": "", "raw": "${ENV:not valid}", "literal": "{{request.path}}"}
+		for key, value := range want {
+			if body[key] != value {
+				t.Errorf("%s: expected %q, got %q", key, value, body[key])
+			}
+		}
+	}
+}
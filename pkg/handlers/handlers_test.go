@@ -1,11 +1,19 @@
 package handlers
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
 	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
 )
 
 func BenchmarkMockHandler(b *testing.B) {
@@ -294,3 +302,286 @@ func TestMockHandlerScenarioNoFilter(t *testing.T) {
 		t.Fatal("Expected non-empty response body")
 	}
 }
+
+func TestMockHandlerMethodOverride(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-method-override.yml"); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+
+	handler := MockHandler(store, nil)
+	call := func() int {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/api/items/1")
+		ctx.Request.Header.SetMethod("POST")
+		ctx.Request.Header.Set("X-HTTP-Method-Override", "DELETE")
+		handler(ctx)
+		return ctx.Response.StatusCode()
+	}
+
+	// Disabled by default: the POST does not match the DELETE scenario
+	if status := call(); status != fasthttp.StatusNotFound {
+		t.Fatalf("Expected 404 without method override, got %d", status)
+	}
+
+	store.SetMethodOverride(true)
+	if status := call(); status != fasthttp.StatusOK {
+		t.Fatalf("Expected 200 with method override, got %d", status)
+	}
+}
+
+func TestMockHandlerMockIDPrefix(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	store.SetMockIDPrefix(true)
+
+	handler := MockHandler(store, nil)
+	call := func(path string) (int, string) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(path)
+		ctx.Request.Header.SetMethod("GET")
+		handler(ctx)
+		return ctx.Response.StatusCode(), string(ctx.Response.Body())
+	}
+
+	// Both variants of the same endpoint are reachable side by side
+	if status, body := call("/api-v1/data/4"); status != fasthttp.StatusOK || !strings.Contains(body, `"version":1`) {
+		t.Fatalf("Expected api-v1 response, got %d %s", status, body)
+	}
+	if status, body := call("/api-v2/data/4"); status != fasthttp.StatusOK || !strings.Contains(body, `"version":2`) {
+		t.Fatalf("Expected api-v2 response, got %d %s", status, body)
+	}
+	if status, _ := call("/api-v3/data/4"); status != fasthttp.StatusNotFound {
+		t.Fatalf("Expected 404 for unknown mock ID, got %d", status)
+	}
+}
+
+func TestMockHandlerStrictCountsUnmatched(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	store.SetStrict(true)
+
+	handler := MockHandler(store, nil)
+	for _, path := range []string{"/missing", "/missing", "/also-missing"} {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(path)
+		ctx.Request.Header.SetMethod("GET")
+		handler(ctx)
+		if ctx.Response.StatusCode() != fasthttp.StatusNotFound {
+			t.Fatalf("Expected 404 for %s, got %d", path, ctx.Response.StatusCode())
+		}
+	}
+
+	unmatched := store.Unmatched()
+	if unmatched.Total() != 3 {
+		t.Fatalf("Expected 3 unmatched requests, got %d", unmatched.Total())
+	}
+	summary := unmatched.Summary()
+	if len(summary) != 2 || summary[0].Path != "/missing" || summary[0].Count != 2 {
+		t.Fatalf("Unexpected unmatched summary: %+v", summary)
+	}
+}
+
+func TestMockHandlerCustomNotFound(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	notFound, err := storage.NewNotFoundResponse(418, "text/plain", "no mock for {{request.method}} {{request.path}} (id={{request.query.id}})")
+	if err != nil {
+		t.Fatalf("Failed to create not found response: %v", err)
+	}
+	store.SetNotFoundResponse(notFound)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/missing?id=7")
+	ctx.Request.Header.SetMethod("DELETE")
+	MockHandler(store, nil)(ctx)
+
+	if ctx.Response.StatusCode() != 418 {
+		t.Fatalf("Expected 418, got %d", ctx.Response.StatusCode())
+	}
+	if ct := string(ctx.Response.Header.ContentType()); ct != "text/plain" {
+		t.Errorf("Expected text/plain, got %s", ct)
+	}
+	if body := string(ctx.Response.Body()); body != "no mock for DELETE /missing (id=7)" {
+		t.Errorf("Unexpected body: %s", body)
+	}
+
+	// Only the status overridden: the default JSON body is kept
+	notFound, err = storage.NewNotFoundResponse(501, "", "")
+	if err != nil {
+		t.Fatalf("Failed to create not found response: %v", err)
+	}
+	store.SetNotFoundResponse(notFound)
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/missing")
+	MockHandler(store, nil)(ctx)
+	if ctx.Response.StatusCode() != 501 || string(ctx.Response.Body()) != `{"error":"No mock found"}` {
+		t.Errorf("Unexpected response: %d %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+
+	if _, err := storage.NewNotFoundResponse(700, "", ""); err == nil {
+		t.Error("Expected an error for status 700")
+	}
+	if _, err := storage.NewNotFoundResponse(0, "", "{{request.nope}}"); err == nil {
+		t.Error("Expected an error for an invalid template")
+	}
+}
+
+func TestMatchLogRecordsMatchedRequests(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	dir := t.TempDir()
+	if err := store.SetMatchLog(dir); err != nil {
+		t.Fatalf("Failed to enable match log: %v", err)
+	}
+
+	handler := MockHandler(store, nil)
+	for _, req := range []struct{ uri, mockID, accept string }{
+		{"/data/2", "api-v1", "application/json"},
+		{"/stream", "sse-test", "text/event-stream"},
+		{"/missing", "api-v1", "application/json"},
+	} {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(req.uri)
+		ctx.Request.Header.SetMethod("GET")
+		ctx.Request.Header.Set("Accept", req.accept)
+		ctx.Request.Header.Set("x-mock-id", req.mockID)
+		handler(ctx)
+	}
+
+	jsonLogs, _ := filepath.Glob(filepath.Join(dir, "api-v1", "application_json_*.json"))
+	sseLogs, _ := filepath.Glob(filepath.Join(dir, "sse-test", "text_event-stream_*.json"))
+	if len(jsonLogs) != 1 || len(sseLogs) != 1 {
+		t.Fatalf("Expected one log per matched request, got %v and %v", jsonLogs, sseLogs)
+	}
+
+	var record struct {
+		Response struct {
+			StatusCode    int           `json:"status_code"`
+			Body          []interface{} `json:"body"`
+			MockRequestID string        `json:"mock_request_id"`
+		} `json:"response"`
+	}
+	data, err := os.ReadFile(sseLogs[0])
+	if err != nil {
+		t.Fatalf("Failed to read match log: %v", err)
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("Failed to parse match log: %v", err)
+	}
+	if record.Response.MockRequestID != "sse-bench-35e6d6d3" || len(record.Response.Body) != 5 {
+		t.Fatalf("Unexpected SSE match log: %+v", record.Response)
+	}
+
+	// The log is itself a loadable recording directory
+	replayed, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to load match log as mocks: %v", err)
+	}
+	if failed := replayed.FailedFiles(); len(failed) != 0 {
+		t.Fatalf("Match log files failed to load: %+v", failed)
+	}
+	if replayed.FindResponseBytes([]byte("/data/2"), []byte("api-v1"), []byte("application/json"), []byte("GET")) == nil {
+		t.Fatal("Expected the logged request to be served from the match log")
+	}
+}
+
+func TestMockServerExpectContinue(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	ln := fasthttputil.NewInmemoryListener()
+	server := &fasthttp.Server{Handler: Router(store, "")}
+	go server.Serve(ln)
+	defer ln.Close()
+
+	conn, err := ln.Dial()
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+
+	body := `{"file":"data"}`
+	conn.Write([]byte("POST /users/1 HTTP/1.1\r\nHost: mock\r\nExpect: 100-continue\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n"))
+
+	// The interim response must arrive before the client sends the body
+	reader := bufio.NewReader(conn)
+	status, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(status, "HTTP/1.1 100 Continue") {
+		t.Fatalf("Expected interim 100 Continue, got %q (%v)", status, err)
+	}
+	reader.ReadString('\n') // Blank line ending the interim response
+
+	conn.Write([]byte(body))
+	status, err = reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(status, "HTTP/1.1 ") {
+		t.Fatalf("Expected final response, got %q (%v)", status, err)
+	}
+}
+
+func TestRequestSizeLimits(t *testing.T) {
+	store, err := storage.NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	ln := fasthttputil.NewInmemoryListener()
+	server := &fasthttp.Server{
+		Handler:            Router(store, ""),
+		ErrorHandler:       RequestErrorHandler,
+		MaxRequestBodySize: 64,
+		ReadBufferSize:     1024,
+	}
+	go server.Serve(ln)
+	defer ln.Close()
+
+	send := func(raw string) *fasthttp.Response {
+		conn, err := ln.Dial()
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(3 * time.Second))
+		conn.Write([]byte(raw))
+
+		resp := &fasthttp.Response{}
+		if err := resp.Read(bufio.NewReader(conn)); err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		return resp
+	}
+
+	body := strings.Repeat("x", 100)
+	resp := send("POST /users/1 HTTP/1.1\r\nHost: mock\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body)
+	if resp.StatusCode() != fasthttp.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected 413 for oversized body, got %d", resp.StatusCode())
+	}
+	if string(resp.Body()) != `{"error":"Request body too large"}` {
+		t.Fatalf("Unexpected 413 body: %s", resp.Body())
+	}
+
+	resp = send("GET /users/1 HTTP/1.1\r\nHost: mock\r\nX-Big: " + strings.Repeat("y", 2048) + "\r\n\r\n")
+	if resp.StatusCode() != fasthttp.StatusRequestHeaderFieldsTooLarge {
+		t.Fatalf("Expected 431 for oversized header, got %d", resp.StatusCode())
+	}
+
+	resp = send("GET /users/1 HTTP/1.1\r\nHost: mock\r\n\r\n")
+	if resp.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("Expected requests within limits to be served, got %d", resp.StatusCode())
+	}
+}
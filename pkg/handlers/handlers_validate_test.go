@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/openapi"
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+func TestValidateSpec(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "default"), 0755); err != nil {
+		t.Fatalf("Failed to create mock dir: %v", err)
+	}
+	record := `{"request": {"method": "POST", "url": "http://api/users"},
+		"response": {"status_code": 201, "headers": {"Content-Type": "application/json"}, "body": {"id": 1}}}`
+	if err := os.WriteFile(filepath.Join(dir, "default", "create.json"), []byte(record), 0644); err != nil {
+		t.Fatalf("Failed to write mock: %v", err)
+	}
+	spec := filepath.Join(t.TempDir(), "openapi.yml")
+	if err := os.WriteFile(spec, []byte(`openapi: 3.0.3
+info: {title: Users, version: "1"}
+paths:
+  /users:
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [name]
+              properties:
+                name: {type: string, minLength: 1}
+      responses:
+        "201": {description: Created}
+`), 0644); err != nil {
+		t.Fatalf("Failed to write spec: %v", err)
+	}
+
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	doc, err := openapi.LoadSpec(spec)
+	if err != nil {
+		t.Fatalf("Failed to load spec: %v", err)
+	}
+	validator, err := openapi.NewValidator(doc)
+	if err != nil {
+		t.Fatalf("Failed to build validator: %v", err)
+	}
+	store.SetRequestValidator(validator)
+
+	ln := fasthttputil.NewInmemoryListener()
+	server := &fasthttp.Server{Handler: Router(store, "")}
+	go server.Serve(ln)
+	defer ln.Close()
+	client := &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+
+	post := func(body string) *fasthttp.Response {
+		req := fasthttp.AcquireRequest()
+		defer fasthttp.ReleaseRequest(req)
+		req.SetRequestURI("http://mock/users")
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.Header.SetContentType("application/json")
+		req.SetBodyString(body)
+		resp := &fasthttp.Response{}
+		if err := client.Do(req, resp); err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		return resp
+	}
+
+	resp := post(`{"name": ""}`)
+	if resp.StatusCode() != fasthttp.StatusBadRequest {
+		t.Fatalf("Expected 400 for an invalid body, got %d: %s", resp.StatusCode(), resp.Body())
+	}
+	var answer struct {
+		Operation  string              `json:"operation"`
+		Violations []openapi.Violation `json:"violations"`
+	}
+	if err := json.Unmarshal(resp.Body(), &answer); err != nil {
+		t.Fatalf("Expected a JSON answer, got %q: %v", resp.Body(), err)
+	}
+	if answer.Operation != "POST /users" || len(answer.Violations) != 1 || answer.Violations[0].Name != "$.name" {
+		t.Fatalf("Expected one violation on $.name, got %+v", answer)
+	}
+
+	if resp := post(`{"name": "Ann"}`); resp.StatusCode() != fasthttp.StatusCreated || string(resp.Body()) != `{"id":1}` {
+		t.Fatalf("Expected the mock for a valid request, got %d: %s", resp.StatusCode(), resp.Body())
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+const websocketRecord = `{
+  "type": "websocket",
+  "request": {
+    "method": "GET",
+    "url": "http://api.example.com/ws/prices",
+    "headers": {"x-mock-id": "prices"}
+  },
+  "response": {
+    "status_code": 101,
+    "headers": {"Upgrade": "websocket", "Connection": "Upgrade", "Sec-WebSocket-Accept": "stale", "Sec-WebSocket-Protocol": "prices.v1"},
+    "body": [
+      {"direction": "server", "timestamp": 0.0, "type": "text", "data": "welcome"},
+      {"direction": "client", "timestamp": 0.5, "type": "text", "data": "{\"subscribe\":\"ACME\"}"},
+      {"direction": "server", "timestamp": 0.6, "type": "text", "data": "{\"price\":42}"},
+      {"direction": "server", "timestamp": 0.6, "type": "binary", "data": "AAEC", "encoding": "base64"},
+      {"direction": "server", "timestamp": 0.7, "type": "close", "code": 1001, "reason": "going away"}
+    ],
+    "delay": 0
+  }
+}`
+
+// clientFrame builds a masked client-to-server frame.
+func clientFrame(opcode byte, payload []byte) []byte {
+	key := []byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, key...)
+	for i, b := range payload {
+		frame = append(frame, b^key[i%4])
+	}
+	return frame
+}
+
+func TestWebSocketReplay(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "prices"), 0755); err != nil {
+		t.Fatalf("Failed to create mock dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "prices", "websocket_1.json"), []byte(websocketRecord), 0644); err != nil {
+		t.Fatalf("Failed to write mock: %v", err)
+	}
+
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	store.SetTimingConfig(true, 0.0)
+
+	ln := fasthttputil.NewInmemoryListener()
+	server := &fasthttp.Server{Handler: Router(store, "")}
+	go server.Serve(ln)
+	defer ln.Close()
+
+	// Plain requests that reach a WebSocket mock are told to upgrade
+	client := &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+	req.SetRequestURI("http://mock/ws/prices")
+	req.Header.Set("Accept", "*/*")
+	req.Header.Set("x-mock-id", "prices")
+	if err := client.Do(req, resp); err != nil || resp.StatusCode() != fasthttp.StatusUpgradeRequired {
+		t.Fatalf("Expected 426 for a plain request, got %d (%v)", resp.StatusCode(), err)
+	}
+
+	conn, err := ln.Dial()
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	conn.Write([]byte("GET /ws/prices HTTP/1.1\r\nHost: mock\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: " + key + "\r\nx-mock-id: prices\r\n\r\n"))
+
+	br := bufio.NewReader(conn)
+	var head fasthttp.ResponseHeader
+	if err := head.Read(br); err != nil {
+		t.Fatalf("Failed to read handshake: %v", err)
+	}
+	if head.StatusCode() != fasthttp.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", head.StatusCode())
+	}
+	if got := string(head.Peek("Sec-WebSocket-Accept")); got != storage.WebSocketAccept(key) {
+		t.Errorf("Expected a fresh Sec-WebSocket-Accept, got %q", got)
+	}
+	if got := string(head.Peek("Sec-WebSocket-Protocol")); got != "prices.v1" {
+		t.Errorf("Expected recorded subprotocol, got %q", got)
+	}
+
+	readFrame := func() storage.WebSocketFrame {
+		t.Helper()
+		frame, _, err := storage.ReadWebSocketFrame(br)
+		if err != nil {
+			t.Fatalf("Failed to read frame: %v", err)
+		}
+		return frame
+	}
+
+	if frame := readFrame(); string(frame.Payload) != "welcome" {
+		t.Fatalf("Expected welcome frame, got %q", frame.Payload)
+	}
+
+	// The price update answers the client's message after the recorded 100ms gap
+	sent := time.Now()
+	conn.Write(clientFrame(storage.WebSocketText, []byte(`{"subscribe":"ACME"}`)))
+	if frame := readFrame(); string(frame.Payload) != `{"price":42}` {
+		t.Fatalf("Expected price frame, got %q", frame.Payload)
+	}
+	if elapsed := time.Since(sent); elapsed < 80*time.Millisecond {
+		t.Errorf("Expected the recorded gap before the reply, got %v", elapsed)
+	}
+	if frame := readFrame(); frame.Opcode != storage.WebSocketBinary || string(frame.Payload) != "\x00\x01\x02" {
+		t.Fatalf("Expected binary frame, got opcode %d %q", frame.Opcode, frame.Payload)
+	}
+
+	frame := readFrame()
+	if code, reason := frame.CloseCode(); frame.Opcode != storage.WebSocketClose || code != 1001 || reason != "going away" {
+		t.Fatalf("Expected close 1001, got opcode %d code %d %q", frame.Opcode, code, reason)
+	}
+}
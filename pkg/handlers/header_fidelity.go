@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"time"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+var headerDate = []byte("Date")
+
+// applyHeaderFidelity replaces the Date and Server headers fasthttp would
+// send with the -date-header and -server-header choices. It goes last among
+// the headers, since setting Date parses the header back.
+func applyHeaderFidelity(ctx *fasthttp.RequestCtx, fidelity storage.HeaderFidelity, mockResponse *storage.MockResponse) {
+	switch fidelity.Server {
+	case storage.HeaderLive, storage.HeaderNone:
+		// The server name, if any, is filled in by fasthttp
+		ctx.Response.Header.SetServer("")
+	}
+
+	if fidelity.Date == storage.HeaderRecorded {
+		if date, ok := mockResponse.RecordedHeader("date"); ok {
+			setDateHeader(&ctx.Response.Header, []byte(date))
+		} else {
+			setDateHeader(&ctx.Response.Header, liveDate())
+		}
+	}
+}
+
+// chunkedFraming reports whether the body of mockResponse goes out with
+// Transfer-Encoding: chunked instead of Content-Length, per -body-framing.
+func chunkedFraming(fidelity storage.HeaderFidelity, mockResponse *storage.MockResponse) bool {
+	switch fidelity.Framing {
+	case storage.HeaderChunked:
+		return true
+	case storage.HeaderRecorded:
+		return mockResponse.RecordedChunked()
+	}
+	return false
+}
+
+// streamBody resends the body already set on ctx as a chunked stream.
+func streamBody(ctx *fasthttp.RequestCtx) {
+	body := append([]byte(nil), ctx.Response.Body()...)
+	ctx.Response.SetBodyStream(bytes.NewReader(body), -1)
+}
+
+// LiveDate adds the current Date to responses that have none. Servers running
+// with NoDefaultDate for -date-header=recorded wrap their handler with it, so
+// admin endpoints and responses without a mock still carry a date.
+func LiveDate(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		next(ctx)
+		if !ctx.Hijacked() && len(ctx.Response.Header.PeekBytes(headerDate)) == 0 {
+			setDateHeader(&ctx.Response.Header, liveDate())
+		}
+	}
+}
+
+func liveDate() []byte {
+	return fasthttp.AppendHTTPDate(nil, time.Now())
+}
+
+// setDateHeader sets the Date header. fasthttp ignores Date set through the
+// header API and writes the current date itself; a server with NoDefaultDate
+// only writes Date headers parsed from a raw header, so the header is
+// serialized and parsed back with the date added.
+func setDateHeader(h *fasthttp.ResponseHeader, date []byte) {
+	head := h.Header()
+	raw := make([]byte, 0, len(head)+len(date)+len("Date: \r\n"))
+	raw = append(raw, head[:len(head)-2]...) // Without the blank line ending the header
+	raw = append(raw, "Date: "...)
+	raw = append(raw, date...)
+	raw = append(raw, "\r\n\r\n"...)
+	// A header fasthttp serialized itself always parses
+	h.Read(bufio.NewReader(bytes.NewReader(raw)))
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"bufio"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+var metricsPath = []byte("/__mock__/metrics")
+
+// MetricsHandler exposes the injected faults, replayed delays and jitter in
+// the OpenMetrics text format, for correlating client failures in chaos runs.
+func MetricsHandler(store *storage.MockStorage) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType(storage.OpenMetricsContentType)
+		ctx.SetBodyStreamWriter(func(w *bufio.Writer) { store.WriteMetrics(w) })
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"bytes"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+var interimStatusLine = []byte("HTTP/1.1 1")
+
+// ReceiptListener notes when the first bytes of each request arrive on the
+// connections of ln. fasthttp only calls the handler, and stamps ctx.Time,
+// once the whole body has been read; servers replaying timing wrap their
+// listener with it so a slow upload counts towards the recorded delay instead
+// of adding to it. Wrap the plain TCP listener, below any TLS listener.
+func ReceiptListener(ln net.Listener) net.Listener {
+	return &receiptListener{Listener: ln}
+}
+
+type receiptListener struct {
+	net.Listener
+}
+
+func (l *receiptListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	c := &receiptConn{Conn: conn}
+	c.awaiting.Store(true)
+	return c, nil
+}
+
+// receiptConn stamps the first read after a response, which starts the next
+// request. Interim 1xx responses such as 100 Continue do not end a request.
+type receiptConn struct {
+	net.Conn
+	awaiting atomic.Bool
+	received atomic.Int64 // Unix nanoseconds of the current request's first bytes
+}
+
+func (c *receiptConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 && c.awaiting.CompareAndSwap(true, false) {
+		c.received.Store(time.Now().UnixNano())
+	}
+	return n, err
+}
+
+func (c *receiptConn) Write(p []byte) (int, error) {
+	if !bytes.HasPrefix(p, interimStatusLine) {
+		c.awaiting.Store(true)
+	}
+	return c.Conn.Write(p)
+}
+
+// connReceivedAt returns when the request of ctx started to arrive, if its
+// connection was accepted by a ReceiptListener.
+func connReceivedAt(ctx *fasthttp.RequestCtx) (time.Time, bool) {
+	conn := ctx.Conn()
+	// TLS connections read the request through the wrapped TCP connection
+	if tlsConn, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		conn = tlsConn.NetConn()
+	}
+	c, ok := conn.(*receiptConn)
+	if !ok {
+		return time.Time{}, false
+	}
+	received := c.received.Load()
+	if received == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, received), true
+}
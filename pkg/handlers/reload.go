@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	reloadPath = []byte("/__mock__/reload")
+	resetPath  = []byte("/__mock__/reset")
+)
+
+// ReloadHandler re-reads the mock directory and the scenario config, then
+// answers with the refreshed stats. When the scenario config no longer loads
+// it answers 500 and keeps serving the previous mocks.
+func ReloadHandler(store *storage.MockStorage) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType(defaultContentType)
+		if err := store.Reload(); err != nil {
+			ctx.SetStatusCode(fasthttp.StatusInternalServerError)
+			body, _ := json.Marshal(map[string]string{"error": err.Error()})
+			ctx.SetBody(body)
+			return
+		}
+		ctx.SetBodyStreamWriter(func(w *bufio.Writer) { store.WriteStatsJSON(w) })
+	}
+}
+
+// ResetHandler drops the admin mocks and restarts scenario sequences,
+// rotations and unmatched counts, answering 204.
+func ResetHandler(store *storage.MockStorage) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if err := store.Reset(); err != nil {
+			ctx.SetContentType(defaultContentType)
+			ctx.SetStatusCode(fasthttp.StatusInternalServerError)
+			body, _ := json.Marshal(map[string]string{"error": err.Error()})
+			ctx.SetBody(body)
+			return
+		}
+		ctx.SetStatusCode(fasthttp.StatusNoContent)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	headerRespondWith    = []byte("x-mock-respond-with")
+	textContentTypeBytes = []byte("text/plain; charset=utf-8")
+)
+
+// respondWith is the inline response of an x-mock-respond-with header. Body
+// may be any JSON value; a JSON string is sent as plain text.
+type respondWith struct {
+	Status int             `json:"status"` // 200 when unset
+	Body   json.RawMessage `json:"body"`
+	Delay  float64         `json:"delay"` // Seconds to wait before answering
+}
+
+// serveRespondWith answers the request from its x-mock-respond-with header,
+// when -respond-with-header allows it, instead of matching a mock. It reports
+// whether the request was answered.
+func serveRespondWith(ctx *fasthttp.RequestCtx) bool {
+	header := ctx.Request.Header.PeekBytes(headerRespondWith)
+	if len(header) == 0 {
+		return false
+	}
+
+	var inline respondWith
+	if err := parseRespondWith(header, &inline); err != nil {
+		ctx.SetStatusCode(fasthttp.StatusBadRequest)
+		ctx.Response.Header.SetBytesKV(headerContentType, defaultContentTypeBytes)
+		body, _ := json.Marshal(map[string]string{"error": "Invalid x-mock-respond-with", "detail": err.Error()})
+		ctx.SetBody(body)
+		return true
+	}
+
+	if inline.Delay > 0 {
+		time.Sleep(time.Duration(inline.Delay * float64(time.Second)))
+	}
+
+	ctx.SetStatusCode(inline.Status)
+	var text string
+	switch {
+	case len(inline.Body) == 0 || bytes.Equal(inline.Body, []byte("null")):
+		ctx.Response.Header.SetBytesKV(headerContentType, defaultContentTypeBytes)
+	case json.Unmarshal(inline.Body, &text) == nil:
+		ctx.Response.Header.SetBytesKV(headerContentType, textContentTypeBytes)
+		ctx.SetBodyString(text)
+	default:
+		ctx.Response.Header.SetBytesKV(headerContentType, defaultContentTypeBytes)
+		var compact bytes.Buffer
+		json.Compact(&compact, inline.Body)
+		ctx.SetBody(compact.Bytes())
+	}
+	return true
+}
+
+// parseRespondWith decodes and checks an x-mock-respond-with header.
+func parseRespondWith(header []byte, inline *respondWith) error {
+	decoder := json.NewDecoder(bytes.NewReader(header))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(inline); err != nil {
+		return fmt.Errorf(`expected {"status", "body", "delay"}: %v`, err)
+	}
+	if inline.Status == 0 {
+		inline.Status = fasthttp.StatusOK
+	}
+	if inline.Status < 100 || inline.Status > 999 {
+		return fmt.Errorf("status %d is not an HTTP status code", inline.Status)
+	}
+	if inline.Delay < 0 {
+		return fmt.Errorf("delay must not be negative")
+	}
+	return nil
+}
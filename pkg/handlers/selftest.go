@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+// templateErrorMarker is how Template.Render reports a failing placeholder inline.
+var templateErrorMarker = []byte("<template error:")
+
+// SelfTestFailure is a response that could not be served cleanly.
+type SelfTestFailure struct {
+	Scenario  string `json:"scenario,omitempty"`
+	MockID    string `json:"mock_id"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	RequestID string `json:"request_id"`
+	Error     string `json:"error"`
+}
+
+// SelfTestReport summarizes a self-test run.
+type SelfTestReport struct {
+	Served   int               `json:"served"`
+	Skipped  int               `json:"skipped"` // WebSocket, gRPC, SSE abort and keep-open responses, which need a live connection, and faulty responses
+	Failures []SelfTestFailure `json:"failures"`
+}
+
+// SelfTest serves every response of store.SelfTestTargets once to a synthetic
+// request built from its recorded method and URL, and reports the ones whose
+// output is broken: template errors, invalid JSON bodies or invalid statuses.
+// Matching is not exercised (POST /__mock__/evaluate covers that), and
+// nothing is logged or counted. Run it with timing replay disabled.
+func SelfTest(store *storage.MockStorage) *SelfTestReport {
+	report := &SelfTestReport{Failures: []SelfTestFailure{}}
+	for _, target := range store.SelfTestTargets() {
+		resp := target.Response
+		if resp.IsWebSocket || resp.IsGRPC || (resp.IsSSE && (resp.SSEAbort != nil || resp.SSEKeepOpen)) || resp.Fault != nil {
+			report.Skipped++
+			continue
+		}
+
+		report.Served++
+		if problem := selfTestResponse(store, resp); problem != "" {
+			report.Failures = append(report.Failures, SelfTestFailure{
+				Scenario:  target.Scenario,
+				MockID:    resp.MockID,
+				Method:    resp.Method,
+				Path:      resp.Path,
+				RequestID: resp.RequestID,
+				Error:     problem,
+			})
+		}
+	}
+	return report
+}
+
+// selfTestResponse serves resp to a synthetic request and returns what is
+// wrong with the output, or "" when it is fine.
+func selfTestResponse(store *storage.MockStorage, resp *storage.MockResponse) (problem string) {
+	ctx := &fasthttp.RequestCtx{}
+	uri := resp.Path
+	if parsed, err := url.Parse(resp.FullURL); err == nil && parsed.RawQuery != "" {
+		uri += "?" + parsed.RawQuery
+	}
+	ctx.Request.SetRequestURI(uri)
+	ctx.Request.Header.SetMethod(resp.Method)
+	ctx.Request.Header.Set("x-mock-id", resp.MockID)
+	if resp.ContentType != "" {
+		ctx.Request.Header.Set("Accept", resp.ContentType)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			problem = fmt.Sprintf("panic while serving: %v", r)
+		}
+	}()
+	serveMock(ctx, store, resp, nil)
+
+	status := ctx.Response.StatusCode()
+	if resp.StatusCode < 100 || resp.StatusCode > 599 {
+		return fmt.Sprintf("invalid status code %d", resp.StatusCode)
+	}
+	if status != resp.StatusCode {
+		return fmt.Sprintf("served status %d instead of %d", status, resp.StatusCode)
+	}
+
+	var rendered []byte
+	ctx.Response.Header.VisitAll(func(key, value []byte) {
+		if rendered == nil && bytes.Contains(value, templateErrorMarker) {
+			rendered = key
+		}
+	})
+	if rendered != nil {
+		return fmt.Sprintf("template error in header %s", rendered)
+	}
+
+	body := ctx.Response.Body()
+	if bytes.Contains(body, templateErrorMarker) {
+		return "template error in body"
+	}
+	// Rendered templates depend on the request, so only recorded bodies must be valid JSON
+	if resp.BodyTemplate == nil && !resp.IsSSE && len(body) > 0 && isJSONContentType(resp.ContentType) && !json.Valid(body) {
+		return "body is not valid JSON"
+	}
+	return ""
+}
+
+// isJSONContentType reports whether contentType is application/json or a +json type.
+func isJSONContentType(contentType string) bool {
+	return contentType == "application/json" || strings.HasSuffix(contentType, "+json")
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"time"
+)
+
+var (
+	crlf = []byte("\r\n")
+)
+
+// AbortTo streams SSE events over a hijacked connection using chunked encoding
+// and drops the connection at the configured point without the terminating
+// chunk, so clients observe an unexpected end of stream.
+func (sw *sseStreamWriter) AbortTo(conn net.Conn) {
+	defer sw.release()
+
+	w := bufio.NewWriter(conn)
+	w.Write(sw.head)
+	if err := w.Flush(); err != nil {
+		return
+	}
+
+	abort := sw.abort
+	startTime := time.Now()
+	previousTimestamp := 0.0
+	offset := 0.0
+
+	for i := range sw.events {
+		event := &sw.events[i]
+		offset += sw.nextGap(event, &previousTimestamp)
+
+		timeCut := abort.AfterSeconds > 0 && offset > abort.AfterSeconds
+		if i == abort.AfterEvents || timeCut {
+			if timeCut && !sw.instant {
+				time.Sleep(time.Until(startTime.Add(time.Duration(abort.AfterSeconds * float64(time.Second)))))
+			}
+			if abort.BrokenChunk {
+				writeChunkHeader(w, len(event.Frame))
+				w.Write(event.Frame[:len(event.Frame)/2])
+				w.Flush()
+			}
+			return
+		}
+
+		if !sw.instant {
+			time.Sleep(time.Until(startTime.Add(time.Duration(offset * float64(time.Second)))))
+		}
+
+		writeChunkHeader(w, len(event.Frame))
+		w.Write(event.Frame)
+		w.Write(crlf)
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+
+	// Every event fit before the cut point: close without the final zero-length chunk
+	if !sw.instant && abort.AfterSeconds > offset {
+		time.Sleep(time.Until(startTime.Add(time.Duration(abort.AfterSeconds * float64(time.Second)))))
+	}
+}
+
+// writeChunkHeader writes the hex size line that starts an HTTP/1.1 chunk.
+func writeChunkHeader(w *bufio.Writer, size int) {
+	var buf [16]byte
+	w.Write(strconv.AppendInt(buf[:0], int64(size), 16))
+	w.Write(crlf)
+}
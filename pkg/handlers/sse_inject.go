@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"time"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+// sseKeepAliveInterval is how often a held-open stream sends a comment so
+// disconnected clients are noticed and intermediaries keep the connection.
+const sseKeepAliveInterval = 15 * time.Second
+
+var (
+	ssePathPrefix    = []byte("/__mock__/sse/")
+	sseEmitSuffix    = []byte("/emit")
+	sseKeepAlive     = []byte(": keep-alive\n\n")
+	errorEmptyStream = []byte(`{"error":"Stream name is required"}`)
+)
+
+// wait sleeps for d while forwarding injected events. It returns false when the
+// stream should end, either because the client went away or the hub closed.
+func (sw *sseStreamWriter) wait(w *bufio.Writer, d time.Duration) bool {
+	if sw.inbox == nil {
+		time.Sleep(d)
+		return true
+	}
+	if d <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case frame, ok := <-sw.inbox:
+			if !ok {
+				return false
+			}
+			w.Write(frame)
+			if err := w.Flush(); err != nil {
+				return false
+			}
+		}
+	}
+}
+
+// hold keeps a stream open after the recorded events, forwarding injected
+// events until the client disconnects or the hub closes.
+func (sw *sseStreamWriter) hold(w *bufio.Writer) {
+	if sw.inbox == nil {
+		return
+	}
+
+	ticker := time.NewTicker(sseKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		var frame []byte
+		select {
+		case injected, ok := <-sw.inbox:
+			if !ok {
+				return
+			}
+			frame = injected
+		case <-ticker.C:
+			frame = sseKeepAlive
+		}
+
+		w.Write(frame)
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// EmitHandler pushes the request body as an SSE event into every connected
+// stream of the mock named in the path (/__mock__/sse/{stream}/emit). The
+// optional event and id query parameters become the event's type and id.
+func EmitHandler(store *storage.MockStorage) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		path := ctx.Path()
+		stream := string(path[len(ssePathPrefix) : len(path)-len(sseEmitSuffix)])
+		if stream == "" {
+			ctx.SetStatusCode(fasthttp.StatusBadRequest)
+			ctx.SetContentType(defaultContentType)
+			ctx.SetBody(errorEmptyStream)
+			return
+		}
+
+		var frame []byte
+		if event := ctx.QueryArgs().Peek("event"); len(event) > 0 {
+			frame = append(frame, "event: "...)
+			frame = append(frame, event...)
+			frame = append(frame, '\n')
+		}
+		if id := ctx.QueryArgs().Peek("id"); len(id) > 0 {
+			frame = append(frame, "id: "...)
+			frame = append(frame, id...)
+			frame = append(frame, '\n')
+		}
+		frame = storage.AppendSSEFrame(frame, ctx.PostBody())
+
+		delivered := store.SSEHub().Emit(stream, frame)
+
+		ctx.SetContentType(defaultContentType)
+		body := []byte(`{"stream":`)
+		body = strconv.AppendQuote(body, stream)
+		body = append(body, `,"delivered":`...)
+		body = strconv.AppendInt(body, int64(delivered), 10)
+		body = append(body, '}')
+		ctx.SetBody(body)
+	}
+}
+
+// isEmitPath reports whether path addresses the event injection endpoint.
+func isEmitPath(path []byte) bool {
+	return len(path) >= len(ssePathPrefix)+len(sseEmitSuffix) &&
+		bytes.HasPrefix(path, ssePathPrefix) &&
+		bytes.HasSuffix(path, sseEmitSuffix)
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	headerUpgrade        = []byte("Upgrade")
+	headerWebSocketKey   = []byte("Sec-WebSocket-Key")
+	websocketContentType = []byte(storage.WebSocketContentType)
+	upgradeWebSocket     = []byte("websocket")
+	errorUpgradeRequired = []byte(`{"error":"WebSocket upgrade required"}`)
+	errorWebSocketKey    = []byte(`{"error":"Missing Sec-WebSocket-Key"}`)
+)
+
+// websocketCloseWait bounds how long a replay waits for the client's close
+// frame after the server side sent its own.
+const websocketCloseWait = time.Second
+
+// websocketReplayHeaders are recorded handshake headers that are regenerated
+// or not applicable when the conversation is replayed.
+var websocketReplayHeaders = map[string]bool{
+	"sec-websocket-accept":     true,
+	"sec-websocket-extensions": true, // Frames are replayed uncompressed
+	"upgrade":                  true,
+	"connection":               true,
+	"content-length":           true,
+	"transfer-encoding":        true,
+	"x-mock-id":                true,
+}
+
+// isWebSocketUpgrade reports whether the request asks to switch to the WebSocket protocol.
+func isWebSocketUpgrade(ctx *fasthttp.RequestCtx) bool {
+	return ctx.Request.Header.ConnectionUpgrade() &&
+		bytes.EqualFold(ctx.Request.Header.PeekBytes(headerUpgrade), upgradeWebSocket)
+}
+
+// websocketReplay plays a recorded conversation back over a hijacked connection.
+type websocketReplay struct {
+	frames  []storage.WebSocketFrame
+	head    []byte  // 101 response written before the first frame
+	instant bool    // Ignore recorded timing
+	scale   float64 // Jitter applied to all recorded offsets
+	limiter *storage.ConcurrencyLimiter
+
+	writeMutex sync.Mutex
+}
+
+// serveWebSocket completes the handshake for a recorded WebSocket mock and
+// replays its frames. Requests that are not upgrades get 426. It reports
+// whether the connection was taken over, in which case the replay owns the limiter slot.
+func serveWebSocket(ctx *fasthttp.RequestCtx, mockResponse *storage.MockResponse, replay *websocketReplay) bool {
+	if !isWebSocketUpgrade(ctx) {
+		ctx.SetStatusCode(fasthttp.StatusUpgradeRequired)
+		ctx.Response.Header.SetBytesKV(headerUpgrade, upgradeWebSocket)
+		ctx.Response.Header.SetContentType(defaultContentType)
+		ctx.SetBody(errorUpgradeRequired)
+		return false
+	}
+	key := ctx.Request.Header.PeekBytes(headerWebSocketKey)
+	if len(key) == 0 {
+		ctx.SetStatusCode(fasthttp.StatusBadRequest)
+		ctx.Response.Header.SetContentType(defaultContentType)
+		ctx.SetBody(errorWebSocketKey)
+		return false
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusSwitchingProtocols)
+	for keyLower, name := range mockResponse.HeaderKeysLower {
+		if !websocketReplayHeaders[keyLower] {
+			ctx.Response.Header.Set(name, mockResponse.Headers[name])
+		}
+	}
+	ctx.Response.Header.Set("Upgrade", "websocket")
+	ctx.Response.Header.Set("Connection", "Upgrade")
+	ctx.Response.Header.Set("Sec-WebSocket-Accept", storage.WebSocketAccept(string(key)))
+	replay.head = append(replay.head[:0], ctx.Response.Header.Header()...)
+
+	ctx.HijackSetNoResponse(true)
+	ctx.Hijack(replay.ServeConn)
+	return true
+}
+
+// ServeConn writes the handshake and replays the server frames. A server frame
+// that followed a client message in the recording waits until the client has
+// sent a message, then keeps the recorded gap. Pings are answered with pongs.
+// The connection ends the way the recording did: after the server's close frame,
+// after answering the client's close, or by dropping when the recording just stops.
+func (r *websocketReplay) ServeConn(conn net.Conn) {
+	if r.limiter != nil {
+		defer r.limiter.Release()
+	}
+	// Unblock the client reader once the replay is over
+	defer conn.SetDeadline(time.Now())
+
+	if !r.write(conn, r.head) {
+		return
+	}
+
+	incoming := make(chan storage.WebSocketFrame, 16)
+	done := make(chan struct{})
+	defer close(done)
+	go r.readClient(conn, incoming, done)
+
+	anchor := time.Now()
+	anchorOffset := 0.0
+	for _, frame := range r.frames {
+		if frame.FromClient {
+			if frame.Opcode == storage.WebSocketPing || frame.Opcode == storage.WebSocketPong {
+				continue
+			}
+			received, ok := <-incoming
+			if !ok {
+				return
+			}
+			if received.Opcode == storage.WebSocketClose && frame.Opcode != storage.WebSocketClose {
+				// The client is leaving earlier than in the recording
+				r.write(conn, storage.AppendWebSocketFrame(nil, storage.WebSocketClose, true, received.Payload))
+				return
+			}
+			anchor, anchorOffset = time.Now(), frame.Timestamp
+			continue
+		}
+
+		if !r.instant {
+			wait := time.Duration((frame.Timestamp - anchorOffset) * r.scale * float64(time.Second))
+			time.Sleep(time.Until(anchor.Add(wait)))
+		}
+		if !r.write(conn, storage.AppendWebSocketFrame(nil, frame.Opcode, frame.Fin, frame.Payload)) {
+			return
+		}
+
+		if frame.Opcode == storage.WebSocketClose {
+			r.awaitClose(incoming)
+			return
+		}
+	}
+}
+
+// readClient forwards client frames to incoming, answering pings directly.
+// It stops when the replay is done or the client goes away.
+func (r *websocketReplay) readClient(conn net.Conn, incoming chan<- storage.WebSocketFrame, done <-chan struct{}) {
+	defer close(incoming)
+	br := bufio.NewReader(conn)
+	for {
+		frame, _, err := storage.ReadWebSocketFrame(br)
+		if err != nil {
+			return
+		}
+		switch frame.Opcode {
+		case storage.WebSocketPing:
+			r.write(conn, storage.AppendWebSocketFrame(nil, storage.WebSocketPong, true, frame.Payload))
+		case storage.WebSocketPong:
+		default:
+			select {
+			case incoming <- frame:
+			case <-done:
+				return
+			}
+			if frame.Opcode == storage.WebSocketClose {
+				return
+			}
+		}
+	}
+}
+
+// awaitClose gives the client a moment to answer the server's close frame.
+func (r *websocketReplay) awaitClose(incoming <-chan storage.WebSocketFrame) {
+	timer := time.NewTimer(websocketCloseWait)
+	defer timer.Stop()
+	for {
+		select {
+		case frame, ok := <-incoming:
+			if !ok || frame.Opcode == storage.WebSocketClose {
+				return
+			}
+		case <-timer.C:
+			return
+		}
+	}
+}
+
+func (r *websocketReplay) write(conn net.Conn, data []byte) bool {
+	r.writeMutex.Lock()
+	defer r.writeMutex.Unlock()
+	_, err := conn.Write(data)
+	return err == nil
+}
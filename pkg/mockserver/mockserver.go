@@ -0,0 +1,274 @@
+// Package mockserver runs the mock server inside a Go program, typically a
+// test, without the auto-mock-server binary:
+//
+//	func TestClient(t *testing.T) {
+//		baseURL := mockserver.New("testdata/mocks").Start(t)
+//		client := api.NewClient(baseURL)
+//		...
+//	}
+//
+// The server listens on a random loopback port and is shut down by t.Cleanup.
+package mockserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/handlers"
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+// Option configures a Server before it starts.
+type Option func(*Server)
+
+// WithScenarioConfig loads a scenario YAML file (like -mock-config).
+func WithScenarioConfig(path string) Option {
+	return func(s *Server) { s.scenarioConfig = path }
+}
+
+// WithReplayTiming replays recorded delays with the given jitter (like
+// -replay-timing and -jitter).
+func WithReplayTiming(jitter float64) Option {
+	return func(s *Server) {
+		s.replayTiming = true
+		s.jitter = jitter
+	}
+}
+
+// WithStrict fails the test at cleanup when any request had no mock (like -strict).
+func WithStrict() Option {
+	return func(s *Server) { s.strict = true }
+}
+
+// WithLogDir logs requests without a mock to dir (like -log-dir).
+func WithLogDir(dir string) Option {
+	return func(s *Server) { s.logDir = dir }
+}
+
+// WithStorage lets the caller configure the loaded storage before the server
+// starts, for settings without a dedicated option.
+func WithStorage(configure func(*storage.MockStorage) error) Option {
+	return func(s *Server) { s.configure = append(s.configure, configure) }
+}
+
+// Server is an in-process mock server serving the recordings of a directory
+// plus mocks registered in code.
+type Server struct {
+	dir            string
+	scenarioConfig string
+	replayTiming   bool
+	jitter         float64
+	strict         bool
+	logDir         string
+	configure      []func(*storage.MockStorage) error
+
+	mutex   sync.Mutex
+	store   *storage.MockStorage
+	pending [][]byte // Records registered before Start
+	server  *fasthttp.Server
+	url     string
+}
+
+// New creates a server for the recordings in dir; an empty or missing dir
+// starts with no mocks.
+func New(dir string, opts ...Option) *Server {
+	s := &Server{dir: dir}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Start loads the mocks, serves them on a random loopback port and returns the
+// base URL (http://127.0.0.1:<port>). The server is closed by t.Cleanup; any
+// setup error fails the test.
+func (s *Server) Start(t testing.TB) string {
+	t.Helper()
+	baseURL, err := s.start()
+	if err != nil {
+		t.Fatalf("mockserver: %v", err)
+	}
+	t.Cleanup(func() {
+		if s.strict {
+			if unmatched := s.store.Unmatched(); unmatched != nil && unmatched.Total() > 0 {
+				for _, entry := range unmatched.Summary() {
+					t.Errorf("mockserver: no mock for %s %s (%d requests)", entry.Method, entry.Path, entry.Count)
+				}
+			}
+		}
+		s.Close()
+	})
+	return baseURL
+}
+
+func (s *Server) start() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.server != nil {
+		return "", fmt.Errorf("server already started")
+	}
+
+	store, err := storage.NewMockStorage(s.dir)
+	if err != nil {
+		return "", fmt.Errorf("load mocks: %w", err)
+	}
+	if s.scenarioConfig != "" {
+		if err := store.LoadScenarioConfig(s.scenarioConfig); err != nil {
+			return "", fmt.Errorf("load scenario config: %w", err)
+		}
+	}
+	store.SetTimingConfig(s.replayTiming, s.jitter)
+	store.SetStrict(s.strict)
+	for _, configure := range s.configure {
+		if err := configure(store); err != nil {
+			return "", err
+		}
+	}
+	for _, record := range s.pending {
+		if err := store.AddRecord(record, "default"); err != nil {
+			return "", fmt.Errorf("register mock: %w", err)
+		}
+	}
+	s.pending = nil
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	s.store = store
+	s.server = &fasthttp.Server{
+		Handler:      handlers.Router(store, s.logDir),
+		Name:         "AutoMockServer",
+		ErrorHandler: handlers.RequestErrorHandler,
+	}
+	s.url = "http://" + listener.Addr().String()
+	if s.replayTiming {
+		listener = handlers.ReceiptListener(listener)
+	}
+	go s.server.Serve(listener)
+	return s.url, nil
+}
+
+// URL returns the base URL, or "" before Start.
+func (s *Server) URL() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.url
+}
+
+// Storage returns the loaded mocks, or nil before Start.
+func (s *Server) Storage() *storage.MockStorage {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.store
+}
+
+// Close stops the server. It is called by the Start cleanup and is safe to
+// call more than once.
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	server := s.server
+	s.server = nil
+	s.mutex.Unlock()
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown()
+}
+
+// Mock is a response registered in code. Zero values select GET, the
+// "default" mock ID and status 200.
+type Mock struct {
+	Method  string
+	Path    string // May include a query, which is ignored for matching like in recordings
+	MockID  string
+	Status  int
+	Headers map[string]string
+	Body    interface{}   // string or []byte served as is, or any value encoded as JSON
+	Delay   time.Duration // Served with WithReplayTiming, like a recorded delay
+}
+
+// AddMock registers a mock. Before Start it is served from the start; after
+// Start it is served from the next request.
+func (s *Server) AddMock(mock Mock) error {
+	record, err := mock.record()
+	if err != nil {
+		return err
+	}
+	return s.AddRecord(record)
+}
+
+// AddRecord registers a recording in the native JSON format, as written by
+// auto-proxy. Records added before Start are parsed by Start.
+func (s *Server) AddRecord(record []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.store == nil {
+		s.pending = append(s.pending, record)
+		return nil
+	}
+	return s.store.AddRecord(record, "default")
+}
+
+// record renders the mock in the native recording format.
+func (m Mock) record() ([]byte, error) {
+	if !strings.HasPrefix(m.Path, "/") {
+		return nil, fmt.Errorf("mock path %q must start with /", m.Path)
+	}
+	method := strings.ToUpper(m.Method)
+	if method == "" {
+		method = "GET"
+	}
+	status := m.Status
+	if status == 0 {
+		status = 200
+	}
+
+	headers := map[string]string{}
+	contentType := ""
+	for key, value := range m.Headers {
+		headers[key] = value
+		if strings.EqualFold(key, "content-type") {
+			contentType = value
+		}
+	}
+	body := m.Body
+	if data, ok := body.([]byte); ok {
+		body = string(data)
+	}
+	if body == nil {
+		body = ""
+	}
+	if contentType == "" {
+		contentType = "application/json"
+		if _, isText := body.(string); isText {
+			contentType = "text/plain"
+		}
+		headers["Content-Type"] = contentType
+	}
+
+	requestHeaders := map[string]string{}
+	if m.MockID != "" {
+		requestHeaders["x-mock-id"] = m.MockID
+	}
+	return json.Marshal(map[string]interface{}{
+		"request": map[string]interface{}{
+			"method":  method,
+			"url":     "http://localhost" + m.Path,
+			"headers": requestHeaders,
+			"body":    "",
+		},
+		"response": map[string]interface{}{
+			"status_code": status,
+			"headers":     headers,
+			"body":        body,
+			"delay":       m.Delay.Seconds(),
+		},
+	})
+}
@@ -0,0 +1,88 @@
+package mockserver
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func get(t *testing.T, url string, headers map[string]string) (int, string, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, resp.Header.Get("Content-Type"), string(body)
+}
+
+func TestServerServesRecordingsAndRegisteredMocks(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "default"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	record := `{"request": {"method": "GET", "url": "http://api/users"},
+		"response": {"status_code": 200, "headers": {"Content-Type": "application/json"}, "body": [{"id": 1}]}}`
+	if err := os.WriteFile(filepath.Join(dir, "default", "users.json"), []byte(record), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := New(dir)
+	if err := server.AddMock(Mock{Path: "/health", Body: "ok"}); err != nil {
+		t.Fatalf("AddMock failed: %v", err)
+	}
+	baseURL := server.Start(t)
+
+	if status, _, body := get(t, baseURL+"/users", nil); status != 200 || body != `[{"id":1}]` {
+		t.Fatalf("expected the recording, got %d %s", status, body)
+	}
+	if status, contentType, body := get(t, baseURL+"/health", map[string]string{"Accept": "text/plain"}); status != 200 || body != "ok" || contentType != "text/plain" {
+		t.Fatalf("expected the mock registered before Start, got %d %s %q", status, contentType, body)
+	}
+
+	// Registered after Start, under its own mock ID
+	err := server.AddMock(Mock{Method: "get", Path: "/users", MockID: "empty", Status: 404,
+		Body: map[string]string{"error": "none"}})
+	if err != nil {
+		t.Fatalf("AddMock failed: %v", err)
+	}
+	status, contentType, body := get(t, baseURL+"/users", map[string]string{"x-mock-id": "empty"})
+	if status != 404 || body != `{"error":"none"}` || contentType != "application/json" {
+		t.Fatalf("expected the mock registered after Start, got %d %s %s", status, contentType, body)
+	}
+
+	if err := server.AddMock(Mock{Path: "users"}); err == nil {
+		t.Error("expected an error for a relative path")
+	}
+}
+
+func TestServerCloses(t *testing.T) {
+	server := New("")
+	baseURL := server.Start(t)
+	if server.URL() != baseURL || server.Storage() == nil {
+		t.Fatalf("expected URL and storage after Start")
+	}
+	if status, _, _ := get(t, baseURL+"/missing", nil); status != 404 {
+		t.Fatalf("expected 404 without mocks, got %d", status)
+	}
+	if err := server.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := http.Get(baseURL + "/missing"); err == nil {
+		t.Fatal("expected the server to be closed")
+	}
+	// The cleanup registered by Start closes again
+	if err := server.Close(); err != nil {
+		t.Fatalf("second Close failed: %v", err)
+	}
+}
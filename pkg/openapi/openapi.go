@@ -0,0 +1,497 @@
+// Package openapi synthesizes an OpenAPI 3 document from recorded mocks, so a
+// mock directory doubles as documentation of the API it was recorded from.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+)
+
+// Version is the OpenAPI version of generated documents.
+const Version = "3.0.3"
+
+// Document is an OpenAPI 3.0 document, limited to what recordings can tell
+// and what request validation needs.
+type Document struct {
+	OpenAPI    string               `json:"openapi" yaml:"openapi"`
+	Info       Info                 `json:"info" yaml:"info"`
+	Servers    []Server             `json:"servers,omitempty" yaml:"servers,omitempty"`
+	Paths      map[string]*PathItem `json:"paths" yaml:"paths"`
+	Components *Components          `json:"components,omitempty" yaml:"components,omitempty"`
+}
+
+// Server is a server object; only the path of its URL is used, as a base path.
+type Server struct {
+	URL string `json:"url" yaml:"url"`
+}
+
+// Components holds the reusable objects $ref can point to.
+type Components struct {
+	Schemas       map[string]*Schema      `json:"schemas,omitempty" yaml:"schemas,omitempty"`
+	Parameters    map[string]*Parameter   `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBodies map[string]*RequestBody `json:"requestBodies,omitempty" yaml:"requestBodies,omitempty"`
+}
+
+// Info is the document's info object.
+type Info struct {
+	Title       string `json:"title" yaml:"title"`
+	Version     string `json:"version" yaml:"version"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// PathItem holds the operations of one path. Path parameters shared by all
+// operations are declared once here.
+type PathItem struct {
+	Parameters []*Parameter `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	Get        *Operation   `json:"get,omitempty" yaml:"get,omitempty"`
+	Put        *Operation   `json:"put,omitempty" yaml:"put,omitempty"`
+	Post       *Operation   `json:"post,omitempty" yaml:"post,omitempty"`
+	Delete     *Operation   `json:"delete,omitempty" yaml:"delete,omitempty"`
+	Options    *Operation   `json:"options,omitempty" yaml:"options,omitempty"`
+	Head       *Operation   `json:"head,omitempty" yaml:"head,omitempty"`
+	Patch      *Operation   `json:"patch,omitempty" yaml:"patch,omitempty"`
+	Trace      *Operation   `json:"trace,omitempty" yaml:"trace,omitempty"`
+}
+
+// Operation describes one method on a path.
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty" yaml:"operationId,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty" yaml:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses" yaml:"responses"`
+}
+
+// Parameter is a path, query or header parameter.
+type Parameter struct {
+	Ref      string  `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Name     string  `json:"name,omitempty" yaml:"name,omitempty"`
+	In       string  `json:"in,omitempty" yaml:"in,omitempty"`
+	Required bool    `json:"required,omitempty" yaml:"required,omitempty"`
+	Schema   *Schema `json:"schema,omitempty" yaml:"schema,omitempty"`
+}
+
+// RequestBody describes the request bodies of an operation.
+type RequestBody struct {
+	Ref      string                `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Required bool                  `json:"required,omitempty" yaml:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+// Response describes the recorded responses with one status code.
+type Response struct {
+	Description string                `json:"description" yaml:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+// MediaType holds the schema inferred for one content type.
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty" yaml:"schema,omitempty"`
+}
+
+// Schema is the subset of the OpenAPI schema object that inference produces
+// and validation checks. An empty schema accepts any value; keywords not
+// listed here are ignored.
+type Schema struct {
+	Ref        string             `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Type       string             `json:"type,omitempty" yaml:"type,omitempty"`
+	Format     string             `json:"format,omitempty" yaml:"format,omitempty"`
+	Nullable   bool               `json:"nullable,omitempty" yaml:"nullable,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty" yaml:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty" yaml:"required,omitempty"`
+	Items      *Schema            `json:"items,omitempty" yaml:"items,omitempty"`
+
+	Enum      []interface{} `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Minimum   *float64      `json:"minimum,omitempty" yaml:"minimum,omitempty"`
+	Maximum   *float64      `json:"maximum,omitempty" yaml:"maximum,omitempty"`
+	MinLength *int          `json:"minLength,omitempty" yaml:"minLength,omitempty"`
+	MaxLength *int          `json:"maxLength,omitempty" yaml:"maxLength,omitempty"`
+	Pattern   string        `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	MinItems  *int          `json:"minItems,omitempty" yaml:"minItems,omitempty"`
+	MaxItems  *int          `json:"maxItems,omitempty" yaml:"maxItems,omitempty"`
+	AllOf     []*Schema     `json:"allOf,omitempty" yaml:"allOf,omitempty"`
+	AnyOf     []*Schema     `json:"anyOf,omitempty" yaml:"anyOf,omitempty"`
+	OneOf     []*Schema     `json:"oneOf,omitempty" yaml:"oneOf,omitempty"`
+
+	pattern *regexp.Regexp // Compiled Pattern, set by NewValidator
+}
+
+// Generate builds a document from every mock in store. Path segments that
+// look like identifiers (numbers, UUIDs) become {id} parameters so recordings
+// of /users/1 and /users/2 describe one operation; schemas are merged across
+// all recordings of an operation, and a property is required when every
+// sample has it. WebSocket and gRPC recordings are left out.
+func Generate(store *storage.MockStorage, info Info) *Document {
+	doc := &Document{OpenAPI: Version, Info: info, Paths: map[string]*PathItem{}}
+
+	mocks := store.ListAllMocks()
+	sort.Slice(mocks, func(i, j int) bool {
+		return mocks[i].FullURL+mocks[i].RequestID < mocks[j].FullURL+mocks[j].RequestID
+	})
+
+	for _, mock := range mocks {
+		if mock.IsWebSocket || mock.IsGRPC {
+			continue
+		}
+		template, params := templatePath(mock.Path)
+		item := doc.Paths[template]
+		if item == nil {
+			item = &PathItem{}
+			for _, name := range params {
+				item.Parameters = append(item.Parameters, &Parameter{
+					Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"},
+				})
+			}
+			doc.Paths[template] = item
+		}
+
+		op := item.operation(mock.Method)
+		if op == nil {
+			continue
+		}
+		if op.OperationID == "" {
+			op.OperationID = operationID(mock.Method, template)
+		}
+		addQueryParameters(op, mock.FullURL)
+		addRequestBody(op, mock.RequestBody)
+		addResponse(op, mock)
+	}
+
+	for _, item := range doc.Paths {
+		for _, op := range item.operations() {
+			if op.RequestBody != nil {
+				for _, media := range op.RequestBody.Content {
+					complete(media.Schema)
+				}
+			}
+			for _, response := range op.Responses {
+				for _, media := range response.Content {
+					complete(media.Schema)
+				}
+			}
+		}
+	}
+	return doc
+}
+
+// complete gives arrays that were only ever recorded empty an any-items
+// schema, as OpenAPI requires items on every array.
+func complete(schema *Schema) {
+	if schema == nil {
+		return
+	}
+	if schema.Type == "array" && schema.Items == nil {
+		schema.Items = &Schema{}
+	}
+	complete(schema.Items)
+	for _, property := range schema.Properties {
+		complete(property)
+	}
+}
+
+// operation returns the operation for method, creating it; nil for methods
+// OpenAPI has no field for.
+func (item *PathItem) operation(method string) *Operation {
+	var slot **Operation
+	switch strings.ToUpper(method) {
+	case http.MethodGet:
+		slot = &item.Get
+	case http.MethodPut:
+		slot = &item.Put
+	case http.MethodPost:
+		slot = &item.Post
+	case http.MethodDelete:
+		slot = &item.Delete
+	case http.MethodOptions:
+		slot = &item.Options
+	case http.MethodHead:
+		slot = &item.Head
+	case http.MethodPatch:
+		slot = &item.Patch
+	case http.MethodTrace:
+		slot = &item.Trace
+	default:
+		return nil
+	}
+	if *slot == nil {
+		*slot = &Operation{Responses: map[string]*Response{}}
+	}
+	return *slot
+}
+
+// templatePath replaces identifier-like segments with {id}, {id2}, ... and
+// returns the parameter names.
+func templatePath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, segment := range segments {
+		if !isIdentifier(segment) {
+			continue
+		}
+		name := "id"
+		if len(params) > 0 {
+			name += strconv.Itoa(len(params) + 1)
+		}
+		params = append(params, name)
+		segments[i] = "{" + name + "}"
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// isIdentifier reports whether a path segment is a number or a UUID.
+func isIdentifier(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	if _, err := strconv.ParseUint(segment, 10, 64); err == nil {
+		return true
+	}
+	if len(segment) != 36 {
+		return false
+	}
+	for i, r := range segment {
+		switch i {
+		case 8, 13, 18, 23:
+			if r != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// operationID derives a stable identifier such as get_users_id.
+func operationID(method, template string) string {
+	id := strings.ToLower(method)
+	for _, segment := range strings.Split(template, "/") {
+		segment = strings.Trim(segment, "{}")
+		if segment == "" {
+			continue
+		}
+		id += "_" + strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+				return r
+			}
+			return '_'
+		}, segment)
+	}
+	return id
+}
+
+// addQueryParameters declares the query parameters seen in a recorded URL.
+func addQueryParameters(op *Operation, fullURL string) {
+	parsed, err := url.Parse(fullURL)
+	if err != nil {
+		return
+	}
+	names := make([]string, 0, len(parsed.Query()))
+	for name := range parsed.Query() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		known := false
+		for _, param := range op.Parameters {
+			known = known || param.Name == name
+		}
+		if !known {
+			op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "query", Schema: &Schema{Type: "string"}})
+		}
+	}
+}
+
+// addRequestBody merges a recorded request body into the operation.
+func addRequestBody(op *Operation, body interface{}) {
+	contentType := "application/json"
+	switch typed := body.(type) {
+	case nil:
+		return
+	case string:
+		if typed == "" {
+			return
+		}
+		contentType = "text/plain"
+	case map[string]interface{}:
+		// Streamed uploads are recorded as a placeholder, not their content
+		if streamed, _ := typed["streamed"].(bool); streamed {
+			contentType, body = "application/octet-stream", ""
+		}
+	}
+	if op.RequestBody == nil {
+		op.RequestBody = &RequestBody{Content: map[string]*MediaType{}}
+	}
+	mergeMedia(op.RequestBody.Content, contentType, body)
+}
+
+// addResponse merges a recorded response into the operation.
+func addResponse(op *Operation, mock *storage.MockResponse) {
+	status := strconv.Itoa(mock.StatusCode)
+	response := op.Responses[status]
+	if response == nil {
+		description := http.StatusText(mock.StatusCode)
+		if description == "" {
+			description = "Status " + status
+		}
+		response = &Response{Description: description}
+		op.Responses[status] = response
+	}
+
+	contentType := mock.ContentType
+	if contentType == "" || len(mock.Body) == 0 && !mock.IsSSE {
+		return
+	}
+	if response.Content == nil {
+		response.Content = map[string]*MediaType{}
+	}
+	var body interface{} = ""
+	if isJSON(contentType) && !mock.IsSSE {
+		if err := json.Unmarshal(mock.Body, &body); err != nil {
+			body = ""
+		}
+	}
+	mergeMedia(response.Content, contentType, body)
+}
+
+// mergeMedia merges a sample into the schema for contentType.
+func mergeMedia(content map[string]*MediaType, contentType string, sample interface{}) {
+	schema := Infer(sample)
+	if !isJSON(contentType) {
+		schema = &Schema{Type: "string"}
+		if contentType == "application/octet-stream" {
+			schema.Format = "binary"
+		}
+	}
+	if media := content[contentType]; media != nil {
+		media.Schema = Merge(media.Schema, schema)
+		return
+	}
+	content[contentType] = &MediaType{Schema: schema}
+}
+
+// isJSON reports whether contentType is application/json or a +json type.
+func isJSON(contentType string) bool {
+	return contentType == "application/json" || strings.HasSuffix(contentType, "+json")
+}
+
+// Infer returns the schema of a decoded JSON value. Every property of an
+// object is required; Merge relaxes that across samples.
+func Infer(value interface{}) *Schema {
+	switch typed := value.(type) {
+	case nil:
+		return &Schema{Nullable: true}
+	case bool:
+		return &Schema{Type: "boolean"}
+	case float64:
+		if typed == float64(int64(typed)) {
+			return &Schema{Type: "integer"}
+		}
+		return &Schema{Type: "number"}
+	case string:
+		return &Schema{Type: "string"}
+	case []interface{}:
+		// Items stay nil for empty arrays until a sample has some; see complete
+		var items *Schema
+		for _, item := range typed {
+			items = Merge(items, Infer(item))
+		}
+		return &Schema{Type: "array", Items: items}
+	case map[string]interface{}:
+		schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		for key, item := range typed {
+			schema.Properties[key] = Infer(item)
+			schema.Required = append(schema.Required, key)
+		}
+		sort.Strings(schema.Required)
+		return schema
+	}
+	return &Schema{}
+}
+
+// Merge returns a schema accepting what either a or b accepts. Integers
+// widen to numbers, null makes the other side nullable, object properties are
+// merged and only stay required when both sides require them, and any other
+// type conflict yields an empty (any) schema.
+func Merge(a, b *Schema) *Schema {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+
+	// A null-only sample keeps the other side's shape
+	if isNull(a) {
+		copied := *b
+		copied.Nullable = true
+		return &copied
+	}
+	if isNull(b) {
+		return Merge(b, a)
+	}
+
+	merged := &Schema{Nullable: a.Nullable || b.Nullable}
+	switch {
+	case a.Type == "" || b.Type == "":
+		return &Schema{}
+	case a.Type == b.Type:
+		merged.Type = a.Type
+		merged.Format = a.Format
+		if a.Format != b.Format {
+			merged.Format = ""
+		}
+	case isNumeric(a.Type) && isNumeric(b.Type):
+		merged.Type = "number"
+		return merged
+	default:
+		return &Schema{}
+	}
+
+	switch merged.Type {
+	case "array":
+		merged.Items = Merge(a.Items, b.Items)
+	case "object":
+		merged.Properties = map[string]*Schema{}
+		for key, schema := range a.Properties {
+			merged.Properties[key] = Merge(schema, b.Properties[key])
+		}
+		for key, schema := range b.Properties {
+			if _, ok := a.Properties[key]; !ok {
+				merged.Properties[key] = schema
+			}
+		}
+		for _, key := range a.Required {
+			if containsString(b.Required, key) {
+				merged.Required = append(merged.Required, key)
+			}
+		}
+	}
+	return merged
+}
+
+// isNull reports whether s was inferred from null alone.
+func isNull(s *Schema) bool {
+	return s.Type == "" && s.Nullable
+}
+
+func isNumeric(schemaType string) bool {
+	return schemaType == "integer" || schemaType == "number"
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+)
+
+// writeRecording writes a recording in the auto-proxy format.
+func writeRecording(t *testing.T, dir, name, method, url string, requestBody, responseBody interface{}, status int) {
+	t.Helper()
+	record := map[string]interface{}{
+		"request": map[string]interface{}{
+			"request_id": name,
+			"method":     method,
+			"url":        url,
+			"headers":    map[string]string{},
+			"body":       requestBody,
+		},
+		"response": map[string]interface{}{
+			"request_id":  name,
+			"status_code": status,
+			"headers":     map[string]string{"Content-Type": "application/json"},
+			"body":        responseBody,
+		},
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "default"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "default", fmt.Sprintf("application_json_%s.json", name)), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerateFromRecordings(t *testing.T) {
+	dir := t.TempDir()
+	writeRecording(t, dir, "u1", "GET", "http://api/users/1?fields=name", "",
+		map[string]interface{}{"id": 1, "name": "Ann", "tags": []interface{}{}, "manager": nil}, 200)
+	writeRecording(t, dir, "u2", "GET", "http://api/users/2", "",
+		map[string]interface{}{"id": 2.5, "name": "Bob", "tags": []interface{}{"admin"}, "manager": map[string]interface{}{"id": 1}, "email": "b@example.com"}, 200)
+	writeRecording(t, dir, "u404", "GET", "http://api/users/3", "",
+		map[string]interface{}{"error": "not found"}, 404)
+	writeRecording(t, dir, "create", "POST", "http://api/users", map[string]interface{}{"name": "Cy"},
+		map[string]interface{}{"id": 3}, 201)
+
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("failed to load recordings: %v", err)
+	}
+	doc := Generate(store, Info{Title: "Users", Version: "1"})
+
+	if doc.OpenAPI != Version || len(doc.Paths) != 2 {
+		t.Fatalf("expected two templated paths, got %v", doc.Paths)
+	}
+	item := doc.Paths["/users/{id}"]
+	if item == nil || item.Get == nil || len(item.Parameters) != 1 || item.Parameters[0].In != "path" {
+		t.Fatalf("expected GET /users/{id} with a path parameter, got %+v", item)
+	}
+	if item.Get.OperationID != "get_users_id" {
+		t.Errorf("unexpected operationId %q", item.Get.OperationID)
+	}
+	if len(item.Get.Parameters) != 1 || item.Get.Parameters[0].Name != "fields" || item.Get.Parameters[0].In != "query" {
+		t.Errorf("expected the fields query parameter, got %+v", item.Get.Parameters)
+	}
+	if len(item.Get.Responses) != 2 || item.Get.Responses["404"].Description != "Not Found" {
+		t.Fatalf("expected 200 and 404 responses, got %+v", item.Get.Responses)
+	}
+
+	schema := item.Get.Responses["200"].Content["application/json"].Schema
+	if schema.Type != "object" {
+		t.Fatalf("expected an object schema, got %+v", schema)
+	}
+	if !reflect.DeepEqual(schema.Required, []string{"id", "manager", "name", "tags"}) {
+		t.Errorf("expected properties present in every sample to be required, got %v", schema.Required)
+	}
+	if schema.Properties["id"].Type != "number" {
+		t.Errorf("expected integer and number samples to merge to number, got %+v", schema.Properties["id"])
+	}
+	if manager := schema.Properties["manager"]; manager.Type != "object" || !manager.Nullable {
+		t.Errorf("expected a nullable object for manager, got %+v", manager)
+	}
+	if tags := schema.Properties["tags"]; tags.Type != "array" || tags.Items == nil || tags.Items.Type != "string" {
+		t.Errorf("expected string items for tags, got %+v", tags)
+	}
+
+	create := doc.Paths["/users"]
+	if create == nil || create.Post == nil || create.Post.RequestBody == nil {
+		t.Fatalf("expected POST /users with a request body, got %+v", create)
+	}
+	if body := create.Post.RequestBody.Content["application/json"].Schema; body.Properties["name"].Type != "string" {
+		t.Errorf("unexpected request body schema: %+v", body)
+	}
+	if _, ok := create.Post.Responses["201"]; !ok {
+		t.Errorf("expected a 201 response, got %+v", create.Post.Responses)
+	}
+}
@@ -0,0 +1,198 @@
+package openapi
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadSpec reads an OpenAPI 3.0 document in YAML or JSON.
+func LoadSpec(path string) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read spec: %w", err)
+	}
+	doc := &Document{}
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("parse spec: %w", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("parse spec: unsupported openapi version %q (expected 3.x)", doc.OpenAPI)
+	}
+	return doc, nil
+}
+
+// operations returns the operations of a path item keyed by upper-case method.
+func (item *PathItem) operations() map[string]*Operation {
+	operations := make(map[string]*Operation)
+	for method, op := range map[string]*Operation{
+		"GET": item.Get, "PUT": item.Put, "POST": item.Post, "DELETE": item.Delete,
+		"OPTIONS": item.Options, "HEAD": item.Head, "PATCH": item.Patch, "TRACE": item.Trace,
+	} {
+		if op != nil {
+			operations[method] = op
+		}
+	}
+	return operations
+}
+
+// maxRefChain bounds $ref chains (a $ref to a $ref ...) to catch cycles.
+const maxRefChain = 32
+
+// resolver replaces local $refs ("#/components/...") with the objects they
+// point to, in place. Schemas are shared rather than copied, so recursive
+// schemas stay finite.
+type resolver struct {
+	doc  *Document
+	seen map[*Schema]bool
+}
+
+// resolve resolves every $ref in doc and compiles schema patterns.
+func resolve(doc *Document) error {
+	r := &resolver{doc: doc, seen: make(map[*Schema]bool)}
+	if doc.Components == nil {
+		doc.Components = &Components{}
+	}
+	for name, schema := range doc.Components.Schemas {
+		resolved, err := r.schema(schema)
+		if err != nil {
+			return fmt.Errorf("components.schemas.%s: %w", name, err)
+		}
+		doc.Components.Schemas[name] = resolved
+	}
+
+	for path, item := range doc.Paths {
+		if item == nil {
+			continue
+		}
+		if err := r.parameters(item.Parameters); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		for method, op := range item.operations() {
+			if err := r.operation(op); err != nil {
+				return fmt.Errorf("%s %s: %w", method, path, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *resolver) operation(op *Operation) error {
+	if err := r.parameters(op.Parameters); err != nil {
+		return err
+	}
+	if op.RequestBody == nil {
+		return nil
+	}
+	if ref := op.RequestBody.Ref; ref != "" {
+		name, err := refName(ref, "requestBodies")
+		if err != nil {
+			return err
+		}
+		body := r.doc.Components.RequestBodies[name]
+		if body == nil || body.Ref != "" {
+			return fmt.Errorf("unresolved $ref %q", ref)
+		}
+		op.RequestBody = body
+	}
+	for mediaType, media := range op.RequestBody.Content {
+		if media == nil {
+			continue
+		}
+		schema, err := r.schema(media.Schema)
+		if err != nil {
+			return fmt.Errorf("requestBody %s: %w", mediaType, err)
+		}
+		media.Schema = schema
+	}
+	return nil
+}
+
+func (r *resolver) parameters(params []*Parameter) error {
+	for i, param := range params {
+		if param == nil {
+			continue
+		}
+		if ref := param.Ref; ref != "" {
+			name, err := refName(ref, "parameters")
+			if err != nil {
+				return err
+			}
+			target := r.doc.Components.Parameters[name]
+			if target == nil || target.Ref != "" {
+				return fmt.Errorf("unresolved $ref %q", ref)
+			}
+			params[i] = target
+			param = target
+		}
+		schema, err := r.schema(param.Schema)
+		if err != nil {
+			return fmt.Errorf("parameter %s: %w", param.Name, err)
+		}
+		param.Schema = schema
+	}
+	return nil
+}
+
+// schema returns s with its $ref chain followed, after resolving the schemas
+// nested in it.
+func (r *resolver) schema(s *Schema) (*Schema, error) {
+	for depth := 0; s != nil && s.Ref != ""; depth++ {
+		if depth == maxRefChain {
+			return nil, fmt.Errorf("$ref cycle at %q", s.Ref)
+		}
+		name, err := refName(s.Ref, "schemas")
+		if err != nil {
+			return nil, err
+		}
+		target := r.doc.Components.Schemas[name]
+		if target == nil {
+			return nil, fmt.Errorf("unresolved $ref %q", s.Ref)
+		}
+		s = target
+	}
+	if s == nil || r.seen[s] {
+		return s, nil
+	}
+	r.seen[s] = true
+
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = pattern
+	}
+
+	var err error
+	for key, property := range s.Properties {
+		if s.Properties[key], err = r.schema(property); err != nil {
+			return nil, err
+		}
+	}
+	if s.Items, err = r.schema(s.Items); err != nil {
+		return nil, err
+	}
+	for _, list := range [][]*Schema{s.AllOf, s.AnyOf, s.OneOf} {
+		for i, sub := range list {
+			if list[i], err = r.schema(sub); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// refName returns the component name of a local $ref of the given kind,
+// e.g. "User" for "#/components/schemas/User".
+func refName(ref, kind string) (string, error) {
+	prefix := "#/components/" + kind + "/"
+	if !strings.HasPrefix(ref, prefix) {
+		return "", fmt.Errorf("unsupported $ref %q (only %s... is supported)", ref, prefix)
+	}
+	// JSON pointer escapes
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(strings.TrimPrefix(ref, prefix)), nil
+}
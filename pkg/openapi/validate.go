@@ -0,0 +1,454 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Violation is one way a request departs from the spec.
+type Violation struct {
+	In      string `json:"in"`             // path, query, header or body
+	Name    string `json:"name,omitempty"` // Parameter name, or JSON path into the body
+	Message string `json:"message"`
+}
+
+// Validator checks requests against the paths, parameters and request bodies
+// of an OpenAPI document.
+type Validator struct {
+	routes    []*route
+	basePaths []string // Server URL paths, longest first
+}
+
+// route is a spec path split into segments; params name the {templated} ones.
+type route struct {
+	template string
+	segments []string
+	params   []bool
+	literals int
+	item     *PathItem
+}
+
+// NewValidator resolves the $refs of doc and prepares its paths for matching.
+// doc is modified in place and must not be used concurrently while this runs.
+func NewValidator(doc *Document) (*Validator, error) {
+	if err := resolve(doc); err != nil {
+		return nil, err
+	}
+
+	v := &Validator{}
+	for template, item := range doc.Paths {
+		if item == nil {
+			continue
+		}
+		rt := &route{template: template, item: item}
+		for _, segment := range strings.Split(strings.Trim(template, "/"), "/") {
+			param := strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+			rt.segments = append(rt.segments, segment)
+			rt.params = append(rt.params, param)
+			if !param {
+				rt.literals++
+			}
+		}
+		v.routes = append(v.routes, rt)
+	}
+	// Literal segments win over templated ones: /users/me before /users/{id}
+	sort.Slice(v.routes, func(i, j int) bool {
+		if v.routes[i].literals != v.routes[j].literals {
+			return v.routes[i].literals > v.routes[j].literals
+		}
+		return v.routes[i].template < v.routes[j].template
+	})
+
+	for _, server := range doc.Servers {
+		if base := serverPath(server.URL); base != "" {
+			v.basePaths = append(v.basePaths, base)
+		}
+	}
+	sort.Slice(v.basePaths, func(i, j int) bool { return len(v.basePaths[i]) > len(v.basePaths[j]) })
+	return v, nil
+}
+
+// Paths returns the number of paths in the spec.
+func (v *Validator) Paths() int {
+	return len(v.routes)
+}
+
+// serverPath returns the path of a server URL without its trailing slash.
+// Server variables in the host are tolerated; the path is taken literally.
+func serverPath(serverURL string) string {
+	if idx := strings.Index(serverURL, "://"); idx >= 0 {
+		serverURL = serverURL[idx+3:]
+		slash := strings.IndexByte(serverURL, '/')
+		if slash < 0 {
+			return ""
+		}
+		serverURL = serverURL[slash:]
+	}
+	return strings.TrimRight(serverURL, "/")
+}
+
+// ValidateRequest implements storage.RequestValidator: it returns the JSON
+// body of a 400 answer describing the violations, or nil for a valid request.
+// method is the method used for matching (after X-HTTP-Method-Override).
+func (v *Validator) ValidateRequest(ctx *fasthttp.RequestCtx, method []byte) []byte {
+	operation, violations := v.Validate(ctx, string(method))
+	if len(violations) == 0 {
+		return nil
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"error":      "Request does not match the OpenAPI spec",
+		"operation":  operation,
+		"violations": violations,
+	})
+	return body
+}
+
+// Validate returns the violations of a request and the operation it was
+// checked against ("GET /users/{id}"), empty when the path is not in the spec.
+func (v *Validator) Validate(ctx *fasthttp.RequestCtx, method string) (string, []Violation) {
+	method = strings.ToUpper(method)
+	rt, pathValues := v.match(string(ctx.Path()))
+	if rt == nil {
+		return "", []Violation{{In: "path", Message: fmt.Sprintf("path %s is not in the spec", ctx.Path())}}
+	}
+	operation := method + " " + rt.template
+	op := rt.item.operations()[method]
+	if op == nil {
+		return operation, []Violation{{In: "path", Message: fmt.Sprintf("method %s is not defined for %s", method, rt.template)}}
+	}
+
+	c := &check{}
+	for _, param := range mergeParameters(rt.item.Parameters, op.Parameters) {
+		switch param.In {
+		case "path":
+			c.parameter(param, []string{pathValues[param.Name]})
+		case "query":
+			var values []string
+			for _, value := range ctx.QueryArgs().PeekMulti(param.Name) {
+				values = append(values, string(value))
+			}
+			c.parameter(param, values)
+		case "header":
+			if value := ctx.Request.Header.Peek(param.Name); value != nil {
+				c.parameter(param, []string{string(value)})
+			} else {
+				c.parameter(param, nil)
+			}
+		}
+	}
+	if op.RequestBody != nil {
+		c.body(op.RequestBody, string(ctx.Request.Header.ContentType()), ctx.PostBody())
+	}
+	return operation, c.violations
+}
+
+// match finds the route for a request path, trying it with and without the
+// server base paths, and returns the values of its path parameters.
+func (v *Validator) match(path string) (*route, map[string]string) {
+	candidates := []string{path}
+	for _, base := range v.basePaths {
+		if path == base || strings.HasPrefix(path, base+"/") {
+			candidates = append([]string{strings.TrimPrefix(path, base)}, candidates...)
+		}
+	}
+	for _, candidate := range candidates {
+		segments := strings.Split(strings.Trim(candidate, "/"), "/")
+		for _, rt := range v.routes {
+			if values, ok := rt.match(segments); ok {
+				return rt, values
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (rt *route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(rt.segments) {
+		return nil, false
+	}
+	values := make(map[string]string)
+	for i, segment := range segments {
+		if !rt.params[i] {
+			if segment != rt.segments[i] {
+				return nil, false
+			}
+			continue
+		}
+		if segment == "" {
+			return nil, false
+		}
+		values[strings.Trim(rt.segments[i], "{}")] = segment
+	}
+	return values, true
+}
+
+// mergeParameters lets operation parameters override path-level ones with the
+// same name and location.
+func mergeParameters(pathLevel, operation []*Parameter) []*Parameter {
+	merged := make([]*Parameter, 0, len(pathLevel)+len(operation))
+	for _, param := range pathLevel {
+		overridden := false
+		for _, other := range operation {
+			overridden = overridden || (other.Name == param.Name && other.In == param.In)
+		}
+		if !overridden {
+			merged = append(merged, param)
+		}
+	}
+	return append(merged, operation...)
+}
+
+// check collects the violations of one request.
+type check struct {
+	violations []Violation
+}
+
+func (c *check) addf(in, name, format string, args ...interface{}) {
+	c.violations = append(c.violations, Violation{In: in, Name: name, Message: fmt.Sprintf(format, args...)})
+}
+
+// parameter validates the raw values of a parameter, converted to its schema type.
+func (c *check) parameter(param *Parameter, values []string) {
+	if len(values) == 0 {
+		if param.Required || param.In == "path" {
+			c.addf(param.In, param.Name, "required %s parameter is missing", param.In)
+		}
+		return
+	}
+	schema := param.Schema
+	if schema == nil {
+		return
+	}
+
+	var value interface{}
+	if schema.Type == "array" {
+		items := make([]interface{}, 0, len(values))
+		for _, raw := range values {
+			// Non-exploded form: a single comma-separated value
+			for _, part := range strings.Split(raw, ",") {
+				items = append(items, parameterValue(schema.Items, part))
+			}
+		}
+		value = items
+	} else {
+		value = parameterValue(schema, values[0])
+	}
+	c.value(param.In, param.Name, schema, value)
+}
+
+// parameterValue converts a raw parameter to the type its schema expects,
+// leaving it a string when it does not parse (the type check then reports it).
+func parameterValue(schema *Schema, raw string) interface{} {
+	if schema == nil {
+		return raw
+	}
+	switch schema.Type {
+	case "integer", "number":
+		if number, err := strconv.ParseFloat(raw, 64); err == nil {
+			return number
+		}
+	case "boolean":
+		if flag, err := strconv.ParseBool(raw); err == nil {
+			return flag
+		}
+	}
+	return raw
+}
+
+// body validates a request body against the media type matching contentType.
+func (c *check) body(spec *RequestBody, contentType string, body []byte) {
+	if len(body) == 0 {
+		if spec.Required {
+			c.addf("body", "", "request body is required")
+		}
+		return
+	}
+	if len(spec.Content) == 0 {
+		return
+	}
+
+	mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	media, ok := mediaTypeFor(spec.Content, mediaType)
+	if !ok {
+		allowed := make([]string, 0, len(spec.Content))
+		for key := range spec.Content {
+			allowed = append(allowed, key)
+		}
+		sort.Strings(allowed)
+		c.addf("body", "", "content type %q is not allowed (expected %s)", mediaType, strings.Join(allowed, ", "))
+		return
+	}
+	if media == nil || media.Schema == nil || !isJSON(mediaType) {
+		return
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		c.addf("body", "", "body is not valid JSON: %v", err)
+		return
+	}
+	c.value("body", "$", media.Schema, decoded)
+}
+
+// mediaTypeFor finds the content entry for a media type: exact, then
+// type/* and */* wildcards. Spec keys may carry parameters.
+func mediaTypeFor(content map[string]*MediaType, mediaType string) (*MediaType, bool) {
+	entries := make(map[string]*MediaType, len(content))
+	for key, media := range content {
+		entries[strings.ToLower(strings.TrimSpace(strings.SplitN(key, ";", 2)[0]))] = media
+	}
+	candidates := []string{mediaType}
+	if slash := strings.IndexByte(mediaType, '/'); slash >= 0 {
+		candidates = append(candidates, mediaType[:slash]+"/*")
+	}
+	candidates = append(candidates, "*/*")
+	for _, candidate := range candidates {
+		if media, ok := entries[candidate]; ok {
+			return media, true
+		}
+	}
+	return nil, false
+}
+
+// value validates a decoded value against schema. name is the parameter name
+// or, for bodies, the JSON path of the value.
+func (c *check) value(in, name string, schema *Schema, value interface{}) {
+	if schema == nil {
+		return
+	}
+	for _, sub := range schema.AllOf {
+		c.value(in, name, sub, value)
+	}
+	if alternatives := append(append([]*Schema{}, schema.AnyOf...), schema.OneOf...); len(alternatives) > 0 {
+		matched := false
+		for _, sub := range alternatives {
+			trial := &check{}
+			trial.value(in, name, sub, value)
+			if len(trial.violations) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			c.addf(in, name, "does not match any of the allowed schemas")
+		}
+	}
+
+	if value == nil {
+		if !schema.Nullable && schema.Type != "" {
+			c.addf(in, name, "must not be null")
+		}
+		return
+	}
+	if schema.Type != "" && !hasType(schema.Type, value) {
+		c.addf(in, name, "must be %s %s", article(schema.Type), schema.Type)
+		return
+	}
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		c.addf(in, name, "must be one of %v", schema.Enum)
+	}
+
+	switch typed := value.(type) {
+	case float64:
+		if schema.Minimum != nil && typed < *schema.Minimum {
+			c.addf(in, name, "must be at least %v", *schema.Minimum)
+		}
+		if schema.Maximum != nil && typed > *schema.Maximum {
+			c.addf(in, name, "must be at most %v", *schema.Maximum)
+		}
+	case string:
+		length := utf8.RuneCountInString(typed)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			c.addf(in, name, "must be at least %d characters long", *schema.MinLength)
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			c.addf(in, name, "must be at most %d characters long", *schema.MaxLength)
+		}
+		if schema.pattern != nil && !schema.pattern.MatchString(typed) {
+			c.addf(in, name, "must match pattern %s", schema.Pattern)
+		}
+	case []interface{}:
+		if schema.MinItems != nil && len(typed) < *schema.MinItems {
+			c.addf(in, name, "must have at least %d items", *schema.MinItems)
+		}
+		if schema.MaxItems != nil && len(typed) > *schema.MaxItems {
+			c.addf(in, name, "must have at most %d items", *schema.MaxItems)
+		}
+		for i, item := range typed {
+			c.value(in, fmt.Sprintf("%s[%d]", name, i), schema.Items, item)
+		}
+	case map[string]interface{}:
+		for _, key := range schema.Required {
+			if _, ok := typed[key]; !ok {
+				c.addf(in, name+"."+key, "is required")
+			}
+		}
+		keys := make([]string, 0, len(schema.Properties))
+		for key := range schema.Properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if item, ok := typed[key]; ok {
+				c.value(in, name+"."+key, schema.Properties[key], item)
+			}
+		}
+	}
+}
+
+// hasType reports whether a decoded JSON value has the schema type.
+func hasType(schemaType string, value interface{}) bool {
+	switch schemaType {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	}
+	return true
+}
+
+func article(schemaType string) string {
+	switch schemaType {
+	case "array", "object", "integer":
+		return "an"
+	}
+	return "a"
+}
+
+// inEnum compares a decoded JSON value with enum entries decoded from YAML,
+// where numbers may be ints.
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		switch typed := allowed.(type) {
+		case int:
+			allowed = float64(typed)
+		case int64:
+			allowed = float64(typed)
+		}
+		if allowed == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package openapi
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+const testSpec = `openapi: 3.0.3
+info: {title: Users, version: "1"}
+servers:
+  - url: https://api.example.com/v1
+paths:
+  /users/me:
+    get:
+      responses: {"200": {description: OK}}
+  /users/{id}:
+    parameters:
+      - $ref: '#/components/parameters/UserID'
+    get:
+      parameters:
+        - {name: fields, in: query, schema: {type: array, items: {type: string, enum: [name, email]}}}
+        - {name: X-Tenant, in: header, required: true, schema: {type: string, pattern: '^[a-z]+$'}}
+      responses: {"200": {description: OK}}
+    put:
+      requestBody:
+        $ref: '#/components/requestBodies/User'
+      responses: {"200": {description: OK}}
+components:
+  parameters:
+    UserID: {name: id, in: path, required: true, schema: {type: integer, minimum: 1}}
+  requestBodies:
+    User:
+      required: true
+      content:
+        application/json:
+          schema: {$ref: '#/components/schemas/User'}
+  schemas:
+    User:
+      type: object
+      required: [name]
+      properties:
+        name: {type: string}
+        age: {type: integer, nullable: true}
+        tags: {type: array, maxItems: 2, items: {type: string}}
+        manager: {$ref: '#/components/schemas/User'}
+`
+
+func TestValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "openapi.yml")
+	if err := os.WriteFile(path, []byte(testSpec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := LoadSpec(path)
+	if err != nil {
+		t.Fatalf("failed to load spec: %v", err)
+	}
+	v, err := NewValidator(doc)
+	if err != nil {
+		t.Fatalf("failed to build validator: %v", err)
+	}
+
+	request := func(method, uri string, headers map[string]string, contentType, body string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(method)
+		ctx.Request.SetRequestURI(uri)
+		for key, value := range headers {
+			ctx.Request.Header.Set(key, value)
+		}
+		if contentType != "" {
+			ctx.Request.Header.SetContentType(contentType)
+		}
+		ctx.Request.SetBodyString(body)
+		return ctx
+	}
+	tenant := map[string]string{"X-Tenant": "acme"}
+
+	tests := []struct {
+		name      string
+		ctx       *fasthttp.RequestCtx
+		operation string
+		names     []string // names of the expected violations, in order
+	}{
+		{"valid get", request("GET", "/users/7?fields=name", tenant, "", ""), "GET /users/{id}", nil},
+		{"server base path", request("GET", "/v1/users/7", tenant, "", ""), "GET /users/{id}", nil},
+		{"literal route wins", request("GET", "/users/me", nil, "", ""), "GET /users/me", nil},
+		{"unknown path", request("GET", "/orders", nil, "", ""), "", []string{""}},
+		{"unknown method", request("DELETE", "/users/7", nil, "", ""), "DELETE /users/{id}", []string{""}},
+		{"bad path and query", request("GET", "/users/0?fields=name&fields=phone", tenant, "", ""), "GET /users/{id}", []string{"id", "fields[1]"}},
+		{"non-integer id", request("GET", "/users/abc", tenant, "", ""), "GET /users/{id}", []string{"id"}},
+		{"missing header", request("GET", "/users/7", nil, "", ""), "GET /users/{id}", []string{"X-Tenant"}},
+		{"header pattern", request("GET", "/users/7", map[string]string{"X-Tenant": "ACME"}, "", ""), "GET /users/{id}", []string{"X-Tenant"}},
+		{"valid body", request("PUT", "/users/7", nil, "application/json; charset=utf-8",
+			`{"name": "Ann", "age": null, "manager": {"name": "Bob"}}`), "PUT /users/{id}", nil},
+		{"missing body", request("PUT", "/users/7", nil, "", ""), "PUT /users/{id}", []string{""}},
+		{"wrong content type", request("PUT", "/users/7", nil, "text/plain", "Ann"), "PUT /users/{id}", []string{""}},
+		{"invalid json", request("PUT", "/users/7", nil, "application/json", "{"), "PUT /users/{id}", []string{""}},
+		{"body violations", request("PUT", "/users/7", nil, "application/json",
+			`{"age": 1.5, "tags": ["a", 2, "c"], "manager": {}}`), "PUT /users/{id}",
+			[]string{"$.name", "$.age", "$.manager.name", "$.tags", "$.tags[1]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operation, violations := v.Validate(tt.ctx, string(tt.ctx.Method()))
+			if operation != tt.operation {
+				t.Errorf("expected operation %q, got %q", tt.operation, operation)
+			}
+			var names []string
+			for _, violation := range violations {
+				names = append(names, violation.Name)
+			}
+			if !reflect.DeepEqual(names, tt.names) {
+				t.Errorf("expected violations %q, got %+v", tt.names, violations)
+			}
+		})
+	}
+
+	if body := v.ValidateRequest(request("GET", "/users/7", tenant, "", ""), []byte("GET")); body != nil {
+		t.Errorf("expected no answer for a valid request, got %s", body)
+	}
+}
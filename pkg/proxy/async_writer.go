@@ -0,0 +1,189 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// Policies for a full asynchronous recording queue.
+const (
+	// QueueBlock waits for room in the queue; requests slow down but nothing is lost.
+	QueueBlock = "block"
+	// QueueDrop discards the recording and counts it.
+	QueueDrop = "drop"
+	// QueueSync writes the recording in the request path, as without a queue.
+	QueueSync = "sync"
+)
+
+// ErrRecordDropped is returned by the recorder when the asynchronous queue was
+// full and the QueueDrop policy discarded the recording.
+var ErrRecordDropped = errors.New("recording dropped: write queue full")
+
+// maxWriteBatch caps how many queued recordings the worker writes per wake-up.
+const maxWriteBatch = 64
+
+// pendingRecord is a recording waiting to be marshaled and written.
+type pendingRecord struct {
+	dir      string
+	filename string
+	mockID   string
+	record   map[string]interface{}
+}
+
+// asyncWriter moves json.MarshalIndent and file writes out of the request
+// path into one background worker, which keeps the write order of a session.
+type asyncWriter struct {
+	queue   chan pendingRecord
+	policy  string
+	done    chan struct{}
+	mutex   sync.RWMutex // Held for reading while enqueuing; Close takes it to stop the queue
+	closed  bool
+	dropped uint64 // Updated atomically
+}
+
+// SetAsync queues recordings for a background writer instead of writing them
+// while the request is being answered. queueSize bounds the queue; policy
+// (QueueBlock, QueueDrop or QueueSync) decides what happens when it is full.
+// Close flushes the queue, so call it on shutdown.
+func (r *Recorder) SetAsync(queueSize int, policy string) error {
+	switch policy {
+	case "":
+		policy = QueueBlock
+	case QueueBlock, QueueDrop, QueueSync:
+	default:
+		return fmt.Errorf("unknown queue policy %q (expected block, drop or sync)", policy)
+	}
+	if queueSize <= 0 {
+		return fmt.Errorf("queue size must be positive, got %d", queueSize)
+	}
+
+	w := &asyncWriter{
+		queue:  make(chan pendingRecord, queueSize),
+		policy: policy,
+		done:   make(chan struct{}),
+	}
+	r.async = w
+	go r.runWriter(w)
+	return nil
+}
+
+// Dropped returns how many recordings the QueueDrop policy has discarded.
+func (r *Recorder) Dropped() uint64 {
+	if r.async == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&r.async.dropped)
+}
+
+// QueueDepth returns how many recordings wait for the background writer and
+// the queue capacity; both are 0 without SetAsync.
+func (r *Recorder) QueueDepth() (depth, capacity int) {
+	if r.async == nil {
+		return 0, 0
+	}
+	return len(r.async.queue), cap(r.async.queue)
+}
+
+// persist writes a recording to dir/filename, through the background writer
+// when one is configured.
+func (r *Recorder) persist(dir, filename, mockID string, record map[string]interface{}) error {
+	pending := pendingRecord{dir: dir, filename: filename, mockID: mockID, record: record}
+	if r.memory != nil {
+		return r.writePending(pending)
+	}
+	if w := r.async; w != nil {
+		w.mutex.RLock()
+		defer w.mutex.RUnlock()
+		if !w.closed {
+			if w.policy == QueueBlock {
+				w.queue <- pending
+				return nil
+			}
+			select {
+			case w.queue <- pending:
+				return nil
+			default:
+			}
+			if w.policy == QueueDrop {
+				atomic.AddUint64(&w.dropped, 1)
+				return ErrRecordDropped
+			}
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return r.writePending(pending)
+}
+
+// writePending marshals a recording and writes it into its existing directory.
+func (r *Recorder) writePending(pending pendingRecord) error {
+	var data []byte
+	var err error
+	if r.format == FormatHAR {
+		data, err = marshalHAR(pending.record)
+	} else {
+		data, err = json.MarshalIndent(pending.record, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	return r.writeRecord(filepath.Join(pending.dir, pending.filename), data, pending.mockID)
+}
+
+// runWriter drains the queue in batches until Close, creating each mock
+// directory once per batch.
+func (r *Recorder) runWriter(w *asyncWriter) {
+	defer close(w.done)
+	batch := make([]pendingRecord, 0, maxWriteBatch)
+	for pending := range w.queue {
+		batch = append(batch[:0], pending)
+	drain:
+		for len(batch) < maxWriteBatch {
+			select {
+			case next, ok := <-w.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+
+		created := make(map[string]bool)
+		for _, pending := range batch {
+			if !created[pending.dir] {
+				if err := os.MkdirAll(pending.dir, 0755); err != nil {
+					log.Printf("⚠️  Failed to create %s: %v", pending.dir, err)
+					continue
+				}
+				created[pending.dir] = true
+			}
+			if err := r.writePending(pending); err != nil {
+				log.Printf("⚠️  Failed to write recording %s: %v", pending.filename, err)
+			}
+		}
+	}
+}
+
+// close stops accepting recordings and waits until the queued ones are written.
+// Recordings made afterwards are written synchronously.
+func (w *asyncWriter) close() {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.queue)
+	w.mutex.Unlock()
+	<-w.done
+}
@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func recordJSON(t *testing.T, recorder *Recorder, id string) error {
+	t.Helper()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	resp.Header.SetContentType("application/json")
+	resp.SetBodyString(`{"id":"` + id + `"}`)
+	reqData := &RequestData{RequestID: id, Method: "GET", URL: "/items/" + id, Headers: map[string]string{}}
+	return recorder.RecordPair(reqData, resp, 0)
+}
+
+func TestAsyncRecorderFlushesOnClose(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	if err := recorder.SetAsync(4, QueueBlock); err != nil {
+		t.Fatalf("Failed to enable async recording: %v", err)
+	}
+
+	for i := 0; i < 50; i++ {
+		if err := recordJSON(t, recorder, fmt.Sprint(i)); err != nil {
+			t.Fatalf("Failed to record: %v", err)
+		}
+	}
+	recorder.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "default", "*.json"))
+	if len(files) != 50 {
+		t.Fatalf("Expected 50 recordings after Close, got %d", len(files))
+	}
+
+	// Recordings after Close are written synchronously
+	if err := recordJSON(t, recorder, "late"); err != nil {
+		t.Fatalf("Failed to record after Close: %v", err)
+	}
+	if files, _ = filepath.Glob(filepath.Join(dir, "default", "*.json")); len(files) != 51 {
+		t.Fatalf("Expected 51 recordings, got %d", len(files))
+	}
+
+	for _, bad := range []struct {
+		size   int
+		policy string
+	}{{0, QueueBlock}, {8, "spill"}} {
+		if err := recorder.SetAsync(bad.size, bad.policy); err == nil {
+			t.Errorf("Expected an error for queue %d/%s", bad.size, bad.policy)
+		}
+	}
+}
+
+func TestAsyncRecorderFullQueue(t *testing.T) {
+	for _, policy := range []string{QueueDrop, QueueSync} {
+		t.Run(policy, func(t *testing.T) {
+			dir := t.TempDir()
+			recorder, err := NewRecorder(dir)
+			if err != nil {
+				t.Fatalf("Failed to create recorder: %v", err)
+			}
+
+			// Hold the worker inside the first write so the queue stays full
+			busy, release := make(chan struct{}), make(chan struct{})
+			var hooked int32
+			recorder.SetRecordHook(func(path, mockID string) {
+				if atomic.CompareAndSwapInt32(&hooked, 0, 1) {
+					close(busy)
+					<-release
+				}
+			})
+			if err := recorder.SetAsync(1, policy); err != nil {
+				t.Fatalf("Failed to enable async recording: %v", err)
+			}
+
+			if err := recordJSON(t, recorder, "1"); err != nil {
+				t.Fatalf("Failed to record: %v", err)
+			}
+			<-busy
+			if err := recordJSON(t, recorder, "2"); err != nil {
+				t.Fatalf("Failed to queue: %v", err)
+			}
+			err = recordJSON(t, recorder, "3")
+			close(release)
+			recorder.Close()
+
+			files, _ := filepath.Glob(filepath.Join(dir, "default", "*.json"))
+			switch policy {
+			case QueueDrop:
+				if !errors.Is(err, ErrRecordDropped) || recorder.Dropped() != 1 || len(files) != 2 {
+					t.Fatalf("Expected one dropped recording, got err=%v dropped=%d files=%d", err, recorder.Dropped(), len(files))
+				}
+			case QueueSync:
+				if err != nil || recorder.Dropped() != 0 || len(files) != 3 {
+					t.Fatalf("Expected an inline write, got err=%v dropped=%d files=%d", err, recorder.Dropped(), len(files))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+)
+
+// revalidations remembers, per recorded URL, the last full response that
+// carried validators (ETag or Last-Modified), so a later 304 Not Modified
+// can name the recording it revalidated.
+type revalidations struct {
+	mutex  sync.Mutex
+	latest map[string]string // hostDir|mockID|URL -> request ID
+}
+
+// observe updates the latest validated response for the request and returns,
+// for a 304, the request ID of the recording it revalidates ("" if unknown).
+func (v *revalidations) observe(reqData *RequestData, resp *fasthttp.Response) string {
+	if reqData.Method != fasthttp.MethodGet && reqData.Method != fasthttp.MethodHead {
+		return ""
+	}
+	key := reqData.HostDir + "|" + reqData.MockID + "|" + reqData.URL
+	status := resp.StatusCode()
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	switch {
+	case status == fasthttp.StatusNotModified:
+		return v.latest[key]
+	case status >= 200 && status < 300 && hasValidators(resp):
+		if v.latest == nil {
+			v.latest = make(map[string]string)
+		}
+		v.latest[key] = reqData.RequestID
+	}
+	return ""
+}
+
+// hasValidators reports whether resp carries an ETag or Last-Modified. The
+// upstream client keeps header names as sent, so they are matched in any case.
+func hasValidators(resp *fasthttp.Response) bool {
+	found := false
+	resp.Header.VisitAll(func(key, value []byte) {
+		if len(value) > 0 && (bytes.EqualFold(key, []byte("ETag")) || bytes.EqualFold(key, []byte("Last-Modified"))) {
+			found = true
+		}
+	})
+	return found
+}
@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestRecordRevalidations(t *testing.T) {
+	upstream := startUpstream(t, func(ctx *fasthttp.RequestCtx) {
+		ctx.Response.Header.Set("ETag", `"v1"`)
+		if string(ctx.Request.Header.Peek("If-None-Match")) == `"v1"` {
+			ctx.SetStatusCode(fasthttp.StatusNotModified)
+			return
+		}
+		ctx.SetContentType("application/json")
+		ctx.SetBodyString(`{"items":[]}`)
+	})
+
+	server, err := NewServer(upstream, ServerOptions{InMemory: true})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Shutdown(context.Background())
+
+	get := func(path, ifNoneMatch string) {
+		t.Helper()
+		req := fasthttp.AcquireRequest()
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseRequest(req)
+		defer fasthttp.ReleaseResponse(resp)
+		req.SetRequestURI(server.URL() + path)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		if err := fasthttp.Do(req, resp); err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+	}
+	get("/items", "")
+	get("/items", `"v1"`)
+	get("/other", `"v1"`) // No full response recorded for this URL
+
+	recordings := server.Recorder().Memory().Recordings()
+	if len(recordings) != 3 {
+		t.Fatalf("Expected 3 recordings, got %d", len(recordings))
+	}
+	records := make([]map[string]map[string]interface{}, len(recordings))
+	for i, recording := range recordings {
+		if err := json.Unmarshal(recording.Data, &records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, ok := records[0]["response"]["revalidates"]; ok {
+		t.Fatalf("The full response should not be linked: %v", records[0]["response"])
+	}
+	if status := records[1]["response"]["status_code"]; status != float64(fasthttp.StatusNotModified) {
+		t.Fatalf("Expected the 304 to be recorded, got status %v", status)
+	}
+	if records[1]["response"]["revalidates"] != records[0]["request"]["request_id"] {
+		t.Fatalf("Expected revalidates %v, got %v", records[0]["request"]["request_id"], records[1]["response"]["revalidates"])
+	}
+	if _, ok := records[2]["response"]["revalidates"]; ok {
+		t.Fatalf("A 304 without a recorded full response should not be linked: %v", records[2]["response"])
+	}
+}
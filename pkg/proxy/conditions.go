@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+)
+
+// ErrRecordSkipped is returned by the recorder when a response was filtered out
+// by a recording condition. It is not a failure.
+var ErrRecordSkipped = errors.New("recording skipped by condition")
+
+// RecordCondition is a single rule evaluated against upstream response headers.
+// Require conditions must all match for a response to be recorded; any matching
+// skip condition prevents recording.
+type RecordCondition struct {
+	Header   string // Lowercase header name
+	Operator string // One of =, !=, >, >=, <, <=, ~ (contains)
+	Value    string
+	Skip     bool // true for skip-if rules, false for record-if rules
+
+	numeric float64
+}
+
+// conditionOperators is ordered so two-character operators win over the
+// one-character operator they start with.
+var conditionOperators = []string{"!=", ">=", "<=", "=", ">", "<", "~"}
+
+// ParseRecordCondition parses rules like "x-cache=MISS" or "content-length>1MB".
+// The rule is split at the first operator, so values may contain operator
+// characters. Numeric comparisons accept KB, MB and GB suffixes.
+func ParseRecordCondition(rule string, skip bool) (RecordCondition, error) {
+	idx, op := -1, ""
+	for _, candidate := range conditionOperators {
+		if i := strings.Index(rule, candidate); i >= 0 && (idx < 0 || i < idx) {
+			idx, op = i, candidate
+		}
+	}
+
+	if idx <= 0 {
+		return RecordCondition{}, fmt.Errorf("invalid condition %q (expected header<op>value with op one of = != > >= < <= ~)", rule)
+	}
+
+	cond := RecordCondition{
+		Header:   strings.ToLower(strings.TrimSpace(rule[:idx])),
+		Operator: op,
+		Value:    strings.TrimSpace(rule[idx+len(op):]),
+		Skip:     skip,
+	}
+
+	switch op {
+	case ">", ">=", "<", "<=":
+		n, err := storage.ParseSize(cond.Value)
+		if err != nil {
+			return RecordCondition{}, fmt.Errorf("invalid condition %q: %w", rule, err)
+		}
+		cond.numeric = n
+	}
+
+	return cond, nil
+}
+
+// Matches reports whether the condition holds for the given header value.
+// A missing header only satisfies "!=" comparisons.
+func (c RecordCondition) Matches(value string, present bool) bool {
+	if !present {
+		return c.Operator == "!="
+	}
+
+	switch c.Operator {
+	case "=":
+		return strings.EqualFold(value, c.Value)
+	case "!=":
+		return !strings.EqualFold(value, c.Value)
+	case "~":
+		return strings.Contains(strings.ToLower(value), strings.ToLower(c.Value))
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return false
+	}
+	switch c.Operator {
+	case ">":
+		return n > c.numeric
+	case ">=":
+		return n >= c.numeric
+	case "<":
+		return n < c.numeric
+	case "<=":
+		return n <= c.numeric
+	}
+	return false
+}
+
+// AddCondition registers a recording condition.
+func (r *Recorder) AddCondition(cond RecordCondition) {
+	r.conditions = append(r.conditions, cond)
+}
+
+// shouldRecord evaluates all conditions against a header lookup function.
+func (r *Recorder) shouldRecord(lookup func(name string) (string, bool)) bool {
+	for _, cond := range r.conditions {
+		value, present := lookup(cond.Header)
+		matched := cond.Matches(value, present)
+		if cond.Skip && matched {
+			return false
+		}
+		if !cond.Skip && !matched {
+			return false
+		}
+	}
+	return true
+}
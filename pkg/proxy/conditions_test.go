@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestParseRecordCondition(t *testing.T) {
+	cond, err := ParseRecordCondition("Content-Length>1MB", true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cond.Header != "content-length" || cond.Operator != ">" || cond.numeric != 1<<20 {
+		t.Fatalf("Unexpected condition: %+v", cond)
+	}
+
+	cond, err = ParseRecordCondition("x-cache!=HIT", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cond.Operator != "!=" || cond.Value != "HIT" {
+		t.Fatalf("Unexpected condition: %+v", cond)
+	}
+
+	if _, err := ParseRecordCondition("content-length>big", true); err == nil {
+		t.Fatal("Expected error for non-numeric size")
+	}
+	if _, err := ParseRecordCondition("x-cache", false); err == nil {
+		t.Fatal("Expected error for missing operator")
+	}
+	if _, err := ParseRecordCondition("=MISS", false); err == nil {
+		t.Fatal("Expected error for missing header")
+	}
+
+	// The rule is split at the first operator; values may contain the others
+	tests := []struct {
+		rule, header, op, value string
+	}{
+		{"x-q=a>b", "x-q", "=", "a>b"},
+		{"x-q~a=b", "x-q", "~", "a=b"},
+		{"x-q!=a<=b", "x-q", "!=", "a<=b"},
+		{"x-q=>b", "x-q", "=", ">b"},
+		{"x-q>=1KB", "x-q", ">=", "1KB"},
+		{"x-q<=1", "x-q", "<=", "1"},
+		{"x-link~<https://a>; rel=next", "x-link", "~", "<https://a>; rel=next"},
+	}
+	for _, tt := range tests {
+		cond, err := ParseRecordCondition(tt.rule, false)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.rule, err)
+			continue
+		}
+		if cond.Header != tt.header || cond.Operator != tt.op || cond.Value != tt.value {
+			t.Errorf("%q: expected %s %s %s, got %+v", tt.rule, tt.header, tt.op, tt.value, cond)
+		}
+	}
+}
+
+func TestRecordPairConditions(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+
+	requireMiss, _ := ParseRecordCondition("x-cache=MISS", false)
+	skipLarge, _ := ParseRecordCondition("content-length>10", true)
+	recorder.AddCondition(requireMiss)
+	recorder.AddCondition(skipLarge)
+
+	reqData := &RequestData{RequestID: "1", Method: "GET", URL: "/items", Headers: map[string]string{}}
+
+	record := func(cache, body string) error {
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseResponse(resp)
+		resp.Header.SetContentType("application/json")
+		if cache != "" {
+			resp.Header.Set("X-Cache", cache)
+		}
+		resp.SetBodyString(body)
+		return recorder.RecordPair(reqData, resp, 0)
+	}
+
+	if err := record("HIT", `{}`); !errors.Is(err, ErrRecordSkipped) {
+		t.Fatalf("Expected cache hit to be skipped, got %v", err)
+	}
+	if err := record("", `{}`); !errors.Is(err, ErrRecordSkipped) {
+		t.Fatalf("Expected missing x-cache to be skipped, got %v", err)
+	}
+	if err := record("MISS", `{"large":"payload"}`); !errors.Is(err, ErrRecordSkipped) {
+		t.Fatalf("Expected large body to be skipped, got %v", err)
+	}
+	if err := record("MISS", `{}`); err != nil {
+		t.Fatalf("Expected small cache miss to be recorded, got %v", err)
+	}
+
+	files, err := os.ReadDir(dir + "/default")
+	if err != nil {
+		t.Fatalf("Failed to read recordings: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Expected exactly 1 recording, got %d", len(files))
+	}
+}
@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// corpusFile is a recording counted against the corpus budget.
+type corpusFile struct {
+	path    string
+	dir     string // Mock ID directory
+	size    int64
+	modTime time.Time
+}
+
+// corpusBudget caps the recordings under the base directory, deleting the
+// oldest ones first once a limit is exceeded.
+type corpusBudget struct {
+	maxBytes int64 // 0 = no size limit
+	maxFiles int   // Per mock ID directory; 0 = no limit
+
+	mutex   sync.Mutex
+	files   []corpusFile // Oldest first
+	perDir  map[string]int
+	total   int64
+	evicted uint64 // Updated atomically
+}
+
+// SetCorpusLimits bounds the recordings on disk: maxBytes caps the total size
+// of the corpus and maxFilesPerMockID the recordings kept per mock ID (and host)
+// directory; 0 disables either limit. Recordings already in the base directory
+// count towards the limits. When a new recording exceeds one, the oldest
+// recordings are deleted first; the new one is always kept. Captured uploads
+// are not counted.
+func (r *Recorder) SetCorpusLimits(maxBytes int64, maxFilesPerMockID int) error {
+	if maxBytes < 0 || maxFilesPerMockID < 0 {
+		return fmt.Errorf("corpus limits must not be negative, got %d bytes and %d files", maxBytes, maxFilesPerMockID)
+	}
+	if r.memory != nil {
+		return fmt.Errorf("corpus limits need a recording directory, not an in-memory recorder")
+	}
+	if maxBytes == 0 && maxFilesPerMockID == 0 {
+		r.corpus = nil
+		return nil
+	}
+
+	budget := &corpusBudget{maxBytes: maxBytes, maxFiles: maxFilesPerMockID, perDir: make(map[string]int)}
+	err := filepath.WalkDir(r.baseDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == "uploads" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(path); ext != ".json" && ext != ".har" {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		budget.files = append(budget.files, corpusFile{path: path, dir: filepath.Dir(path), size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("scan recordings: %w", err)
+	}
+	sort.SliceStable(budget.files, func(i, j int) bool {
+		if !budget.files[i].modTime.Equal(budget.files[j].modTime) {
+			return budget.files[i].modTime.Before(budget.files[j].modTime)
+		}
+		return budget.files[i].path < budget.files[j].path
+	})
+	for _, file := range budget.files {
+		budget.perDir[file.dir]++
+		budget.total += file.size
+	}
+
+	r.corpus = budget
+	// Apply the limits to an existing corpus right away
+	budget.enforce("")
+	return nil
+}
+
+// Evicted returns how many recordings the corpus limits have deleted.
+func (r *Recorder) Evicted() uint64 {
+	if r.corpus == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&r.corpus.evicted)
+}
+
+// add counts a written recording (again, when the hash strategy overwrote
+// it) and deletes the oldest recordings over the limits.
+func (b *corpusBudget) add(path string, size int64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if i := b.index(path); i >= 0 {
+		b.remove(i)
+	}
+	file := corpusFile{path: path, dir: filepath.Dir(path), size: size, modTime: time.Now()}
+	b.files = append(b.files, file)
+	b.perDir[file.dir]++
+	b.total += size
+	b.enforce(path)
+}
+
+// enforce deletes recordings, oldest first, until the corpus fits its limits.
+// keep is never deleted. The caller holds the mutex, or owns b exclusively.
+func (b *corpusBudget) enforce(keep string) {
+	if b.maxFiles > 0 {
+		for dir, count := range b.perDir {
+			for i := 0; count > b.maxFiles && i < len(b.files); {
+				if b.files[i].dir != dir || b.files[i].path == keep {
+					i++
+					continue
+				}
+				b.evict(i)
+				count--
+			}
+		}
+	}
+	if b.maxBytes > 0 {
+		for i := 0; b.total > b.maxBytes && i < len(b.files); {
+			if b.files[i].path == keep {
+				i++
+				continue
+			}
+			b.evict(i)
+		}
+	}
+}
+
+// evict deletes the recording at index i from disk and from the budget.
+func (b *corpusBudget) evict(i int) {
+	path := b.files[i].path
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to rotate out recording %s: %v", path, err)
+	}
+	b.remove(i)
+	atomic.AddUint64(&b.evicted, 1)
+}
+
+func (b *corpusBudget) remove(i int) {
+	file := b.files[i]
+	b.files = append(b.files[:i], b.files[i+1:]...)
+	b.total -= file.size
+	if b.perDir[file.dir]--; b.perDir[file.dir] <= 0 {
+		delete(b.perDir, file.dir)
+	}
+}
+
+func (b *corpusBudget) index(path string) int {
+	for i := range b.files {
+		if b.files[i].path == path {
+			return i
+		}
+	}
+	return -1
+}
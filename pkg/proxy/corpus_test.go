@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordedIDs returns the ids of the recordings left in dir/default.
+func recordedIDs(t *testing.T, dir string) []string {
+	t.Helper()
+	files, _ := filepath.Glob(filepath.Join(dir, "default", "*.json"))
+	var ids []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range []string{"0", "1", "2", "3", "4", "5", "old"} {
+			if strings.Contains(string(data), `"request_id": "`+id+`"`) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestCorpusLimitsRotateOldestFirst(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	if err := recorder.SetCorpusLimits(0, 3); err != nil {
+		t.Fatalf("Failed to set corpus limits: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := recordJSON(t, recorder, fmt.Sprint(i)); err != nil {
+			t.Fatalf("Failed to record: %v", err)
+		}
+	}
+	if ids := recordedIDs(t, dir); fmt.Sprint(ids) != "[2 3 4]" {
+		t.Fatalf("Expected the three newest recordings, got %v", ids)
+	}
+	if recorder.Evicted() != 2 {
+		t.Errorf("Expected 2 evictions, got %d", recorder.Evicted())
+	}
+
+	if err := recorder.SetCorpusLimits(-1, 0); err == nil {
+		t.Error("Expected an error for a negative limit")
+	}
+}
+
+func TestCorpusSizeCountsExistingRecordings(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "default", "uploads"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := filepath.Join(dir, "default", "application_json_old.json")
+	if err := os.WriteFile(old, []byte(`{"request": {"request_id": "old"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(old, past, past)
+	// Uploads are neither counted nor deleted
+	upload := filepath.Join(dir, "default", "uploads", "big.bin")
+	if err := os.WriteFile(upload, make([]byte, 4096), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	if err := recordJSON(t, recorder, "0"); err != nil {
+		t.Fatalf("Failed to record: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "default", "application_json_2*.json"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one new recording, got %v", files)
+	}
+	info, err := os.Stat(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Room for about two recordings: the old one goes first
+	if err := recorder.SetCorpusLimits(2*info.Size()+info.Size()/2, 0); err != nil {
+		t.Fatalf("Failed to set corpus limits: %v", err)
+	}
+	for _, id := range []string{"1", "2"} {
+		if err := recordJSON(t, recorder, id); err != nil {
+			t.Fatalf("Failed to record: %v", err)
+		}
+	}
+	if ids := recordedIDs(t, dir); fmt.Sprint(ids) != "[1 2]" {
+		t.Fatalf("Expected the two newest recordings, got %v", ids)
+	}
+	if _, err := os.Stat(upload); err != nil {
+		t.Errorf("Expected the upload to survive rotation: %v", err)
+	}
+}
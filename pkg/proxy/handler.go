@@ -2,13 +2,12 @@ package proxy
 
 import (
 	"bufio"
-	"bytes"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
-	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -16,6 +15,9 @@ import (
 	"github.com/valyala/fasthttp"
 )
 
+// headerMockNote is the request header whose value annotates a recording.
+const headerMockNote = "x-mock-note"
+
 // ProxyHandler creates a proxy handler that forwards requests and records them.
 type ProxyHandler struct {
 	recorder      *Recorder
@@ -23,6 +25,31 @@ type ProxyHandler struct {
 	targetURL     string // Target URL to proxy to
 	headerXMockID []byte
 	tlsConfig     *tls.Config // TLS configuration for client certs and SSE
+
+	// resolveOverrides maps lowercase "host:port" to a fixed dial address (like curl --resolve)
+	resolveOverrides map[string]string
+
+	latency *LatencyTracker // Optional per-endpoint latency histograms
+
+	// Upload streaming: bodies above the threshold are forwarded without buffering
+	streamUploadsOver int64
+	captureUploads    bool
+
+	mitm *CertAuthority // Set when CONNECT tunnels are intercepted
+
+	// Forward proxy mode: the upstream comes from each request instead of targetURL
+	forwardMode bool
+
+	routes []Route // Per-prefix upstreams, longest prefix first
+
+	rewriter *Rewriter // Optional response transformations before the client and recorder see a response
+
+	sseLimits sseLimits // Caps on the capture of each SSE stream
+
+	cache *responseCache // Optional reuse of responses to repeated GETs
+
+	metrics     proxyMetrics
+	maxInFlight int64 // Requests beyond this many in flight get 503; 0 = unlimited
 }
 
 // NewProxyHandler creates a new proxy handler.
@@ -32,7 +59,7 @@ func NewProxyHandler(recorder *Recorder, targetURL string) *ProxyHandler {
 		InsecureSkipVerify: true, // Skip verification for self-signed certs in testing
 	}
 
-	return &ProxyHandler{
+	p := &ProxyHandler{
 		recorder:  recorder,
 		targetURL: targetURL,
 		client: &fasthttp.Client{
@@ -47,6 +74,9 @@ func NewProxyHandler(recorder *Recorder, targetURL string) *ProxyHandler {
 		headerXMockID: []byte("x-mock-id"),
 		tlsConfig:     tlsConfig,
 	}
+	p.client.Dial = p.dial
+
+	return p
 }
 
 // LoadClientCertificate loads a client certificate and key for mTLS authentication
@@ -65,14 +95,90 @@ func (p *ProxyHandler) LoadClientCertificate(certFile, keyFile string) error {
 	return nil
 }
 
+// SetLatencyTracker enables per-endpoint latency tracking for the session.
+func (p *ProxyHandler) SetLatencyTracker(tracker *LatencyTracker) {
+	p.latency = tracker
+}
+
+// SetForwardMode makes the proxy act as a forward (HTTP_PROXY) proxy: each request
+// goes to the host of its absolute URI or Host header, and recordings are
+// written to a per-host subdirectory of the log dir.
+func (p *ProxyHandler) SetForwardMode(enabled bool) {
+	p.forwardMode = enabled
+}
+
 // Handle handles an incoming proxy request.
 func (p *ProxyHandler) Handle(ctx *fasthttp.RequestCtx) {
+	if isMetricsRequest(ctx) {
+		p.serveMetrics(ctx)
+		return
+	}
+
+	if !p.forwardMode {
+		if route := p.matchRoute(ctx.Path()); route != nil {
+			p.forward(ctx, route.Target, route.upstreamPath(string(ctx.Path())), "")
+			return
+		}
+		if p.targetURL == "" {
+			ctx.SetStatusCode(fasthttp.StatusBadGateway)
+			ctx.SetBodyString("Proxy error: no route for " + string(ctx.Path()))
+			return
+		}
+		p.forward(ctx, p.targetURL, string(ctx.Path()), "")
+		return
+	}
+
+	targetBase, err := forwardTarget(ctx)
+	if err != nil {
+		ctx.SetStatusCode(fasthttp.StatusBadRequest)
+		ctx.SetBodyString("Forward proxy error: " + err.Error())
+		return
+	}
+	// Forwarded traffic is grouped by upstream host
+	p.forward(ctx, targetBase, string(ctx.Path()), hostDirName(targetBase))
+}
+
+// forwardTarget derives scheme://host[:port] from the request's absolute URI or Host header.
+func forwardTarget(ctx *fasthttp.RequestCtx) (string, error) {
+	uri := ctx.URI()
+	host := string(uri.Host())
+	if host == "" {
+		return "", errors.New("request has neither an absolute URI nor a Host header")
+	}
+	// An origin-form request addressed to the proxy itself would loop forever
+	if local := ctx.LocalAddr(); local != nil && strings.EqualFold(host, local.String()) {
+		return "", fmt.Errorf("request is addressed to the proxy itself (%s); send absolute URIs via HTTP_PROXY", host)
+	}
+	return string(uri.Scheme()) + "://" + host, nil
+}
+
+// hostDirName turns scheme://host[:port] into a directory name safe on all platforms.
+func hostDirName(targetBase string) string {
+	host := targetBase
+	if idx := strings.Index(host, "://"); idx >= 0 {
+		host = host[idx+3:]
+	}
+	return strings.NewReplacer(":", "_", "/", "_", "\\", "_").Replace(strings.ToLower(host))
+}
+
+// forward proxies a request to targetBase (scheme://host[:port]) and records the
+// exchange. upstreamPath is the path sent upstream; the recording keeps the
+// path the client used. hostDir, when set, groups the recording by upstream host.
+func (p *ProxyHandler) forward(ctx *fasthttp.RequestCtx, targetBase, upstreamPath, hostDir string) {
+	if !p.acquire(ctx) {
+		return
+	}
+	defer p.release()
+
 	// Generate request ID
 	requestID := p.recorder.generateRequestID()
 
 	// Extract x-mock-id from headers
 	mockID := string(ctx.Request.Header.PeekBytes(p.headerXMockID))
 
+	// x-mock-note labels the recording; it is neither forwarded nor kept as a header
+	note := strings.TrimSpace(string(ctx.Request.Header.Peek(headerMockNote)))
+
 	// Log incoming request
 	logMockID := mockID
 	if logMockID == "" {
@@ -83,16 +189,29 @@ func (p *ProxyHandler) Handle(ctx *fasthttp.RequestCtx) {
 	// Prepare request data for later recording
 	reqHeaders := make(map[string]string)
 	ctx.Request.Header.VisitAll(func(key, value []byte) {
-		reqHeaders[string(key)] = string(value)
+		if !strings.EqualFold(string(key), headerMockNote) {
+			reqHeaders[string(key)] = string(value)
+		}
 	})
 	if mockID != "" {
 		reqHeaders["x-mock-id"] = mockID
 	}
 
-	// Parse request body as JSON if possible
+	// Parse request body as JSON if possible; large uploads are streamed instead
 	var reqBody interface{}
-	requestBodyBytes := ctx.Request.Body()
-	if len(requestBodyBytes) > 0 {
+	var uploadStream io.Reader
+	if p.shouldStreamUpload(ctx) {
+		body, stream, closeUpload, err := p.openUploadStream(ctx, hostDir, mockID, requestID)
+		if err != nil {
+			log.Printf("[%s] ❌ Upload capture error: %v", requestID, err)
+			ctx.SetStatusCode(fasthttp.StatusInternalServerError)
+			ctx.SetBodyString("Upload capture error: " + err.Error())
+			return
+		}
+		defer closeUpload()
+		reqBody = body
+		uploadStream = stream
+	} else if requestBodyBytes := ctx.Request.Body(); len(requestBodyBytes) > 0 {
 		var jsonBody interface{}
 		if err := json.Unmarshal(requestBodyBytes, &jsonBody); err == nil {
 			reqBody = jsonBody
@@ -111,7 +230,10 @@ func (p *ProxyHandler) Handle(ctx *fasthttp.RequestCtx) {
 		Headers:   reqHeaders,
 		Body:      reqBody,
 		MockID:    mockID,
+		HostDir:   hostDir,
+		Note:      note,
 	}
+	reqData.Sequence, reqData.SessionOffset = p.recorder.nextSequence()
 
 	// Prepare the proxied request
 	req := fasthttp.AcquireRequest()
@@ -119,10 +241,10 @@ func (p *ProxyHandler) Handle(ctx *fasthttp.RequestCtx) {
 	defer fasthttp.ReleaseRequest(req)
 	defer fasthttp.ReleaseResponse(resp)
 
-	// Build target URL: targetURL + request path + query
+	// Build target URL: targetBase + upstream path + query
 	path := string(ctx.Path())
 	queryString := ctx.URI().QueryString()
-	targetURL := p.targetURL + path
+	targetURL := targetBase + upstreamPath
 	if len(queryString) > 0 {
 		targetURL += "?" + string(queryString)
 	}
@@ -131,51 +253,103 @@ func (p *ProxyHandler) Handle(ctx *fasthttp.RequestCtx) {
 	req.SetRequestURI(targetURL)
 	req.Header.SetMethod(string(ctx.Method()))
 
-	// Copy headers (except Host and x-mock-id)
+	// Copy headers (except Host and the x-mock-* control headers)
 	ctx.Request.Header.VisitAll(func(key, value []byte) {
 		keyStr := string(key)
 		keyLower := strings.ToLower(keyStr)
-		if keyLower != "host" && keyLower != "x-mock-id" {
+		if keyLower != "host" && keyLower != "x-mock-id" && keyLower != headerMockNote {
 			req.Header.SetBytesKV(key, value)
 		}
 	})
 
 	// Copy body
-	req.SetBody(ctx.Request.Body())
+	if uploadStream != nil {
+		req.SetBodyStream(uploadStream, ctx.Request.Header.ContentLength())
+	} else {
+		req.SetBody(ctx.Request.Body())
+	}
+
+	// The client already got its interim 100 Continue from this server and the
+	// body is fully read, so upstream must not be asked to wait for one again
+	if ctx.Request.MayContinue() {
+		req.Header.Del("Expect")
+		reqData.ExpectContinue = true
+	}
 
 	// Remove proxy-specific headers
 	req.Header.Del("Proxy-Connection")
 	req.Header.Del("Proxy-Authenticate")
 	req.Header.Del("Proxy-Authorization")
 
+	// WebSocket upgrades are tunneled frame by frame over a hijacked connection
+	if isWebSocketUpgrade(ctx) {
+		p.handleWebSocket(ctx, req, reqData, targetBase)
+		return
+	}
+
 	// Check Accept header to detect SSE request
 	acceptHeader := string(ctx.Request.Header.Peek("Accept"))
 	expectSSE := strings.Contains(acceptHeader, "text/event-stream")
 
 	if expectSSE {
 		// Handle SSE with streaming
-		p.handleSSEStreaming(ctx, req, reqData)
+		p.handleSSEStreaming(ctx, req, reqData, targetBase)
 		return
 	}
 
-	// Forward the request (non-SSE)
-	startTime := time.Now()
-	err := p.client.Do(req, resp)
-	elapsedSeconds := time.Since(startTime).Seconds()
+	// A repeated GET may be answered with the response recorded for the first one
+	var elapsedSeconds float64
+	var cacheKey string
+	if uploadStream == nil {
+		cacheKey = p.cache.cacheKey(ctx, targetURL, reqData)
+	}
+	if cacheKey != "" {
+		if cached, ok := p.cache.get(cacheKey, resp); ok {
+			elapsedSeconds = cached.delay
+			reqData.CachedFrom = cached.requestID
+		}
+	}
 
-	if err != nil {
-		log.Printf("[%s] ❌ Proxy error: %v", requestID, err)
-		ctx.SetStatusCode(fasthttp.StatusBadGateway)
-		ctx.SetBodyString("Proxy error: " + err.Error())
-		return
+	if reqData.CachedFrom == "" {
+		// Forward the request (non-SSE)
+		startTime := time.Now()
+		err := p.client.Do(req, resp)
+		elapsedSeconds = time.Since(startTime).Seconds()
+
+		if p.latency != nil {
+			p.latency.Observe(reqData.Method, path, elapsedSeconds, err != nil)
+		}
+
+		if err != nil {
+			log.Printf("[%s] ❌ Proxy error: %v", requestID, err)
+			ctx.SetStatusCode(fasthttp.StatusBadGateway)
+			ctx.SetBodyString("Proxy error: " + err.Error())
+			return
+		}
+
+		// Normalize the response before it is recorded and returned
+		if err := p.rewriter.Response(reqData.Method, path, resp); err != nil {
+			log.Printf("[%s] ⚠️  Failed to rewrite response: %v", requestID, err)
+		}
 	}
 
 	// Record the request/response pair
-	if err := p.recorder.RecordPair(reqData, resp, elapsedSeconds); err != nil {
+	if err := p.recorder.RecordPair(reqData, resp, elapsedSeconds); errors.Is(err, ErrRecordSkipped) {
+		log.Printf("[%s] ⏭️  Not recorded (condition)", requestID)
+	} else if err != nil {
 		log.Printf("[%s] ⚠️  Failed to record: %v", requestID, err)
 	}
 
-	log.Printf("[%s] ✓ %d %s (%.3fs)", requestID, resp.StatusCode(), http.StatusText(resp.StatusCode()), elapsedSeconds)
+	// Cache after recording so hits point at the request_id that was written
+	if cacheKey != "" && reqData.CachedFrom == "" {
+		p.cache.put(cacheKey, resp, elapsedSeconds, reqData.RequestID)
+	}
+
+	if reqData.CachedFrom != "" {
+		log.Printf("[%s] ♻️  %d %s (cached from %s)", requestID, resp.StatusCode(), http.StatusText(resp.StatusCode()), reqData.CachedFrom)
+	} else {
+		log.Printf("[%s] ✓ %d %s (%.3fs)", requestID, resp.StatusCode(), http.StatusText(resp.StatusCode()), elapsedSeconds)
+	}
 
 	// Copy response to client
 	ctx.SetStatusCode(resp.StatusCode())
@@ -199,45 +373,11 @@ func (p *ProxyHandler) Handle(ctx *fasthttp.RequestCtx) {
 }
 
 // handleSSEStreaming handles SSE requests with true streaming and event recording
-func (p *ProxyHandler) handleSSEStreaming(ctx *fasthttp.RequestCtx, req *fasthttp.Request, reqData *RequestData) {
+func (p *ProxyHandler) handleSSEStreaming(ctx *fasthttp.RequestCtx, req *fasthttp.Request, reqData *RequestData, targetBase string) {
 	log.Printf("[%s] 📡 SSE streaming started", reqData.RequestID)
 	startTime := time.Now()
 
-	// Determine if target is HTTPS
-	isHTTPS := strings.HasPrefix(p.targetURL, "https://")
-
-	// Extract host for connection
-	targetHost := strings.TrimPrefix(p.targetURL, "http://")
-	targetHost = strings.TrimPrefix(targetHost, "https://")
-
-	// If no port specified, add default port
-	if !strings.Contains(targetHost, ":") {
-		if isHTTPS {
-			targetHost += ":443"
-		} else {
-			targetHost += ":80"
-		}
-	}
-
-	log.Printf("[%s] SSE connecting to %s (HTTPS: %v)", reqData.RequestID, targetHost, isHTTPS)
-
-	// Connect to upstream
-	var conn net.Conn
-	var err error
-
-	if isHTTPS {
-		// For HTTPS, use TLS connection with configured TLS config (includes client certs if loaded)
-		conn, err = tls.DialWithDialer(
-			&net.Dialer{Timeout: 10 * time.Second},
-			"tcp",
-			targetHost,
-			p.tlsConfig,
-		)
-	} else {
-		// For HTTP, use plain TCP
-		conn, err = net.DialTimeout("tcp", targetHost, 10*time.Second)
-	}
-
+	conn, err := p.dialUpstream(targetBase, reqData.RequestID, "SSE")
 	if err != nil {
 		log.Printf("[%s] ❌ SSE connection error: %v", reqData.RequestID, err)
 		ctx.SetStatusCode(fasthttp.StatusBadGateway)
@@ -276,6 +416,10 @@ func (p *ProxyHandler) handleSSEStreaming(ctx *fasthttp.RequestCtx, req *fasthtt
 		return
 	}
 
+	// Header rewrites apply once; data lines are rewritten as they stream
+	rewrites := p.rewriter.match(reqData.Method, string(ctx.Path()))
+	rewriteHeaders(rewrites, &resp.Header)
+
 	// Copy headers to client
 	log.Printf("[%s] SSE response status: %d", reqData.RequestID, resp.StatusCode())
 	ctx.SetStatusCode(resp.StatusCode())
@@ -300,9 +444,8 @@ func (p *ProxyHandler) handleSSEStreaming(ctx *fasthttp.RequestCtx, req *fasthtt
 	// Check if response is chunked
 	isChunked := string(resp.Header.Peek("Transfer-Encoding")) == "chunked"
 
-	// Prepare for streaming
-	events := []interface{}{}
-	currentEvent := &bytes.Buffer{}
+	// Prepare for streaming; the capture may be finalized early by the SSE limits
+	capture := p.newSSECapture(reqData, resp, savedHeaders, startTime)
 
 	// Stream body: read line → send to client → accumulate for log
 	ctx.Response.SetBodyStreamWriter(func(w *bufio.Writer) {
@@ -348,90 +491,31 @@ func (p *ProxyHandler) handleSSEStreaming(ctx *fasthttp.RequestCtx, req *fasthtt
 					if line == "" && len(lines) == 1 {
 						continue // Skip empty chunks
 					}
+					line = rewriteSSELine(rewrites, line)
 
 					lineNum++
-					elapsed := time.Since(startTime).Seconds()
 
 					// Send line to client
 					w.WriteString(line + "\n")
 					w.Flush()
 
 					// Accumulate for recording
-					currentEvent.WriteString(line + "\n")
-
-					// Empty line = end of SSE event
-					if line == "" && currentEvent.Len() > 1 {
-						eventStr := currentEvent.String()
-						eventLines := strings.Split(strings.TrimSpace(eventStr), "\n")
-
-						// Parse data lines
-						for _, l := range eventLines {
-							if strings.HasPrefix(l, "data: ") {
-								dataStr := strings.TrimPrefix(l, "data: ")
-
-								// Try parse as JSON
-								var jsonData interface{}
-								if err := json.Unmarshal([]byte(dataStr), &jsonData); err == nil {
-									events = append(events, map[string]interface{}{
-										"data":      jsonData,
-										"timestamp": elapsed,
-									})
-								} else {
-									events = append(events, map[string]interface{}{
-										"data":      dataStr,
-										"timestamp": elapsed,
-									})
-								}
-							}
-						}
-
-						currentEvent.Reset()
-					}
+					capture.line(line)
 				}
 			}
 		} else {
 			// Non-chunked - read line by line
 			scanner := bufio.NewScanner(br)
 			for scanner.Scan() {
-				line := scanner.Text()
+				line := rewriteSSELine(rewrites, scanner.Text())
 				lineNum++
-				elapsed := time.Since(startTime).Seconds()
 
 				// Send line to client
 				w.WriteString(line + "\n")
 				w.Flush()
 
 				// Accumulate for recording
-				currentEvent.WriteString(line + "\n")
-
-				// Empty line = end of SSE event
-				if line == "" && currentEvent.Len() > 1 {
-					eventStr := currentEvent.String()
-					eventLines := strings.Split(strings.TrimSpace(eventStr), "\n")
-
-					// Parse data lines
-					for _, l := range eventLines {
-						if strings.HasPrefix(l, "data: ") {
-							dataStr := strings.TrimPrefix(l, "data: ")
-
-							// Try parse as JSON
-							var jsonData interface{}
-							if err := json.Unmarshal([]byte(dataStr), &jsonData); err == nil {
-								events = append(events, map[string]interface{}{
-									"data":      jsonData,
-									"timestamp": elapsed,
-								})
-							} else {
-								events = append(events, map[string]interface{}{
-									"data":      dataStr,
-									"timestamp": elapsed,
-								})
-							}
-						}
-					}
-
-					currentEvent.Reset()
-				}
+				capture.line(line)
 			}
 
 		}
@@ -441,18 +525,22 @@ func (p *ProxyHandler) handleSSEStreaming(ctx *fasthttp.RequestCtx, req *fasthtt
 
 		// Streaming finished - save to log
 		elapsedSeconds := time.Since(startTime).Seconds()
-		if err := p.recorder.RecordSSEPair(reqData, resp, events, elapsedSeconds, savedHeaders); err != nil {
-			log.Printf("[%s] ⚠️  Failed to record SSE: %v", reqData.RequestID, err)
-		} else {
-			log.Printf("[%s] ✓ SSE completed: %d events recorded (%.3fs)", reqData.RequestID, len(events), elapsedSeconds)
+		if p.latency != nil {
+			p.latency.Observe(reqData.Method, string(req.URI().Path()), elapsedSeconds, false)
 		}
+		capture.finish("")
 	})
 }
 
-// HandleConnect handles CONNECT requests for HTTPS tunneling.
+// HandleConnect handles CONNECT requests for HTTPS tunneling. Tunnels are only
+// accepted in MITM mode, where they are decrypted and recorded.
 func (p *ProxyHandler) HandleConnect(ctx *fasthttp.RequestCtx) {
-	// For now, reject CONNECT requests
-	// Full HTTPS proxy support requires more complex tunneling
+	if p.mitm != nil {
+		p.interceptConnect(ctx)
+		return
+	}
+
+	// Opaque tunnels cannot be recorded, so reject them
 	ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
-	ctx.SetBodyString("CONNECT method not supported. Use HTTP proxy mode only.")
+	ctx.SetBodyString("CONNECT method not supported. Use HTTP proxy mode or enable MITM with -mitm-ca-cert/-mitm-ca-key.")
 }
@@ -0,0 +1,254 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// startUpstream serves handler on a random local port and returns its base URL.
+func startUpstream(t *testing.T, handler fasthttp.RequestHandler) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &fasthttp.Server{Handler: handler}
+	go server.Serve(ln)
+	t.Cleanup(func() { server.Shutdown() })
+
+	return "http://" + ln.Addr().String()
+}
+
+func TestExpectContinueRecorded(t *testing.T) {
+	var upstreamExpect string
+	upstream := startUpstream(t, func(ctx *fasthttp.RequestCtx) {
+		upstreamExpect = string(ctx.Request.Header.Peek("Expect"))
+		ctx.SetContentType("application/json")
+		ctx.SetBodyString(`{"uploaded":true}`)
+	})
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	p := NewProxyHandler(recorder, upstream)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/upload")
+	ctx.Request.Header.SetMethod("PUT")
+	ctx.Request.Header.Set("Expect", "100-continue")
+	ctx.Request.SetBodyString(`{"file":"data"}`)
+
+	p.Handle(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	if upstreamExpect != "" {
+		t.Errorf("Expected Expect header to be stripped upstream, got %q", upstreamExpect)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "default", "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one recording, got %v (%v)", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Failed to read recording: %v", err)
+	}
+
+	var record struct {
+		Response struct {
+			Interim []struct {
+				StatusCode int `json:"status_code"`
+			} `json:"interim_responses"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("Failed to parse recording: %v", err)
+	}
+	if len(record.Response.Interim) != 1 || record.Response.Interim[0].StatusCode != 100 {
+		t.Fatalf("Expected interim 100 to be recorded, got %+v", record.Response.Interim)
+	}
+}
+
+func TestStreamedUploadCaptured(t *testing.T) {
+	var upstreamBody []byte
+	upstream := startUpstream(t, func(ctx *fasthttp.RequestCtx) {
+		upstreamBody = append([]byte(nil), ctx.Request.Body()...)
+		ctx.SetContentType("application/json")
+		ctx.SetBodyString(`{"uploaded":true}`)
+	})
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	p := NewProxyHandler(recorder, upstream)
+	if err := p.SetUploadStreaming("1KB", true); err != nil {
+		t.Fatalf("SetUploadStreaming failed: %v", err)
+	}
+
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 256) // 4KB
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/upload")
+	ctx.Request.Header.SetMethod("PUT")
+	ctx.Request.Header.Set("x-mock-id", "uploads-test")
+	ctx.Request.SetBodyStream(bytes.NewReader(payload), len(payload))
+
+	p.Handle(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	if !bytes.Equal(upstreamBody, payload) {
+		t.Fatalf("Upstream received %d bytes, expected %d", len(upstreamBody), len(payload))
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "uploads-test", "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one recording, got %v (%v)", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Failed to read recording: %v", err)
+	}
+
+	var record struct {
+		Request struct {
+			Body struct {
+				Streamed bool   `json:"streamed"`
+				Size     int    `json:"size"`
+				File     string `json:"file"`
+			} `json:"body"`
+		} `json:"request"`
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("Failed to parse recording: %v", err)
+	}
+	body := record.Request.Body
+	if !body.Streamed || body.Size != len(payload) || body.File == "" {
+		t.Fatalf("Unexpected streamed body record: %+v", body)
+	}
+
+	captured, err := os.ReadFile(filepath.Join(dir, "uploads-test", body.File))
+	if err != nil {
+		t.Fatalf("Failed to read captured upload: %v", err)
+	}
+	if !bytes.Equal(captured, payload) {
+		t.Fatalf("Captured %d bytes, expected %d", len(captured), len(payload))
+	}
+}
+
+func TestForwardModeRecordsPerHost(t *testing.T) {
+	upstream := startUpstream(t, func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType("application/json")
+		ctx.SetBodyString(`{"path":"` + string(ctx.Path()) + `"}`)
+	})
+	hostPort := strings.TrimPrefix(upstream, "http://")
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	p := NewProxyHandler(recorder, "")
+	p.SetForwardMode(true)
+
+	// Absolute-form request as sent by clients using HTTP_PROXY
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI(upstream + "/items?page=2")
+	ctx.Request.Header.SetMethod("GET")
+	p.Handle(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusOK || string(ctx.Response.Body()) != `{"path":"/items"}` {
+		t.Fatalf("Unexpected response %d: %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+
+	hostDir := strings.ReplaceAll(hostPort, ":", "_")
+	files, err := filepath.Glob(filepath.Join(dir, hostDir, "default", "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one recording under %s, got %v (%v)", hostDir, files, err)
+	}
+
+	// Origin-form requests use the Host header
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/other")
+	ctx.Request.Header.SetHost(hostPort)
+	ctx.Request.Header.SetMethod("GET")
+	ctx.Request.Header.Set("x-mock-id", "host-header")
+	p.Handle(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("Expected Host header request to be forwarded, got %d: %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	files, err = filepath.Glob(filepath.Join(dir, hostDir, "host-header", "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one recording for the Host header request, got %v (%v)", files, err)
+	}
+}
+
+func TestMockNoteAnnotatesRecording(t *testing.T) {
+	var upstreamNote []byte
+	upstream := startUpstream(t, func(ctx *fasthttp.RequestCtx) {
+		upstreamNote = append([]byte(nil), ctx.Request.Header.Peek("x-mock-note")...)
+		ctx.SetContentType("application/json")
+		ctx.SetBodyString(`{"cart":[]}`)
+	})
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	p := NewProxyHandler(recorder, upstream)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/cart")
+	ctx.Request.Header.SetMethod("GET")
+	ctx.Request.Header.Set("X-Mock-Note", "empty cart after coupon removal")
+	p.Handle(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	if upstreamNote != nil {
+		t.Errorf("Expected x-mock-note to be stripped before forwarding, upstream got %q", upstreamNote)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "default", "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one recording, got %v (%v)", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Failed to read recording: %v", err)
+	}
+	var record struct {
+		Note    string `json:"note"`
+		Request struct {
+			Headers map[string]string `json:"headers"`
+		} `json:"request"`
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("Failed to parse recording: %v", err)
+	}
+	if record.Note != "empty cart after coupon removal" {
+		t.Errorf("Expected the note in the recording, got %q", record.Note)
+	}
+	for key := range record.Request.Headers {
+		if strings.EqualFold(key, "x-mock-note") {
+			t.Errorf("Expected x-mock-note to be kept out of the recorded headers, got %v", record.Request.Headers)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+)
+
+// Recording file formats.
+const (
+	// FormatJSON writes the native recording schema.
+	FormatJSON = "json"
+	// FormatHAR writes every recording as an HTTP Archive 1.2 with a single entry.
+	FormatHAR = "har"
+)
+
+// harCreator identifies auto-proxy in the HAR files it writes.
+var harCreator = storage.HARCreator{Name: "auto-proxy", Version: "0.1.0"}
+
+// SetFormat selects the file format of recordings (FormatJSON or FormatHAR).
+func (r *Recorder) SetFormat(format string) error {
+	switch format {
+	case "", FormatJSON:
+		r.format = FormatJSON
+	case FormatHAR:
+		r.format = FormatHAR
+	default:
+		return fmt.Errorf("unknown recording format %q (expected %s or %s)", format, FormatJSON, FormatHAR)
+	}
+	return nil
+}
+
+// fileExtension returns the extension of recorded files, including the dot.
+func (r *Recorder) fileExtension() string {
+	if r.format == FormatHAR {
+		return ".har"
+	}
+	return ".json"
+}
+
+// marshalHAR converts a native record to an indented single-entry HAR document.
+func marshalHAR(record map[string]interface{}) ([]byte, error) {
+	// Round-trip through JSON so the record has the types a loaded file has
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+
+	entry, err := storage.NewHAREntry(decoded)
+	if err != nil {
+		return nil, err
+	}
+	har := storage.HAR{Log: storage.HARLog{
+		Version: "1.2",
+		Creator: harCreator,
+		Entries: []storage.HAREntry{entry},
+	}}
+	return json.MarshalIndent(har, "", "  ")
+}
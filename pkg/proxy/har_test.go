@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+func TestHARRecordingsReplay(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	if err := recorder.SetFormat("xml"); err == nil {
+		t.Fatal("Expected an error for an unknown format")
+	}
+	if err := recorder.SetFormat(FormatHAR); err != nil {
+		t.Fatalf("Failed to set format: %v", err)
+	}
+
+	// Compressed JSON response
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	resp.Header.SetContentType("application/json")
+	resp.Header.Set("Content-Encoding", "gzip")
+	resp.SetBody(fasthttp.AppendGzipBytes(nil, []byte(`{"id":7}`)))
+	reqData := &RequestData{RequestID: "json-1", Timestamp: "2025-01-02T10:00:00Z", Method: "POST",
+		URL: "http://api.example.com/items?page=2", Headers: map[string]string{"Content-Type": "application/json"},
+		Body: map[string]interface{}{"name": "pen"}}
+	if err := recorder.RecordPair(reqData, resp, 0.25); err != nil {
+		t.Fatalf("Failed to record: %v", err)
+	}
+
+	// SSE stream
+	sseResp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(sseResp)
+	events := []interface{}{newSSEEventRecord(`{"n":1}`, 0.1), newSSEEventRecord("bye", 0.3)}
+	reqData = &RequestData{RequestID: "sse-1", Method: "GET", URL: "http://api.example.com/stream", Headers: map[string]string{}}
+	if err := recorder.RecordSSEPair(reqData, sseResp, events, 0.3, map[string]string{"Content-Type": "text/event-stream"}); err != nil {
+		t.Fatalf("Failed to record SSE: %v", err)
+	}
+
+	// WebSocket conversation
+	wsResp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(wsResp)
+	wsResp.SetStatusCode(fasthttp.StatusSwitchingProtocols)
+	frames := []storage.WebSocketFrame{
+		{FromClient: true, Timestamp: 0.5, Opcode: storage.WebSocketText, Fin: true, Payload: []byte("hi")},
+		{Timestamp: 1.25, Opcode: storage.WebSocketBinary, Fin: true, Payload: []byte{0xff, 0x00}},
+	}
+	reqData = &RequestData{RequestID: "ws-1", Timestamp: "2025-01-02T10:00:00Z", Method: "GET", URL: "http://api.example.com/ws", Headers: map[string]string{}}
+	if err := recorder.RecordWebSocket(reqData, wsResp, frames, 0.01); err != nil {
+		t.Fatalf("Failed to record WebSocket: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "default", "*.har"))
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 .har files, got %d", len(files))
+	}
+	for _, file := range files {
+		data, _ := os.ReadFile(file)
+		var har storage.HAR
+		if err := json.Unmarshal(data, &har); err != nil || har.Log.Version != "1.2" || len(har.Log.Entries) != 1 {
+			t.Fatalf("%s is not a single-entry HAR 1.2 document: %v", file, err)
+		}
+	}
+
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to load recordings: %v", err)
+	}
+
+	mock := store.FindResponse("/items", "default", "application/json", "POST")
+	if mock == nil || string(mock.Body) != `{"id":7}` || mock.RequestID != "json-1" || mock.Delay != 0.25 {
+		t.Fatalf("Unexpected JSON mock: %+v", mock)
+	}
+
+	mock = store.FindResponse("/stream", "default", "text/event-stream", "GET")
+	if mock == nil || len(mock.SSEEvents) != 2 || string(mock.SSEEvents[1].SerializedData) != "bye" {
+		t.Fatalf("Unexpected SSE mock: %+v", mock)
+	}
+
+	mock = store.FindResponse("/ws", "default", storage.WebSocketContentType, "GET")
+	if mock == nil || len(mock.WebSocketFrames) != 2 {
+		t.Fatalf("Unexpected WebSocket mock: %+v", mock)
+	}
+	for i, frame := range mock.WebSocketFrames {
+		want := frames[i]
+		if frame.FromClient != want.FromClient || frame.Opcode != want.Opcode || string(frame.Payload) != string(want.Payload) ||
+			frame.Timestamp < want.Timestamp-0.001 || frame.Timestamp > want.Timestamp+0.001 {
+			t.Fatalf("Frame %d: expected %+v, got %+v", i, want, frame)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"strings"
+)
+
+// alwaysPersistedHeaders are needed to replay a recording and are never filtered out.
+var alwaysPersistedHeaders = map[string]bool{
+	"content-type": true,
+	"x-mock-id":    true,
+}
+
+// HeaderFilter decides which request/response headers are written to mock files.
+// Patterns are case-insensitive; a trailing "*" matches any suffix ("x-amz-*").
+type HeaderFilter struct {
+	keep []string // When non-empty, only matching headers are persisted
+	drop []string // Matching headers are never persisted
+}
+
+// ParseHeaderPatterns splits a comma-separated header list into lowercase patterns.
+func ParseHeaderPatterns(list string) []string {
+	var patterns []string
+	for _, item := range strings.Split(list, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item != "" {
+			patterns = append(patterns, item)
+		}
+	}
+	return patterns
+}
+
+// NewHeaderFilter creates a filter from allowlist and denylist patterns.
+func NewHeaderFilter(keep, drop []string) *HeaderFilter {
+	return &HeaderFilter{keep: keep, drop: drop}
+}
+
+// Allows reports whether a header should be persisted.
+func (f *HeaderFilter) Allows(name string) bool {
+	name = strings.ToLower(name)
+	if alwaysPersistedHeaders[name] {
+		return true
+	}
+	if matchHeaderPattern(f.drop, name) {
+		return false
+	}
+	return len(f.keep) == 0 || matchHeaderPattern(f.keep, name)
+}
+
+// Apply returns a copy of headers without the filtered entries.
+func (f *HeaderFilter) Apply(headers map[string]string) map[string]string {
+	if f == nil {
+		return headers
+	}
+	filtered := make(map[string]string, len(headers))
+	for key, value := range headers {
+		if f.Allows(key) {
+			filtered[key] = value
+		}
+	}
+	return filtered
+}
+
+func matchHeaderPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SetHeaderFilter limits which headers are written to recorded files.
+func (r *Recorder) SetHeaderFilter(filter *HeaderFilter) {
+	r.headerFilter = filter
+}
@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"testing"
+)
+
+func TestHeaderFilterAllows(t *testing.T) {
+	filter := NewHeaderFilter(nil, ParseHeaderPatterns("Cookie, set-cookie ,cf-*"))
+
+	tests := map[string]bool{
+		"Cookie":       false,
+		"Set-Cookie":   false,
+		"CF-Ray":       false,
+		"Accept":       true,
+		"Content-Type": true,
+	}
+	for name, want := range tests {
+		if got := filter.Allows(name); got != want {
+			t.Errorf("Denylist Allows(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	filter = NewHeaderFilter(ParseHeaderPatterns("accept,x-request-*"), ParseHeaderPatterns("x-request-secret"))
+	tests = map[string]bool{
+		"Accept":           true,
+		"X-Request-Id":     true,
+		"X-Request-Secret": false,
+		"Authorization":    false,
+		"Content-Type":     true, // Needed for replay
+		"x-mock-id":        true,
+	}
+	for name, want := range tests {
+		if got := filter.Allows(name); got != want {
+			t.Errorf("Allowlist Allows(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestHeaderFilterApplyNil(t *testing.T) {
+	var filter *HeaderFilter
+	headers := map[string]string{"Cookie": "a=b"}
+	if got := filter.Apply(headers); len(got) != 1 {
+		t.Fatalf("Expected nil filter to keep all headers, got %v", got)
+	}
+}
@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyBucketBounds are histogram upper bounds in seconds; the last bucket is open-ended.
+var latencyBucketBounds = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// LatencyTracker keeps per-endpoint latency histograms for a recording session.
+type LatencyTracker struct {
+	mutex     sync.Mutex
+	startedAt time.Time
+	endpoints map[string]*latencyHistogram
+}
+
+type latencyHistogram struct {
+	method  string
+	path    string
+	count   int
+	errors  int
+	sum     float64
+	min     float64
+	max     float64
+	buckets []int // len(latencyBucketBounds)+1, last one is +Inf
+}
+
+// NewLatencyTracker creates an empty tracker.
+func NewLatencyTracker() *LatencyTracker {
+	return &LatencyTracker{
+		startedAt: time.Now(),
+		endpoints: make(map[string]*latencyHistogram),
+	}
+}
+
+// Observe records a single upstream round trip for method+path.
+// Failed requests are counted separately and do not affect the histogram.
+func (t *LatencyTracker) Observe(method, path string, seconds float64, failed bool) {
+	key := method + " " + path
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	h, ok := t.endpoints[key]
+	if !ok {
+		h = &latencyHistogram{
+			method:  method,
+			path:    path,
+			min:     math.MaxFloat64,
+			buckets: make([]int, len(latencyBucketBounds)+1),
+		}
+		t.endpoints[key] = h
+	}
+
+	if failed {
+		h.errors++
+		return
+	}
+
+	h.count++
+	h.sum += seconds
+	if seconds < h.min {
+		h.min = seconds
+	}
+	if seconds > h.max {
+		h.max = seconds
+	}
+
+	idx := sort.SearchFloat64s(latencyBucketBounds, seconds)
+	h.buckets[idx]++
+}
+
+// percentile estimates the q-th quantile using bucket upper bounds (capped at max).
+func (h *latencyHistogram) percentile(q float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(q * float64(h.count)))
+	seen := 0
+	for i, n := range h.buckets {
+		seen += n
+		if seen >= rank {
+			if i < len(latencyBucketBounds) && latencyBucketBounds[i] < h.max {
+				return latencyBucketBounds[i]
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
+
+// Report builds a JSON-friendly summary sorted by method and path.
+func (t *LatencyTracker) Report() map[string]interface{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	keys := make([]string, 0, len(t.endpoints))
+	for key := range t.endpoints {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	endpoints := make([]map[string]interface{}, 0, len(keys))
+	totalRequests := 0
+	for _, key := range keys {
+		h := t.endpoints[key]
+		totalRequests += h.count + h.errors
+
+		buckets := make([]map[string]interface{}, 0, len(h.buckets))
+		for i, n := range h.buckets {
+			le := "+Inf"
+			if i < len(latencyBucketBounds) {
+				le = formatBound(latencyBucketBounds[i])
+			}
+			buckets = append(buckets, map[string]interface{}{"le": le, "count": n})
+		}
+
+		entry := map[string]interface{}{
+			"method":  h.method,
+			"path":    h.path,
+			"count":   h.count,
+			"errors":  h.errors,
+			"buckets": buckets,
+		}
+		if h.count > 0 {
+			entry["min"] = h.min
+			entry["max"] = h.max
+			entry["mean"] = h.sum / float64(h.count)
+			entry["p50"] = h.percentile(0.50)
+			entry["p90"] = h.percentile(0.90)
+			entry["p99"] = h.percentile(0.99)
+		}
+		endpoints = append(endpoints, entry)
+	}
+
+	return map[string]interface{}{
+		"started_at":       t.startedAt.UTC().Format(time.RFC3339Nano),
+		"duration_seconds": time.Since(t.startedAt).Seconds(),
+		"total_requests":   totalRequests,
+		"endpoints":        endpoints,
+	}
+}
+
+// WriteReport writes the summary report to a JSON file.
+func (t *LatencyTracker) WriteReport(path string) error {
+	data, err := json.MarshalIndent(t.Report(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// formatBound renders a bucket bound without trailing zeros.
+func formatBound(v float64) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,37 @@
+package proxy
+
+import "testing"
+
+func TestLatencyTrackerReport(t *testing.T) {
+	tracker := NewLatencyTracker()
+	for i := 0; i < 9; i++ {
+		tracker.Observe("GET", "/users", 0.02, false)
+	}
+	tracker.Observe("GET", "/users", 0.3, false)
+	tracker.Observe("GET", "/users", 0, true)
+	tracker.Observe("POST", "/orders", 1.2, false)
+
+	report := tracker.Report()
+	if report["total_requests"] != 12 {
+		t.Fatalf("Expected 12 total requests, got %v", report["total_requests"])
+	}
+
+	endpoints := report["endpoints"].([]map[string]interface{})
+	if len(endpoints) != 2 {
+		t.Fatalf("Expected 2 endpoints, got %d", len(endpoints))
+	}
+
+	users := endpoints[0]
+	if users["method"] != "GET" || users["path"] != "/users" {
+		t.Fatalf("Expected GET /users first, got %v %v", users["method"], users["path"])
+	}
+	if users["count"] != 10 || users["errors"] != 1 {
+		t.Fatalf("Expected count=10 errors=1, got count=%v errors=%v", users["count"], users["errors"])
+	}
+	if users["p50"] != 0.025 {
+		t.Fatalf("Expected p50 bucket 0.025, got %v", users["p50"])
+	}
+	if users["max"] != 0.3 || users["p99"] != 0.3 {
+		t.Fatalf("Expected max/p99 0.3, got max=%v p99=%v", users["max"], users["p99"])
+	}
+}
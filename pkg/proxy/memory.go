@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Recording is a recording kept in memory instead of being written to disk.
+type Recording struct {
+	Path   string // Where it would have been written, relative to the log dir
+	MockID string
+	Data   []byte // The file contents (native JSON or HAR)
+}
+
+// MemoryStore collects recordings in memory, in the order they were made.
+type MemoryStore struct {
+	mutex      sync.Mutex
+	recordings []Recording
+}
+
+// NewMemoryRecorder creates a recorder that keeps recordings in a MemoryStore
+// instead of writing them to disk, for tests that capture fixtures
+// programmatically. Captured uploads are not supported.
+func NewMemoryRecorder() *Recorder {
+	return &Recorder{
+		memory:    &MemoryStore{},
+		startedAt: time.Now(),
+	}
+}
+
+// Memory returns the in-memory store of a NewMemoryRecorder, or nil.
+func (r *Recorder) Memory() *MemoryStore {
+	return r.memory
+}
+
+func (m *MemoryStore) add(recording Recording) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	// The hash filename strategy overwrites a recording of the same call
+	for i := range m.recordings {
+		if m.recordings[i].Path == recording.Path {
+			m.recordings[i] = recording
+			return
+		}
+	}
+	m.recordings = append(m.recordings, recording)
+}
+
+// Recordings returns a copy of the recordings made so far.
+func (m *MemoryStore) Recordings() []Recording {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]Recording(nil), m.recordings...)
+}
+
+// Reset discards the recordings made so far.
+func (m *MemoryStore) Reset() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.recordings = nil
+}
+
+// WriteTo writes the recordings under dir, laid out as a recording proxy
+// would have written them, so they load as a mock dir.
+func (m *MemoryStore) WriteTo(dir string) error {
+	for _, recording := range m.Recordings() {
+		path := filepath.Join(dir, recording.Path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, recording.Data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/valyala/fasthttp"
+)
+
+// MetricsPath serves the proxy's connection metrics as JSON. It is answered
+// by the proxy itself and never forwarded.
+const MetricsPath = "/__proxy__/metrics"
+
+// proxyMetrics counts the load on a proxy. All fields are updated atomically.
+type proxyMetrics struct {
+	openConns   int64  // Upstream connections currently open
+	dialedConns uint64 // Upstream connections opened since start
+	inFlight    int64  // Requests being forwarded
+	requests    uint64 // Requests received, including shed ones
+	shed        uint64 // Requests rejected with 503 by -max-inflight
+}
+
+// trackedConn decrements the open connection count once when closed.
+type trackedConn struct {
+	net.Conn
+	metrics *proxyMetrics
+	closed  int32
+}
+
+func (c *trackedConn) Close() error {
+	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		atomic.AddInt64(&c.metrics.openConns, -1)
+	}
+	return c.Conn.Close()
+}
+
+// trackConn counts an upstream connection until it is closed.
+func (m *proxyMetrics) trackConn(conn net.Conn, err error) (net.Conn, error) {
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt64(&m.openConns, 1)
+	atomic.AddUint64(&m.dialedConns, 1)
+	return &trackedConn{Conn: conn, metrics: m}, nil
+}
+
+// SetMaxInFlight makes the proxy answer 503 Service Unavailable instead of
+// forwarding when limit requests are already in flight, so a proxy in front of
+// a load test sheds load predictably instead of queueing it. 0 disables the limit.
+func (p *ProxyHandler) SetMaxInFlight(limit int) {
+	p.maxInFlight = int64(limit)
+}
+
+// acquire counts a request as in flight, or answers it with 503 when the
+// limit is reached. Callers must call release when acquire returns true.
+func (p *ProxyHandler) acquire(ctx *fasthttp.RequestCtx) bool {
+	atomic.AddUint64(&p.metrics.requests, 1)
+	inFlight := atomic.AddInt64(&p.metrics.inFlight, 1)
+	if p.maxInFlight > 0 && inFlight > p.maxInFlight {
+		atomic.AddInt64(&p.metrics.inFlight, -1)
+		atomic.AddUint64(&p.metrics.shed, 1)
+		ctx.SetStatusCode(fasthttp.StatusServiceUnavailable)
+		ctx.Response.Header.Set("Retry-After", "1")
+		ctx.SetBodyString("Proxy overloaded: " + strconv.FormatInt(p.maxInFlight, 10) + " requests already in flight")
+		return false
+	}
+	return true
+}
+
+func (p *ProxyHandler) release() {
+	atomic.AddInt64(&p.metrics.inFlight, -1)
+}
+
+// Metrics returns a snapshot of the proxy's connection and queue metrics.
+// SSE streams and WebSocket tunnels count as in flight until their handler
+// returns, and their upstream connections as open until closed.
+func (p *ProxyHandler) Metrics() map[string]interface{} {
+	depth, capacity := p.recorder.QueueDepth()
+	return map[string]interface{}{
+		"open_upstream_connections":  atomic.LoadInt64(&p.metrics.openConns),
+		"upstream_connections_total": atomic.LoadUint64(&p.metrics.dialedConns),
+		"in_flight_requests":         atomic.LoadInt64(&p.metrics.inFlight),
+		"max_in_flight":              p.maxInFlight,
+		"requests_total":             atomic.LoadUint64(&p.metrics.requests),
+		"shed_total":                 atomic.LoadUint64(&p.metrics.shed),
+		"recording_queue_depth":      depth,
+		"recording_queue_capacity":   capacity,
+		"recordings_dropped":         p.recorder.Dropped(),
+	}
+}
+
+// isMetricsRequest reports whether ctx asks for MetricsPath on the proxy
+// itself rather than through it (absolute-form forward proxy requests).
+func isMetricsRequest(ctx *fasthttp.RequestCtx) bool {
+	uri := ctx.Request.Header.RequestURI()
+	return ctx.IsGet() && len(uri) > 0 && uri[0] == '/' && string(ctx.Path()) == MetricsPath
+}
+
+// serveMetrics writes Metrics as JSON.
+func (p *ProxyHandler) serveMetrics(ctx *fasthttp.RequestCtx) {
+	data, err := json.Marshal(p.Metrics())
+	if err != nil {
+		ctx.SetStatusCode(fasthttp.StatusInternalServerError)
+		ctx.SetBodyString(err.Error())
+		return
+	}
+	ctx.SetContentType("application/json")
+	ctx.SetBody(data)
+}
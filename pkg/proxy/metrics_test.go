@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestMaxInFlightShedsLoad(t *testing.T) {
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	upstream := startUpstream(t, func(ctx *fasthttp.RequestCtx) {
+		if string(ctx.Path()) == "/slow" {
+			entered <- struct{}{}
+			<-unblock
+		}
+		ctx.SetContentType("application/json")
+		ctx.SetBodyString(`{"ok":true}`)
+	})
+
+	recorder, err := NewRecorder(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	p := NewProxyHandler(recorder, upstream)
+	p.SetMaxInFlight(1)
+
+	var wg sync.WaitGroup
+	slow := &fasthttp.RequestCtx{}
+	slow.Request.SetRequestURI("/slow")
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.Handle(slow)
+	}()
+	<-entered
+
+	shed := &fasthttp.RequestCtx{}
+	shed.Request.SetRequestURI("/fast")
+	p.Handle(shed)
+	if shed.Response.StatusCode() != fasthttp.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 while the limit is reached, got %d", shed.Response.StatusCode())
+	}
+	if string(shed.Response.Header.Peek("Retry-After")) != "1" {
+		t.Errorf("Expected Retry-After on shed requests, got %q", shed.Response.Header.Peek("Retry-After"))
+	}
+
+	// The metrics endpoint is answered even while the limit is reached
+	metricsCtx := &fasthttp.RequestCtx{}
+	metricsCtx.Request.SetRequestURI(MetricsPath)
+	p.Handle(metricsCtx)
+	var during map[string]float64
+	if err := json.Unmarshal(metricsCtx.Response.Body(), &during); err != nil {
+		t.Fatalf("Failed to parse metrics: %v (%s)", err, metricsCtx.Response.Body())
+	}
+	if during["in_flight_requests"] != 1 || during["open_upstream_connections"] != 1 || during["shed_total"] != 1 {
+		t.Fatalf("Unexpected metrics while a request is in flight: %v", during)
+	}
+
+	close(unblock)
+	wg.Wait()
+	if slow.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("Expected the in-flight request to complete, got %d", slow.Response.StatusCode())
+	}
+
+	fast := &fasthttp.RequestCtx{}
+	fast.Request.SetRequestURI("/fast")
+	p.Handle(fast)
+	if fast.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("Expected 200 once the slot is free, got %d", fast.Response.StatusCode())
+	}
+
+	metrics := p.Metrics()
+	if metrics["in_flight_requests"] != int64(0) || metrics["requests_total"] != uint64(3) || metrics["shed_total"] != uint64(1) {
+		t.Fatalf("Unexpected final metrics: %v", metrics)
+	}
+}
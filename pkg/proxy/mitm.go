@@ -0,0 +1,217 @@
+package proxy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// leafValidity bounds how long generated host certificates are valid.
+const leafValidity = 365 * 24 * time.Hour
+
+// CertAuthority signs per-host leaf certificates for HTTPS interception.
+// Clients must trust the CA certificate for the intercepted connections to verify.
+type CertAuthority struct {
+	cert    *x509.Certificate
+	key     crypto.Signer
+	leafKey *ecdsa.PrivateKey // Shared by all leaves; only the CA key has to stay private
+
+	mutex  sync.Mutex
+	leaves map[string]*tls.Certificate // Host -> generated leaf
+}
+
+// LoadCertAuthority reads a PEM CA certificate and its private key.
+func LoadCertAuthority(certFile, keyFile string) (*CertAuthority, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("read CA certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read CA key: %w", err)
+	}
+	return NewCertAuthority(certPEM, keyPEM)
+}
+
+// NewCertAuthority creates a CertAuthority from PEM-encoded certificate and key.
+func NewCertAuthority(certPEM, keyPEM []byte) (*CertAuthority, error) {
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("load CA key pair: %w", err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("parse CA certificate: %w", err)
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("certificate %q is not a CA", cert.Subject.CommonName)
+	}
+	signer, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported CA key type %T", pair.PrivateKey)
+	}
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate leaf key: %w", err)
+	}
+
+	return &CertAuthority{
+		cert:    cert,
+		key:     signer,
+		leafKey: leafKey,
+		leaves:  make(map[string]*tls.Certificate),
+	}, nil
+}
+
+// leafFor returns a certificate for host, generating and caching it on first use.
+func (ca *CertAuthority) leafFor(host string) (*tls.Certificate, error) {
+	host = strings.ToLower(host)
+
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+
+	if leaf, ok := ca.leaves[host]; ok {
+		return leaf, nil
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	notBefore := time.Now().Add(-time.Hour)
+	notAfter := notBefore.Add(leafValidity)
+	if notAfter.After(ca.cert.NotAfter) {
+		notAfter = ca.cert.NotAfter
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &ca.leafKey.PublicKey, ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("sign certificate for %s: %w", host, err)
+	}
+
+	leaf := &tls.Certificate{
+		Certificate: [][]byte{der, ca.cert.Raw},
+		PrivateKey:  ca.leafKey,
+	}
+	ca.leaves[host] = leaf
+	return leaf, nil
+}
+
+// EnableMITM makes HandleConnect intercept HTTPS tunnels: TLS is terminated with
+// a leaf certificate signed by ca and the decrypted requests are forwarded to
+// the host the client asked for (see mitmTarget) and recorded like plain HTTP
+// traffic, in a per-host subdirectory of the log dir.
+func (p *ProxyHandler) EnableMITM(ca *CertAuthority) {
+	p.mitm = ca
+}
+
+// interceptConnect takes over a CONNECT tunnel and serves the decrypted requests.
+func (p *ProxyHandler) interceptConnect(ctx *fasthttp.RequestCtx) {
+	hostPort := string(ctx.Request.Header.RequestURI())
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil || host == "" {
+		ctx.SetStatusCode(fasthttp.StatusBadRequest)
+		ctx.SetBodyString("CONNECT target must be host:port")
+		return
+	}
+
+	ctx.HijackSetNoResponse(true)
+	ctx.Hijack(func(conn net.Conn) {
+		if _, err := conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+			return
+		}
+
+		tlsConn := tls.Server(conn, &tls.Config{
+			GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+				// Prefer SNI so virtual hosts behind one address get their own name
+				if hello.ServerName != "" {
+					return p.mitm.leafFor(hello.ServerName)
+				}
+				return p.mitm.leafFor(host)
+			},
+		})
+		if err := tlsConn.Handshake(); err != nil {
+			log.Printf("🔓 MITM handshake with client for %s failed: %v", hostPort, err)
+			tlsConn.Close()
+			return
+		}
+
+		// Serve keep-alive requests on the decrypted connection until the client
+		// closes it. A tunnel may carry several virtual hosts, so each request is
+		// sent and recorded by the name the client asked for.
+		serverName := tlsConn.ConnectionState().ServerName
+		err := fasthttp.ServeConn(tlsConn, func(inner *fasthttp.RequestCtx) {
+			targetURL := mitmTarget(serverName, string(inner.Host()), host, port)
+			p.forward(inner, targetURL, string(inner.Path()), hostDirName(targetURL))
+		})
+		if err != nil && !isClosedConnError(err) {
+			log.Printf("🔓 MITM connection for %s ended: %v", hostPort, err)
+		}
+	})
+}
+
+// mitmTarget picks the upstream of an intercepted request: the TLS server name
+// (SNI) when the client sent one, otherwise the request's Host header,
+// otherwise the CONNECT target. The CONNECT port is kept unless the Host
+// header names its own.
+func mitmTarget(serverName, hostHeader, connectHost, connectPort string) string {
+	host, port := connectHost, connectPort
+	switch {
+	case serverName != "":
+		host = serverName
+	case hostHeader != "":
+		if h, p, err := net.SplitHostPort(hostHeader); err == nil {
+			host, port = h, p
+		} else {
+			host = hostHeader
+		}
+	}
+	if port == "443" {
+		if strings.Contains(host, ":") {
+			return "https://[" + host + "]"
+		}
+		return "https://" + host
+	}
+	return "https://" + net.JoinHostPort(host, port)
+}
+
+// isClosedConnError reports errors caused by the peer going away, which end tunnels normally.
+func isClosedConnError(err error) bool {
+	if err == nil {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "use of closed network connection") ||
+		strings.Contains(msg, "connection reset by peer") ||
+		strings.Contains(msg, "EOF")
+}
@@ -0,0 +1,174 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// newTestCA returns PEM-encoded certificate and key of a throwaway CA.
+func newTestCA(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "auto-proxy test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal CA key: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM
+}
+
+func TestMITMRecordsDecryptedTraffic(t *testing.T) {
+	certPEM, keyPEM := newTestCA(t)
+	ca, err := NewCertAuthority(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("Failed to load CA: %v", err)
+	}
+
+	// HTTPS upstream using a certificate from the same CA
+	upstreamCert, err := ca.leafFor("127.0.0.1")
+	if err != nil {
+		t.Fatalf("Failed to issue upstream certificate: %v", err)
+	}
+	upstreamLn, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	upstream := &fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType("application/json")
+		ctx.SetBodyString(`{"secure":true,"path":"` + string(ctx.Path()) + `"}`)
+	}}
+	go upstream.Serve(tls.NewListener(upstreamLn, &tls.Config{Certificates: []tls.Certificate{*upstreamCert}}))
+	t.Cleanup(func() { upstream.Shutdown() })
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	p := NewProxyHandler(recorder, "http://unused.invalid")
+	p.EnableMITM(ca)
+
+	proxyLn, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	proxyServer := &fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) {
+		if ctx.IsConnect() {
+			p.HandleConnect(ctx)
+			return
+		}
+		p.Handle(ctx)
+	}}
+	go proxyServer.Serve(proxyLn)
+	t.Cleanup(func() { proxyServer.Shutdown() })
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(certPEM)
+	proxyURL, _ := url.Parse("http://" + proxyLn.Addr().String())
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyURL(proxyURL),
+			TLSClientConfig: &tls.Config{RootCAs: roots},
+		},
+	}
+
+	req, _ := http.NewRequest("GET", "https://"+upstreamLn.Addr().String()+"/secure/data", nil)
+	req.Header.Set("x-mock-id", "mitm")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request through MITM proxy failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"secure":true`) {
+		t.Fatalf("Unexpected response %d: %s", resp.StatusCode, body)
+	}
+
+	// Intercepted traffic is grouped by the host the client asked for
+	files, err := filepath.Glob(filepath.Join(dir, hostDirName(upstreamLn.Addr().String()), "mitm", "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one recording, got %v (%v)", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Failed to read recording: %v", err)
+	}
+	if !strings.Contains(string(data), "https://"+upstreamLn.Addr().String()+"/secure/data") {
+		t.Fatalf("Expected recording of the decrypted HTTPS request, got %s", data)
+	}
+}
+
+func TestMITMTarget(t *testing.T) {
+	tests := []struct {
+		serverName, hostHeader, connectHost, connectPort string
+		want                                             string
+	}{
+		{"api.example.com", "10.0.0.1:8443", "10.0.0.1", "8443", "https://api.example.com:8443"},
+		{"api.example.com", "", "10.0.0.1", "443", "https://api.example.com"},
+		{"", "cdn.example.com", "10.0.0.1", "443", "https://cdn.example.com"},
+		{"", "127.0.0.1:9443", "127.0.0.1", "9443", "https://127.0.0.1:9443"},
+		{"", "", "example.com", "443", "https://example.com"},
+		{"", "", "::1", "443", "https://[::1]"},
+		{"", "", "::1", "8443", "https://[::1]:8443"},
+	}
+	for _, tt := range tests {
+		if got := mitmTarget(tt.serverName, tt.hostHeader, tt.connectHost, tt.connectPort); got != tt.want {
+			t.Errorf("mitmTarget(%q, %q, %q, %q) = %q, want %q", tt.serverName, tt.hostHeader, tt.connectHost, tt.connectPort, got, tt.want)
+		}
+	}
+}
+
+func TestConnectRejectedWithoutMITM(t *testing.T) {
+	recorder, err := NewRecorder(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	p := NewProxyHandler(recorder, "http://unused.invalid")
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod("CONNECT")
+	ctx.Request.SetRequestURI("example.com:443")
+	p.HandleConnect(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusMethodNotAllowed {
+		t.Fatalf("Expected 405 without MITM, got %d", ctx.Response.StatusCode())
+	}
+}
@@ -0,0 +1,193 @@
+package proxy
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RecordFilter decides by path, method and response status which exchanges are
+// recorded. Empty lists allow everything; exclusions win over inclusions.
+//
+// Paths are globs over "/"-separated segments: "*" matches within one segment
+// and "**" any number of segments, so "/api/**" covers everything under /api.
+// Statuses are exact codes ("404"), classes ("4xx") or ranges ("500-599").
+type RecordFilter struct {
+	IncludePaths    []string `yaml:"include_paths"`
+	ExcludePaths    []string `yaml:"exclude_paths"`
+	Methods         []string `yaml:"methods"`
+	Statuses        []string `yaml:"statuses"`
+	ExcludeStatuses []string `yaml:"exclude_statuses"`
+
+	statuses        []statusRange
+	excludeStatuses []statusRange
+}
+
+// statusRange is an inclusive range of status codes.
+type statusRange struct {
+	lo, hi int
+}
+
+// LoadRecordFilter reads a RecordFilter from a YAML file.
+func LoadRecordFilter(path string) (*RecordFilter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read record filter: %w", err)
+	}
+	filter := &RecordFilter{}
+	if err := yaml.Unmarshal(data, filter); err != nil {
+		return nil, fmt.Errorf("parse record filter: %w", err)
+	}
+	return filter, nil
+}
+
+// SetRecordFilter limits recording to the exchanges the filter allows. Skipped
+// exchanges are still proxied; recording returns ErrRecordSkipped for them.
+func (r *Recorder) SetRecordFilter(filter *RecordFilter) error {
+	if err := filter.compile(); err != nil {
+		return err
+	}
+	r.recordFilter = filter
+	return nil
+}
+
+func (f *RecordFilter) compile() error {
+	for _, pattern := range append(append([]string{}, f.IncludePaths...), f.ExcludePaths...) {
+		if !strings.HasPrefix(pattern, "/") {
+			return fmt.Errorf("invalid path pattern %q: must start with /", pattern)
+		}
+		for _, segment := range strings.Split(pattern, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	for i, method := range f.Methods {
+		f.Methods[i] = strings.ToUpper(strings.TrimSpace(method))
+	}
+
+	var err error
+	if f.statuses, err = parseStatusRanges(f.Statuses); err != nil {
+		return err
+	}
+	if f.excludeStatuses, err = parseStatusRanges(f.ExcludeStatuses); err != nil {
+		return err
+	}
+	return nil
+}
+
+func parseStatusRanges(patterns []string) ([]statusRange, error) {
+	ranges := make([]statusRange, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		var r statusRange
+		var err error
+		switch {
+		case len(pattern) == 3 && strings.HasSuffix(pattern, "xx"):
+			var class int
+			class, err = strconv.Atoi(pattern[:1])
+			r = statusRange{class * 100, class*100 + 99}
+		case strings.Contains(pattern, "-"):
+			lo, hi, _ := strings.Cut(pattern, "-")
+			if r.lo, err = strconv.Atoi(lo); err == nil {
+				r.hi, err = strconv.Atoi(hi)
+			}
+		default:
+			r.lo, err = strconv.Atoi(pattern)
+			r.hi = r.lo
+		}
+		if err != nil || r.lo < 100 || r.hi > 599 || r.lo > r.hi {
+			return nil, fmt.Errorf("invalid status %q (expected e.g. 404, 4xx or 500-599)", pattern)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// Allows reports whether an exchange should be recorded. rawURL is the
+// request URL as recorded; only its path is matched.
+func (f *RecordFilter) Allows(method, rawURL string, status int) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.Methods) > 0 && !containsString(f.Methods, strings.ToUpper(method)) {
+		return false
+	}
+
+	if len(f.IncludePaths) > 0 || len(f.ExcludePaths) > 0 {
+		requestPath := rawURL
+		if parsed, err := url.Parse(rawURL); err == nil {
+			requestPath = parsed.Path
+		}
+		if requestPath == "" {
+			requestPath = "/"
+		}
+		if matchAnyPathGlob(f.ExcludePaths, requestPath) {
+			return false
+		}
+		if len(f.IncludePaths) > 0 && !matchAnyPathGlob(f.IncludePaths, requestPath) {
+			return false
+		}
+	}
+
+	if inStatusRanges(f.excludeStatuses, status) {
+		return false
+	}
+	return len(f.statuses) == 0 || inStatusRanges(f.statuses, status)
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func inStatusRanges(ranges []statusRange, status int) bool {
+	for _, r := range ranges {
+		if status >= r.lo && status <= r.hi {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyPathGlob(patterns []string, requestPath string) bool {
+	for _, pattern := range patterns {
+		if matchPathGlob(strings.Split(pattern, "/"), strings.Split(requestPath, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPathGlob matches path segments against pattern segments, where a "**"
+// segment consumes any number of path segments.
+func matchPathGlob(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skip := 0; skip <= len(segments); skip++ {
+				if matchPathGlob(pattern[1:], segments[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
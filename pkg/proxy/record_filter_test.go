@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestRecordFilterAllows(t *testing.T) {
+	filter := &RecordFilter{
+		IncludePaths:    []string{"/api/**", "/v*/status"},
+		ExcludePaths:    []string{"/**/health"},
+		Methods:         []string{"get", "POST"},
+		ExcludeStatuses: []string{"304", "500-502"},
+	}
+	if err := filter.compile(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		method string
+		url    string
+		status int
+		want   bool
+	}{
+		{"GET", "http://svc/api/users/1?x=1", 200, true},
+		{"GET", "/api", 200, true},
+		{"POST", "/v2/status", 201, true},
+		{"GET", "/v2/x/status", 200, false},
+		{"GET", "/apis/users", 200, false},
+		{"GET", "/api/internal/health", 200, false},
+		{"DELETE", "/api/users/1", 200, false},
+		{"GET", "/api/users", 304, false},
+		{"GET", "/api/users", 501, false},
+		{"GET", "/api/users", 503, true},
+	}
+	for _, tt := range tests {
+		if got := filter.Allows(tt.method, tt.url, tt.status); got != tt.want {
+			t.Errorf("%s %s %d: expected %v, got %v", tt.method, tt.url, tt.status, tt.want, got)
+		}
+	}
+
+	// Only non-2xx responses
+	errorsOnly := &RecordFilter{ExcludeStatuses: []string{"2xx"}}
+	if err := errorsOnly.compile(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if errorsOnly.Allows("GET", "/", 204) || !errorsOnly.Allows("GET", "/", 404) {
+		t.Error("Expected 2xx to be skipped and 404 to be recorded")
+	}
+
+	var none *RecordFilter
+	if !none.Allows("GET", "/", 200) {
+		t.Error("Expected a nil filter to allow everything")
+	}
+
+	for _, invalid := range []*RecordFilter{
+		{IncludePaths: []string{"api/**"}},
+		{ExcludePaths: []string{"/[a"}},
+		{Statuses: []string{"6xx"}},
+		{Statuses: []string{"500-400"}},
+		{ExcludeStatuses: []string{"ok"}},
+	} {
+		if err := invalid.compile(); err == nil {
+			t.Errorf("Expected an error for %+v", invalid)
+		}
+	}
+}
+
+func TestRecordPairFilter(t *testing.T) {
+	dir := t.TempDir()
+	filterFile := filepath.Join(dir, "filter.yml")
+	if err := os.WriteFile(filterFile, []byte("include_paths: [\"/api/**\"]\nexclude_statuses: [2xx]\n"), 0644); err != nil {
+		t.Fatalf("Failed to write filter: %v", err)
+	}
+	filter, err := LoadRecordFilter(filterFile)
+	if err != nil {
+		t.Fatalf("Failed to load filter: %v", err)
+	}
+
+	logDir := filepath.Join(dir, "mocks")
+	recorder, err := NewRecorder(logDir)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	if err := recorder.SetRecordFilter(filter); err != nil {
+		t.Fatalf("Failed to set filter: %v", err)
+	}
+
+	record := func(url string, status int) error {
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseResponse(resp)
+		resp.Header.SetContentType("application/json")
+		resp.SetStatusCode(status)
+		resp.SetBodyString(`{}`)
+		reqData := &RequestData{RequestID: url, Method: "GET", URL: url, Headers: map[string]string{}}
+		return recorder.RecordPair(reqData, resp, 0)
+	}
+
+	if err := record("http://svc/api/items", 200); !errors.Is(err, ErrRecordSkipped) {
+		t.Fatalf("Expected 200 to be skipped, got %v", err)
+	}
+	if err := record("http://svc/healthz", 503); !errors.Is(err, ErrRecordSkipped) {
+		t.Fatalf("Expected a path outside /api to be skipped, got %v", err)
+	}
+	if err := record("http://svc/api/items", 503); err != nil {
+		t.Fatalf("Expected 503 under /api to be recorded, got %v", err)
+	}
+
+	files, err := os.ReadDir(filepath.Join(logDir, "default"))
+	if err != nil {
+		t.Fatalf("Failed to read recordings: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Expected exactly 1 recording, got %d", len(files))
+	}
+}
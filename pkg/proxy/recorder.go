@@ -2,25 +2,57 @@ package proxy
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
 	"github.com/valyala/fasthttp"
 )
 
 // Recorder writes HTTP request/response pairs to JSON files organized by mock_id.
 type Recorder struct {
-	baseDir string
-	mutex   sync.Mutex
+	baseDir          string
+	mutex            sync.Mutex
+	conditions       []RecordCondition // Optional header-based recording rules
+	recordFilter     *RecordFilter     // Optional path/method/status recording rules
+	filenameStrategy string            // FilenameTimestamp (default) or FilenameHash
+	format           string            // FormatJSON (default) or FormatHAR
+	headerFilter     *HeaderFilter     // Optional allowlist/denylist for persisted headers
+	redactor         *Redactor         // Optional masking of sensitive headers and body fields
+	async            *asyncWriter      // Optional background writer; nil writes in the request path
+	corpus           *corpusBudget     // Optional size/count limits with oldest-first rotation
+	memory           *MemoryStore      // Set by NewMemoryRecorder; recordings never touch the disk
+	revalidations    revalidations     // Links 304 answers to the full response they revalidate
+
+	// Optional callback after a recording is written (hybrid mode)
+	onRecord func(path, mockID string)
+
+	// Session ordering: every proxied request gets the next sequence number
+	startedAt time.Time
+	sequence  uint64 // Updated atomically
 }
 
+// Filename strategies for recorded files.
+const (
+	// FilenameTimestamp names files <content-type>_<timestamp>_<random>.json.
+	FilenameTimestamp = "timestamp"
+	// FilenameHash names files <content-type>_<hash>.json where the hash covers
+	// method, URL and body, so re-recording the same call overwrites the same file.
+	FilenameHash = "hash"
+)
+
 // NewRecorder creates a new recorder that writes to the specified directory.
 func NewRecorder(baseDir string) (*Recorder, error) {
 	// Create base directory if it doesn't exist
@@ -29,15 +61,91 @@ func NewRecorder(baseDir string) (*Recorder, error) {
 	}
 
 	return &Recorder{
-		baseDir: baseDir,
+		baseDir:   baseDir,
+		startedAt: time.Now(),
 	}, nil
 }
 
-// Close is kept for API compatibility but does nothing now.
+// Close flushes recordings still queued for the background writer.
 func (r *Recorder) Close() error {
+	if r.async != nil {
+		r.async.close()
+	}
+	return nil
+}
+
+// SetFilenameStrategy selects how recorded files are named.
+func (r *Recorder) SetFilenameStrategy(strategy string) error {
+	switch strategy {
+	case "", FilenameTimestamp:
+		r.filenameStrategy = FilenameTimestamp
+	case FilenameHash:
+		r.filenameStrategy = FilenameHash
+	default:
+		return fmt.Errorf("unknown filename strategy %q (expected %s or %s)", strategy, FilenameTimestamp, FilenameHash)
+	}
 	return nil
 }
 
+// buildFilename returns the file name for a recording of the given content type.
+func (r *Recorder) buildFilename(safeContentType string, reqData *RequestData) string {
+	if r.filenameStrategy == FilenameHash {
+		return fmt.Sprintf("%s_%s%s", safeContentType, requestHash(reqData), r.fileExtension())
+	}
+	timestamp := time.Now().Format("20060102_150405")
+	return fmt.Sprintf("%s_%s_%s%s", safeContentType, timestamp, generateRandomHex(4), r.fileExtension())
+}
+
+// stabilize makes hash-named recordings reproducible: request_id becomes the
+// request hash and the wall-clock fields are left out, so re-recording the same
+// traffic rewrites the file unchanged apart from the measured delays.
+func (r *Recorder) stabilize(reqData *RequestData, record map[string]interface{}) {
+	if r.filenameStrategy != FilenameHash {
+		return
+	}
+	reqData.RequestID = requestHash(reqData)
+	for _, side := range []string{"request", "response"} {
+		fields := record[side].(map[string]interface{})
+		fields["request_id"] = reqData.RequestID
+		delete(fields, "timestamp")
+		delete(fields, "session_offset")
+	}
+}
+
+// requestHash derives a stable identifier from method, URL (path and query) and body.
+// JSON bodies are hashed in their re-marshaled form so key order does not matter.
+func requestHash(reqData *RequestData) string {
+	target := reqData.URL
+	if parsed, err := url.Parse(reqData.URL); err == nil {
+		target = parsed.RequestURI()
+	}
+
+	h := sha256.New()
+	h.Write([]byte(strings.ToUpper(reqData.Method)))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(target))
+	h.Write([]byte{'\n'})
+	switch body := reqData.Body.(type) {
+	case string:
+		h.Write([]byte(body))
+	default:
+		if data, err := json.Marshal(body); err == nil {
+			// Hash the same canonical form the mock server matches against
+			if canonical, ok := storage.CanonicalizeJSON(data); ok {
+				data = canonical
+			}
+			h.Write(data)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// nextSequence returns the 1-based order of a request within the recording
+// session and its offset in seconds from the session start.
+func (r *Recorder) nextSequence() (uint64, float64) {
+	return atomic.AddUint64(&r.sequence, 1), time.Since(r.startedAt).Seconds()
+}
+
 // generateRequestID generates a unique request ID.
 func (r *Recorder) generateRequestID() string {
 	// Use timestamp + nanoseconds for uniqueness
@@ -71,6 +179,45 @@ func sanitizeContentType(contentType string) string {
 	return contentType
 }
 
+// mockDir returns the directory recordings for mockID are written to, under
+// the optional per-host subdirectory. An empty mockID means "default".
+func (r *Recorder) mockDir(hostDir, mockID string) string {
+	if mockID == "" {
+		mockID = "default"
+	}
+	return filepath.Join(r.baseDir, hostDir, mockID)
+}
+
+// SetRecordHook registers a callback invoked after each recording is written.
+// The mock ID is the directory name the file was written under.
+func (r *Recorder) SetRecordHook(hook func(path, mockID string)) {
+	r.onRecord = hook
+}
+
+// writeRecord persists a recording and notifies the record hook.
+func (r *Recorder) writeRecord(path string, data []byte, mockID string) error {
+	if r.memory != nil {
+		if mockID == "" {
+			mockID = "default"
+		}
+		r.memory.add(Recording{Path: filepath.ToSlash(path), MockID: mockID, Data: data})
+		return nil
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	if r.corpus != nil {
+		r.corpus.add(path, int64(len(data)))
+	}
+	if r.onRecord != nil {
+		if mockID == "" {
+			mockID = "default"
+		}
+		r.onRecord(path, mockID)
+	}
+	return nil
+}
+
 // RequestData holds request information for later writing
 type RequestData struct {
 	RequestID string
@@ -80,26 +227,93 @@ type RequestData struct {
 	Headers   map[string]string
 	Body      interface{}
 	MockID    string
+	HostDir   string // Per-upstream subdirectory of the log dir (forward proxy mode)
+
+	Sequence      uint64  // Order in which the proxy received the request
+	SessionOffset float64 // Seconds since the recording session started
+
+	ExpectContinue bool // Client sent Expect: 100-continue and received an interim 100
+
+	Note string // Annotation from the x-mock-note header, stored as "note" in the record
+
+	Truncated string // SSE limit that cut the capture short, stored as "truncated" in the response
+
+	CachedFrom string // Request ID of the recording a cached response came from, stored as "cached_from"
+}
+
+// annotate stores the tester's note on a record.
+func (reqData *RequestData) annotate(record map[string]interface{}) {
+	if reqData.Note != "" {
+		record["note"] = reqData.Note
+	}
+}
+
+// interimResponses lists the 1xx responses the client saw before the final one.
+func (reqData *RequestData) interimResponses() []map[string]interface{} {
+	if !reqData.ExpectContinue {
+		return nil
+	}
+	return []map[string]interface{}{{"status_code": fasthttp.StatusContinue}}
+}
+
+// newSSEEventRecord builds the stored form of a single SSE event. JSON payloads
+// stay structured; other text is marked "text" and non-UTF-8 payloads are base64
+// encoded and marked "base64" so replay can reproduce the exact bytes.
+func newSSEEventRecord(data string, timestamp float64) map[string]interface{} {
+	event := map[string]interface{}{
+		"timestamp": timestamp,
+	}
+
+	var jsonData interface{}
+	switch {
+	case !utf8.ValidString(data):
+		event["data"] = base64.StdEncoding.EncodeToString([]byte(data))
+		event["encoding"] = "base64"
+	case json.Unmarshal([]byte(data), &jsonData) == nil:
+		event["data"] = jsonData
+	case data == "[DONE]":
+		event["data"] = data
+	default:
+		event["data"] = data
+		event["encoding"] = "text"
+	}
+
+	return event
+}
+
+// parseSSEEventBlock extracts the data of one SSE event (the lines up to a blank line).
+// Per the SSE spec, multiple data lines are joined with "\n" and a single space
+// after the colon is optional. Returns false when the block has no data field.
+func parseSSEEventBlock(block string) (string, bool) {
+	var data []string
+	for _, line := range strings.Split(block, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "data" {
+			data = append(data, "")
+			continue
+		}
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		value := strings.TrimPrefix(line, "data:")
+		value = strings.TrimPrefix(value, " ")
+		data = append(data, value)
+	}
+
+	if len(data) == 0 {
+		return "", false
+	}
+	return strings.Join(data, "\n"), true
 }
 
-// parseSSEEvents parses SSE body into array of JSON objects
+// parseSSEEvents parses a buffered SSE body into event records (without timing)
 func parseSSEEvents(body string) ([]interface{}, bool) {
 	events := []interface{}{}
-	lines := strings.Split(body, "\n")
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		// SSE data lines start with "data: "
-		if strings.HasPrefix(line, "data: ") {
-			dataStr := strings.TrimPrefix(line, "data: ")
-			// Try to parse as JSON
-			var jsonData interface{}
-			if err := json.Unmarshal([]byte(dataStr), &jsonData); err == nil {
-				events = append(events, jsonData)
-			} else {
-				// If not JSON, store as string
-				events = append(events, dataStr)
-			}
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+
+	for _, block := range strings.Split(body, "\n\n") {
+		if data, ok := parseSSEEventBlock(block); ok {
+			events = append(events, newSSEEventRecord(data, 0))
 		}
 	}
 
@@ -107,8 +321,31 @@ func parseSSEEvents(body string) ([]interface{}, bool) {
 	return events, len(events) > 0
 }
 
+// persistedHeaders applies the header filter and redaction to headers about to be written.
+func (r *Recorder) persistedHeaders(headers map[string]string) map[string]string {
+	return r.redactor.Headers(r.headerFilter.Apply(headers))
+}
+
 // RecordPair records both HTTP request and response to a single JSON file
 func (r *Recorder) RecordPair(reqData *RequestData, resp *fasthttp.Response, delay float64) error {
+	if !r.recordFilter.Allows(reqData.Method, reqData.URL, resp.StatusCode()) {
+		return ErrRecordSkipped
+	}
+
+	// Evaluate recording conditions against upstream response headers
+	if len(r.conditions) > 0 {
+		record := r.shouldRecord(func(name string) (string, bool) {
+			if name == "content-length" {
+				return strconv.Itoa(len(resp.Body())), true
+			}
+			value := resp.Header.Peek(name)
+			return string(value), value != nil
+		})
+		if !record {
+			return ErrRecordSkipped
+		}
+	}
+
 	// Build response headers
 	respHeaders := make(map[string]string)
 	resp.Header.VisitAll(func(key, value []byte) {
@@ -135,9 +372,19 @@ func (r *Recorder) RecordPair(reqData *RequestData, resp *fasthttp.Response, del
 	var bodyData interface{}
 
 	isSSE := contentType == "text/event-stream"
-	contentEncoding := string(resp.Header.Peek("Content-Encoding"))
+	contentEncoding := strings.ToLower(strings.TrimSpace(string(resp.Header.Peek("Content-Encoding"))))
+	compressed := contentEncoding != "" && contentEncoding != "identity"
+
+	// Redacted fields must be found in compressed bodies too; the decoded JSON or
+	// events are stored, and the mock server serves them like any other body
+	if compressed && r.redactor.redactsBody() {
+		if decoded, err := resp.BodyUncompressed(); err == nil && (isSSE || json.Valid(decoded)) {
+			body, compressed = decoded, false
+		}
+	}
 
-	if contentEncoding == "gzip" {
+	// Compressed bodies are kept byte-exact; the mock server decodes them on load
+	if compressed {
 		bodyData = base64.StdEncoding.EncodeToString(body)
 	} else if isSSE {
 		events, hasEvents := parseSSEEvents(string(body))
@@ -155,105 +402,178 @@ func (r *Recorder) RecordPair(reqData *RequestData, resp *fasthttp.Response, del
 		}
 	}
 
+	// Mask sensitive fields before anything reaches disk
+	reqData.Body = r.redactor.Body(reqData.Body)
+	if !isSSE {
+		bodyData = r.redactor.Body(bodyData)
+	} else if events, ok := bodyData.([]interface{}); ok {
+		r.redactor.SSEEvents(events)
+	}
+
 	// Build complete record
 	record := map[string]interface{}{
 		"request": map[string]interface{}{
-			"request_id": reqData.RequestID,
-			"timestamp":  reqData.Timestamp,
-			"method":     reqData.Method,
-			"url":        reqData.URL,
-			"headers":    reqData.Headers,
-			"body":       reqData.Body,
+			"request_id":     reqData.RequestID,
+			"timestamp":      reqData.Timestamp,
+			"method":         reqData.Method,
+			"url":            reqData.URL,
+			"headers":        r.persistedHeaders(reqData.Headers),
+			"body":           reqData.Body,
+			"sequence":       reqData.Sequence,
+			"session_offset": reqData.SessionOffset,
 		},
 		"response": map[string]interface{}{
 			"request_id":  reqData.RequestID,
 			"timestamp":   time.Now().UTC().Format(time.RFC3339Nano),
 			"status_code": resp.StatusCode(),
-			"headers":     respHeaders,
+			"headers":     r.persistedHeaders(respHeaders),
 			"body":        bodyData,
 			"delay":       delay,
 		},
 	}
+	r.stabilize(reqData, record)
 
-	// Determine mock_id (default if not set)
-	mockID := reqData.MockID
-	if mockID == "" {
-		mockID = "default"
+	if interim := reqData.interimResponses(); interim != nil {
+		record["response"].(map[string]interface{})["interim_responses"] = interim
 	}
-
-	// Create directory for mock_id
-	mockDir := filepath.Join(r.baseDir, mockID)
-	if err := os.MkdirAll(mockDir, 0755); err != nil {
-		return err
+	if reqData.CachedFrom != "" {
+		record["response"].(map[string]interface{})["cached_from"] = reqData.CachedFrom
 	}
-
-	// Generate filename: <content-type>_<timestamp>_<random>.json
-	timestamp := time.Now().Format("20060102_150405")
-	randomHex := generateRandomHex(4)
-	safeContentType := sanitizeContentType(contentType)
-	filename := fmt.Sprintf("%s_%s_%s.json", safeContentType, timestamp, randomHex)
-	filepath := filepath.Join(mockDir, filename)
-
-	// Write JSON file
-	data, err := json.MarshalIndent(record, "", "  ")
-	if err != nil {
-		return err
+	if revalidates := r.revalidations.observe(reqData, resp); revalidates != "" {
+		record["response"].(map[string]interface{})["revalidates"] = revalidates
 	}
+	reqData.annotate(record)
+
+	// Generate filename: <content-type>_<timestamp>_<random>.json (or <content-type>_<hash>.json)
+	filename := r.buildFilename(sanitizeContentType(contentType), reqData)
 
-	return os.WriteFile(filepath, data, 0644)
+	return r.persist(r.mockDir(reqData.HostDir, reqData.MockID), filename, reqData.MockID, record)
 }
 
 // RecordSSEPair records SSE request/response with events and timestamps to a single JSON file
 func (r *Recorder) RecordSSEPair(reqData *RequestData, resp *fasthttp.Response, events []interface{}, delay float64, savedHeaders map[string]string) error {
+	if !r.recordFilter.Allows(reqData.Method, reqData.URL, resp.StatusCode()) {
+		return ErrRecordSkipped
+	}
+
+	// Evaluate recording conditions against the headers captured before streaming
+	if len(r.conditions) > 0 {
+		record := r.shouldRecord(func(name string) (string, bool) {
+			for key, value := range savedHeaders {
+				if strings.EqualFold(key, name) {
+					return value, true
+				}
+			}
+			return "", false
+		})
+		if !record {
+			return ErrRecordSkipped
+		}
+	}
+
 	// Use saved headers
 	respHeaders := savedHeaders
 	if reqData.MockID != "" {
 		respHeaders["x-mock-id"] = reqData.MockID
 	}
 
+	// Mask sensitive fields before anything reaches disk
+	reqData.Body = r.redactor.Body(reqData.Body)
+	r.redactor.SSEEvents(events)
+
 	// Build complete record
 	record := map[string]interface{}{
 		"request": map[string]interface{}{
-			"request_id": reqData.RequestID,
-			"timestamp":  reqData.Timestamp,
-			"method":     reqData.Method,
-			"url":        reqData.URL,
-			"headers":    reqData.Headers,
-			"body":       reqData.Body,
+			"request_id":     reqData.RequestID,
+			"timestamp":      reqData.Timestamp,
+			"method":         reqData.Method,
+			"url":            reqData.URL,
+			"headers":        r.persistedHeaders(reqData.Headers),
+			"body":           reqData.Body,
+			"sequence":       reqData.Sequence,
+			"session_offset": reqData.SessionOffset,
 		},
 		"response": map[string]interface{}{
 			"request_id":  reqData.RequestID,
 			"timestamp":   time.Now().UTC().Format(time.RFC3339Nano),
 			"status_code": resp.StatusCode(),
-			"headers":     respHeaders,
+			"headers":     r.persistedHeaders(respHeaders),
 			"body":        events,
 			"delay":       delay,
 		},
 	}
+	r.stabilize(reqData, record)
 
-	// Determine mock_id
-	mockID := reqData.MockID
-	if mockID == "" {
-		mockID = "default"
+	if interim := reqData.interimResponses(); interim != nil {
+		record["response"].(map[string]interface{})["interim_responses"] = interim
 	}
-
-	// Create directory for mock_id
-	mockDir := filepath.Join(r.baseDir, mockID)
-	if err := os.MkdirAll(mockDir, 0755); err != nil {
-		return err
+	if reqData.Truncated != "" {
+		record["response"].(map[string]interface{})["truncated"] = reqData.Truncated
 	}
+	reqData.annotate(record)
 
 	// Generate filename for SSE
-	timestamp := time.Now().Format("20060102_150405")
-	randomHex := generateRandomHex(4)
-	filename := fmt.Sprintf("text_event-stream_%s_%s.json", timestamp, randomHex)
-	filepath := filepath.Join(mockDir, filename)
+	filename := r.buildFilename("text_event-stream", reqData)
 
-	// Write JSON file
-	data, err := json.MarshalIndent(record, "", "  ")
-	if err != nil {
-		return err
+	return r.persist(r.mockDir(reqData.HostDir, reqData.MockID), filename, reqData.MockID, record)
+}
+
+// RecordWebSocket records a WebSocket handshake and the frames relayed in both
+// directions as a "websocket" record. delay is the handshake round trip.
+func (r *Recorder) RecordWebSocket(reqData *RequestData, resp *fasthttp.Response, frames []storage.WebSocketFrame, delay float64) error {
+	if !r.recordFilter.Allows(reqData.Method, reqData.URL, resp.StatusCode()) {
+		return ErrRecordSkipped
+	}
+
+	// Evaluate recording conditions against the handshake response headers
+	if len(r.conditions) > 0 {
+		record := r.shouldRecord(func(name string) (string, bool) {
+			value := resp.Header.Peek(name)
+			return string(value), value != nil
+		})
+		if !record {
+			return ErrRecordSkipped
+		}
 	}
 
-	return os.WriteFile(filepath, data, 0644)
+	respHeaders := make(map[string]string)
+	resp.Header.VisitAll(func(key, value []byte) {
+		respHeaders[string(key)] = string(value)
+	})
+	if reqData.MockID != "" {
+		respHeaders["x-mock-id"] = reqData.MockID
+	}
+
+	frameRecords := make([]interface{}, 0, len(frames))
+	for _, frame := range frames {
+		frameRecords = append(frameRecords, frame.Record())
+	}
+
+	record := map[string]interface{}{
+		"type": storage.WebSocketContentType,
+		"request": map[string]interface{}{
+			"request_id":     reqData.RequestID,
+			"timestamp":      reqData.Timestamp,
+			"method":         reqData.Method,
+			"url":            reqData.URL,
+			"headers":        r.persistedHeaders(reqData.Headers),
+			"body":           r.redactor.Body(reqData.Body),
+			"sequence":       reqData.Sequence,
+			"session_offset": reqData.SessionOffset,
+		},
+		"response": map[string]interface{}{
+			"request_id":  reqData.RequestID,
+			"timestamp":   time.Now().UTC().Format(time.RFC3339Nano),
+			"status_code": resp.StatusCode(),
+			"headers":     r.persistedHeaders(respHeaders),
+			"body":        frameRecords,
+			"delay":       delay,
+		},
+	}
+	r.stabilize(reqData, record)
+
+	reqData.annotate(record)
+
+	filename := r.buildFilename(storage.WebSocketContentType, reqData)
+	return r.persist(r.mockDir(reqData.HostDir, reqData.MockID), filename, reqData.MockID, record)
 }
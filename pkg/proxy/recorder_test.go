@@ -0,0 +1,201 @@
+package proxy
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestHashFilenameStrategy(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	if err := recorder.SetFilenameStrategy(FilenameHash); err != nil {
+		t.Fatalf("Failed to set strategy: %v", err)
+	}
+
+	record := func(url string, body interface{}) {
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseResponse(resp)
+		resp.Header.SetContentType("application/json")
+		resp.SetBodyString(`{"ok":true}`)
+		reqData := &RequestData{RequestID: "1", Method: "POST", URL: url, Headers: map[string]string{}, Body: body}
+		if err := recorder.RecordPair(reqData, resp, 0); err != nil {
+			t.Fatalf("Failed to record: %v", err)
+		}
+	}
+
+	// Same call twice (JSON key order differs) must land in the same file
+	record("http://api.example.com/orders?x=1", map[string]interface{}{"a": 1.0, "b": 2.0})
+	record("http://api.example.com/orders?x=1", map[string]interface{}{"b": 2.0, "a": 1.0})
+	// Different query and body produce new files
+	record("http://api.example.com/orders?x=2", map[string]interface{}{"a": 1.0, "b": 2.0})
+	record("http://api.example.com/orders?x=1", "raw body")
+
+	files, err := os.ReadDir(dir + "/default")
+	if err != nil {
+		t.Fatalf("Failed to read recordings: %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 distinct files, got %d", len(files))
+	}
+}
+
+func TestHashFilenameStrategyRewritesIdenticalFiles(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	if err := recorder.SetFilenameStrategy(FilenameHash); err != nil {
+		t.Fatalf("Failed to set strategy: %v", err)
+	}
+
+	record := func(requestID, timestamp string, offset float64) []byte {
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseResponse(resp)
+		resp.Header.SetContentType("application/json")
+		resp.SetBodyString(`{"ok":true}`)
+		reqData := &RequestData{
+			RequestID:     requestID,
+			Timestamp:     timestamp,
+			Method:        "GET",
+			URL:           "http://api.example.com/orders",
+			Headers:       map[string]string{},
+			Body:          "",
+			SessionOffset: offset,
+		}
+		if err := recorder.RecordPair(reqData, resp, 0.25); err != nil {
+			t.Fatalf("Failed to record: %v", err)
+		}
+		files, err := os.ReadDir(dir + "/default")
+		if err != nil || len(files) != 1 {
+			t.Fatalf("Expected a single recording, got %v (%v)", files, err)
+		}
+		data, err := os.ReadFile(dir + "/default/" + files[0].Name())
+		if err != nil {
+			t.Fatalf("Failed to read recording: %v", err)
+		}
+		return data
+	}
+
+	first := record("20240101000000.1", "2024-01-01T00:00:00Z", 1.5)
+	second := record("20240102000000.2", "2024-01-02T00:00:00Z", 7.25)
+	if string(first) != string(second) {
+		t.Fatalf("Expected identical re-recordings, got:\n%s\n%s", first, second)
+	}
+
+	var saved map[string]map[string]interface{}
+	if err := json.Unmarshal(first, &saved); err != nil {
+		t.Fatalf("Failed to parse recording: %v", err)
+	}
+	hash := requestHash(&RequestData{Method: "GET", URL: "http://api.example.com/orders", Body: ""})
+	for _, side := range []string{"request", "response"} {
+		if saved[side]["request_id"] != hash {
+			t.Fatalf("Expected %s request_id %q, got %v", side, hash, saved[side]["request_id"])
+		}
+		if _, ok := saved[side]["timestamp"]; ok {
+			t.Fatalf("Expected no %s timestamp, got %v", side, saved[side]["timestamp"])
+		}
+	}
+}
+
+func TestSetFilenameStrategyRejectsUnknown(t *testing.T) {
+	recorder, err := NewRecorder(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	if err := recorder.SetFilenameStrategy("sequential"); err == nil {
+		t.Fatal("Expected error for unknown strategy")
+	}
+}
+
+func TestNewSSEEventRecordEncoding(t *testing.T) {
+	binary := newSSEEventRecord("\xff\x00\x01\xfe", 0.5)
+	if binary["encoding"] != "base64" || binary["data"] != "/wAB/g==" {
+		t.Fatalf("Expected base64 encoded binary event, got %v", binary)
+	}
+
+	text := newSSEEventRecord("hello world", 0.5)
+	if text["encoding"] != "text" || text["data"] != "hello world" {
+		t.Fatalf("Expected text event, got %v", text)
+	}
+
+	jsonEvent := newSSEEventRecord(`{"n":1}`, 0.5)
+	if _, ok := jsonEvent["encoding"]; ok {
+		t.Fatalf("Expected JSON event without encoding marker, got %v", jsonEvent)
+	}
+	if data, ok := jsonEvent["data"].(map[string]interface{}); !ok || data["n"] != 1.0 {
+		t.Fatalf("Expected structured JSON data, got %v", jsonEvent["data"])
+	}
+}
+
+func TestParseSSEEventsMultiLine(t *testing.T) {
+	body := "event: update\r\ndata: first line\r\ndata:second line\r\n\r\ndata: {\"n\":1}\n\n: comment only\n\n"
+
+	events, ok := parseSSEEvents(body)
+	if !ok || len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d (%v)", len(events), events)
+	}
+
+	multi := events[0].(map[string]interface{})
+	if multi["data"] != "first line\nsecond line" || multi["encoding"] != "text" {
+		t.Fatalf("Expected joined multi-line text event, got %v", multi)
+	}
+
+	if _, ok := events[1].(map[string]interface{})["data"].(map[string]interface{}); !ok {
+		t.Fatalf("Expected JSON event, got %v", events[1])
+	}
+}
+
+func TestRecordedSequenceOrdering(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+
+	first, firstOffset := recorder.nextSequence()
+	second, secondOffset := recorder.nextSequence()
+	if first != 1 || second != 2 {
+		t.Fatalf("Expected sequence 1 then 2, got %d then %d", first, second)
+	}
+	if secondOffset < firstOffset {
+		t.Fatalf("Expected non-decreasing session offsets, got %v then %v", firstOffset, secondOffset)
+	}
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	resp.Header.SetContentType("application/json")
+	resp.SetBodyString(`{"ok":true}`)
+	reqData := &RequestData{RequestID: "1", Method: "GET", URL: "/items", Headers: map[string]string{}, Sequence: second, SessionOffset: secondOffset}
+	if err := recorder.RecordPair(reqData, resp, 0); err != nil {
+		t.Fatalf("Failed to record: %v", err)
+	}
+
+	files, err := os.ReadDir(dir + "/default")
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one recording, got %v (%v)", files, err)
+	}
+	data, err := os.ReadFile(dir + "/default/" + files[0].Name())
+	if err != nil {
+		t.Fatalf("Failed to read recording: %v", err)
+	}
+
+	var record struct {
+		Request struct {
+			Sequence      uint64  `json:"sequence"`
+			SessionOffset float64 `json:"session_offset"`
+		} `json:"request"`
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("Failed to parse recording: %v", err)
+	}
+	if record.Request.Sequence != 2 || record.Request.SessionOffset != secondOffset {
+		t.Fatalf("Expected sequence 2 at offset %v, got %+v", secondOffset, record.Request)
+	}
+}
@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultRedactPlaceholder replaces redacted values unless another one is configured.
+const DefaultRedactPlaceholder = "[REDACTED]"
+
+// Redactor masks sensitive headers and JSON body fields before recordings are
+// written, so mock directories can be committed safely. The live exchange
+// between client and upstream is never modified.
+type Redactor struct {
+	headers     []string   // Lowercase header patterns, "*" suffix allowed
+	fields      [][]string // JSON paths split into segments, "*" matches any key or index
+	placeholder string
+}
+
+// NewRedactor creates a redactor for the given header patterns (as accepted by
+// ParseHeaderPatterns) and JSON paths such as "$.user.ssn", "$.cards[*].number"
+// or "token". An empty placeholder selects DefaultRedactPlaceholder.
+func NewRedactor(headers, fields []string, placeholder string) (*Redactor, error) {
+	if placeholder == "" {
+		placeholder = DefaultRedactPlaceholder
+	}
+	r := &Redactor{headers: headers, placeholder: placeholder}
+	for _, field := range fields {
+		segments, err := parseFieldPath(field)
+		if err != nil {
+			return nil, err
+		}
+		r.fields = append(r.fields, segments)
+	}
+	return r, nil
+}
+
+// parseFieldPath splits a JSON path such as "$.a.b[0].c" or "a.b.*.c" into its segments.
+func parseFieldPath(field string) ([]string, error) {
+	path := strings.TrimPrefix(strings.TrimSpace(field), "$")
+	path = strings.TrimPrefix(path, ".")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	if path == "" {
+		return nil, fmt.Errorf("invalid field path %q: empty path", field)
+	}
+	segments := strings.Split(path, ".")
+	for _, segment := range segments {
+		if segment == "" {
+			return nil, fmt.Errorf("invalid field path %q: empty segment", field)
+		}
+	}
+	return segments, nil
+}
+
+// SetRedactor masks sensitive values in every recording written from now on.
+func (r *Recorder) SetRedactor(redactor *Redactor) {
+	r.redactor = redactor
+}
+
+// Headers returns a copy of headers with the configured ones replaced by the
+// placeholder. Content-Type and x-mock-id are never redacted.
+func (r *Redactor) Headers(headers map[string]string) map[string]string {
+	if r == nil || len(r.headers) == 0 {
+		return headers
+	}
+	redacted := make(map[string]string, len(headers))
+	for key, value := range headers {
+		name := strings.ToLower(key)
+		if !alwaysPersistedHeaders[name] && matchHeaderPattern(r.headers, name) {
+			value = r.placeholder
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
+
+// Body replaces the configured fields of a decoded JSON body in place. Other
+// body types (text, base64) are returned unchanged.
+func (r *Redactor) Body(body interface{}) interface{} {
+	if r == nil {
+		return body
+	}
+	for _, field := range r.fields {
+		setFieldPath(body, field, r.placeholder)
+	}
+	return body
+}
+
+// redactsBody reports whether any JSON body fields are masked.
+func (r *Redactor) redactsBody() bool {
+	return r != nil && len(r.fields) > 0
+}
+
+// SSEEvents redacts the JSON data of every recorded SSE event.
+func (r *Redactor) SSEEvents(events []interface{}) {
+	if r == nil || len(r.fields) == 0 {
+		return
+	}
+	for _, event := range events {
+		if eventMap, ok := event.(map[string]interface{}); ok {
+			if _, encoded := eventMap["encoding"]; !encoded {
+				r.Body(eventMap["data"])
+			}
+		}
+	}
+}
+
+// setFieldPath replaces every value of a decoded JSON body matching path with value.
+func setFieldPath(node interface{}, path []string, value interface{}) {
+	segment, last := path[0], len(path) == 1
+	switch typed := node.(type) {
+	case map[string]interface{}:
+		for key, child := range typed {
+			if segment != "*" && segment != key {
+				continue
+			}
+			if last {
+				typed[key] = value
+			} else {
+				setFieldPath(child, path[1:], value)
+			}
+		}
+	case []interface{}:
+		for i, child := range typed {
+			if segment != "*" && segment != strconv.Itoa(i) {
+				continue
+			}
+			if last {
+				typed[i] = value
+			} else {
+				setFieldPath(child, path[1:], value)
+			}
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package proxy
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestParseFieldPath(t *testing.T) {
+	tests := map[string]string{
+		"$.user.ssn":         "user/ssn",
+		"token":              "token",
+		"$.cards[*].number":  "cards/*/number",
+		"$.items[0].secret":  "items/0/secret",
+		"$..ssn":             "",
+		"$":                  "",
+		"$.user..ssn":        "",
+		" $.user.password  ": "user/password",
+	}
+	for field, want := range tests {
+		segments, err := parseFieldPath(field)
+		if want == "" {
+			if err == nil {
+				t.Errorf("%q: expected error, got %v", field, segments)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", field, err)
+			continue
+		}
+		got := ""
+		for i, segment := range segments {
+			if i > 0 {
+				got += "/"
+			}
+			got += segment
+		}
+		if got != want {
+			t.Errorf("%q: expected %s, got %s", field, want, got)
+		}
+	}
+}
+
+func TestRecordPairRedaction(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	redactor, err := NewRedactor([]string{"authorization", "set-cookie", "x-api-*"},
+		[]string{"$.user.ssn", "$.cards[*].number", "password"}, "")
+	if err != nil {
+		t.Fatalf("Failed to create redactor: %v", err)
+	}
+	recorder.SetRedactor(redactor)
+
+	var reqBody interface{}
+	json.Unmarshal([]byte(`{"password":"hunter2","user":{"name":"Ann"}}`), &reqBody)
+	reqData := &RequestData{
+		RequestID: "1",
+		Method:    "POST",
+		URL:       "/accounts",
+		Headers:   map[string]string{"Authorization": "Bearer secret", "X-Api-Key": "k", "Accept": "*/*"},
+		Body:      reqBody,
+	}
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	resp.Header.SetContentType("application/json")
+	resp.Header.Set("Set-Cookie", "session=abc")
+	resp.SetBodyString(`{"user":{"name":"Ann","ssn":"123-45-6789"},"cards":[{"number":"4111","brand":"visa"},{"number":"5500"}]}`)
+	if err := recorder.RecordPair(reqData, resp, 0); err != nil {
+		t.Fatalf("Failed to record: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "default", "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one recording, got %v (%v)", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Failed to read recording: %v", err)
+	}
+
+	var record struct {
+		Request struct {
+			Headers map[string]string      `json:"headers"`
+			Body    map[string]interface{} `json:"body"`
+		} `json:"request"`
+		Response struct {
+			Headers map[string]string `json:"headers"`
+			Body    struct {
+				User  map[string]string   `json:"user"`
+				Cards []map[string]string `json:"cards"`
+			} `json:"body"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("Failed to parse recording: %v", err)
+	}
+
+	if h := record.Request.Headers; h["Authorization"] != DefaultRedactPlaceholder || h["X-Api-Key"] != DefaultRedactPlaceholder || h["Accept"] != "*/*" {
+		t.Errorf("Unexpected request headers: %v", h)
+	}
+	if record.Request.Body["password"] != DefaultRedactPlaceholder {
+		t.Errorf("Expected password to be redacted, got %v", record.Request.Body)
+	}
+	if h := record.Response.Headers; h["Set-Cookie"] != DefaultRedactPlaceholder || h["Content-Type"] != "application/json" {
+		t.Errorf("Unexpected response headers: %v", h)
+	}
+	body := record.Response.Body
+	if body.User["ssn"] != DefaultRedactPlaceholder || body.User["name"] != "Ann" {
+		t.Errorf("Unexpected user: %v", body.User)
+	}
+	if len(body.Cards) != 2 || body.Cards[0]["number"] != DefaultRedactPlaceholder ||
+		body.Cards[1]["number"] != DefaultRedactPlaceholder || body.Cards[0]["brand"] != "visa" {
+		t.Errorf("Unexpected cards: %v", body.Cards)
+	}
+}
+
+func TestRecordPairRedactsCompressedBodies(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	redactor, err := NewRedactor(nil, []string{"$.user.ssn", "token"}, "")
+	if err != nil {
+		t.Fatalf("Failed to create redactor: %v", err)
+	}
+	recorder.SetRedactor(redactor)
+
+	record := func(id, contentType, body string) []byte {
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseResponse(resp)
+		resp.Header.SetContentType(contentType)
+		resp.Header.Set("Content-Encoding", "gzip")
+		resp.SetBody(fasthttp.AppendGzipBytes(nil, []byte(body)))
+		if err := recorder.RecordPair(&RequestData{RequestID: id, Method: "GET", URL: "/" + id}, resp, 0); err != nil {
+			t.Fatalf("Failed to record: %v", err)
+		}
+		files, err := filepath.Glob(filepath.Join(dir, "default", "*.json"))
+		if err != nil {
+			t.Fatalf("Failed to list recordings: %v", err)
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("Failed to read recording: %v", err)
+			}
+			if strings.Contains(string(data), `"request_id": "`+id+`"`) {
+				return data
+			}
+		}
+		t.Fatalf("Expected a recording for %s, got %v", id, files)
+		return nil
+	}
+
+	data := record("user", "application/json", `{"user":{"name":"Ann","ssn":"123-45-6789"}}`)
+	if strings.Contains(string(data), "123-45-6789") {
+		t.Fatalf("Expected the compressed body to be redacted, got %s", data)
+	}
+	var user struct {
+		Response struct {
+			Headers map[string]string `json:"headers"`
+			Body    struct {
+				User map[string]string `json:"user"`
+			} `json:"body"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(data, &user); err != nil {
+		t.Fatalf("Failed to parse recording: %v", err)
+	}
+	if user.Response.Body.User["ssn"] != DefaultRedactPlaceholder || user.Response.Body.User["name"] != "Ann" {
+		t.Errorf("Expected the decoded body with ssn redacted, got %v", user.Response.Body.User)
+	}
+	if user.Response.Headers["Content-Encoding"] != "gzip" {
+		t.Errorf("Expected the recorded Content-Encoding to be kept, got %v", user.Response.Headers)
+	}
+
+	data = record("events", "text/event-stream", "data: {\"token\":\"s3cret\",\"n\":1}\n\n")
+	if strings.Contains(string(data), "s3cret") {
+		t.Fatalf("Expected the compressed events to be redacted, got %s", data)
+	}
+}
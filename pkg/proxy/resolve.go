@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// dialTimeout is used for upstream connections opened by the proxy itself.
+const dialTimeout = 10 * time.Second
+
+// ParseResolveRule parses a curl-style resolve override in the form
+// "host:port=addr". The address may omit the port, in which case the
+// original port is kept.
+func ParseResolveRule(rule string) (string, string, error) {
+	hostPort, addr, ok := strings.Cut(rule, "=")
+	hostPort = strings.TrimSpace(hostPort)
+	addr = strings.TrimSpace(addr)
+	if !ok || hostPort == "" || addr == "" {
+		return "", "", fmt.Errorf("invalid resolve rule %q (expected host:port=addr)", rule)
+	}
+
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil || host == "" || port == "" {
+		return "", "", fmt.Errorf("invalid resolve rule %q: %q must be host:port", rule, hostPort)
+	}
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), port)
+	}
+
+	return strings.ToLower(hostPort), addr, nil
+}
+
+// AddResolveOverride routes connections for hostPort to addr instead of using DNS.
+// TLS server name verification and the Host header still use the original host.
+func (p *ProxyHandler) AddResolveOverride(hostPort, addr string) {
+	if p.resolveOverrides == nil {
+		p.resolveOverrides = make(map[string]string)
+	}
+	p.resolveOverrides[strings.ToLower(hostPort)] = addr
+}
+
+// resolveAddr returns the dial address for hostPort after applying overrides.
+func (p *ProxyHandler) resolveAddr(hostPort string) string {
+	if addr, ok := p.resolveOverrides[strings.ToLower(hostPort)]; ok {
+		return addr
+	}
+	return hostPort
+}
+
+// dial is used by the fasthttp client so resolve overrides apply to all upstream traffic.
+func (p *ProxyHandler) dial(addr string) (net.Conn, error) {
+	return p.metrics.trackConn(fasthttp.DialTimeout(p.resolveAddr(addr), dialTimeout))
+}
+
+// dialUpstream opens a raw connection to targetBase (scheme://host[:port]) for
+// exchanges the fasthttp client cannot carry, such as SSE streams and WebSocket
+// tunnels. kind only labels the log line.
+func (p *ProxyHandler) dialUpstream(targetBase, requestID, kind string) (net.Conn, error) {
+	// Determine if target is HTTPS
+	isHTTPS := strings.HasPrefix(targetBase, "https://")
+
+	// Extract host for connection
+	targetHost := strings.TrimPrefix(targetBase, "http://")
+	targetHost = strings.TrimPrefix(targetHost, "https://")
+
+	// If no port specified, add default port
+	if !strings.Contains(targetHost, ":") {
+		if isHTTPS {
+			targetHost += ":443"
+		} else {
+			targetHost += ":80"
+		}
+	}
+
+	log.Printf("[%s] %s connecting to %s (HTTPS: %v)", requestID, kind, targetHost, isHTTPS)
+
+	// Connect to upstream (resolve overrides redirect the dial, not the TLS server name)
+	dialAddr := p.resolveAddr(targetHost)
+
+	if !isHTTPS {
+		return p.metrics.trackConn(net.DialTimeout("tcp", dialAddr, dialTimeout))
+	}
+
+	// For HTTPS, use TLS connection with configured TLS config (includes client certs if loaded)
+	tlsConfig := p.tlsConfig
+	if tlsConfig.ServerName == "" {
+		if host, _, splitErr := net.SplitHostPort(targetHost); splitErr == nil {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName = host
+		}
+	}
+	return p.metrics.trackConn(tls.DialWithDialer(&net.Dialer{Timeout: dialTimeout}, "tcp", dialAddr, tlsConfig))
+}
@@ -0,0 +1,48 @@
+package proxy
+
+import "testing"
+
+func TestParseResolveRule(t *testing.T) {
+	tests := []struct {
+		rule     string
+		hostPort string
+		addr     string
+		wantErr  bool
+	}{
+		{rule: "api.example.com:443=10.0.0.5", hostPort: "api.example.com:443", addr: "10.0.0.5:443"},
+		{rule: "API.example.com:80=10.0.0.5:8080", hostPort: "api.example.com:80", addr: "10.0.0.5:8080"},
+		{rule: "svc:8443=::1", hostPort: "svc:8443", addr: "[::1]:8443"},
+		{rule: "api.example.com=10.0.0.5", wantErr: true},
+		{rule: "api.example.com:443", wantErr: true},
+		{rule: "api.example.com:443=", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		hostPort, addr, err := ParseResolveRule(tt.rule)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected error, got %s=%s", tt.rule, hostPort, addr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.rule, err)
+			continue
+		}
+		if hostPort != tt.hostPort || addr != tt.addr {
+			t.Errorf("%q: expected %s=%s, got %s=%s", tt.rule, tt.hostPort, tt.addr, hostPort, addr)
+		}
+	}
+}
+
+func TestResolveAddr(t *testing.T) {
+	p := NewProxyHandler(nil, "http://api.example.com")
+	p.AddResolveOverride("api.example.com:80", "127.0.0.1:9000")
+
+	if got := p.resolveAddr("API.EXAMPLE.COM:80"); got != "127.0.0.1:9000" {
+		t.Fatalf("Expected override to apply, got %s", got)
+	}
+	if got := p.resolveAddr("other.example.com:80"); got != "other.example.com:80" {
+		t.Fatalf("Expected unrelated host to pass through, got %s", got)
+	}
+}
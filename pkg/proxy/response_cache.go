@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// cacheVaryHeaders are the request headers that can change an upstream
+// answer, so requests that differ in any of them are cached separately.
+// Conditional requests only share answers with identical validators.
+var cacheVaryHeaders = []string{"Accept", "Accept-Encoding", "Authorization", "Cookie", "If-None-Match", "If-Modified-Since"}
+
+// responseCache answers repeated GETs of a recording session from the
+// response recorded for the first one, sparing fragile upstreams.
+type responseCache struct {
+	ttl time.Duration // 0 = entries live for the whole session
+
+	mutex   sync.Mutex
+	entries map[string]*cachedResponse
+	hits    atomic.Uint64
+}
+
+type cachedResponse struct {
+	resp      *fasthttp.Response
+	delay     float64 // Upstream duration of the original request, replayed in the recording
+	requestID string  // Recording the response was captured in
+	storedAt  time.Time
+}
+
+// SetResponseCache serves a GET that repeats an earlier one (same upstream
+// URL, mock ID and cacheVaryHeaders) from the earlier response instead of
+// forwarding it. Each hit is still recorded, with "cached_from" naming the
+// original recording, so the corpus stays complete. Only answers below 500, except 429, are cached. ttl bounds
+// how long a response is reused; 0 keeps it for the whole session.
+func (p *ProxyHandler) SetResponseCache(enabled bool, ttl time.Duration) error {
+	if ttl < 0 {
+		return fmt.Errorf("cache TTL must not be negative")
+	}
+	if !enabled {
+		p.cache = nil
+		return nil
+	}
+	p.cache = &responseCache{ttl: ttl, entries: make(map[string]*cachedResponse)}
+	return nil
+}
+
+// CacheHits returns how many requests were answered from the response cache.
+func (p *ProxyHandler) CacheHits() uint64 {
+	if p.cache == nil {
+		return 0
+	}
+	return p.cache.hits.Load()
+}
+
+// cacheKey identifies a cacheable request, or returns "" for requests that
+// must reach the upstream.
+func (c *responseCache) cacheKey(ctx *fasthttp.RequestCtx, targetURL string, reqData *RequestData) string {
+	if c == nil || reqData.Method != fasthttp.MethodGet || len(ctx.Request.Body()) > 0 {
+		return ""
+	}
+	var key strings.Builder
+	key.WriteString(reqData.HostDir)
+	key.WriteByte(0)
+	key.WriteString(reqData.MockID)
+	key.WriteByte(0)
+	key.WriteString(targetURL)
+	for _, name := range cacheVaryHeaders {
+		key.WriteByte(0)
+		key.Write(ctx.Request.Header.Peek(name))
+	}
+	return key.String()
+}
+
+// get copies a live cached response for key into resp.
+func (c *responseCache) get(key string, resp *fasthttp.Response) (*cachedResponse, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if c.ttl > 0 && time.Since(entry.storedAt) > c.ttl {
+		delete(c.entries, key)
+		return nil, false
+	}
+	entry.resp.CopyTo(resp)
+	c.hits.Add(1)
+	return entry, true
+}
+
+// put caches a copy of resp for key when its status is worth reusing.
+func (c *responseCache) put(key string, resp *fasthttp.Response, delay float64, requestID string) {
+	status := resp.StatusCode()
+	if status >= 500 || status == fasthttp.StatusTooManyRequests {
+		return
+	}
+	stored := &fasthttp.Response{}
+	resp.CopyTo(stored)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[key] = &cachedResponse{resp: stored, delay: delay, requestID: requestID, storedAt: time.Now()}
+}
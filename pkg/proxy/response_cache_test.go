@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestResponseCache(t *testing.T) {
+	var upstreamCalls atomic.Int32
+	upstream := startUpstream(t, func(ctx *fasthttp.RequestCtx) {
+		upstreamCalls.Add(1)
+		if string(ctx.Path()) == "/flaky" {
+			ctx.SetStatusCode(fasthttp.StatusServiceUnavailable)
+			return
+		}
+		ctx.SetContentType("application/json")
+		ctx.SetBodyString(`{"user":"` + string(ctx.Request.Header.Peek("Authorization")) + `"}`)
+	})
+
+	server, err := NewServer(upstream, ServerOptions{
+		InMemory: true,
+		Configure: func(_ *Recorder, handler *ProxyHandler) error {
+			return handler.SetResponseCache(true, 0)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Shutdown(context.Background())
+
+	get := func(path, auth string) (int, string) {
+		t.Helper()
+		req := fasthttp.AcquireRequest()
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseRequest(req)
+		defer fasthttp.ReleaseResponse(resp)
+		req.SetRequestURI(server.URL() + path)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		if err := fasthttp.Do(req, resp); err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		return resp.StatusCode(), string(resp.Body())
+	}
+
+	for i := 0; i < 3; i++ {
+		if status, body := get("/users?page=1", "alice"); status != fasthttp.StatusOK || body != `{"user":"alice"}` {
+			t.Fatalf("Request %d: unexpected answer %d %s", i, status, body)
+		}
+	}
+	if calls := upstreamCalls.Load(); calls != 1 {
+		t.Fatalf("Expected repeated GETs to reach the upstream once, got %d calls", calls)
+	}
+
+	// Another identity or query is a different request
+	if _, body := get("/users?page=1", "bob"); body != `{"user":"bob"}` {
+		t.Fatalf("Expected a fresh answer for another Authorization, got %s", body)
+	}
+	get("/users?page=2", "alice")
+	if calls := upstreamCalls.Load(); calls != 3 {
+		t.Fatalf("Expected 3 upstream calls, got %d", calls)
+	}
+
+	// Server errors are not reused
+	get("/flaky", "")
+	get("/flaky", "")
+	if calls := upstreamCalls.Load(); calls != 5 {
+		t.Fatalf("Expected errors to be retried upstream, got %d calls", calls)
+	}
+	if hits := server.Handler().CacheHits(); hits != 2 {
+		t.Fatalf("Expected 2 cache hits, got %d", hits)
+	}
+
+	// Every request is still recorded; hits point at the original recording
+	recordings := server.Recorder().Memory().Recordings()
+	if len(recordings) != 7 {
+		t.Fatalf("Expected every request to be recorded, got %d recordings", len(recordings))
+	}
+	var record map[string]map[string]interface{}
+	if err := json.Unmarshal(recordings[0].Data, &record); err != nil {
+		t.Fatal(err)
+	}
+	original := record["request"]["request_id"]
+	if _, ok := record["response"]["cached_from"]; ok {
+		t.Fatalf("The first recording should not be marked as cached: %v", record["response"])
+	}
+	for _, recording := range recordings[1:3] {
+		if err := json.Unmarshal(recording.Data, &record); err != nil {
+			t.Fatal(err)
+		}
+		if record["response"]["cached_from"] != original {
+			t.Fatalf("Expected cached_from %v, got %v", original, record["response"]["cached_from"])
+		}
+	}
+}
+
+func TestResponseCacheTTL(t *testing.T) {
+	var upstreamCalls atomic.Int32
+	upstream := startUpstream(t, func(ctx *fasthttp.RequestCtx) {
+		upstreamCalls.Add(1)
+		ctx.SetBodyString("ok")
+	})
+
+	recorder := NewMemoryRecorder()
+	p := NewProxyHandler(recorder, upstream)
+	if err := p.SetResponseCache(true, -time.Second); err == nil {
+		t.Fatal("Expected a negative TTL to be rejected")
+	}
+	if err := p.SetResponseCache(true, 20*time.Millisecond); err != nil {
+		t.Fatalf("Failed to enable the cache: %v", err)
+	}
+
+	serve := func() {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/status")
+		p.Handle(ctx)
+	}
+	serve()
+	serve()
+	time.Sleep(50 * time.Millisecond)
+	serve()
+	if calls := upstreamCalls.Load(); calls != 2 {
+		t.Fatalf("Expected the expired response to be fetched again, got %d upstream calls", calls)
+	}
+}
@@ -0,0 +1,243 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"gopkg.in/yaml.v3"
+)
+
+// Rewriter transforms upstream responses before they reach the client and the
+// recorder, so environment-specific values (absolute URLs, tokens, hosts) are
+// normalized at capture time. Every rule whose paths and methods match the
+// request applies, in file order.
+type Rewriter struct {
+	Rules []RewriteRule `yaml:"rules"`
+}
+
+// RewriteRule is one set of response transformations. Paths are globs as in
+// RecordFilter; empty paths or methods match every request.
+type RewriteRule struct {
+	Paths         []string               `yaml:"paths"`
+	Methods       []string               `yaml:"methods"`
+	SetHeaders    map[string]string      `yaml:"set_headers"`    // Added or replaced response headers
+	RemoveHeaders []string               `yaml:"remove_headers"` // Header patterns as accepted by ParseHeaderPatterns
+	Replace       []RewriteReplacement   `yaml:"replace"`        // Literal replacements in header values and the body
+	SetFields     map[string]interface{} `yaml:"set_fields"`     // JSON path -> new value, in JSON bodies and SSE event data
+
+	fields []rewriteField
+}
+
+// RewriteReplacement replaces every occurrence of From with To.
+type RewriteReplacement struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+type rewriteField struct {
+	path  []string
+	value interface{}
+}
+
+// LoadRewriter reads rewrite rules from a YAML file.
+func LoadRewriter(path string) (*Rewriter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read rewrite rules: %w", err)
+	}
+	rewriter := &Rewriter{}
+	if err := yaml.Unmarshal(data, rewriter); err != nil {
+		return nil, fmt.Errorf("parse rewrite rules: %w", err)
+	}
+	if err := rewriter.compile(); err != nil {
+		return nil, err
+	}
+	return rewriter, nil
+}
+
+// SetRewriter applies the rewriter to every proxied response.
+func (p *ProxyHandler) SetRewriter(rewriter *Rewriter) {
+	p.rewriter = rewriter
+}
+
+func (w *Rewriter) compile() error {
+	for i := range w.Rules {
+		rule := &w.Rules[i]
+		filter := RecordFilter{IncludePaths: rule.Paths, Methods: rule.Methods}
+		if err := filter.compile(); err != nil {
+			return fmt.Errorf("rewrite rule %d: %w", i+1, err)
+		}
+		for j, pattern := range rule.RemoveHeaders {
+			rule.RemoveHeaders[j] = strings.ToLower(strings.TrimSpace(pattern))
+		}
+		for _, replacement := range rule.Replace {
+			if replacement.From == "" {
+				return fmt.Errorf("rewrite rule %d: replace needs a non-empty from", i+1)
+			}
+		}
+		for field, value := range rule.SetFields {
+			segments, err := parseFieldPath(field)
+			if err != nil {
+				return fmt.Errorf("rewrite rule %d: %w", i+1, err)
+			}
+			rule.fields = append(rule.fields, rewriteField{path: segments, value: value})
+		}
+	}
+	return nil
+}
+
+// match returns the rules that apply to a request.
+func (w *Rewriter) match(method, requestPath string) []*RewriteRule {
+	if w == nil {
+		return nil
+	}
+	var rules []*RewriteRule
+	for i := range w.Rules {
+		rule := &w.Rules[i]
+		if len(rule.Methods) > 0 && !containsString(rule.Methods, strings.ToUpper(method)) {
+			continue
+		}
+		if len(rule.Paths) > 0 && !matchAnyPathGlob(rule.Paths, requestPath) {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// rewriteHeaders applies header removals, replacements and additions in place.
+func rewriteHeaders(rules []*RewriteRule, header *fasthttp.ResponseHeader) {
+	for _, rule := range rules {
+		// Collect the changes first; the header must not change while it is visited
+		var remove []string
+		replaced := make(map[string]string)
+		header.VisitAll(func(key, value []byte) {
+			name := strings.ToLower(string(key))
+			if matchHeaderPattern(rule.RemoveHeaders, name) {
+				remove = append(remove, string(key))
+				return
+			}
+			if len(rule.Replace) > 0 && name != "content-length" {
+				if text := rule.replaceText(string(value)); text != string(value) {
+					replaced[string(key)] = text
+				}
+			}
+		})
+		for _, key := range remove {
+			header.Del(key)
+		}
+		for key, value := range replaced {
+			header.Set(key, value)
+		}
+		for key, value := range rule.SetHeaders {
+			header.Set(key, value)
+		}
+	}
+}
+
+// replaceText applies the literal replacements of the rule.
+func (rule *RewriteRule) replaceText(text string) string {
+	for _, replacement := range rule.Replace {
+		text = strings.ReplaceAll(text, replacement.From, replacement.To)
+	}
+	return text
+}
+
+// rewritesBody reports whether any rule changes bodies.
+func rewritesBody(rules []*RewriteRule) bool {
+	for _, rule := range rules {
+		if len(rule.Replace) > 0 || len(rule.fields) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteBody applies the replacements and field rewrites of every rule.
+// Field rewrites only apply to JSON bodies; the result is re-marshaled.
+func rewriteBody(rules []*RewriteRule, body []byte) []byte {
+	for _, rule := range rules {
+		if len(rule.Replace) > 0 {
+			body = []byte(rule.replaceText(string(body)))
+		}
+		if len(rule.fields) == 0 {
+			continue
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(body, &decoded); err != nil {
+			continue
+		}
+		for _, field := range rule.fields {
+			setFieldPath(decoded, field.path, field.value)
+		}
+		if data, err := json.Marshal(decoded); err == nil {
+			body = data
+		}
+	}
+	return body
+}
+
+// Response rewrites a buffered upstream response in place. Compressed bodies
+// are decoded, rewritten and encoded again with the same Content-Encoding.
+func (w *Rewriter) Response(method, requestPath string, resp *fasthttp.Response) error {
+	rules := w.match(method, requestPath)
+	if len(rules) == 0 {
+		return nil
+	}
+
+	var err error
+	if rewritesBody(rules) && len(resp.Body()) > 0 {
+		err = rewriteResponseBody(rules, resp)
+	}
+	// Headers are rewritten even when the body could not be
+	rewriteHeaders(rules, &resp.Header)
+	return err
+}
+
+// rewriteResponseBody rewrites the body of a buffered response, keeping its Content-Encoding.
+func rewriteResponseBody(rules []*RewriteRule, resp *fasthttp.Response) error {
+	encoding := strings.ToLower(strings.TrimSpace(string(resp.Header.Peek("Content-Encoding"))))
+	body := resp.Body()
+	if encoding != "" && encoding != "identity" {
+		decoded, err := resp.BodyUncompressed()
+		if err != nil {
+			return fmt.Errorf("decode %s body: %w", encoding, err)
+		}
+		body = decoded
+	}
+
+	body = rewriteBody(rules, body)
+	switch encoding {
+	case "gzip":
+		body = fasthttp.AppendGzipBytes(nil, body)
+	case "deflate":
+		body = fasthttp.AppendDeflateBytes(nil, body)
+	case "br":
+		body = fasthttp.AppendBrotliBytes(nil, body)
+	}
+	resp.SetBody(body)
+	return nil
+}
+
+// rewriteSSELine rewrites one line of an event stream. Field rewrites apply to
+// single-line JSON data fields.
+func rewriteSSELine(rules []*RewriteRule, line string) string {
+	for _, rule := range rules {
+		if len(rule.Replace) > 0 {
+			line = rule.replaceText(line)
+		}
+		if len(rule.fields) == 0 {
+			continue
+		}
+		if data, ok := strings.CutPrefix(line, "data:"); ok {
+			data = strings.TrimPrefix(data, " ")
+			if rewritten := rewriteBody([]*RewriteRule{{fields: rule.fields}}, []byte(data)); string(rewritten) != data {
+				line = "data: " + string(rewritten)
+			}
+		}
+	}
+	return line
+}
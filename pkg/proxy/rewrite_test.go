@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestRewriteResponses(t *testing.T) {
+	upstream := startUpstream(t, func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType("application/json")
+		ctx.Response.Header.Set("Location", "https://api.staging.example.com/orders/1")
+		ctx.Response.Header.Set("Set-Cookie", "session=abc")
+		ctx.Response.Header.Set("Content-Encoding", "gzip")
+		ctx.SetBody(fasthttp.AppendGzipBytes(nil,
+			[]byte(`{"self":"https://api.staging.example.com/orders/1","auth":{"token":"live-123"}}`)))
+	})
+
+	rulesFile := filepath.Join(t.TempDir(), "rewrite.yml")
+	rules := `rules:
+  - paths: ["/orders/**"]
+    remove_headers: [set-cookie]
+    set_headers: {X-Recorded: "true"}
+    replace:
+      - from: https://api.staging.example.com
+        to: http://localhost:8080
+    set_fields:
+      $.auth.token: test-token
+  - paths: ["/health"]
+    set_headers: {X-Unused: "true"}
+`
+	if err := os.WriteFile(rulesFile, []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rewriter, err := LoadRewriter(rulesFile)
+	if err != nil {
+		t.Fatalf("Failed to load rewrite rules: %v", err)
+	}
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	p := NewProxyHandler(recorder, upstream)
+	p.SetRewriter(rewriter)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/orders/1")
+	ctx.Request.Header.SetMethod("GET")
+	p.Handle(ctx)
+
+	// The client sees the rewritten response, still gzip-encoded
+	body, err := ctx.Response.BodyGunzip()
+	if err != nil {
+		t.Fatalf("Expected a gzip body: %v", err)
+	}
+	want := `{"auth":{"token":"test-token"},"self":"http://localhost:8080/orders/1"}`
+	if string(body) != want {
+		t.Fatalf("Unexpected client body: %s", body)
+	}
+	if got := string(ctx.Response.Header.Peek("Location")); got != "http://localhost:8080/orders/1" {
+		t.Errorf("Unexpected Location: %q", got)
+	}
+	if ctx.Response.Header.Peek("Set-Cookie") != nil || string(ctx.Response.Header.Peek("X-Recorded")) != "true" {
+		t.Errorf("Expected Set-Cookie removed and X-Recorded set, got %s", ctx.Response.Header.Header())
+	}
+	if ctx.Response.Header.Peek("X-Unused") != nil {
+		t.Error("Expected rules for other paths not to apply")
+	}
+
+	// So does the recording
+	files, _ := filepath.Glob(filepath.Join(dir, "default", "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one recording, got %d", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	var record struct {
+		Response struct {
+			Headers map[string]string `json:"headers"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("Failed to parse recording: %v", err)
+	}
+	if record.Response.Headers["Location"] != "http://localhost:8080/orders/1" || record.Response.Headers["Set-Cookie"] != "" {
+		t.Errorf("Unexpected recorded headers: %v", record.Response.Headers)
+	}
+}
+
+func TestRewriteSSELine(t *testing.T) {
+	rewriter := &Rewriter{Rules: []RewriteRule{{
+		Replace:   []RewriteReplacement{{From: "staging", To: "local"}},
+		SetFields: map[string]interface{}{"token": "x"},
+	}}}
+	if err := rewriter.compile(); err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+	rules := rewriter.match("GET", "/stream")
+
+	tests := map[string]string{
+		`data: {"token":"secret","host":"staging"}`: `data: {"host":"local","token":"x"}`,
+		`data: plain staging text`:                  `data: plain local text`,
+		`event: update`:                             `event: update`,
+		``:                                          ``,
+	}
+	for line, want := range tests {
+		if got := rewriteSSELine(rules, line); got != want {
+			t.Errorf("%q: expected %q, got %q", line, want, got)
+		}
+	}
+
+	bad := &Rewriter{Rules: []RewriteRule{{Paths: []string{"orders"}}}}
+	if err := bad.compile(); err == nil || !strings.Contains(err.Error(), "rule 1") {
+		t.Errorf("Expected an invalid path error for rule 1, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Route sends requests under a path prefix to their own upstream.
+type Route struct {
+	Prefix      string // Path prefix without the trailing "/*", e.g. "/billing"
+	Target      string // Upstream base URL, scheme://host[:port]
+	StripPrefix bool   // Remove Prefix from the path before forwarding
+}
+
+// ParseRoute parses a routing rule of the form "/billing/* -> http://billing:8080",
+// optionally followed by "strip" to forward /billing/invoices as /invoices.
+// A prefix matches whole path segments: "/billing" and "/billing/*" are the
+// same rule and match /billing and everything below it, but not /billings.
+func ParseRoute(rule string) (Route, error) {
+	prefix, rest, ok := strings.Cut(rule, "->")
+	prefix = strings.TrimSpace(prefix)
+	fields := strings.Fields(rest)
+	if !ok || prefix == "" || len(fields) == 0 || len(fields) > 2 {
+		return Route{}, fmt.Errorf("invalid route %q (expected /prefix/* -> http://host:port [strip])", rule)
+	}
+
+	route := Route{Target: strings.TrimSuffix(fields[0], "/")}
+	if len(fields) == 2 {
+		if fields[1] != "strip" {
+			return Route{}, fmt.Errorf("invalid route %q: unknown option %q (expected strip)", rule, fields[1])
+		}
+		route.StripPrefix = true
+	}
+
+	if !strings.HasPrefix(prefix, "/") {
+		return Route{}, fmt.Errorf("invalid route %q: prefix must start with /", rule)
+	}
+	prefix = strings.TrimSuffix(prefix, "*")
+	route.Prefix = strings.TrimSuffix(prefix, "/")
+	if strings.Contains(route.Prefix, "*") {
+		return Route{}, fmt.Errorf("invalid route %q: * is only allowed at the end of the prefix", rule)
+	}
+
+	// SSE and WebSocket tunnels dial the target directly, so it cannot carry a path
+	target, err := url.Parse(route.Target)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return Route{}, fmt.Errorf("invalid route %q: target must be http(s)://host[:port]", rule)
+	}
+	if target.Path != "" || target.RawQuery != "" {
+		return Route{}, fmt.Errorf("invalid route %q: target must not contain a path", rule)
+	}
+
+	return route, nil
+}
+
+// LoadRoutes reads one routing rule per line from path. Blank lines and lines
+// starting with # are ignored.
+func LoadRoutes(path string) ([]Route, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open routes file: %w", err)
+	}
+	defer file.Close()
+
+	var routes []Route
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		route, err := ParseRoute(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		routes = append(routes, route)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read routes file: %w", err)
+	}
+	return routes, nil
+}
+
+// AddRoute forwards requests under route.Prefix to route.Target. The longest
+// matching prefix wins; requests matching no route go to the default target.
+func (p *ProxyHandler) AddRoute(route Route) {
+	p.routes = append(p.routes, route)
+	sort.SliceStable(p.routes, func(i, j int) bool {
+		return len(p.routes[i].Prefix) > len(p.routes[j].Prefix)
+	})
+}
+
+// matchRoute returns the route responsible for path, or nil.
+func (p *ProxyHandler) matchRoute(path []byte) *Route {
+	for i := range p.routes {
+		prefix := p.routes[i].Prefix
+		if len(path) < len(prefix) || string(path[:len(prefix)]) != prefix {
+			continue
+		}
+		if len(path) == len(prefix) || path[len(prefix)] == '/' {
+			return &p.routes[i]
+		}
+	}
+	return nil
+}
+
+// upstreamPath returns the path to request from the route's target.
+func (r *Route) upstreamPath(path string) string {
+	if !r.StripPrefix {
+		return path
+	}
+	if path = path[len(r.Prefix):]; path == "" {
+		return "/"
+	}
+	return path
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestParseRoute(t *testing.T) {
+	tests := []struct {
+		rule    string
+		want    Route
+		wantErr bool
+	}{
+		{rule: "/billing/* -> http://billing:8080", want: Route{Prefix: "/billing", Target: "http://billing:8080"}},
+		{rule: "/billing->https://billing/ strip", want: Route{Prefix: "/billing", Target: "https://billing", StripPrefix: true}},
+		{rule: "/* -> http://catch-all", want: Route{Prefix: "", Target: "http://catch-all"}},
+		{rule: "/billing/* http://billing:8080", wantErr: true},
+		{rule: "billing/* -> http://billing:8080", wantErr: true},
+		{rule: "/bill*/x -> http://billing:8080", wantErr: true},
+		{rule: "/billing/* -> billing:8080", wantErr: true},
+		{rule: "/billing/* -> http://billing:8080/v2", wantErr: true},
+		{rule: "/billing/* -> http://billing:8080 rewrite", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		route, err := ParseRoute(tt.rule)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected error, got %+v", tt.rule, route)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.rule, err)
+			continue
+		}
+		if route != tt.want {
+			t.Errorf("%q: expected %+v, got %+v", tt.rule, tt.want, route)
+		}
+	}
+}
+
+func TestRoutesSelectUpstream(t *testing.T) {
+	billing := startUpstream(t, func(ctx *fasthttp.RequestCtx) {
+		ctx.SetBodyString("billing " + string(ctx.RequestURI()))
+	})
+	users := startUpstream(t, func(ctx *fasthttp.RequestCtx) {
+		ctx.SetBodyString("users " + string(ctx.RequestURI()))
+	})
+	fallback := startUpstream(t, func(ctx *fasthttp.RequestCtx) {
+		ctx.SetBodyString("fallback " + string(ctx.RequestURI()))
+	})
+
+	routesFile := filepath.Join(t.TempDir(), "routes.txt")
+	content := "# mesh\n/billing/* -> " + billing + " strip\n\n/billing/users/* -> " + users + "\n"
+	if err := os.WriteFile(routesFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write routes: %v", err)
+	}
+	routes, err := LoadRoutes(routesFile)
+	if err != nil {
+		t.Fatalf("Failed to load routes: %v", err)
+	}
+
+	recorder, err := NewRecorder(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	p := NewProxyHandler(recorder, fallback)
+	for _, route := range routes {
+		p.AddRoute(route)
+	}
+
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{"/billing/invoices?page=2", "billing /invoices?page=2"},
+		{"/billing", "billing /"},
+		{"/billing/users/7", "users /billing/users/7"},
+		{"/billings", "fallback /billings"},
+		{"/status", "fallback /status"},
+	}
+	for _, tt := range tests {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(tt.uri)
+		p.Handle(ctx)
+		if got := string(ctx.Response.Body()); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.uri, tt.want, got)
+		}
+	}
+
+	// Without a default target, unrouted requests are rejected
+	p = NewProxyHandler(recorder, "")
+	p.AddRoute(routes[0])
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/status")
+	p.Handle(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusBadGateway {
+		t.Errorf("Expected 502 for an unrouted request, got %d", ctx.Response.StatusCode())
+	}
+}
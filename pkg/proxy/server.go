@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ServerOptions configures a Server.
+type ServerOptions struct {
+	// Dir receives the recordings; ignored with InMemory.
+	Dir string
+	// InMemory keeps recordings in Recorder().Memory() instead of on disk.
+	InMemory bool
+	// Addr is the listen address (default "127.0.0.1:0", a random free port).
+	Addr string
+	// Configure adjusts the recorder and handler before the server starts,
+	// e.g. to add conditions, redaction or rewrites.
+	Configure func(*Recorder, *ProxyHandler) error
+}
+
+// Server is a recording reverse proxy for use as a library, e.g. to capture
+// fixtures from integration tests:
+//
+//	server, _ := proxy.NewServer("https://api.example.com", proxy.ServerOptions{InMemory: true})
+//	server.Start()
+//	defer server.Shutdown(context.Background())
+//	// point the client at server.URL(), then read server.Recorder().Memory().Recordings()
+type Server struct {
+	recorder *Recorder
+	handler  *ProxyHandler
+	addr     string
+
+	mutex  sync.Mutex
+	server *fasthttp.Server
+	url    string
+}
+
+// NewServer creates a proxy to target that records every exchange.
+func NewServer(target string, opts ServerOptions) (*Server, error) {
+	if parsed, err := url.Parse(target); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid target %q: expected an absolute URL such as http://host:port", target)
+	}
+
+	var recorder *Recorder
+	if opts.InMemory {
+		recorder = NewMemoryRecorder()
+	} else {
+		if opts.Dir == "" {
+			return nil, fmt.Errorf("a recording directory is required unless InMemory is set")
+		}
+		var err error
+		if recorder, err = NewRecorder(opts.Dir); err != nil {
+			return nil, err
+		}
+	}
+
+	handler := NewProxyHandler(recorder, target)
+	if opts.Configure != nil {
+		if err := opts.Configure(recorder, handler); err != nil {
+			return nil, err
+		}
+	}
+	addr := opts.Addr
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	return &Server{recorder: recorder, handler: handler, addr: addr}, nil
+}
+
+// Recorder returns the recorder, e.g. for Memory().
+func (s *Server) Recorder() *Recorder {
+	return s.recorder
+}
+
+// Handler returns the proxy handler.
+func (s *Server) Handler() *ProxyHandler {
+	return s.handler
+}
+
+// Start listens and serves in the background.
+func (s *Server) Start() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.server != nil {
+		return fmt.Errorf("server already started")
+	}
+
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.server = &fasthttp.Server{
+		Handler: func(ctx *fasthttp.RequestCtx) {
+			if string(ctx.Method()) == fasthttp.MethodConnect {
+				s.handler.HandleConnect(ctx)
+				return
+			}
+			s.handler.Handle(ctx)
+		},
+		Name:              "AutoRecordingProxy",
+		StreamRequestBody: s.handler.streamUploadsOver > 0,
+	}
+	s.url = "http://" + ln.Addr().String()
+	go s.server.Serve(ln)
+	return nil
+}
+
+// URL returns the base URL of the running proxy, or "" before Start.
+func (s *Server) URL() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.url
+}
+
+// Shutdown stops accepting connections, waits for open requests until ctx is
+// done and flushes recordings queued for the background writer.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mutex.Lock()
+	server := s.server
+	s.server = nil
+	s.mutex.Unlock()
+	if server == nil {
+		return nil
+	}
+
+	err := server.ShutdownWithContext(ctx)
+	s.recorder.Close()
+	return err
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+func TestServerRecordsInMemory(t *testing.T) {
+	upstream := startUpstream(t, func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType("application/json")
+		ctx.SetBodyString(`{"path":"` + string(ctx.Path()) + `"}`)
+	})
+
+	server, err := NewServer(upstream, ServerOptions{InMemory: true})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+
+	for _, path := range []string{"/users", "/orders"} {
+		status, body, err := fasthttp.Get(nil, server.URL()+path)
+		if err != nil || status != fasthttp.StatusOK || string(body) != `{"path":"`+path+`"}` {
+			t.Fatalf("GET %s through the proxy: %d %s (%v)", path, status, body, err)
+		}
+	}
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	recordings := server.Recorder().Memory().Recordings()
+	if len(recordings) != 2 || recordings[0].MockID != "default" || filepath.Dir(recordings[0].Path) != "default" {
+		t.Fatalf("Expected two in-memory recordings under default/, got %+v", recordings)
+	}
+
+	// Written out, they load as a mock dir
+	dir := t.TempDir()
+	if err := server.Recorder().Memory().WriteTo(dir); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to load the recordings: %v", err)
+	}
+	if mock := store.FindResponse("/orders", "default", "application/json", "GET"); mock == nil || string(mock.Body) != `{"path":"/orders"}` {
+		t.Fatalf("Expected the recorded /orders mock, got %+v", mock)
+	}
+
+	server.Recorder().Memory().Reset()
+	if len(server.Recorder().Memory().Recordings()) != 0 {
+		t.Error("Expected Reset to discard the recordings")
+	}
+}
+
+func TestNewServerValidation(t *testing.T) {
+	if _, err := NewServer("localhost:8080", ServerOptions{InMemory: true}); err == nil {
+		t.Error("Expected an error for a target without a scheme")
+	}
+	if _, err := NewServer("http://localhost:8080", ServerOptions{}); err == nil {
+		t.Error("Expected an error without a directory or InMemory")
+	}
+	if err := NewMemoryRecorder().SetCorpusLimits(1024, 0); err == nil {
+		t.Error("Expected corpus limits to be refused for an in-memory recorder")
+	}
+}
@@ -0,0 +1,150 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+// Reasons an SSE capture was finalized before the stream ended, stored as
+// "truncated" in the recording's response.
+const (
+	SSELimitDuration = "max_duration"
+	SSELimitEvents   = "max_events"
+	SSELimitBytes    = "max_bytes"
+)
+
+// sseLimits bound the capture of a single SSE stream; zero disables a limit.
+type sseLimits struct {
+	maxDuration time.Duration
+	maxEvents   int
+	maxBytes    int64
+}
+
+// SetSSELimits bounds how much of an SSE stream is captured: maxDuration since
+// the request started, maxEvents recorded events and maxBytes (e.g. "1MB") of
+// event lines. Zero or empty disables a limit. When one is reached the
+// recording is written right away with "truncated" set to the limit; the
+// stream keeps flowing to the client, but nothing more is captured.
+func (p *ProxyHandler) SetSSELimits(maxDuration time.Duration, maxEvents int, maxBytes string) error {
+	if maxDuration < 0 || maxEvents < 0 {
+		return fmt.Errorf("SSE limits must not be negative")
+	}
+	limits := sseLimits{maxDuration: maxDuration, maxEvents: maxEvents}
+	if maxBytes != "" {
+		size, err := storage.ParseSize(maxBytes)
+		if err != nil {
+			return err
+		}
+		if size <= 0 {
+			return fmt.Errorf("SSE byte limit must be positive")
+		}
+		limits.maxBytes = int64(size)
+	}
+	p.sseLimits = limits
+	return nil
+}
+
+// sseCapture accumulates the events of one SSE stream and records them once,
+// when the stream ends or a limit is reached. The duration limit fires from a
+// timer, so a silent upstream is finalized too.
+type sseCapture struct {
+	p            *ProxyHandler
+	reqData      *RequestData
+	resp         *fasthttp.Response // Status and headers only; owned by the capture
+	savedHeaders map[string]string
+	startTime    time.Time
+
+	mutex   sync.Mutex
+	events  []interface{}
+	current bytes.Buffer
+	size    int64
+	done    bool
+	timer   *time.Timer
+}
+
+// newSSECapture starts capturing a stream whose headers were read into resp.
+func (p *ProxyHandler) newSSECapture(reqData *RequestData, resp *fasthttp.Response, savedHeaders map[string]string, startTime time.Time) *sseCapture {
+	c := &sseCapture{
+		p:            p,
+		reqData:      reqData,
+		resp:         &fasthttp.Response{},
+		savedHeaders: savedHeaders,
+		startTime:    startTime,
+		events:       []interface{}{},
+	}
+	// The upstream response is released once the handler returns, before the stream ends
+	resp.Header.CopyTo(&c.resp.Header)
+	if max := p.sseLimits.maxDuration; max > 0 {
+		c.timer = time.AfterFunc(max-time.Since(startTime), func() { c.finish(SSELimitDuration) })
+	}
+	return c
+}
+
+// line adds one relayed line to the current event.
+func (c *sseCapture) line(line string) {
+	c.mutex.Lock()
+	if c.done {
+		c.mutex.Unlock()
+		return
+	}
+	limits := c.p.sseLimits
+	c.size += int64(len(line)) + 1
+	if limits.maxBytes > 0 && c.size > limits.maxBytes {
+		c.mutex.Unlock()
+		c.finish(SSELimitBytes)
+		return
+	}
+
+	c.current.WriteString(line + "\n")
+	// Empty line = end of SSE event
+	if line == "" && c.current.Len() > 1 {
+		// Multiple data lines in one event are joined with newlines
+		if data, ok := parseSSEEventBlock(c.current.String()); ok {
+			c.events = append(c.events, newSSEEventRecord(data, time.Since(c.startTime).Seconds()))
+		}
+		c.current.Reset()
+	}
+	full := limits.maxEvents > 0 && len(c.events) >= limits.maxEvents
+	c.mutex.Unlock()
+	if full {
+		c.finish(SSELimitEvents)
+	}
+}
+
+// finish records the events captured so far, once. reason is empty when the
+// stream ended on its own, or the limit that cut the capture short.
+func (c *sseCapture) finish(reason string) {
+	c.mutex.Lock()
+	if c.done {
+		c.mutex.Unlock()
+		return
+	}
+	c.done = true
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	events := c.events
+	c.events = nil
+	c.current.Reset()
+	c.mutex.Unlock()
+
+	reqData := c.reqData
+	reqData.Truncated = reason
+	elapsedSeconds := time.Since(c.startTime).Seconds()
+	if err := c.p.recorder.RecordSSEPair(reqData, c.resp, events, elapsedSeconds, c.savedHeaders); errors.Is(err, ErrRecordSkipped) {
+		log.Printf("[%s] ⏭️  SSE not recorded (condition)", reqData.RequestID)
+	} else if err != nil {
+		log.Printf("[%s] ⚠️  Failed to record SSE: %v", reqData.RequestID, err)
+	} else if reason != "" {
+		log.Printf("[%s] ✂️  SSE capture stopped at %s: %d events recorded (%.3fs)", reqData.RequestID, reason, len(events), elapsedSeconds)
+	} else {
+		log.Printf("[%s] ✓ SSE completed: %d events recorded (%.3fs)", reqData.RequestID, len(events), elapsedSeconds)
+	}
+}
@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// readSSERecording returns the response of the only recording in dir/default.
+func readSSERecording(t *testing.T, dir string) map[string]interface{} {
+	t.Helper()
+	files, _ := filepath.Glob(filepath.Join(dir, "default", "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one recording, got %v", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record map[string]map[string]interface{}
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatal(err)
+	}
+	return record["response"]
+}
+
+func TestSSECaptureLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		duration  time.Duration
+		events    int
+		bytes     string
+		lines     int // Events sent before the stream ends
+		wait      time.Duration
+		truncated string
+		recorded  int
+	}{
+		{name: "no limits", lines: 5, recorded: 5},
+		{name: "max events", events: 3, lines: 5, truncated: SSELimitEvents, recorded: 3},
+		{name: "max bytes", bytes: "40", lines: 5, truncated: SSELimitBytes, recorded: 2},
+		{name: "max duration on a silent stream", duration: 20 * time.Millisecond, lines: 1, wait: 200 * time.Millisecond, truncated: SSELimitDuration, recorded: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			recorder, err := NewRecorder(dir)
+			if err != nil {
+				t.Fatalf("Failed to create recorder: %v", err)
+			}
+			p := NewProxyHandler(recorder, "http://upstream")
+			if err := p.SetSSELimits(tt.duration, tt.events, tt.bytes); err != nil {
+				t.Fatalf("Failed to set SSE limits: %v", err)
+			}
+
+			resp := fasthttp.AcquireResponse()
+			resp.Header.SetContentType("text/event-stream")
+			reqData := &RequestData{RequestID: "sse", Method: "GET", URL: "/events", Headers: map[string]string{}}
+			capture := p.newSSECapture(reqData, resp, map[string]string{"Content-Type": "text/event-stream"}, time.Now())
+			// The capture must not depend on the upstream response after the handler returns
+			fasthttp.ReleaseResponse(resp)
+
+			for i := 0; i < tt.lines; i++ {
+				capture.line(`data: {"n":1}`) // 14 bytes with the newline
+				capture.line("")
+			}
+			time.Sleep(tt.wait)
+			capture.finish("")
+
+			response := readSSERecording(t, dir)
+			if events, _ := response["body"].([]interface{}); len(events) != tt.recorded {
+				t.Errorf("Expected %d recorded events, got %d", tt.recorded, len(events))
+			}
+			truncated, _ := response["truncated"].(string)
+			if truncated != tt.truncated {
+				t.Errorf("Expected truncated %q, got %q", tt.truncated, truncated)
+			}
+			if response["status_code"].(float64) != 200 {
+				t.Errorf("Expected the captured status, got %v", response["status_code"])
+			}
+		})
+	}
+
+	p := NewProxyHandler(nil, "http://upstream")
+	if err := p.SetSSELimits(-time.Second, 0, ""); err == nil {
+		t.Error("Expected an error for a negative duration")
+	}
+	if err := p.SetSSELimits(0, 0, "lots"); err == nil {
+		t.Error("Expected an error for an invalid byte limit")
+	}
+}
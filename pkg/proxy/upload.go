@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+// streamedBody stands in for a request body that was streamed upstream instead
+// of being buffered. It is recorded as {"streamed":true,"size":N,"file":...}.
+type streamedBody struct {
+	size int64  // Bytes forwarded so far, updated atomically while streaming
+	file string // Capture path relative to the mock_id directory, if captured
+}
+
+// MarshalJSON renders the placeholder stored in the recording.
+func (b *streamedBody) MarshalJSON() ([]byte, error) {
+	record := map[string]interface{}{
+		"streamed": true,
+		"size":     atomic.LoadInt64(&b.size),
+	}
+	if b.file != "" {
+		record["file"] = b.file
+	}
+	return json.Marshal(record)
+}
+
+// countingReader counts the bytes read through it into a streamedBody.
+type countingReader struct {
+	r    io.Reader
+	body *streamedBody
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(&c.body.size, int64(n))
+	return n, err
+}
+
+// SetUploadStreaming makes request bodies larger than threshold (e.g. "10MB"),
+// or of unknown length, stream to the upstream instead of being buffered. With
+// capture enabled the streamed bytes are also written next to the recording.
+// The server must be created with StreamRequestBody enabled.
+func (p *ProxyHandler) SetUploadStreaming(threshold string, capture bool) error {
+	size, err := storage.ParseSize(threshold)
+	if err != nil {
+		return err
+	}
+	if size <= 0 {
+		return fmt.Errorf("upload streaming threshold must be positive")
+	}
+	p.streamUploadsOver = int64(size)
+	p.captureUploads = capture
+	return nil
+}
+
+// shouldStreamUpload reports whether the request body should bypass buffering.
+func (p *ProxyHandler) shouldStreamUpload(ctx *fasthttp.RequestCtx) bool {
+	if p.streamUploadsOver <= 0 || !ctx.Request.IsBodyStream() {
+		return false
+	}
+	contentLength := ctx.Request.Header.ContentLength()
+	return contentLength < 0 || int64(contentLength) > p.streamUploadsOver
+}
+
+// openUploadStream wraps the incoming body stream for forwarding. The returned
+// closer must be called once the upstream request has completed.
+func (p *ProxyHandler) openUploadStream(ctx *fasthttp.RequestCtx, hostDir, mockID, requestID string) (*streamedBody, io.Reader, func(), error) {
+	body := &streamedBody{}
+	var stream io.Reader = ctx.RequestBodyStream()
+	closer := func() {}
+
+	if p.captureUploads && p.recorder.memory == nil {
+		file, relPath, err := p.recorder.createUploadCapture(hostDir, mockID, requestID)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		body.file = relPath
+		stream = io.TeeReader(stream, file)
+		closer = func() { file.Close() }
+	}
+
+	return body, &countingReader{r: stream, body: body}, closer, nil
+}
+
+// createUploadCapture creates the file that receives a streamed request body.
+// It lives in an uploads/ subdirectory, which the mock loaders skip.
+func (r *Recorder) createUploadCapture(hostDir, mockID, requestID string) (*os.File, string, error) {
+	mockDir := r.mockDir(hostDir, mockID)
+	if err := os.MkdirAll(filepath.Join(mockDir, "uploads"), 0755); err != nil {
+		return nil, "", err
+	}
+
+	relPath := filepath.Join("uploads", requestID+".bin")
+	file, err := os.Create(filepath.Join(mockDir, relPath))
+	if err != nil {
+		return nil, "", err
+	}
+	return file, filepath.ToSlash(relPath), nil
+}
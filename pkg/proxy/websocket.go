@@ -0,0 +1,169 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+// isWebSocketUpgrade reports whether the request asks to switch to the WebSocket protocol.
+func isWebSocketUpgrade(ctx *fasthttp.RequestCtx) bool {
+	return ctx.Request.Header.ConnectionUpgrade() &&
+		bytes.EqualFold(ctx.Request.Header.Peek("Upgrade"), []byte("websocket"))
+}
+
+// handleWebSocket forwards the upgrade handshake, then relays frames in both
+// directions until either side closes, and records the conversation.
+// A handshake the upstream refuses is passed through and recorded like any response.
+func (p *ProxyHandler) handleWebSocket(ctx *fasthttp.RequestCtx, req *fasthttp.Request, reqData *RequestData, targetBase string) {
+	log.Printf("[%s] 🔌 WebSocket upgrade requested", reqData.RequestID)
+	startTime := time.Now()
+
+	upstream, err := p.dialUpstream(targetBase, reqData.RequestID, "WebSocket")
+	if err != nil {
+		log.Printf("[%s] ❌ WebSocket connection error: %v", reqData.RequestID, err)
+		ctx.SetStatusCode(fasthttp.StatusBadGateway)
+		ctx.SetBodyString("Failed to connect to upstream")
+		return
+	}
+
+	bw := bufio.NewWriter(upstream)
+	if err := req.Write(bw); err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		log.Printf("[%s] ❌ WebSocket handshake write error: %v", reqData.RequestID, err)
+		upstream.Close()
+		ctx.SetStatusCode(fasthttp.StatusBadGateway)
+		ctx.SetBodyString("Failed to write request to upstream")
+		return
+	}
+
+	br := bufio.NewReader(upstream)
+	resp := fasthttp.AcquireResponse()
+	if err := resp.Header.Read(br); err != nil {
+		log.Printf("[%s] ❌ WebSocket handshake read error: %v", reqData.RequestID, err)
+		fasthttp.ReleaseResponse(resp)
+		upstream.Close()
+		ctx.SetStatusCode(fasthttp.StatusBadGateway)
+		ctx.SetBodyString("Failed to read response headers from upstream")
+		return
+	}
+	handshakeSeconds := time.Since(startTime).Seconds()
+
+	if resp.StatusCode() != fasthttp.StatusSwitchingProtocols {
+		defer fasthttp.ReleaseResponse(resp)
+		defer upstream.Close()
+		p.relayRefusedUpgrade(ctx, br, resp, reqData, handshakeSeconds)
+		return
+	}
+
+	log.Printf("[%s] 🔌 WebSocket established (%.3fs)", reqData.RequestID, handshakeSeconds)
+
+	// The 101 response and everything after it go straight over the hijacked connection
+	ctx.HijackSetNoResponse(true)
+	ctx.Hijack(func(client net.Conn) {
+		defer fasthttp.ReleaseResponse(resp)
+		defer upstream.Close()
+
+		if _, err := client.Write(resp.Header.Header()); err != nil {
+			log.Printf("[%s] ❌ WebSocket handshake relay error: %v", reqData.RequestID, err)
+			return
+		}
+
+		frames := relayWebSocketFrames(bufio.NewReader(client), client, br, upstream)
+		log.Printf("[%s] 🔌 WebSocket closed after %d frame(s)", reqData.RequestID, len(frames))
+
+		if err := p.recorder.RecordWebSocket(reqData, resp, frames, handshakeSeconds); errors.Is(err, ErrRecordSkipped) {
+			log.Printf("[%s] ⏭️  Not recorded (condition)", reqData.RequestID)
+		} else if err != nil {
+			log.Printf("[%s] ⚠️  Failed to record: %v", reqData.RequestID, err)
+		}
+	})
+}
+
+// relayRefusedUpgrade passes a non-101 handshake answer back to the client.
+func (p *ProxyHandler) relayRefusedUpgrade(ctx *fasthttp.RequestCtx, br *bufio.Reader, resp *fasthttp.Response, reqData *RequestData, elapsedSeconds float64) {
+	if err := resp.ReadBody(br, 0); err != nil && !errors.Is(err, io.EOF) {
+		log.Printf("[%s] ❌ WebSocket refusal body read error: %v", reqData.RequestID, err)
+		ctx.SetStatusCode(fasthttp.StatusBadGateway)
+		ctx.SetBodyString("Failed to read response body from upstream")
+		return
+	}
+
+	if err := p.recorder.RecordPair(reqData, resp, elapsedSeconds); errors.Is(err, ErrRecordSkipped) {
+		log.Printf("[%s] ⏭️  Not recorded (condition)", reqData.RequestID)
+	} else if err != nil {
+		log.Printf("[%s] ⚠️  Failed to record: %v", reqData.RequestID, err)
+	}
+
+	log.Printf("[%s] ✓ %d upgrade refused (%.3fs)", reqData.RequestID, resp.StatusCode(), elapsedSeconds)
+
+	ctx.SetStatusCode(resp.StatusCode())
+	resp.Header.VisitAll(func(key, value []byte) {
+		switch string(bytes.ToLower(key)) {
+		case "connection", "keep-alive", "transfer-encoding", "upgrade", "x-mock-id":
+			return
+		}
+		ctx.Response.Header.SetBytesKV(key, value)
+	})
+	ctx.SetBody(resp.Body())
+}
+
+// relayWebSocketFrames copies frames between client and upstream unchanged and
+// returns them in the order they were relayed. It returns once both directions
+// have ended; when one side goes away the other is shut down too.
+func relayWebSocketFrames(clientReader *bufio.Reader, client net.Conn, upstreamReader *bufio.Reader, upstream net.Conn) []storage.WebSocketFrame {
+	start := time.Now()
+	var mutex sync.Mutex
+	var frames []storage.WebSocketFrame
+	var closeOnce sync.Once
+	shutdown := func() {
+		upstream.Close()
+		// fasthttp closes hijacked connections itself once the handler returns,
+		// so an expired deadline is what unblocks the pending client read
+		client.SetDeadline(time.Now())
+	}
+
+	pump := func(src *bufio.Reader, dst net.Conn, fromClient bool) {
+		defer closeOnce.Do(shutdown)
+		for {
+			frame, raw, err := storage.ReadWebSocketFrame(src)
+			if err != nil {
+				return
+			}
+			frame.FromClient = fromClient
+			frame.Timestamp = time.Since(start).Seconds()
+
+			mutex.Lock()
+			frames = append(frames, frame)
+			mutex.Unlock()
+
+			if _, err := dst.Write(raw); err != nil {
+				return
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		pump(clientReader, upstream, true)
+	}()
+	go func() {
+		defer wg.Done()
+		pump(upstreamReader, client, false)
+	}()
+	wg.Wait()
+
+	return frames
+}
@@ -0,0 +1,156 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+	"github.com/valyala/fasthttp"
+)
+
+// websocketEcho upgrades the connection and echoes text frames until the client closes.
+func websocketEcho(ctx *fasthttp.RequestCtx) {
+	accept := storage.WebSocketAccept(string(ctx.Request.Header.Peek("Sec-WebSocket-Key")))
+	ctx.HijackSetNoResponse(true)
+	ctx.Hijack(func(conn net.Conn) {
+		conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " + accept + "\r\n\r\n"))
+		br := bufio.NewReader(conn)
+		for {
+			frame, _, err := storage.ReadWebSocketFrame(br)
+			if err != nil {
+				return
+			}
+			if frame.Opcode == storage.WebSocketClose {
+				conn.Write(storage.AppendWebSocketFrame(nil, storage.WebSocketClose, true, frame.Payload))
+				return
+			}
+			conn.Write(storage.AppendWebSocketFrame(nil, frame.Opcode, true, append([]byte("echo: "), frame.Payload...)))
+		}
+	})
+}
+
+// maskedFrame builds a client-to-server frame, which RFC 6455 requires to be masked.
+func maskedFrame(opcode byte, payload []byte) []byte {
+	key := []byte{0x12, 0x34, 0x56, 0x78}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, key...)
+	for i, b := range payload {
+		frame = append(frame, b^key[i%4])
+	}
+	return frame
+}
+
+func TestWebSocketProxiedAndRecorded(t *testing.T) {
+	upstream := startUpstream(t, websocketEcho)
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	proxyURL := startUpstream(t, NewProxyHandler(recorder, upstream).Handle)
+
+	conn, err := net.DialTimeout("tcp", strings.TrimPrefix(proxyURL, "http://"), time.Second)
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	conn.Write([]byte("GET /ws HTTP/1.1\r\nHost: example\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: " + key + "\r\nx-mock-id: chat\r\n\r\n"))
+
+	br := bufio.NewReader(conn)
+	var resp fasthttp.ResponseHeader
+	if err := resp.Read(br); err != nil {
+		t.Fatalf("Failed to read handshake: %v", err)
+	}
+	if resp.StatusCode() != fasthttp.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", resp.StatusCode())
+	}
+	if got := string(resp.Peek("Sec-WebSocket-Accept")); got != storage.WebSocketAccept(key) {
+		t.Fatalf("Unexpected Sec-WebSocket-Accept %q", got)
+	}
+
+	conn.Write(maskedFrame(storage.WebSocketText, []byte("hello")))
+	frame, _, err := storage.ReadWebSocketFrame(br)
+	if err != nil || string(frame.Payload) != "echo: hello" {
+		t.Fatalf("Expected echoed frame, got %q (%v)", frame.Payload, err)
+	}
+
+	closePayload := binary.BigEndian.AppendUint16(nil, 1000)
+	conn.Write(maskedFrame(storage.WebSocketClose, append(closePayload, "bye"...)))
+	if frame, _, err := storage.ReadWebSocketFrame(br); err != nil || frame.Opcode != storage.WebSocketClose {
+		t.Fatalf("Expected close frame, got opcode %d (%v)", frame.Opcode, err)
+	}
+
+	// The recording is written once the tunnel has shut down
+	var files []string
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		files, _ = filepath.Glob(filepath.Join(dir, "chat", "websocket_*.json"))
+		if len(files) > 0 {
+			break
+		}
+	}
+	if len(files) != 1 {
+		t.Fatalf("Expected one websocket recording, got %v", files)
+	}
+
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Failed to read recording: %v", err)
+	}
+	var record struct {
+		Type     string `json:"type"`
+		Response struct {
+			StatusCode int                      `json:"status_code"`
+			Body       []map[string]interface{} `json:"body"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("Failed to parse recording: %v", err)
+	}
+	if record.Type != "websocket" || record.Response.StatusCode != 101 {
+		t.Fatalf("Unexpected record type %q / status %d", record.Type, record.Response.StatusCode)
+	}
+
+	want := []struct{ direction, kind, data string }{
+		{"client", "text", "hello"},
+		{"server", "text", "echo: hello"},
+		{"client", "close", ""},
+		{"server", "close", ""},
+	}
+	if len(record.Response.Body) != len(want) {
+		t.Fatalf("Expected %d frames, got %+v", len(want), record.Response.Body)
+	}
+	for i, w := range want {
+		got := record.Response.Body[i]
+		if got["direction"] != w.direction || got["type"] != w.kind {
+			t.Errorf("Frame %d: expected %s %s, got %+v", i, w.direction, w.kind, got)
+		}
+		if w.data != "" && got["data"] != w.data {
+			t.Errorf("Frame %d: expected data %q, got %v", i, w.data, got["data"])
+		}
+	}
+	if last := record.Response.Body[3]; last["code"] != float64(1000) || last["reason"] != "bye" {
+		t.Errorf("Expected close 1000 \"bye\", got %+v", last)
+	}
+
+	// The storage side loads the record with its frames
+	store, err := storage.NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to load recordings: %v", err)
+	}
+	mock := store.FindResponse("/ws", "chat", storage.WebSocketContentType, "GET")
+	if mock == nil || !mock.IsWebSocket || len(mock.WebSocketFrames) != 4 {
+		t.Fatalf("Expected a websocket mock with 4 frames, got %+v", mock)
+	}
+}
@@ -0,0 +1,149 @@
+// Package scenariotest checks scenario config files in unit tests, without
+// starting the mock server:
+//
+//	func TestScenarios(t *testing.T) {
+//		h := scenariotest.Load(t, "mocks/scenarios.yml")
+//		h.Expect(scenariotest.Request{Method: "POST", Path: "/orders", Body: `{"sku":"A-1"}`}, "order-created")
+//		h.ExpectNone(scenariotest.Request{Method: "DELETE", Path: "/orders"})
+//	}
+//
+// Requests are matched like the mock handler matches them (method override,
+// canonical JSON, consumes/produces, filters), without advancing sequences.
+package scenariotest
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/andrey-viktorov/auto-mock-tools/pkg/storage"
+)
+
+// Request is a synthetic request to resolve.
+type Request struct {
+	Method     string            // Default GET
+	Path       string            // May include a query, which is ignored like by the server
+	Headers    map[string]string // e.g. Content-Type, Accept, X-HTTP-Method-Override
+	Body       string
+	ClientCert *x509.Certificate // Identity for client_cert scenarios
+	ClientIP   string            // Address for client_ip scenarios; an X-Forwarded-For header takes precedence
+}
+
+// Harness resolves requests against one scenario config.
+type Harness struct {
+	t     testing.TB
+	store *storage.MockStorage
+}
+
+// Load reads a scenario config (and the recordings it references) and fails
+// the test if it does not load.
+func Load(t testing.TB, configPath string) *Harness {
+	t.Helper()
+	store, err := storage.NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("scenariotest: %v", err)
+	}
+	if err := store.LoadScenarioConfig(configPath); err != nil {
+		t.Fatalf("scenariotest: %v", err)
+	}
+	return &Harness{t: t, store: store}
+}
+
+// Storage returns the storage holding the scenarios, e.g. to enable
+// SetCanonicalJSON or SetMethodOverride like the server flags do.
+func (h *Harness) Storage() *storage.MockStorage {
+	return h.store
+}
+
+// Resolve returns how the scenarios treat req: the answering scenario, if any,
+// and the verdict of every scenario registered for the path.
+func (h *Harness) Resolve(req Request) *storage.ScenarioTrace {
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = "GET"
+	}
+	path := req.Path
+	if idx := strings.IndexByte(path, '?'); idx >= 0 {
+		path = path[:idx]
+	}
+	var contentType, accept string
+	clientIP := net.ParseIP(req.ClientIP)
+	for key, value := range req.Headers {
+		switch strings.ToLower(key) {
+		case "content-type":
+			contentType = value
+		case "accept":
+			accept = value
+		case "x-forwarded-for":
+			if idx := strings.IndexByte(value, ','); idx >= 0 {
+				value = value[:idx]
+			}
+			if ip := net.ParseIP(strings.TrimSpace(value)); ip != nil {
+				clientIP = ip
+			}
+		case "x-http-method-override":
+			if h.store.MethodOverride && method == "POST" && value != "" {
+				method = strings.ToUpper(value)
+			}
+		}
+	}
+	body := []byte(req.Body)
+	if h.store.CanonicalJSON {
+		if canonical, ok := storage.CanonicalizeJSON(body); ok {
+			body = canonical
+		}
+	}
+	return h.store.EvaluateScenarios([]byte(path), []byte(method), []byte(contentType), []byte(accept), body, req.ClientCert, clientIP)
+}
+
+// Expect fails the test unless req is answered by the scenario named want.
+func (h *Harness) Expect(req Request, want string) {
+	h.t.Helper()
+	if trace := h.Resolve(req); trace.Scenario != want {
+		h.t.Errorf("%s: expected scenario %q, got %s", describe(req), want, explain(trace))
+	}
+}
+
+// ExpectNone fails the test if any scenario answers req.
+func (h *Harness) ExpectNone(req Request) {
+	h.t.Helper()
+	if trace := h.Resolve(req); trace.Scenario != "" {
+		h.t.Errorf("%s: expected no scenario, got %s", describe(req), explain(trace))
+	}
+}
+
+func describe(req Request) string {
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = "GET"
+	}
+	return method + " " + req.Path
+}
+
+// explain summarizes a trace: the answering scenario and why the others did
+// not match.
+func explain(trace *storage.ScenarioTrace) string {
+	answer := "no scenario"
+	if trace.Scenario != "" {
+		answer = fmt.Sprintf("%q", trace.Scenario)
+	}
+	if len(trace.Scenarios) == 0 {
+		return answer + " (no scenarios for this path)"
+	}
+	verdicts := make([]string, 0, len(trace.Scenarios))
+	for _, eval := range trace.Scenarios {
+		switch {
+		case eval.Skipped:
+			verdicts = append(verdicts, eval.Name+": skipped")
+		case eval.Matched && eval.DelayOnly:
+			verdicts = append(verdicts, eval.Name+": matched (delay only)")
+		case eval.Matched:
+			verdicts = append(verdicts, eval.Name+": matched")
+		default:
+			verdicts = append(verdicts, eval.Name+": "+eval.Reason+" mismatch")
+		}
+	}
+	return answer + " [" + strings.Join(verdicts, "; ") + "]"
+}
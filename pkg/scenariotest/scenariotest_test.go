@@ -0,0 +1,76 @@
+package scenariotest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExpect(t *testing.T) {
+	h := Load(t, "../../tests/fixtures/mock-example.yml")
+
+	h.Expect(Request{
+		Method:  "POST",
+		Path:    "/api/v1/status?verbose=1",
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    `{"processing":{"state":"done"},"payload":{"id":"ABC-1234"}}`,
+	}, "Status Ready With Valid ID")
+	h.Expect(Request{
+		Method: "post",
+		Path:   "/api/v1/status",
+		Body:   `{"processing":{"state":"pending"}}`,
+	}, "Status Fallback Default")
+	h.ExpectNone(Request{Path: "/api/v1/status"})
+	h.ExpectNone(Request{Method: "POST", Path: "/unknown"})
+}
+
+func TestMethodOverride(t *testing.T) {
+	h := Load(t, "../../tests/fixtures/test-method-override.yml")
+	req := Request{
+		Method:  "POST",
+		Path:    "/api/items/1",
+		Headers: map[string]string{"X-HTTP-Method-Override": "delete"},
+	}
+
+	h.ExpectNone(req)
+	h.Storage().SetMethodOverride(true)
+	h.Expect(req, "Delete Item")
+}
+
+// recordingTB captures failures instead of failing the test.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestExpectReportsReasons(t *testing.T) {
+	tb := &recordingTB{TB: t}
+	h := Load(t, "../../tests/fixtures/mock-example.yml")
+	h.t = tb
+
+	h.Expect(Request{Method: "POST", Path: "/api/v1/status", Body: `{"processing":{"state":"pending"}}`}, "Status Ready With Valid ID")
+	if len(tb.errors) != 1 {
+		t.Fatalf("Expected one failure, got %v", tb.errors)
+	}
+	for _, want := range []string{
+		`expected scenario "Status Ready With Valid ID", got "Status Fallback Default"`,
+		"Status Ready With Valid ID: filter.body mismatch",
+		"Status Fallback Default: matched",
+	} {
+		if !strings.Contains(tb.errors[0], want) {
+			t.Errorf("Failure %q does not mention %q", tb.errors[0], want)
+		}
+	}
+
+	tb.errors = nil
+	h.ExpectNone(Request{Method: "POST", Path: "/api/v1/status"})
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], `expected no scenario, got "Status Fallback Default"`) {
+		t.Fatalf("Unexpected failures: %v", tb.errors)
+	}
+}
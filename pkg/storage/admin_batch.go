@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Operations of an admin batch.
+const (
+	AdminBatchAdd    = "add"
+	AdminBatchUpdate = "update"
+	AdminBatchDelete = "delete"
+)
+
+// AdminBatchOperation is one entry of an admin batch: add registers Mock
+// under a new ID, update replaces the admin mock ID with Mock and delete
+// removes it.
+type AdminBatchOperation struct {
+	Op   string          `json:"op"`   // add (the default), update or delete
+	ID   string          `json:"id"`   // Admin mock ID, for update and delete
+	Mock json.RawMessage `json:"mock"` // Recording in the native JSON format, for add and update
+}
+
+// AdminBatchError names the operation a batch was rejected for.
+type AdminBatchError struct {
+	Index int // Position of the operation in the batch, from 0
+	Err   error
+}
+
+func (e *AdminBatchError) Error() string {
+	return fmt.Sprintf("operation #%d: %v", e.Index+1, e.Err)
+}
+
+func (e *AdminBatchError) Unwrap() error {
+	return e.Err
+}
+
+// adminBatchChange is a validated operation: previous is the admin mock
+// replaced or deleted, next the one registered.
+type adminBatchChange struct {
+	id       string
+	previous *MockResponse
+	next     *MockResponse
+}
+
+// ApplyAdminBatch applies operations all or nothing, so test setup can
+// configure many mocks in one round trip. Every operation is validated before
+// anything changes and requests see the indexes either before or after the
+// whole batch. It returns the mock registered by each operation, nil for
+// deletes. mockID applies to recordings without an x-mock-id header.
+func (s *MockStorage) ApplyAdminBatch(operations []AdminBatchOperation, mockID string) ([]*MockResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.adminMocks == nil {
+		s.adminMocks = make(map[string]*MockResponse)
+	}
+
+	// Later operations see the earlier ones, e.g. an update after a delete fails
+	current := make(map[string]*MockResponse)
+	lookup := func(id string) *MockResponse {
+		if mockResponse, ok := current[id]; ok {
+			return mockResponse
+		}
+		return s.adminMocks[id]
+	}
+
+	changes := make([]adminBatchChange, 0, len(operations))
+	for i, operation := range operations {
+		change := adminBatchChange{id: operation.ID}
+		switch operation.Op {
+		case AdminBatchAdd, "":
+			change.id = adminIDPrefix + generateRandomHex(6)
+			for lookup(change.id) != nil {
+				change.id = adminIDPrefix + generateRandomHex(6)
+			}
+		case AdminBatchUpdate, AdminBatchDelete:
+			if change.previous = lookup(operation.ID); change.previous == nil {
+				return nil, &AdminBatchError{Index: i, Err: ErrAdminMockNotFound}
+			}
+		default:
+			return nil, &AdminBatchError{Index: i, Err: fmt.Errorf("unknown op %q (expected add, update or delete)", operation.Op)}
+		}
+
+		if operation.Op != AdminBatchDelete {
+			next, err := s.parseAdminMock(change.id, operation.Mock, mockID)
+			if err != nil {
+				return nil, &AdminBatchError{Index: i, Err: err}
+			}
+			change.next = next
+		}
+		current[change.id] = change.next
+		changes = append(changes, change)
+	}
+
+	if err := s.persistAdminBatch(changes); err != nil {
+		return nil, err
+	}
+
+	registered := make([]*MockResponse, len(changes))
+	for i, change := range changes {
+		s.replaceAdminMock(change.id, change.next, change.previous)
+		registered[i] = change.next
+	}
+	s.cacheResponses()
+	return registered, nil
+}
+
+// persistAdminBatch writes and removes the files of a batch under
+// -persist-admin. When one fails, the files already touched get their
+// previous content back.
+func (s *MockStorage) persistAdminBatch(changes []adminBatchChange) error {
+	if !s.persistAdmin {
+		return nil
+	}
+	// Persisted admin mocks loaded from BaseDir are restored as stored
+	stored := make(map[*MockResponse][]byte)
+	for _, change := range changes {
+		if change.previous != nil && change.previous.adminRecord == nil {
+			stored[change.previous], _ = os.ReadFile(s.adminMockPath(change.previous))
+		}
+	}
+
+	for i, change := range changes {
+		var err error
+		if change.next != nil {
+			err = s.persistAdminMock(change.next, change.previous)
+		} else if err = os.Remove(s.adminMockPath(change.previous)); os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil {
+			for j := i - 1; j >= 0; j-- {
+				s.restoreAdminMockFile(changes[j], stored)
+			}
+			return &AdminBatchError{Index: i, Err: err}
+		}
+	}
+	return nil
+}
+
+// restoreAdminMockFile undoes the file change of a batch operation.
+func (s *MockStorage) restoreAdminMockFile(change adminBatchChange, stored map[*MockResponse][]byte) {
+	if change.next != nil {
+		os.Remove(s.adminMockPath(change.next))
+	}
+	if change.previous == nil {
+		return
+	}
+	record := change.previous.adminRecord
+	if record == nil {
+		record = stored[change.previous]
+	}
+	if len(record) > 0 {
+		os.WriteFile(s.adminMockPath(change.previous), record, 0644)
+	}
+}
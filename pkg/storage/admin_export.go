@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ExportAdminMocks writes every admin mock to dir/<mock-id>/<id>.json in the
+// native format, the layout of -mock-dir, so a mock set built through the
+// admin API can be committed as fixtures. An empty dir selects BaseDir. It
+// returns the written files, sorted by ID.
+func (s *MockStorage) ExportAdminMocks(dir string) ([]string, error) {
+	if dir == "" {
+		dir = s.BaseDir
+	}
+
+	s.mutex.RLock()
+	mocks := make([]*MockResponse, 0, len(s.adminMocks))
+	for _, mockResponse := range s.adminMocks {
+		mocks = append(mocks, mockResponse)
+	}
+	s.mutex.RUnlock()
+	sort.Slice(mocks, func(i, j int) bool { return mocks[i].RequestID < mocks[j].RequestID })
+
+	files := make([]string, 0, len(mocks))
+	for _, mockResponse := range mocks {
+		if !validMockIDDir(mockResponse.MockID) {
+			return files, fmt.Errorf("mock ID %q cannot be exported", mockResponse.MockID)
+		}
+		record := mockResponse.adminRecord
+		if record == nil {
+			// Persisted admin mocks loaded from BaseDir are exported as stored
+			data, err := os.ReadFile(mockResponse.SourceFile)
+			if err != nil {
+				return files, err
+			}
+			record = data
+		}
+
+		path := filepath.Join(dir, mockResponse.MockID, mockResponse.RequestID+".json")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return files, err
+		}
+		if err := os.WriteFile(path, record, 0644); err != nil {
+			return files, err
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// ImportAdminMocks registers the native recordings in dir/<mock-id>/*.json
+// as admin mocks, the reverse of ExportAdminMocks. A recording whose
+// request_id is an admin mock ID keeps it, replacing the admin mock with that
+// ID; the others get a new ID. Nothing is registered when a recording does
+// not load.
+func (s *MockStorage) ImportAdminMocks(dir string) ([]*MockResponse, error) {
+	type importedRecord struct {
+		id     string
+		data   []byte
+		mockID string
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var records []importedRecord
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name(), file.Name())
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			mockResponse, err := parseMockRecord(data, entry.Name())
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			if !strings.HasPrefix(mockResponse.Path, "/") {
+				return nil, fmt.Errorf("%s: request url %q has no absolute path", path, mockResponse.FullURL)
+			}
+			id := ""
+			if strings.HasPrefix(mockResponse.RequestID, adminIDPrefix) {
+				id = mockResponse.RequestID
+			}
+			records = append(records, importedRecord{id: id, data: data, mockID: entry.Name()})
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.adminMocks == nil {
+		s.adminMocks = make(map[string]*MockResponse)
+	}
+	imported := make([]*MockResponse, 0, len(records))
+	for _, record := range records {
+		id := record.id
+		for id == "" || (record.id == "" && s.adminMocks[id] != nil) {
+			id = adminIDPrefix + generateRandomHex(6)
+		}
+		mockResponse, err := s.putAdminMock(id, record.data, record.mockID)
+		if err != nil {
+			return imported, err
+		}
+		imported = append(imported, mockResponse)
+	}
+	return imported, nil
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// adminIDPrefix marks the request IDs of mocks registered through the admin
+// API; persisted files are named after the ID, so they are recognized again
+// after a restart.
+const adminIDPrefix = "admin-"
+
+// ErrAdminMockNotFound is returned for an ID that names no admin mock.
+var ErrAdminMockNotFound = errors.New("admin mock not found")
+
+// SetPersistAdmin writes mocks registered through the admin API to
+// BaseDir/<mock-id>/<id>.json, so they are loaded again on the next start,
+// and deletes the file when the mock is deleted. Persisted admin mocks already
+// loaded from BaseDir can be updated and deleted again.
+func (s *MockStorage) SetPersistAdmin(enabled bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.persistAdmin = enabled
+	if !enabled {
+		return
+	}
+	if s.adminMocks == nil {
+		s.adminMocks = make(map[string]*MockResponse)
+	}
+	for _, responses := range s.Responses {
+		for _, mockResponse := range responses {
+			if strings.HasPrefix(mockResponse.RequestID, adminIDPrefix) {
+				s.loadAdminMock(mockResponse)
+			}
+		}
+	}
+}
+
+// loadAdminMock registers a persisted admin mock read from BaseDir and keeps
+// its stored record, so Reset can bring it back after an update or delete.
+// Callers hold the write lock.
+func (s *MockStorage) loadAdminMock(mockResponse *MockResponse) {
+	if s.loadedAdminMocks == nil {
+		s.loadedAdminMocks = make(map[string]*MockResponse)
+	}
+	if mockResponse.adminRecord == nil {
+		mockResponse.adminRecord, _ = os.ReadFile(mockResponse.SourceFile)
+	}
+	s.adminMocks[mockResponse.RequestID] = mockResponse
+	s.loadedAdminMocks[mockResponse.RequestID] = mockResponse
+}
+
+// adminMock returns the admin mock with id, initializing the registry.
+// Callers hold the write lock.
+func (s *MockStorage) adminMock(id string) *MockResponse {
+	if s.adminMocks == nil {
+		s.adminMocks = make(map[string]*MockResponse)
+	}
+	return s.adminMocks[id]
+}
+
+// AddAdminMock registers a recording in the native JSON format and returns it
+// with its newly assigned ID (its RequestID). mockID applies unless the
+// recorded request carries an x-mock-id header.
+func (s *MockStorage) AddAdminMock(data []byte, mockID string) (*MockResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	id := adminIDPrefix + generateRandomHex(6)
+	for s.adminMock(id) != nil {
+		id = adminIDPrefix + generateRandomHex(6)
+	}
+	return s.putAdminMock(id, data, mockID)
+}
+
+// UpdateAdminMock replaces the admin mock with id, keeping the ID.
+func (s *MockStorage) UpdateAdminMock(id string, data []byte, mockID string) (*MockResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.adminMock(id) == nil {
+		return nil, ErrAdminMockNotFound
+	}
+	return s.putAdminMock(id, data, mockID)
+}
+
+// DeleteAdminMock stops serving the admin mock with id.
+func (s *MockStorage) DeleteAdminMock(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	existing := s.adminMock(id)
+	if existing == nil {
+		return ErrAdminMockNotFound
+	}
+	if s.persistAdmin {
+		if err := os.Remove(s.adminMockPath(existing)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	s.unindexResponse(existing)
+	delete(s.adminMocks, id)
+	s.cacheResponses()
+	return nil
+}
+
+// putAdminMock parses, persists and indexes data as the admin mock id,
+// replacing any previous version. Callers hold the write lock.
+func (s *MockStorage) putAdminMock(id string, data []byte, mockID string) (*MockResponse, error) {
+	mockResponse, err := s.parseAdminMock(id, data, mockID)
+	if err != nil {
+		return nil, err
+	}
+	previous := s.adminMocks[id]
+	if err := s.persistAdminMock(mockResponse, previous); err != nil {
+		return nil, err
+	}
+	s.replaceAdminMock(id, mockResponse, previous)
+	s.cacheResponses()
+	return mockResponse, nil
+}
+
+// parseAdminMock parses and validates data as the admin mock id without
+// registering it.
+func (s *MockStorage) parseAdminMock(id string, data []byte, mockID string) (*MockResponse, error) {
+	var record map[string]interface{}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	requestData, ok := record["request"].(map[string]interface{})
+	if !ok {
+		return nil, errInvalidRecord
+	}
+	requestData["request_id"] = id
+
+	mockResponse, err := mockFromRecord(record, mockID)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(mockResponse.Path, "/") {
+		return nil, fmt.Errorf("request url %q has no absolute path", mockResponse.FullURL)
+	}
+	if s.persistAdmin && !validMockIDDir(mockResponse.MockID) {
+		return nil, fmt.Errorf("mock ID %q cannot be persisted", mockResponse.MockID)
+	}
+
+	normalized, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	mockResponse.adminRecord = normalized
+	return mockResponse, nil
+}
+
+// persistAdminMock writes mockResponse to the mock dir under -persist-admin,
+// removing the file of previous when the update moved it to another mock ID.
+func (s *MockStorage) persistAdminMock(mockResponse, previous *MockResponse) error {
+	if !s.persistAdmin {
+		return nil
+	}
+	path := s.adminMockPath(mockResponse)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, mockResponse.adminRecord, 0644); err != nil {
+		return err
+	}
+	// The update may have moved the mock to another mock ID directory
+	if previous != nil && previous.MockID != mockResponse.MockID {
+		os.Remove(s.adminMockPath(previous))
+	}
+	return nil
+}
+
+// replaceAdminMock swaps previous, if any, for mockResponse in the registry
+// and the indexes; a nil mockResponse deletes the admin mock id. Callers
+// hold the write lock and refresh the caches.
+func (s *MockStorage) replaceAdminMock(id string, mockResponse, previous *MockResponse) {
+	if previous != nil {
+		s.unindexResponse(previous)
+	}
+	if mockResponse == nil {
+		delete(s.adminMocks, id)
+		return
+	}
+	s.adminMocks[id] = mockResponse
+	s.indexAdminResponse(mockResponse)
+}
+
+// validMockIDDir reports whether a mock ID can name a directory of the
+// -mock-dir layout.
+func validMockIDDir(mockID string) bool {
+	return mockID != "" && mockID != "." && mockID != ".." && !strings.ContainsAny(mockID, `/\`)
+}
+
+// adminMockPath is where a persisted admin mock is written.
+func (s *MockStorage) adminMockPath(mockResponse *MockResponse) string {
+	return filepath.Join(s.BaseDir, mockResponse.MockID, mockResponse.RequestID+".json")
+}
+
+// indexAdminResponse indexes an admin mock ahead of recordings with the same
+// key, so a stub registered by a test takes precedence over the files.
+func (s *MockStorage) indexAdminResponse(mockResponse *MockResponse) {
+	mockResponse.pathTemplate, _ = parsePathTemplate(mockResponse.Path)
+
+	key := makeIndexKey(mockResponse.Path, mockResponse.MockID, mockResponse.ContentType)
+	s.Responses[key] = append([]*MockResponse{mockResponse}, s.Responses[key]...)
+
+	pathMockIDKey := makePathMockIDKey(mockResponse.Path, mockResponse.MockID)
+	s.ResponsesByPathMockID[pathMockIDKey] = append([]*MockResponse{mockResponse}, s.ResponsesByPathMockID[pathMockIDKey]...)
+}
+
+// unindexResponse removes a response from the lookup indexes.
+func (s *MockStorage) unindexResponse(mockResponse *MockResponse) {
+	key := makeIndexKey(mockResponse.Path, mockResponse.MockID, mockResponse.ContentType)
+	s.Responses[key] = removeResponse(s.Responses[key], mockResponse)
+	if len(s.Responses[key]) == 0 {
+		delete(s.Responses, key)
+	}
+
+	pathMockIDKey := makePathMockIDKey(mockResponse.Path, mockResponse.MockID)
+	s.ResponsesByPathMockID[pathMockIDKey] = removeResponse(s.ResponsesByPathMockID[pathMockIDKey], mockResponse)
+	if len(s.ResponsesByPathMockID[pathMockIDKey]) == 0 {
+		delete(s.ResponsesByPathMockID, pathMockIDKey)
+	}
+}
+
+// removeResponse returns responses without target.
+func removeResponse(responses []*MockResponse, target *MockResponse) []*MockResponse {
+	kept := make([]*MockResponse, 0, len(responses))
+	for _, mockResponse := range responses {
+		if mockResponse != target {
+			kept = append(kept, mockResponse)
+		}
+	}
+	return kept
+}
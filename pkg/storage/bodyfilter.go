@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unsafe"
+
+	jsonfilter "github.com/andrey-viktorov/jsonfilter-go"
+	"github.com/andrey-viktorov/jsonfilter-go/operator/logic"
+	"github.com/andrey-viktorov/jsonfilter-go/serde"
+	"github.com/tidwall/gjson"
+)
+
+// maxBodyFilterComplexity mirrors the default complexity guard of jsonfilter-go.
+const maxBodyFilterComplexity = 42
+
+// Negative matchers added on top of the jsonfilter-go operators.
+const (
+	filterNot      = "not"
+	filterNotEqual = "ne"
+	filterNotRegex = "nrx"
+	filterAbsent   = "absent"
+	filterNull     = "null"
+)
+
+// parseBodyFilter compiles a filter.body tree. jsonfilter-go only provides eq,
+// rx, and and or, while routing rules are often exclusions, so this adds:
+//
+//	not:    {eq: {...}}                 any operator, inverted
+//	ne:     {field: tier, value: gold}  not equal; a missing field matches
+//	nrx:    {field: id, value: ^tmp-}   does not match the regex; a missing field matches
+//	absent: {field: coupon}             the field does not exist
+//	null:   {field: coupon}             the field exists and is null
+//
+// and/or are rebuilt here so the new operators can appear at any depth; eq
+// and rx leaves are handed to the jsonfilter-go parser unchanged.
+func parseBodyFilter(node map[string]interface{}, parser serde.Parser) (jsonfilter.Operator, error) {
+	complexity := 0
+	return parseBodyFilterNode(node, parser, &complexity)
+}
+
+func parseBodyFilterNode(node map[string]interface{}, parser serde.Parser, complexity *int) (jsonfilter.Operator, error) {
+	if len(node) != 1 {
+		return nil, fmt.Errorf("operator definition must contain exactly one entry, got %d", len(node))
+	}
+	*complexity++
+	if *complexity > maxBodyFilterComplexity {
+		return nil, fmt.Errorf("filter complexity exceeds limit %d", maxBodyFilterComplexity)
+	}
+
+	for rawName, value := range node {
+		name := strings.ToLower(rawName)
+		switch name {
+		case string(logic.And), string(logic.Or):
+			items, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("logic operator %s expects an array of child operators", name)
+			}
+			children := make([]jsonfilter.Operator, 0, len(items))
+			for idx, item := range items {
+				child, ok := item.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("logic operator %s child %d must be an object", name, idx)
+				}
+				op, err := parseBodyFilterNode(child, parser, complexity)
+				if err != nil {
+					return nil, err
+				}
+				children = append(children, op)
+			}
+			return logic.NewOperator(logic.Type(name), children)
+
+		case filterNot:
+			child, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, errors.New("operator not expects a single child operator")
+			}
+			op, err := parseBodyFilterNode(child, parser, complexity)
+			if err != nil {
+				return nil, err
+			}
+			return newNotOperator(filterNot, op), nil
+
+		case filterNotEqual, filterNotRegex:
+			// eq and rx do not match a missing field, so their inverse does
+			positive := "eq"
+			if name == filterNotRegex {
+				positive = "rx"
+			}
+			op, err := parser.FromMap(map[string]interface{}{positive: value})
+			if err != nil {
+				return nil, fmt.Errorf("operator %s: %w", name, err)
+			}
+			return newNotOperator(name, op), nil
+
+		case filterAbsent, filterNull:
+			cfg, _ := value.(map[string]interface{})
+			field, _ := cfg["field"].(string)
+			if field == "" {
+				return nil, fmt.Errorf("operator %s requires field attribute", name)
+			}
+			return &presenceOperator{name: name, field: field, mismatchMsg: "json path " + field + " is not " + name}, nil
+
+		default:
+			op, err := parser.FromMap(node)
+			if err != nil {
+				return nil, err
+			}
+			return op, nil
+		}
+	}
+	return nil, errors.New("could not parse operator")
+}
+
+// notOperator inverts the result of its child.
+type notOperator struct {
+	name        string
+	child       jsonfilter.Operator
+	mismatchMsg string
+}
+
+func newNotOperator(name string, child jsonfilter.Operator) *notOperator {
+	return &notOperator{name: name, child: child, mismatchMsg: "inverted operator " + child.Name() + " matched"}
+}
+
+func (o *notOperator) Name() string { return o.name }
+
+func (o *notOperator) Evaluate(json []byte) jsonfilter.EvaluationResult {
+	if o.child.Evaluate(json).Match {
+		return jsonfilter.ErrorResult(o.name, o.mismatchMsg)
+	}
+	return jsonfilter.ValidResult(o.name)
+}
+
+func (o *notOperator) Validate() jsonfilter.ValidationResult {
+	if child := o.child.Validate(); !child.Valid {
+		return jsonfilter.AggregateValidationResult(o.name, false, []jsonfilter.ValidationResult{child}, "child operator validation failed")
+	}
+	return jsonfilter.ValidValidationResult(o.name)
+}
+
+// presenceOperator implements absent and null, which test a field's existence
+// rather than its value.
+type presenceOperator struct {
+	name        string
+	field       string
+	mismatchMsg string
+}
+
+func (o *presenceOperator) Name() string { return o.name }
+
+func (o *presenceOperator) Evaluate(json []byte) jsonfilter.EvaluationResult {
+	var result gjson.Result
+	if len(json) > 0 {
+		// The body outlives the lookup, so it can be read without copying
+		result = gjson.Get(unsafe.String(unsafe.SliceData(json), len(json)), o.field)
+	}
+
+	var match bool
+	if o.name == filterAbsent {
+		match = !result.Exists()
+	} else {
+		match = result.Exists() && result.Type == gjson.Null
+	}
+	if !match {
+		return jsonfilter.ErrorResult(o.name, o.mismatchMsg)
+	}
+	return jsonfilter.ValidResult(o.name)
+}
+
+func (o *presenceOperator) Validate() jsonfilter.ValidationResult {
+	if o.field == "" {
+		return jsonfilter.ErrorValidationResult(o.name, "json path must not be empty")
+	}
+	return jsonfilter.ValidValidationResult(o.name)
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CanonicalizeJSON rewrites a JSON document into a canonical form: object keys
+// sorted, insignificant whitespace removed and numbers normalized (1.0, 1e0 and
+// 1 all become 1). It returns false when data is not valid JSON.
+func CanonicalizeJSON(data []byte) ([]byte, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+	if decoder.More() {
+		return nil, false
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(normalizeJSONNumbers(value)); err != nil {
+		return nil, false
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), true
+}
+
+// normalizeJSONNumbers walks a decoded document and rewrites json.Number values.
+// encoding/json already sorts map keys when encoding.
+func normalizeJSONNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeJSONNumbers(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeJSONNumbers(item)
+		}
+		return v
+	case json.Number:
+		return json.Number(normalizeJSONNumber(string(v)))
+	}
+	return value
+}
+
+// normalizeJSONNumber keeps integer literals exactly (no float rounding for big
+// IDs) and renders other values in the shortest float form, using integer
+// notation when the value is integral and exactly representable.
+func normalizeJSONNumber(number string) string {
+	if !strings.ContainsAny(number, ".eE") {
+		if number == "-0" {
+			return "0"
+		}
+		return number
+	}
+
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return number
+	}
+	if f == 0 {
+		return "0"
+	}
+	if f == math.Trunc(f) && math.Abs(f) <= 1<<53 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// ChaosConfig is the global chaos mode: matched responses are replaced by
+// errors or delayed at random, for game-day style testing.
+type ChaosConfig struct {
+	ErrorRate    float64       // Share of matched requests answered with a chaos error, 0-1
+	ExtraLatency time.Duration // Random latency of up to this much added to matched requests
+	Timeout      time.Duration // How long a chaos timeout holds the request before dropping the connection
+}
+
+// Chaos errors, picked with equal odds. The fault names are reported to the metrics.
+const (
+	ChaosInternalError = "chaos_500"
+	ChaosUnavailable   = "chaos_503"
+	ChaosTimeout       = "chaos_timeout"
+)
+
+var chaosErrors = []string{ChaosInternalError, ChaosUnavailable, ChaosTimeout}
+
+// ChaosAction is what chaos mode does to one matched request.
+type ChaosAction struct {
+	Latency time.Duration // Extra latency before answering
+	Error   string        // One of the Chaos* errors replacing the response, or ""
+}
+
+// scenarioChaosDefinition is one entry of the chaos: list of a scenario
+// config, overriding the global chaos settings for the paths it matches.
+type scenarioChaosDefinition struct {
+	Path         string   `yaml:"path"`          // Exact path or template such as /payments/**
+	ErrorRate    *float64 `yaml:"error_rate"`    // Replaces -chaos-error-rate
+	ExtraLatency *float64 `yaml:"extra_latency"` // Replaces -chaos-extra-latency, in seconds
+}
+
+// chaosRule is the compiled form of scenarioChaosDefinition.
+type chaosRule struct {
+	path         string
+	pathTemplate *pathTemplate
+	errorRate    *float64
+	extraLatency *time.Duration
+}
+
+func (r *chaosRule) matches(path string) bool {
+	if r.pathTemplate != nil {
+		_, ok := r.pathTemplate.match(path)
+		return ok
+	}
+	return r.path == path
+}
+
+// buildChaosRules validates the chaos: overrides of a scenario config.
+func buildChaosRules(defs []scenarioChaosDefinition) ([]chaosRule, error) {
+	rules := make([]chaosRule, 0, len(defs))
+	for i, def := range defs {
+		path := strings.TrimSpace(def.Path)
+		if path == "" {
+			return nil, fmt.Errorf("chaos #%d is missing path", i+1)
+		}
+		tmpl, err := parsePathTemplate(path)
+		if err != nil {
+			return nil, fmt.Errorf("chaos %s: %w", path, err)
+		}
+		if def.ErrorRate == nil && def.ExtraLatency == nil {
+			return nil, fmt.Errorf("chaos %s: set error_rate and/or extra_latency", path)
+		}
+		rule := chaosRule{path: path, pathTemplate: tmpl, errorRate: def.ErrorRate}
+		if def.ErrorRate != nil && (*def.ErrorRate < 0 || *def.ErrorRate > 1) {
+			return nil, fmt.Errorf("chaos %s: error_rate must be between 0 and 1", path)
+		}
+		if def.ExtraLatency != nil {
+			if *def.ExtraLatency < 0 {
+				return nil, fmt.Errorf("chaos %s: extra_latency must not be negative", path)
+			}
+			latency := time.Duration(*def.ExtraLatency * float64(time.Second))
+			rule.extraLatency = &latency
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// SetChaos enables the global chaos mode; the zero ChaosConfig disables it.
+// Per-path overrides come from the chaos: list of the scenario config.
+func (s *MockStorage) SetChaos(config ChaosConfig) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.chaos = config
+}
+
+// Chaos decides what chaos mode does to a matched request for path: the
+// first chaos: override matching the path replaces the global settings it
+// sets. The zero ChaosAction leaves the response alone.
+func (s *MockStorage) Chaos(pathBytes []byte) ChaosAction {
+	s.mutex.RLock()
+	config := s.chaos
+	rules := s.chaosRules
+	s.mutex.RUnlock()
+
+	errorRate, extraLatency := config.ErrorRate, config.ExtraLatency
+	if len(rules) > 0 {
+		path := string(pathBytes)
+		for i := range rules {
+			if !rules[i].matches(path) {
+				continue
+			}
+			if rules[i].errorRate != nil {
+				errorRate = *rules[i].errorRate
+			}
+			if rules[i].extraLatency != nil {
+				extraLatency = *rules[i].extraLatency
+			}
+			break
+		}
+	}
+
+	var action ChaosAction
+	if extraLatency > 0 {
+		action.Latency = time.Duration(rand.Int63n(int64(extraLatency)))
+	}
+	if errorRate > 0 && rand.Float64() < errorRate {
+		action.Error = chaosErrors[rand.Intn(len(chaosErrors))]
+	}
+	return action
+}
+
+// ChaosTimeout is how long a chaos timeout holds a request.
+func (s *MockStorage) ChaosTimeout() time.Duration {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.chaos.Timeout
+}
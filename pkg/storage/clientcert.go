@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strings"
+)
+
+// scenarioClientCertDefinition matches the verified mTLS client certificate.
+type scenarioClientCertDefinition struct {
+	Subject string `yaml:"subject"` // Common name or full subject DN ("CN=billing,O=Acme")
+	SAN     string `yaml:"san"`     // Any DNS, URI, email or IP subject alternative name
+}
+
+// clientCertMatcher is the compiled form of scenarioClientCertDefinition.
+type clientCertMatcher struct {
+	subject string
+	san     string
+}
+
+// newClientCertMatcher validates a client_cert definition.
+func newClientCertMatcher(def *scenarioClientCertDefinition) (*clientCertMatcher, error) {
+	m := &clientCertMatcher{
+		subject: strings.TrimSpace(def.Subject),
+		san:     strings.TrimSpace(def.SAN),
+	}
+	if m.subject == "" && m.san == "" {
+		return nil, fmt.Errorf("client_cert requires subject or san")
+	}
+	return m, nil
+}
+
+// matches reports whether cert satisfies every configured field.
+// Requests without a client certificate never match.
+func (m *clientCertMatcher) matches(cert *x509.Certificate) bool {
+	if cert == nil {
+		return false
+	}
+
+	if m.subject != "" && m.subject != cert.Subject.CommonName && !strings.EqualFold(m.subject, cert.Subject.String()) {
+		return false
+	}
+
+	if m.san != "" && !certHasSAN(cert, m.san) {
+		return false
+	}
+
+	return true
+}
+
+// certHasSAN checks all subject alternative name kinds for value.
+func certHasSAN(cert *x509.Certificate, value string) bool {
+	for _, name := range cert.DNSNames {
+		if strings.EqualFold(name, value) {
+			return true
+		}
+	}
+	for _, email := range cert.EmailAddresses {
+		if strings.EqualFold(email, value) {
+			return true
+		}
+	}
+	for _, uri := range cert.URIs {
+		if uri.String() == value {
+			return true
+		}
+	}
+	for _, ip := range cert.IPAddresses {
+		if ip.String() == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// clientIPList is a scenario client_ip value: a single address or CIDR, or a list.
+type clientIPList []string
+
+// UnmarshalYAML accepts both "client_ip: 10.0.0.0/8" and a YAML sequence.
+func (l *clientIPList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = clientIPList{value.Value}
+		return nil
+	}
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// clientIPMatcher is the compiled form of a client_ip list; single addresses
+// are kept as /32 (or /128) networks.
+type clientIPMatcher struct {
+	networks []*net.IPNet
+}
+
+// newClientIPMatcher parses a client_ip list; an empty list matches any client.
+func newClientIPMatcher(list clientIPList) (*clientIPMatcher, error) {
+	if len(list) == 0 {
+		return nil, nil
+	}
+	m := &clientIPMatcher{}
+	for _, entry := range list {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q", entry)
+			}
+			m.networks = append(m.networks, network)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", entry)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		m.networks = append(m.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return m, nil
+}
+
+// matches reports whether ip is in one of the networks. Requests without a
+// known client address never match.
+func (m *clientIPMatcher) matches(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range m.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// scenarioGeoDefinition adds synthetic geolocation headers to a response, as
+// a CDN or geo-IP proxy in front of the backend would.
+type scenarioGeoDefinition struct {
+	Country string `yaml:"country"` // X-Geo-Country, e.g. DE
+	Region  string `yaml:"region"`  // X-Geo-Region, e.g. BE
+	City    string `yaml:"city"`    // X-Geo-City, e.g. Berlin
+}
+
+// headers returns the geo headers to merge into the response.
+func (def *scenarioGeoDefinition) headers() (map[string]string, error) {
+	headers := make(map[string]string, 3)
+	for name, value := range map[string]string{
+		"X-Geo-Country": def.Country,
+		"X-Geo-Region":  def.Region,
+		"X-Geo-City":    def.City,
+	} {
+		if value = strings.TrimSpace(value); value != "" {
+			headers[name] = value
+		}
+	}
+	if len(headers) == 0 {
+		return nil, fmt.Errorf("geo requires country, region or city")
+	}
+	return headers, nil
+}
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"crypto/x509"
+	"net"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Dimensions of a request a ClosestMatch can fail on, besides the scenario
+// matchers (method, client_cert, client_ip, consumes, produces, filter.body, filter.form, after_requests, max_serves).
+const (
+	mismatchPath        = "path"
+	mismatchMockID      = "mock_id"
+	mismatchContentType = "content_type"
+)
+
+// MissQuery describes a request no mock answered.
+type MissQuery struct {
+	Path        []byte
+	Method      []byte
+	MockID      []byte // x-mock-id lookup key, after the default and -mock-id-prefix
+	ContentType []byte // Content type the x-mock-id lookup wanted; nil accepts any
+	MockIDUsed  bool   // The x-mock-id lookup ran, e.g. after a delay-only scenario
+
+	// Request details compared by scenario matchers
+	RequestContentType []byte
+	Accept             []byte
+	Body               []byte
+	ClientCert         *x509.Certificate
+	ClientIP           net.IP
+}
+
+// ClosestMatch is a mock that nearly answered a request, with the dimensions
+// that did not match.
+type ClosestMatch struct {
+	Scenario    string   `json:"scenario,omitempty"`
+	MockID      string   `json:"mock_id"`
+	RequestID   string   `json:"request_id,omitempty"`
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	ContentType string   `json:"content_type,omitempty"`
+	File        string   `json:"file,omitempty"`
+	Failed      []string `json:"failed"` // path, method, mock_id, content_type or, for scenarios, the first failed matcher
+
+	distance int // Path segments that differ, for ranking
+	order    int // Declaration or index order, for stable ranking
+}
+
+// ClosestMatches returns up to limit mocks that came closest to answering a
+// request: those failing on the fewest dimensions first, then those whose
+// path differs in the fewest segments. Scenarios are compared when loaded,
+// recordings when the x-mock-id lookup ran.
+func (s *MockStorage) ClosestMatches(q MissQuery, limit int) []ClosestMatch {
+	matches := []ClosestMatch{}
+	if s.scenariosEnabled {
+		matches = append(matches, s.closestScenarios(q)...)
+	}
+	if !s.scenariosEnabled || q.MockIDUsed {
+		matches = append(matches, s.closestRecordings(q)...)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if len(matches[i].Failed) != len(matches[j].Failed) {
+			return len(matches[i].Failed) < len(matches[j].Failed)
+		}
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].order < matches[j].order
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// closestScenarios compares the request with every answering scenario.
+func (s *MockStorage) closestScenarios(q MissQuery) []ClosestMatch {
+	s.mutex.RLock()
+	scenarios := s.scenarioOrder
+	s.mutex.RUnlock()
+
+	matches := make([]ClosestMatch, 0, len(scenarios))
+	for i, scenario := range scenarios {
+		if scenario.response == nil {
+			continue // Delay-only scenarios never answer
+		}
+		match := ClosestMatch{
+			Scenario:    scenario.name,
+			MockID:      scenario.name,
+			RequestID:   scenario.response.RequestID,
+			Method:      scenario.method,
+			Path:        scenario.path,
+			ContentType: scenario.response.ContentType,
+			File:        scenario.response.SourceFile,
+			Failed:      []string{},
+			distance:    templatedPathDistance(scenario.pathTemplate, scenario.path, string(q.Path)),
+			order:       i,
+		}
+		if match.distance > 0 {
+			match.Failed = append(match.Failed, mismatchPath)
+		}
+		if reason := scenario.mismatch(q.Method, q.RequestContentType, q.Accept, q.Body, q.ClientCert, q.ClientIP); reason != "" {
+			match.Failed = append(match.Failed, reason)
+		} else if scenario.dormant(atomic.LoadUint64(&scenario.requests)) {
+			match.Failed = append(match.Failed, mismatchAfterRequests) // The missed request was already counted
+		} else if scenario.spent() {
+			match.Failed = append(match.Failed, mismatchMaxServes)
+		}
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+// closestRecordings compares the x-mock-id lookup of the request with every
+// indexed recording.
+func (s *MockStorage) closestRecordings(q MissQuery) []ClosestMatch {
+	path := string(q.Path)
+	var contentType string
+	if q.ContentType != nil {
+		contentType = string(q.ContentType)
+		if idx := strings.IndexByte(contentType, ';'); idx >= 0 {
+			contentType = contentType[:idx]
+		}
+		contentType = strings.TrimSpace(contentType)
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var matches []ClosestMatch
+	for _, indexed := range s.ResponsesByPathMockID {
+		for _, resp := range indexed {
+			match := ClosestMatch{
+				MockID:      resp.MockID,
+				RequestID:   resp.RequestID,
+				Method:      resp.Method,
+				Path:        resp.Path,
+				ContentType: resp.ContentType,
+				File:        resp.SourceFile,
+				Failed:      []string{},
+				distance:    templatedPathDistance(resp.pathTemplate, resp.Path, path),
+			}
+			if match.distance > 0 {
+				match.Failed = append(match.Failed, mismatchPath)
+			}
+			if len(q.Method) > 0 && !equalFoldBytes(resp.MethodBytes, q.Method) && !answersHead(resp, q.Method) {
+				match.Failed = append(match.Failed, mismatchMethod)
+			}
+			if resp.MockID != string(q.MockID) {
+				match.Failed = append(match.Failed, mismatchMockID)
+			}
+			if q.ContentType != nil && resp.ContentType != contentType {
+				match.Failed = append(match.Failed, mismatchContentType)
+			}
+			matches = append(matches, match)
+		}
+	}
+
+	// Map order is random; rank ties by request ID instead
+	sort.Slice(matches, func(i, j int) bool { return matches[i].RequestID < matches[j].RequestID })
+	for i := range matches {
+		matches[i].order = i
+	}
+	return matches
+}
+
+// pathDistance counts the segments that differ between two paths, plus
+// those only one of them has.
+func pathDistance(a, b string) int {
+	if a == b {
+		return 0
+	}
+	segmentsA := strings.Split(strings.Trim(a, "/"), "/")
+	segmentsB := strings.Split(strings.Trim(b, "/"), "/")
+	if len(segmentsA) < len(segmentsB) {
+		segmentsA, segmentsB = segmentsB, segmentsA
+	}
+	distance := len(segmentsA) - len(segmentsB)
+	for i, segment := range segmentsB {
+		if segment != segmentsA[i] {
+			distance++
+		}
+	}
+	if distance == 0 {
+		distance = 1 // Same segments, different slashes
+	}
+	return distance
+}
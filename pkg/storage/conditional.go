@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+// notModifiedHeaders are the headers of a full response repeated in a 304
+// when no 304 was recorded for it (RFC 9110, section 15.4.5).
+var notModifiedHeaders = []string{"cache-control", "content-location", "date", "etag", "expires", "last-modified", "vary"}
+
+// linkRevalidations attaches each recorded 304 Not Modified to the full
+// response it revalidated ("revalidates" in the recording) and returns the
+// responses without those 304s: they are served through the full response
+// when the request's validators match, never on their own. A 304 whose full
+// response is not loaded is kept as is.
+func linkRevalidations(responses []*MockResponse) []*MockResponse {
+	var byRequestID map[string]*MockResponse
+	for _, mockResponse := range responses {
+		if mockResponse.Revalidates != "" {
+			byRequestID = make(map[string]*MockResponse, len(responses))
+			break
+		}
+	}
+	if byRequestID == nil {
+		return responses
+	}
+
+	for _, mockResponse := range responses {
+		if mockResponse.RequestID != "" {
+			byRequestID[mockResponse.MockID+"|"+mockResponse.RequestID] = mockResponse
+		}
+	}
+	kept := responses[:0:0]
+	for _, mockResponse := range responses {
+		if mockResponse.Revalidates != "" && mockResponse.StatusCode == http.StatusNotModified {
+			if full := byRequestID[mockResponse.MockID+"|"+mockResponse.Revalidates]; full != nil {
+				full.NotModified = mockResponse
+				continue
+			}
+		}
+		kept = append(kept, mockResponse)
+	}
+	return kept
+}
+
+// attachRevalidation links a recorded 304 to its already indexed full
+// response, reporting whether it did; the 304 is then not indexed itself.
+// Callers hold the write lock.
+func (s *MockStorage) attachRevalidation(mockResponse *MockResponse) bool {
+	if mockResponse.Revalidates == "" || mockResponse.StatusCode != http.StatusNotModified {
+		return false
+	}
+	for _, full := range s.ResponsesByPathMockID[makePathMockIDKey(mockResponse.Path, mockResponse.MockID)] {
+		if full.RequestID == mockResponse.Revalidates {
+			full.NotModified = mockResponse
+			return true
+		}
+	}
+	return false
+}
+
+// NotModifiedFor reports whether a conditional request with the given
+// If-None-Match and If-Modified-Since values is answered with 304 Not
+// Modified by this response: it must be a 2xx carrying the matching ETag or
+// a Last-Modified no later than If-Modified-Since. If-Modified-Since is
+// ignored when If-None-Match is sent.
+func (m *MockResponse) NotModifiedFor(ifNoneMatch, ifModifiedSince []byte) bool {
+	if m.StatusCode < 200 || m.StatusCode >= 300 || m.IsSSE || m.IsWebSocket || m.IsGRPC {
+		return false
+	}
+
+	if len(ifNoneMatch) > 0 {
+		etag := m.header("etag")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range bytes.Split(ifNoneMatch, []byte(",")) {
+			candidate = bytes.TrimSpace(candidate)
+			// Weak comparison: W/"x" and "x" match
+			if string(candidate) == "*" || strings.TrimPrefix(string(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(ifModifiedSince) > 0 {
+		lastModified, err := http.ParseTime(m.header("last-modified"))
+		if err != nil {
+			return false
+		}
+		since, err := http.ParseTime(string(ifModifiedSince))
+		return err == nil && !lastModified.After(since)
+	}
+	return false
+}
+
+// NotModifiedHeaders returns the headers of the 304 answer for this response:
+// those of the recorded 304, or the validator and caching headers of the full
+// response when no 304 was recorded.
+func (m *MockResponse) NotModifiedHeaders() map[string]string {
+	if m.NotModified != nil {
+		return m.NotModified.Headers
+	}
+	headers := make(map[string]string)
+	for _, name := range notModifiedHeaders {
+		if key, ok := m.HeaderKeysLower[name]; ok {
+			headers[key] = m.Headers[key]
+		}
+	}
+	return headers
+}
+
+// header returns the recorded value of a response header by lowercase name.
+func (m *MockResponse) header(nameLower string) string {
+	return strings.TrimSpace(m.Headers[m.HeaderKeysLower[nameLower]])
+}
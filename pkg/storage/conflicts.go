@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ConflictPolicy decides which recording answers when several files map to
+// the same path, mock ID, content type and method with different responses.
+type ConflictPolicy string
+
+const (
+	ConflictFirst  ConflictPolicy = "first"  // First file by path (the default)
+	ConflictNewest ConflictPolicy = "newest" // Most recently modified file
+	ConflictError  ConflictPolicy = "error"  // Refuse to load conflicting recordings
+)
+
+// ParseConflictPolicy validates a -on-conflict value.
+func ParseConflictPolicy(value string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(value); policy {
+	case ConflictFirst, ConflictNewest, ConflictError:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %q (expected first, newest or error)", value)
+}
+
+// Conflict lists recordings that answer the same request differently: Winner
+// is served, Overridden never are.
+type Conflict struct {
+	Path        string   `json:"path"`
+	MockID      string   `json:"mock_id"`
+	ContentType string   `json:"content_type"`
+	Method      string   `json:"method"`
+	Winner      string   `json:"winner"`
+	Overridden  []string `json:"overridden"`
+}
+
+// SetConflictPolicy applies policy to the loaded recordings and to those
+// loaded later. With ConflictError it fails when recordings already
+// conflict; Reload then fails instead of serving conflicting recordings,
+// while files added by -watch or hybrid mode are only reported.
+func (s *MockStorage) SetConflictPolicy(policy ConflictPolicy) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.conflictPolicy = policy
+	s.cacheResponses()
+	return conflictsError(policy, s.conflicts)
+}
+
+// Conflicts returns the conflicting recordings found when the mocks last changed.
+func (s *MockStorage) Conflicts() []Conflict {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.conflicts
+}
+
+// conflictsError reports conflicts under ConflictError.
+func conflictsError(policy ConflictPolicy, conflicts []Conflict) error {
+	if policy != ConflictError || len(conflicts) == 0 {
+		return nil
+	}
+	descriptions := make([]string, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		descriptions = append(descriptions, fmt.Sprintf("%s %s (%s): %s vs %s", conflict.Method, conflict.Path,
+			conflict.MockID, conflict.Winner, strings.Join(conflict.Overridden, ", ")))
+	}
+	return fmt.Errorf("%d conflicting recording(s): %s", len(conflicts), strings.Join(descriptions, "; "))
+}
+
+// resolveConflicts orders the recordings of every index key so the one the
+// conflict policy picks is found first, and returns the conflicts. Only
+// recordings loaded from files are ordered: admin mocks (pinned) and mocks
+// added in code keep their place.
+func resolveConflicts(index map[IndexKey][]*MockResponse, policy ConflictPolicy, pinned map[string]*MockResponse) []Conflict {
+	conflicts := []Conflict{}
+	modTimes := make(map[string]time.Time)
+	modTime := func(file string) time.Time {
+		if t, ok := modTimes[file]; ok {
+			return t
+		}
+		var t time.Time
+		if info, err := os.Stat(file); err == nil {
+			t = info.ModTime()
+		}
+		modTimes[file] = t
+		return t
+	}
+
+	for _, candidates := range index {
+		if len(candidates) < 2 {
+			continue
+		}
+
+		var slots []int
+		var files []*MockResponse
+		for i, mockResponse := range candidates {
+			if mockResponse.SourceFile != "" && pinned[mockResponse.RequestID] != mockResponse {
+				slots = append(slots, i)
+				files = append(files, mockResponse)
+			}
+		}
+		if len(files) < 2 {
+			continue
+		}
+
+		sort.SliceStable(files, func(i, j int) bool {
+			if policy == ConflictNewest {
+				if ti, tj := modTime(files[i].SourceFile), modTime(files[j].SourceFile); !ti.Equal(tj) {
+					return ti.After(tj)
+				}
+			}
+			return files[i].SourceFile < files[j].SourceFile
+		})
+		for i, slot := range slots {
+			candidates[slot] = files[i]
+		}
+
+		conflicts = append(conflicts, findConflicts(files)...)
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Path != conflicts[j].Path {
+			return conflicts[i].Path < conflicts[j].Path
+		}
+		if conflicts[i].MockID != conflicts[j].MockID {
+			return conflicts[i].MockID < conflicts[j].MockID
+		}
+		if conflicts[i].ContentType != conflicts[j].ContentType {
+			return conflicts[i].ContentType < conflicts[j].ContentType
+		}
+		return conflicts[i].Method < conflicts[j].Method
+	})
+	return conflicts
+}
+
+// findConflicts groups ordered recordings of one index key by method and
+// reports those that differ from the first of their method.
+func findConflicts(files []*MockResponse) []Conflict {
+	var conflicts []Conflict
+	byMethod := make(map[string]int) // Method -> index in conflicts, or -1 until a conflict is found
+	winners := make(map[string]*MockResponse)
+	for _, mockResponse := range files {
+		method := strings.ToUpper(mockResponse.Method)
+		winner, ok := winners[method]
+		if !ok {
+			winners[method] = mockResponse
+			byMethod[method] = -1
+			continue
+		}
+		if sameResponse(winner, mockResponse) {
+			continue
+		}
+		if byMethod[method] < 0 {
+			byMethod[method] = len(conflicts)
+			conflicts = append(conflicts, Conflict{
+				Path:        winner.Path,
+				MockID:      winner.MockID,
+				ContentType: winner.ContentType,
+				Method:      method,
+				Winner:      winner.SourceFile,
+			})
+		}
+		conflict := &conflicts[byMethod[method]]
+		conflict.Overridden = append(conflict.Overridden, mockResponse.SourceFile)
+	}
+	return conflicts
+}
+
+// sameResponse reports whether two recordings answer identically, so loading
+// both is harmless.
+func sameResponse(a, b *MockResponse) bool {
+	return a.StatusCode == b.StatusCode && bytes.Equal(a.Body, b.Body) &&
+		len(a.SSEEvents) == len(b.SSEEvents) && sameSSEEvents(a.SSEEvents, b.SSEEvents) &&
+		reflect.DeepEqual(a.WebSocketFrames, b.WebSocketFrames) &&
+		reflect.DeepEqual(a.GRPCMessages, b.GRPCMessages)
+}
+
+// sameSSEEvents compares the data of two recorded event streams.
+func sameSSEEvents(a, b []SSEEvent) bool {
+	for i := range a {
+		if !bytes.Equal(a[i].SerializedData, b[i].SerializedData) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContentLengthMismatch describes a mock whose recorded Content-Length header
+// differs from the size of the body the server actually sends.
+type ContentLengthMismatch struct {
+	MockID    string `json:"mock_id"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	RequestID string `json:"request_id,omitempty"`
+	Recorded  int    `json:"recorded"`
+	Served    int    `json:"served"`
+}
+
+// RecordedContentLength returns the Content-Length header captured with the
+// response, if there is a valid one.
+func (r *MockResponse) RecordedContentLength() (int, bool) {
+	key, ok := r.HeaderKeysLower["content-length"]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(r.Headers[key]))
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// CheckContentLength lists mocks whose recorded Content-Length no longer matches
+// the served body, typically because the recording was edited by hand. The
+// server always sends the real length; the check helps find recordings that
+// strict clients would have rejected against the original backend. Skipped are
+// responses whose size is not fixed at load time (streams, templates, HEAD
+// recordings), bodyless statuses and compressed recordings, whose served size
+// depends on -compression-parity.
+func (s *MockStorage) CheckContentLength() []ContentLengthMismatch {
+	var mismatches []ContentLengthMismatch
+	for _, resp := range s.ListAllMocks() {
+		if resp.IsSSE || resp.IsWebSocket || resp.BodyTemplate != nil || equalFoldBytes(resp.MethodBytes, methodHEAD) {
+			continue
+		}
+		if resp.StatusCode < 200 || resp.StatusCode == 204 || resp.StatusCode == 304 {
+			continue // No body is sent with these statuses
+		}
+		if _, encoded := resp.HeaderKeysLower["content-encoding"]; encoded {
+			continue
+		}
+		recorded, ok := resp.RecordedContentLength()
+		if !ok || recorded == len(resp.Body) {
+			continue
+		}
+		mismatches = append(mismatches, ContentLengthMismatch{
+			MockID:    resp.MockID,
+			Method:    resp.Method,
+			Path:      resp.Path,
+			RequestID: resp.RequestID,
+			Recorded:  recorded,
+			Served:    len(resp.Body),
+		})
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool {
+		a, b := mismatches[i], mismatches[j]
+		if a.MockID != b.MockID {
+			return a.MockID < b.MockID
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Method < b.Method
+	})
+	return mismatches
+}
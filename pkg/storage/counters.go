@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// requestCounters counts served requests. They are updated in the request
+// path with atomic operations only; the maps take a lock just the first time
+// a content type, mock or path is served.
+type requestCounters struct {
+	served        atomic.Uint64
+	missed        atomic.Uint64
+	byContentType sync.Map // content type -> *atomic.Uint64
+	byMock        sync.Map // *MockResponse -> *hitCounter
+	byPath        sync.Map // mock path -> *hitCounter
+}
+
+// hitCounter counts the requests served by one mock or path.
+type hitCounter struct {
+	hits       atomic.Uint64
+	lastServed atomic.Int64 // Unix nanoseconds
+}
+
+// mockHits is the stats entry of a mock that served requests.
+type mockHits struct {
+	MockID     string `json:"mock_id"`
+	RequestID  string `json:"request_id,omitempty"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	StatusCode int    `json:"status_code"`
+	File       string `json:"file,omitempty"`
+	Hits       uint64 `json:"hits"`
+	LastServed string `json:"last_served"`
+}
+
+// pathHits is the stats entry of a path that served requests.
+type pathHits struct {
+	Hits       uint64 `json:"hits"`
+	LastServed string `json:"last_served"`
+}
+
+// CountServed counts a request answered by mockResponse.
+func (s *MockStorage) CountServed(mockResponse *MockResponse) {
+	s.counters.served.Add(1)
+
+	counter, ok := s.counters.byContentType.Load(mockResponse.ContentType)
+	if !ok {
+		counter, _ = s.counters.byContentType.LoadOrStore(mockResponse.ContentType, new(atomic.Uint64))
+	}
+	counter.(*atomic.Uint64).Add(1)
+
+	now := time.Now().UnixNano()
+	loadHitCounter(&s.counters.byMock, mockResponse).hit(now)
+	loadHitCounter(&s.counters.byPath, mockResponse.Path).hit(now)
+}
+
+// loadHitCounter returns the counter for key, adding it on first use.
+func loadHitCounter(counters *sync.Map, key interface{}) *hitCounter {
+	counter, ok := counters.Load(key)
+	if !ok {
+		counter, _ = counters.LoadOrStore(key, &hitCounter{})
+	}
+	return counter.(*hitCounter)
+}
+
+// hit counts one request served at now (Unix nanoseconds).
+func (c *hitCounter) hit(now int64) {
+	c.hits.Add(1)
+	c.lastServed.Store(now)
+}
+
+// lastServedTime formats the time of the latest hit.
+func (c *hitCounter) lastServedTime() string {
+	return time.Unix(0, c.lastServed.Load()).UTC().Format(time.RFC3339Nano)
+}
+
+// CountMiss counts a request no mock answered.
+func (s *MockStorage) CountMiss() {
+	s.counters.missed.Add(1)
+}
+
+// reset zeroes the counters.
+func (c *requestCounters) reset() {
+	c.served.Store(0)
+	c.missed.Store(0)
+	for _, counters := range []*sync.Map{&c.byContentType, &c.byMock, &c.byPath} {
+		counters.Range(func(key, _ interface{}) bool {
+			counters.Delete(key)
+			return true
+		})
+	}
+}
+
+// addTo adds the current counts to a stats map.
+func (c *requestCounters) addTo(stats map[string]interface{}) {
+	byContentType := make(map[string]uint64)
+	c.byContentType.Range(func(key, value interface{}) bool {
+		byContentType[key.(string)] = value.(*atomic.Uint64).Load()
+		return true
+	})
+
+	// Busiest mocks first, so the fixtures a run relies on head the list
+	mocks := []mockHits{}
+	c.byMock.Range(func(key, value interface{}) bool {
+		mockResponse, counter := key.(*MockResponse), value.(*hitCounter)
+		mocks = append(mocks, mockHits{
+			MockID:     mockResponse.MockID,
+			RequestID:  mockResponse.RequestID,
+			Method:     mockResponse.Method,
+			Path:       mockResponse.Path,
+			StatusCode: mockResponse.StatusCode,
+			File:       mockResponse.SourceFile,
+			Hits:       counter.hits.Load(),
+			LastServed: counter.lastServedTime(),
+		})
+		return true
+	})
+	sort.Slice(mocks, func(i, j int) bool {
+		if mocks[i].Hits != mocks[j].Hits {
+			return mocks[i].Hits > mocks[j].Hits
+		}
+		if mocks[i].Path != mocks[j].Path {
+			return mocks[i].Path < mocks[j].Path
+		}
+		return mocks[i].RequestID < mocks[j].RequestID
+	})
+
+	paths := make(map[string]pathHits)
+	c.byPath.Range(func(key, value interface{}) bool {
+		counter := value.(*hitCounter)
+		paths[key.(string)] = pathHits{Hits: counter.hits.Load(), LastServed: counter.lastServedTime()}
+		return true
+	})
+
+	stats["requests_served"] = c.served.Load()
+	stats["requests_missed"] = c.missed.Load()
+	stats["served_by_content_type"] = byContentType
+	stats["mocks_served"] = len(mocks)
+	stats["mock_hits"] = mocks
+	stats["path_hits"] = paths
+}
+
+// liveStats returns the stats computed at load time with the current
+// request counts.
+func (s *MockStorage) liveStats() map[string]interface{} {
+	s.mutex.RLock()
+	stats := make(map[string]interface{}, len(s.statsBase)+6)
+	for key, value := range s.statsBase {
+		stats[key] = value
+	}
+	s.mutex.RUnlock()
+
+	s.counters.addTo(stats)
+	return stats
+}
+
+// GetStatsJSON returns the stats JSON served by /__mock__/stats; handlers
+// stream it with WriteStatsJSON instead.
+func (s *MockStorage) GetStatsJSON() []byte {
+	var buf bytes.Buffer
+	s.WriteStatsJSON(&buf)
+	return buf.Bytes()
+}
@@ -0,0 +1,51 @@
+package storage
+
+// CoverageReport tells which of the loaded mocks served requests since
+// startup (or the last reset).
+type CoverageReport struct {
+	Total  int             `json:"total"`  // Servable responses, as listed by SelfTestTargets
+	Served int             `json:"served"` // Responses that answered at least one request
+	Ratio  float64         `json:"ratio"`  // Served / Total; 1 when nothing is loaded
+	Unused []CoverageEntry `json:"unused"` // Responses never served, in SelfTestTargets order
+}
+
+// CoverageEntry identifies a response in a coverage report.
+type CoverageEntry struct {
+	Scenario   string `json:"scenario,omitempty"`
+	MockID     string `json:"mock_id"`
+	RequestID  string `json:"request_id,omitempty"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	StatusCode int    `json:"status_code"`
+	File       string `json:"file,omitempty"`
+}
+
+// Coverage reports the loaded mocks that were never served. Each step of a
+// scenario sequence, A/B variant and response.dir recording counts on its
+// own, so a flow that stops halfway shows up as unused steps.
+func (s *MockStorage) Coverage() CoverageReport {
+	report := CoverageReport{Unused: []CoverageEntry{}}
+	for _, target := range s.SelfTestTargets() {
+		report.Total++
+		if _, served := s.counters.byMock.Load(target.Response); served {
+			report.Served++
+			continue
+		}
+		resp := target.Response
+		report.Unused = append(report.Unused, CoverageEntry{
+			Scenario:   target.Scenario,
+			MockID:     resp.MockID,
+			RequestID:  resp.RequestID,
+			Method:     resp.Method,
+			Path:       resp.Path,
+			StatusCode: resp.StatusCode,
+			File:       resp.SourceFile,
+		})
+	}
+
+	report.Ratio = 1
+	if report.Total > 0 {
+		report.Ratio = float64(report.Served) / float64(report.Total)
+	}
+	return report
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ContentEncoding returns the lowercased Content-Encoding the upstream applied
+// to the recorded response, or "" when the body was sent as is.
+func (r *MockResponse) ContentEncoding() string {
+	key, ok := r.HeaderKeysLower["content-encoding"]
+	if !ok {
+		return ""
+	}
+	encoding := strings.ToLower(strings.TrimSpace(r.Headers[key]))
+	if encoding == "identity" {
+		return ""
+	}
+	return encoding
+}
+
+// decodeContentEncoding decompresses a body recorded with Content-Encoding
+// gzip, deflate or br.
+func decodeContentEncoding(encoding string, data []byte) ([]byte, error) {
+	switch encoding {
+	case "gzip":
+		return fasthttp.AppendGunzipBytes(nil, data)
+	case "deflate":
+		return fasthttp.AppendInflateBytes(nil, data)
+	case "br":
+		return fasthttp.AppendUnbrotliBytes(nil, data)
+	}
+	return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"crypto/x509"
+	"net"
+	"sync/atomic"
+
+	jsonfilter "github.com/andrey-viktorov/jsonfilter-go"
+)
+
+// ScenarioTrace explains how MatchScenario treats a request, for dry runs.
+type ScenarioTrace struct {
+	Scenarios []ScenarioEvaluation // Every scenario registered for the path, in matching order
+	Scenario  string               // Name of the scenario that answers, if any
+	Response  *MockResponse        // Response that scenario would serve next
+	Timing    *TimingOverride      // Override of the first matching delay-only scenario
+}
+
+// ScenarioEvaluation is the verdict of one scenario for a request.
+type ScenarioEvaluation struct {
+	Name      string                       `json:"name"`
+	Method    string                       `json:"method,omitempty"`
+	Matched   bool                         `json:"matched"`
+	Reason    string                       `json:"reason,omitempty"` // First failed matcher: method, client_cert, client_ip, consumes, produces, filter.body, filter.form, after_requests or max_serves
+	DelayOnly bool                         `json:"delay_only,omitempty"`
+	Skipped   bool                         `json:"skipped,omitempty"` // Not reached because an earlier scenario answered
+	Filter    *jsonfilter.EvaluationResult `json:"filter,omitempty"`  // Body filter evaluation, once the request got that far
+}
+
+// EvaluateScenarios runs the scenario matching of MatchScenario without
+// serving anything: sequences are not advanced. Scenarios after the one that
+// answers are listed as skipped.
+func (s *MockStorage) EvaluateScenarios(pathBytes, methodBytes, contentType, accept, body []byte, clientCert *x509.Certificate, clientIP net.IP) *ScenarioTrace {
+	trace := &ScenarioTrace{}
+	if !s.scenariosEnabled {
+		return trace
+	}
+
+	for _, scenario := range s.scenariosFor(pathBytes) {
+		eval := ScenarioEvaluation{Name: scenario.name, Method: scenario.method, DelayOnly: scenario.timing != nil}
+		if trace.Response != nil {
+			eval.Skipped = true
+			trace.Scenarios = append(trace.Scenarios, eval)
+			continue
+		}
+
+		eval.Reason = scenario.mismatch(methodBytes, contentType, accept, body, clientCert, clientIP)
+		// A dry run is not counted: the threshold is checked for the request being evaluated
+		if eval.Reason == "" && scenario.dormant(atomic.LoadUint64(&scenario.requests)+1) {
+			eval.Reason = mismatchAfterRequests
+		} else if eval.Reason == "" && scenario.spent() {
+			eval.Reason = mismatchMaxServes
+		}
+		eval.Matched = eval.Reason == ""
+		if scenario.filter != nil && (eval.Matched || eval.Reason == mismatchBody || eval.Reason == mismatchForm) {
+			result := scenario.filter.Evaluate(body)
+			eval.Filter = &result
+		}
+		trace.Scenarios = append(trace.Scenarios, eval)
+
+		if !eval.Matched {
+			continue
+		}
+		if scenario.timing != nil {
+			if trace.Timing == nil {
+				trace.Timing = scenario.timing
+			}
+			continue
+		}
+		trace.Scenario = scenario.name
+		trace.Response = scenario.peek()
+	}
+	return trace
+}
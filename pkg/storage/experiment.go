@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Experiment variants.
+const (
+	VariantA = "a"
+	VariantB = "b"
+)
+
+// defaultExperimentCookie carries the assigned variant when a scenario does not name a cookie.
+const defaultExperimentCookie = "mock_variant"
+
+// headerMockVariant tells the client which variant served the request.
+var headerMockVariant = []byte("X-Mock-Variant")
+
+// Experiment splits the traffic of one scenario between two responses. A client
+// keeps its variant across requests: an assignment cookie is honored first,
+// then an optional client id header is hashed, and only new clients are
+// assigned at random (and handed the cookie).
+type Experiment struct {
+	Cookie   string  // Cookie holding the assigned variant
+	Header   string  // Optional request header identifying the client
+	PercentB float64 // Share of clients served variant B, 0-100
+	A        *MockResponse
+	B        *MockResponse
+
+	cookieBytes []byte
+	headerBytes []byte
+}
+
+// scenarioExperimentDefinition is the experiment block of a scenario.
+type scenarioExperimentDefinition struct {
+	PercentB float64                    `yaml:"percent_b"` // Percentage of clients served variant B
+	Cookie   string                     `yaml:"cookie"`    // Sticky assignment cookie (default mock_variant)
+	Header   string                     `yaml:"header"`    // Client id header hashed for stickiness
+	A        scenarioResponseDefinition `yaml:"a"`
+	B        scenarioResponseDefinition `yaml:"b"`
+}
+
+// newExperiment validates an experiment definition against its loaded variants.
+func newExperiment(def *scenarioExperimentDefinition, a, b *MockResponse) (*Experiment, error) {
+	if def.PercentB < 0 || def.PercentB > 100 {
+		return nil, fmt.Errorf("experiment.percent_b must be between 0 and 100, got %v", def.PercentB)
+	}
+
+	cookie := def.Cookie
+	if cookie == "" {
+		cookie = defaultExperimentCookie
+	}
+
+	exp := &Experiment{
+		Cookie:      cookie,
+		Header:      def.Header,
+		PercentB:    def.PercentB,
+		A:           a,
+		B:           b,
+		cookieBytes: []byte(cookie),
+	}
+	if def.Header != "" {
+		exp.headerBytes = []byte(def.Header)
+	}
+	a.Experiment = exp
+	return exp, nil
+}
+
+// Select picks the variant for the request and marks the response with the
+// X-Mock-Variant header, plus the sticky cookie for newly assigned clients.
+func (e *Experiment) Select(ctx *fasthttp.RequestCtx) *MockResponse {
+	variant := string(ctx.Request.Header.CookieBytes(e.cookieBytes))
+	assigned := variant == VariantA || variant == VariantB
+
+	if !assigned {
+		if clientID := e.clientID(ctx); len(clientID) > 0 {
+			variant = e.variantForClient(clientID)
+		} else {
+			variant = VariantA
+			if rand.Float64()*100 < e.PercentB {
+				variant = VariantB
+			}
+		}
+
+		cookie := fasthttp.AcquireCookie()
+		cookie.SetKeyBytes(e.cookieBytes)
+		cookie.SetValue(variant)
+		cookie.SetPath("/")
+		ctx.Response.Header.SetCookie(cookie)
+		fasthttp.ReleaseCookie(cookie)
+	}
+
+	ctx.Response.Header.SetBytesK(headerMockVariant, variant)
+	if variant == VariantB {
+		return e.B
+	}
+	return e.A
+}
+
+func (e *Experiment) clientID(ctx *fasthttp.RequestCtx) []byte {
+	if e.headerBytes == nil {
+		return nil
+	}
+	return ctx.Request.Header.PeekBytes(e.headerBytes)
+}
+
+// variantForClient hashes a client id into a stable bucket out of 10000.
+func (e *Experiment) variantForClient(clientID []byte) string {
+	h := fnv.New32a()
+	h.Write(clientID)
+	if float64(h.Sum32()%10000) < e.PercentB*100 {
+		return VariantB
+	}
+	return VariantA
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fault kinds of a scenario response.
+const (
+	FaultEmptyResponse   = "empty_response"   // Close the connection without answering
+	FaultConnectionReset = "connection_reset" // Reset the connection (TCP RST) without answering
+	FaultMalformedJSON   = "malformed_json"   // Serve the response with a body no JSON parser accepts
+	FaultTruncateBody    = "truncate_body"    // Announce the full body, send part of it and close
+	FaultRandomGarbage   = "random_garbage"   // Send random bytes instead of an HTTP response
+
+	// FaultSSEAbort is reported to the metrics for streams cut by response.abort
+	FaultSSEAbort = "sse_abort"
+)
+
+// ResponseFault breaks a response on purpose to exercise client error handling.
+type ResponseFault struct {
+	Kind         string // One of the Fault* kinds
+	TruncateBody int    // Body bytes sent before the connection is closed, for FaultTruncateBody
+}
+
+// scenarioFaultDefinition is response.fault. A plain scalar names the fault;
+// truncate_body takes its byte count as a mapping.
+type scenarioFaultDefinition struct {
+	Kind         string
+	TruncateBody *int `yaml:"truncate_body"`
+}
+
+// UnmarshalYAML accepts both "fault: connection_reset" and "fault: {truncate_body: N}".
+func (d *scenarioFaultDefinition) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		d.Kind = strings.ToLower(strings.TrimSpace(value.Value))
+		return nil
+	}
+	type plain scenarioFaultDefinition
+	if err := value.Decode((*plain)(d)); err != nil {
+		return err
+	}
+	if d.TruncateBody != nil {
+		d.Kind = FaultTruncateBody
+	}
+	return nil
+}
+
+// buildResponseFault validates a fault definition against the loaded response.
+func buildResponseFault(def *scenarioFaultDefinition, resp *MockResponse) (*ResponseFault, error) {
+	if resp.IsSSE || resp.IsWebSocket || resp.IsGRPC {
+		return nil, fmt.Errorf("fault is only supported for plain HTTP responses (use abort for SSE)")
+	}
+	switch def.Kind {
+	case FaultEmptyResponse, FaultConnectionReset, FaultMalformedJSON, FaultRandomGarbage:
+		return &ResponseFault{Kind: def.Kind}, nil
+	case FaultTruncateBody:
+		if def.TruncateBody == nil {
+			return nil, fmt.Errorf("fault truncate_body needs a byte count, e.g. fault: {truncate_body: 10}")
+		}
+		if *def.TruncateBody < 0 {
+			return nil, fmt.Errorf("fault.truncate_body must not be negative")
+		}
+		return &ResponseFault{Kind: FaultTruncateBody, TruncateBody: *def.TruncateBody}, nil
+	case "":
+		return nil, fmt.Errorf("fault is empty")
+	default:
+		return nil, fmt.Errorf("unknown fault %q (expected empty_response, connection_reset, malformed_json, truncate_body or random_garbage)", def.Kind)
+	}
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/valyala/fasthttp"
+	"gopkg.in/yaml.v3"
+)
+
+// scenarioFormFieldDefinition is one entry of filter.form. A plain scalar is
+// shorthand for equals; a mapping may combine equals, regex and present.
+type scenarioFormFieldDefinition struct {
+	Equals  *string `yaml:"equals"`
+	Regex   string  `yaml:"regex"`
+	Present *bool   `yaml:"present"`
+}
+
+// UnmarshalYAML accepts both "field: value" and "field: {regex: ...}".
+func (d *scenarioFormFieldDefinition) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		equals := value.Value
+		d.Equals = &equals
+		return nil
+	}
+	type plain scenarioFormFieldDefinition
+	return value.Decode((*plain)(d))
+}
+
+// formFieldMatcher is the compiled form of scenarioFormFieldDefinition.
+type formFieldMatcher struct {
+	name    string
+	equals  *string
+	regex   *regexp.Regexp
+	present *bool
+}
+
+// formFilter matches application/x-www-form-urlencoded bodies. Every field must match.
+type formFilter struct {
+	fields []formFieldMatcher
+}
+
+// newFormFilter compiles filter.form. It returns nil when no fields are declared.
+func newFormFilter(defs map[string]scenarioFormFieldDefinition) (*formFilter, error) {
+	if len(defs) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	f := &formFilter{}
+	for _, name := range names {
+		def := defs[name]
+		m := formFieldMatcher{name: name, equals: def.Equals, present: def.Present}
+		if def.Regex != "" {
+			re, err := regexp.Compile(def.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: invalid regex: %w", name, err)
+			}
+			m.regex = re
+		}
+		if m.equals == nil && m.regex == nil && m.present == nil {
+			return nil, fmt.Errorf("field %s: expected a value or equals, regex or present", name)
+		}
+		if m.present != nil && !*m.present && (m.equals != nil || m.regex != nil) {
+			return nil, fmt.Errorf("field %s: present: false cannot be combined with equals or regex", name)
+		}
+		f.fields = append(f.fields, m)
+	}
+	return f, nil
+}
+
+// matches parses body as form data and evaluates every field. A repeated
+// field matches when any of its values does.
+func (f *formFilter) matches(body []byte) bool {
+	args := fasthttp.AcquireArgs()
+	defer fasthttp.ReleaseArgs(args)
+	args.ParseBytes(body)
+
+	for i := range f.fields {
+		if !f.fields[i].matches(args) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *formFieldMatcher) matches(args *fasthttp.Args) bool {
+	present := args.Has(m.name)
+	if m.present != nil && *m.present != present {
+		return false
+	}
+	if m.equals == nil && m.regex == nil {
+		return true
+	}
+
+	for _, value := range args.PeekMulti(m.name) {
+		if m.equals != nil && string(value) != *m.equals {
+			continue
+		}
+		if m.regex != nil && !m.regex.Match(value) {
+			continue
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// GRPCContentType is the index content type of gRPC records.
+const GRPCContentType = "application/grpc"
+
+// maxGRPCMessage matches the default receive limit of gRPC servers.
+const maxGRPCMessage = 4 << 20
+
+// GRPCMessage is one recorded response message of a gRPC call.
+type GRPCMessage struct {
+	Timestamp float64 // Seconds since the call started
+	Data      []byte  // Serialized protobuf message
+}
+
+// parseGRPCMessages decodes the message list of a grpc record. Each entry has
+// base64 "data" with the serialized message and an optional "timestamp".
+func parseGRPCMessages(body interface{}) ([]GRPCMessage, error) {
+	items, _ := body.([]interface{})
+	messages := make([]GRPCMessage, 0, len(items))
+	for _, item := range items {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, errInvalidRecord
+		}
+		data, _ := itemMap["data"].(string)
+		payload, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, fmt.Errorf("grpc message data: %w", err)
+		}
+		timestamp, _ := itemMap["timestamp"].(float64)
+		messages = append(messages, GRPCMessage{Timestamp: timestamp, Data: payload})
+	}
+	return messages, nil
+}
+
+// parseGRPCTrailers reads the recorded trailers. A missing grpc-status means OK.
+func parseGRPCTrailers(value interface{}) map[string]string {
+	trailers := map[string]string{"grpc-status": "0"}
+	items, _ := value.(map[string]interface{})
+	for key, v := range items {
+		if str, ok := v.(string); ok {
+			trailers[toLowerASCIISimple(key)] = str
+		}
+	}
+	return trailers
+}
+
+// ReadGRPCMessage reads one length-prefixed message from a gRPC stream and
+// reports whether the sender compressed it.
+func ReadGRPCMessage(r io.Reader) (bool, []byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return false, nil, err
+	}
+	length := binary.BigEndian.Uint32(prefix[1:])
+	if length > maxGRPCMessage {
+		return false, nil, fmt.Errorf("grpc message of %d bytes exceeds the %d byte limit", length, maxGRPCMessage)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return false, nil, err
+	}
+	return prefix[0] == 1, data, nil
+}
+
+// AppendGRPCMessage appends an uncompressed length-prefixed message.
+func AppendGRPCMessage(dst, data []byte) []byte {
+	dst = append(dst, 0)
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(data)))
+	return append(dst, data...)
+}
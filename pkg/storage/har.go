@@ -0,0 +1,475 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/valyala/fasthttp"
+)
+
+// HAR is an HTTP Archive 1.2 document. Recordings can be written and loaded in
+// this format, so browser devtools exports replay directly. Fields starting
+// with an underscore are custom fields, as the spec allows:
+//   - _requestId keeps the recorder's request ID
+//   - _sseEvents keeps the SSE events with their timestamps
+//   - _webSocketMessages holds WebSocket frames in the Chrome devtools format
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the root object of a HAR document.
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator names the application that wrote a HAR document.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is one request/response exchange.
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"` // Total duration in milliseconds
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+
+	Comment           string                `json:"comment,omitempty"` // Recording annotation (x-mock-note)
+	RequestID         string                `json:"_requestId,omitempty"`
+	SSEEvents         []interface{}         `json:"_sseEvents,omitempty"`
+	WebSocketMessages []HARWebSocketMessage `json:"_webSocketMessages,omitempty"`
+}
+
+// HARRequest is the request of an entry.
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []interface{}  `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARResponse is the response of an entry.
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []interface{}  `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARNameValue is a header or query string parameter.
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is a request body.
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARContent is a response body, always stored decoded (after Content-Encoding).
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"` // "base64" for binary bodies
+}
+
+// HARTimings breaks down the duration of an entry, in milliseconds.
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// HARWebSocketMessage is a WebSocket frame as exported by Chrome devtools:
+// type is "send" (client) or "receive" (server), time is in Unix seconds and
+// data is text for opcode 1 and base64 for every other opcode.
+type HARWebSocketMessage struct {
+	Type   string  `json:"type"`
+	Time   float64 `json:"time"`
+	Opcode int     `json:"opcode"`
+	Data   string  `json:"data"`
+	Fin    *bool   `json:"_fin,omitempty"` // Set to false for non-final fragments
+}
+
+// isHARFile reports whether name is an HTTP Archive.
+func isHARFile(name string) bool {
+	return strings.HasSuffix(name, ".har")
+}
+
+// loadHARFile loads every entry of a HAR file as a response.
+func loadHARFile(filePath, fallbackMockID string) ([]*MockResponse, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var har HAR
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, err
+	}
+
+	responses := make([]*MockResponse, 0, len(har.Log.Entries))
+	for i := range har.Log.Entries {
+		entry := &har.Log.Entries[i]
+		if entry.RequestID == "" {
+			entry.RequestID = fmt.Sprintf("%s#%d", filepath.Base(filePath), i+1)
+		}
+		resp, err := mockFromRecord(entry.nativeRecord(), fallbackMockID)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		responses = append(responses, resp)
+	}
+	return responses, nil
+}
+
+// nativeRecord converts the entry to the record layout parsed by mockFromRecord.
+func (e *HAREntry) nativeRecord() map[string]interface{} {
+	reqHeaders := make(map[string]interface{}, len(e.Request.Headers))
+	for _, header := range e.Request.Headers {
+		if !strings.HasPrefix(header.Name, ":") { // HTTP/2 pseudo-headers
+			reqHeaders[header.Name] = header.Value
+		}
+	}
+
+	// HAR content is already decoded, so the encoding headers no longer apply
+	respHeaders := make(map[string]interface{}, len(e.Response.Headers))
+	hasContentType := false
+	for _, header := range e.Response.Headers {
+		switch strings.ToLower(header.Name) {
+		case "content-encoding", "content-length":
+			continue
+		case "content-type":
+			hasContentType = true
+		}
+		if !strings.HasPrefix(header.Name, ":") {
+			respHeaders[header.Name] = header.Value
+		}
+	}
+	if !hasContentType && e.Response.Content.MimeType != "" {
+		respHeaders["Content-Type"] = e.Response.Content.MimeType
+	}
+
+	record := map[string]interface{}{
+		"request": map[string]interface{}{
+			"request_id": e.RequestID,
+			"method":     e.Request.Method,
+			"url":        e.Request.URL,
+			"headers":    reqHeaders,
+		},
+	}
+	response := map[string]interface{}{
+		"request_id":  e.RequestID,
+		"status_code": float64(e.Response.Status),
+		"headers":     respHeaders,
+		"delay":       e.Time / 1000,
+	}
+	record["response"] = response
+	if e.Comment != "" {
+		record["note"] = e.Comment
+	}
+
+	switch {
+	case len(e.WebSocketMessages) > 0:
+		record["type"] = WebSocketContentType
+		response["body"] = e.webSocketFrames()
+	case len(e.SSEEvents) > 0:
+		response["body"] = e.SSEEvents
+	default:
+		response["body"] = e.Response.Content.body()
+	}
+	return record
+}
+
+// body returns the decoded content: parsed JSON, SSE events or raw text.
+func (c HARContent) body() interface{} {
+	text := c.Text
+	if c.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(text)
+		if err == nil {
+			text = string(decoded)
+		}
+	}
+	if strings.HasPrefix(c.MimeType, "text/event-stream") {
+		return parseSSEText(text)
+	}
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(text), &parsed); err == nil {
+		return parsed
+	}
+	return text
+}
+
+// parseSSEText splits a raw event stream into event records without timing.
+func parseSSEText(text string) []interface{} {
+	events := []interface{}{}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	for _, block := range strings.Split(text, "\n\n") {
+		var lines []string
+		for _, line := range strings.Split(block, "\n") {
+			if data, ok := strings.CutPrefix(line, "data:"); ok {
+				lines = append(lines, strings.TrimPrefix(data, " "))
+			}
+		}
+		if lines == nil {
+			continue
+		}
+		data := strings.Join(lines, "\n")
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(data), &parsed); err == nil {
+			events = append(events, map[string]interface{}{"data": parsed, "timestamp": 0.0})
+		} else {
+			events = append(events, map[string]interface{}{"data": data, "encoding": "text", "timestamp": 0.0})
+		}
+	}
+	return events
+}
+
+// webSocketFrames converts devtools messages to frame records, timed from the
+// end of the handshake (or from the first message when the entry has no start time).
+func (e *HAREntry) webSocketFrames() []interface{} {
+	base := e.WebSocketMessages[0].Time
+	if started, err := time.Parse(time.RFC3339Nano, e.StartedDateTime); err == nil {
+		base = float64(started.UnixNano())/1e9 + e.Time/1000
+	}
+
+	frames := make([]interface{}, 0, len(e.WebSocketMessages))
+	for _, message := range e.WebSocketMessages {
+		frame := WebSocketFrame{
+			FromClient: message.Type == "send",
+			Timestamp:  message.Time - base,
+			Opcode:     byte(message.Opcode),
+			Fin:        message.Fin == nil || *message.Fin,
+			Payload:    []byte(message.Data),
+		}
+		if frame.Timestamp < 0 {
+			frame.Timestamp = 0
+		}
+		if frame.Opcode != WebSocketText {
+			if payload, err := base64.StdEncoding.DecodeString(message.Data); err == nil {
+				frame.Payload = payload
+			}
+		}
+		frames = append(frames, frame.Record())
+	}
+	return frames
+}
+
+// NewHAREntry converts a native recording, as decoded from its JSON file, to a
+// HAR entry. Compressed bodies are decoded, since HAR content never is encoded;
+// SSE events and WebSocket frames keep their timing in the custom fields.
+func NewHAREntry(record map[string]interface{}) (HAREntry, error) {
+	requestData, hasRequest := record["request"].(map[string]interface{})
+	responseData, hasResponse := record["response"].(map[string]interface{})
+	if !hasRequest || !hasResponse {
+		return HAREntry{}, errInvalidRecord
+	}
+
+	entry := HAREntry{}
+	entry.RequestID, _ = requestData["request_id"].(string)
+	entry.Comment, _ = record["note"].(string)
+	delay, _ := responseData["delay"].(float64)
+	entry.Time = delay * 1000
+	entry.Timings.Wait = entry.Time
+
+	// Records without a capture time (hash-named recordings) start at the epoch
+	started := time.Unix(0, 0).UTC()
+	if timestamp, ok := requestData["timestamp"].(string); ok {
+		if parsed, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+			started = parsed
+		}
+	}
+	entry.StartedDateTime = started.Format(time.RFC3339Nano)
+
+	// Request
+	method, _ := requestData["method"].(string)
+	rawURL, _ := requestData["url"].(string)
+	reqHeaders := harHeaders(requestData["headers"])
+	entry.Request = HARRequest{
+		Method:      method,
+		URL:         rawURL,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []interface{}{},
+		Headers:     reqHeaders,
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    0,
+	}
+	if parsed, err := url.Parse(rawURL); err == nil {
+		for name, values := range parsed.Query() {
+			for _, value := range values {
+				entry.Request.QueryString = append(entry.Request.QueryString, HARNameValue{Name: name, Value: value})
+			}
+		}
+	}
+	if text, ok := harRequestBody(requestData["body"]); ok {
+		entry.Request.PostData = &HARPostData{MimeType: harHeader(reqHeaders, "content-type"), Text: text}
+		entry.Request.BodySize = len(text)
+	}
+
+	// Response
+	statusCode := 200
+	if sc, ok := responseData["status_code"].(float64); ok {
+		statusCode = int(sc)
+	}
+	respHeaders := harHeaders(responseData["headers"])
+	entry.Response = HARResponse{
+		Status:      statusCode,
+		StatusText:  fasthttp.StatusMessage(statusCode),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []interface{}{},
+		Headers:     respHeaders,
+		RedirectURL: harHeader(respHeaders, "location"),
+		HeadersSize: -1,
+	}
+
+	mimeType := harHeader(respHeaders, "content-type")
+	body := responseData["body"]
+	var content []byte
+	switch {
+	case record["type"] == WebSocketContentType:
+		frames, err := parseWebSocketFrames(body)
+		if err != nil {
+			return HAREntry{}, err
+		}
+		base := float64(started.UnixNano())/1e9 + delay
+		for _, frame := range frames {
+			entry.WebSocketMessages = append(entry.WebSocketMessages, harWebSocketMessage(frame, base))
+		}
+	case isSSEEventList(mimeType, body):
+		events := body.([]interface{})
+		for _, event := range events {
+			if eventMap, ok := event.(map[string]interface{}); ok {
+				if data, err := serializeSSEData(eventMap); err == nil {
+					content = AppendSSEFrame(content, data)
+				}
+			}
+		}
+		entry.SSEEvents = events
+	default:
+		var err error
+		if content, err = harResponseBody(body, harHeader(respHeaders, "content-encoding")); err != nil {
+			return HAREntry{}, err
+		}
+	}
+
+	entry.Response.Content = HARContent{Size: len(content), MimeType: mimeType}
+	if utf8.Valid(content) {
+		entry.Response.Content.Text = string(content)
+	} else {
+		entry.Response.Content.Text = base64.StdEncoding.EncodeToString(content)
+		entry.Response.Content.Encoding = "base64"
+	}
+	entry.Response.BodySize = len(content)
+	return entry, nil
+}
+
+// isSSEEventList reports whether an event stream was recorded as separate events.
+func isSSEEventList(mimeType string, body interface{}) bool {
+	_, isList := body.([]interface{})
+	return isList && strings.HasPrefix(mimeType, "text/event-stream")
+}
+
+// harHeaders converts recorded headers to a sorted HAR header list.
+func harHeaders(headers interface{}) []HARNameValue {
+	headerMap, _ := headers.(map[string]interface{})
+	list := make([]HARNameValue, 0, len(headerMap))
+	for name, value := range headerMap {
+		if str, ok := value.(string); ok {
+			list = append(list, HARNameValue{Name: name, Value: str})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// harHeader returns the value of a header, matched case-insensitively.
+func harHeader(headers []HARNameValue, name string) string {
+	for _, header := range headers {
+		if strings.EqualFold(header.Name, name) {
+			return header.Value
+		}
+	}
+	return ""
+}
+
+// harRequestBody returns the recorded request body as text, if there is one.
+func harRequestBody(body interface{}) (string, bool) {
+	switch value := body.(type) {
+	case nil:
+		return "", false
+	case string:
+		return value, value != ""
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// harResponseBody returns the bytes of a recorded response body, decompressing
+// bodies recorded with a Content-Encoding.
+func harResponseBody(body interface{}, encoding string) ([]byte, error) {
+	str, isString := body.(string)
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	if isString && encoding != "" && encoding != "identity" {
+		compressed, err := base64.StdEncoding.DecodeString(str)
+		if err != nil {
+			return nil, err
+		}
+		return decodeContentEncoding(encoding, compressed)
+	}
+	if isString || body == nil {
+		return []byte(str), nil
+	}
+	return json.Marshal(body)
+}
+
+// harWebSocketMessage converts a frame to the devtools format; base is the
+// Unix time in seconds the handshake completed.
+func harWebSocketMessage(frame WebSocketFrame, base float64) HARWebSocketMessage {
+	message := HARWebSocketMessage{
+		Type:   "receive",
+		Time:   base + frame.Timestamp,
+		Opcode: int(frame.Opcode),
+	}
+	if frame.FromClient {
+		message.Type = "send"
+	}
+	if frame.Opcode == WebSocketText {
+		message.Data = string(frame.Payload)
+	} else {
+		message.Data = base64.StdEncoding.EncodeToString(frame.Payload)
+	}
+	if !frame.Fin {
+		fin := false
+		message.Fin = &fin
+	}
+	return message
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HeaderSource decides where a protocol header of replayed responses comes
+// from: the recording or the mock server itself.
+type HeaderSource string
+
+const (
+	HeaderLive     HeaderSource = "live"     // What a live server sends: current Date, server name, Content-Length
+	HeaderRecorded HeaderSource = "recorded" // The value captured with the recording
+	HeaderNone     HeaderSource = "none"     // Leave the header out
+	HeaderChunked  HeaderSource = "chunked"  // Body framing only: always Transfer-Encoding: chunked
+)
+
+// HeaderFidelity controls the Date, Server and body framing headers that
+// fasthttp otherwise manages itself, for clients strict about them.
+type HeaderFidelity struct {
+	Date    HeaderSource // live (default), recorded or none
+	Server  HeaderSource // recorded (default, falls back to the server name), live or none
+	Framing HeaderSource // live (Content-Length, default), recorded or chunked
+}
+
+// DefaultHeaderFidelity is how the server behaves without the fidelity flags.
+var DefaultHeaderFidelity = HeaderFidelity{Date: HeaderLive, Server: HeaderRecorded, Framing: HeaderLive}
+
+// ParseHeaderSource validates the value of the flag named name against the
+// sources it accepts.
+func ParseHeaderSource(name, value string, accepted ...HeaderSource) (HeaderSource, error) {
+	names := make([]string, len(accepted))
+	for i, source := range accepted {
+		if HeaderSource(value) == source {
+			return source, nil
+		}
+		names[i] = string(source)
+	}
+	return "", fmt.Errorf("unknown -%s %q (expected %s)", name, value, strings.Join(names, ", "))
+}
+
+// SetHeaderFidelity configures where Date, Server and the body framing of
+// replayed responses come from.
+func (s *MockStorage) SetHeaderFidelity(fidelity HeaderFidelity) {
+	s.HeaderFidelity = fidelity
+}
+
+// RecordedHeader returns a header captured with the response by its
+// lowercase name.
+func (r *MockResponse) RecordedHeader(keyLower string) (string, bool) {
+	key, ok := r.HeaderKeysLower[keyLower]
+	if !ok {
+		return "", false
+	}
+	return r.Headers[key], true
+}
+
+// RecordedChunked reports whether the response was recorded with
+// Transfer-Encoding: chunked.
+func (r *MockResponse) RecordedChunked() bool {
+	encoding, ok := r.RecordedHeader("transfer-encoding")
+	return ok && strings.Contains(strings.ToLower(encoding), "chunked")
+}
@@ -0,0 +1,255 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// indexCacheVersion is bumped whenever the cached layout or the loader output changes.
+const indexCacheVersion = 7
+
+// indexCache is the on-disk form of a loaded mock directory.
+type indexCache struct {
+	Version     int
+	Fingerprint string
+	Entries     []indexCacheEntry
+	Failed      []LoadFailure
+}
+
+// indexCacheEntry holds the pre-serialized parts of a MockResponse needed to serve it.
+type indexCacheEntry struct {
+	RequestID       string
+	Path            string
+	Method          string
+	MockID          string
+	ContentType     string
+	StatusCode      int
+	Headers         map[string]string
+	Body            []byte
+	RequestBody     []byte // Recorded request body as JSON; gob cannot encode the decoded form
+	FullURL         string
+	Note            string
+	Delay           float64
+	IsSSE           bool
+	Events          []indexCacheEvent
+	IsWebSocket     bool
+	WebSocketFrames []WebSocketFrame
+	IsGRPC          bool
+	GRPCMessages    []GRPCMessage
+	GRPCTrailers    map[string]string
+	SourceFile      string
+	Revalidates     string
+}
+
+type indexCacheEvent struct {
+	Timestamp      float64
+	SerializedData []byte
+}
+
+// NewMockStorageWithCache is NewMockStorage backed by an index cache file. When
+// the cache matches the current mock directory (same files, sizes and modification
+// times) responses are restored from it instead of parsing every recording;
+// otherwise the directory is loaded normally and the cache is rewritten.
+func NewMockStorageWithCache(baseDir, cachePath string) (*MockStorage, bool, error) {
+	storage := newEmptyStorage(baseDir)
+
+	fingerprint, err := mockDirFingerprint(baseDir)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if cache, err := readIndexCache(cachePath); err == nil && cache.Version == indexCacheVersion && cache.Fingerprint == fingerprint {
+		responses := make([]*MockResponse, len(cache.Entries))
+		for i := range cache.Entries {
+			responses[i] = cache.Entries[i].toResponse()
+		}
+		for _, mockResponse := range linkRevalidations(responses) {
+			storage.indexResponse(mockResponse)
+		}
+		storage.failedFiles = cache.Failed
+		storage.cacheResponses()
+		return storage, true, nil
+	}
+
+	responses, err := storage.readResponseFiles()
+	if err != nil {
+		return nil, false, err
+	}
+	// The cache keeps linked 304s as entries of their own; they are linked again on restore
+	if err := storage.writeIndexCache(cachePath, fingerprint, responses); err != nil {
+		return nil, false, fmt.Errorf("write index cache: %w", err)
+	}
+	for _, mockResponse := range linkRevalidations(responses) {
+		storage.indexResponse(mockResponse)
+	}
+	storage.cacheResponses()
+	return storage, false, nil
+}
+
+// mockDirFingerprint hashes the names, sizes and modification times of all
+// recordings in the mock directory without reading their contents.
+func mockDirFingerprint(baseDir string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d\n", indexCacheVersion)
+
+	entries, err := os.ReadDir(baseDir)
+	if os.IsNotExist(err) {
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(baseDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		// os.ReadDir returns entries sorted by name, so the fingerprint is stable
+		for _, file := range files {
+			if file.IsDir() || !isRecordingFile(file.Name()) {
+				continue
+			}
+			info, err := file.Info()
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "%s/%s %d %d\n", entry.Name(), file.Name(), info.Size(), info.ModTime().UnixNano())
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func readIndexCache(path string) (*indexCache, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var cache indexCache
+	if err := gob.NewDecoder(file).Decode(&cache); err != nil {
+		return nil, err
+	}
+	return &cache, nil
+}
+
+// writeIndexCache stores responses in load order so restored indexes match. The file is written atomically so
+// concurrent CI jobs never read a partial cache.
+func (s *MockStorage) writeIndexCache(path, fingerprint string, responses []*MockResponse) error {
+	cache := indexCache{
+		Version:     indexCacheVersion,
+		Fingerprint: fingerprint,
+		Failed:      s.failedFiles,
+	}
+	for _, resp := range responses {
+		cache.Entries = append(cache.Entries, newIndexCacheEntry(resp))
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(tmp).Encode(&cache); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func newIndexCacheEntry(resp *MockResponse) indexCacheEntry {
+	entry := indexCacheEntry{
+		RequestID:       resp.RequestID,
+		Path:            resp.Path,
+		Method:          resp.Method,
+		MockID:          resp.MockID,
+		ContentType:     resp.ContentType,
+		StatusCode:      resp.StatusCode,
+		Headers:         resp.Headers,
+		Body:            resp.Body,
+		FullURL:         resp.FullURL,
+		Note:            resp.Note,
+		Delay:           resp.Delay,
+		IsSSE:           resp.IsSSE,
+		IsWebSocket:     resp.IsWebSocket,
+		WebSocketFrames: resp.WebSocketFrames,
+		IsGRPC:          resp.IsGRPC,
+		GRPCMessages:    resp.GRPCMessages,
+		GRPCTrailers:    resp.GRPCTrailers,
+		SourceFile:      resp.SourceFile,
+		Revalidates:     resp.Revalidates,
+	}
+	if resp.RequestBody != nil {
+		entry.RequestBody, _ = json.Marshal(resp.RequestBody)
+	}
+	for _, event := range resp.SSEEvents {
+		entry.Events = append(entry.Events, indexCacheEvent{Timestamp: event.Timestamp, SerializedData: event.SerializedData})
+	}
+	return entry
+}
+
+// toResponse rebuilds a MockResponse, recomputing the derived lookup fields and
+// the ${ENV:NAME} body template. OriginalBody and SSEEvent.Data are not cached;
+// serving only uses the serialized forms.
+func (e *indexCacheEntry) toResponse() *MockResponse {
+	headerKeysLower := make(map[string]string, len(e.Headers))
+	for k := range e.Headers {
+		headerKeysLower[toLowerASCIISimple(k)] = k
+	}
+
+	var events []SSEEvent
+	for _, event := range e.Events {
+		events = append(events, SSEEvent{
+			Timestamp:      event.Timestamp,
+			SerializedData: event.SerializedData,
+			Frame:          AppendSSEFrame(nil, event.SerializedData),
+		})
+	}
+
+	mockResponse := &MockResponse{
+		RequestID:       e.RequestID,
+		Path:            e.Path,
+		Method:          e.Method,
+		MethodBytes:     []byte(e.Method),
+		MockID:          e.MockID,
+		ContentType:     e.ContentType,
+		StatusCode:      e.StatusCode,
+		Headers:         e.Headers,
+		HeaderKeysLower: headerKeysLower,
+		Body:            e.Body,
+		FullURL:         e.FullURL,
+		Note:            e.Note,
+		Delay:           e.Delay,
+		SSEEvents:       events,
+		IsSSE:           e.IsSSE,
+		WebSocketFrames: e.WebSocketFrames,
+		IsWebSocket:     e.IsWebSocket,
+		GRPCMessages:    e.GRPCMessages,
+		GRPCTrailers:    e.GRPCTrailers,
+		IsGRPC:          e.IsGRPC,
+		SourceFile:      e.SourceFile,
+		Revalidates:     e.Revalidates,
+	}
+	if e.RequestBody != nil {
+		json.Unmarshal(e.RequestBody, &mockResponse.RequestBody)
+	}
+	// ${ENV:NAME} placeholders are expanded whenever the body is served
+	if !e.IsSSE && !e.IsWebSocket && !e.IsGRPC {
+		mockResponse.BodyTemplate = CompileEnvTemplate(string(e.Body))
+	}
+	return mockResponse
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"time"
+)
+
+// ConcurrencyLimiter bounds the number of requests a single mock serves at once.
+// Excess requests are either rejected immediately or queued until a slot frees up.
+type ConcurrencyLimiter struct {
+	slots        chan struct{}
+	queue        bool
+	queueTimeout time.Duration // Zero means wait indefinitely when queueing
+}
+
+// NewConcurrencyLimiter creates a limiter allowing maxConcurrent simultaneous requests.
+// When queue is false, requests over the limit are rejected straight away.
+func NewConcurrencyLimiter(maxConcurrent int, queue bool, queueTimeout time.Duration) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		slots:        make(chan struct{}, maxConcurrent),
+		queue:        queue,
+		queueTimeout: queueTimeout,
+	}
+}
+
+// Acquire reserves a slot. It returns false when the request should be shed.
+func (l *ConcurrencyLimiter) Acquire() bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if !l.queue {
+		return false
+	}
+
+	if l.queueTimeout <= 0 {
+		l.slots <- struct{}{}
+		return true
+	}
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Release frees a slot previously reserved with Acquire.
+func (l *ConcurrencyLimiter) Release() {
+	<-l.slots
+}
+
+// InFlight returns the number of requests currently holding a slot.
+func (l *ConcurrencyLimiter) InFlight() int {
+	return len(l.slots)
+}
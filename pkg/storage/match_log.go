@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// MatchLogger writes every request answered by a mock in the recorder JSON
+// format, grouped by mock ID like a recording directory. Diffing it against
+// the recordings shows where a client behaves differently during replay.
+type MatchLogger struct {
+	baseDir string
+}
+
+// NewMatchLogger creates a logger that writes to the specified directory.
+func NewMatchLogger(baseDir string) (*MatchLogger, error) {
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return nil, err
+	}
+	return &MatchLogger{baseDir: baseDir}, nil
+}
+
+// SetMatchLog enables logging of matched requests into dir.
+func (s *MockStorage) SetMatchLog(dir string) error {
+	logger, err := NewMatchLogger(dir)
+	if err != nil {
+		return err
+	}
+	s.matchLog = logger
+	return nil
+}
+
+// MatchLog returns the matched request logger, or nil when it is disabled.
+func (s *MockStorage) MatchLog() *MatchLogger {
+	return s.matchLog
+}
+
+// LogMatched records the request and the response served for it from resp.
+// It must run once the handler has shaped the response. SSE responses are
+// logged as the recorded events, since their body is streamed.
+func (l *MatchLogger) LogMatched(ctx *fasthttp.RequestCtx, resp *MockResponse) error {
+	requestID := time.Now().Format("20060102150405.999999999")
+	timestamp := time.Now().UTC().Format(time.RFC3339Nano)
+
+	reqHeaders := make(map[string]string)
+	ctx.Request.Header.VisitAll(func(key, value []byte) {
+		reqHeaders[string(key)] = string(value)
+	})
+
+	respHeaders := make(map[string]string)
+	ctx.Response.Header.VisitAll(func(key, value []byte) {
+		respHeaders[string(key)] = string(value)
+	})
+
+	var respBody interface{}
+	if resp.IsSSE {
+		events := make([]interface{}, len(resp.SSEEvents))
+		for i, event := range resp.SSEEvents {
+			events[i] = map[string]interface{}{"data": event.Data, "timestamp": event.Timestamp}
+		}
+		respBody = events
+	} else {
+		respBody = decodeLoggedBody(ctx.Response.Body())
+	}
+
+	record := map[string]interface{}{
+		"request": map[string]interface{}{
+			"request_id": requestID,
+			"timestamp":  timestamp,
+			"method":     string(ctx.Method()),
+			"url":        string(ctx.RequestURI()),
+			"headers":    reqHeaders,
+			"body":       decodeLoggedBody(ctx.PostBody()),
+		},
+		"response": map[string]interface{}{
+			"request_id":      requestID,
+			"timestamp":       time.Now().UTC().Format(time.RFC3339Nano),
+			"status_code":     ctx.Response.StatusCode(),
+			"headers":         respHeaders,
+			"body":            respBody,
+			"delay":           0,
+			"mock_request_id": resp.RequestID,
+		},
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	mockID := resp.MockID
+	if mockID == "" {
+		mockID = "default"
+	}
+	dir := filepath.Join(l.baseDir, mockID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	filename := fmt.Sprintf("%s_%s_%s.json", sanitizeContentType(resp.ContentType),
+		time.Now().Format("20060102_150405"), generateRandomHex(4))
+	return os.WriteFile(filepath.Join(dir, filename), data, 0644)
+}
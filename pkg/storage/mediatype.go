@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// mediaTypeList is a scenario consumes/produces value: a single media type or a list.
+type mediaTypeList []string
+
+// UnmarshalYAML accepts both "consumes: application/json" and a YAML sequence.
+func (l *mediaTypeList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = mediaTypeList{value.Value}
+		return nil
+	}
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// mediaTypeMatcher is the compiled form of a consumes/produces list.
+// Types are lowercased without parameters; "type/*" and "*/*" are wildcards.
+type mediaTypeMatcher struct {
+	types [][]byte
+}
+
+// newMediaTypeMatcher validates a media type list. It returns nil for an empty list.
+func newMediaTypeMatcher(list mediaTypeList) (*mediaTypeMatcher, error) {
+	if len(list) == 0 {
+		return nil, nil
+	}
+	m := &mediaTypeMatcher{}
+	for _, entry := range list {
+		mediaType := strings.ToLower(strings.TrimSpace(entry))
+		if idx := strings.IndexByte(mediaType, ';'); idx >= 0 {
+			mediaType = strings.TrimSpace(mediaType[:idx])
+		}
+		if strings.Count(mediaType, "/") != 1 || strings.HasPrefix(mediaType, "/") || strings.HasSuffix(mediaType, "/") {
+			return nil, fmt.Errorf("invalid media type %q", entry)
+		}
+		m.types = append(m.types, []byte(mediaType))
+	}
+	return m, nil
+}
+
+// matchesContentType reports whether the request Content-Type is one of the
+// listed types. Requests without a Content-Type never match.
+func (m *mediaTypeMatcher) matchesContentType(contentType []byte) bool {
+	contentType = bareMediaType(contentType)
+	if len(contentType) == 0 {
+		return false
+	}
+	for _, t := range m.types {
+		if mediaTypeCompatible(t, contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAccept reports whether any Accept entry is compatible with a listed
+// type. A missing Accept header accepts anything.
+func (m *mediaTypeMatcher) matchesAccept(accept []byte) bool {
+	if len(trimSpaceASCII(accept)) == 0 {
+		return true
+	}
+	for len(accept) > 0 {
+		entry := accept
+		if idx := bytes.IndexByte(accept, ','); idx >= 0 {
+			entry, accept = accept[:idx], accept[idx+1:]
+		} else {
+			accept = nil
+		}
+		entry = bareMediaType(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		for _, t := range m.types {
+			if mediaTypeCompatible(t, entry) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bareMediaType strips parameters and surrounding whitespace from a media type.
+func bareMediaType(value []byte) []byte {
+	if idx := bytes.IndexByte(value, ';'); idx >= 0 {
+		value = value[:idx]
+	}
+	return trimSpaceASCII(value)
+}
+
+// mediaTypeCompatible compares two media types case-insensitively, honoring
+// "*/*" and "type/*" wildcards on either side.
+func mediaTypeCompatible(a, b []byte) bool {
+	if equalFoldBytes(a, b) {
+		return true
+	}
+	aType, aSub, okA := bytes.Cut(a, []byte("/"))
+	bType, bSub, okB := bytes.Cut(b, []byte("/"))
+	if !okA || !okB {
+		return false
+	}
+	if bytes.Equal(aType, []byte("*")) || bytes.Equal(bType, []byte("*")) {
+		return true
+	}
+	if !equalFoldBytes(aType, bType) {
+		return false
+	}
+	return bytes.Equal(aSub, []byte("*")) || bytes.Equal(bSub, []byte("*")) || equalFoldBytes(aSub, bSub)
+}
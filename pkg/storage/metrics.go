@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OpenMetricsContentType is the Content-Type of WriteMetrics output.
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// Histogram buckets: delays in seconds, and jitter as the ratio of the applied
+// delay to the configured one (1 = no jitter).
+var (
+	delayBuckets  = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	jitterBuckets = []float64{0.5, 0.8, 0.9, 0.95, 0.99, 1, 1.01, 1.05, 1.1, 1.2, 1.5, 2}
+)
+
+// chaosMetrics records what the server did to responses on purpose: injected
+// faults, applied delays and jitter. Like requestCounters it is updated with
+// atomic operations only, and /__mock__/reset leaves it alone so the counters
+// stay monotonic for scrapers.
+type chaosMetrics struct {
+	faults sync.Map // faultKey -> *faultCounter
+	delays sync.Map // mock ID -> *histogram
+	jitter sync.Map // mock ID -> *histogram
+}
+
+type faultKey struct {
+	mockID string
+	fault  string
+}
+
+// faultCounter counts the injections of one fault by one mock.
+type faultCounter struct {
+	count   atomic.Uint64
+	last    atomic.Int64 // Unix nanoseconds
+	created time.Time
+}
+
+// histogram is a fixed-bucket histogram. counts holds one non-cumulative count
+// per bucket plus one for +Inf.
+type histogram struct {
+	bounds  []float64
+	counts  []atomic.Uint64
+	sumBits atomic.Uint64 // math.Float64bits of the sum
+	created time.Time
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]atomic.Uint64, len(bounds)+1), created: time.Now()}
+}
+
+func (h *histogram) observe(v float64) {
+	h.counts[sort.SearchFloat64s(h.bounds, v)].Add(1)
+	for {
+		old := h.sumBits.Load()
+		if h.sumBits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// loadHistogram returns the histogram for mockID, adding it on first use.
+func loadHistogram(histograms *sync.Map, mockID string, bounds []float64) *histogram {
+	h, ok := histograms.Load(mockID)
+	if !ok {
+		h, _ = histograms.LoadOrStore(mockID, newHistogram(bounds))
+	}
+	return h.(*histogram)
+}
+
+// CountFault records that mockResponse was answered with fault.
+func (s *MockStorage) CountFault(mockResponse *MockResponse, fault string) {
+	key := faultKey{mockID: mockResponse.MockID, fault: fault}
+	counter, ok := s.metrics.faults.Load(key)
+	if !ok {
+		counter, _ = s.metrics.faults.LoadOrStore(key, &faultCounter{created: time.Now()})
+	}
+	counter.(*faultCounter).count.Add(1)
+	counter.(*faultCounter).last.Store(time.Now().UnixNano())
+}
+
+// ObserveDelay records a replayed delay of mockResponse, in seconds, jitter included.
+func (s *MockStorage) ObserveDelay(mockResponse *MockResponse, seconds float64) {
+	loadHistogram(&s.metrics.delays, mockResponse.MockID, delayBuckets).observe(seconds)
+}
+
+// ObserveJitter records the jitter applied to mockResponse as the ratio of the
+// delay it got to the delay it had (0.9 = 10% faster).
+func (s *MockStorage) ObserveJitter(mockResponse *MockResponse, ratio float64) {
+	loadHistogram(&s.metrics.jitter, mockResponse.MockID, jitterBuckets).observe(ratio)
+}
+
+// WriteMetrics writes the fault, delay and jitter metrics to w in the
+// OpenMetrics text format, labelled by mock ID (the scenario name for
+// scenario responses).
+func (s *MockStorage) WriteMetrics(w io.Writer) error {
+	mw := &metricsWriter{w: w}
+
+	mw.family("mock_faults", "counter", "", "Faults injected into responses.")
+	faults := make([]faultKey, 0)
+	s.metrics.faults.Range(func(key, _ interface{}) bool {
+		faults = append(faults, key.(faultKey))
+		return true
+	})
+	sort.Slice(faults, func(i, j int) bool {
+		if faults[i].mockID != faults[j].mockID {
+			return faults[i].mockID < faults[j].mockID
+		}
+		return faults[i].fault < faults[j].fault
+	})
+	for _, key := range faults {
+		counter := s.faultCounter(key)
+		labels := `{mock_id="` + escapeLabel(key.mockID) + `",fault="` + escapeLabel(key.fault) + `"}`
+		mw.sample("mock_faults_total", labels, formatUint(counter.count.Load()))
+		mw.sample("mock_faults_created", labels, formatUnixSeconds(counter.created.UnixNano()))
+	}
+
+	mw.family("mock_fault_last_timestamp_seconds", "gauge", "seconds", "When each fault was last injected.")
+	for _, key := range faults {
+		labels := `{mock_id="` + escapeLabel(key.mockID) + `",fault="` + escapeLabel(key.fault) + `"}`
+		mw.sample("mock_fault_last_timestamp_seconds", labels, formatUnixSeconds(s.faultCounter(key).last.Load()))
+	}
+
+	mw.histograms("mock_delay_seconds", "seconds", "Replayed response delays, jitter included.", &s.metrics.delays)
+	mw.histograms("mock_delay_jitter_ratio", "", "Applied delay divided by the configured delay.", &s.metrics.jitter)
+
+	mw.raw("# EOF\n")
+	return mw.err
+}
+
+func (s *MockStorage) faultCounter(key faultKey) *faultCounter {
+	counter, _ := s.metrics.faults.Load(key)
+	return counter.(*faultCounter)
+}
+
+// metricsWriter writes OpenMetrics text and keeps the first error.
+type metricsWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (mw *metricsWriter) raw(text string) {
+	if mw.err == nil {
+		_, mw.err = io.WriteString(mw.w, text)
+	}
+}
+
+func (mw *metricsWriter) family(name, kind, unit, help string) {
+	mw.raw("# TYPE " + name + " " + kind + "\n")
+	if unit != "" {
+		mw.raw("# UNIT " + name + " " + unit + "\n")
+	}
+	mw.raw("# HELP " + name + " " + help + "\n")
+}
+
+func (mw *metricsWriter) sample(name, labels, value string) {
+	mw.raw(name + labels + " " + value + "\n")
+}
+
+// histograms writes one histogram family with a series per mock ID.
+func (mw *metricsWriter) histograms(name, unit, help string, histograms *sync.Map) {
+	mw.family(name, "histogram", unit, help)
+
+	var mockIDs []string
+	histograms.Range(func(key, _ interface{}) bool {
+		mockIDs = append(mockIDs, key.(string))
+		return true
+	})
+	sort.Strings(mockIDs)
+
+	for _, mockID := range mockIDs {
+		value, _ := histograms.Load(mockID)
+		h := value.(*histogram)
+		label := `mock_id="` + escapeLabel(mockID) + `"`
+
+		// Buckets are read first, so the count never trails them in a scrape racing an observation
+		var cumulative uint64
+		for i, bound := range h.bounds {
+			cumulative += h.counts[i].Load()
+			mw.sample(name+"_bucket", "{"+label+`,le="`+strconv.FormatFloat(bound, 'g', -1, 64)+`"}`, formatUint(cumulative))
+		}
+		cumulative += h.counts[len(h.bounds)].Load()
+		mw.sample(name+"_bucket", "{"+label+`,le="+Inf"}`, formatUint(cumulative))
+		mw.sample(name+"_count", "{"+label+"}", formatUint(cumulative))
+		mw.sample(name+"_sum", "{"+label+"}", strconv.FormatFloat(math.Float64frombits(h.sumBits.Load()), 'g', -1, 64))
+		mw.sample(name+"_created", "{"+label+"}", formatUnixSeconds(h.created.UnixNano()))
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatUint(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
+
+// formatUnixSeconds formats Unix nanoseconds as seconds with a fraction.
+func formatUnixSeconds(nanos int64) string {
+	return strconv.FormatFloat(float64(nanos)/1e9, 'f', 3, 64)
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/valyala/fasthttp"
+)
+
+// NotFoundResponse replaces the built-in {"error":"No mock found"} answer for
+// requests without a mock. The body is a response template, so it can echo the
+// request ({{request.method}}, {{request.path}}, {{request.body.id}}, ...).
+type NotFoundResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte    // Served as is when Template is nil
+	Template    *Template // Compiled from the body when it has placeholders
+}
+
+// NewNotFoundResponse compiles a custom response for unmatched requests.
+// A zero status selects 404, an empty content type application/json and an
+// empty body the default {"error":"No mock found"}.
+func NewNotFoundResponse(status int, contentType, body string) (*NotFoundResponse, error) {
+	if status == 0 {
+		status = fasthttp.StatusNotFound
+	}
+	if status < 100 || status > 599 {
+		return nil, fmt.Errorf("invalid not found status %d", status)
+	}
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	if body == "" {
+		body = `{"error":"No mock found"}`
+	}
+	tmpl, err := CompileTemplate(body)
+	if err != nil {
+		return nil, fmt.Errorf("not found body: %w", err)
+	}
+	return &NotFoundResponse{Status: status, ContentType: contentType, Body: []byte(body), Template: tmpl}, nil
+}
+
+// Write sets the status, content type and rendered body on the response.
+func (n *NotFoundResponse) Write(ctx *fasthttp.RequestCtx) {
+	ctx.SetStatusCode(n.Status)
+	ctx.Response.Header.SetContentType(n.ContentType)
+	if n.Template != nil {
+		ctx.SetBody(n.Template.Render(ctx))
+	} else {
+		ctx.SetBody(n.Body)
+	}
+}
+
+// SetNotFoundResponse customizes the answer to requests without a mock; nil
+// restores the default JSON 404.
+func (s *MockStorage) SetNotFoundResponse(resp *NotFoundResponse) {
+	s.notFound = resp
+}
+
+// NotFoundResponse returns the custom response for unmatched requests, or nil
+// for the default.
+func (s *MockStorage) NotFoundResponse() *NotFoundResponse {
+	return s.notFound
+}
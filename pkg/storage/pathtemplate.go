@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// pathParamsKey is the request user value holding the path params of the
+// templated mock that answers it.
+const pathParamsKey = "mock.path_params"
+
+// Path template segment kinds, in matching precedence: /users/me wins over
+// /users/{id}, which wins over /users/*, which wins over /users/**.
+const (
+	segmentLiteral = iota
+	segmentParam
+	segmentWildcard // *: any single segment
+	segmentRest     // **: any number of segments, only last
+)
+
+type pathSegment struct {
+	kind  int
+	value string // Literal text or param name
+}
+
+// pathTemplate is a mock path with {name} params or * and ** wildcards.
+type pathTemplate struct {
+	segments []pathSegment
+}
+
+// parsePathTemplate compiles path into a template. It returns nil for plain
+// paths, so callers keep their exact-match lookup.
+func parsePathTemplate(path string) (*pathTemplate, error) {
+	if !strings.ContainsAny(path, "{*") {
+		return nil, nil
+	}
+
+	tmpl := &pathTemplate{}
+	names := make(map[string]bool)
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, part := range parts {
+		switch {
+		case part == "**":
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("path %s: ** must be the last segment", path)
+			}
+			tmpl.segments = append(tmpl.segments, pathSegment{kind: segmentRest})
+		case part == "*":
+			tmpl.segments = append(tmpl.segments, pathSegment{kind: segmentWildcard})
+		case strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}"):
+			name := part[1 : len(part)-1]
+			if name == "" || strings.ContainsAny(name, "{}*") {
+				return nil, fmt.Errorf("path %s: invalid param %q", path, part)
+			}
+			if names[name] {
+				return nil, fmt.Errorf("path %s: duplicate param %q", path, name)
+			}
+			names[name] = true
+			tmpl.segments = append(tmpl.segments, pathSegment{kind: segmentParam, value: name})
+		case strings.ContainsAny(part, "{}*"):
+			return nil, fmt.Errorf("path %s: params and wildcards must span a whole segment, got %q", path, part)
+		default:
+			tmpl.segments = append(tmpl.segments, pathSegment{kind: segmentLiteral, value: part})
+		}
+	}
+	return tmpl, nil
+}
+
+// match reports whether path fits the template and returns its params. Params
+// and * match a single non-empty segment; ** matches the rest of the path,
+// including nothing.
+func (t *pathTemplate) match(path string) (map[string]string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+	var params map[string]string
+	rest := path[1:]
+	for i, segment := range t.segments {
+		if segment.kind == segmentRest {
+			return params, true
+		}
+		if i > 0 {
+			if rest == "" || rest[0] != '/' {
+				return nil, false
+			}
+			rest = rest[1:]
+		}
+		part := rest
+		if idx := strings.IndexByte(rest, '/'); idx >= 0 {
+			part = rest[:idx]
+		}
+		rest = rest[len(part):]
+
+		switch segment.kind {
+		case segmentLiteral:
+			if part != segment.value {
+				return nil, false
+			}
+		case segmentParam:
+			if part == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = make(map[string]string, len(t.segments))
+			}
+			params[segment.value] = part
+		case segmentWildcard:
+			if part == "" {
+				return nil, false
+			}
+		}
+	}
+	return params, rest == ""
+}
+
+// moreSpecific orders templates so the most specific match is tried first:
+// segment by segment, literals before params before wildcards.
+func (t *pathTemplate) moreSpecific(other *pathTemplate) bool {
+	for i := 0; i < len(t.segments) && i < len(other.segments); i++ {
+		if a, b := t.segments[i].kind, other.segments[i].kind; a != b {
+			return a < b
+		}
+	}
+	return len(t.segments) > len(other.segments)
+}
+
+// templatedKey is an index key whose path is a template, tried when a
+// request path has no exact match.
+type templatedKey struct {
+	tmpl        *pathTemplate
+	mockID      string
+	contentType string
+	key         IndexKey
+}
+
+// indexPathTemplates collects the templated keys of the index, most specific
+// first.
+func indexPathTemplates(index map[IndexKey][]*MockResponse) []templatedKey {
+	var keys []templatedKey
+	for key, candidates := range index {
+		if len(candidates) == 0 || candidates[0].pathTemplate == nil {
+			continue
+		}
+		keys = append(keys, templatedKey{
+			tmpl:        candidates[0].pathTemplate,
+			mockID:      candidates[0].MockID,
+			contentType: candidates[0].ContentType,
+			key:         key,
+		})
+	}
+	// Map order is random; break ties by key so lookups are stable
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].tmpl.moreSpecific(keys[j].tmpl) {
+			return true
+		}
+		if keys[j].tmpl.moreSpecific(keys[i].tmpl) {
+			return false
+		}
+		return keys[i].key < keys[j].key
+	})
+	return keys
+}
+
+// findTemplated looks up a request path among the templated recordings. A
+// nil contentType accepts any. The caller holds the read lock.
+func (s *MockStorage) findTemplated(pathBytes, mockIDBytes, contentTypeBytes, methodBytes []byte) *MockResponse {
+	if len(s.pathTemplates) == 0 {
+		return nil
+	}
+	path := string(pathBytes)
+	for _, templated := range s.pathTemplates {
+		if templated.mockID != string(mockIDBytes) ||
+			contentTypeBytes != nil && templated.contentType != string(contentTypeBytes) {
+			continue
+		}
+		if _, ok := templated.tmpl.match(path); !ok {
+			continue
+		}
+		if resp := pickByMethod(s.Responses[templated.key], methodBytes); resp != nil {
+			return resp
+		}
+	}
+	return nil
+}
+
+// templatedScenarios returns the scenarios with templated paths, most
+// specific first and in declaration order among equally specific ones.
+func templatedScenarios(order []*mockScenario) []*mockScenario {
+	var scenarios []*mockScenario
+	for _, scenario := range order {
+		if scenario.pathTemplate != nil {
+			scenarios = append(scenarios, scenario)
+		}
+	}
+	sort.SliceStable(scenarios, func(i, j int) bool {
+		return scenarios[i].pathTemplate.moreSpecific(scenarios[j].pathTemplate)
+	})
+	return scenarios
+}
+
+// BindPathParams makes the params that path fills in a templated mock path
+// available to response templates as request.path_params.<name>. path is the
+// request path the mock was looked up with.
+func BindPathParams(ctx *fasthttp.RequestCtx, mockResponse *MockResponse, path []byte) {
+	if mockResponse.pathTemplate == nil {
+		return
+	}
+	if params, ok := mockResponse.pathTemplate.match(string(path)); ok && params != nil {
+		ctx.SetUserValue(pathParamsKey, params)
+	}
+}
+
+// pathParam returns a param bound by BindPathParams.
+func pathParam(ctx *fasthttp.RequestCtx, name string) string {
+	params, _ := ctx.UserValue(pathParamsKey).(map[string]string)
+	return params[name]
+}
+
+// templatedPathDistance is pathDistance for paths that may be templates: a
+// request path the template matches is at distance 0.
+func templatedPathDistance(tmpl *pathTemplate, pattern, path string) int {
+	if tmpl != nil {
+		if _, ok := tmpl.match(path); ok {
+			return 0
+		}
+	}
+	return pathDistance(pattern, path)
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+var errMalformedProto = errors.New("malformed protobuf message")
+
+// DecodeProtoJSON converts a protobuf message to JSON without a schema so that
+// scenario filters can match on it. Fields are keyed by their number, nested
+// messages become objects and repeated fields arrays:
+//
+//	{"1": "ACME", "2": 5, "3": {"1": true}}
+//
+// Varints and fixed-width values are unsigned numbers. Length-delimited fields
+// are strings when they are printable UTF-8, nested messages when they parse as
+// one, and base64 strings otherwise.
+func DecodeProtoJSON(data []byte) ([]byte, error) {
+	fields, err := decodeProtoFields(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+func decodeProtoFields(data []byte) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errMalformedProto
+		}
+		data = data[n:]
+
+		number := tag >> 3
+		if number == 0 || number > math.MaxInt32 {
+			return nil, errMalformedProto
+		}
+
+		var value interface{}
+		switch tag & 7 {
+		case 0: // varint
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				return nil, errMalformedProto
+			}
+			value, data = v, data[n:]
+		case 1: // fixed64
+			if len(data) < 8 {
+				return nil, errMalformedProto
+			}
+			value, data = binary.LittleEndian.Uint64(data), data[8:]
+		case 2: // length-delimited
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return nil, errMalformedProto
+			}
+			value = decodeProtoBytes(data[n : n+int(length)])
+			data = data[n+int(length):]
+		case 5: // fixed32
+			if len(data) < 4 {
+				return nil, errMalformedProto
+			}
+			value, data = binary.LittleEndian.Uint32(data), data[4:]
+		default: // groups are not supported
+			return nil, errMalformedProto
+		}
+
+		key := strconv.FormatUint(number, 10)
+		switch existing := fields[key].(type) {
+		case nil:
+			fields[key] = value
+		case []interface{}:
+			fields[key] = append(existing, value)
+		default:
+			fields[key] = []interface{}{existing, value}
+		}
+	}
+	return fields, nil
+}
+
+// decodeProtoBytes guesses what a length-delimited field holds.
+func decodeProtoBytes(data []byte) interface{} {
+	if isPrintableUTF8(data) {
+		return string(data)
+	}
+	if nested, err := decodeProtoFields(data); err == nil && len(nested) > 0 {
+		return nested
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func isPrintableUTF8(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, c := range data {
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' || c == 0x7F {
+			return false
+		}
+	}
+	return true
+}
@@ -1,11 +1,9 @@
 package storage
 
 import (
-	"bytes"
-	"compress/gzip"
 	"encoding/base64"
 	"encoding/json"
-	"io"
+	"fmt"
 	"net/url"
 	"os"
 	"strings"
@@ -15,6 +13,17 @@ import (
 // semantics as directory-based loading. The returned MockResponse is ready to
 // be indexed or reused by scenario definitions.
 func loadResponseFromFile(filePath string, fallbackMockID string) (*MockResponse, error) {
+	if isHARFile(filePath) {
+		responses, err := loadHARFile(filePath, fallbackMockID)
+		if err != nil {
+			return nil, err
+		}
+		if len(responses) != 1 {
+			return nil, fmt.Errorf("%s has %d entries; a single response needs exactly one", filePath, len(responses))
+		}
+		return responses[0], nil
+	}
+
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, err
@@ -22,12 +31,54 @@ func loadResponseFromFile(filePath string, fallbackMockID string) (*MockResponse
 	return parseMockRecord(data, fallbackMockID)
 }
 
+// loadResponsesFromFile loads every response of a recording: one for native
+// JSON files, one per entry for HAR files.
+func loadResponsesFromFile(filePath string, fallbackMockID string) ([]*MockResponse, error) {
+	if isHARFile(filePath) {
+		return loadHARFile(filePath, fallbackMockID)
+	}
+	resp, err := loadResponseFromFile(filePath, fallbackMockID)
+	if err != nil {
+		return nil, err
+	}
+	return []*MockResponse{resp}, nil
+}
+
+// isRecordingFile reports whether name is a native (.json) or HAR (.har) recording.
+func isRecordingFile(name string) bool {
+	return strings.HasSuffix(name, ".json") || isHARFile(name)
+}
+
+// serializeSSEData returns the exact bytes sent after "data: " for a recorded event.
+// Events marked with "encoding": "base64" carry binary payloads and "text" marks
+// plain (non-JSON) text; both are replayed verbatim.
+func serializeSSEData(eventMap map[string]interface{}) ([]byte, error) {
+	eventData := eventMap["data"]
+	if str, ok := eventData.(string); ok {
+		switch eventMap["encoding"] {
+		case "base64":
+			return base64.StdEncoding.DecodeString(str)
+		case "text":
+			return []byte(str), nil
+		}
+		// Special handling for [DONE] - send without quotes
+		if str == "[DONE]" {
+			return []byte("[DONE]"), nil
+		}
+	}
+	return json.Marshal(eventData)
+}
+
 func parseMockRecord(data []byte, fallbackMockID string) (*MockResponse, error) {
 	var record map[string]interface{}
 	if err := json.Unmarshal(data, &record); err != nil {
 		return nil, err
 	}
+	return mockFromRecord(record, fallbackMockID)
+}
 
+// mockFromRecord builds a MockResponse from a decoded native record.
+func mockFromRecord(record map[string]interface{}, fallbackMockID string) (*MockResponse, error) {
 	requestData, hasRequest := record["request"].(map[string]interface{})
 	responseData, hasResponse := record["response"].(map[string]interface{})
 	if !hasRequest || !hasResponse {
@@ -73,22 +124,19 @@ func parseMockRecord(data []byte, fallbackMockID string) (*MockResponse, error)
 		contentType = "application/json"
 	}
 
+	// Compressed bodies are recorded base64-encoded and served decoded
 	body := responseData["body"]
 	if bodyStr, ok := body.(string); ok && bodyStr != "" {
-		if responseHeadersLower["content-encoding"] == "gzip" {
+		if encoding := strings.ToLower(strings.TrimSpace(responseHeadersLower["content-encoding"])); encoding != "" {
 			bodyBytes, err := base64.StdEncoding.DecodeString(bodyStr)
 			if err == nil {
-				gzReader, err := gzip.NewReader(bytes.NewReader(bodyBytes))
+				decompressed, err := decodeContentEncoding(encoding, bodyBytes)
 				if err == nil {
-					decompressed, err := io.ReadAll(gzReader)
-					gzReader.Close()
-					if err == nil {
-						var jsonBody interface{}
-						if err := json.Unmarshal(decompressed, &jsonBody); err == nil {
-							body = jsonBody
-						} else {
-							body = string(decompressed)
-						}
+					var jsonBody interface{}
+					if err := json.Unmarshal(decompressed, &jsonBody); err == nil {
+						body = jsonBody
+					} else {
+						body = string(decompressed)
 					}
 				}
 			}
@@ -107,27 +155,49 @@ func parseMockRecord(data []byte, fallbackMockID string) (*MockResponse, error)
 
 	requestID, _ := requestData["request_id"].(string)
 
+	// WebSocket conversations keep their frames instead of a body
+	var wsFrames []WebSocketFrame
+	isWebSocket := record["type"] == "websocket"
+	if isWebSocket {
+		wsFrames, err = parseWebSocketFrames(body)
+		if err != nil {
+			return nil, err
+		}
+		contentType = WebSocketContentType
+	}
+
+	// gRPC calls keep their response messages and trailers instead of a body
+	var grpcMessages []GRPCMessage
+	var grpcTrailers map[string]string
+	isGRPC := record["type"] == "grpc"
+	if isGRPC {
+		grpcMessages, err = parseGRPCMessages(body)
+		if err != nil {
+			return nil, err
+		}
+		grpcTrailers = parseGRPCTrailers(responseData["trailers"])
+		contentType = GRPCContentType
+		if recorded, _ := requestData["method"].(string); recorded == "" {
+			method = "POST" // gRPC calls are always POSTs
+		}
+	}
+
 	var bodyBytes []byte
 	var serErr error
-	if contentType == "text/event-stream" {
+	if isWebSocket || isGRPC {
+		// No HTTP body: the messages are replayed over the upgraded or HTTP/2 stream
+	} else if contentType == "text/event-stream" {
 		if arr, ok := body.([]interface{}); ok {
 			var sseBuilder strings.Builder
 			for _, event := range arr {
 				// Extract data field from event object
 				if eventMap, ok := event.(map[string]interface{}); ok {
-					if eventData, hasData := eventMap["data"]; hasData {
-						// Special handling for [DONE] - send without quotes
-						if str, ok := eventData.(string); ok && str == "[DONE]" {
-							sseBuilder.WriteString("data: [DONE]\n\n")
-						} else {
-							eventJSON, err := json.Marshal(eventData)
-							if err != nil {
-								continue
-							}
-							sseBuilder.WriteString("data: ")
-							sseBuilder.Write(eventJSON)
-							sseBuilder.WriteString("\n\n")
+					if _, hasData := eventMap["data"]; hasData {
+						eventBytes, err := serializeSSEData(eventMap)
+						if err != nil {
+							continue
 						}
+						sseBuilder.Write(AppendSSEFrame(nil, eventBytes))
 					}
 				} else {
 					// Fallback: treat as direct data
@@ -187,17 +257,15 @@ func parseMockRecord(data []byte, fallbackMockID string) (*MockResponse, error)
 						timestamp = ts
 					}
 					if eventData, ok := eventMap["data"]; ok {
-						var serializedData []byte
-						// Special handling for [DONE] - send without quotes
-						if str, ok := eventData.(string); ok && str == "[DONE]" {
-							serializedData = []byte("[DONE]")
-						} else {
-							serializedData, _ = json.Marshal(eventData)
+						serializedData, err := serializeSSEData(eventMap)
+						if err != nil {
+							continue
 						}
 						sseEvents = append(sseEvents, SSEEvent{
 							Data:           eventData,
 							Timestamp:      timestamp,
 							SerializedData: serializedData,
+							Frame:          AppendSSEFrame(nil, serializedData),
 						})
 					}
 				}
@@ -205,6 +273,8 @@ func parseMockRecord(data []byte, fallbackMockID string) (*MockResponse, error)
 		}
 	}
 
+	note, _ := record["note"].(string)
+	revalidates, _ := responseData["revalidates"].(string)
 	mockResponse := &MockResponse{
 		RequestID:       requestID,
 		Path:            path,
@@ -217,10 +287,23 @@ func parseMockRecord(data []byte, fallbackMockID string) (*MockResponse, error)
 		HeaderKeysLower: headerKeysLower,
 		Body:            bodyBytes,
 		OriginalBody:    body,
+		RequestBody:     requestData["body"],
 		FullURL:         urlStr,
+		Note:            note,
 		Delay:           delay,
 		SSEEvents:       sseEvents,
 		IsSSE:           isSSE,
+		WebSocketFrames: wsFrames,
+		IsWebSocket:     isWebSocket,
+		GRPCMessages:    grpcMessages,
+		GRPCTrailers:    grpcTrailers,
+		IsGRPC:          isGRPC,
+		Revalidates:     revalidates,
+	}
+
+	// ${ENV:NAME} placeholders are expanded whenever the body is served
+	if !isSSE && !isWebSocket && !isGRPC {
+		mockResponse.BodyTemplate = CompileEnvTemplate(string(bodyBytes))
 	}
 
 	return mockResponse, nil
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// Reload re-reads every recording in BaseDir and, when one was loaded, the
+// scenario config, replacing what is served. Mocks added at runtime through
+// the admin API or AddRecord are kept. Nothing changes when the scenario
+// config no longer loads; recordings that fail to parse are listed by
+// FailedFiles as on startup.
+func (s *MockStorage) Reload() error {
+	s.mutex.RLock()
+	configPath := s.scenarioConfig
+	s.mutex.RUnlock()
+
+	var config *scenarioConfig
+	if configPath != "" {
+		var err error
+		if config, err = parseScenarioConfig(configPath, s.scenarioTags); err != nil {
+			return err
+		}
+	}
+
+	loaded := newEmptyStorage(s.BaseDir)
+	responses, err := loaded.readResponseFiles()
+	if err != nil {
+		return err
+	}
+	responses = linkRevalidations(responses)
+
+	s.mutex.RLock()
+	policy := s.conflictPolicy
+	s.mutex.RUnlock()
+	if policy == ConflictError {
+		for _, mockResponse := range responses {
+			loaded.indexResponse(mockResponse)
+		}
+		if err := conflictsError(policy, resolveConflicts(loaded.Responses, policy, nil)); err != nil {
+			return err
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Runtime mocks have no file of their own, except persisted admin mocks
+	var runtime []*MockResponse
+	for _, indexed := range s.ResponsesByPathMockID {
+		for _, mockResponse := range indexed {
+			if mockResponse.SourceFile == "" || s.adminMocks[mockResponse.RequestID] == mockResponse {
+				runtime = append(runtime, mockResponse)
+			}
+		}
+	}
+
+	s.Responses = make(map[IndexKey][]*MockResponse)
+	s.ResponsesByPathMockID = make(map[IndexKey][]*MockResponse)
+	for _, mockResponse := range responses {
+		if strings.HasPrefix(mockResponse.RequestID, adminIDPrefix) && s.adminMocks[mockResponse.RequestID] != nil {
+			continue // Served from the admin registry below
+		}
+		if s.persistAdmin && strings.HasPrefix(mockResponse.RequestID, adminIDPrefix) {
+			s.loadAdminMock(mockResponse)
+		}
+		s.indexResponse(mockResponse)
+	}
+	for _, mockResponse := range runtime {
+		if s.adminMocks[mockResponse.RequestID] == mockResponse {
+			s.indexAdminResponse(mockResponse)
+		} else {
+			s.indexResponse(mockResponse)
+		}
+	}
+	s.failedFiles = loaded.failedFiles
+
+	if config != nil {
+		s.useScenarioConfig(config)
+	}
+	s.cacheResponses()
+	return nil
+}
+
+// Reset returns the server to its state after loading: admin mocks added
+// since are removed (with their files when persisted), persisted admin mocks
+// loaded from BaseDir are restored as stored, scenario sequences and
+// response.dir rotations start over, captured session variables are
+// forgotten, and the request counters of the stats and the unmatched request
+// counts of strict mode are cleared.
+func (s *MockStorage) Reset() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for id, mockResponse := range s.adminMocks {
+		if s.loadedAdminMocks[id] == mockResponse {
+			continue
+		}
+		if s.persistAdmin {
+			if err := os.Remove(s.adminMockPath(mockResponse)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		s.unindexResponse(mockResponse)
+		delete(s.adminMocks, id)
+	}
+	for id, mockResponse := range s.loadedAdminMocks {
+		if s.adminMocks[id] == mockResponse {
+			continue
+		}
+		if s.persistAdmin && len(mockResponse.adminRecord) > 0 {
+			path := s.adminMockPath(mockResponse)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(path, mockResponse.adminRecord, 0644); err != nil {
+				return err
+			}
+		}
+		s.adminMocks[id] = mockResponse
+		s.indexResponse(mockResponse)
+	}
+
+	for _, scenario := range s.scenarioOrder {
+		atomic.StoreUint64(&scenario.served, 0)
+		atomic.StoreUint64(&scenario.requests, 0)
+		atomic.StoreUint64(&scenario.serves, 0)
+		resetRotation(scenario.response)
+		for _, mockResponse := range scenario.sequence {
+			resetRotation(mockResponse)
+		}
+	}
+
+	if s.sessions != nil {
+		s.sessions.reset()
+	}
+	if s.unmatched != nil {
+		s.unmatched.Reset()
+	}
+	s.counters.reset()
+	s.cacheResponses()
+	return nil
+}
+
+// resetRotation restarts the response.dir rotations of a scenario response,
+// including the B variant of an experiment.
+func resetRotation(mockResponse *MockResponse) {
+	if mockResponse == nil {
+		return
+	}
+	if mockResponse.Rotation != nil {
+		atomic.StoreUint64(&mockResponse.Rotation.served, 0)
+	}
+	if mockResponse.Experiment != nil && mockResponse.Experiment.B != mockResponse {
+		resetRotation(mockResponse.Experiment.B)
+	}
+}
@@ -0,0 +1,21 @@
+package storage
+
+import "github.com/valyala/fasthttp"
+
+// RequestValidator checks incoming requests before they are matched, e.g.
+// against an OpenAPI spec. ValidateRequest returns the JSON body of a 400
+// answer describing the violations, or nil when the request is valid.
+// method is the method used for matching (after X-HTTP-Method-Override).
+type RequestValidator interface {
+	ValidateRequest(ctx *fasthttp.RequestCtx, method []byte) []byte
+}
+
+// SetRequestValidator enables request validation; nil disables it.
+func (s *MockStorage) SetRequestValidator(v RequestValidator) {
+	s.requestValidator = v
+}
+
+// RequestValidator returns the request validator, or nil when disabled.
+func (s *MockStorage) RequestValidator() RequestValidator {
+	return s.requestValidator
+}
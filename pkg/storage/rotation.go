@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Strategies for picking a response from response.dir.
+const (
+	pickRoundRobin = "round_robin"
+	pickRandom     = "random"
+	pickNewest     = "newest"
+)
+
+// ResponseRotation serves the recordings of a scenario's response.dir in turn
+// or at random. It is set on the first response of the directory, which stands
+// for the whole set until the handler picks one per request.
+type ResponseRotation struct {
+	Responses []*MockResponse
+	Random    bool
+
+	served uint64 // Number of picks, updated atomically
+}
+
+// Next returns the response for the current request.
+func (r *ResponseRotation) Next() *MockResponse {
+	if r.Random {
+		return r.Responses[rand.Intn(len(r.Responses))]
+	}
+	n := atomic.AddUint64(&r.served, 1) - 1
+	return r.Responses[n%uint64(len(r.Responses))]
+}
+
+// loadScenarioResponseDir loads every *.json recording in a response.dir,
+// in file name order, with the overrides of def applied to each. newest keeps
+// only the most recently modified file; the other strategies rotate through all.
+func loadScenarioResponseDir(def scenarioResponseDefinition, dir, name string) (*MockResponse, error) {
+	strategy := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(def.Strategy)), "-", "_")
+	switch strategy {
+	case "":
+		strategy = pickRoundRobin
+	case pickRoundRobin, pickRandom, pickNewest:
+	default:
+		return nil, fmt.Errorf("scenario %s: unknown response.strategy %q (expected round_robin, random or newest)", name, def.Strategy)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("scenario %s: list response.dir: %w", name, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("scenario %s: response.dir %s has no .json recordings", name, dir)
+	}
+	sort.Strings(files)
+
+	if strategy == pickNewest {
+		newest, err := newestFile(files)
+		if err != nil {
+			return nil, fmt.Errorf("scenario %s: %w", name, err)
+		}
+		return loadScenarioResponseFile(def, newest, name)
+	}
+
+	rotation := &ResponseRotation{Random: strategy == pickRandom}
+	for _, file := range files {
+		resp, err := loadScenarioResponseFile(def, file, name)
+		if err != nil {
+			return nil, err
+		}
+		rotation.Responses = append(rotation.Responses, resp)
+	}
+	first := rotation.Responses[0]
+	if len(rotation.Responses) > 1 {
+		first.Rotation = rotation
+	}
+	return first, nil
+}
+
+// newestFile returns the most recently modified of files; ties go to the later name.
+func newestFile(files []string) (string, error) {
+	var newest string
+	var newestInfo os.FileInfo
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", fmt.Errorf("stat %s: %w", file, err)
+		}
+		if newestInfo == nil || !info.ModTime().Before(newestInfo.ModTime()) {
+			newest, newestInfo = file, info
+		}
+	}
+	return newest, nil
+}
+
+// responseVariants returns resp and, for a response.dir rotation, the other
+// recordings it may be served as.
+func responseVariants(resp *MockResponse) []*MockResponse {
+	if resp.Rotation == nil {
+		return []*MockResponse{resp}
+	}
+	return resp.Rotation.Responses
+}
@@ -1,10 +1,16 @@
 package storage
 
 import (
+	"crypto/x509"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	jsonfilter "github.com/andrey-viktorov/jsonfilter-go"
 	"github.com/andrey-viktorov/jsonfilter-go/serde"
@@ -12,104 +18,754 @@ import (
 )
 
 type scenarioFile struct {
-	Scenarios []scenarioDefinition `yaml:"scenarios"`
+	Defaults  scenarioDefaultsDefinition `yaml:"defaults"` // Inherited by every scenario response
+	Scenarios []scenarioDefinition       `yaml:"scenarios"`
+	Chaos     []scenarioChaosDefinition  `yaml:"chaos"`    // Per-path overrides of the global chaos mode
+	Sessions  scenarioSessionDefinition  `yaml:"sessions"` // What keys the variables scenarios capture
+}
+
+// scenarioDefaultsDefinition holds the response settings every scenario
+// inherits unless its response sets them itself.
+type scenarioDefaultsDefinition struct {
+	Headers map[string]string `yaml:"headers"` // Merged under each response's headers
+	Delay   *float64          `yaml:"delay"`   // Used when a response sets no delay
+	Status  int               `yaml:"status"`  // Used when a response sets no status
 }
 
 type scenarioDefinition struct {
-	Name     string                     `yaml:"name"`
-	Method   string                     `yaml:"method"`
-	Path     string                     `yaml:"path"`
-	Filter   scenarioFilterDefinition   `yaml:"filter"`
-	Response scenarioResponseDefinition `yaml:"response"`
+	Name          string                        `yaml:"name"`
+	Method        string                        `yaml:"method"`
+	Path          string                        `yaml:"path"`
+	Filter        scenarioFilterDefinition      `yaml:"filter"`
+	Response      scenarioResponseDefinition    `yaml:"response"`
+	Responses     []scenarioResponseDefinition  `yaml:"responses"`      // Optional sequence served in order
+	OnExhausted   string                        `yaml:"on_exhausted"`   // repeat_last (default), loop, gone or not_found
+	Retry         *scenarioRetryDefinition      `yaml:"retry"`          // Optional failures served before the response
+	MaxConcurrent int                           `yaml:"max_concurrent"` // Optional limit of simultaneous requests
+	OnLimit       string                        `yaml:"on_limit"`       // "reject" (503, default) or "queue"
+	QueueTimeout  *float64                      `yaml:"queue_timeout"`  // Seconds to wait in queue before 503
+	ClientCert    *scenarioClientCertDefinition `yaml:"client_cert"`    // Match only this mTLS client identity
+	ClientIP      clientIPList                  `yaml:"client_ip"`      // Match only clients in these addresses or CIDRs
+	Experiment    *scenarioExperimentDefinition `yaml:"experiment"`     // Split traffic between variants a and b
+	Consumes      mediaTypeList                 `yaml:"consumes"`       // Match only these request Content-Types
+	Produces      mediaTypeList                 `yaml:"produces"`       // Match only requests accepting these types
+	Tags          []string                      `yaml:"tags"`           // Groups activated by -scenario-tags; untagged scenarios are always active
+	AfterRequests int                           `yaml:"after_requests"` // Only match once the path and method were requested this many times
+	MaxServes     int                           `yaml:"max_serves"`     // Stop matching after answering this many requests
+	Capture       map[string]string             `yaml:"capture"`        // Session variables taken from matched requests
 }
 
 type scenarioFilterDefinition struct {
-	Body map[string]interface{} `yaml:"body"`
+	Body map[string]interface{}                 `yaml:"body"`
+	Form map[string]scenarioFormFieldDefinition `yaml:"form"` // Fields of an application/x-www-form-urlencoded body
 }
 
 type scenarioResponseDefinition struct {
-	File  string   `yaml:"file"`
-	Delay *float64 `yaml:"delay"` // Optional override for response timing
+	File     string                   `yaml:"file"`
+	Dir      string                   `yaml:"dir"`       // Folder of recordings to pick from instead of file
+	Strategy string                   `yaml:"strategy"`  // How dir is picked from: round_robin (default), random or newest
+	Status   int                      `yaml:"status"`    // Optional replacement for the recorded status code
+	Delay    *float64                 `yaml:"delay"`     // Optional override for response timing
+	Jitter   *float64                 `yaml:"jitter"`    // Optional override for the -jitter fraction
+	Headers  map[string]string        `yaml:"headers"`   // Extra/overridden response headers (may contain placeholders)
+	Template bool                     `yaml:"template"`  // Render placeholders in the recorded body and headers
+	Abort    *scenarioAbortDefinition `yaml:"abort"`     // Cut an SSE stream early
+	KeepOpen bool                     `yaml:"keep_open"` // Hold an SSE stream open after replay for injected events
+	Geo      *scenarioGeoDefinition   `yaml:"geo"`       // Synthetic X-Geo-* response headers
+	Fault    *scenarioFaultDefinition `yaml:"fault"`     // Break the response to exercise client error handling
+}
+
+// scenarioAbortDefinition configures a mid-stream connection drop for SSE responses.
+type scenarioAbortDefinition struct {
+	AfterEvents  *int     `yaml:"after_events"`  // Close after this many events
+	AfterSeconds *float64 `yaml:"after_seconds"` // Close once the stream has run this long
+	BrokenChunk  bool     `yaml:"broken_chunk"`  // Send a truncated final chunk before closing
+}
+
+// scenarioRetryDefinition scripts a run of throttling failures before success.
+type scenarioRetryDefinition struct {
+	Attempts   int   `yaml:"attempts"`    // Number of failed attempts before the response is served
+	Status     int   `yaml:"status"`      // 429 (default) or e.g. 503
+	RetryAfter []int `yaml:"retry_after"` // Retry-After seconds per attempt; defaults to attempts..1
 }
 
 type mockScenario struct {
-	name        string
-	path        string
-	method      string
-	methodBytes []byte
-	filter      jsonfilter.Operator
-	response    *MockResponse
+	name         string
+	tags         []string
+	path         string
+	pathTemplate *pathTemplate // Set when path has {params} or wildcards
+	method       string
+	methodBytes  []byte
+	filter       jsonfilter.Operator
+	formFilter   *formFilter
+	clientCert   *clientCertMatcher
+	clientIP     *clientIPMatcher
+	consumes     *mediaTypeMatcher
+	produces     *mediaTypeMatcher
+	response     *MockResponse
+	timing       *TimingOverride // Set for delay-only scenarios, which have no response of their own
+
+	// Sequence state (only when the scenario declares responses)
+	sequence    []*MockResponse
+	onExhausted string
+	exhausted   *MockResponse // Served for gone/not_found once the sequence ran out
+	served      uint64        // Number of matched requests, updated atomically
+
+	// Activation threshold (only when the scenario declares after_requests)
+	afterRequests uint64
+	requests      uint64 // Requests to the scenario's path and method, updated atomically
+
+	// One-shot limit (only when the scenario declares max_serves)
+	maxServes uint64
+	serves    uint64 // Requests answered, updated atomically
+}
+
+// Sequence exhaustion behaviors.
+const (
+	exhaustRepeatLast = "repeat_last"
+	exhaustLoop       = "loop"
+	exhaustGone       = "gone"
+	exhaustNotFound   = "not_found"
+)
+
+var errorSequenceExhausted = []byte(`{"error":"Scenario sequence exhausted"}`)
+
+// TimingOverride adjusts the timing of whichever response a request ends up
+// being served, without replacing the response itself.
+type TimingOverride struct {
+	Delay    *float64 // Replacement total delay in seconds
+	Jitter   *float64 // Replacement jitter fraction
+	Scenario string   // Name of the delay-only scenario declaring it
+}
+
+// Apply returns the delay and jitter to use in place of the given ones.
+func (t *TimingOverride) Apply(delay, jitter float64) (float64, float64) {
+	if t.Delay != nil {
+		delay = *t.Delay
+	}
+	if t.Jitter != nil {
+		jitter = *t.Jitter
+	}
+	return delay, jitter
+}
+
+// hasSource reports whether a response definition names recordings to serve.
+func (def scenarioResponseDefinition) hasSource() bool {
+	return strings.TrimSpace(def.File) != "" || strings.TrimSpace(def.Dir) != ""
+}
+
+// isTimingOnly reports whether a response definition only overrides timing.
+func (def scenarioResponseDefinition) isTimingOnly() bool {
+	return !def.hasSource() && (def.Delay != nil || def.Jitter != nil)
+}
+
+// buildTimingOverride validates a delay-only response definition.
+func buildTimingOverride(def scenarioResponseDefinition) (*TimingOverride, error) {
+	if len(def.Headers) > 0 || def.Status != 0 || def.Template || def.Abort != nil || def.KeepOpen || def.Strategy != "" || def.Geo != nil || def.Fault != nil {
+		return nil, fmt.Errorf("only delay and jitter can be set without response.file")
+	}
+	if def.Delay != nil && *def.Delay < 0 {
+		return nil, fmt.Errorf("response.delay must not be negative")
+	}
+	if def.Jitter != nil && *def.Jitter < 0 {
+		return nil, fmt.Errorf("response.jitter must not be negative")
+	}
+	return &TimingOverride{Delay: def.Delay, Jitter: def.Jitter}, nil
+}
+
+// validate checks the defaults block of a scenario config.
+func (d scenarioDefaultsDefinition) validate() error {
+	if d.Delay != nil && *d.Delay < 0 {
+		return fmt.Errorf("defaults.delay must not be negative")
+	}
+	if d.Status != 0 && (d.Status < 100 || d.Status > 599) {
+		return fmt.Errorf("defaults.status must be between 100 and 599, got %d", d.Status)
+	}
+	return nil
+}
+
+// inherit returns def with the defaults filled in: headers it does not
+// declare (compared case-insensitively), and delay and status when unset.
+func (d scenarioDefaultsDefinition) inherit(def scenarioResponseDefinition) scenarioResponseDefinition {
+	if def.Delay == nil {
+		def.Delay = d.Delay
+	}
+	if def.Status == 0 {
+		def.Status = d.Status
+	}
+	if len(d.Headers) == 0 {
+		return def
+	}
+
+	headers := make(map[string]string, len(d.Headers)+len(def.Headers))
+	declared := make(map[string]bool, len(def.Headers))
+	for key, value := range def.Headers {
+		headers[key] = value
+		declared[toLowerASCIISimple(key)] = true
+	}
+	for key, value := range d.Headers {
+		if !declared[toLowerASCIISimple(key)] {
+			headers[key] = value
+		}
+	}
+	def.Headers = headers
+	return def
+}
+
+// loadScenarioResponse loads the response file or directory referenced by a
+// scenario and applies overrides.
+func loadScenarioResponse(def scenarioResponseDefinition, baseDir, name string) (*MockResponse, error) {
+	responseFile := strings.TrimSpace(def.File)
+	responseDir := strings.TrimSpace(def.Dir)
+	if responseFile != "" && responseDir != "" {
+		return nil, fmt.Errorf("scenario %s: response.file and response.dir are mutually exclusive", name)
+	}
+	if responseFile == "" && responseDir == "" {
+		return nil, fmt.Errorf("scenario %s is missing response.file", name)
+	}
+
+	if responseDir != "" {
+		if !filepath.IsAbs(responseDir) {
+			responseDir = filepath.Join(baseDir, responseDir)
+		}
+		return loadScenarioResponseDir(def, responseDir, name)
+	}
+	if def.Strategy != "" {
+		return nil, fmt.Errorf("scenario %s: response.strategy requires response.dir", name)
+	}
+
+	resolvedFile := responseFile
+	if !filepath.IsAbs(resolvedFile) {
+		resolvedFile = filepath.Join(baseDir, resolvedFile)
+	}
+	return loadScenarioResponseFile(def, resolvedFile, name)
+}
+
+// loadScenarioResponseFile loads one recording and applies the overrides of def.
+func loadScenarioResponseFile(def scenarioResponseDefinition, resolvedFile, name string) (*MockResponse, error) {
+	mockResponse, err := loadResponseFromFile(resolvedFile, name)
+	if err != nil {
+		return nil, fmt.Errorf("scenario %s: load response: %w", name, err)
+	}
+	mockResponse.SourceFile = filepath.Clean(resolvedFile)
+
+	if def.Status != 0 {
+		if def.Status < 100 || def.Status > 599 {
+			return nil, fmt.Errorf("scenario %s: response.status must be between 100 and 599, got %d", name, def.Status)
+		}
+		mockResponse.StatusCode = def.Status
+	}
+
+	// Apply delay override if specified
+	if def.Delay != nil {
+		newDelay := *def.Delay
+		oldDelay := mockResponse.Delay
+
+		// For SSE responses, redistribute timing across events proportionally
+		if mockResponse.IsSSE && len(mockResponse.SSEEvents) > 0 && oldDelay > 0 {
+			// Calculate scaling factor
+			scale := newDelay / oldDelay
+
+			// Rescale all event timestamps
+			for i := range mockResponse.SSEEvents {
+				mockResponse.SSEEvents[i].Timestamp *= scale
+			}
+		}
+
+		mockResponse.Delay = newDelay
+	}
+
+	if def.Jitter != nil {
+		if *def.Jitter < 0 {
+			return nil, fmt.Errorf("scenario %s: response.jitter must not be negative", name)
+		}
+		mockResponse.Jitter = def.Jitter
+	}
+
+	// Declared headers win over the geo ones
+	if def.Geo != nil {
+		geoHeaders, err := def.Geo.headers()
+		if err != nil {
+			return nil, fmt.Errorf("scenario %s: %w", name, err)
+		}
+		if err := applyResponseHeaders(mockResponse, geoHeaders); err != nil {
+			return nil, fmt.Errorf("scenario %s: %w", name, err)
+		}
+	}
+	if err := applyResponseHeaders(mockResponse, def.Headers); err != nil {
+		return nil, fmt.Errorf("scenario %s: %w", name, err)
+	}
+
+	if def.Abort != nil {
+		abort, err := buildSSEAbort(def.Abort, mockResponse)
+		if err != nil {
+			return nil, fmt.Errorf("scenario %s: %w", name, err)
+		}
+		mockResponse.SSEAbort = abort
+	}
+
+	if def.Fault != nil {
+		fault, err := buildResponseFault(def.Fault, mockResponse)
+		if err != nil {
+			return nil, fmt.Errorf("scenario %s: %w", name, err)
+		}
+		mockResponse.Fault = fault
+	}
+
+	if def.KeepOpen {
+		if !mockResponse.IsSSE {
+			return nil, fmt.Errorf("scenario %s: keep_open is only supported for SSE responses", name)
+		}
+		mockResponse.SSEKeepOpen = true
+	}
+
+	if def.Template {
+		if err := compileResponseTemplates(mockResponse); err != nil {
+			return nil, fmt.Errorf("scenario %s: %w", name, err)
+		}
+	}
+
+	return mockResponse, nil
+}
+
+// buildSSEAbort validates an abort definition against the loaded response.
+func buildSSEAbort(def *scenarioAbortDefinition, resp *MockResponse) (*SSEAbort, error) {
+	if !resp.IsSSE {
+		return nil, fmt.Errorf("abort is only supported for SSE responses")
+	}
+	if def.AfterEvents == nil && def.AfterSeconds == nil {
+		return nil, fmt.Errorf("abort requires after_events or after_seconds")
+	}
+
+	abort := &SSEAbort{AfterEvents: -1, BrokenChunk: def.BrokenChunk}
+	if def.AfterEvents != nil {
+		if *def.AfterEvents < 0 {
+			return nil, fmt.Errorf("abort.after_events must not be negative")
+		}
+		abort.AfterEvents = *def.AfterEvents
+	}
+	if def.AfterSeconds != nil {
+		if *def.AfterSeconds <= 0 {
+			return nil, fmt.Errorf("abort.after_seconds must be positive")
+		}
+		abort.AfterSeconds = *def.AfterSeconds
+	}
+	return abort, nil
+}
+
+// applyResponseHeaders merges scenario-declared headers into a response.
+// Declared headers replace recorded ones case-insensitively and are always
+// compiled as templates.
+func applyResponseHeaders(resp *MockResponse, headers map[string]string) error {
+	for key, value := range headers {
+		keyLower := toLowerASCIISimple(key)
+		if existing, ok := resp.HeaderKeysLower[keyLower]; ok {
+			delete(resp.Headers, existing)
+		}
+		resp.Headers[key] = value
+		resp.HeaderKeysLower[keyLower] = key
+
+		if keyLower == "content-type" {
+			resp.ContentType = strings.TrimSpace(strings.Split(value, ";")[0])
+		}
+
+		tmpl, err := CompileTemplate(value)
+		if err != nil {
+			return fmt.Errorf("header %s: %w", key, err)
+		}
+		if tmpl != nil {
+			if resp.HeaderTemplates == nil {
+				resp.HeaderTemplates = make(map[string]*Template)
+			}
+			resp.HeaderTemplates[key] = tmpl
+		}
+	}
+	return nil
+}
+
+// compileResponseTemplates compiles placeholders in the body and all headers of a response.
+func compileResponseTemplates(resp *MockResponse) error {
+	bodyTemplate, err := CompileTemplate(string(resp.Body))
+	if err != nil {
+		return fmt.Errorf("body: %w", err)
+	}
+	resp.BodyTemplate = bodyTemplate
+
+	for key, value := range resp.Headers {
+		tmpl, err := CompileTemplate(value)
+		if err != nil {
+			return fmt.Errorf("header %s: %w", key, err)
+		}
+		if tmpl != nil {
+			if resp.HeaderTemplates == nil {
+				resp.HeaderTemplates = make(map[string]*Template)
+			}
+			resp.HeaderTemplates[key] = tmpl
+		}
+	}
+	return nil
+}
+
+// buildRetryResponses creates the throttling responses served before a scenario
+// succeeds. Retry-After values decrease towards 1 unless listed explicitly.
+func buildRetryResponses(def *scenarioRetryDefinition, success *MockResponse) ([]*MockResponse, error) {
+	if def.Attempts <= 0 {
+		return nil, fmt.Errorf("retry.attempts must be positive")
+	}
+
+	status := def.Status
+	if status == 0 {
+		status = 429
+	}
+	if status < 400 || status > 599 {
+		return nil, fmt.Errorf("retry.status must be an error status, got %d", status)
+	}
+
+	failures := make([]*MockResponse, 0, def.Attempts)
+	for i := 0; i < def.Attempts; i++ {
+		retryAfter := def.Attempts - i
+		if len(def.RetryAfter) > 0 {
+			retryAfter = def.RetryAfter[len(def.RetryAfter)-1]
+			if i < len(def.RetryAfter) {
+				retryAfter = def.RetryAfter[i]
+			}
+		}
+
+		retryAfterStr := strconv.Itoa(retryAfter)
+		failures = append(failures, &MockResponse{
+			RequestID:   success.RequestID,
+			Method:      success.Method,
+			MethodBytes: success.MethodBytes,
+			ContentType: "application/json",
+			StatusCode:  status,
+			Headers: map[string]string{
+				"Content-Type": "application/json",
+				"Retry-After":  retryAfterStr,
+			},
+			HeaderKeysLower: map[string]string{
+				"content-type": "Content-Type",
+				"retry-after":  "Retry-After",
+			},
+			Body: []byte(fmt.Sprintf(`{"error":%q,"retry_after":%d}`, http.StatusText(status), retryAfter)),
+		})
+	}
+
+	return failures, nil
+}
+
+// newExhaustedResponse builds the 410/404 response served after a sequence runs out.
+func newExhaustedResponse(first *MockResponse, onExhausted string) *MockResponse {
+	var status int
+	switch onExhausted {
+	case exhaustGone:
+		status = 410
+	case exhaustNotFound:
+		status = 404
+	default:
+		return nil
+	}
+
+	return &MockResponse{
+		RequestID:       first.RequestID,
+		Path:            first.Path,
+		Method:          first.Method,
+		MethodBytes:     first.MethodBytes,
+		MockID:          first.MockID,
+		ContentType:     "application/json",
+		StatusCode:      status,
+		Headers:         map[string]string{"Content-Type": "application/json"},
+		HeaderKeysLower: map[string]string{"content-type": "Content-Type"},
+		Body:            errorSequenceExhausted,
+		FullURL:         first.FullURL,
+		Limiter:         first.Limiter,
+	}
+}
+
+// next returns the response for the current request, advancing the sequence.
+func (sc *mockScenario) next() *MockResponse {
+	if sc.sequence == nil {
+		return sc.response
+	}
+	return sc.responseAt(atomic.AddUint64(&sc.served, 1) - 1)
+}
+
+// peek returns the response the next matching request would get, without
+// advancing the sequence.
+func (sc *mockScenario) peek() *MockResponse {
+	if sc.sequence == nil {
+		return sc.response
+	}
+	return sc.responseAt(atomic.LoadUint64(&sc.served))
+}
+
+// responseAt returns the response for the n-th (0-based) matched request.
+func (sc *mockScenario) responseAt(n uint64) *MockResponse {
+	if n < uint64(len(sc.sequence)) {
+		return sc.sequence[n]
+	}
+
+	switch sc.onExhausted {
+	case exhaustLoop:
+		return sc.sequence[n%uint64(len(sc.sequence))]
+	case exhaustGone, exhaustNotFound:
+		return sc.exhausted
+	default:
+		return sc.sequence[len(sc.sequence)-1]
+	}
+}
+
+// Reasons a scenario does not match a request, as reported by mismatch.
+const (
+	mismatchAfterRequests = "after_requests" // Reported by callers, see dormant
+	mismatchMaxServes     = "max_serves"     // Reported by callers, see spent
+	mismatchMethod        = "method"
+	mismatchClientCert    = "client_cert"
+	mismatchClientIP      = "client_ip"
+	mismatchConsumes      = "consumes"
+	mismatchProduces      = "produces"
+	mismatchBody          = "filter.body"
+	mismatchForm          = "filter.form"
+)
+
+// mismatch returns the first matcher of the scenario the request fails, or ""
+// when the scenario matches.
+func (sc *mockScenario) mismatch(methodBytes, contentType, accept, body []byte, clientCert *x509.Certificate, clientIP net.IP) string {
+	if !sc.acceptsMethod(methodBytes) {
+		return mismatchMethod
+	}
+	if sc.clientCert != nil && !sc.clientCert.matches(clientCert) {
+		return mismatchClientCert
+	}
+	if sc.clientIP != nil && !sc.clientIP.matches(clientIP) {
+		return mismatchClientIP
+	}
+	if sc.consumes != nil && !sc.consumes.matchesContentType(contentType) {
+		return mismatchConsumes
+	}
+	if sc.produces != nil && !sc.produces.matchesAccept(accept) {
+		return mismatchProduces
+	}
+	if sc.filter != nil && !sc.filter.Evaluate(body).Match {
+		return mismatchBody
+	}
+	if sc.formFilter != nil && !sc.formFilter.matches(body) {
+		return mismatchForm
+	}
+	return ""
+}
+
+// acceptsMethod reports whether the scenario answers the request method; GET
+// scenarios also answer HEAD requests.
+func (sc *mockScenario) acceptsMethod(methodBytes []byte) bool {
+	return len(sc.methodBytes) == 0 || len(methodBytes) == 0 || equalFoldBytes(sc.methodBytes, methodBytes) ||
+		equalFoldBytes(methodBytes, methodHEAD) && equalFoldBytes(sc.methodBytes, methodGET)
+}
+
+// countRequest counts a request to the path towards the after_requests
+// threshold of every scenario whose method it has.
+func countRequest(scenarios []*mockScenario, methodBytes []byte) {
+	for _, scenario := range scenarios {
+		if scenario.afterRequests > 0 && scenario.acceptsMethod(methodBytes) {
+			atomic.AddUint64(&scenario.requests, 1)
+		}
+	}
+}
+
+// dormant reports whether an after_requests scenario is still inactive when
+// its path and method have been requested hits times, the current request
+// included.
+func (sc *mockScenario) dormant(hits uint64) bool {
+	return sc.afterRequests > 0 && hits <= sc.afterRequests
+}
+
+// claim takes one of the scenario's max_serves and reports false once they
+// are used up, so the request falls through to the next scenario.
+func (sc *mockScenario) claim() bool {
+	return sc.maxServes == 0 || atomic.AddUint64(&sc.serves, 1) <= sc.maxServes
+}
+
+// spent reports whether the scenario has used up its max_serves.
+func (sc *mockScenario) spent() bool {
+	return sc.maxServes > 0 && atomic.LoadUint64(&sc.serves) >= sc.maxServes
 }
 
 // LoadScenarioConfig enables scenario-based matching using the supplied YAML file.
 // When scenarios are present the legacy mock-id lookup path is disabled.
 func (s *MockStorage) LoadScenarioConfig(configPath string) error {
+	config, err := parseScenarioConfig(configPath, s.scenarioTags)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.scenarioConfig = configPath
+	s.useScenarioConfig(config)
+	s.scenariosEnabled = true
+	// Refresh cached stats/list to reflect scenarios instead of legacy mock-id data.
+	s.cacheResponses()
+
+	return nil
+}
+
+// scenarioConfig is a parsed scenario config file.
+type scenarioConfig struct {
+	byPath     map[string][]*mockScenario
+	order      []*mockScenario
+	chaosRules []chaosRule
+	sessions   *sessionStore // nil when no scenario captures variables
+}
+
+// useScenarioConfig serves config. Callers hold the write lock.
+func (s *MockStorage) useScenarioConfig(config *scenarioConfig) {
+	s.scenarioByPath = config.byPath
+	s.scenarioOrder = config.order
+	s.chaosRules = config.chaosRules
+	s.sessions = config.sessions
+}
+
+// parseScenarioConfig builds the scenarios of a YAML file, indexed by path
+// and in declaration order, its chaos overrides and session settings. With
+// active tags, tagged scenarios that carry none of them are left out.
+func parseScenarioConfig(configPath string, activeTags []string) (*scenarioConfig, error) {
 	payload, err := os.ReadFile(configPath)
 	if err != nil {
-		return fmt.Errorf("read scenario config: %w", err)
+		return nil, fmt.Errorf("read scenario config: %w", err)
 	}
 
 	var file scenarioFile
 	if err := yaml.Unmarshal(payload, &file); err != nil {
-		return fmt.Errorf("parse scenario config: %w", err)
+		return nil, fmt.Errorf("parse scenario config: %w", err)
 	}
 
 	if len(file.Scenarios) == 0 {
-		return fmt.Errorf("scenario config %s does not define any scenarios", configPath)
+		return nil, fmt.Errorf("scenario config %s does not define any scenarios", configPath)
+	}
+	if err := file.Defaults.validate(); err != nil {
+		return nil, fmt.Errorf("scenario config %s: %w", configPath, err)
 	}
 
 	parser := serde.DefaultParser()
 	baseDir := filepath.Dir(configPath)
 
-	s.scenarioByPath = make(map[string][]*mockScenario)
-	s.scenarioOrder = make([]*mockScenario, 0, len(file.Scenarios))
+	byPath := make(map[string][]*mockScenario)
+	order := make([]*mockScenario, 0, len(file.Scenarios))
+	selected := make(map[string]bool, len(activeTags))
+	for _, tag := range activeTags {
+		selected[tag] = false
+	}
 
+	var sessions *sessionStore
 	for idx, def := range file.Scenarios {
 		name := strings.TrimSpace(def.Name)
 		if name == "" {
-			return fmt.Errorf("scenario #%d is missing name", idx+1)
+			return nil, fmt.Errorf("scenario #%d is missing name", idx+1)
 		}
 
 		path := strings.TrimSpace(def.Path)
 		if path == "" {
-			return fmt.Errorf("scenario %s is missing path", name)
+			return nil, fmt.Errorf("scenario %s is missing path", name)
+		}
+		pathTmpl, err := parsePathTemplate(path)
+		if err != nil {
+			return nil, fmt.Errorf("scenario %s: %w", name, err)
 		}
 
-		responseFile := strings.TrimSpace(def.Response.File)
-		if responseFile == "" {
-			return fmt.Errorf("scenario %s is missing response.file", name)
+		method := strings.ToUpper(strings.TrimSpace(def.Method))
+		if def.AfterRequests < 0 {
+			return nil, fmt.Errorf("scenario %s: after_requests must not be negative", name)
+		}
+		if def.MaxServes < 0 {
+			return nil, fmt.Errorf("scenario %s: max_serves must not be negative", name)
 		}
 
-		resolvedFile := responseFile
-		if !filepath.IsAbs(resolvedFile) {
-			resolvedFile = filepath.Join(baseDir, resolvedFile)
+		tags := make([]string, 0, len(def.Tags))
+		for _, tag := range def.Tags {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		if !scenarioActive(tags, selected) {
+			continue
 		}
 
-		mockResponse, err := loadResponseFromFile(resolvedFile, name)
-		if err != nil {
-			return fmt.Errorf("scenario %s: load response: %w", name, err)
+		// Delay-only scenarios adjust timing and let matching fall through
+		if len(def.Responses) == 0 && def.Response.isTimingOnly() {
+			if def.Retry != nil || def.MaxConcurrent != 0 || len(def.Capture) > 0 || def.Experiment != nil {
+				return nil, fmt.Errorf("scenario %s: delay-only scenarios cannot declare retry, max_concurrent, capture or experiment", name)
+			}
+			timing, err := buildTimingOverride(def.Response)
+			if err != nil {
+				return nil, fmt.Errorf("scenario %s: %w", name, err)
+			}
+			timing.Scenario = name
+			scenario := &mockScenario{
+				name:         name,
+				path:         path,
+				pathTemplate: pathTmpl,
+				method:       method,
+				methodBytes:  []byte(method),
+				timing:       timing,
+
+				afterRequests: uint64(def.AfterRequests),
+				maxServes:     uint64(def.MaxServes),
+			}
+			if err := scenario.buildMatchers(def, parser); err != nil {
+				return nil, err
+			}
+			if pathTmpl == nil {
+				byPath[path] = append(byPath[path], scenario)
+			}
+			order = append(order, scenario)
+			continue
 		}
 
-		// Apply delay override if specified
-		if def.Response.Delay != nil {
-			newDelay := *def.Response.Delay
-			oldDelay := mockResponse.Delay
+		responseDefs := def.Responses
+		if def.Experiment != nil {
+			if len(def.Responses) > 0 || def.Response.hasSource() || def.Retry != nil {
+				return nil, fmt.Errorf("scenario %s: experiment cannot be combined with response, responses or retry", name)
+			}
+			responseDefs = []scenarioResponseDefinition{def.Experiment.A, def.Experiment.B}
+		} else if len(responseDefs) == 0 {
+			responseDefs = []scenarioResponseDefinition{def.Response}
+		} else if def.Response.hasSource() {
+			return nil, fmt.Errorf("scenario %s: response and responses are mutually exclusive", name)
+		}
 
-			// For SSE responses, redistribute timing across events proportionally
-			if mockResponse.IsSSE && len(mockResponse.SSEEvents) > 0 && oldDelay > 0 {
-				// Calculate scaling factor
-				scale := newDelay / oldDelay
+		onExhausted := strings.ToLower(strings.TrimSpace(def.OnExhausted))
+		switch onExhausted {
+		case "":
+			onExhausted = exhaustRepeatLast
+		case exhaustRepeatLast, exhaustLoop, exhaustGone, exhaustNotFound:
+		default:
+			return nil, fmt.Errorf("scenario %s: unknown on_exhausted %q (expected repeat_last, loop, gone or not_found)", name, def.OnExhausted)
+		}
 
-				// Rescale all event timestamps
-				for i := range mockResponse.SSEEvents {
-					mockResponse.SSEEvents[i].Timestamp *= scale
-				}
+		responses := make([]*MockResponse, 0, len(responseDefs))
+		for _, responseDef := range responseDefs {
+			mockResponse, err := loadScenarioResponse(file.Defaults.inherit(responseDef), baseDir, name)
+			if err != nil {
+				return nil, err
 			}
+			responses = append(responses, mockResponse)
+		}
+		mockResponse := responses[0]
 
-			mockResponse.Delay = newDelay
+		if def.Experiment != nil {
+			if _, err := newExperiment(def.Experiment, responses[0], responses[1]); err != nil {
+				return nil, fmt.Errorf("scenario %s: %w", name, err)
+			}
+		}
+
+		if def.Retry != nil {
+			failures, err := buildRetryResponses(def.Retry, mockResponse)
+			if err != nil {
+				return nil, fmt.Errorf("scenario %s: %w", name, err)
+			}
+			responses = append(failures, responses...)
 		}
 
-		method := strings.ToUpper(strings.TrimSpace(def.Method))
 		if method == "" {
 			method = strings.ToUpper(mockResponse.Method)
 		}
@@ -117,46 +773,184 @@ func (s *MockStorage) LoadScenarioConfig(configPath string) error {
 			method = "GET"
 		}
 
-		var operator jsonfilter.Operator
-		if len(def.Filter.Body) > 0 {
-			root := map[string]interface{}{"jsonFilter": def.Filter.Body}
-			operator, err = parser.FromMap(root)
-			if err != nil {
-				return fmt.Errorf("scenario %s filter: %w", name, err)
+		if def.MaxConcurrent < 0 {
+			return nil, fmt.Errorf("scenario %s: max_concurrent must not be negative", name)
+		}
+		if def.MaxConcurrent > 0 {
+			onLimit := strings.ToLower(strings.TrimSpace(def.OnLimit))
+			if onLimit != "" && onLimit != "reject" && onLimit != "queue" {
+				return nil, fmt.Errorf("scenario %s: unknown on_limit %q (expected reject or queue)", name, def.OnLimit)
 			}
+			var queueTimeout time.Duration
+			if def.QueueTimeout != nil {
+				queueTimeout = time.Duration(*def.QueueTimeout * float64(time.Second))
+			}
+			limiter := NewConcurrencyLimiter(def.MaxConcurrent, onLimit == "queue", queueTimeout)
+			for _, resp := range responses {
+				for _, variant := range responseVariants(resp) {
+					variant.Limiter = limiter
+				}
+			}
+		}
 
-			validation := operator.Validate()
-			if !validation.Valid {
-				return fmt.Errorf("scenario %s filter invalid: %s", name, validation.CauseDescription)
+		if len(def.Capture) > 0 {
+			captures, err := buildSessionCaptures(def.Capture)
+			if err != nil {
+				return nil, fmt.Errorf("scenario %s: %w", name, err)
+			}
+			if sessions == nil {
+				if sessions, err = newSessionStore(file.Sessions); err != nil {
+					return nil, fmt.Errorf("scenario config %s: %w", configPath, err)
+				}
+			}
+			for _, resp := range responses {
+				for _, variant := range responseVariants(resp) {
+					variant.captures = captures
+				}
 			}
 		}
 
-		mockResponse.Path = path
-		mockResponse.FullURL = path
-		mockResponse.Method = method
-		mockResponse.MethodBytes = []byte(method)
-		mockResponse.MockID = name
+		for _, resp := range responses {
+			for _, variant := range responseVariants(resp) {
+				variant.Path = path
+				variant.pathTemplate = pathTmpl
+				variant.FullURL = path
+				variant.Method = method
+				variant.MethodBytes = []byte(method)
+				variant.MockID = name
+			}
+		}
 
 		scenario := &mockScenario{
-			name:        name,
-			path:        path,
-			method:      method,
-			methodBytes: []byte(method),
-			filter:      operator,
-			response:    mockResponse,
+			name:         name,
+			tags:         tags,
+			path:         path,
+			pathTemplate: pathTmpl,
+			method:       method,
+			methodBytes:  []byte(method),
+			response:     mockResponse,
+
+			afterRequests: uint64(def.AfterRequests),
+			maxServes:     uint64(def.MaxServes),
+		}
+		if err := scenario.buildMatchers(def, parser); err != nil {
+			return nil, err
+		}
+		if len(def.Responses) > 0 || def.Retry != nil {
+			scenario.sequence = responses
+			scenario.onExhausted = onExhausted
+			scenario.exhausted = newExhaustedResponse(responses[0], onExhausted)
 		}
 
-		s.scenarioByPath[path] = append(s.scenarioByPath[path], scenario)
-		s.scenarioOrder = append(s.scenarioOrder, scenario)
+		// Templated paths are matched through scenarioTemplates
+		if pathTmpl == nil {
+			byPath[path] = append(byPath[path], scenario)
+		}
+		order = append(order, scenario)
 	}
 
-	s.scenariosEnabled = true
-	// Refresh cached stats/list to reflect scenarios instead of legacy mock-id data.
-	s.cacheResponses()
+	for _, tag := range activeTags {
+		if !selected[tag] {
+			return nil, fmt.Errorf("scenario config %s has no scenario tagged %q", configPath, tag)
+		}
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("scenario config %s has no scenario for tags %s", configPath, strings.Join(activeTags, ","))
+	}
+
+	chaosRules, err := buildChaosRules(file.Chaos)
+	if err != nil {
+		return nil, fmt.Errorf("scenario config %s: %w", configPath, err)
+	}
+
+	return &scenarioConfig{byPath: byPath, order: order, chaosRules: chaosRules, sessions: sessions}, nil
+}
+
+// scenarioActive reports whether a scenario with the given tags is loaded
+// when the tags in selected are active, marking the tags it carries as used.
+// Untagged scenarios, and every scenario when no tag is active, are loaded.
+func scenarioActive(tags []string, selected map[string]bool) bool {
+	if len(selected) == 0 || len(tags) == 0 {
+		return true
+	}
+	active := false
+	for _, tag := range tags {
+		if _, ok := selected[tag]; ok {
+			selected[tag] = true
+			active = true
+		}
+	}
+	return active
+}
+
+// buildMatchers compiles the body filters, client certificate and media type matchers of a scenario.
+func (sc *mockScenario) buildMatchers(def scenarioDefinition, parser serde.Parser) error {
+	if len(def.Filter.Body) > 0 {
+		operator, err := parseBodyFilter(def.Filter.Body, parser)
+		if err != nil {
+			return fmt.Errorf("scenario %s filter: %w", sc.name, err)
+		}
+
+		validation := operator.Validate()
+		if !validation.Valid {
+			return fmt.Errorf("scenario %s filter invalid: %s", sc.name, validation.CauseDescription)
+		}
+		sc.filter = operator
+	}
+
+	var err error
+	if sc.formFilter, err = newFormFilter(def.Filter.Form); err != nil {
+		return fmt.Errorf("scenario %s form filter: %w", sc.name, err)
+	}
+
+	if def.ClientCert != nil {
+		clientCert, err := newClientCertMatcher(def.ClientCert)
+		if err != nil {
+			return fmt.Errorf("scenario %s: %w", sc.name, err)
+		}
+		sc.clientCert = clientCert
+	}
+	if sc.clientIP, err = newClientIPMatcher(def.ClientIP); err != nil {
+		return fmt.Errorf("scenario %s client_ip: %w", sc.name, err)
+	}
+
+	if sc.consumes, err = newMediaTypeMatcher(def.Consumes); err != nil {
+		return fmt.Errorf("scenario %s consumes: %w", sc.name, err)
+	}
+	if sc.produces, err = newMediaTypeMatcher(def.Produces); err != nil {
+		return fmt.Errorf("scenario %s produces: %w", sc.name, err)
+	}
 
 	return nil
 }
 
+// scenariosFor returns the scenarios declared for a path, or nil when
+// scenario-based routing is off. Scenarios for the exact path come first,
+// then those whose templated path matches, most specific first.
+func (s *MockStorage) scenariosFor(pathBytes []byte) []*mockScenario {
+	// The config can be swapped by Reload while requests are matched
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if !s.scenariosEnabled {
+		return nil
+	}
+	scenarios := s.scenarioByPath[string(pathBytes)]
+	if len(s.scenarioTemplates) == 0 {
+		return scenarios
+	}
+
+	var templated []*mockScenario
+	for _, scenario := range s.scenarioTemplates {
+		if _, ok := scenario.pathTemplate.match(string(pathBytes)); ok {
+			templated = append(templated, scenario)
+		}
+	}
+	if len(templated) == 0 {
+		return scenarios
+	}
+	return append(append(make([]*mockScenario, 0, len(scenarios)+len(templated)), scenarios...), templated...)
+}
+
 // HasScenarios returns true when scenario-based routing is active.
 func (s *MockStorage) HasScenarios() bool {
 	return s.scenariosEnabled
@@ -165,29 +959,53 @@ func (s *MockStorage) HasScenarios() bool {
 // MatchScenarioResponse evaluates the configured scenarios in declaration order
 // and returns the first response whose method and filter match.
 func (s *MockStorage) MatchScenarioResponse(pathBytes, methodBytes, body []byte) *MockResponse {
-	if !s.scenariosEnabled {
-		return nil
-	}
+	return s.MatchScenarioResponseForClient(pathBytes, methodBytes, body, nil)
+}
 
-	scenarios := s.scenarioByPath[string(pathBytes)]
+// MatchScenarioResponseForClient is MatchScenarioResponse for requests that may
+// carry a verified mTLS client certificate. Scenarios with client_cert only
+// match when clientCert satisfies them.
+func (s *MockStorage) MatchScenarioResponseForClient(pathBytes, methodBytes, body []byte, clientCert *x509.Certificate) *MockResponse {
+	resp, _ := s.MatchScenario(pathBytes, methodBytes, nil, nil, body, clientCert, nil)
+	return resp
+}
+
+// MatchScenario evaluates scenarios like MatchScenarioResponseForClient and also
+// returns the timing override of the first matching delay-only scenario. Such
+// scenarios never answer by themselves: matching continues past them, and when
+// no later scenario responds the caller should fall back to the mock-id lookup
+// and apply the override to whatever it finds. contentType and accept are the
+// raw request headers checked against consumes and produces, clientIP the
+// address checked against client_ip.
+func (s *MockStorage) MatchScenario(pathBytes, methodBytes, contentType, accept, body []byte, clientCert *x509.Certificate, clientIP net.IP) (*MockResponse, *TimingOverride) {
+	scenarios := s.scenariosFor(pathBytes)
 	if len(scenarios) == 0 {
-		return nil
+		return nil, nil
 	}
 
+	var timing *TimingOverride
+
+	countRequest(scenarios, methodBytes)
 	for _, scenario := range scenarios {
-		if len(scenario.methodBytes) > 0 && len(methodBytes) > 0 && !equalFoldBytes(scenario.methodBytes, methodBytes) {
+		if scenario.mismatch(methodBytes, contentType, accept, body, clientCert, clientIP) != "" {
+			continue
+		}
+		if scenario.dormant(atomic.LoadUint64(&scenario.requests)) {
 			continue
 		}
 
-		if scenario.filter != nil {
-			result := scenario.filter.Evaluate(body)
-			if !result.Match {
-				continue
+		if scenario.timing != nil {
+			if timing == nil && scenario.claim() {
+				timing = scenario.timing
 			}
+			continue
+		}
+		if !scenario.claim() {
+			continue
 		}
 
-		return scenario.response
+		return scenario.next(), timing
 	}
 
-	return nil
+	return nil, timing
 }
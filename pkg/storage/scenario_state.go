@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// scenarioStateVersion is bumped whenever the state file layout changes.
+// Version 1 files, written before captured variables were kept, still load.
+const scenarioStateVersion = 2
+
+// scenarioStateFile is the on-disk form of the scenario counters and the
+// captured session variables.
+type scenarioStateFile struct {
+	Version   int                          `json:"version"`
+	Scenarios map[string]scenarioState     `json:"scenarios"`
+	Sessions  map[string]map[string]string `json:"sessions,omitempty"` // Session key -> variable -> value
+}
+
+// scenarioState holds the counters that decide what a scenario serves next.
+// Scenarios still in their initial state are left out of the file.
+type scenarioState struct {
+	Served    uint64   `json:"served,omitempty"`    // Position in the responses sequence
+	Requests  uint64   `json:"requests,omitempty"`  // Requests counted towards after_requests
+	Serves    uint64   `json:"serves,omitempty"`    // Requests answered, for max_serves
+	Rotations []uint64 `json:"rotations,omitempty"` // response.dir picks, in scenarioRotations order
+}
+
+func (st *scenarioState) isZero() bool {
+	if st.Served != 0 || st.Requests != 0 || st.Serves != 0 {
+		return false
+	}
+	for _, picks := range st.Rotations {
+		if picks != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// scenarioStateKeys names the scenarios in the state file. Scenarios are
+// identified by name; a repeated name gets a #2, #3... suffix in declaration
+// order, so the file still lines up with an unchanged config.
+func scenarioStateKeys(order []*mockScenario) []string {
+	keys := make([]string, len(order))
+	seen := make(map[string]int, len(order))
+	for i, scenario := range order {
+		seen[scenario.name]++
+		keys[i] = scenario.name
+		if n := seen[scenario.name]; n > 1 {
+			keys[i] += "#" + strconv.Itoa(n)
+		}
+	}
+	return keys
+}
+
+// scenarioRotations returns the response.dir rotations of a scenario, in a
+// stable order: the response, its sequence, then the B variants.
+func scenarioRotations(scenario *mockScenario) []*ResponseRotation {
+	var rotations []*ResponseRotation
+	seen := make(map[*ResponseRotation]bool)
+	add := func(mockResponse *MockResponse) {
+		for ; mockResponse != nil; mockResponse = experimentB(mockResponse) {
+			if rotation := mockResponse.Rotation; rotation != nil && !seen[rotation] {
+				seen[rotation] = true
+				rotations = append(rotations, rotation)
+			}
+		}
+	}
+	add(scenario.response)
+	for _, mockResponse := range scenario.sequence {
+		add(mockResponse)
+	}
+	return rotations
+}
+
+// experimentB returns the B variant of an experiment response, or nil.
+func experimentB(mockResponse *MockResponse) *MockResponse {
+	if mockResponse.Experiment != nil && mockResponse.Experiment.B != mockResponse {
+		return mockResponse.Experiment.B
+	}
+	return nil
+}
+
+// scenarioStateJSON encodes the current scenario counters.
+func (s *MockStorage) scenarioStateJSON() ([]byte, error) {
+	s.mutex.RLock()
+	order := s.scenarioOrder
+	sessions := s.sessions
+	s.mutex.RUnlock()
+
+	file := scenarioStateFile{Version: scenarioStateVersion, Scenarios: make(map[string]scenarioState)}
+	if sessions != nil {
+		file.Sessions = sessions.snapshot()
+	}
+	for i, key := range scenarioStateKeys(order) {
+		scenario := order[i]
+		state := scenarioState{
+			Served:   atomic.LoadUint64(&scenario.served),
+			Requests: atomic.LoadUint64(&scenario.requests),
+			Serves:   atomic.LoadUint64(&scenario.serves),
+		}
+		for _, rotation := range scenarioRotations(scenario) {
+			state.Rotations = append(state.Rotations, atomic.LoadUint64(&rotation.served))
+		}
+		if !state.isZero() {
+			file.Scenarios[key] = state
+		}
+	}
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// SaveScenarioState writes the scenario sequence positions, after_requests
+// and max_serves counters, response.dir rotations and captured session
+// variables to path. The file is
+// written atomically so a crash never leaves a partial state behind.
+func (s *MockStorage) SaveScenarioState(path string) error {
+	data, err := s.scenarioStateJSON()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// RestoreScenarioState resumes the scenarios from a file written by
+// SaveScenarioState and returns how many were restored. A missing file is not
+// an error: the scenarios start fresh. Scenarios the file does not know start
+// fresh too, and entries of scenarios no longer configured are ignored.
+// Captured session variables are restored when a scenario still captures.
+func (s *MockStorage) RestoreScenarioState(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var file scenarioStateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return 0, fmt.Errorf("parse scenario state %s: %w", path, err)
+	}
+	if file.Version < 1 || file.Version > scenarioStateVersion {
+		return 0, fmt.Errorf("scenario state %s: unsupported version %d", path, file.Version)
+	}
+
+	s.mutex.RLock()
+	order := s.scenarioOrder
+	sessions := s.sessions
+	s.mutex.RUnlock()
+
+	if sessions != nil {
+		sessions.restore(file.Sessions)
+	}
+	restored := 0
+	for i, key := range scenarioStateKeys(order) {
+		state, ok := file.Scenarios[key]
+		if !ok {
+			continue
+		}
+		scenario := order[i]
+		atomic.StoreUint64(&scenario.served, state.Served)
+		atomic.StoreUint64(&scenario.requests, state.Requests)
+		atomic.StoreUint64(&scenario.serves, state.Serves)
+		// A changed response.dir keeps what still lines up
+		for j, rotation := range scenarioRotations(scenario) {
+			if j < len(state.Rotations) {
+				atomic.StoreUint64(&rotation.served, state.Rotations[j])
+			}
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+// ScenarioStatePersister keeps a scenario state file up to date.
+type ScenarioStatePersister struct {
+	store *MockStorage
+	path  string
+	done  chan struct{}
+	wg    sync.WaitGroup
+
+	mu   sync.Mutex
+	last []byte // Last state written, so unchanged state is not rewritten
+}
+
+// PersistScenarioState checks the scenario state every interval and writes it
+// to path when it changed, so a restarted server resumes mid-flow with
+// RestoreScenarioState. Errors are passed to onError. Close writes the final
+// state.
+func (s *MockStorage) PersistScenarioState(path string, interval time.Duration, onError func(error)) *ScenarioStatePersister {
+	p := &ScenarioStatePersister{store: s, path: path, done: make(chan struct{})}
+	p.last, _ = os.ReadFile(path)
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+				if err := p.flush(); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+	return p
+}
+
+// flush writes the state if it changed since the last write.
+func (p *ScenarioStatePersister) flush() error {
+	data, err := p.store.scenarioStateJSON()
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if bytes.Equal(data, p.last) {
+		return nil
+	}
+	if err := writeFileAtomic(p.path, data); err != nil {
+		return err
+	}
+	p.last = data
+	return nil
+}
+
+// Close stops the periodic writes and writes the final state.
+func (p *ScenarioStatePersister) Close() error {
+	close(p.done)
+	p.wg.Wait()
+	return p.flush()
+}
+
+// writeFileAtomic replaces path with data through a temporary file in the same directory.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
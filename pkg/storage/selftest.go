@@ -0,0 +1,62 @@
+package storage
+
+import "sort"
+
+// SelfTestTarget is one response the startup self-test serves.
+type SelfTestTarget struct {
+	Scenario string        // Scenario serving the response; empty for x-mock-id mocks
+	Response *MockResponse // Concrete response: sequence steps, A/B variants and response.dir recordings are listed one by one
+}
+
+// SelfTestTargets lists every response that can be served: each step of the
+// scenario sequences, both variants of experiments and every recording of a
+// response.dir, or all loaded mocks when no scenarios are configured.
+func (s *MockStorage) SelfTestTargets() []SelfTestTarget {
+	var targets []SelfTestTarget
+	add := func(scenario string, resp *MockResponse) {
+		var variants []*MockResponse
+		switch {
+		case resp.Experiment != nil:
+			variants = []*MockResponse{resp.Experiment.A, resp.Experiment.B}
+		case resp.Rotation != nil:
+			variants = resp.Rotation.Responses
+		default:
+			variants = []*MockResponse{resp}
+		}
+		for _, variant := range variants {
+			targets = append(targets, SelfTestTarget{Scenario: scenario, Response: variant})
+		}
+	}
+
+	if s.scenariosEnabled {
+		for _, scenario := range s.scenarioOrder {
+			switch {
+			case scenario.sequence != nil:
+				for _, resp := range scenario.sequence {
+					add(scenario.name, resp)
+				}
+			case scenario.response != nil:
+				add(scenario.name, scenario.response)
+			}
+		}
+		return targets
+	}
+
+	for _, resp := range s.ListAllMocks() {
+		add("", resp)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		a, b := targets[i].Response, targets[j].Response
+		if a.MockID != b.MockID {
+			return a.MockID < b.MockID
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.RequestID < b.RequestID
+	})
+	return targets
+}
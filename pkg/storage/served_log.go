@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ServedLogger writes one JSON file per served request describing the response
+// that actually went out, after templating and header overrides. Files are
+// numbered in serving order so a test run leaves an auditable trail.
+type ServedLogger struct {
+	baseDir  string
+	sequence int64
+}
+
+// NewServedLogger creates a logger that writes to the specified directory.
+func NewServedLogger(baseDir string) (*ServedLogger, error) {
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return nil, err
+	}
+	return &ServedLogger{baseDir: baseDir}, nil
+}
+
+// SetServedLog enables recording of served responses into dir.
+func (s *MockStorage) SetServedLog(dir string) error {
+	logger, err := NewServedLogger(dir)
+	if err != nil {
+		return err
+	}
+	s.servedLog = logger
+	return nil
+}
+
+// ServedLog returns the served response logger, or nil when it is disabled.
+func (s *MockStorage) ServedLog() *ServedLogger {
+	return s.servedLog
+}
+
+// LogServed records the request and the final response. It must run before the
+// response is written, once the handler has finished shaping it. resp is the
+// matched mock, or nil when nothing matched.
+func (l *ServedLogger) LogServed(ctx *fasthttp.RequestCtx, resp *MockResponse) error {
+	sequence := atomic.AddInt64(&l.sequence, 1)
+
+	reqHeaders := make(map[string]string)
+	ctx.Request.Header.VisitAll(func(key, value []byte) {
+		reqHeaders[string(key)] = string(value)
+	})
+
+	respHeaders := make(map[string]string)
+	ctx.Response.Header.VisitAll(func(key, value []byte) {
+		respHeaders[string(key)] = string(value)
+	})
+
+	response := map[string]interface{}{
+		"status_code": ctx.Response.StatusCode(),
+		"headers":     respHeaders,
+	}
+
+	// Streamed and hijacked SSE responses have no buffered body; the recorded
+	// events are what the stream replays
+	if ctx.Response.IsBodyStream() || ctx.Hijacked() {
+		response["streamed"] = true
+		if resp != nil {
+			response["body"] = string(resp.Body)
+		}
+	} else {
+		response["body"] = decodeLoggedBody(ctx.Response.Body())
+	}
+
+	if resp != nil {
+		response["mock_id"] = resp.MockID
+		response["mock_request_id"] = resp.RequestID
+	}
+
+	record := map[string]interface{}{
+		"sequence":  sequence,
+		"timestamp": time.Now().UTC().Format(time.RFC3339Nano),
+		"request": map[string]interface{}{
+			"method":  string(ctx.Method()),
+			"url":     string(ctx.RequestURI()),
+			"headers": reqHeaders,
+			"body":    decodeLoggedBody(ctx.PostBody()),
+		},
+		"response": response,
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("%06d_%s.json", sequence, sanitizeContentType(string(ctx.Response.Header.ContentType())))
+	return os.WriteFile(filepath.Join(l.baseDir, filename), data, 0644)
+}
+
+// decodeLoggedBody returns parsed JSON when possible, otherwise the raw text.
+func decodeLoggedBody(body []byte) interface{} {
+	if len(body) == 0 {
+		return ""
+	}
+	var parsed interface{}
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		return parsed
+	}
+	return string(body)
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"fmt"
+	"net/textproto"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+)
+
+// sessionVarsKey holds the session variables of a request among its user values.
+const sessionVarsKey = "__mock_session_vars"
+
+// scenarioSessionDefinition is the sessions: section of a scenario config,
+// naming what tells the sessions of captured variables apart.
+type scenarioSessionDefinition struct {
+	Header string `yaml:"header"` // Request header carrying the session key
+	Cookie string `yaml:"cookie"` // Cookie carrying the session key
+}
+
+// sessionCapture is one entry of a scenario's capture: map.
+type sessionCapture struct {
+	name  string
+	value *Template
+}
+
+// sessionStore holds the variables captured by scenarios, per session.
+// Requests without the session header or cookie share one session.
+type sessionStore struct {
+	header string
+	cookie string
+
+	mu   sync.Mutex
+	vars map[string]map[string]string // Session key -> variable -> value
+}
+
+// newSessionStore validates the sessions: section of a scenario config.
+func newSessionStore(def scenarioSessionDefinition) (*sessionStore, error) {
+	header, cookie := strings.TrimSpace(def.Header), strings.TrimSpace(def.Cookie)
+	if header != "" && cookie != "" {
+		return nil, fmt.Errorf("sessions: set header or cookie, not both")
+	}
+	return &sessionStore{
+		header: textproto.CanonicalMIMEHeaderKey(header),
+		cookie: cookie,
+		vars:   make(map[string]map[string]string),
+	}, nil
+}
+
+// buildSessionCaptures compiles the capture: map of a scenario. Values are
+// request references such as request.body.order.id, or templates.
+func buildSessionCaptures(defs map[string]string) ([]sessionCapture, error) {
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	captures := make([]sessionCapture, 0, len(defs))
+	for _, name := range names {
+		if !isEnvName(name) {
+			return nil, fmt.Errorf("capture %q: names are letters, digits and underscores", name)
+		}
+		source := strings.TrimSpace(defs[name])
+		if source == "" {
+			return nil, fmt.Errorf("capture %s is empty", name)
+		}
+		if !strings.Contains(source, "{{") {
+			source = "{{" + source + "}}"
+		}
+		value, err := CompileTemplate(source)
+		if err != nil {
+			return nil, fmt.Errorf("capture %s: %w", name, err)
+		}
+		captures = append(captures, sessionCapture{name: name, value: value})
+	}
+	return captures, nil
+}
+
+// key returns the session of the request.
+func (st *sessionStore) key(ctx *fasthttp.RequestCtx) string {
+	if st.header != "" {
+		return string(ctx.Request.Header.Peek(st.header))
+	}
+	if st.cookie != "" {
+		return string(ctx.Request.Header.Cookie(st.cookie))
+	}
+	return ""
+}
+
+// BindSession stores the variables the scenario answering with mockResponse
+// captures from the request in the request's session, then makes the session
+// available to {{session.name}} placeholders. Captures that resolve to
+// nothing keep the previous value. It does nothing unless a scenario of the
+// config captures variables.
+func (s *MockStorage) BindSession(ctx *fasthttp.RequestCtx, mockResponse *MockResponse) {
+	s.mutex.RLock()
+	sessions := s.sessions
+	s.mutex.RUnlock()
+	if sessions == nil {
+		return
+	}
+
+	key := sessions.key(ctx)
+	captured := make(map[string]string, len(mockResponse.captures))
+	for _, capture := range mockResponse.captures {
+		if value := capture.value.RenderString(ctx); value != "" {
+			captured[capture.name] = value
+		}
+	}
+
+	sessions.mu.Lock()
+	vars := sessions.vars[key]
+	if len(captured) > 0 {
+		if vars == nil {
+			vars = make(map[string]string, len(captured))
+			sessions.vars[key] = vars
+		}
+		for name, value := range captured {
+			vars[name] = value
+		}
+	}
+	// The request renders from a copy, so later captures cannot race with it
+	snapshot := make(map[string]string, len(vars))
+	for name, value := range vars {
+		snapshot[name] = value
+	}
+	sessions.mu.Unlock()
+
+	ctx.SetUserValue(sessionVarsKey, snapshot)
+}
+
+// sessionVar returns a variable of the session bound by BindSession.
+func sessionVar(ctx *fasthttp.RequestCtx, name string) string {
+	vars, _ := ctx.UserValue(sessionVarsKey).(map[string]string)
+	return vars[name]
+}
+
+// snapshot returns a copy of every captured variable, or nil when none was.
+func (st *sessionStore) snapshot() map[string]map[string]string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if len(st.vars) == 0 {
+		return nil
+	}
+	sessions := make(map[string]map[string]string, len(st.vars))
+	for key, vars := range st.vars {
+		copied := make(map[string]string, len(vars))
+		for name, value := range vars {
+			copied[name] = value
+		}
+		sessions[key] = copied
+	}
+	return sessions
+}
+
+// restore replaces the captured variables with sessions.
+func (st *sessionStore) restore(sessions map[string]map[string]string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.vars = make(map[string]map[string]string, len(sessions))
+	for key, vars := range sessions {
+		copied := make(map[string]string, len(vars))
+		for name, value := range vars {
+			copied[name] = value
+		}
+		st.vars[key] = copied
+	}
+}
+
+// reset forgets every captured variable.
+func (st *sessionStore) reset() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.vars = make(map[string]map[string]string)
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseSize parses a number with an optional B/KB/MB/GB suffix (binary multiples),
+// e.g. "512", "64KB" or "1.5MB".
+func ParseSize(value string) (float64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := 1.0
+	for _, unit := range []struct {
+		suffix string
+		factor float64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(upper, unit.suffix) {
+			upper = strings.TrimSpace(strings.TrimSuffix(upper, unit.suffix))
+			multiplier = unit.factor
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(upper, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", value)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"sync"
+)
+
+// sseInboxSize bounds how many injected events may queue up for a slow stream.
+const sseInboxSize = 64
+
+// SSEHub tracks connected mocked SSE streams by mock ID so ad-hoc events can be
+// pushed into them while they are open.
+type SSEHub struct {
+	mutex   sync.Mutex
+	streams map[string]map[chan []byte]struct{}
+	closed  bool
+}
+
+// NewSSEHub creates an empty hub.
+func NewSSEHub() *SSEHub {
+	return &SSEHub{streams: make(map[string]map[chan []byte]struct{})}
+}
+
+// Subscribe registers a connected stream and returns its inbox of complete SSE frames.
+// The inbox is closed when the hub shuts down. It returns nil after Close.
+func (h *SSEHub) Subscribe(stream string) chan []byte {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.closed {
+		return nil
+	}
+
+	inbox := make(chan []byte, sseInboxSize)
+	subscribers := h.streams[stream]
+	if subscribers == nil {
+		subscribers = make(map[chan []byte]struct{})
+		h.streams[stream] = subscribers
+	}
+	subscribers[inbox] = struct{}{}
+	return inbox
+}
+
+// Unsubscribe removes a stream registered with Subscribe.
+func (h *SSEHub) Unsubscribe(stream string, inbox chan []byte) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	subscribers := h.streams[stream]
+	if _, ok := subscribers[inbox]; !ok {
+		return
+	}
+	delete(subscribers, inbox)
+	if len(subscribers) == 0 {
+		delete(h.streams, stream)
+	}
+}
+
+// Emit queues frame on every stream connected under the given mock ID and returns
+// how many streams accepted it. Streams with a full inbox are skipped.
+func (h *SSEHub) Emit(stream string, frame []byte) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delivered := 0
+	for inbox := range h.streams[stream] {
+		select {
+		case inbox <- frame:
+			delivered++
+		default:
+		}
+	}
+	return delivered
+}
+
+// Connected returns the number of open streams for a mock ID.
+func (h *SSEHub) Connected(stream string) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.streams[stream])
+}
+
+// Close ends all connected streams and rejects new subscriptions.
+// Held-open streams would otherwise block a graceful server shutdown.
+func (h *SSEHub) Close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for stream, subscribers := range h.streams {
+		for inbox := range subscribers {
+			close(inbox)
+		}
+		delete(h.streams, stream)
+	}
+}
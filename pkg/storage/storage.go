@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"os"
-	"strings"
+	"path/filepath"
 	"sync"
 )
 
@@ -19,23 +19,61 @@ var keyBufPool = sync.Pool{
 
 var errInvalidRecord = errors.New("invalid mock record")
 
+var (
+	methodGET  = []byte("GET")
+	methodHEAD = []byte("HEAD")
+
+	webSocketContentTypeBytes = []byte(WebSocketContentType)
+	grpcContentTypeBytes      = []byte(GRPCContentType)
+)
+
 // MockResponse represents a stored mock response with pre-serialized body.
 type MockResponse struct {
-	RequestID       string            `json:"request_id"`
-	Path            string            `json:"path"`
-	Method          string            `json:"method"`
-	MethodBytes     []byte            `json:"-"` // Pre-computed method as bytes to avoid allocation
-	MockID          string            `json:"mock_id"`
-	ContentType     string            `json:"content_type"`
-	StatusCode      int               `json:"status_code"`
-	Headers         map[string]string `json:"headers"`
-	HeaderKeysLower map[string]string `json:"-"` // Pre-computed lowercase keys for fast lookup
-	Body            []byte            // Pre-serialized body ready to send
-	OriginalBody    interface{}       `json:"-"` // Keep for listing endpoints
-	FullURL         string            `json:"full_url"`
-	Delay           float64           `json:"delay"` // Total request duration
-	SSEEvents       []SSEEvent        `json:"-"`     // SSE events with timestamps
-	IsSSE           bool              `json:"-"`     // Whether this is SSE response
+	RequestID       string               `json:"request_id"`
+	Path            string               `json:"path"`
+	Method          string               `json:"method"`
+	MethodBytes     []byte               `json:"-"` // Pre-computed method as bytes to avoid allocation
+	MockID          string               `json:"mock_id"`
+	ContentType     string               `json:"content_type"`
+	StatusCode      int                  `json:"status_code"`
+	Headers         map[string]string    `json:"headers"`
+	HeaderKeysLower map[string]string    `json:"-"` // Pre-computed lowercase keys for fast lookup
+	Body            []byte               // Pre-serialized body ready to send
+	OriginalBody    interface{}          `json:"-"` // Keep for listing endpoints
+	RequestBody     interface{}          `json:"-"` // Recorded request body, for converters
+	FullURL         string               `json:"full_url"`
+	Note            string               `json:"note"`  // Annotation recorded from the x-mock-note header
+	Delay           float64              `json:"delay"` // Total request duration
+	SSEEvents       []SSEEvent           `json:"-"`     // SSE events with timestamps
+	IsSSE           bool                 `json:"-"`     // Whether this is SSE response
+	Limiter         *ConcurrencyLimiter  `json:"-"`     // Optional per-mock concurrency limit
+	BodyTemplate    *Template            `json:"-"`     // Set when the body contains placeholders to render per request
+	HeaderTemplates map[string]*Template `json:"-"`     // Header key -> template for headers rendered per request
+	SSEAbort        *SSEAbort            `json:"-"`     // Optional fault that cuts the SSE stream early
+	SSEKeepOpen     bool                 `json:"-"`     // Keep the SSE stream open for injected events after replay
+	Fault           *ResponseFault       `json:"-"`     // Optional fault breaking the response on purpose
+	Jitter          *float64             `json:"-"`     // Optional per-response override of the -jitter fraction
+	Experiment      *Experiment          `json:"-"`     // Set on variant A of an A/B scenario; selects the variant per client
+	WebSocketFrames []WebSocketFrame     `json:"-"`     // Recorded frames of a WebSocket conversation
+	IsWebSocket     bool                 `json:"-"`     // Whether this is a WebSocket record
+	GRPCMessages    []GRPCMessage        `json:"-"`     // Recorded response messages of a gRPC call
+	GRPCTrailers    map[string]string    `json:"-"`     // gRPC trailers (lowercase keys), always with grpc-status
+	IsGRPC          bool                 `json:"-"`     // Whether this is a gRPC record
+	Rotation        *ResponseRotation    `json:"-"`     // Set on the first recording of a response.dir; picks one per request
+	SourceFile      string               `json:"-"`     // Recording the response was loaded from, for reloading and debug headers
+	Revalidates     string               `json:"-"`     // On a recorded 304: request ID of the full response it revalidated
+	NotModified     *MockResponse        `json:"-"`     // Recorded 304 answering conditional requests for this response
+
+	adminRecord  []byte           // Native JSON of an admin mock, for ExportAdminMocks and Reset
+	pathTemplate *pathTemplate    // Set when Path has {params} or wildcards
+	captures     []sessionCapture // Session variables the scenario captures from matched requests
+}
+
+// SSEAbort describes where an SSE stream is cut off to simulate a dropped connection.
+type SSEAbort struct {
+	AfterEvents  int     // Number of events sent before the cut; -1 when not limited by count
+	AfterSeconds float64 // Stream time after which the connection is cut; 0 when not limited by time
+	BrokenChunk  bool    // Send half of the next event frame before closing
 }
 
 // SSEEvent represents a single SSE event with timestamp
@@ -43,6 +81,30 @@ type SSEEvent struct {
 	Data           interface{} `json:"data"`
 	Timestamp      float64     `json:"timestamp"`
 	SerializedData []byte      `json:"-"` // Pre-serialized data for performance
+	Frame          []byte      `json:"-"` // Complete wire frame ("data: ...\n\n"), one data line per payload line
+}
+
+// AppendSSEFrame appends data as a complete SSE event frame. Payloads containing
+// newlines are split into multiple data lines so clients reassemble them intact.
+func AppendSSEFrame(dst, data []byte) []byte {
+	for {
+		idx := bytes.IndexByte(data, '\n')
+		dst = append(dst, "data: "...)
+		if idx < 0 {
+			dst = append(dst, data...)
+			break
+		}
+		dst = append(dst, data[:idx]...)
+		dst = append(dst, '\n')
+		data = data[idx+1:]
+	}
+	return append(dst, "\n\n"...)
+}
+
+// LoadFailure records a mock file that was skipped while loading.
+type LoadFailure struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
 }
 
 // IndexKey is the key for indexing responses using string concatenation.
@@ -111,20 +173,102 @@ type MockStorage struct {
 	Responses map[IndexKey][]*MockResponse
 	// ResponsesByPathMockID is indexed by "path|mockID" for Accept: */* lookups
 	ResponsesByPathMockID map[IndexKey][]*MockResponse
-	cachedStats           []byte // Pre-serialized stats JSON
-	cachedMockList        []byte // Pre-serialized mock list JSON
+	cachedErrors          []byte // Pre-serialized load failures JSON
 
 	// Timing configuration
 	ReplayTiming bool
 	Jitter       float64
+	SSEGapJitter float64 // Per inter-event gap jitter for SSE replay (0.2 = ±20% per gap)
+
+	// MethodOverride honors X-HTTP-Method-Override on POST requests when matching
+	MethodOverride bool
+
+	// CanonicalJSON canonicalizes JSON request bodies before scenario filters run
+	CanonicalJSON bool
+
+	// MockIDPrefix serves mocks under /<mock-id>/original/path when no x-mock-id header is sent
+	MockIDPrefix bool
+
+	// CompressionParity re-encodes bodies with the Content-Encoding recorded from the upstream
+	CompressionParity bool
+
+	// DebugHeaders adds x-mock-matched-id, x-mock-matched-file and x-mock-scenario to served responses
+	DebugHeaders bool
+
+	// Debug lists the closest mocks in the body of every 404, not only when x-mock-debug asks for it
+	Debug bool
+
+	// ContentTypeFallback answers with a same path and method recording of any
+	// content type when none was recorded for the requested one
+	ContentTypeFallback bool
+
+	// RespondWithHeader answers requests carrying x-mock-respond-with with the
+	// inline response it holds, without matching a mock
+	RespondWithHeader bool
+
+	// HeaderFidelity decides whether Date, Server and body framing follow the recording
+	HeaderFidelity HeaderFidelity
+
+	// Connected SSE streams that accept injected events
+	sseHub *SSEHub
+
+	// Unmatched request counts, only tracked in strict mode
+	unmatched *UnmatchedTracker
+
+	// Record of served responses, only written when enabled
+	servedLog *ServedLogger
+
+	// Log of matched requests in the recorder format, only written when enabled
+	matchLog *MatchLogger
+
+	// Custom answer for requests without a mock (nil = default JSON 404)
+	notFound *NotFoundResponse
+
+	// Checks requests before matching (nil = no validation)
+	requestValidator RequestValidator
+
+	// Mocks registered through the admin API by ID, and whether they are written to BaseDir
+	adminMocks   map[string]*MockResponse
+	persistAdmin bool
+
+	// Persisted admin mocks loaded from BaseDir by ID, which Reset restores
+	loadedAdminMocks map[string]*MockResponse
+
+	// Index keys with templated paths, most specific first, rebuilt by cacheResponses
+	pathTemplates []templatedKey
+
+	// Mock files that could not be loaded
+	failedFiles []LoadFailure
+
+	// Recordings answering the same request differently, and which one wins
+	conflictPolicy ConflictPolicy
+	conflicts      []Conflict
+
+	// Live request counts, and the stats computed when the mocks last changed
+	counters  requestCounters
+	statsBase map[string]interface{}
+
+	// Injected faults, delays and jitter, for /__mock__/metrics
+	metrics chaosMetrics
+
+	// Chaos mode, and its per-path overrides from the scenario config
+	chaos      ChaosConfig
+	chaosRules []chaosRule
 
 	// Reusable buffer for key building to avoid allocations
 	keyBuf []byte
 
+	// Guards the indexes and cached JSON when recordings are added at runtime
+	mutex sync.RWMutex
+
 	// Scenario configuration (when enabled)
-	scenariosEnabled bool
-	scenarioByPath   map[string][]*mockScenario
-	scenarioOrder    []*mockScenario
+	scenariosEnabled  bool
+	scenarioConfig    string   // Path of the loaded scenario config, for Reload
+	scenarioTags      []string // Tags selecting the tagged scenarios to load; nil loads all
+	scenarioByPath    map[string][]*mockScenario
+	scenarioOrder     []*mockScenario
+	scenarioTemplates []*mockScenario // Scenarios with templated paths, most specific first
+	sessions          *sessionStore   // Variables captured by scenarios; nil when none captures
 }
 
 // SetTimingConfig configures timing replay behavior
@@ -133,13 +277,85 @@ func (s *MockStorage) SetTimingConfig(replayTiming bool, jitter float64) {
 	s.Jitter = jitter
 }
 
+// SetSSEGapJitter configures independent jitter for each gap between replayed SSE events.
+func (s *MockStorage) SetSSEGapJitter(jitter float64) {
+	s.SSEGapJitter = jitter
+}
+
+// SetMethodOverride enables matching by the X-HTTP-Method-Override header.
+func (s *MockStorage) SetMethodOverride(enabled bool) {
+	s.MethodOverride = enabled
+}
+
+// SetCanonicalJSON enables JSON body canonicalization before filter evaluation.
+func (s *MockStorage) SetCanonicalJSON(enabled bool) {
+	s.CanonicalJSON = enabled
+}
+
+// SetMockIDPrefix enables addressing mocks by a leading /<mock-id> path segment.
+func (s *MockStorage) SetMockIDPrefix(enabled bool) {
+	s.MockIDPrefix = enabled
+}
+
+// SetCompressionParity enables recompressing replayed bodies with their recorded Content-Encoding.
+func (s *MockStorage) SetCompressionParity(enabled bool) {
+	s.CompressionParity = enabled
+}
+
+// SetContentTypeFallback enables falling back to recordings of any content type in FindResponseBytes.
+func (s *MockStorage) SetContentTypeFallback(enabled bool) {
+	s.ContentTypeFallback = enabled
+}
+
+// SetDebugHeaders enables response headers naming the recording and scenario that answered.
+func (s *MockStorage) SetDebugHeaders(enabled bool) {
+	s.DebugHeaders = enabled
+}
+
+// SetRespondWithHeader enables inline responses from the x-mock-respond-with header.
+func (s *MockStorage) SetRespondWithHeader(enabled bool) {
+	s.RespondWithHeader = enabled
+}
+
+// SetDebug enables closest-match diagnostics on every 404.
+func (s *MockStorage) SetDebug(enabled bool) {
+	s.Debug = enabled
+}
+
+// SetScenarioTags limits the tagged scenarios loaded by LoadScenarioConfig
+// and Reload to those carrying one of tags; untagged scenarios always load.
+// Call it before LoadScenarioConfig.
+func (s *MockStorage) SetScenarioTags(tags []string) {
+	s.scenarioTags = tags
+}
+
+// FailedFiles returns the mock files that were skipped because they could not be loaded.
+func (s *MockStorage) FailedFiles() []LoadFailure {
+	return s.failedFiles
+}
+
+// SetStrict enables counting of unmatched requests for a strict replay summary.
+func (s *MockStorage) SetStrict(enabled bool) {
+	if enabled {
+		s.unmatched = NewUnmatchedTracker()
+	} else {
+		s.unmatched = nil
+	}
+}
+
+// Unmatched returns the unmatched request tracker, or nil when strict mode is off.
+func (s *MockStorage) Unmatched() *UnmatchedTracker {
+	return s.unmatched
+}
+
+// SSEHub returns the registry of connected SSE streams used for event injection.
+func (s *MockStorage) SSEHub() *SSEHub {
+	return s.sseHub
+}
+
 // NewMockStorage creates a new MockStorage instance.
 func NewMockStorage(baseDir string) (*MockStorage, error) {
-	storage := &MockStorage{
-		BaseDir:               baseDir,
-		Responses:             make(map[IndexKey][]*MockResponse),
-		ResponsesByPathMockID: make(map[IndexKey][]*MockResponse),
-	}
+	storage := newEmptyStorage(baseDir)
 
 	if err := storage.loadResponses(); err != nil {
 		return nil, err
@@ -148,19 +364,49 @@ func NewMockStorage(baseDir string) (*MockStorage, error) {
 	return storage, nil
 }
 
+// newEmptyStorage creates a storage with initialized indexes and nothing loaded.
+func newEmptyStorage(baseDir string) *MockStorage {
+	return &MockStorage{
+		BaseDir:               baseDir,
+		Responses:             make(map[IndexKey][]*MockResponse),
+		ResponsesByPathMockID: make(map[IndexKey][]*MockResponse),
+		sseHub:                NewSSEHub(),
+		HeaderFidelity:        DefaultHeaderFidelity,
+	}
+}
+
 // loadResponses loads responses from JSON files in the directory structure.
 func (s *MockStorage) loadResponses() error {
+	responses, err := s.readResponseFiles()
+	if err != nil {
+		return err
+	}
+
+	for _, mockResponse := range linkRevalidations(responses) {
+		s.indexResponse(mockResponse)
+	}
+
+	// Pre-serialize stats and mock list for fast serving
+	s.cacheResponses()
+
+	return nil
+}
+
+// readResponseFiles parses every recording under BaseDir in directory order.
+// Files that fail to parse are remembered in failedFiles and skipped.
+func (s *MockStorage) readResponseFiles() ([]*MockResponse, error) {
 	// Check if directory exists
 	if _, err := os.Stat(s.BaseDir); os.IsNotExist(err) {
-		return nil // Directory doesn't exist, that's ok
+		return nil, nil // Directory doesn't exist, that's ok
 	}
 
 	// Walk through all mock_id subdirectories
 	entries, err := os.ReadDir(s.BaseDir)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	var responses []*MockResponse
 	for _, entry := range entries {
 		if !entry.IsDir() {
 			continue // Skip non-directories
@@ -169,65 +415,110 @@ func (s *MockStorage) loadResponses() error {
 		folderMockID := entry.Name()
 		mockDir := s.BaseDir + "/" + folderMockID
 
-		// Read all JSON files in this mock_id directory
+		// Read all recordings (JSON or HAR) in this mock_id directory
 		files, err := os.ReadDir(mockDir)
 		if err != nil {
-			continue // Skip if can't read directory
+			// Skip if can't read directory, but remember why
+			s.failedFiles = append(s.failedFiles, LoadFailure{File: mockDir, Error: err.Error()})
+			continue
 		}
 
 		for _, file := range files {
-			if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			if file.IsDir() || !isRecordingFile(file.Name()) {
 				continue
 			}
 
 			filePath := mockDir + "/" + file.Name()
-			mockResponse, err := loadResponseFromFile(filePath, folderMockID)
+			loaded, err := loadResponsesFromFile(filePath, folderMockID)
 			if err != nil {
+				s.failedFiles = append(s.failedFiles, LoadFailure{File: filePath, Error: err.Error()})
 				continue
 			}
+			for _, mockResponse := range loaded {
+				mockResponse.SourceFile = filepath.Clean(filePath)
+			}
+			responses = append(responses, loaded...)
+		}
+	}
 
-			// Index by full key (path|mockID|contentType)
-			key := makeIndexKey(mockResponse.Path, mockResponse.MockID, mockResponse.ContentType)
-			s.Responses[key] = append(s.Responses[key], mockResponse)
+	return responses, nil
+}
 
-			// Also index by path|mockID for Accept: */* lookups
-			pathMockIDKey := makePathMockIDKey(mockResponse.Path, mockResponse.MockID)
-			s.ResponsesByPathMockID[pathMockIDKey] = append(s.ResponsesByPathMockID[pathMockIDKey], mockResponse)
-		}
+// indexResponse adds a response to the lookup indexes.
+func (s *MockStorage) indexResponse(mockResponse *MockResponse) {
+	// Paths such as /users/{id} match by template; invalid ones stay literal
+	mockResponse.pathTemplate, _ = parsePathTemplate(mockResponse.Path)
+
+	// Index by full key (path|mockID|contentType)
+	key := makeIndexKey(mockResponse.Path, mockResponse.MockID, mockResponse.ContentType)
+	s.Responses[key] = append(s.Responses[key], mockResponse)
+
+	// Also index by path|mockID for Accept: */* lookups
+	pathMockIDKey := makePathMockIDKey(mockResponse.Path, mockResponse.MockID)
+	s.ResponsesByPathMockID[pathMockIDKey] = append(s.ResponsesByPathMockID[pathMockIDKey], mockResponse)
+}
+
+// AddResponseFile loads a single recording and makes it servable immediately.
+// It is used in hybrid mode, where requests without a mock are proxied and the
+// resulting recording must be replayed on the next identical request.
+func (s *MockStorage) AddResponseFile(filePath, mockID string) error {
+	responses, err := loadResponsesFromFile(filePath, mockID)
+	if err != nil {
+		return err
 	}
 
-	// Pre-serialize stats and mock list for fast serving
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// A watched mock dir may have indexed the file already
+	s.unindexFile(filepath.Clean(filePath))
+	for _, mockResponse := range responses {
+		mockResponse.SourceFile = filepath.Clean(filePath)
+		if !s.attachRevalidation(mockResponse) {
+			s.indexResponse(mockResponse)
+		}
+	}
 	s.cacheResponses()
+	return nil
+}
+
+// AddRecord indexes a recording in the native JSON format ({"request": ...,
+// "response": ...}) without reading it from disk, for mocks registered in code.
+// mockID applies unless the recorded request carries an x-mock-id header.
+func (s *MockStorage) AddRecord(data []byte, mockID string) error {
+	mockResponse, err := parseMockRecord(data, mockID)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
+	s.indexResponse(mockResponse)
+	s.cacheResponses()
 	return nil
 }
 
-// cacheResponses pre-serializes stats and mock list to avoid marshaling on each request.
+// cacheResponses refreshes what is derived from the indexes: conflicts, path
+// templates, the load-time stats and the pre-serialized load failures.
 func (s *MockStorage) cacheResponses() {
-	if s.scenariosEnabled {
-		stats := s.computeScenarioStats()
-		if data, err := json.Marshal(stats); err == nil {
-			s.cachedStats = data
-		}
+	// Every index change ends here; keep the policy's pick first
+	s.conflicts = resolveConflicts(s.Responses, s.conflictPolicy, s.adminMocks)
+	s.pathTemplates = indexPathTemplates(s.Responses)
+	s.scenarioTemplates = templatedScenarios(s.scenarioOrder)
 
-		mocks := s.listScenarioMocks()
-		if data, err := json.Marshal(mocks); err == nil {
-			s.cachedMockList = data
-		}
-		return
+	if data, err := json.Marshal(s.listFailures()); err == nil {
+		s.cachedErrors = data
 	}
 
-	// Cache stats for legacy mock-id lookups
-	stats := s.computeStats()
-	if data, err := json.Marshal(stats); err == nil {
-		s.cachedStats = data
+	// The stats and mock list JSON are streamed from here on each request
+	if s.scenariosEnabled {
+		s.statsBase = s.computeScenarioStats()
+		return
 	}
 
-	// Cache mock list
-	mocks := s.listMocks()
-	if data, err := json.Marshal(mocks); err == nil {
-		s.cachedMockList = data
-	}
+	// Stats for legacy mock-id lookups
+	s.statsBase = s.computeStats()
 }
 
 // computeStats calculates statistics (internal version).
@@ -253,12 +544,14 @@ func (s *MockStorage) computeStats() map[string]interface{} {
 		paths = append(paths, path)
 	}
 
-	return map[string]interface{}{
+	return s.withFailureStats(map[string]interface{}{
 		"total_responses": total,
 		"unique_paths":    len(uniquePaths),
 		"unique_mock_ids": len(uniqueMockIDs),
 		"paths":           paths,
-	}
+		"conflicts_count": len(s.conflicts),
+		"conflicts":       s.conflicts,
+	})
 }
 
 func (s *MockStorage) computeScenarioStats() map[string]interface{} {
@@ -276,58 +569,34 @@ func (s *MockStorage) computeScenarioStats() map[string]interface{} {
 		paths = append(paths, path)
 	}
 
-	return map[string]interface{}{
+	return s.withFailureStats(map[string]interface{}{
 		"total_responses": total,
 		"unique_paths":    len(uniquePaths),
 		"unique_mock_ids": len(uniqueMockIDs),
 		"paths":           paths,
-	}
+	})
 }
 
-// listMocks creates mock list (internal version).
-func (s *MockStorage) listMocks() map[string]interface{} {
-	allResponses := []*MockResponse{}
-	for _, responses := range s.Responses {
-		allResponses = append(allResponses, responses...)
-	}
-
-	mockList := make([]map[string]interface{}, 0, len(allResponses))
-	for _, m := range allResponses {
-		mockList = append(mockList, map[string]interface{}{
-			"request_id":   m.RequestID,
-			"path":         m.Path,
-			"method":       m.Method,
-			"mock_id":      m.MockID,
-			"content_type": m.ContentType,
-			"status_code":  m.StatusCode,
-			"full_url":     m.FullURL,
-		})
-	}
-
-	return map[string]interface{}{
-		"mocks": mockList,
-		"total": len(mockList),
+// withFailureStats adds the count and names of mock files that failed to load.
+func (s *MockStorage) withFailureStats(stats map[string]interface{}) map[string]interface{} {
+	names := make([]string, 0, len(s.failedFiles))
+	for _, failure := range s.failedFiles {
+		names = append(names, failure.File)
 	}
+	stats["failed_files_count"] = len(s.failedFiles)
+	stats["failed_files"] = names
+	return stats
 }
 
-func (s *MockStorage) listScenarioMocks() map[string]interface{} {
-	mockList := make([]map[string]interface{}, 0, len(s.scenarioOrder))
-	for _, scenario := range s.scenarioOrder {
-		resp := scenario.response
-		mockList = append(mockList, map[string]interface{}{
-			"request_id":   resp.RequestID,
-			"path":         resp.Path,
-			"method":       resp.Method,
-			"mock_id":      resp.MockID,
-			"content_type": resp.ContentType,
-			"status_code":  resp.StatusCode,
-			"full_url":     resp.FullURL,
-		})
+// listFailures creates the load failure report served by /__mock__/errors.
+func (s *MockStorage) listFailures() map[string]interface{} {
+	failures := s.failedFiles
+	if failures == nil {
+		failures = []LoadFailure{}
 	}
-
 	return map[string]interface{}{
-		"mocks": mockList,
-		"total": len(mockList),
+		"errors": failures,
+		"total":  len(failures),
 	}
 }
 
@@ -362,8 +631,30 @@ func (s *MockStorage) FindResponseBytes(pathBytes, mockIDBytes, contentTypeBytes
 	// Build key from []byte - single allocation for the key string
 	key := makeIndexKeyFromBytes(pathBytes, mockIDBytes, contentTypeBytes)
 
-	candidates, ok := s.Responses[key]
-	if !ok || len(candidates) == 0 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if resp := pickByMethod(s.Responses[key], methodBytes); resp != nil {
+		return resp
+	}
+
+	// Exact paths win; templated ones such as /users/{id} are tried next
+	if resp := s.findTemplated(pathBytes, mockIDBytes, contentTypeBytes, methodBytes); resp != nil {
+		return resp
+	}
+
+	// An unusual Accept value still gets the recording of another content type.
+	// WebSocket and gRPC lookups never fall back to plain HTTP recordings.
+	if s.ContentTypeFallback && !bytes.Equal(contentTypeBytes, webSocketContentTypeBytes) && !bytes.Equal(contentTypeBytes, grpcContentTypeBytes) {
+		return s.findAnyContentType(pathBytes, mockIDBytes, methodBytes)
+	}
+	return nil
+}
+
+// pickByMethod returns the candidate recorded for the method, or the GET
+// recording for a HEAD request. Without a method the first candidate answers.
+func pickByMethod(candidates []*MockResponse, methodBytes []byte) *MockResponse {
+	if len(candidates) == 0 {
 		return nil
 	}
 
@@ -373,19 +664,36 @@ func (s *MockStorage) FindResponseBytes(pathBytes, mockIDBytes, contentTypeBytes
 	}
 
 	// Filter by method - use pre-computed MethodBytes to avoid allocation
+	var headFallback *MockResponse
 	for _, c := range candidates {
 		if equalFoldBytes(c.MethodBytes, methodBytes) {
 			return c
 		}
+		if headFallback == nil && answersHead(c, methodBytes) {
+			headFallback = c
+		}
 	}
 
-	return nil
+	return headFallback
+}
+
+// answersHead reports whether c can answer a HEAD request for which no HEAD was
+// recorded: the GET recording's headers are served without its body.
+func answersHead(c *MockResponse, methodBytes []byte) bool {
+	return equalFoldBytes(methodBytes, methodHEAD) && equalFoldBytes(c.MethodBytes, methodGET)
 }
 
 // FindResponseBytesAnyContentType finds a mock response by path and mock_id, accepting any content_type.
 // Returns the first matching response for the given method.
 // Zero-allocation implementation: parses key inline without string splits.
 func (s *MockStorage) FindResponseBytesAnyContentType(pathBytes, mockIDBytes, methodBytes []byte) *MockResponse {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.findAnyContentType(pathBytes, mockIDBytes, methodBytes)
+}
+
+// findAnyContentType is FindResponseBytesAnyContentType for callers holding the read lock.
+func (s *MockStorage) findAnyContentType(pathBytes, mockIDBytes, methodBytes []byte) *MockResponse {
 	// Build prefix for direct key matching: "path|mockID|"
 	// This allows us to check if any key starts with this prefix
 	bufPtr := keyBufPool.Get().(*[]byte)
@@ -399,6 +707,8 @@ func (s *MockStorage) FindResponseBytesAnyContentType(pathBytes, mockIDBytes, me
 	prefix := buf
 	prefixLen := len(prefix)
 
+	var headFallback *MockResponse
+
 	// Iterate through all responses to find keys with matching prefix
 	for key, candidates := range s.Responses {
 		if len(candidates) == 0 {
@@ -436,6 +746,9 @@ func (s *MockStorage) FindResponseBytesAnyContentType(pathBytes, mockIDBytes, me
 				keyBufPool.Put(bufPtr)
 				return c
 			}
+			if headFallback == nil && answersHead(c, methodBytes) {
+				headFallback = c
+			}
 		}
 
 		// If method didn't match but path/mockID did, try next content-type
@@ -443,7 +756,10 @@ func (s *MockStorage) FindResponseBytesAnyContentType(pathBytes, mockIDBytes, me
 	}
 
 	keyBufPool.Put(bufPtr)
-	return nil
+	if headFallback != nil {
+		return headFallback
+	}
+	return s.findTemplated(pathBytes, mockIDBytes, nil, methodBytes)
 }
 
 // FindResponse is kept for backwards compatibility (mainly for tests).
@@ -456,11 +772,16 @@ func (s *MockStorage) ListAllMocks() []*MockResponse {
 	if s.scenariosEnabled {
 		responses := make([]*MockResponse, 0, len(s.scenarioOrder))
 		for _, scenario := range s.scenarioOrder {
-			responses = append(responses, scenario.response)
+			if scenario.response != nil {
+				responses = append(responses, scenario.response)
+			}
 		}
 		return responses
 	}
 
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
 	allResponses := []*MockResponse{}
 	for _, responses := range s.Responses {
 		allResponses = append(allResponses, responses...)
@@ -468,22 +789,24 @@ func (s *MockStorage) ListAllMocks() []*MockResponse {
 	return allResponses
 }
 
-// GetStats returns pre-serialized statistics (for display purposes).
+// GetStats returns the current statistics, including request counts (for display purposes).
 func (s *MockStorage) GetStats() map[string]interface{} {
-	if s.scenariosEnabled {
-		return s.computeScenarioStats()
-	}
-	return s.computeStats()
+	return s.liveStats()
 }
 
-// GetStatsJSON returns pre-serialized JSON stats (for serving).
-func (s *MockStorage) GetStatsJSON() []byte {
-	return s.cachedStats
+// GetMockListJSON returns the mock list JSON served by /__mock__/list;
+// handlers stream it with WriteMockListJSON instead.
+func (s *MockStorage) GetMockListJSON() []byte {
+	var buf bytes.Buffer
+	s.WriteMockListJSON(&buf)
+	return buf.Bytes()
 }
 
-// GetMockListJSON returns pre-serialized JSON mock list (for serving).
-func (s *MockStorage) GetMockListJSON() []byte {
-	return s.cachedMockList
+// GetErrorsJSON returns pre-serialized JSON describing mock files that failed to load.
+func (s *MockStorage) GetErrorsJSON() []byte {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.cachedErrors
 }
 
 // toLowerASCIISimple converts ASCII string to lowercase.
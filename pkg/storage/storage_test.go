@@ -1,7 +1,24 @@
 package storage
 
 import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/andrey-viktorov/jsonfilter-go/serde"
+	"github.com/valyala/fasthttp"
 )
 
 func BenchmarkFindResponse(b *testing.B) {
@@ -335,3 +352,1710 @@ func BenchmarkFindResponseBytesAnyContentType(b *testing.B) {
 		}
 	}
 }
+
+func TestScenarioSequenceExhaustion(t *testing.T) {
+	store, err := NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-sequence.yml"); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+
+	serve := func(path string) *MockResponse {
+		resp := store.MatchScenarioResponse([]byte(path), []byte("GET"), []byte(""))
+		if resp == nil {
+			t.Fatalf("Expected scenario match for %s", path)
+		}
+		return resp
+	}
+
+	first := `{"data":2,"version":1}`
+	second := `{"data":4,"version":2}`
+
+	// repeat_last: the final element keeps being served
+	for i, expected := range []string{first, second, second, second} {
+		if body := string(serve("/api/poll").Body); body != expected {
+			t.Fatalf("repeat_last call %d: expected %s, got %s", i+1, expected, body)
+		}
+	}
+
+	// loop: the sequence starts over
+	for i, expected := range []string{first, second, first, second} {
+		if body := string(serve("/api/loop").Body); body != expected {
+			t.Fatalf("loop call %d: expected %s, got %s", i+1, expected, body)
+		}
+	}
+
+	// gone: 410 once the single element was served
+	if resp := serve("/api/once"); resp.StatusCode != 200 {
+		t.Fatalf("Expected first one-shot call to succeed, got %d", resp.StatusCode)
+	}
+	for i := 0; i < 2; i++ {
+		if resp := serve("/api/once"); resp.StatusCode != 410 {
+			t.Fatalf("Expected 410 after exhaustion, got %d", resp.StatusCode)
+		}
+	}
+
+	// not_found: 404 after the last element, until the scenarios are reset
+	for i, expected := range []int{200, 200, 404, 404} {
+		if resp := serve("/api/job"); resp.StatusCode != expected {
+			t.Fatalf("not_found call %d: expected %d, got %d", i+1, expected, resp.StatusCode)
+		}
+	}
+	if err := store.Reset(); err != nil {
+		t.Fatalf("Failed to reset: %v", err)
+	}
+	if body := string(serve("/api/job").Body); body != first {
+		t.Fatalf("Expected reset to restart the sequence, got %s", body)
+	}
+}
+
+func TestCompileTemplateErrors(t *testing.T) {
+	if tmpl, err := CompileTemplate("plain text"); tmpl != nil || err != nil {
+		t.Fatalf("Expected nil template for plain text, got %v, %v", tmpl, err)
+	}
+
+	for _, text := range []string{
+		"{{request.body.id",
+		"{{unknown}}",
+		"{{request.headers}}",
+		"{{request.cookies.session}}",
+		"{{nosuchfunc \"x\"}}",
+	} {
+		if _, err := CompileTemplate(text); err == nil {
+			t.Errorf("Expected compile error for %q", text)
+		}
+	}
+}
+
+func TestRegisterTemplateFunc(t *testing.T) {
+	err := RegisterTemplateFunc("shout", func(_ *fasthttp.RequestCtx, args []string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("shout takes one argument")
+		}
+		return strings.ToUpper(args[0]), nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+
+	tmpl, err := CompileTemplate(`{"name":"{{shout request.query.name}}","tag":"{{shout "x"}}","bad":"{{shout}}"}`)
+	if err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/users?name=ada")
+	expected := `{"name":"ADA","tag":"X","bad":"<template error: shout takes one argument>"}`
+	if body := tmpl.RenderString(ctx); body != expected {
+		t.Fatalf("Expected %s, got %s", expected, body)
+	}
+
+	shout := func(*fasthttp.RequestCtx, []string) (string, error) { return "", nil }
+	for _, name := range []string{"shout", "now", "", "request.id", "two words"} {
+		if err := RegisterTemplateFunc(name, shout); err == nil {
+			t.Errorf("Expected an error registering %q", name)
+		}
+	}
+	if err := RegisterTemplateFunc("nilfunc", nil); err == nil {
+		t.Errorf("Expected an error registering a nil function")
+	}
+}
+
+func TestTemplateRandomFuncs(t *testing.T) {
+	tmpl, err := CompileTemplate(`{{uuid}} {{uuid}} {{randInt 5 7}} {{fake "email"}} {{fake "name"}}`)
+	if err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+	render := func(uri string) []string {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(uri)
+		return strings.Split(tmpl.RenderString(ctx), " ")
+	}
+
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	values := render("/orders")
+	if !uuidPattern.MatchString(values[0]) || values[0] == values[1] {
+		t.Fatalf("Expected two different v4 UUIDs, got %q and %q", values[0], values[1])
+	}
+	if n, err := strconv.Atoi(values[2]); err != nil || n < 5 || n > 7 {
+		t.Errorf("Expected an integer between 5 and 7, got %q", values[2])
+	}
+	if !strings.Contains(values[3], "@") {
+		t.Errorf("Expected an email, got %q", values[3])
+	}
+	if first := render("/orders"); first[0] == values[0] {
+		t.Errorf("Expected unseeded values to differ between requests")
+	}
+
+	// Seeded, the same request renders the same values
+	SetTemplateSeed(42)
+	defer templateSeeded.Store(false)
+	first, second := render("/orders?id=1"), render("/orders?id=1")
+	if strings.Join(first, " ") != strings.Join(second, " ") {
+		t.Errorf("Expected seeded values to repeat, got %v and %v", first, second)
+	}
+	if other := render("/orders?id=2"); other[0] == first[0] {
+		t.Errorf("Expected another request to get other values")
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	for text, expected := range map[string]string{
+		`{{randInt 9 1}}`:    "<template error: randInt maximum 1 is below the minimum 9>",
+		`{{randInt "x" 1}}`:  `<template error: randInt minimum "x" is not an integer>`,
+		`{{fake "unicorn"}}`: `<template error: unknown fake "unicorn" (expected city, company, country, email, first_name, ipv4, last_name, name, phone, sentence, street, username, word)>`,
+	} {
+		tmpl, err := CompileTemplate(text)
+		if err != nil {
+			t.Fatalf("Failed to compile %s: %v", text, err)
+		}
+		if rendered := tmpl.RenderString(ctx); rendered != expected {
+			t.Errorf("%s: expected %s, got %s", text, expected, rendered)
+		}
+	}
+}
+
+func TestSSEEncodedEventReplay(t *testing.T) {
+	record := []byte(`{
+		"request": {"method": "GET", "url": "http://api.example.com/binary", "headers": {}},
+		"response": {
+			"status_code": 200,
+			"headers": {"Content-Type": "text/event-stream"},
+			"body": [
+				{"data": "/wAB/g==", "encoding": "base64", "timestamp": 0.1},
+				{"data": "plain text", "encoding": "text", "timestamp": 0.2},
+				{"data": {"n": 1}, "timestamp": 0.3},
+				{"data": "[DONE]", "timestamp": 0.4}
+			]
+		}
+	}`)
+
+	resp, err := parseMockRecord(record, "default")
+	if err != nil {
+		t.Fatalf("Failed to parse record: %v", err)
+	}
+
+	expected := [][]byte{{0xff, 0x00, 0x01, 0xfe}, []byte("plain text"), []byte(`{"n":1}`), []byte("[DONE]")}
+	if len(resp.SSEEvents) != len(expected) {
+		t.Fatalf("Expected %d events, got %d", len(expected), len(resp.SSEEvents))
+	}
+	for i, want := range expected {
+		if string(resp.SSEEvents[i].SerializedData) != string(want) {
+			t.Fatalf("Event %d: expected %q, got %q", i+1, want, resp.SSEEvents[i].SerializedData)
+		}
+	}
+
+	wantBody := "data: \xff\x00\x01\xfe\n\ndata: plain text\n\ndata: {\"n\":1}\n\ndata: [DONE]\n\n"
+	if string(resp.Body) != wantBody {
+		t.Fatalf("Unexpected pre-serialized body: %q", resp.Body)
+	}
+}
+
+func TestAppendSSEFrameMultiLine(t *testing.T) {
+	frame := AppendSSEFrame(nil, []byte("line one\nline two"))
+	if string(frame) != "data: line one\ndata: line two\n\n" {
+		t.Fatalf("Unexpected multi-line frame: %q", frame)
+	}
+
+	frame = AppendSSEFrame(nil, []byte(`{"n":1}`))
+	if string(frame) != "data: {\"n\":1}\n\n" {
+		t.Fatalf("Unexpected single-line frame: %q", frame)
+	}
+}
+
+func TestSSEAbortRequiresSSE(t *testing.T) {
+	store, err := NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-sse-abort-invalid.yml"); err == nil {
+		t.Fatal("Expected abort on a non-SSE response to be rejected")
+	}
+}
+
+func TestDelayOnlyScenarioMatching(t *testing.T) {
+	store, err := NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-delay-only.yml"); err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+
+	resp, timing := store.MatchScenario([]byte("/users/17"), []byte("GET"), nil, nil, nil, nil, nil)
+	if resp != nil {
+		t.Fatalf("Expected delay-only scenario not to answer by itself, got %+v", resp)
+	}
+	if timing == nil {
+		t.Fatal("Expected timing override from delay-only scenario")
+	}
+	if delay, jitter := timing.Apply(1.5, 0.3); delay != 0.2 || jitter != 0 {
+		t.Fatalf("Expected delay 0.2 and jitter 0, got %v and %v", delay, jitter)
+	}
+
+	if _, timing := store.MatchScenario([]byte("/users/17"), []byte("POST"), nil, nil, nil, nil, nil); timing != nil {
+		t.Fatal("Expected method mismatch to skip the delay-only scenario")
+	}
+
+	// Response fields other than delay and jitter need a response file
+	configPath := filepath.Join(t.TempDir(), "invalid.yml")
+	config := "scenarios:\n  - name: bad\n    path: /users/17\n    response:\n      delay: 0.1\n      headers:\n        X-Test: yes\n"
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := store.LoadScenarioConfig(configPath); err == nil {
+		t.Fatal("Expected headers without response.file to be rejected")
+	}
+}
+
+func TestFailedFilesReported(t *testing.T) {
+	baseDir := t.TempDir()
+	mockDir := filepath.Join(baseDir, "broken")
+	if err := os.MkdirAll(mockDir, 0755); err != nil {
+		t.Fatalf("Failed to create mock dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(mockDir, "bad.json"), []byte("{not json"), 0644); err != nil {
+		t.Fatalf("Failed to write mock file: %v", err)
+	}
+
+	store, err := NewMockStorage(baseDir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	failed := store.FailedFiles()
+	if len(failed) != 1 || filepath.Base(failed[0].File) != "bad.json" || failed[0].Error == "" {
+		t.Fatalf("Expected bad.json to be reported as failed, got %+v", failed)
+	}
+
+	stats := store.GetStats()
+	if stats["failed_files_count"] != 1 {
+		t.Fatalf("Expected failed_files_count 1 in stats, got %v", stats["failed_files_count"])
+	}
+	if names, ok := stats["failed_files"].([]string); !ok || len(names) != 1 || filepath.Base(names[0]) != "bad.json" {
+		t.Fatalf("Expected bad.json in stats failed_files, got %v", stats["failed_files"])
+	}
+
+	var report struct {
+		Total  int           `json:"total"`
+		Errors []LoadFailure `json:"errors"`
+	}
+	if err := json.Unmarshal(store.GetErrorsJSON(), &report); err != nil {
+		t.Fatalf("Failed to parse errors JSON: %v", err)
+	}
+	if report.Total != 1 || len(report.Errors) != 1 || report.Errors[0].Error == "" {
+		t.Fatalf("Unexpected errors report: %+v", report)
+	}
+}
+
+func TestScenarioClientCertMatching(t *testing.T) {
+	store, err := NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-client-cert.yml"); err != nil {
+		t.Fatalf("Failed to load scenario config: %v", err)
+	}
+
+	spiffe, _ := url.Parse("spiffe://example.org/ns/prod/sa/reports")
+	tests := []struct {
+		name   string
+		cert   *x509.Certificate
+		mockID string
+	}{
+		{"no certificate", nil, "Anonymous Caller"},
+		{"subject common name", &x509.Certificate{Subject: pkix.Name{CommonName: "billing-service"}}, "Billing Service Caller"},
+		{"uri san", &x509.Certificate{Subject: pkix.Name{CommonName: "reports"}, URIs: []*url.URL{spiffe}}, "SPIFFE Caller"},
+		{"unknown identity", &x509.Certificate{Subject: pkix.Name{CommonName: "Test Client"}}, "Anonymous Caller"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := store.MatchScenarioResponseForClient([]byte("/api/identity"), []byte("GET"), nil, tt.cert)
+			if resp == nil {
+				t.Fatal("Expected a scenario to match")
+			}
+			if resp.MockID != tt.mockID {
+				t.Errorf("Expected scenario %q, got %q", tt.mockID, resp.MockID)
+			}
+		})
+	}
+}
+
+func TestScenarioContentTypeRouting(t *testing.T) {
+	store, err := NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-content-type.yml"); err != nil {
+		t.Fatalf("Failed to load scenario config: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		method      string
+		contentType string
+		accept      string
+		mockID      string
+	}{
+		{"json body", "/api/submit", "POST", "application/json; charset=utf-8", "", "JSON Submission"},
+		{"form body", "/api/submit", "POST", "application/x-www-form-urlencoded", "", "Form Submission"},
+		{"multipart body", "/api/submit", "POST", "Multipart/Form-Data; boundary=x", "", "Form Submission"},
+		{"unknown body", "/api/submit", "POST", "text/plain", "", ""},
+		{"no content type", "/api/submit", "POST", "", "", ""},
+		{"accepts xml", "/api/report", "GET", "", "text/html, application/xml;q=0.9", "XML Report"},
+		{"accepts any", "/api/report", "GET", "", "*/*", "XML Report"},
+		{"accepts json", "/api/report", "GET", "", "application/json", "Default Report"},
+		{"no accept", "/api/report", "GET", "", "", "XML Report"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := store.MatchScenario([]byte(tt.path), []byte(tt.method), []byte(tt.contentType), []byte(tt.accept), nil, nil, nil)
+			if tt.mockID == "" {
+				if resp != nil {
+					t.Fatalf("Expected no match, got %q", resp.MockID)
+				}
+				return
+			}
+			if resp == nil {
+				t.Fatal("Expected a scenario to match")
+			}
+			if resp.MockID != tt.mockID {
+				t.Errorf("Expected scenario %q, got %q", tt.mockID, resp.MockID)
+			}
+		})
+	}
+}
+
+func TestScenarioFormFilter(t *testing.T) {
+	store, err := NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-form-filter.yml"); err != nil {
+		t.Fatalf("Failed to load scenario config: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		mockID string
+	}{
+		{"password grant", "grant_type=password&username=user-42&client_secret=s3cr3t", "Password Grant"},
+		{"encoded values", "client_secret=&username=user%2D7&grant_type=password", "Password Grant"},
+		{"username mismatch", "grant_type=password&username=admin&client_secret=x", ""},
+		{"secret missing", "grant_type=password&username=user-1", ""},
+		{"refresh grant", "grant_type=refresh_token&refresh_token=abc", "Refresh Grant"},
+		{"refresh with scope", "grant_type=refresh_token&scope=read", ""},
+		{"repeated field", "grant_type=other&grant_type=refresh_token", "Refresh Grant"},
+		{"json body", `{"grant_type":"password"}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := store.MatchScenarioResponse([]byte("/oauth/token"), []byte("POST"), []byte(tt.body))
+			if tt.mockID == "" {
+				if resp != nil {
+					t.Fatalf("Expected no match, got %q", resp.MockID)
+				}
+				return
+			}
+			if resp == nil {
+				t.Fatal("Expected a scenario to match")
+			}
+			if resp.MockID != tt.mockID {
+				t.Errorf("Expected scenario %q, got %q", tt.mockID, resp.MockID)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeJSON(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`{ "b": 1, "a": [ 2.0, 1e2, -0.0 ] }`, `{"a":[2,100,0],"b":1}`},
+		{`{"id": 12345678901234567890, "price": 1.50}`, `{"id":12345678901234567890,"price":1.5}`},
+		{`{"html": "<a&b>", "nested": {"z": true, "y": null}}`, `{"html":"<a&b>","nested":{"y":null,"z":true}}`},
+		{`"plain"`, `"plain"`},
+	}
+
+	for _, tt := range tests {
+		got, ok := CanonicalizeJSON([]byte(tt.input))
+		if !ok {
+			t.Errorf("CanonicalizeJSON(%s) reported invalid JSON", tt.input)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("CanonicalizeJSON(%s) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{`{"a":`, `{"a":1} {"b":2}`, `not json`} {
+		if _, ok := CanonicalizeJSON([]byte(invalid)); ok {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestIndexCacheReuse(t *testing.T) {
+	mockDir := filepath.Join(t.TempDir(), "mocks")
+	if err := os.CopyFS(mockDir, os.DirFS("../../test_mocks")); err != nil {
+		t.Fatalf("Failed to copy mocks: %v", err)
+	}
+	cachePath := filepath.Join(t.TempDir(), "index.cache")
+
+	cold, hit, err := NewMockStorageWithCache(mockDir, cachePath)
+	if err != nil {
+		t.Fatalf("Cold load failed: %v", err)
+	}
+	if hit {
+		t.Fatal("Expected cache miss on first load")
+	}
+
+	warm, hit, err := NewMockStorageWithCache(mockDir, cachePath)
+	if err != nil {
+		t.Fatalf("Warm load failed: %v", err)
+	}
+	if !hit {
+		t.Fatal("Expected cache hit when the mock dir is unchanged")
+	}
+	for _, key := range []string{"total_responses", "unique_paths", "unique_mock_ids"} {
+		if warm.GetStats()[key] != cold.GetStats()[key] {
+			t.Fatalf("Cached %s differs: %v vs %v", key, warm.GetStats()[key], cold.GetStats()[key])
+		}
+	}
+
+	coldResp := cold.FindResponse("/users/1", "default", "application/json", "GET")
+	warmResp := warm.FindResponse("/users/1", "default", "application/json", "GET")
+	if coldResp == nil || warmResp == nil || string(coldResp.Body) != string(warmResp.Body) {
+		t.Fatalf("Expected identical cached response, got %v and %v", coldResp, warmResp)
+	}
+
+	// Any change to the recordings invalidates the cache
+	extra := filepath.Join(mockDir, "default", "extra.json")
+	if err := os.WriteFile(extra, []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to add recording: %v", err)
+	}
+	if _, hit, err = NewMockStorageWithCache(mockDir, cachePath); err != nil || hit {
+		t.Fatalf("Expected cache miss after change (hit=%v, err=%v)", hit, err)
+	}
+}
+
+// loadWithCacheHit writes records to a mock dir, loads it twice through an
+// index cache and returns the storage restored from the cache.
+func loadWithCacheHit(t *testing.T, records map[string]string) *MockStorage {
+	t.Helper()
+	mockDir := filepath.Join(t.TempDir(), "mocks")
+	if err := os.MkdirAll(filepath.Join(mockDir, "default"), 0755); err != nil {
+		t.Fatalf("Failed to create mock dir: %v", err)
+	}
+	for name, record := range records {
+		if err := os.WriteFile(filepath.Join(mockDir, "default", name), []byte(record), 0644); err != nil {
+			t.Fatalf("Failed to write recording: %v", err)
+		}
+	}
+	cachePath := filepath.Join(t.TempDir(), "index.cache")
+	if _, hit, err := NewMockStorageWithCache(mockDir, cachePath); err != nil || hit {
+		t.Fatalf("Expected a cache miss on the first load (hit=%v, err=%v)", hit, err)
+	}
+	warm, hit, err := NewMockStorageWithCache(mockDir, cachePath)
+	if err != nil || !hit {
+		t.Fatalf("Expected a cache hit on the second load (hit=%v, err=%v)", hit, err)
+	}
+	return warm
+}
+
+func TestIndexCacheKeepsEnvTemplates(t *testing.T) {
+	t.Setenv("MOCK_CACHE_HOST", "db.internal")
+	warm := loadWithCacheHit(t, map[string]string{
+		"config.json": `{"request":{"method":"GET","url":"http://api.example.com/config"},` +
+			`"response":{"status_code":200,"headers":{"Content-Type":"application/json"},"body":{"host":"${ENV:MOCK_CACHE_HOST}"}}}`,
+	})
+
+	resp := warm.FindResponse("/config", "default", "application/json", "GET")
+	if resp == nil || resp.BodyTemplate == nil {
+		t.Fatalf("Expected the cached response to keep its env template, got %+v", resp)
+	}
+	if body := resp.BodyTemplate.RenderString(&fasthttp.RequestCtx{}); body != `{"host":"db.internal"}` {
+		t.Fatalf("Expected the env placeholder to be expanded, got %s", body)
+	}
+}
+
+func TestIndexCacheKeepsWebSocketFrames(t *testing.T) {
+	warm := loadWithCacheHit(t, map[string]string{
+		"prices.json": `{"type":"websocket","request":{"method":"GET","url":"http://api.example.com/ws/prices"},` +
+			`"response":{"status_code":101,"headers":{"Upgrade":"websocket"},"body":[` +
+			`{"direction":"server","timestamp":0,"type":"text","data":"welcome"},` +
+			`{"direction":"client","timestamp":0.5,"type":"text","data":"ping"}]}}`,
+	})
+
+	resp := warm.FindResponse("/ws/prices", "default", WebSocketContentType, "GET")
+	if resp == nil || !resp.IsWebSocket {
+		t.Fatalf("Expected the cached recording to stay a WebSocket conversation, got %+v", resp)
+	}
+	if len(resp.WebSocketFrames) != 2 || string(resp.WebSocketFrames[0].Payload) != "welcome" ||
+		resp.WebSocketFrames[0].FromClient || !resp.WebSocketFrames[1].FromClient || resp.WebSocketFrames[1].Timestamp != 0.5 {
+		t.Fatalf("Expected the recorded frames to survive the cache, got %+v", resp.WebSocketFrames)
+	}
+}
+
+func TestIndexCacheKeepsGRPCCalls(t *testing.T) {
+	warm := loadWithCacheHit(t, map[string]string{
+		"watch.json": `{"type":"grpc","request":{"url":"http://api/prices.PriceService/Watch","body":{"symbol":"ACME"}},` +
+			`"response":{"status_code":200,"headers":{"content-type":"application/grpc"},` +
+			`"body":[{"timestamp":0,"data":"CCo="},{"timestamp":1,"data":"CCs="}],` +
+			`"trailers":{"grpc-status":"5","grpc-message":"recorded"}}}`,
+	})
+
+	resp := warm.FindResponse("/prices.PriceService/Watch", "default", GRPCContentType, "POST")
+	if resp == nil || !resp.IsGRPC {
+		t.Fatalf("Expected the cached recording to stay a gRPC call, got %+v", resp)
+	}
+	if len(resp.GRPCMessages) != 2 || string(resp.GRPCMessages[1].Data) != "\x08\x2b" || resp.GRPCMessages[1].Timestamp != 1 {
+		t.Fatalf("Expected the recorded messages to survive the cache, got %+v", resp.GRPCMessages)
+	}
+	if resp.GRPCTrailers["grpc-status"] != "5" || resp.GRPCTrailers["grpc-message"] != "recorded" {
+		t.Fatalf("Expected the recorded trailers to survive the cache, got %v", resp.GRPCTrailers)
+	}
+	if body, ok := resp.RequestBody.(map[string]interface{}); !ok || body["symbol"] != "ACME" {
+		t.Fatalf("Expected the recorded request body to survive the cache, got %#v", resp.RequestBody)
+	}
+}
+
+func TestDecodeProtoJSON(t *testing.T) {
+	// {1: "ACME", 2: 5, 2: 6, 3: {1: 1}, 4: fixed32 7, 5: "\xff\x00"}
+	message := []byte("\x0a\x04ACME\x10\x05\x10\x06\x1a\x02\x08\x01\x25\x07\x00\x00\x00\x2a\x02\xff\x00")
+	got, err := DecodeProtoJSON(message)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	want := `{"1":"ACME","2":[5,6],"3":{"1":1},"4":7,"5":"/wA="}`
+	if string(got) != want {
+		t.Fatalf("Expected %s, got %s", want, got)
+	}
+
+	if _, err := DecodeProtoJSON([]byte("\x0a\x09short")); err == nil {
+		t.Fatal("Expected a truncated field to be rejected")
+	}
+}
+
+func TestScenarioNegativeFilters(t *testing.T) {
+	store, err := NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if err := store.LoadScenarioConfig("../../tests/fixtures/test-negative-filter.yml"); err != nil {
+		t.Fatalf("Failed to load scenario config: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		mockID string
+	}{
+		{"guest without channel", `{"items":[1]}`, "Guest Checkout"},
+		{"guest from web", `{"channel":"web"}`, "Guest Checkout"},
+		{"guest from internal", `{"channel":"internal"}`, ""},
+		{"null coupon", `{"customer":{"id":"c-1"},"coupon":null}`, "Cleared Coupon"},
+		{"null coupon temporary customer", `{"customer":{"id":"tmp-1"},"coupon":null}`, ""},
+		{"coupon set", `{"customer":{"id":"c-1"},"coupon":"SAVE"}`, ""},
+		{"silver tier", `{"customer":{"id":"c-1"},"tier":"silver"}`, "Not Gold"},
+		{"gold tier", `{"customer":{"id":"c-1"},"tier":"gold"}`, ""},
+		{"empty body", ``, "Guest Checkout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := store.MatchScenarioResponse([]byte("/checkout"), []byte("POST"), []byte(tt.body))
+			if tt.mockID == "" {
+				if resp != nil {
+					t.Fatalf("Expected no match, got %q", resp.MockID)
+				}
+				return
+			}
+			if resp == nil {
+				t.Fatal("Expected a scenario to match")
+			}
+			if resp.MockID != tt.mockID {
+				t.Errorf("Expected scenario %q, got %q", tt.mockID, resp.MockID)
+			}
+		})
+	}
+}
+
+func TestScenarioNegativeFilterErrors(t *testing.T) {
+	parser := serde.DefaultParser()
+	invalid := []map[string]interface{}{
+		{"absent": map[string]interface{}{}},
+		{"ne": map[string]interface{}{"field": "tier"}},
+		{"nrx": map[string]interface{}{"field": "id", "value": "("}},
+		{"not": []interface{}{}},
+		{"and": []interface{}{map[string]interface{}{"null": "coupon"}}},
+	}
+	for _, node := range invalid {
+		if _, err := parseBodyFilter(node, parser); err == nil {
+			t.Errorf("Expected an error for %v", node)
+		}
+	}
+}
+
+func TestScenarioResponseDir(t *testing.T) {
+	baseDir := t.TempDir()
+	dataDir := filepath.Join(baseDir, "quotes")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	for i, name := range []string{"b.json", "a.json", "c.json"} {
+		record := `{"request":{"method":"GET","url":"http://api.example.com/quote"},` +
+			`"response":{"status_code":200,"headers":{"Content-Type":"application/json"},"body":{"quote":"` + name + `"}}}`
+		file := filepath.Join(dataDir, name)
+		if err := os.WriteFile(file, []byte(record), 0644); err != nil {
+			t.Fatalf("Failed to write record: %v", err)
+		}
+		// b.json is the most recently modified
+		mtime := time.Now().Add(time.Duration(-i-1) * time.Hour)
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatalf("Failed to set mtime: %v", err)
+		}
+	}
+
+	config := `scenarios:
+  - name: Rotating
+    path: /quote
+    response:
+      dir: quotes
+      headers:
+        X-Source: rotation
+  - name: Random
+    path: /random
+    response:
+      dir: quotes
+      strategy: random
+  - name: Newest
+    path: /newest
+    response:
+      dir: quotes
+      strategy: newest
+`
+	configPath := filepath.Join(baseDir, "scenarios.yml")
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	store, err := NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig(configPath); err != nil {
+		t.Fatalf("Failed to load scenario config: %v", err)
+	}
+
+	pick := func(path string) *MockResponse {
+		resp := store.MatchScenarioResponse([]byte(path), []byte("GET"), nil)
+		if resp == nil {
+			t.Fatalf("Expected a scenario to match %s", path)
+		}
+		if resp.Rotation != nil {
+			resp = resp.Rotation.Next()
+		}
+		return resp
+	}
+
+	// Round robin follows file name order and wraps around
+	for _, want := range []string{"a.json", "b.json", "c.json", "a.json"} {
+		resp := pick("/quote")
+		if got := string(resp.Body); got != `{"quote":"`+want+`"}` {
+			t.Errorf("Expected %s, got %s", want, got)
+		}
+		if resp.Headers["X-Source"] != "rotation" || resp.MockID != "Rotating" || resp.Path != "/quote" {
+			t.Errorf("Expected scenario overrides on every recording, got %+v", resp)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 200; i++ {
+		seen[string(pick("/random").Body)] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected random picks to cover all recordings, got %v", seen)
+	}
+
+	for i := 0; i < 2; i++ {
+		if got := string(pick("/newest").Body); got != `{"quote":"b.json"}` {
+			t.Errorf("Expected the newest recording, got %s", got)
+		}
+	}
+}
+
+func TestLoadHARFile(t *testing.T) {
+	// Trimmed Chrome devtools export: decoded content, HTTP/2 pseudo-headers
+	// and WebSocket frames in _webSocketMessages
+	har := `{"log": {"version": "1.2", "creator": {"name": "WebInspector", "version": "537.36"}, "entries": [
+		{
+			"startedDateTime": "2025-01-02T10:00:00.000Z", "time": 120,
+			"request": {"method": "GET", "url": "https://api.example.com/users/9?full=1", "httpVersion": "HTTP/2",
+				"headers": [{"name": ":authority", "value": "api.example.com"}, {"name": "accept", "value": "application/json"}]},
+			"response": {"status": 200, "statusText": "", "httpVersion": "HTTP/2",
+				"headers": [{"name": "content-type", "value": "application/json; charset=utf-8"}, {"name": "content-encoding", "value": "gzip"}],
+				"content": {"size": 22, "mimeType": "application/json", "text": "{\"id\":9,\"name\":\"Ada\"}"}}
+		},
+		{
+			"startedDateTime": "2025-01-02T10:00:01.000Z", "time": 40,
+			"request": {"method": "GET", "url": "https://api.example.com/events", "headers": []},
+			"response": {"status": 200, "headers": [],
+				"content": {"mimeType": "text/event-stream", "encoding": "base64", "text": "ZGF0YTogeyJuIjoxfQoKZGF0YTogZG9uZQoK"}}
+		},
+		{
+			"startedDateTime": "2025-01-02T10:00:02.000Z", "time": 10,
+			"request": {"method": "GET", "url": "wss://api.example.com/ws", "headers": []},
+			"response": {"status": 101, "headers": [{"name": "Upgrade", "value": "websocket"}], "content": {"mimeType": ""}},
+			"_webSocketMessages": [
+				{"type": "send", "time": 1735812002.51, "opcode": 1, "data": "ping"},
+				{"type": "receive", "time": 1735812002.76, "opcode": 2, "data": "AAE="}
+			]
+		}
+	]}}`
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "default"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "default", "devtools.har"), []byte(har), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	resp := store.FindResponse("/users/9", "default", "application/json", "GET")
+	if resp == nil {
+		t.Fatal("Expected the JSON entry to be loaded")
+	}
+	if string(resp.Body) != `{"id":9,"name":"Ada"}` || resp.ContentEncoding() != "" || resp.Delay != 0.12 {
+		t.Fatalf("Unexpected JSON entry: body=%s encoding=%q delay=%v", resp.Body, resp.ContentEncoding(), resp.Delay)
+	}
+	if resp.RequestID != "devtools.har#1" {
+		t.Fatalf("Expected a request ID derived from the file name, got %q", resp.RequestID)
+	}
+
+	resp = store.FindResponse("/events", "default", "text/event-stream", "GET")
+	if resp == nil || len(resp.SSEEvents) != 2 || string(resp.SSEEvents[1].SerializedData) != "done" {
+		t.Fatalf("Unexpected SSE entry: %+v", resp)
+	}
+
+	resp = store.FindResponse("/ws", "default", WebSocketContentType, "GET")
+	if resp == nil || len(resp.WebSocketFrames) != 2 {
+		t.Fatalf("Unexpected WebSocket entry: %+v", resp)
+	}
+	frames := resp.WebSocketFrames
+	if !frames[0].FromClient || string(frames[0].Payload) != "ping" || frames[1].Opcode != WebSocketBinary ||
+		string(frames[1].Payload) != "\x00\x01" || frames[1].Timestamp < 0.74 || frames[1].Timestamp > 0.76 {
+		t.Fatalf("Unexpected frames: %+v", frames)
+	}
+}
+
+func TestRecordNoteListed(t *testing.T) {
+	mockDir := filepath.Join(t.TempDir(), "default")
+	if err := os.MkdirAll(mockDir, 0755); err != nil {
+		t.Fatalf("Failed to create mock dir: %v", err)
+	}
+	record := `{"note": "checkout with expired card",
+		"request": {"request_id": "n1", "method": "POST", "url": "http://api/pay", "headers": {}, "body": ""},
+		"response": {"request_id": "n1", "status_code": 402, "headers": {"Content-Type": "application/json"}, "body": {"error": "expired"}}}`
+	if err := os.WriteFile(filepath.Join(mockDir, "application_json_n1.json"), []byte(record), 0644); err != nil {
+		t.Fatalf("Failed to write mock file: %v", err)
+	}
+
+	store, err := NewMockStorage(filepath.Dir(mockDir))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	resp := store.FindResponse("/pay", "default", "application/json", "POST")
+	if resp == nil || resp.Note != "checkout with expired card" {
+		t.Fatalf("Expected the recorded note on the mock, got %+v", resp)
+	}
+
+	var list struct {
+		Mocks []map[string]interface{} `json:"mocks"`
+	}
+	if err := json.Unmarshal(store.GetMockListJSON(), &list); err != nil {
+		t.Fatalf("Failed to parse mock list: %v", err)
+	}
+	if len(list.Mocks) != 1 || list.Mocks[0]["note"] != "checkout with expired card" {
+		t.Fatalf("Expected the note in the mock list, got %v", list.Mocks)
+	}
+}
+
+func TestWatchReloadsRecordings(t *testing.T) {
+	dir := t.TempDir()
+	record := func(status int) []byte {
+		return []byte(fmt.Sprintf(`{"request":{"method":"GET","url":"http://api.example.com/items"},`+
+			`"response":{"status_code":%d,"headers":{"Content-Type":"application/json"},"body":{}}}`, status))
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "default"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "default", "items.json")
+	if err := os.WriteFile(file, record(200), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	reloaded := make(chan string, 10)
+	watcher, err := store.Watch(func(file string, err error) { reloaded <- file })
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	defer watcher.Close()
+
+	wait := func() {
+		t.Helper()
+		select {
+		case <-reloaded:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for a reload")
+		}
+	}
+	status := func(mockID string) int {
+		if resp := store.FindResponse("/items", mockID, "application/json", "GET"); resp != nil {
+			return resp.StatusCode
+		}
+		return 0
+	}
+
+	if err := os.WriteFile(file, record(201), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wait()
+	if got := status("default"); got != 201 {
+		t.Fatalf("Expected the changed recording, got status %d", got)
+	}
+	if total := len(store.ListAllMocks()); total != 1 {
+		t.Fatalf("Expected the old response to be replaced, got %d responses", total)
+	}
+
+	// A new mock ID directory is watched too
+	if err := os.MkdirAll(filepath.Join(dir, "checkout"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "checkout", "items.json"), record(202), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wait()
+	if got := status("checkout"); got != 202 {
+		t.Fatalf("Expected the new recording, got status %d", got)
+	}
+
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	wait()
+	if got := status("default"); got != 0 {
+		t.Fatalf("Expected the removed recording to be gone, got status %d", got)
+	}
+}
+
+func TestStatsCounters(t *testing.T) {
+	store, err := NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	mockResponse := store.FindResponse("/users/1", "default", "application/json", "GET")
+	if mockResponse == nil {
+		t.Fatal("Expected the /users/1 mock")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.CountServed(mockResponse)
+			store.CountMiss()
+		}()
+	}
+	wg.Wait()
+
+	stats := store.GetStats()
+	if stats["requests_served"] != uint64(50) || stats["requests_missed"] != uint64(50) {
+		t.Fatalf("Expected 50 served and 50 missed, got %v and %v", stats["requests_served"], stats["requests_missed"])
+	}
+	if byType := stats["served_by_content_type"].(map[string]uint64); byType["application/json"] != 50 {
+		t.Fatalf("Expected 50 application/json responses, got %v", byType)
+	}
+	mocks := stats["mock_hits"].([]mockHits)
+	if len(mocks) != 1 || mocks[0].Hits != 50 || mocks[0].Path != "/users/1" || mocks[0].RequestID != mockResponse.RequestID {
+		t.Fatalf("Expected 50 hits on the /users/1 mock, got %+v", mocks)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, mocks[0].LastServed); err != nil {
+		t.Fatalf("Expected an RFC 3339 last_served, got %q", mocks[0].LastServed)
+	}
+	if paths := stats["path_hits"].(map[string]pathHits); paths["/users/1"].Hits != 50 || len(paths) != 1 {
+		t.Fatalf("Expected 50 hits on /users/1, got %+v", paths)
+	}
+
+	// The streamed JSON always carries the current counters
+	store.CountServed(mockResponse)
+	var served map[string]interface{}
+	if err := json.Unmarshal(store.GetStatsJSON(), &served); err != nil {
+		t.Fatal(err)
+	}
+	if served["requests_served"] != float64(51) {
+		t.Fatalf("Expected 51 served requests in the stats JSON, got %v", served["requests_served"])
+	}
+	if err := store.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	served = nil
+	if err := json.Unmarshal(store.GetStatsJSON(), &served); err != nil {
+		t.Fatal(err)
+	}
+	if served["requests_served"] != float64(0) || served["mocks_served"] != float64(0) || served["total_responses"] != float64(stats["total_responses"].(int)) {
+		t.Fatalf("Expected counters to be cleared by Reset, got %v", served)
+	}
+}
+
+func TestScenarioTags(t *testing.T) {
+	recording, err := filepath.Abs("../../test_mocks/default/application_json_20251122_233842_059b6fbd.json")
+	if err != nil {
+		t.Fatalf("Failed to resolve recording: %v", err)
+	}
+	config := `scenarios:
+  - name: Baseline
+    path: /health
+    response:
+      file: ` + recording + `
+  - name: Checkout Happy
+    path: /checkout
+    tags: [checkout, smoke]
+    response:
+      file: ` + recording + `
+  - name: Checkout Declined
+    path: /checkout
+    tags: [payments-failure]
+    response:
+      file: ` + recording + `
+`
+	configPath := filepath.Join(t.TempDir(), "scenarios.yml")
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	load := func(tags ...string) (*MockStorage, error) {
+		store, err := NewMockStorage(t.TempDir())
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+		store.SetScenarioTags(tags)
+		return store, store.LoadScenarioConfig(configPath)
+	}
+	matched := func(store *MockStorage, path string) string {
+		resp := store.MatchScenarioResponse([]byte(path), []byte("GET"), nil)
+		if resp == nil {
+			return ""
+		}
+		return resp.MockID
+	}
+
+	// Without tags every scenario loads, first declared wins
+	store, err := load()
+	if err != nil {
+		t.Fatalf("Failed to load scenario config: %v", err)
+	}
+	var list struct {
+		Mocks []struct {
+			Tags []string `json:"tags"`
+		} `json:"mocks"`
+		Total int `json:"total"`
+	}
+	if err := json.Unmarshal(store.GetMockListJSON(), &list); err != nil || list.Total != 3 {
+		t.Errorf("Expected 3 scenarios, got %s", store.GetMockListJSON())
+	}
+
+	// A tag keeps the scenarios carrying it plus the untagged baseline
+	store, err = load("payments-failure")
+	if err != nil {
+		t.Fatalf("Failed to load scenario config: %v", err)
+	}
+	if got := matched(store, "/checkout"); got != "Checkout Declined" {
+		t.Errorf("Expected Checkout Declined, got %q", got)
+	}
+	if got := matched(store, "/health"); got != "Baseline" {
+		t.Errorf("Expected the untagged scenario to load, got %q", got)
+	}
+	if err := json.Unmarshal(store.GetMockListJSON(), &list); err != nil || len(list.Mocks) != 2 {
+		t.Fatalf("Expected 2 scenarios, got %s", store.GetMockListJSON())
+	}
+	if tags := list.Mocks[1].Tags; len(tags) != 1 || tags[0] != "payments-failure" {
+		t.Errorf("Expected the tags in the mock list, got %v", tags)
+	}
+
+	// Any selected tag activates a scenario
+	store, err = load("smoke", "payments-failure")
+	if err != nil {
+		t.Fatalf("Failed to load scenario config: %v", err)
+	}
+	if got := matched(store, "/checkout"); got != "Checkout Happy" {
+		t.Errorf("Expected Checkout Happy, got %q", got)
+	}
+
+	// A tag no scenario carries is reported
+	if _, err := load("smoke", "payment-failure"); err == nil || !strings.Contains(err.Error(), `"payment-failure"`) {
+		t.Errorf("Expected an error naming the unknown tag, got %v", err)
+	}
+}
+
+func TestClientIPMatcher(t *testing.T) {
+	matcher, err := newClientIPMatcher(clientIPList{"10.0.0.0/8", "192.168.1.20", "::1"})
+	if err != nil {
+		t.Fatalf("Failed to compile client_ip: %v", err)
+	}
+	for ip, want := range map[string]bool{
+		"10.200.3.4":      true,
+		"192.168.1.20":    true,
+		"192.168.1.21":    false,
+		"::1":             true,
+		"::ffff:10.0.0.1": true,
+		"11.0.0.1":        false,
+	} {
+		if got := matcher.matches(net.ParseIP(ip)); got != want {
+			t.Errorf("%s: expected %v, got %v", ip, want, got)
+		}
+	}
+	if matcher.matches(nil) {
+		t.Error("Expected an unknown client not to match")
+	}
+
+	for _, invalid := range []string{"10.0.0.0/33", "10.0.0", "localhost"} {
+		if _, err := newClientIPMatcher(clientIPList{invalid}); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestConflictPolicy(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "default"), 0755); err != nil {
+		t.Fatalf("Failed to create mock dir: %v", err)
+	}
+	write := func(name, body string, age time.Duration) {
+		t.Helper()
+		record := `{"request":{"method":"GET","url":"http://api/users"},` +
+			`"response":{"status_code":200,"headers":{"Content-Type":"application/json"},"body":` + body + `}}`
+		file := filepath.Join(dir, "default", name)
+		if err := os.WriteFile(file, []byte(record), 0644); err != nil {
+			t.Fatalf("Failed to write record: %v", err)
+		}
+		mtime := time.Now().Add(-age)
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatalf("Failed to set mtime: %v", err)
+		}
+	}
+	write("a.json", `{"v":"a"}`, 2*time.Hour)
+	write("b.json", `{"v":"b"}`, time.Hour)
+	write("c.json", `{"v":"a"}`, 3*time.Hour) // Same answer as a.json
+
+	served := func(store *MockStorage) string {
+		resp := store.FindResponse("/users", "default", "application/json", "GET")
+		if resp == nil {
+			t.Fatal("Expected a response")
+		}
+		return string(resp.Body)
+	}
+
+	store, err := NewMockStorage(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if got := served(store); got != `{"v":"a"}` {
+		t.Errorf("Expected the first file by default, got %s", got)
+	}
+	conflicts := store.Conflicts()
+	if len(conflicts) != 1 || conflicts[0].Winner != filepath.Join(dir, "default", "a.json") ||
+		len(conflicts[0].Overridden) != 1 || conflicts[0].Overridden[0] != filepath.Join(dir, "default", "b.json") {
+		t.Fatalf("Expected b.json to conflict with a.json only, got %+v", conflicts)
+	}
+	if got := store.GetStats()["conflicts_count"]; got != 1 {
+		t.Errorf("Expected the conflict in the stats, got %v", got)
+	}
+
+	if err := store.SetConflictPolicy(ConflictNewest); err != nil {
+		t.Fatalf("Unexpected error for newest: %v", err)
+	}
+	if got := served(store); got != `{"v":"b"}` {
+		t.Errorf("Expected the newest file, got %s", got)
+	}
+	if conflicts := store.Conflicts(); len(conflicts) != 1 || len(conflicts[0].Overridden) != 2 {
+		t.Errorf("Expected a.json and c.json to be overridden, got %+v", conflicts)
+	}
+
+	if err := store.SetConflictPolicy(ConflictError); err == nil || !strings.Contains(err.Error(), "b.json") {
+		t.Errorf("Expected an error naming the conflicting files, got %v", err)
+	}
+	if err := store.Reload(); err == nil {
+		t.Error("Expected Reload to refuse conflicting recordings")
+	}
+
+	if err := os.Remove(filepath.Join(dir, "default", "b.json")); err != nil {
+		t.Fatalf("Failed to remove record: %v", err)
+	}
+	if err := store.Reload(); err != nil {
+		t.Fatalf("Expected identical recordings to load: %v", err)
+	}
+	if conflicts := store.Conflicts(); len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts, got %+v", conflicts)
+	}
+
+	if _, err := ParseConflictPolicy("last"); err == nil {
+		t.Error("Expected an error for an unknown policy")
+	}
+}
+
+func TestPathTemplate(t *testing.T) {
+	cases := []struct {
+		template string
+		path     string
+		params   map[string]string
+		ok       bool
+	}{
+		{"/users/{id}", "/users/42", map[string]string{"id": "42"}, true},
+		{"/users/{id}", "/users/", nil, false},
+		{"/users/{id}", "/users/42/orders", nil, false},
+		{"/users/{user}/orders/{order}", "/users/7/orders/9", map[string]string{"user": "7", "order": "9"}, true},
+		{"/users/*/avatar", "/users/7/avatar", nil, true},
+		{"/files/**", "/files", nil, true},
+		{"/files/**", "/files/a/b/c.txt", nil, true},
+		{"/files/**", "/filesystem", nil, false},
+	}
+	for _, c := range cases {
+		tmpl, err := parsePathTemplate(c.template)
+		if err != nil || tmpl == nil {
+			t.Fatalf("Failed to parse %s: %v", c.template, err)
+		}
+		params, ok := tmpl.match(c.path)
+		if ok != c.ok || ok && !reflect.DeepEqual(params, c.params) {
+			t.Errorf("%s against %s: expected %v %v, got %v %v", c.template, c.path, c.params, c.ok, params, ok)
+		}
+	}
+
+	if tmpl, err := parsePathTemplate("/users/42"); tmpl != nil || err != nil {
+		t.Errorf("Expected plain paths to stay literal, got %v %v", tmpl, err)
+	}
+	for _, invalid := range []string{"/users/{}", "/users/{id}/{id}", "/users/id-{id}", "/files/**/raw"} {
+		if _, err := parsePathTemplate(invalid); err == nil {
+			t.Errorf("Expected %s to be rejected", invalid)
+		}
+	}
+
+	// Literals win over params, params over wildcards
+	specific, _ := parsePathTemplate("/users/{id}/orders")
+	param, _ := parsePathTemplate("/users/{id}/{section}")
+	rest, _ := parsePathTemplate("/users/**")
+	if !specific.moreSpecific(param) || !param.moreSpecific(rest) || rest.moreSpecific(specific) {
+		t.Errorf("Expected /users/{id}/orders before /users/{id}/{section} before /users/**")
+	}
+}
+
+// chunkWriter records the largest single write it receives; streamed JSON
+// arrives in buffer-sized chunks.
+type chunkWriter struct {
+	strings.Builder
+	largest int
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	if len(p) > w.largest {
+		w.largest = len(p)
+	}
+	return w.Builder.Write(p)
+}
+
+func TestStreamedAdminJSON(t *testing.T) {
+	store := newEmptyStorage(t.TempDir())
+	for i := 0; i < 2000; i++ {
+		record := fmt.Sprintf(`{"request": {"request_id": "r%d", "method": "GET", "url": "http://api/items/%d"},
+			"response": {"status_code": 200, "headers": {"Content-Type": "application/json"}, "body": {"id": %d}}}`, i, i, i)
+		if err := store.AddRecord([]byte(record), "default"); err != nil {
+			t.Fatalf("Failed to add record: %v", err)
+		}
+	}
+
+	var list chunkWriter
+	if err := store.WriteMockListJSON(&list); err != nil {
+		t.Fatalf("Failed to write the mock list: %v", err)
+	}
+	var parsed struct {
+		Mocks []map[string]interface{} `json:"mocks"`
+		Total int                      `json:"total"`
+	}
+	if err := json.Unmarshal([]byte(list.String()), &parsed); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if parsed.Total != 2000 || len(parsed.Mocks) != 2000 || parsed.Mocks[0]["status_code"] != float64(200) {
+		t.Fatalf("Expected 2000 mocks, got %d (%d listed)", parsed.Total, len(parsed.Mocks))
+	}
+	if list.largest > 4096 {
+		t.Errorf("Expected the list to be written in chunks, got a %d byte write of %d", list.largest, list.Len())
+	}
+
+	var stats chunkWriter
+	if err := store.WriteStatsJSON(&stats); err != nil {
+		t.Fatalf("Failed to write the stats: %v", err)
+	}
+	var parsedStats map[string]interface{}
+	if err := json.Unmarshal([]byte(stats.String()), &parsedStats); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if paths, _ := parsedStats["paths"].([]interface{}); len(paths) != 2000 || parsedStats["unique_paths"] != float64(2000) {
+		t.Errorf("Expected 2000 paths in the stats, got %v", parsedStats["unique_paths"])
+	}
+	if stats.largest > 4096 {
+		t.Errorf("Expected the stats to be written in chunks, got a %d byte write of %d", stats.largest, stats.Len())
+	}
+}
+
+func TestScenarioDefaults(t *testing.T) {
+	recording, err := filepath.Abs("../../test_mocks/default/application_json_20251122_233842_059b6fbd.json")
+	if err != nil {
+		t.Fatalf("Failed to resolve recording: %v", err)
+	}
+	config := `defaults:
+  status: 202
+  delay: 0.25
+  headers:
+    X-Env: staging
+    Cache-Control: no-store
+scenarios:
+  - name: Inherits
+    path: /inherits
+    response:
+      file: ` + recording + `
+  - name: Overrides
+    path: /overrides
+    response:
+      file: ` + recording + `
+      status: 201
+      delay: 0
+      headers:
+        cache-control: max-age=60
+  - name: Sequence
+    path: /sequence
+    responses:
+      - file: ` + recording + `
+      - file: ` + recording + `
+        status: 500
+  - name: Slow
+    path: /slow
+    response:
+      delay: 2
+`
+	configPath := filepath.Join(t.TempDir(), "scenarios.yml")
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	store, err := NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig(configPath); err != nil {
+		t.Fatalf("Failed to load scenario config: %v", err)
+	}
+	match := func(path string) *MockResponse {
+		resp := store.MatchScenarioResponse([]byte(path), []byte("GET"), nil)
+		if resp == nil {
+			t.Fatalf("Expected a scenario for %s", path)
+		}
+		return resp
+	}
+
+	inherits := match("/inherits")
+	if inherits.StatusCode != 202 || inherits.Delay != 0.25 || inherits.Headers["X-Env"] != "staging" || inherits.Headers["Cache-Control"] != "no-store" {
+		t.Errorf("Expected the defaults, got %d %v %v", inherits.StatusCode, inherits.Delay, inherits.Headers)
+	}
+
+	overrides := match("/overrides")
+	if overrides.StatusCode != 201 || overrides.Delay != 0 {
+		t.Errorf("Expected status and delay overridden, got %d %v", overrides.StatusCode, overrides.Delay)
+	}
+	if overrides.Headers["cache-control"] != "max-age=60" || overrides.Headers["Cache-Control"] != "" || overrides.Headers["X-Env"] != "staging" {
+		t.Errorf("Expected cache-control overridden case-insensitively, got %v", overrides.Headers)
+	}
+
+	if first, second := match("/sequence"), match("/sequence"); first.StatusCode != 202 || second.StatusCode != 500 {
+		t.Errorf("Expected each sequence entry to inherit, got %d and %d", first.StatusCode, second.StatusCode)
+	}
+
+	// Delay-only scenarios keep their own timing and gain no response
+	if _, timing := store.MatchScenario([]byte("/slow"), []byte("GET"), nil, nil, nil, nil, nil); timing == nil || *timing.Delay != 2 {
+		t.Errorf("Expected the delay-only scenario to stay delay-only, got %+v", timing)
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.yml")
+	if err := os.WriteFile(invalid, []byte("defaults:\n  status: 42\n"+config[strings.Index(config, "scenarios:"):]), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := store.LoadScenarioConfig(invalid); err == nil || !strings.Contains(err.Error(), "defaults.status") {
+		t.Errorf("Expected an invalid default status to be rejected, got %v", err)
+	}
+}
+
+func TestScenarioAfterRequests(t *testing.T) {
+	recording, err := filepath.Abs("../../test_mocks/default/application_json_20251122_233842_059b6fbd.json")
+	if err != nil {
+		t.Fatalf("Failed to resolve recording: %v", err)
+	}
+	config := `scenarios:
+  - name: QuotaExceeded
+    path: /quota
+    method: GET
+    after_requests: 3
+    response:
+      file: ` + recording + `
+      status: 429
+  - name: Success
+    path: /quota
+    response:
+      file: ` + recording + `
+  - name: Create
+    path: /quota
+    method: POST
+    response:
+      file: ` + recording + `
+      status: 201
+`
+	configPath := filepath.Join(t.TempDir(), "scenarios.yml")
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	store, err := NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig(configPath); err != nil {
+		t.Fatalf("Failed to load scenario config: %v", err)
+	}
+	status := func(method string) int {
+		resp := store.MatchScenarioResponse([]byte("/quota"), []byte(method), nil)
+		if resp == nil {
+			t.Fatalf("Expected a scenario for %s /quota", method)
+		}
+		return resp.StatusCode
+	}
+
+	// Requests with other methods do not count towards the threshold
+	if got := status("POST"); got != 201 {
+		t.Errorf("Expected POST to be answered by Create, got %d", got)
+	}
+	for i := 1; i <= 3; i++ {
+		if got := status("GET"); got != 200 {
+			t.Errorf("Request %d: expected 200 before the threshold, got %d", i, got)
+		}
+	}
+
+	// Dry runs do not count either
+	trace := store.EvaluateScenarios([]byte("/quota"), []byte("GET"), nil, nil, nil, nil, nil)
+	if trace.Scenario != "QuotaExceeded" {
+		t.Errorf("Expected the evaluation to pick QuotaExceeded, got %+v", trace.Scenarios)
+	}
+	for i := 4; i <= 5; i++ {
+		if got := status("GET"); got != 429 {
+			t.Errorf("Request %d: expected 429 after the threshold, got %d", i, got)
+		}
+	}
+
+	if err := store.Reset(); err != nil {
+		t.Fatalf("Failed to reset: %v", err)
+	}
+	trace = store.EvaluateScenarios([]byte("/quota"), []byte("GET"), nil, nil, nil, nil, nil)
+	if trace.Scenario != "Success" || trace.Scenarios[0].Reason != "after_requests" {
+		t.Errorf("Expected reset to restart the count, got %+v", trace.Scenarios)
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.yml")
+	if err := os.WriteFile(invalid, []byte(strings.Replace(config, "after_requests: 3", "after_requests: -1", 1)), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := store.LoadScenarioConfig(invalid); err == nil || !strings.Contains(err.Error(), "after_requests") {
+		t.Errorf("Expected a negative after_requests to be rejected, got %v", err)
+	}
+}
+
+func TestScenarioMaxServes(t *testing.T) {
+	recording, err := filepath.Abs("../../test_mocks/default/application_json_20251122_233842_059b6fbd.json")
+	if err != nil {
+		t.Fatalf("Failed to resolve recording: %v", err)
+	}
+	config := `scenarios:
+  - name: SlowOnce
+    path: /login
+    max_serves: 2
+    response:
+      delay: 1.5
+  - name: MFASetup
+    path: /login
+    max_serves: 1
+    response:
+      file: ` + recording + `
+      status: 403
+  - name: Login
+    path: /login
+    response:
+      file: ` + recording + `
+`
+	configPath := filepath.Join(t.TempDir(), "scenarios.yml")
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	store, err := NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig(configPath); err != nil {
+		t.Fatalf("Failed to load scenario config: %v", err)
+	}
+
+	// The first login needs MFA setup, later ones fall through to Login; the
+	// delay-only scenario applies to its first two requests
+	for i, expected := range []struct {
+		status int
+		slow   bool
+	}{{403, true}, {200, true}, {200, false}} {
+		resp, timing := store.MatchScenario([]byte("/login"), []byte("GET"), nil, nil, nil, nil, nil)
+		if resp == nil || resp.StatusCode != expected.status {
+			t.Fatalf("Request %d: expected %d, got %+v", i+1, expected.status, resp)
+		}
+		if (timing != nil) != expected.slow {
+			t.Errorf("Request %d: expected delay %v, got %+v", i+1, expected.slow, timing)
+		}
+	}
+
+	trace := store.EvaluateScenarios([]byte("/login"), []byte("GET"), nil, nil, nil, nil, nil)
+	if trace.Scenario != "Login" || trace.Scenarios[1].Reason != "max_serves" {
+		t.Errorf("Expected MFASetup to be spent, got %+v", trace.Scenarios)
+	}
+
+	if err := store.Reset(); err != nil {
+		t.Fatalf("Failed to reset: %v", err)
+	}
+	if resp := store.MatchScenarioResponse([]byte("/login"), []byte("GET"), nil); resp == nil || resp.StatusCode != 403 {
+		t.Errorf("Expected reset to restore MFASetup, got %+v", resp)
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.yml")
+	if err := os.WriteFile(invalid, []byte(strings.Replace(config, "max_serves: 1", "max_serves: -1", 1)), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := store.LoadScenarioConfig(invalid); err == nil || !strings.Contains(err.Error(), "max_serves") {
+		t.Errorf("Expected a negative max_serves to be rejected, got %v", err)
+	}
+}
+
+func TestExperimentRejectedWithDelayOnlyResponse(t *testing.T) {
+	mocks, err := filepath.Abs("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to resolve mocks: %v", err)
+	}
+	config := `scenarios:
+  - name: Rollout
+    path: /api/data
+    response:
+      delay: 100
+    experiment:
+      a:
+        file: ` + filepath.Join(mocks, "api-v1/application_json_20251122_233842_3121ee87.json") + `
+      b:
+        file: ` + filepath.Join(mocks, "api-v2/application_json_20251122_233842_2040ed72.json") + `
+`
+	configPath := filepath.Join(t.TempDir(), "scenarios.yml")
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	store, err := NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.LoadScenarioConfig(configPath); err == nil || !strings.Contains(err.Error(), "experiment") {
+		t.Errorf("Expected an experiment with a delay-only response to be rejected, got %v", err)
+	}
+}
+
+func TestScenarioStatePersistence(t *testing.T) {
+	mocks, err := filepath.Abs("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to resolve mocks: %v", err)
+	}
+	fixture, err := os.ReadFile("../../tests/fixtures/test-sequence.yml")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "scenarios.yml")
+	config := `scenarios:
+  - name: MFASetup
+    path: /api/poll
+    max_serves: 1
+    response:
+      file: ` + mocks + `/api-v1/application_json_20251122_233842_3121ee87.json
+      status: 403
+` + strings.TrimPrefix(strings.ReplaceAll(string(fixture), "../../test_mocks", mocks), "scenarios:\n")
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	load := func() *MockStorage {
+		store, err := NewMockStorage(t.TempDir())
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+		if err := store.LoadScenarioConfig(configPath); err != nil {
+			t.Fatalf("Failed to load scenarios: %v", err)
+		}
+		return store
+	}
+	serve := func(store *MockStorage, path string) *MockResponse {
+		resp := store.MatchScenarioResponse([]byte(path), []byte("GET"), nil)
+		if resp == nil {
+			t.Fatalf("Expected scenario match for %s", path)
+		}
+		return resp
+	}
+
+	statePath := filepath.Join(dir, "state.json")
+	store := load()
+	if restored, err := store.RestoreScenarioState(statePath); err != nil || restored != 0 {
+		t.Fatalf("Expected a missing state file to start fresh, got %d, %v", restored, err)
+	}
+	persister := store.PersistScenarioState(statePath, time.Hour, nil)
+	serve(store, "/api/poll") // MFASetup
+	serve(store, "/api/poll") // Polling Repeat Last, first response
+	serve(store, "/api/loop")
+	if err := persister.Close(); err != nil {
+		t.Fatalf("Failed to write state: %v", err)
+	}
+
+	// A restarted server picks up where the previous one stopped
+	restarted := load()
+	if restored, err := restarted.RestoreScenarioState(statePath); err != nil || restored != 3 {
+		t.Fatalf("Expected 3 scenarios restored, got %d, %v", restored, err)
+	}
+	if resp := serve(restarted, "/api/poll"); string(resp.Body) != `{"data":4,"version":2}` {
+		t.Errorf("Expected the sequence to resume past MFASetup, got %d %s", resp.StatusCode, resp.Body)
+	}
+	if body := string(serve(restarted, "/api/loop").Body); body != `{"data":4,"version":2}` {
+		t.Errorf("Expected the loop to resume at its second response, got %s", body)
+	}
+	if resp := serve(restarted, "/api/once"); resp.StatusCode != 200 {
+		t.Errorf("Expected scenarios missing from the state to start fresh, got %d", resp.StatusCode)
+	}
+
+	if err := os.WriteFile(statePath, []byte(`{"version": 99}`), 0644); err != nil {
+		t.Fatalf("Failed to write state: %v", err)
+	}
+	if _, err := restarted.RestoreScenarioState(statePath); err == nil {
+		t.Error("Expected an unknown state version to be rejected")
+	}
+}
+
+func TestChaos(t *testing.T) {
+	recording, err := filepath.Abs("../../test_mocks/default/application_json_20251122_233842_059b6fbd.json")
+	if err != nil {
+		t.Fatalf("Failed to resolve recording: %v", err)
+	}
+	config := `chaos:
+  - path: /health
+    error_rate: 0
+  - path: /payments/**
+    extra_latency: 0.05
+scenarios:
+  - name: Health
+    path: /health
+    response:
+      file: ` + recording + `
+`
+	configPath := filepath.Join(t.TempDir(), "scenarios.yml")
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	store, err := NewMockStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if action := store.Chaos([]byte("/users")); action != (ChaosAction{}) {
+		t.Fatalf("Expected chaos mode to be off by default, got %+v", action)
+	}
+	if err := store.LoadScenarioConfig(configPath); err != nil {
+		t.Fatalf("Failed to load scenario config: %v", err)
+	}
+	store.SetChaos(ChaosConfig{ErrorRate: 1, ExtraLatency: time.Millisecond})
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		action := store.Chaos([]byte("/users"))
+		if action.Error == "" || action.Latency >= time.Millisecond {
+			t.Fatalf("Expected an error and at most 1ms latency, got %+v", action)
+		}
+		seen[action.Error] = true
+
+		// Overrides replace only the settings they declare
+		if action := store.Chaos([]byte("/health")); action.Error != "" || action.Latency >= time.Millisecond {
+			t.Fatalf("Expected /health to be spared errors, got %+v", action)
+		}
+		if action := store.Chaos([]byte("/payments/42/refund")); action.Error == "" || action.Latency >= 50*time.Millisecond {
+			t.Fatalf("Expected the /payments/** latency and the global error rate, got %+v", action)
+		}
+	}
+	if !seen[ChaosInternalError] || !seen[ChaosUnavailable] || !seen[ChaosTimeout] {
+		t.Errorf("Expected every chaos error to be picked, got %v", seen)
+	}
+
+	for _, chaos := range []string{"  - error_rate: 0.5", "  - path: /x", "  - path: /x\n    error_rate: 2"} {
+		invalid := filepath.Join(t.TempDir(), "invalid.yml")
+		if err := os.WriteFile(invalid, []byte("chaos:\n"+chaos+"\n"+config[strings.Index(config, "scenarios:"):]), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		if err := store.LoadScenarioConfig(invalid); err == nil || !strings.Contains(err.Error(), "chaos") {
+			t.Errorf("%q: expected a chaos error, got %v", chaos, err)
+		}
+	}
+}
+
+func TestFindResponseContentTypeFallback(t *testing.T) {
+	store, err := NewMockStorage("../../test_mocks")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if resp := store.FindResponse("/users/1", "default", "application/vnd.custom+json", "GET"); resp != nil {
+		t.Fatalf("Expected no fallback by default, got %s", resp.ContentType)
+	}
+
+	store.SetContentTypeFallback(true)
+	resp := store.FindResponse("/users/1", "default", "application/vnd.custom+json", "GET")
+	if resp == nil || resp.Path != "/users/1" || resp.ContentType != "application/json" {
+		t.Fatalf("Expected the application/json recording, got %+v", resp)
+	}
+	if resp := store.FindResponse("/users/1", "default", "text/html", "POST"); resp != nil {
+		t.Errorf("Expected the method to still apply, got %s", resp.Method)
+	}
+	if resp := store.FindResponse("/users/1", "other", "text/html", "GET"); resp != nil {
+		t.Errorf("Expected the mock ID to still apply, got %s", resp.MockID)
+	}
+	if resp := store.FindResponse("/users/1", "default", WebSocketContentType, "GET"); resp != nil {
+		t.Errorf("Expected WebSocket lookups not to fall back, got %s", resp.ContentType)
+	}
+}
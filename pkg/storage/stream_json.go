@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// mockListEntry is one recording or scenario in /__mock__/list.
+type mockListEntry struct {
+	RequestID   string   `json:"request_id"`
+	Path        string   `json:"path"`
+	Method      string   `json:"method"`
+	MockID      string   `json:"mock_id"`
+	ContentType string   `json:"content_type"`
+	StatusCode  int      `json:"status_code"`
+	FullURL     string   `json:"full_url"`
+	Note        string   `json:"note,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// timingOnlyEntry lists a delay-only scenario, which has no response.
+type timingOnlyEntry struct {
+	Path       string   `json:"path"`
+	Method     string   `json:"method"`
+	MockID     string   `json:"mock_id"`
+	TimingOnly bool     `json:"timing_only"`
+	Tags       []string `json:"tags,omitempty"`
+}
+
+func newMockListEntry(resp *MockResponse) mockListEntry {
+	return mockListEntry{
+		RequestID:   resp.RequestID,
+		Path:        resp.Path,
+		Method:      resp.Method,
+		MockID:      resp.MockID,
+		ContentType: resp.ContentType,
+		StatusCode:  resp.StatusCode,
+		FullURL:     resp.FullURL,
+		Note:        resp.Note,
+	}
+}
+
+// scenarioListEntry lists a scenario by the response it serves.
+func scenarioListEntry(scenario *mockScenario) interface{} {
+	if scenario.response == nil {
+		return timingOnlyEntry{
+			Path:       scenario.path,
+			Method:     scenario.method,
+			MockID:     scenario.name,
+			TimingOnly: true,
+			Tags:       scenario.tags,
+		}
+	}
+	entry := newMockListEntry(scenario.response)
+	entry.Tags = scenario.tags
+	return entry
+}
+
+// WriteMockListJSON streams the /__mock__/list JSON to w one mock at a time,
+// so the list of a large corpus is never held in memory as a whole.
+func (s *MockStorage) WriteMockListJSON(w io.Writer) error {
+	// Only the pointers are copied under the lock; entries are encoded after
+	s.mutex.RLock()
+	var scenarios []*mockScenario
+	var responses []*MockResponse
+	if s.scenariosEnabled {
+		scenarios = s.scenarioOrder
+	} else {
+		for _, indexed := range s.Responses {
+			responses = append(responses, indexed...)
+		}
+	}
+	s.mutex.RUnlock()
+
+	jw := newJSONStreamWriter(w)
+	jw.raw(`{"mocks":[`)
+	for i, scenario := range scenarios {
+		if i > 0 {
+			jw.raw(",")
+		}
+		jw.value(scenarioListEntry(scenario))
+	}
+	for i, resp := range responses {
+		if i > 0 {
+			jw.raw(",")
+		}
+		jw.value(newMockListEntry(resp))
+	}
+	jw.raw(`],"total":` + strconv.Itoa(len(scenarios)+len(responses)) + "}")
+	return jw.flush()
+}
+
+// WriteStatsJSON streams the /__mock__/stats JSON to w, with the request
+// counters as of the call. Keys are sorted and lists are encoded element by
+// element, so long path and hit lists are never buffered whole.
+func (s *MockStorage) WriteStatsJSON(w io.Writer) error {
+	stats := s.liveStats()
+	keys := make([]string, 0, len(stats))
+	for key := range stats {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	jw := newJSONStreamWriter(w)
+	jw.raw("{")
+	for i, key := range keys {
+		if i > 0 {
+			jw.raw(",")
+		}
+		jw.value(key)
+		jw.raw(":")
+		jw.list(stats[key])
+	}
+	jw.raw("}")
+	return jw.flush()
+}
+
+// jsonStreamWriter writes a JSON document piece by piece and keeps the first
+// error, so callers check it once at the end.
+type jsonStreamWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func newJSONStreamWriter(w io.Writer) *jsonStreamWriter {
+	bw, ok := w.(*bufio.Writer)
+	if !ok {
+		bw = bufio.NewWriter(w)
+	}
+	return &jsonStreamWriter{w: bw}
+}
+
+// raw writes JSON punctuation or pre-encoded text.
+func (jw *jsonStreamWriter) raw(text string) {
+	if jw.err == nil {
+		_, jw.err = jw.w.WriteString(text)
+	}
+}
+
+// value encodes v; only v itself is ever buffered.
+func (jw *jsonStreamWriter) value(v interface{}) {
+	if jw.err != nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		jw.err = err
+		return
+	}
+	_, jw.err = jw.w.Write(data)
+}
+
+// list encodes v, writing slices one element at a time.
+func (jw *jsonStreamWriter) list(v interface{}) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 || rv.IsNil() {
+		jw.value(v)
+		return
+	}
+	jw.raw("[")
+	for i := 0; i < rv.Len(); i++ {
+		if i > 0 {
+			jw.raw(",")
+		}
+		jw.value(rv.Index(i).Interface())
+	}
+	jw.raw("]")
+}
+
+func (jw *jsonStreamWriter) flush() error {
+	if jw.err != nil {
+		return jw.err
+	}
+	return jw.w.Flush()
+}
@@ -0,0 +1,367 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"github.com/valyala/fasthttp"
+)
+
+// Template is a pre-compiled response template. Placeholders use the form
+// {{ expr }} where expr is either a request reference (request.body.id,
+// request.headers.Authorization, request.query.page, request.path_params.id,
+// request.path, request.method, request.url), a session variable captured by a
+// scenario (session.order_id) or a function call such as {{now}} or
+// {{randInt 1 100}}.
+// ${ENV:NAME} placeholders are replaced by the environment variable NAME.
+type Template struct {
+	segments []templateSegment
+}
+
+type templateSegment struct {
+	literal []byte
+	expr    *templateExpr // nil for literal segments
+	env     string        // Environment variable name for ${ENV:NAME} segments
+}
+
+// envPrefix opens an environment variable placeholder.
+const envPrefix = "${ENV:"
+
+type templateExpr struct {
+	fn   TemplateFunc // nil when the expression is a plain reference
+	args []templateArg
+}
+
+type templateArg struct {
+	literal string
+	ref     []string // Non-nil when the argument is a request reference
+}
+
+// TemplateFunc renders a function placeholder such as {{sign "secret" request.body}}
+// from its evaluated arguments: quoted strings as written, request references
+// resolved against the live request. An error is rendered inline.
+type TemplateFunc func(ctx *fasthttp.RequestCtx, args []string) (string, error)
+
+var (
+	// templateFuncs holds the built-in and registered template functions.
+	templateFuncs = map[string]TemplateFunc{
+		"now":     templateNow,
+		"uuid":    templateUUID,
+		"randInt": templateRandInt,
+		"fake":    templateFake,
+	}
+	templateFuncsMutex sync.RWMutex
+)
+
+// RegisterTemplateFunc makes fn available to response templates as name, e.g.
+// a company-specific ID generator or a signing helper. Templates are compiled
+// when mocks are loaded, so register before creating the storage. Names must
+// be single words and cannot replace a function already registered.
+func RegisterTemplateFunc(name string, fn TemplateFunc) error {
+	if fn == nil {
+		return fmt.Errorf("template function %q is nil", name)
+	}
+	if name == "" || isTemplateRef(name) || strings.ContainsAny(name, " \t\"{}") {
+		return fmt.Errorf("invalid template function name %q", name)
+	}
+
+	templateFuncsMutex.Lock()
+	defer templateFuncsMutex.Unlock()
+	if _, exists := templateFuncs[name]; exists {
+		return fmt.Errorf("template function %q is already registered", name)
+	}
+	templateFuncs[name] = fn
+	return nil
+}
+
+// lookupTemplateFunc returns the template function registered as name.
+func lookupTemplateFunc(name string) (TemplateFunc, bool) {
+	templateFuncsMutex.RLock()
+	defer templateFuncsMutex.RUnlock()
+	fn, ok := templateFuncs[name]
+	return fn, ok
+}
+
+// templateNow renders the current time, in HTTP date format unless a Go layout is given.
+func templateNow(_ *fasthttp.RequestCtx, args []string) (string, error) {
+	if len(args) > 0 {
+		return time.Now().Format(args[0]), nil
+	}
+	return time.Now().UTC().Format(http.TimeFormat), nil
+}
+
+// CompileTemplate parses text into a Template. It returns nil when the text
+// contains no placeholders so callers can skip rendering entirely.
+func CompileTemplate(text string) (*Template, error) {
+	if !strings.Contains(text, "{{") {
+		return CompileEnvTemplate(text), nil
+	}
+
+	tmpl := &Template{}
+	rest := text
+	for {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			tmpl.appendLiteral(rest)
+			break
+		}
+		end := strings.Index(rest[start:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated placeholder in template: %q", rest[start:])
+		}
+		end += start
+
+		tmpl.appendLiteral(rest[:start])
+
+		expr, err := parseTemplateExpr(strings.TrimSpace(rest[start+2 : end]))
+		if err != nil {
+			return nil, err
+		}
+		tmpl.segments = append(tmpl.segments, templateSegment{expr: expr})
+		rest = rest[end+2:]
+	}
+
+	return tmpl, nil
+}
+
+// CompileEnvTemplate compiles only the ${ENV:NAME} placeholders of text, which
+// are expanded in every recorded body, and leaves {{ }} untouched. It returns
+// nil when there are none.
+func CompileEnvTemplate(text string) *Template {
+	if !strings.Contains(text, envPrefix) {
+		return nil
+	}
+	tmpl := &Template{}
+	tmpl.appendLiteral(text)
+	if len(tmpl.segments) == 1 && tmpl.segments[0].env == "" {
+		return nil // Only malformed placeholders, kept as text
+	}
+	return tmpl
+}
+
+// appendLiteral adds text as literal segments, split around ${ENV:NAME}
+// placeholders. Malformed placeholders stay literal text, as recorded bodies
+// may contain the sequence by accident.
+func (t *Template) appendLiteral(text string) {
+	var literal []byte
+	for {
+		start := strings.Index(text, envPrefix)
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(text[start:], '}')
+		if end < 0 || !isEnvName(text[start+len(envPrefix):start+end]) {
+			literal = append(literal, text[:start+len(envPrefix)]...)
+			text = text[start+len(envPrefix):]
+			continue
+		}
+
+		literal = append(literal, text[:start]...)
+		if len(literal) > 0 {
+			t.segments = append(t.segments, templateSegment{literal: literal})
+			literal = nil
+		}
+		t.segments = append(t.segments, templateSegment{env: text[start+len(envPrefix) : start+end]})
+		text = text[start+end+1:]
+	}
+	literal = append(literal, text...)
+	if len(literal) > 0 {
+		t.segments = append(t.segments, templateSegment{literal: literal})
+	}
+}
+
+// isEnvName reports whether name is a valid environment variable name.
+func isEnvName(name string) bool {
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c != '_' && (c < 'A' || c > 'Z') && (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTemplateExpr parses the contents of a single placeholder.
+func parseTemplateExpr(source string) (*templateExpr, error) {
+	tokens, err := tokenizeTemplateExpr(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty placeholder in template")
+	}
+
+	expr := &templateExpr{}
+	head := tokens[0]
+	if fn, ok := lookupTemplateFunc(head.text); ok && !head.quoted {
+		expr.fn = fn
+		tokens = tokens[1:]
+	} else if len(tokens) > 1 {
+		return nil, fmt.Errorf("unknown template function %q", head.text)
+	}
+
+	for _, tok := range tokens {
+		if tok.quoted || !isTemplateRef(tok.text) {
+			if expr.fn == nil {
+				return nil, fmt.Errorf("unknown template reference %q", tok.text)
+			}
+			expr.args = append(expr.args, templateArg{literal: tok.text})
+			continue
+		}
+
+		ref := strings.SplitN(tok.text, ".", 3)
+		if err := validateTemplateRef(ref); err != nil {
+			return nil, err
+		}
+		expr.args = append(expr.args, templateArg{ref: ref})
+	}
+
+	return expr, nil
+}
+
+// isTemplateRef reports whether a placeholder token refers to the request or
+// to a session variable rather than being a literal.
+func isTemplateRef(text string) bool {
+	return text == "request" || strings.HasPrefix(text, "request.") || strings.HasPrefix(text, "session.")
+}
+
+type templateToken struct {
+	text   string
+	quoted bool
+}
+
+// tokenizeTemplateExpr splits on whitespace while keeping double-quoted strings intact.
+func tokenizeTemplateExpr(source string) ([]templateToken, error) {
+	var tokens []templateToken
+	for i := 0; i < len(source); {
+		switch {
+		case source[i] == ' ' || source[i] == '\t':
+			i++
+		case source[i] == '"':
+			end := strings.IndexByte(source[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in placeholder %q", source)
+			}
+			tokens = append(tokens, templateToken{text: source[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		default:
+			end := strings.IndexAny(source[i:], " \t")
+			if end < 0 {
+				end = len(source) - i
+			}
+			tokens = append(tokens, templateToken{text: source[i : i+end]})
+			i += end
+		}
+	}
+	return tokens, nil
+}
+
+// validateTemplateRef checks a split reference ("request", section, name) or
+// ("session", name).
+func validateTemplateRef(ref []string) error {
+	if len(ref) < 2 {
+		return fmt.Errorf("incomplete template reference %q", strings.Join(ref, "."))
+	}
+	if ref[0] == "session" {
+		if len(ref) == 2 && isEnvName(ref[1]) {
+			return nil
+		}
+		return fmt.Errorf("unknown template reference %q", strings.Join(ref, "."))
+	}
+	switch ref[1] {
+	case "method", "path", "url":
+		if len(ref) == 2 {
+			return nil
+		}
+	case "body":
+		return nil
+	case "headers", "query", "path_params":
+		if len(ref) == 3 && ref[2] != "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown template reference %q", strings.Join(ref, "."))
+}
+
+// resolveTemplateRef evaluates a request reference against the live request.
+func resolveTemplateRef(ctx *fasthttp.RequestCtx, ref []string) string {
+	if ref[0] == "session" {
+		return sessionVar(ctx, ref[1])
+	}
+	switch ref[1] {
+	case "method":
+		return string(ctx.Method())
+	case "path":
+		return string(ctx.Path())
+	case "url":
+		return string(ctx.RequestURI())
+	case "headers":
+		return string(ctx.Request.Header.Peek(ref[2]))
+	case "query":
+		return string(ctx.QueryArgs().Peek(ref[2]))
+	case "path_params":
+		return pathParam(ctx, ref[2])
+	case "body":
+		if len(ref) == 2 {
+			return string(ctx.PostBody())
+		}
+		result := gjson.GetBytes(ctx.PostBody(), ref[2])
+		if result.Type == gjson.String {
+			return result.Str
+		}
+		return result.Raw
+	}
+	return ""
+}
+
+// Render evaluates the template for the given request. Function errors are
+// rendered inline so a broken placeholder is visible in the served response.
+func (t *Template) Render(ctx *fasthttp.RequestCtx) []byte {
+	var buf bytes.Buffer
+	for _, seg := range t.segments {
+		switch {
+		case seg.expr != nil:
+			buf.WriteString(seg.expr.eval(ctx))
+		case seg.env != "":
+			buf.WriteString(os.Getenv(seg.env))
+		default:
+			buf.Write(seg.literal)
+		}
+	}
+	return buf.Bytes()
+}
+
+// RenderString is a convenience wrapper returning the rendered text as a string.
+func (t *Template) RenderString(ctx *fasthttp.RequestCtx) string {
+	return string(t.Render(ctx))
+}
+
+func (e *templateExpr) eval(ctx *fasthttp.RequestCtx) string {
+	args := make([]string, len(e.args))
+	for i, arg := range e.args {
+		if arg.ref != nil {
+			args[i] = resolveTemplateRef(ctx, arg.ref)
+		} else {
+			args[i] = arg.literal
+		}
+	}
+
+	if e.fn == nil {
+		return args[0]
+	}
+
+	out, err := e.fn(ctx, args)
+	if err != nil {
+		return "<template error: " + err.Error() + ">"
+	}
+	return out
+}
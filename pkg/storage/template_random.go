@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// templateRandKey holds the random source of a request among its user values.
+const templateRandKey = "__mock_template_rand"
+
+var (
+	templateSeeded atomic.Bool
+	templateSeed   atomic.Int64
+)
+
+// SetTemplateSeed makes the {{uuid}}, {{randInt}} and {{fake}} template
+// functions deterministic: each request draws from a source seeded with seed
+// and a hash of its method, URL and body, so the same request renders the same
+// values in every run while different requests still differ.
+func SetTemplateSeed(seed int64) {
+	templateSeed.Store(seed)
+	templateSeeded.Store(true)
+}
+
+// templateRand returns the random source of the request, created on first
+// use so repeated placeholders in one response draw different values.
+func templateRand(ctx *fasthttp.RequestCtx) *rand.Rand {
+	if ctx == nil {
+		return rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	if r, ok := ctx.UserValue(templateRandKey).(*rand.Rand); ok {
+		return r
+	}
+
+	seed := time.Now().UnixNano() ^ rand.Int63()
+	if templateSeeded.Load() {
+		h := fnv.New64a()
+		var seedBytes [8]byte
+		binary.LittleEndian.PutUint64(seedBytes[:], uint64(templateSeed.Load()))
+		h.Write(seedBytes[:])
+		h.Write(ctx.Method())
+		h.Write([]byte{' '})
+		h.Write(ctx.RequestURI())
+		h.Write([]byte{'\n'})
+		h.Write(ctx.PostBody())
+		seed = int64(h.Sum64())
+	}
+	r := rand.New(rand.NewSource(seed))
+	ctx.SetUserValue(templateRandKey, r)
+	return r
+}
+
+// templateUUID renders a random version 4 UUID.
+func templateUUID(ctx *fasthttp.RequestCtx, _ []string) (string, error) {
+	var id [16]byte
+	templateRand(ctx).Read(id[:])
+	id[6] = id[6]&0x0f | 0x40 // Version 4
+	id[8] = id[8]&0x3f | 0x80 // RFC 4122 variant
+
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], id[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], id[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], id[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], id[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], id[10:])
+	return string(buf), nil
+}
+
+// templateRandInt renders a random integer between its two arguments, inclusive.
+func templateRandInt(ctx *fasthttp.RequestCtx, args []string) (string, error) {
+	if len(args) != 2 {
+		return "", fmt.Errorf("randInt takes a minimum and a maximum")
+	}
+	min, err := strconv.ParseInt(strings.TrimSpace(args[0]), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("randInt minimum %q is not an integer", args[0])
+	}
+	max, err := strconv.ParseInt(strings.TrimSpace(args[1]), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("randInt maximum %q is not an integer", args[1])
+	}
+	if max < min {
+		return "", fmt.Errorf("randInt maximum %d is below the minimum %d", max, min)
+	}
+	return strconv.FormatInt(min+templateRand(ctx).Int63n(max-min+1), 10), nil
+}
+
+// Word lists of the fake data generators.
+var (
+	fakeFirstNames = []string{"Alice", "Bob", "Carol", "David", "Emma", "Frank", "Grace", "Henry", "Isla", "Jack", "Maria", "Noah", "Olivia", "Sofia", "Liam", "Yuki"}
+	fakeLastNames  = []string{"Smith", "Johnson", "Garcia", "Miller", "Davis", "Martin", "Lee", "Walker", "Young", "King", "Novak", "Ivanova", "Rossi", "Tanaka", "Silva", "Brown"}
+	fakeCompanies  = []string{"Acme", "Globex", "Initech", "Umbrella", "Hooli", "Stark", "Wayne", "Wonka", "Cyberdyne", "Soylent"}
+	fakeSuffixes   = []string{"Inc", "LLC", "Ltd", "Group", "Labs", "Systems"}
+	fakeCities     = []string{"Berlin", "Lisbon", "Toronto", "Osaka", "Austin", "Melbourne", "Prague", "Oslo", "Denver", "Seoul"}
+	fakeCountries  = []string{"Germany", "Portugal", "Canada", "Japan", "United States", "Australia", "Czechia", "Norway", "Brazil", "South Korea"}
+	fakeStreets    = []string{"Main St", "Oak Ave", "Maple Rd", "Cedar Ln", "Park Blvd", "Elm St", "Hill Rd", "Lake Dr"}
+	fakeDomains    = []string{"example.com", "example.org", "example.net", "test.dev"}
+	fakeWords      = []string{"alpha", "bravo", "delta", "echo", "lorem", "ipsum", "dolor", "amet", "nova", "orbit", "pixel", "quartz", "river", "signal", "vector", "zephyr"}
+)
+
+// fakeGenerators render one kind of fake data for {{fake "kind"}}.
+var fakeGenerators = map[string]func(r *rand.Rand) string{
+	"first_name": func(r *rand.Rand) string { return pick(r, fakeFirstNames) },
+	"last_name":  func(r *rand.Rand) string { return pick(r, fakeLastNames) },
+	"name": func(r *rand.Rand) string {
+		return pick(r, fakeFirstNames) + " " + pick(r, fakeLastNames)
+	},
+	"username": func(r *rand.Rand) string {
+		return strings.ToLower(pick(r, fakeFirstNames)) + strconv.Itoa(r.Intn(1000))
+	},
+	"email": func(r *rand.Rand) string {
+		return strings.ToLower(pick(r, fakeFirstNames)+"."+pick(r, fakeLastNames)) + "@" + pick(r, fakeDomains)
+	},
+	"phone": func(r *rand.Rand) string {
+		return fmt.Sprintf("+1-%03d-%03d-%04d", 200+r.Intn(800), r.Intn(1000), r.Intn(10000))
+	},
+	"company": func(r *rand.Rand) string {
+		return pick(r, fakeCompanies) + " " + pick(r, fakeSuffixes)
+	},
+	"city":    func(r *rand.Rand) string { return pick(r, fakeCities) },
+	"country": func(r *rand.Rand) string { return pick(r, fakeCountries) },
+	"street": func(r *rand.Rand) string {
+		return strconv.Itoa(1+r.Intn(9999)) + " " + pick(r, fakeStreets)
+	},
+	"word": func(r *rand.Rand) string { return pick(r, fakeWords) },
+	"sentence": func(r *rand.Rand) string {
+		words := make([]string, 4+r.Intn(5))
+		for i := range words {
+			words[i] = pick(r, fakeWords)
+		}
+		return strings.ToUpper(words[0][:1]) + strings.Join(words, " ")[1:] + "."
+	},
+	"ipv4": func(r *rand.Rand) string {
+		return fmt.Sprintf("%d.%d.%d.%d", 1+r.Intn(223), r.Intn(256), r.Intn(256), 1+r.Intn(254))
+	},
+}
+
+func pick(r *rand.Rand, values []string) string {
+	return values[r.Intn(len(values))]
+}
+
+// templateFake renders fake data of the kind named by its argument.
+func templateFake(ctx *fasthttp.RequestCtx, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("fake takes the kind of data, e.g. fake \"email\"")
+	}
+	generate, ok := fakeGenerators[args[0]]
+	if !ok {
+		kinds := make([]string, 0, len(fakeGenerators))
+		for kind := range fakeGenerators {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		return "", fmt.Errorf("unknown fake %q (expected %s)", args[0], strings.Join(kinds, ", "))
+	}
+	return generate(templateRand(ctx)), nil
+}
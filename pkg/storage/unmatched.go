@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+)
+
+// UnmatchedTracker counts requests that did not match any mock, keyed by method and path.
+type UnmatchedTracker struct {
+	mutex  sync.Mutex
+	counts map[string]*UnmatchedEntry
+	total  int
+}
+
+// UnmatchedEntry summarizes unmatched requests for one method+path.
+type UnmatchedEntry struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Count  int    `json:"count"`
+}
+
+// NewUnmatchedTracker creates an empty tracker.
+func NewUnmatchedTracker() *UnmatchedTracker {
+	return &UnmatchedTracker{counts: make(map[string]*UnmatchedEntry)}
+}
+
+// Record counts one unmatched request.
+func (t *UnmatchedTracker) Record(method, path string) {
+	key := method + " " + path
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	entry, ok := t.counts[key]
+	if !ok {
+		entry = &UnmatchedEntry{Method: method, Path: path}
+		t.counts[key] = entry
+	}
+	entry.Count++
+	t.total++
+}
+
+// Total returns the number of unmatched requests seen so far.
+func (t *UnmatchedTracker) Total() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.total
+}
+
+// Reset forgets the unmatched requests seen so far.
+func (t *UnmatchedTracker) Reset() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.counts = make(map[string]*UnmatchedEntry)
+	t.total = 0
+}
+
+// Summary returns unmatched endpoints, most frequent first.
+func (t *UnmatchedTracker) Summary() []UnmatchedEntry {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	entries := make([]UnmatchedEntry, 0, len(t.counts))
+	for _, entry := range t.counts {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		if entries[i].Path != entries[j].Path {
+			return entries[i].Path < entries[j].Path
+		}
+		return entries[i].Method < entries[j].Method
+	})
+	return entries
+}
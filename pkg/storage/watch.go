@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce coalesces the burst of events an editor or a recorder emits
+// while writing one file, so it is parsed once it is complete.
+const watchDebounce = 100 * time.Millisecond
+
+// Watcher re-indexes the recordings of a mock directory as they are added,
+// changed or removed. Scenario configs and the files they reference are not
+// reloaded.
+type Watcher struct {
+	store    *MockStorage
+	watcher  *fsnotify.Watcher
+	onReload func(file string, err error)
+
+	mutex   sync.Mutex
+	pending map[string]*time.Timer
+	closed  bool
+	done    chan struct{}
+}
+
+// Watch starts watching BaseDir and its mock ID directories. onReload, when
+// not nil, is called after each file is re-indexed or removed, with the
+// error that made a changed file unloadable (the file is then listed by
+// FailedFiles, and its previous responses are no longer served).
+func (s *MockStorage) Watch(onReload func(file string, err error)) (*Watcher, error) {
+	if err := os.MkdirAll(s.BaseDir, 0755); err != nil {
+		return nil, err
+	}
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{
+		store:    s,
+		watcher:  fsWatcher,
+		onReload: onReload,
+		pending:  make(map[string]*time.Timer),
+		done:     make(chan struct{}),
+	}
+
+	if err := fsWatcher.Add(s.BaseDir); err != nil {
+		fsWatcher.Close()
+		return nil, err
+	}
+	entries, err := os.ReadDir(s.BaseDir)
+	if err != nil {
+		fsWatcher.Close()
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			if err := fsWatcher.Add(filepath.Join(s.BaseDir, entry.Name())); err != nil {
+				fsWatcher.Close()
+				return nil, err
+			}
+		}
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// Close stops watching. Changes still waiting for the debounce are dropped.
+func (w *Watcher) Close() error {
+	w.mutex.Lock()
+	w.closed = true
+	for _, timer := range w.pending {
+		timer.Stop()
+	}
+	w.mutex.Unlock()
+
+	err := w.watcher.Close()
+	<-w.done
+	return err
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+	baseDir := filepath.Clean(w.store.BaseDir)
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			path := filepath.Clean(event.Name)
+
+			// A new mock ID directory: watch it and pick up files already written into it
+			if filepath.Dir(path) == baseDir {
+				if event.Has(fsnotify.Create) {
+					if info, err := os.Stat(path); err == nil && info.IsDir() {
+						w.watcher.Add(path)
+						if files, err := os.ReadDir(path); err == nil {
+							for _, file := range files {
+								w.schedule(filepath.Join(path, file.Name()))
+							}
+						}
+					}
+				} else if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+					w.removeDir(path)
+				}
+				continue
+			}
+			w.schedule(path)
+
+		case _, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// schedule reloads path once no event arrived for it during watchDebounce.
+func (w *Watcher) schedule(path string) {
+	if !isRecordingFile(path) {
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return
+	}
+	if timer, ok := w.pending[path]; ok {
+		timer.Reset(watchDebounce)
+		return
+	}
+	w.pending[path] = time.AfterFunc(watchDebounce, func() {
+		w.mutex.Lock()
+		delete(w.pending, path)
+		closed := w.closed
+		w.mutex.Unlock()
+		if closed {
+			return
+		}
+
+		err := w.store.reloadFile(path)
+		if w.onReload != nil {
+			w.onReload(path, err)
+		}
+	})
+}
+
+// removeDir drops the responses of a deleted mock ID directory.
+func (w *Watcher) removeDir(dir string) {
+	w.store.mutex.Lock()
+	var files []string
+	seen := make(map[string]bool)
+	for _, responses := range w.store.Responses {
+		for _, mockResponse := range responses {
+			if filepath.Dir(mockResponse.SourceFile) == dir && !seen[mockResponse.SourceFile] {
+				seen[mockResponse.SourceFile] = true
+				files = append(files, mockResponse.SourceFile)
+			}
+		}
+	}
+	w.store.mutex.Unlock()
+
+	for _, file := range files {
+		w.schedule(file)
+	}
+}
+
+// reloadFile re-indexes the responses of one recording in BaseDir, or drops
+// them when the file is gone.
+func (s *MockStorage) reloadFile(path string) error {
+	mockID := filepath.Base(filepath.Dir(path))
+	responses, err := loadResponsesFromFile(path, mockID)
+	if os.IsNotExist(err) {
+		responses, err = nil, nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Persisted admin mocks are indexed by the admin API already
+	id := strings.TrimSuffix(filepath.Base(path), ".json")
+	if s.adminMocks[id] != nil {
+		return nil
+	}
+
+	s.unindexFile(path)
+	failed := s.failedFiles[:0:0]
+	for _, failure := range s.failedFiles {
+		if filepath.Clean(failure.File) != path {
+			failed = append(failed, failure)
+		}
+	}
+	s.failedFiles = failed
+
+	if err != nil {
+		s.failedFiles = append(s.failedFiles, LoadFailure{File: path, Error: err.Error()})
+	}
+	for _, mockResponse := range responses {
+		mockResponse.SourceFile = path
+		if !s.attachRevalidation(mockResponse) {
+			s.indexResponse(mockResponse)
+		}
+	}
+	s.cacheResponses()
+	return err
+}
+
+// unindexFile removes every response loaded from path from the lookup
+// indexes and unlinks the 304s it recorded. Callers hold the write lock.
+func (s *MockStorage) unindexFile(path string) {
+	var stale []*MockResponse
+	for _, responses := range s.Responses {
+		for _, mockResponse := range responses {
+			if mockResponse.SourceFile == path {
+				stale = append(stale, mockResponse)
+			}
+			if mockResponse.NotModified != nil && mockResponse.NotModified.SourceFile == path {
+				mockResponse.NotModified = nil
+			}
+		}
+	}
+	for _, mockResponse := range stale {
+		s.unindexResponse(mockResponse)
+	}
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// WebSocket opcodes (RFC 6455 section 5.2).
+const (
+	WebSocketContinuation byte = 0x0
+	WebSocketText         byte = 0x1
+	WebSocketBinary       byte = 0x2
+	WebSocketClose        byte = 0x8
+	WebSocketPing         byte = 0x9
+	WebSocketPong         byte = 0xA
+)
+
+// WebSocketContentType is the index content type of WebSocket records, which have no body.
+const WebSocketContentType = "websocket"
+
+// maxWebSocketPayload bounds a single frame so a corrupt length cannot exhaust memory.
+const maxWebSocketPayload = 64 << 20
+
+// websocketGUID is appended to Sec-WebSocket-Key to derive Sec-WebSocket-Accept.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var websocketOpcodeNames = map[byte]string{
+	WebSocketContinuation: "continuation",
+	WebSocketText:         "text",
+	WebSocketBinary:       "binary",
+	WebSocketClose:        "close",
+	WebSocketPing:         "ping",
+	WebSocketPong:         "pong",
+}
+
+// WebSocketFrame is a single frame of a recorded WebSocket conversation.
+type WebSocketFrame struct {
+	FromClient bool    // Sent by the client (true) or the server (false)
+	Timestamp  float64 // Seconds since the handshake completed
+	Opcode     byte
+	Fin        bool   // Final fragment of a message
+	Payload    []byte // Unmasked payload
+}
+
+// CloseCode returns the status code and reason of a close frame; 1005 (no status) when absent.
+func (f WebSocketFrame) CloseCode() (int, string) {
+	if len(f.Payload) < 2 {
+		return 1005, ""
+	}
+	return int(binary.BigEndian.Uint16(f.Payload)), string(f.Payload[2:])
+}
+
+// Record converts the frame to its JSON form. Text payloads are stored as
+// strings; binary or non-UTF-8 payloads are base64 with "encoding": "base64".
+func (f WebSocketFrame) Record() map[string]interface{} {
+	direction := "server"
+	if f.FromClient {
+		direction = "client"
+	}
+	name, ok := websocketOpcodeNames[f.Opcode]
+	if !ok {
+		name = fmt.Sprintf("0x%x", f.Opcode)
+	}
+
+	record := map[string]interface{}{
+		"direction": direction,
+		"timestamp": f.Timestamp,
+		"type":      name,
+	}
+	if !f.Fin {
+		record["fin"] = false
+	}
+
+	if f.Opcode == WebSocketClose {
+		if len(f.Payload) >= 2 {
+			code, reason := f.CloseCode()
+			record["code"] = code
+			record["reason"] = reason
+		}
+		return record
+	}
+
+	if f.Opcode != WebSocketBinary && utf8.Valid(f.Payload) {
+		record["data"] = string(f.Payload)
+	} else {
+		record["data"] = base64.StdEncoding.EncodeToString(f.Payload)
+		record["encoding"] = "base64"
+	}
+	return record
+}
+
+// parseWebSocketFrame is the inverse of WebSocketFrame.Record.
+func parseWebSocketFrame(item map[string]interface{}) (WebSocketFrame, error) {
+	frame := WebSocketFrame{Fin: true}
+	frame.FromClient = item["direction"] == "client"
+	frame.Timestamp, _ = item["timestamp"].(float64)
+	if fin, ok := item["fin"].(bool); ok {
+		frame.Fin = fin
+	}
+
+	name, _ := item["type"].(string)
+	found := false
+	for opcode, opcodeName := range websocketOpcodeNames {
+		if opcodeName == name {
+			frame.Opcode = opcode
+			found = true
+			break
+		}
+	}
+	if !found {
+		return frame, fmt.Errorf("unknown websocket frame type %q", name)
+	}
+
+	if frame.Opcode == WebSocketClose {
+		if code, ok := item["code"].(float64); ok {
+			reason, _ := item["reason"].(string)
+			frame.Payload = binary.BigEndian.AppendUint16(nil, uint16(code))
+			frame.Payload = append(frame.Payload, reason...)
+		}
+		return frame, nil
+	}
+
+	data, _ := item["data"].(string)
+	if item["encoding"] == "base64" {
+		payload, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return frame, fmt.Errorf("websocket frame payload: %w", err)
+		}
+		frame.Payload = payload
+	} else {
+		frame.Payload = []byte(data)
+	}
+	return frame, nil
+}
+
+// parseWebSocketFrames decodes the recorded frame list of a websocket record.
+func parseWebSocketFrames(body interface{}) ([]WebSocketFrame, error) {
+	items, _ := body.([]interface{})
+	frames := make([]WebSocketFrame, 0, len(items))
+	for _, item := range items {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, errInvalidRecord
+		}
+		frame, err := parseWebSocketFrame(itemMap)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// ReadWebSocketFrame reads one frame. It returns the decoded frame together
+// with the exact bytes read, so proxies can relay frames untouched.
+func ReadWebSocketFrame(r *bufio.Reader) (WebSocketFrame, []byte, error) {
+	var frame WebSocketFrame
+
+	raw := make([]byte, 2, 14)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return frame, nil, err
+	}
+	frame.Fin = raw[0]&0x80 != 0
+	frame.Opcode = raw[0] & 0x0F
+	masked := raw[1]&0x80 != 0
+
+	length := uint64(raw[1] & 0x7F)
+	extra := 0
+	switch length {
+	case 126:
+		extra = 2
+	case 127:
+		extra = 8
+	}
+	if masked {
+		extra += 4
+	}
+	raw = raw[:2+extra]
+	if _, err := io.ReadFull(r, raw[2:]); err != nil {
+		return frame, nil, err
+	}
+
+	offset := 2
+	switch length {
+	case 126:
+		length = uint64(binary.BigEndian.Uint16(raw[2:]))
+		offset += 2
+	case 127:
+		length = binary.BigEndian.Uint64(raw[2:])
+		offset += 8
+	}
+	if length > maxWebSocketPayload {
+		return frame, nil, fmt.Errorf("websocket frame of %d bytes exceeds the %d byte limit", length, maxWebSocketPayload)
+	}
+
+	header := len(raw)
+	raw = append(raw, make([]byte, length)...)
+	if _, err := io.ReadFull(r, raw[header:]); err != nil {
+		return frame, nil, err
+	}
+
+	frame.Payload = append([]byte(nil), raw[header:]...)
+	if masked {
+		key := raw[offset : offset+4]
+		for i := range frame.Payload {
+			frame.Payload[i] ^= key[i%4]
+		}
+	}
+	return frame, raw, nil
+}
+
+// AppendWebSocketFrame appends an unmasked (server-to-client) frame.
+func AppendWebSocketFrame(dst []byte, opcode byte, fin bool, payload []byte) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	dst = append(dst, first)
+
+	switch n := len(payload); {
+	case n < 126:
+		dst = append(dst, byte(n))
+	case n <= 0xFFFF:
+		dst = append(dst, 126)
+		dst = binary.BigEndian.AppendUint16(dst, uint16(n))
+	default:
+		dst = append(dst, 127)
+		dst = binary.BigEndian.AppendUint64(dst, uint64(n))
+	}
+	return append(dst, payload...)
+}
+
+// WebSocketAccept derives the Sec-WebSocket-Accept value for a Sec-WebSocket-Key.
+func WebSocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}